@@ -2,23 +2,29 @@ package app
 
 import (
 "bytes"
+"errors"
 "fmt"
+"log"
 "os"
 "path/filepath"
 "runtime"
 "strings"
 "sync"
+"time"
 
 "passquantum/core/crypto"
+"passquantum/core/filevault"
 "passquantum/core/model"
 "passquantum/core/storage"
+securestorage "passquantum/internal/storage"
 )
 
 const appSecurityMetadataPath = storage.DefaultAppSecurityMetadataPath
 
 // maxRotationWorkers caps how many vaults are re-encrypted in parallel during a
-// master-password change. Each ReencryptVaultFile runs Argon2id (64 MB) for both
-// the old and new password, so this bounds peak memory regardless of core count.
+// master-password change. Each ReencryptVaultFile runs Argon2id (64 MB, up to
+// 256 MB once calibrated) for both the old and new password, so this bounds
+// peak memory regardless of core count.
 const maxRotationWorkers = 4
 
 // StartupAccessState describes what the login screen should present on startup.
@@ -27,9 +33,12 @@ RequiresSetup bool
 Warning       string
 }
 
+// preparedVaultRotation is a vault or snapshot file re-encrypted for a new
+// master password, with the snapshot's copy of the security profile if it
+// has one.
 type preparedVaultRotation struct {
 path     string
-tempPath string
+metadata string
 data     []byte
 }
 
@@ -45,6 +54,14 @@ if err != nil {
 return StartupAccessState{}, err
 }
 
+// A wrapped private.key is only opened by unlocking, which checks the
+// fingerprint then.
+if appState.PrivateKey == nil {
+appState.SecurityProfile = profile
+appState.StartupWarning = ""
+return StartupAccessState{RequiresSetup: false}, nil
+}
+
 fingerprint, err := crypto.PrivateKeyFingerprint(appState.PrivateKey)
 if err != nil {
 return StartupAccessState{}, err
@@ -62,8 +79,25 @@ appState.StartupWarning = ""
 return StartupAccessState{RequiresSetup: false}, nil
 }
 
+// CreateMasterPasswordProfile sets up the master password and wraps
+// private.key under it.
 func CreateMasterPasswordProfile(appState *AppState, masterPassword string) error {
-profile, sessionEncryptionKey, sessionVerificationKey, err := crypto.CreateAppSecurityProfile(masterPassword, appState.PrivateKey)
+privateKey := appState.PrivateKey
+if privateKey == nil {
+var err error
+privateKey, err = LoadStoredPrivateKey(masterPassword)
+if err != nil {
+return fmt.Errorf("private.key is encrypted under a different master password: %w", err)
+}
+}
+
+// New profiles are derived at a cost benchmarked for this machine.
+if _, err := calibrateKDFPolicy(); err != nil {
+log.Printf("[Vault] WARNING: could not calibrate the KDF, using the policy floor: %v", err)
+applyKDFPolicy(nil)
+}
+
+profile, sessionEncryptionKey, sessionVerificationKey, err := crypto.CreateAppSecurityProfile(masterPassword, privateKey)
 if err != nil {
 return err
 }
@@ -73,13 +107,26 @@ crypto.WipeBytes(sessionEncryptionKey)
 crypto.WipeBytes(sessionVerificationKey)
 return err
 }
+if err := wrapStoredPrivateKey(privateKey, masterPassword); err != nil {
+log.Printf("[Vault] WARNING: could not encrypt private.key, retrying at next unlock: %v", err)
+}
+if err := stampKeyCreated(); err != nil {
+log.Printf("[Vault] WARNING: could not record key creation time: %v", err)
+}
 
+appState.PrivateKey = privateKey
 appState.StoreUnlockedSession(masterPassword, profile, sessionEncryptionKey, sessionVerificationKey)
 appState.StartupWarning = ""
 return nil
 }
 
 func unlockAppSession(appState *AppState, masterPassword string) error {
+if appState.LockMonitor != nil {
+if err := appState.LockMonitor.UnlockAllowed(); err != nil {
+return err
+}
+}
+
 profile := appState.SecurityProfile
 if profile == nil {
 var err error
@@ -88,8 +135,30 @@ if err != nil {
 return fmt.Errorf("failed to load app security profile: %w", err)
 }
 }
+applyKDFPolicy(profile)
+
+incorrect := func() error {
+if appState.LockMonitor != nil {
+appState.LockMonitor.UnlockFailed()
+}
+return fmt.Errorf("incorrect master password")
+}
 
-sessionEncryptionKey, sessionVerificationKey, verified, fingerprintMatches, err := crypto.VerifyAppSecurityProfile(profile, masterPassword, appState.PrivateKey)
+// The private key stays wrapped on disk while locked; the master password
+// opens it here.
+privateKey := appState.PrivateKey
+if privateKey == nil {
+var err error
+privateKey, err = LoadStoredPrivateKey(masterPassword)
+if errors.Is(err, crypto.ErrPQSignatureInvalid) || errors.Is(err, crypto.ErrPQAuthFailed) {
+return incorrect()
+}
+if err != nil {
+return fmt.Errorf("failed to load private.key: %w", err)
+}
+}
+
+sessionEncryptionKey, sessionVerificationKey, verified, fingerprintMatches, err := crypto.VerifyAppSecurityProfile(profile, masterPassword, privateKey)
 if err != nil {
 return err
 }
@@ -101,10 +170,23 @@ return fmt.Errorf("the stored master-password profile does not match the loaded
 if !verified {
 crypto.WipeBytes(sessionEncryptionKey)
 crypto.WipeBytes(sessionVerificationKey)
-return fmt.Errorf("incorrect master password")
+return incorrect()
+}
+
+if err := wrapStoredPrivateKey(privateKey, masterPassword); err != nil {
+log.Printf("[Vault] WARNING: could not encrypt private.key: %v", err)
 }
 
+appState.PrivateKey = privateKey
 appState.StoreUnlockedSession(masterPassword, profile, sessionEncryptionKey, sessionVerificationKey)
+
+appState.Mu.Lock()
+if err := upgradeKDF(appState, false); err != nil {
+log.Printf("[Vault] WARNING: could not re-derive everything at the current KDF cost, retrying at next unlock: %v", err)
+}
+appState.Mu.Unlock()
+
+migrateKeypair(appState)
 return nil
 }
 
@@ -142,6 +224,9 @@ return fmt.Errorf("failed to open vault with the unlocked global master password
 }
 
 appState.StoreCurrentVaultState(vaultName)
+if _, err := PurgeExpiredTrash(appState, time.Now().UTC()); err != nil {
+log.Printf("[Vault] WARNING: could not purge expired trash: %v", err)
+}
 return nil
 }
 
@@ -176,75 +261,133 @@ if !verified {
 return fmt.Errorf("current password is incorrect")
 }
 
-vaultNames := ListVaults()
-originalVaultData := make(map[string][]byte, len(vaultNames))
-preparedVaults := make([]preparedVaultRotation, len(vaultNames))
+// Every vault is re-encrypted for the new password, and so is every
+// snapshot of it, so restore points taken before the change still open
+// with the master password. A snapshot that no longer opens with the
+// current one (it predates an earlier change) is left as it is. File store
+// manifests are sealed with the password too; without them no stored file
+// opens.
+type rotationJob struct {
+name     string
+path     string
+metadata string
+optional bool
+seal     func() ([]byte, error)
+}
+var jobs []rotationJob
+for _, vaultName := range ListVaults() {
+vaultPath := GetVaultPath(vaultName)
+jobs = append(jobs, rotationJob{
+name: "vault " + vaultName,
+path: vaultPath,
+seal: func() ([]byte, error) {
+return storage.ReencryptVaultFile(vaultPath, currentPassword, newPassword)
+},
+})
+snapshots, err := storage.ListSnapshots(vaultPath)
+if err != nil {
+return fmt.Errorf("failed to list snapshots of vault %s: %w", vaultName, err)
+}
+for _, snap := range snapshots {
+jobs = append(jobs, rotationJob{
+name:     fmt.Sprintf("snapshot %s of vault %s", snap.ID, vaultName),
+path:     snap.Path,
+metadata: snap.MetadataPath,
+optional: true,
+seal: func() ([]byte, error) {
+return storage.ReencryptSnapshot(snap, currentPassword, newPassword)
+},
+})
+}
+}
 
-// Re-encrypt vaults concurrently. This step is purely in-memory (no vault
-// file is written until staging below), so an error from any worker simply
-// aborts the whole change with nothing persisted. Parallelism is bounded by
+// The open store's background upgrade saves its manifest, which must not
+// land between reading the manifests here and the commit below.
+if appState.FileStore != nil {
+appState.FileStore.StopUpgrade()
+defer appState.FileStore.StartUpgrade()
+}
+stores, err := filevault.ListStores()
+if err != nil {
+return err
+}
+for _, storeName := range stores {
+manifestPath, err := filevault.ManifestPath(storeName)
+if err != nil {
+return err
+}
+if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
+continue
+}
+jobs = append(jobs, rotationJob{
+name: "file store " + storeName,
+path: manifestPath,
+seal: func() ([]byte, error) {
+return filevault.ReencryptManifest(manifestPath, currentPassword, newPassword)
+},
+})
+}
+
+// Re-encrypt concurrently. This step is purely in-memory (no file is
+// written until staging below), so an error from any vault simply aborts
+// the whole change with nothing persisted. Parallelism is bounded by
 // maxRotationWorkers to keep Argon2id's memory cost in check.
 type rotationOutcome struct {
-path         string
-originalData []byte
-prepared     preparedVaultRotation
-err          error
+prepared preparedVaultRotation
+err      error
 }
 
-if len(vaultNames) > 0 {
+var preparedVaults []preparedVaultRotation
+if len(jobs) > 0 {
 workers := runtime.NumCPU()
 if workers > maxRotationWorkers {
 workers = maxRotationWorkers
 }
-if workers > len(vaultNames) {
-workers = len(vaultNames)
+if workers > len(jobs) {
+workers = len(jobs)
 }
 
-results := make([]rotationOutcome, len(vaultNames))
+results := make([]rotationOutcome, len(jobs))
 sem := make(chan struct{}, workers)
 var wg sync.WaitGroup
 
-for i, vaultName := range vaultNames {
+for i, job := range jobs {
 wg.Add(1)
-go func(i int, vaultName string) {
+go func(i int, job rotationJob) {
 defer wg.Done()
 sem <- struct{}{}
 defer func() { <-sem }()
 
-vaultPath := GetVaultPath(vaultName)
-currentData, err := os.ReadFile(vaultPath)
-if err != nil {
-results[i] = rotationOutcome{err: fmt.Errorf("failed to read vault %s: %w", vaultName, err)}
-return
-}
-
-rotatedData, err := storage.ReencryptVaultFile(vaultPath, currentPassword, newPassword)
+rotatedData, err := job.seal()
 if err != nil {
-results[i] = rotationOutcome{err: fmt.Errorf("failed to rotate vault %s: %w", vaultName, err)}
+results[i] = rotationOutcome{err: fmt.Errorf("failed to rotate %s: %w", job.name, err)}
 return
 }
 
 results[i] = rotationOutcome{
-path:         vaultPath,
-originalData: currentData,
 prepared: preparedVaultRotation{
-path:     vaultPath,
-tempPath: vaultPath + ".tmp",
+path:     job.path,
+metadata: job.metadata,
 data:     rotatedData,
 },
 }
-}(i, vaultName)
+}(i, job)
 }
 wg.Wait()
 
-for _, r := range results {
-if r.err != nil {
+for i, r := range results {
+if r.err == nil {
+continue
+}
+if !jobs[i].optional {
 return r.err
 }
+log.Printf("[Vault] WARNING: %v; it keeps its previous password", r.err)
+}
+for _, r := range results {
+if r.err == nil {
+preparedVaults = append(preparedVaults, r.prepared)
 }
-for i, r := range results {
-originalVaultData[r.path] = r.originalData
-preparedVaults[i] = r.prepared
 }
 }
 
@@ -253,69 +396,58 @@ if err != nil {
 return err
 }
 
-metadataExisted := false
-originalMetadataData, readMetadataErr := os.ReadFile(appSecurityMetadataPath)
-if readMetadataErr == nil {
-metadataExisted = true
-} else if !os.IsNotExist(readMetadataErr) {
-return fmt.Errorf("failed to read existing app security metadata: %w", readMetadataErr)
-}
-
-metadataTempPath := appSecurityMetadataPath + ".tmp"
-
-cleanupTempFiles := func() {
-for _, preparedVault := range preparedVaults {
-_ = os.Remove(preparedVault.tempPath)
+wrappedKey, err := crypto.WrapPrivateKey(appState.PrivateKey, newPassword)
+if err != nil {
+return fmt.Errorf("failed to re-wrap private.key: %w", err)
 }
-_ = os.Remove(metadataTempPath)
+privKeyPath, err := securestorage.GetSecureFilePath(PrivKeyPath)
+if err != nil {
+return err
 }
 
-restoreVaults := func(paths []string) {
-for _, path := range paths {
-if data, exists := originalVaultData[path]; exists {
-_ = os.WriteFile(path, data, 0600)
-}
-}
+// Every rotated vault, snapshot and manifest, the new profile and the
+// re-wrapped private.key (and any keys retired by RotateKeys) are replaced in
+// one journaled transaction: a crash part-way through is completed on the
+// next start, so they never disagree about the password. The vaults keep their
+// entries, so no new snapshot is taken of them.
+tx, err := securestorage.BeginTx()
+if err != nil {
+return err
 }
+defer tx.Abort()
 
-restoreMetadata := func() {
-if metadataExisted {
-_ = os.WriteFile(appSecurityMetadataPath, originalMetadataData, 0600)
-return
+for _, preparedVault := range preparedVaults {
+if err := tx.Write(preparedVault.path, preparedVault.data, 0600); err != nil {
+return fmt.Errorf("failed to stage re-encrypted %s: %w", filepath.Base(preparedVault.path), err)
 }
-_ = os.Remove(appSecurityMetadataPath)
+// A snapshot's copy of the profile follows its new password.
+if preparedVault.metadata != "" {
+if err := storage.StageAppSecurityProfile(tx, preparedVault.metadata, newProfile); err != nil {
+return fmt.Errorf("failed to stage snapshot metadata %s: %w", filepath.Base(preparedVault.metadata), err)
 }
-
-for _, preparedVault := range preparedVaults {
-if err := os.WriteFile(preparedVault.tempPath, preparedVault.data, 0600); err != nil {
-cleanupTempFiles()
-return fmt.Errorf("failed to stage rotated vault %s: %w", filepath.Base(preparedVault.path), err)
 }
 }
 
-if err := storage.SaveAppSecurityProfile(metadataTempPath, newProfile); err != nil {
-cleanupTempFiles()
+if err := storage.StageAppSecurityProfile(tx, appSecurityMetadataPath, newProfile); err != nil {
 return fmt.Errorf("failed to stage app security metadata: %w", err)
 }
-
-replacedVaults := make([]string, 0, len(preparedVaults))
-for _, preparedVault := range preparedVaults {
-if err := os.Rename(preparedVault.tempPath, preparedVault.path); err != nil {
-cleanupTempFiles()
-restoreVaults(replacedVaults)
-return fmt.Errorf("failed to activate rotated vault %s: %w", filepath.Base(preparedVault.path), err)
+if err := tx.Write(privKeyPath, wrappedKey, 0600); err != nil {
+return fmt.Errorf("failed to stage re-wrapped private.key: %w", err)
 }
-replacedVaults = append(replacedVaults, preparedVault.path)
+if err := stageRetiredKeys(tx, currentPassword, newPassword); err != nil {
+return err
 }
 
-if err := os.Rename(metadataTempPath, appSecurityMetadataPath); err != nil {
-cleanupTempFiles()
-restoreVaults(replacedVaults)
-restoreMetadata()
-return fmt.Errorf("failed to activate app security metadata: %w", err)
+if err := tx.Commit(); err != nil {
+return fmt.Errorf("failed to activate the new master password: %w", err)
 }
 
 appState.StoreUnlockedSession(newPassword, newProfile, sessionEncryptionKey, sessionVerificationKey)
+if appState.FileStore != nil {
+if err := appState.FileStore.SetPassword(newPassword); err != nil {
+return fmt.Errorf("password changed, but the open file store could not be reloaded: %w", err)
+}
+}
 
 if appState.CurrentVault != "" {
 appState.StoreCurrentVaultState(appState.CurrentVault)
@@ -0,0 +1,185 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"passquantum/core/crypto"
+	"passquantum/core/model"
+	"passquantum/core/storage"
+	securestorage "passquantum/internal/storage"
+)
+
+// newTestKeypair writes a raw keypair, as releases before key wrapping did,
+// and returns a locked state holding it.
+func newTestKeypair(t *testing.T) *AppState {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	pubKey, privKey, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPath, _ := securestorage.GetSecureFilePath(PubKeyPath)
+	privPath, _ := securestorage.GetSecureFilePath(PrivKeyPath)
+	if err := crypto.SaveKeypair(pubKey, privKey, pubPath, privPath); err != nil {
+		t.Fatal(err)
+	}
+	return &AppState{PublicKey: pubKey, PrivateKey: privKey}
+}
+
+func privateKeyIsWrapped(t *testing.T) bool {
+	t.Helper()
+	privPath, _ := securestorage.GetSecureFilePath(PrivKeyPath)
+	data, err := os.ReadFile(privPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypto.IsWrappedPrivateKey(data)
+}
+
+func TestUnlockWrapsRawPrivateKey(t *testing.T) {
+	appState := newTestKeypair(t)
+	profile, _, _, err := crypto.CreateAppSecurityProfile("first", appState.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveAppSecurityProfile(appSecurityMetadataPath, profile); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := NewHeadlessSession("first", "")
+	if err != nil {
+		t.Fatalf("NewHeadlessSession: %v", err)
+	}
+	session.ClearSensitiveState()
+	if !privateKeyIsWrapped(t) {
+		t.Fatal("private.key still raw after unlocking")
+	}
+	if session.PrivateKey != nil {
+		t.Error("ClearSensitiveState kept the private key")
+	}
+
+	if _, err := NewHeadlessSession("wrong", ""); err == nil {
+		t.Fatal("unlock with a wrong password succeeded")
+	}
+	if _, err := NewHeadlessSession("first", ""); err != nil {
+		t.Fatalf("unlock of the wrapped key: %v", err)
+	}
+}
+
+func TestChangeMasterPasswordRewrapsPrivateKey(t *testing.T) {
+	appState := newTestKeypair(t)
+	if err := CreateMasterPasswordProfile(appState, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if !privateKeyIsWrapped(t) {
+		t.Fatal("private.key not wrapped by setup")
+	}
+	if err := CreateNewVault(appState, "Default"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ChangeMasterPassword(appState, "first", "second"); err != nil {
+		t.Fatalf("ChangeMasterPassword: %v", err)
+	}
+	appState.ClearSensitiveState()
+
+	if _, err := LoadStoredPrivateKey("first"); err == nil {
+		t.Error("private.key still opens with the old password")
+	}
+	session, err := NewHeadlessSession("second", "Default")
+	if err != nil {
+		t.Fatalf("unlock with the new password: %v", err)
+	}
+	session.ClearSensitiveState()
+}
+
+func TestChangeMasterPasswordKeepsStoredFiles(t *testing.T) {
+	appState := newTestKeypair(t)
+	if err := CreateMasterPasswordProfile(appState, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateNewVault(appState, "Default"); err != nil {
+		t.Fatal(err)
+	}
+	appState.CurrentVault = "Default"
+	if err := InitFileStore(appState); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "doc.txt")
+	if err := os.WriteFile(src, []byte("contents"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := appState.FileStore.StoreFile(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ChangeMasterPassword(appState, "first", "second"); err != nil {
+		t.Fatalf("ChangeMasterPassword: %v", err)
+	}
+	// The open store follows the new password, so closing it does not seal
+	// the manifest under the old one again.
+	if data, err := appState.FileStore.DecryptToMemory(meta.UUID); err != nil || string(data) != "contents" {
+		t.Fatalf("file in the open store: %q, %v", data, err)
+	}
+	appState.ClearSensitiveState()
+
+	session, err := NewHeadlessSession("second", "Default")
+	if err != nil {
+		t.Fatalf("unlock with the new password: %v", err)
+	}
+	defer session.ClearSensitiveState()
+	if err := InitFileStore(session); err != nil {
+		t.Fatalf("reopen the file store: %v", err)
+	}
+	if data, err := session.FileStore.DecryptToMemory(meta.UUID); err != nil || string(data) != "contents" {
+		t.Fatalf("file after reopening: %q, %v", data, err)
+	}
+}
+
+func TestChangeMasterPasswordReencryptsSnapshots(t *testing.T) {
+	appState := newTestKeypair(t)
+	if err := CreateMasterPasswordProfile(appState, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateNewVault(appState, "Default"); err != nil {
+		t.Fatal(err)
+	}
+	appState.CurrentVault = "Default"
+	for _, service := range []string{"github.com", "gitlab.com"} {
+		entry, err := BuildPasswordEntry(service, "alice", &model.PasswordPayload{Password: "hunter2"}, appState.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AddEntry(appState, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := ListVaultSnapshots(appState)
+	if err != nil || len(before) == 0 {
+		t.Fatalf("ListVaultSnapshots = %d, %v", len(before), err)
+	}
+
+	if err := ChangeMasterPassword(appState, "first", "second"); err != nil {
+		t.Fatalf("ChangeMasterPassword: %v", err)
+	}
+	after, err := ListVaultSnapshots(appState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("snapshots = %d after the change, want %d", len(after), len(before))
+	}
+	for _, snap := range after {
+		if _, err := OpenVaultSnapshot(appState, snap); err != nil {
+			t.Errorf("snapshot %s with the new password: %v", snap.ID, err)
+		}
+		if _, err := storage.ReadSnapshot(snap, "first"); err == nil {
+			t.Errorf("snapshot %s still opens with the old password", snap.ID)
+		}
+	}
+}
@@ -0,0 +1,560 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+
+	"passquantum/core/crypto"
+	"passquantum/core/model"
+	"passquantum/core/totp"
+)
+
+// NotePayload is the decrypted body of a secure note entry, as written by
+// the add-item form and the importers.
+type NotePayload struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// CardPayload is the decrypted body of a card entry.
+type CardPayload struct {
+	Subtype string `json:"subtype"`
+	Holder  string `json:"holder"`
+	Number  string `json:"number"`
+	Expiry  string `json:"expiry"`
+	CVV     string `json:"cvv"`
+}
+
+// Service prefixes used by the non-password entry types.
+const (
+	NoteServicePrefix = "NOTE:"
+	CardServicePrefix = "CARD:"
+	TOTPServicePrefix = "TOTP:"
+)
+
+var entryTypeNames = []struct {
+	t    model.EntryType
+	name string
+}{
+	{model.EntryTypePassword, "password"},
+	{model.EntryTypeNote, "note"},
+	{model.EntryTypeCard, "card"},
+	{model.EntryTypeTOTP, "totp"},
+	{model.EntryTypeFile, "file"},
+	{model.EntryTypeSSHKey, "ssh"},
+}
+
+// EntryTypeName returns the lowercase name of t ("password", "note", ...).
+func EntryTypeName(t model.EntryType) string {
+	for _, n := range entryTypeNames {
+		if n.t == t {
+			return n.name
+		}
+	}
+	return "unknown"
+}
+
+// ParseEntryType is the inverse of EntryTypeName.
+func ParseEntryType(name string) (model.EntryType, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, n := range entryTypeNames {
+		if n.name == name {
+			return n.t, nil
+		}
+	}
+	return model.EntryTypeUnknown, fmt.Errorf("unknown entry type %q", name)
+}
+
+// EntryName returns the name an entry is shown under: its service with the
+// type prefix ("NOTE:", "CARD:", "TOTP:", "SSH:") removed.
+func EntryName(entry *model.VaultEntry) string {
+	for _, prefix := range []string{NoteServicePrefix, CardServicePrefix, TOTPServicePrefix, SSHKeyServicePrefix} {
+		if strings.HasPrefix(entry.Service, prefix) {
+			return strings.TrimPrefix(entry.Service, prefix)
+		}
+	}
+	return entry.Service
+}
+
+// FormatEntryID renders an entry ID the way headless clients print and
+// accept it.
+func FormatEntryID(id uint64) string {
+	return fmt.Sprintf("%016x", id)
+}
+
+// ResolveEntry finds the live entry ref refers to: a 16-digit hex ID, or
+// otherwise a case-insensitive entry name. Ambiguous names are an error
+// listing the candidate IDs. IDs also resolve to trashed entries.
+func ResolveEntry(entries []*model.VaultEntry, ref string) (*model.VaultEntry, error) {
+	return resolveEntry(entries, LiveEntries(entries), ref)
+}
+
+// ResolveTrashedEntry is ResolveEntry for names of entries in the trash.
+func ResolveTrashedEntry(entries []*model.VaultEntry, ref string) (*model.VaultEntry, error) {
+	return resolveEntry(entries, TrashedEntries(entries), ref)
+}
+
+func resolveEntry(entries, named []*model.VaultEntry, ref string) (*model.VaultEntry, error) {
+	ref = strings.TrimSpace(ref)
+	if len(ref) == 16 {
+		if id, err := strconv.ParseUint(ref, 16, 64); err == nil {
+			for _, e := range entries {
+				if e != nil && e.ID == id {
+					return e, nil
+				}
+			}
+		}
+	}
+
+	var matches []*model.VaultEntry
+	for _, e := range named {
+		if strings.EqualFold(EntryName(e), ref) {
+			matches = append(matches, e)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no entry matches %q", ref)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, e := range matches {
+		ids[i] = FormatEntryID(e.ID) + " (" + e.Username + ")"
+	}
+	return nil, fmt.Errorf("%q matches %d entries, use an ID: %s", ref, len(matches), strings.Join(ids, ", "))
+}
+
+// AddEntry appends entry to the current vault.
+func AddEntry(appState *AppState, entry *model.VaultEntry) error {
+	return rewriteEntries(appState, func(entries []*model.VaultEntry) ([]*model.VaultEntry, error) {
+		return append(entries, entry), nil
+	})
+}
+
+// SealEntrySecret encrypts plaintext with a fresh KEM encapsulation and
+// stores the crypto fields on entry. The entry's metadata is bound to the
+// ciphertext, so set it before sealing and change it afterwards only through
+// UpdateEntryMetadata.
+func SealEntrySecret(entry *model.VaultEntry, plaintext string, pubKey kem.PublicKey) error {
+	ct, nonce, ciphertext, err := sealEntryCiphertext(entry, plaintext, pubKey)
+	if err != nil {
+		return err
+	}
+	entry.KyberCiphertext = ct
+	entry.Nonce = nonce
+	entry.Ciphertext = ciphertext
+	entry.CryptoVersion = model.CurrentEntryCrypto
+	return nil
+}
+
+// OpenEntrySecret decrypts the plaintext payload of any entry type.
+func OpenEntrySecret(entry *model.VaultEntry, privKey kem.PrivateKey) (string, error) {
+	return openEntryCiphertext(entry, entry.KyberCiphertext, entry.Nonce, entry.Ciphertext, entry.CryptoVersion, privKey)
+}
+
+// sealEntryCiphertext encapsulates to pubKey and encrypts plaintext under
+// the current entry crypto version with entry's associated data.
+func sealEntryCiphertext(entry *model.VaultEntry, plaintext string, pubKey kem.PublicKey) (kyberCt, nonce, ciphertext []byte, err error) {
+	ct, ss, err := crypto.Encapsulate(pubKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("encapsulation failed: %w", err)
+	}
+	defer crypto.WipeBytes(ss)
+
+	nonce, ciphertext, err = crypto.EncryptAES256GCMWithAAD(plaintext, ss, entry.AssociatedData(model.CurrentEntryCrypto))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("encryption failed: %w", err)
+	}
+	return ct, nonce, ciphertext, nil
+}
+
+// openEntryCiphertext decrypts one of entry's ciphertexts, the payload or a
+// history version, sealed under version.
+func openEntryCiphertext(entry *model.VaultEntry, kyberCt, nonce, ciphertext []byte, version uint8, privKey kem.PrivateKey) (string, error) {
+	ss, err := crypto.Decapsulate(kyberCt, privKey)
+	if err != nil {
+		return "", fmt.Errorf("decapsulation failed: %w", err)
+	}
+	defer crypto.WipeBytes(ss)
+
+	plaintext, err := crypto.DecryptAES256GCMWithAAD(nonce, ciphertext, ss, entry.AssociatedData(version))
+	if err != nil {
+		return "", fmt.Errorf("decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+// UpdateEntryMetadata applies change to entry's bound metadata (service,
+// username, type, card subtype) and re-seals the payload and password
+// history to match. Entries still on an older crypto version are upgraded
+// on the way.
+func UpdateEntryMetadata(entry *model.VaultEntry, change func(*model.VaultEntry), pubKey kem.PublicKey, privKey kem.PrivateKey) error {
+	updated := *entry
+	updated.PasswordHistory = append([]model.PasswordHistoryEntry(nil), entry.PasswordHistory...)
+	change(&updated)
+	if bytes.Equal(entry.AssociatedData(model.CurrentEntryCrypto), updated.AssociatedData(model.CurrentEntryCrypto)) &&
+		!needsCryptoUpgrade(&updated) {
+		*entry = updated
+		return nil
+	}
+	if err := resealEntryFrom(&updated, entry, []kem.PrivateKey{privKey}, pubKey); err != nil {
+		return err
+	}
+	*entry = updated
+	return nil
+}
+
+func needsCryptoUpgrade(e *model.VaultEntry) bool {
+	if len(e.KyberCiphertext) > 0 && e.CryptoVersion < model.CurrentEntryCrypto {
+		return true
+	}
+	for _, h := range e.PasswordHistory {
+		if h.CryptoVersion < model.CurrentEntryCrypto {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradeEntryCrypto re-seals entries still on an older crypto version,
+// which is how existing vaults move to version 2 as they are written.
+// Entries the current key cannot open are left for key rotation or fsck to
+// report and do not stop the write.
+func upgradeEntryCrypto(entries []*model.VaultEntry, pubKey kem.PublicKey, privKey kem.PrivateKey) {
+	if pubKey == nil || privKey == nil {
+		return
+	}
+	upgraded := 0
+	for _, e := range entries {
+		if e == nil || !needsCryptoUpgrade(e) {
+			continue
+		}
+		sealed := *e
+		sealed.PasswordHistory = append([]model.PasswordHistoryEntry(nil), e.PasswordHistory...)
+		if err := resealEntry(&sealed, []kem.PrivateKey{privKey}, pubKey); err != nil {
+			log.Printf("[Vault] WARNING: could not upgrade entry %s: %v", FormatEntryID(e.ID), err)
+			continue
+		}
+		*e = sealed
+		upgraded++
+	}
+	if upgraded > 0 {
+		log.Printf("[Vault] upgraded %d entries to entry crypto version %d", upgraded, model.CurrentEntryCrypto)
+	}
+}
+
+// BuildPasswordEntry creates a sealed password entry.
+func BuildPasswordEntry(service, username string, payload *model.PasswordPayload, pubKey kem.PublicKey) (*model.VaultEntry, error) {
+	if strings.TrimSpace(service) == "" {
+		return nil, fmt.Errorf("service name cannot be empty")
+	}
+	if payload.Password == "" {
+		return nil, fmt.Errorf("password cannot be empty")
+	}
+	entry := model.NewVaultEntry()
+	entry.Type = model.EntryTypePassword
+	entry.Service = service
+	entry.Username = username
+	if err := SealPasswordPayload(entry, payload, pubKey); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// BuildNoteEntry creates a sealed secure note.
+func BuildNoteEntry(title, content string, pubKey kem.PublicKey) (*model.VaultEntry, error) {
+	if title == "" || content == "" {
+		return nil, fmt.Errorf("note title and content cannot be empty")
+	}
+	entry := model.NewVaultEntry()
+	entry.Type = model.EntryTypeNote
+	entry.Service = NoteServicePrefix + title
+	entry.Username = "note"
+	if err := SealNotePayload(entry, &NotePayload{Title: title, Content: content}, pubKey); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// BuildCardEntry creates a sealed card entry named name.
+func BuildCardEntry(name string, card *CardPayload, pubKey kem.PublicKey) (*model.VaultEntry, error) {
+	if name == "" || card.Holder == "" || card.Number == "" {
+		return nil, fmt.Errorf("card name, holder and number cannot be empty")
+	}
+	entry := model.NewVaultEntry()
+	entry.Type = model.EntryTypeCard
+	entry.CardSubtype = card.Subtype
+	entry.Service = CardServicePrefix + name
+	entry.Username = card.Subtype
+	if err := SealCardPayload(entry, card, pubKey); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// BuildTOTPEntry creates a sealed TOTP entry after validating params.
+func BuildTOTPEntry(params *totp.TOTPParams, pubKey kem.PublicKey) (*model.VaultEntry, error) {
+	if params.Issuer == "" {
+		return nil, fmt.Errorf("TOTP issuer is required")
+	}
+	entry := model.NewVaultEntry()
+	entry.Type = model.EntryTypeTOTP
+	entry.Service = TOTPServicePrefix + params.Issuer
+	entry.Username = params.Account
+	if err := SealTOTPParams(entry, params, pubKey); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// SealNotePayload encrypts note into entry.
+func SealNotePayload(entry *model.VaultEntry, note *NotePayload, pubKey kem.PublicKey) error {
+	note.Type = "note"
+	data, err := json.Marshal(note)
+	if err != nil {
+		return fmt.Errorf("encode note: %w", err)
+	}
+	return SealEntrySecret(entry, string(data), pubKey)
+}
+
+// SealCardPayload encrypts card into entry.
+func SealCardPayload(entry *model.VaultEntry, card *CardPayload, pubKey kem.PublicKey) error {
+	data, err := json.Marshal(card)
+	if err != nil {
+		return fmt.Errorf("encode card: %w", err)
+	}
+	return SealEntrySecret(entry, string(data), pubKey)
+}
+
+// SealTOTPParams validates params and encrypts them into entry.
+func SealTOTPParams(entry *model.VaultEntry, params *totp.TOTPParams, pubKey kem.PublicKey) error {
+	if err := totp.Validate(params); err != nil {
+		return fmt.Errorf("invalid TOTP params: %w", err)
+	}
+	data, err := totp.Serialize(params)
+	if err != nil {
+		return fmt.Errorf("encode TOTP params: %w", err)
+	}
+	return SealEntrySecret(entry, string(data), pubKey)
+}
+
+// EntryDetails is a decrypted, serializable view of an entry for headless
+// clients. Exactly one of Password, Note, Card, TOTP, SSHKey or Secret is
+// set when the entry was decrypted; none are set for a metadata-only view.
+type EntryDetails struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	Name      string     `json:"name"`
+	Username  string     `json:"username,omitempty"`
+	Folder    string     `json:"folder,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	Favorite  bool       `json:"favorite,omitempty"`
+	Deleted   bool       `json:"deleted,omitempty"`
+	Created   *time.Time `json:"created,omitempty"`
+	Modified  *time.Time `json:"modified,omitempty"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	History   int        `json:"password_history,omitempty"`
+
+	// Match and MatchPattern are the entry's match rule when it is not
+	// the default base-domain match on its own site.
+	Match        string `json:"match,omitempty"`
+	MatchPattern string `json:"match_pattern,omitempty"`
+
+	Password *model.PasswordPayload `json:"password,omitempty"`
+	Note     *NotePayload           `json:"note,omitempty"`
+	Card     *CardPayload           `json:"card,omitempty"`
+	TOTP     *totp.TOTPParams       `json:"totp,omitempty"`
+	SSHKey   *model.SSHKeyPayload   `json:"ssh_key,omitempty"`
+	Secret   string                 `json:"secret,omitempty"` // raw payload of file and unknown entries
+}
+
+// DescribeEntry builds the EntryDetails for entry. With a nil privKey only
+// metadata is filled in and nothing is decrypted.
+func DescribeEntry(entry *model.VaultEntry, privKey kem.PrivateKey) (*EntryDetails, error) {
+	d := &EntryDetails{
+		ID:        FormatEntryID(entry.ID),
+		Type:      EntryTypeName(entry.Type),
+		Name:      EntryName(entry),
+		Username:  entry.Username,
+		Folder:    entry.Folder,
+		Tags:      entry.Tags,
+		Favorite:  entry.Favorite,
+		Deleted:   entry.Deleted,
+		Created:   optionalTime(entry.Created),
+		Modified:  optionalTime(entry.Modified),
+		LastUsed:  optionalTime(entry.LastUsed),
+		DeletedAt: optionalTime(entry.DeletedAt),
+		History:   len(entry.PasswordHistory),
+	}
+	if !entry.Match.IsZero() {
+		d.Match, d.MatchPattern = entry.Match.Mode.String(), entry.Match.Pattern
+	}
+	if privKey == nil {
+		return d, nil
+	}
+
+	plaintext, err := OpenEntrySecret(entry, privKey)
+	if err != nil {
+		return nil, err
+	}
+	switch entry.Type {
+	case model.EntryTypePassword:
+		d.Password = model.ParsePasswordPayload(plaintext)
+	case model.EntryTypeNote:
+		d.Note = &NotePayload{Title: d.Name, Content: plaintext}
+		_ = json.Unmarshal([]byte(plaintext), d.Note)
+	case model.EntryTypeCard:
+		d.Card = &CardPayload{Subtype: entry.CardSubtype}
+		if err := json.Unmarshal([]byte(plaintext), d.Card); err != nil {
+			d.Card.Number = plaintext
+		}
+	case model.EntryTypeTOTP:
+		params, err := totp.Deserialize([]byte(plaintext))
+		if err != nil {
+			return nil, fmt.Errorf("invalid TOTP payload: %w", err)
+		}
+		d.TOTP = params
+	case model.EntryTypeSSHKey:
+		payload, err := model.ParseSSHKeyPayload(plaintext)
+		if err != nil {
+			return nil, err
+		}
+		d.SSHKey = payload
+	default:
+		d.Secret = plaintext
+	}
+	return d, nil
+}
+
+// Redact blanks the secret values in d (password, custom hidden fields,
+// card number and CVV, TOTP secret, SSH private key and passphrase, raw
+// secret) while keeping the rest.
+func (d *EntryDetails) Redact() {
+	const mask = "********"
+	if p := d.Password; p != nil {
+		p.Password = mask
+		for i := range p.Fields {
+			if p.Fields[i].Hidden {
+				p.Fields[i].Value = mask
+			}
+		}
+	}
+	if c := d.Card; c != nil {
+		if n := len(c.Number); n > 4 {
+			c.Number = strings.Repeat("*", n-4) + c.Number[n-4:]
+		}
+		if c.CVV != "" {
+			c.CVV = mask
+		}
+	}
+	if d.TOTP != nil {
+		d.TOTP.Secret = mask
+	}
+	if k := d.SSHKey; k != nil {
+		k.PrivateKey = mask
+		if k.Passphrase != "" {
+			k.Passphrase = mask
+		}
+	}
+	if d.Secret != "" {
+		d.Secret = mask
+	}
+}
+
+// primaryField is the field Field returns for an empty name, by type name.
+var primaryField = map[string]string{
+	"password": "password",
+	"note":     "content",
+	"card":     "number",
+	"totp":     "secret",
+	"file":     "secret",
+	"ssh":      "private_key",
+}
+
+// Field picks a single value out of d by name ("password", "username",
+// "number", ...). Custom field names of password entries are matched
+// case-insensitively after the built-in ones. An empty name selects the
+// entry's main secret.
+func (d *EntryDetails) Field(name string) (string, bool) {
+	if name == "" {
+		name = primaryField[d.Type]
+	}
+	switch strings.ToLower(name) {
+	case "name":
+		return d.Name, true
+	case "username":
+		return d.Username, true
+	}
+	if p := d.Password; p != nil {
+		switch strings.ToLower(name) {
+		case "password":
+			return p.Password, true
+		case "url":
+			if p.LoginURL != "" {
+				return p.LoginURL, true
+			}
+			if len(p.URLs) > 0 {
+				return p.URLs[0], true
+			}
+			return "", false
+		case "notes":
+			return p.Notes, true
+		}
+		for _, f := range p.Fields {
+			if strings.EqualFold(f.Name, name) {
+				return f.Value, true
+			}
+		}
+	}
+	if n := d.Note; n != nil && strings.EqualFold(name, "content") {
+		return n.Content, true
+	}
+	if cd := d.Card; cd != nil {
+		switch strings.ToLower(name) {
+		case "holder":
+			return cd.Holder, true
+		case "number":
+			return cd.Number, true
+		case "expiry":
+			return cd.Expiry, true
+		case "cvv":
+			return cd.CVV, true
+		}
+	}
+	if k := d.SSHKey; k != nil {
+		switch strings.ToLower(name) {
+		case "private_key":
+			return k.PrivateKey, true
+		case "public_key":
+			return k.PublicKey, true
+		case "passphrase":
+			return k.Passphrase, true
+		case "comment":
+			return k.Comment, true
+		}
+	}
+	if t := d.TOTP; t != nil && strings.EqualFold(name, "secret") {
+		return t.Secret, true
+	}
+	if d.Secret != "" && strings.EqualFold(name, "secret") {
+		return d.Secret, true
+	}
+	return "", false
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
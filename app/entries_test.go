@@ -0,0 +1,193 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"passquantum/core/crypto"
+	"passquantum/core/model"
+	"passquantum/core/totp"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+func TestBuildAndDescribeEntries(t *testing.T) {
+	pub, priv, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("GenerateKeypair: %v", err)
+	}
+
+	payload := model.NewPasswordPayload("hunter2!x")
+	payload.Fields = []model.CustomField{{Name: "pin", Value: "1234", Hidden: true}}
+	pw, err := BuildPasswordEntry("github.com", "alice", payload, pub)
+	if err != nil {
+		t.Fatalf("BuildPasswordEntry: %v", err)
+	}
+	note, err := BuildNoteEntry("Recovery", "codes", pub)
+	if err != nil {
+		t.Fatalf("BuildNoteEntry: %v", err)
+	}
+	card, err := BuildCardEntry("Visa", &CardPayload{Subtype: "credit", Holder: "A", Number: "4111111111111111", CVV: "123"}, pub)
+	if err != nil {
+		t.Fatalf("BuildCardEntry: %v", err)
+	}
+	params := totp.DefaultParams()
+	params.Issuer, params.Account, params.Secret = "GitHub", "alice", "JBSWY3DPEHPK3PXP"
+	otp, err := BuildTOTPEntry(params, pub)
+	if err != nil {
+		t.Fatalf("BuildTOTPEntry: %v", err)
+	}
+
+	d, err := DescribeEntry(pw, priv)
+	if err != nil || d.Type != "password" || d.Password.Password != "hunter2!x" {
+		t.Fatalf("password details = %+v, %v", d, err)
+	}
+	d.Redact()
+	if d.Password.Password == "hunter2!x" || d.Password.Fields[0].Value == "1234" {
+		t.Error("Redact left password secrets visible")
+	}
+
+	if d, _ := DescribeEntry(note, priv); d.Name != "Recovery" || d.Note.Content != "codes" {
+		t.Errorf("note details = %+v", d.Note)
+	}
+	d, _ = DescribeEntry(card, priv)
+	if d.Name != "Visa" || d.Card.Number != "4111111111111111" {
+		t.Errorf("card details = %+v", d.Card)
+	}
+	d.Redact()
+	if d.Card.Number != "************1111" || d.Card.CVV == "123" {
+		t.Errorf("redacted card = %+v", d.Card)
+	}
+	if d, _ := DescribeEntry(otp, priv); d.TOTP.Secret != "JBSWY3DPEHPK3PXP" || d.Name != "GitHub" {
+		t.Errorf("totp details = %+v", d.TOTP)
+	}
+
+	if d, _ := DescribeEntry(pw, nil); d.Password != nil {
+		t.Error("DescribeEntry without a key should not decrypt")
+	}
+}
+
+func TestResolveEntry(t *testing.T) {
+	a := makePasswordEntry("github.com", "alice")
+	a.ID = 0x10
+	b := makePasswordEntry("github.com", "bob")
+	b.ID = 0x20
+	c := makePasswordEntry("gitlab.com", "alice")
+	c.ID = 0x30
+	entries := []*model.VaultEntry{a, b, c}
+
+	if got, err := ResolveEntry(entries, "GITLAB.com"); err != nil || got != c {
+		t.Errorf("by name = %v, %v", got, err)
+	}
+	if got, err := ResolveEntry(entries, FormatEntryID(b.ID)); err != nil || got != b {
+		t.Errorf("by ID = %v, %v", got, err)
+	}
+	if _, err := ResolveEntry(entries, "github.com"); err == nil || !strings.Contains(err.Error(), FormatEntryID(a.ID)) {
+		t.Errorf("ambiguous name error = %v", err)
+	}
+
+	c.MoveToTrash(time.Now())
+	if _, err := ResolveEntry(entries, "gitlab.com"); err == nil {
+		t.Error("trashed entries should not resolve by name")
+	}
+	if got, _ := ResolveEntry(entries, FormatEntryID(c.ID)); got != c {
+		t.Error("trashed entries should still resolve by ID")
+	}
+	if got, err := ResolveTrashedEntry(entries, "gitlab.com"); err != nil || got != c {
+		t.Errorf("ResolveTrashedEntry = %v, %v", got, err)
+	}
+}
+
+func TestEntryMetadataBinding(t *testing.T) {
+	pub, priv, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("GenerateKeypair: %v", err)
+	}
+	alice, err := BuildPasswordEntry("github.com", "alice", model.NewPasswordPayload("hunter2!x"), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := BuildPasswordEntry("bank.example", "bob", model.NewPasswordPayload("s3cret"), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A ciphertext moved onto another entry no longer opens.
+	swapped := *bob
+	swapped.KyberCiphertext, swapped.Nonce, swapped.Ciphertext = alice.KyberCiphertext, alice.Nonce, alice.Ciphertext
+	if _, err := OpenEntrySecret(&swapped, priv); err == nil {
+		t.Error("ciphertext opened under another entry's metadata")
+	}
+	edited := *alice
+	edited.Username = "mallory"
+	if _, err := OpenEntrySecret(&edited, priv); err == nil {
+		t.Error("ciphertext opened after its username was edited in place")
+	}
+
+	// Renames go through UpdateEntryMetadata, which re-seals the history too.
+	if err := ReplacePasswordPayload(alice, model.NewPasswordPayload("n3wer!pw"), pub, priv); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateEntryMetadata(alice, func(e *model.VaultEntry) { e.Username = "alice@example.com" }, pub, priv); err != nil {
+		t.Fatalf("UpdateEntryMetadata: %v", err)
+	}
+	if payload, err := OpenPasswordPayload(alice, priv); err != nil || payload.Password != "n3wer!pw" {
+		t.Fatalf("payload after rename = %v, %v", payload, err)
+	}
+	if old, err := OpenPasswordHistory(alice, 0, priv); err != nil || old.Password != "hunter2!x" {
+		t.Fatalf("history after rename = %v, %v", old, err)
+	}
+}
+
+func TestEntryCryptoUpgradeOnWrite(t *testing.T) {
+	pub, priv, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("GenerateKeypair: %v", err)
+	}
+	entry, err := BuildPasswordEntry("github.com", "alice", model.NewPasswordPayload("hunter2!x"), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := mustOpen(t, entry, priv)
+
+	// Version 1 entries were sealed without associated data.
+	ct, ss, err := crypto.Encapsulate(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, ciphertext, err := crypto.EncryptAES256GCM(plaintext, ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.KyberCiphertext, entry.Nonce, entry.Ciphertext = ct, nonce, ciphertext
+	entry.CryptoVersion = 0
+	if got := mustOpen(t, entry, priv); got != plaintext {
+		t.Fatalf("version 1 entry = %q", got)
+	}
+
+	appState := newTestVaultState(t, entry)
+	appState.PublicKey, appState.PrivateKey = pub, priv
+	if err := UpdateEntry(appState, entry.ID, func(e *model.VaultEntry) error {
+		e.Favorite = true
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateEntry: %v", err)
+	}
+	upgraded := readTestVault(t, appState)[0]
+	if upgraded.CryptoVersion != model.CurrentEntryCrypto {
+		t.Fatalf("CryptoVersion after write = %d", upgraded.CryptoVersion)
+	}
+	if got := mustOpen(t, upgraded, priv); got != plaintext {
+		t.Errorf("upgraded entry = %q", got)
+	}
+}
+
+func mustOpen(t *testing.T, entry *model.VaultEntry, priv kem.PrivateKey) string {
+	t.Helper()
+	plaintext, err := OpenEntrySecret(entry, priv)
+	if err != nil {
+		t.Fatalf("OpenEntrySecret: %v", err)
+	}
+	return plaintext
+}
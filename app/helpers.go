@@ -2,16 +2,19 @@ package app
 
 import (
 "fmt"
+"log"
 "os"
 "path/filepath"
 "strings"
+"time"
 
-"github.com/cloudflare/circl/kem/kyber/kyber768"
+"github.com/cloudflare/circl/kem"
 
 "passquantum/core/crypto"
 "passquantum/core/filevault"
 "passquantum/core/model"
 "passquantum/core/storage"
+"passquantum/core/urlmatch"
 securestorage "passquantum/internal/storage"
 )
 
@@ -118,23 +121,23 @@ return storage.WriteVault(entries, vaultFile, password)
 
 // Crypto wrappers
 
-func GenerateKeypair() (*kyber768.PublicKey, *kyber768.PrivateKey, error) {
+func GenerateKeypair() (kem.PublicKey, kem.PrivateKey, error) {
 return crypto.GenerateKeypair()
 }
 
-func LoadKeypair(pubPath, privPath string) (*kyber768.PublicKey, *kyber768.PrivateKey, error) {
+func LoadKeypair(pubPath, privPath string) (kem.PublicKey, kem.PrivateKey, error) {
 return crypto.LoadKeypair(pubPath, privPath)
 }
 
-func SaveKeypair(pubKey *kyber768.PublicKey, privKey *kyber768.PrivateKey, pubPath, privPath string) error {
+func SaveKeypair(pubKey kem.PublicKey, privKey kem.PrivateKey, pubPath, privPath string) error {
 return crypto.SaveKeypair(pubKey, privKey, pubPath, privPath)
 }
 
-func Encapsulate(pubKey *kyber768.PublicKey) ([]byte, []byte, error) {
+func Encapsulate(pubKey kem.PublicKey) ([]byte, []byte, error) {
 return crypto.Encapsulate(pubKey)
 }
 
-func Decapsulate(ciphertext []byte, privKey *kyber768.PrivateKey) ([]byte, error) {
+func Decapsulate(ciphertext []byte, privKey kem.PrivateKey) ([]byte, error) {
 return crypto.Decapsulate(ciphertext, privKey)
 }
 
@@ -146,6 +149,100 @@ func DecryptAES256GCM(nonce, ciphertext, key []byte) (string, error) {
 return crypto.DecryptAES256GCM(nonce, ciphertext, key)
 }
 
+// SealPasswordPayload encrypts payload with a fresh KEM encapsulation and
+// stores the resulting crypto fields on entry. Entry metadata is untouched.
+func SealPasswordPayload(entry *model.VaultEntry, payload *model.PasswordPayload, pubKey kem.PublicKey) error {
+plain, err := payload.Marshal()
+if err != nil {
+return fmt.Errorf("encode password payload: %w", err)
+}
+defer crypto.WipeBytes(plain)
+return SealEntrySecret(entry, string(plain), pubKey)
+}
+
+// OpenPasswordPayload decrypts a password entry. Legacy entries whose
+// payload is the bare password come back with only Password set.
+func OpenPasswordPayload(entry *model.VaultEntry, privKey kem.PrivateKey) (*model.PasswordPayload, error) {
+plaintext, err := OpenEntrySecret(entry, privKey)
+if err != nil {
+return nil, err
+}
+return model.ParsePasswordPayload(plaintext), nil
+}
+
+// OpenPasswordHistory decrypts archived version index of a password entry.
+func OpenPasswordHistory(entry *model.VaultEntry, index int, privKey kem.PrivateKey) (*model.PasswordPayload, error) {
+if index < 0 || index >= len(entry.PasswordHistory) {
+return nil, fmt.Errorf("password history index %d out of range", index)
+}
+h := &entry.PasswordHistory[index]
+plaintext, err := openEntryCiphertext(entry, h.KyberCiphertext, h.Nonce, h.Ciphertext, h.CryptoVersion, privKey)
+if err != nil {
+return nil, err
+}
+return model.ParsePasswordPayload(plaintext), nil
+}
+
+// ReplacePasswordPayload re-encrypts entry with payload. When the password
+// itself changes (or the old payload cannot be read) the previous ciphertext
+// is archived into the entry's password history first; edits that only touch
+// URLs, notes or fields just bump the modification time.
+func ReplacePasswordPayload(entry *model.VaultEntry, payload *model.PasswordPayload, pubKey kem.PublicKey, privKey kem.PrivateKey) error {
+now := time.Now().UTC()
+old, err := OpenPasswordPayload(entry, privKey)
+if err != nil || old.Password != payload.Password {
+entry.ArchivePassword(now)
+} else {
+entry.Touch(now)
+}
+return SealPasswordPayload(entry, payload, pubKey)
+}
+
+// RestorePasswordVersion makes the password from history[index] current
+// again. The entry's URLs, notes and fields are kept; the password being
+// replaced is archived like any other change, and the restored version is
+// removed from the history so it does not appear twice.
+func RestorePasswordVersion(entry *model.VaultEntry, index int, pubKey kem.PublicKey, privKey kem.PrivateKey) error {
+if index < 0 || index >= len(entry.PasswordHistory) {
+return fmt.Errorf("password history index %d out of range", index)
+}
+restored, err := OpenPasswordHistory(entry, index, privKey)
+if err != nil {
+return err
+}
+current, err := OpenPasswordPayload(entry, privKey)
+if err != nil {
+return err
+}
+current.Password = restored.Password
+entry.PasswordHistory = append(entry.PasswordHistory[:index:index], entry.PasswordHistory[index+1:]...)
+return ReplacePasswordPayload(entry, current, pubKey, privKey)
+}
+
+// MarkEntryUsed stamps LastUsed on the entry with the given ID in the current
+// vault and writes it back without a snapshot, since only usage changed. It
+// is a no-op when the entry no longer exists.
+func MarkEntryUsed(appState *AppState, entryID uint64) error {
+appState.Mu.Lock()
+defer appState.Mu.Unlock()
+
+if !appState.IsUnlocked || appState.CurrentVault == "" {
+return fmt.Errorf("vault is locked")
+}
+vaultFile := GetVaultPath(appState.CurrentVault)
+entries, err := ReadVault(vaultFile, appState.MasterPassword)
+if err != nil {
+return err
+}
+for _, e := range entries {
+if e.ID == entryID {
+e.MarkUsed(time.Now().UTC())
+return storage.WriteVaultUsage(entries, vaultFile, appState.MasterPassword)
+}
+}
+return nil
+}
+
 // PasswordValidationResult holds the result of password validation.
 type PasswordValidationResult struct {
 Valid        bool
@@ -155,7 +252,7 @@ Warnings     []string
 
 // ValidatePassword performs comprehensive password validation:
 // length > 8, must contain special characters, must not already exist in the vault.
-func ValidatePassword(password string, vaultFile string, masterPassword string, privateKey *kyber768.PrivateKey) PasswordValidationResult {
+func ValidatePassword(password string, vaultFile string, masterPassword string, privateKey kem.PrivateKey) PasswordValidationResult {
 result := PasswordValidationResult{
 Valid:    true,
 Warnings: []string{},
@@ -193,16 +290,18 @@ return result
 }
 
 for _, entry := range entries {
-ss, err := Decapsulate(entry.KyberCiphertext, privateKey)
-if err != nil {
+if entry.Deleted {
 continue
 }
-
-plaintext, err := DecryptAES256GCM(entry.Nonce, entry.Ciphertext, ss)
+plaintext, err := OpenEntrySecret(entry, privateKey)
 if err != nil {
 continue
 }
 
+if entry.Type == model.EntryTypePassword {
+plaintext = model.ParsePasswordPayload(plaintext).Password
+}
+
 if plaintext == password {
 result.Valid = false
 result.ErrorMessage = fmt.Sprintf("This password already exists in the vault (used for: %s)", entry.Service)
@@ -214,7 +313,8 @@ return result
 }
 
 // InitFileStore creates (or re-opens) the file vault store for the current vault.
-// It also ensures a TempTracker exists on the appState.
+// It also ensures a TempTracker exists on the appState, and starts rewriting
+// blobs stored in the old stream format in the background.
 func InitFileStore(appState *AppState) error {
 if appState.CurrentVault == "" || appState.MasterPassword == "" {
 return fmt.Errorf("vault must be unlocked before initializing file store")
@@ -232,18 +332,23 @@ appState.TempTracker,
 if err != nil {
 return fmt.Errorf("failed to init file store: %w", err)
 }
+if _, err := store.PurgeTrash(time.Now(), appState.TrashRetention); err != nil {
+log.Printf("[FileVault] WARNING: could not purge expired trash: %v", err)
+}
 if appState.FileStore != nil {
 appState.FileStore.Close()
 }
 appState.FileStore = store
+store.StartUpgrade()
 return nil
 }
 
-// EntriesByType filters vault entries to a single type.
+// EntriesByType filters vault entries to a single type, leaving out entries
+// that are in the trash.
 func EntriesByType(entries []*model.VaultEntry, t model.EntryType) []*model.VaultEntry {
 var filtered []*model.VaultEntry
 for _, e := range entries {
-if e.Type == t {
+if e.Type == t && !e.Deleted {
 filtered = append(filtered, e)
 }
 }
@@ -257,6 +362,8 @@ return filtered
 // For EntryTypeTOTP, the "TOTP:" prefix on Service is stripped before comparing,
 // so callers may pass either "GitHub" or "TOTP:GitHub" as service.
 //
+// Entries in the trash never match.
+//
 // Returns nil for types without a natural dedup key (Note, Card, File).
 func FindDuplicateEntry(
 entries []*model.VaultEntry,
@@ -271,7 +378,7 @@ wantService := normalizeServiceForCompare(service, entryType)
 wantUsername := strings.ToLower(strings.TrimSpace(username))
 
 for _, entry := range entries {
-if entry == nil || entry.Type != entryType {
+if entry == nil || entry.Type != entryType || entry.Deleted {
 continue
 }
 gotService := normalizeServiceForCompare(entry.Service, entryType)
@@ -296,26 +403,17 @@ s = strings.ToLower(strings.TrimSpace(s))
 return normalizeDomainForCompare(s)
 }
 
-// normalizeDomainForCompare strips protocol, path, port and "www." prefix from
-// a service string that looks like a URL or hostname. Falls back to the
-// trimmed input for non-URL strings ("Gmail", "GitHub", etc.).
-//
-// Duplicated here (rather than imported from internal/browser) to avoid an
-// import cycle: internal/browser already imports passquantum/app.
+// normalizeDomainForCompare reduces a service string that looks like a URL
+// or hostname to its host, parsed by urlmatch.Host exactly as browser
+// lookups and imports parse it. Falls back to the trimmed input for non-URL
+// strings ("Gmail", "GitHub", etc.).
 func normalizeDomainForCompare(raw string) string {
 raw = strings.TrimSpace(raw)
-if raw == "" {
-return ""
-}
-if idx := strings.Index(raw, "://"); idx >= 0 {
-raw = raw[idx+3:]
-}
-if idx := strings.IndexAny(raw, "/?#"); idx >= 0 {
-raw = raw[:idx]
+if strings.ContainsAny(raw, " \t") {
+return raw
 }
-if idx := strings.Index(raw, ":"); idx >= 0 {
-raw = raw[:idx]
+if host := urlmatch.Host(raw); host != "" {
+return host
 }
-raw = strings.TrimPrefix(raw, "www.")
 return raw
 }
@@ -140,3 +140,126 @@ func TestFindDuplicateEntry_NilEntryInSlice(t *testing.T) {
 		t.Error("expected match despite nil entry in slice")
 	}
 }
+
+func TestPasswordPayload_SealOpenRoundTrip(t *testing.T) {
+	pub, priv, err := GenerateKeypair()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
+	}
+	entry := makePasswordEntry("github.com", "octocat")
+	payload := model.NewPasswordPayload("hunter2!")
+	payload.Notes = "recovery codes in the safe"
+	payload.Fields = []model.CustomField{{Name: "PIN", Value: "0000", Hidden: true}}
+
+	if err := SealPasswordPayload(entry, payload, pub); err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	got, err := OpenPasswordPayload(entry, priv)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got.Password != "hunter2!" || got.Notes != payload.Notes || len(got.Fields) != 1 {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+func TestPasswordPayload_OpenLegacyRawPassword(t *testing.T) {
+	pub, priv, err := GenerateKeypair()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
+	}
+	ct, ss, err := Encapsulate(pub)
+	if err != nil {
+		t.Fatalf("encapsulate: %v", err)
+	}
+	nonce, ciphertext, err := EncryptAES256GCM("legacy-pw", ss)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	entry := makePasswordEntry("example.com", "bob")
+	entry.KyberCiphertext, entry.Nonce, entry.Ciphertext = ct, nonce, ciphertext
+
+	got, err := OpenPasswordPayload(entry, priv)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got.Password != "legacy-pw" || got.HasExtras() {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+func TestReplacePasswordPayload_ArchivesOnlyPasswordChanges(t *testing.T) {
+	pub, priv, err := GenerateKeypair()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
+	}
+	entry := makePasswordEntry("github.com", "octocat")
+	if err := SealPasswordPayload(entry, model.NewPasswordPayload("first"), pub); err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	notesOnly := model.NewPasswordPayload("first")
+	notesOnly.Notes = "just a note"
+	if err := ReplacePasswordPayload(entry, notesOnly, pub, priv); err != nil {
+		t.Fatalf("replace notes: %v", err)
+	}
+	if len(entry.PasswordHistory) != 0 {
+		t.Fatalf("notes-only edit archived a version")
+	}
+	if entry.Modified.IsZero() {
+		t.Error("modified time not stamped")
+	}
+
+	changed := model.NewPasswordPayload("second")
+	changed.Notes = "just a note"
+	if err := ReplacePasswordPayload(entry, changed, pub, priv); err != nil {
+		t.Fatalf("replace password: %v", err)
+	}
+	if len(entry.PasswordHistory) != 1 {
+		t.Fatalf("history length = %d, want 1", len(entry.PasswordHistory))
+	}
+	old, err := OpenPasswordHistory(entry, 0, priv)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	if old.Password != "first" {
+		t.Errorf("archived password = %q", old.Password)
+	}
+}
+
+func TestRestorePasswordVersion(t *testing.T) {
+	pub, priv, err := GenerateKeypair()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
+	}
+	entry := makePasswordEntry("github.com", "octocat")
+	if err := SealPasswordPayload(entry, model.NewPasswordPayload("working"), pub); err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	broken := model.NewPasswordPayload("broken")
+	broken.Notes = "updated by extension"
+	if err := ReplacePasswordPayload(entry, broken, pub, priv); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+
+	if err := RestorePasswordVersion(entry, 0, pub, priv); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	current, err := OpenPasswordPayload(entry, priv)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if current.Password != "working" || current.Notes != "updated by extension" {
+		t.Errorf("current = %+v", current)
+	}
+	if len(entry.PasswordHistory) != 1 {
+		t.Fatalf("history length = %d, want 1", len(entry.PasswordHistory))
+	}
+	prev, _ := OpenPasswordHistory(entry, 0, priv)
+	if prev.Password != "broken" {
+		t.Errorf("archived after restore = %q", prev.Password)
+	}
+	if err := RestorePasswordVersion(entry, 5, pub, priv); err == nil {
+		t.Error("expected out-of-range error")
+	}
+}
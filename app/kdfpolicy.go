@@ -0,0 +1,310 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"passquantum/core/crypto"
+	"passquantum/core/filevault"
+	"passquantum/core/storage"
+	securestorage "passquantum/internal/storage"
+)
+
+// Calibration targets. The unlock target covers the derivations made once
+// per unlock (the security profile and private.key). Vault files are
+// derived again on every read and write, several times for one copy or
+// save, so they get a per-operation target instead.
+const (
+	kdfPolicyFileName     = "kdf_policy.json"
+	defaultKDFTarget      = 500 * time.Millisecond
+	defaultVaultKDFTarget = 100 * time.Millisecond
+)
+
+// KDFPolicy is the installation's Argon2id policy: the unlock and
+// per-operation times calibration aims for and a minimum cost. A security
+// team can raise the minimum by deploying kdf_policy.json; anything derived
+// below it is re-derived at the next unlock, whatever the calibration
+// chose. Zero fields mean the defaults.
+type KDFPolicy struct {
+	TargetMillis      int64  `json:"target_unlock_ms,omitempty"`
+	VaultTargetMillis int64  `json:"target_vault_ms,omitempty"`
+	MinMemoryKiB      uint32 `json:"min_memory_kib,omitempty"`
+	MinIterations     uint32 `json:"min_iterations,omitempty"`
+}
+
+// LoadKDFPolicy reads the saved policy; none saved means the defaults.
+func LoadKDFPolicy() (*KDFPolicy, error) {
+	path, err := securestorage.GetSecureFilePath(kdfPolicyFileName)
+	if err != nil {
+		return nil, err
+	}
+	p := &KDFPolicy{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return nil, fmt.Errorf("read KDF policy: %w", err)
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("parse KDF policy: %w", err)
+	}
+	return p, nil
+}
+
+func (p *KDFPolicy) Save() error {
+	path, err := securestorage.GetSecureFilePath(kdfPolicyFileName)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal KDF policy: %w", err)
+	}
+	return securestorage.WriteFileAtomic(path, data, 0600)
+}
+
+// Target is the time one unlock derivation should take on this machine.
+func (p *KDFPolicy) Target() time.Duration {
+	if p.TargetMillis <= 0 {
+		return defaultKDFTarget
+	}
+	return time.Duration(p.TargetMillis) * time.Millisecond
+}
+
+// VaultTarget is the time deriving the key of one vault file should take.
+func (p *KDFPolicy) VaultTarget() time.Duration {
+	if p.VaultTargetMillis <= 0 {
+		return defaultVaultKDFTarget
+	}
+	return time.Duration(p.VaultTargetMillis) * time.Millisecond
+}
+
+// Floor is the weakest cost allowed, never below crypto.DefaultKDFParams
+// nor above crypto.MaxKDFMemory and crypto.MaxKDFIterations.
+func (p *KDFPolicy) Floor() crypto.KDFParams {
+	floor := crypto.KDFParams{
+		Memory:     min(p.MinMemoryKiB, crypto.MaxKDFMemory),
+		Iterations: min(p.MinIterations, crypto.MaxKDFIterations),
+	}
+	return floor.AtLeast(crypto.DefaultKDFParams())
+}
+
+// KDFStatus describes the Argon2id cost new derivations use, at unlock and
+// for vault files, and the policy behind it.
+type KDFStatus struct {
+	MemoryKiB         uint32    `json:"memory_kib"`
+	Iterations        uint32    `json:"iterations"`
+	Parallelism       uint8     `json:"parallelism"`
+	TargetMillis      int64     `json:"target_unlock_ms"`
+	VaultMemoryKiB    uint32    `json:"vault_memory_kib"`
+	VaultIterations   uint32    `json:"vault_iterations"`
+	VaultTargetMillis int64     `json:"target_vault_ms"`
+	CalibratedAt      time.Time `json:"calibrated_at,omitempty"`
+	MinMemoryKiB      uint32    `json:"min_memory_kib"`
+	MinIterations     uint32    `json:"min_iterations"`
+}
+
+// CurrentKDFStatus reports the cost in effect for this session and the
+// saved policy. CalibratedAt is zero when the cost was never calibrated.
+func CurrentKDFStatus() (KDFStatus, error) {
+	policy, err := LoadKDFPolicy()
+	if err != nil {
+		return KDFStatus{}, err
+	}
+	params, calibration := crypto.CurrentKDFPolicy()
+	vault := crypto.CurrentVaultKDFPolicy()
+	floor := policy.Floor()
+	status := KDFStatus{
+		MemoryKiB:         params.Memory,
+		Iterations:        params.Iterations,
+		Parallelism:       params.Parallelism,
+		TargetMillis:      policy.Target().Milliseconds(),
+		VaultMemoryKiB:    vault.Memory,
+		VaultIterations:   vault.Iterations,
+		VaultTargetMillis: policy.VaultTarget().Milliseconds(),
+		MinMemoryKiB:      floor.Memory,
+		MinIterations:     floor.Iterations,
+	}
+	if calibration != nil {
+		status.TargetMillis = calibration.TargetMillis
+		if calibration.VaultTargetMillis > 0 {
+			status.VaultTargetMillis = calibration.VaultTargetMillis
+		}
+		status.CalibratedAt = calibration.CalibratedAt
+	}
+	return status, nil
+}
+
+// applyKDFPolicy sets the cost of new derivations: the profile's calibrated
+// parameters when it has them, raised to the policy floor. A profile
+// calibrated before vault files had their own cost seals them at the floor.
+func applyKDFPolicy(profile *crypto.AppSecurityProfile) {
+	policy, err := LoadKDFPolicy()
+	if err != nil {
+		log.Printf("[Vault] WARNING: %v; using the default KDF floor", err)
+		policy = &KDFPolicy{}
+	}
+	params := policy.Floor()
+	vault := params
+	var calibration *crypto.KDFCalibration
+	if profile != nil && profile.KDFCalibration != nil {
+		params = profile.KDFParams.AtLeast(params)
+		calibration = profile.KDFCalibration
+		if profile.VaultKDFParams != nil {
+			vault = profile.VaultKDFParams.AtLeast(vault)
+		}
+	}
+	crypto.SetKDFPolicy(params, calibration)
+	crypto.SetVaultKDFPolicy(vault)
+}
+
+// calibrateKDFPolicy benchmarks this machine against the policy's two
+// targets and makes the results the cost of new derivations. It returns the
+// unlock cost.
+func calibrateKDFPolicy() (crypto.KDFParams, error) {
+	policy, err := LoadKDFPolicy()
+	if err != nil {
+		return crypto.KDFParams{}, err
+	}
+	params := crypto.CalibrateKDF(policy.Target(), policy.Floor())
+	vault := crypto.CalibrateKDF(policy.VaultTarget(), policy.Floor())
+	crypto.SetKDFPolicy(params, &crypto.KDFCalibration{
+		TargetMillis:      policy.Target().Milliseconds(),
+		VaultTargetMillis: policy.VaultTarget().Milliseconds(),
+		CalibratedAt:      time.Now().UTC(),
+	})
+	crypto.SetVaultKDFPolicy(vault)
+	return params, nil
+}
+
+// RecalibrateKDF benchmarks the machine again, for instance after the
+// policy changed or on new hardware, and re-derives the security profile
+// under the result. private.key and the vaults are sealed again where they
+// are now below the policy.
+func RecalibrateKDF(appState *AppState) (crypto.KDFParams, error) {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+
+	if !appState.IsUnlocked || appState.MasterPassword == "" || appState.PrivateKey == nil {
+		return crypto.KDFParams{}, fmt.Errorf("unlock the app before calibrating")
+	}
+	params, err := calibrateKDFPolicy()
+	if err != nil {
+		return crypto.KDFParams{}, err
+	}
+	if err := wrapStoredPrivateKey(appState.PrivateKey, appState.MasterPassword); err != nil {
+		return params, fmt.Errorf("private.key: %w", err)
+	}
+	return params, upgradeKDF(appState, true)
+}
+
+// EnforceKDFPolicy applies the saved policy to the unlocked session, for
+// instance after the floor was raised, and re-derives whatever is now below
+// it. Unlike RecalibrateKDF it keeps the calibrated cost where that already
+// meets the floor.
+func EnforceKDFPolicy(appState *AppState) error {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+
+	if !appState.IsUnlocked || appState.MasterPassword == "" || appState.PrivateKey == nil {
+		return fmt.Errorf("unlock the app before applying the KDF policy")
+	}
+	applyKDFPolicy(appState.SecurityProfile)
+	if err := wrapStoredPrivateKey(appState.PrivateKey, appState.MasterPassword); err != nil {
+		return fmt.Errorf("private.key: %w", err)
+	}
+	return upgradeKDF(appState, false)
+}
+
+// upgradeKDF re-derives the security profile when its Argon2id cost is
+// below the current policy (or always, with force), and seals again every
+// other file derived from the master password that is below it: vaults and
+// their snapshots, file store manifests and retired keys. An attacker would
+// go for the cheapest of them. Each file stays valid for the same password
+// on its own, so an interruption needs no journal. appState.Mu must be
+// held.
+func upgradeKDF(appState *AppState, force bool) error {
+	policy, _ := crypto.CurrentKDFPolicy()
+	password := appState.MasterPassword
+	var errs []error
+
+	if profile := appState.SecurityProfile; force || profile == nil || profile.KDFParams.Weaker(policy) {
+		if err := rederiveSecurityProfile(appState); err != nil {
+			errs = append(errs, fmt.Errorf("security profile: %w", err))
+		}
+	}
+	for _, name := range ListVaults() {
+		vaultPath := GetVaultPath(name)
+		upgraded, err := storage.UpgradeVaultKDF(vaultPath, password)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("vault %s: %w", name, err))
+		} else if upgraded {
+			log.Printf("[Vault] re-derived vault %s at the current KDF cost", name)
+		}
+		snapshots, err := storage.ListSnapshots(vaultPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("snapshots of vault %s: %w", name, err))
+			continue
+		}
+		for _, snap := range snapshots {
+			if upgraded, err := storage.UpgradeSnapshotKDF(snap, password); err != nil {
+				errs = append(errs, fmt.Errorf("snapshot %s of vault %s: %w", snap.ID, name, err))
+			} else if upgraded {
+				log.Printf("[Vault] re-derived snapshot %s of vault %s at the current KDF cost", snap.ID, name)
+			}
+		}
+	}
+
+	stores, err := filevault.ListStores()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, name := range stores {
+		var upgraded bool
+		if appState.FileStore != nil && name == appState.CurrentVault {
+			upgraded, err = appState.FileStore.UpgradeKDF()
+		} else {
+			var path string
+			if path, err = filevault.ManifestPath(name); err == nil {
+				upgraded, err = filevault.UpgradeManifestKDF(path, password)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("file store %s: %w", name, err))
+		} else if upgraded {
+			log.Printf("[Vault] re-derived the file manifest of %s at the current KDF cost", name)
+		}
+	}
+
+	if upgraded, err := upgradeRetiredKeys(password); err != nil {
+		errs = append(errs, err)
+	} else if upgraded > 0 {
+		log.Printf("[Vault] re-wrapped %d retired keys at the current KDF cost", upgraded)
+	}
+	return errors.Join(errs...)
+}
+
+// rederiveSecurityProfile replaces the profile with one derived at the
+// current policy and switches the session to its keys.
+func rederiveSecurityProfile(appState *AppState) error {
+	profile, sessionEncryptionKey, sessionVerificationKey, err := crypto.CreateAppSecurityProfile(appState.MasterPassword, appState.PrivateKey)
+	if err != nil {
+		return err
+	}
+	if err := storage.SaveAppSecurityProfile(appSecurityMetadataPath, profile); err != nil {
+		crypto.WipeBytes(sessionEncryptionKey)
+		crypto.WipeBytes(sessionVerificationKey)
+		return err
+	}
+	crypto.WipeBytes(appState.SessionEncryptionKey)
+	crypto.WipeBytes(appState.SessionVerificationKey)
+	appState.SecurityProfile = profile
+	appState.SessionEncryptionKey = sessionEncryptionKey
+	appState.SessionVerificationKey = sessionVerificationKey
+	return nil
+}
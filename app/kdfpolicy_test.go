@@ -0,0 +1,196 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"passquantum/core/crypto"
+	"passquantum/core/filevault"
+	"passquantum/core/model"
+	"passquantum/core/storage"
+	securestorage "passquantum/internal/storage"
+)
+
+// vaultKDFParams returns the Argon2id cost in a vault's PQ header.
+func vaultKDFParams(t *testing.T, name string) crypto.KDFParams {
+	t.Helper()
+	data, err := securestorage.ReadVaultFile(GetVaultPath(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := crypto.ParsePQVaultHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header.KDFParams()
+}
+
+// fileKDFParams returns the Argon2id cost in the PQ header of the file at
+// path.
+func fileKDFParams(t *testing.T, path string) crypto.KDFParams {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := crypto.ParsePQVaultHeader(data)
+	if err != nil {
+		t.Fatalf("%s: %v", filepath.Base(path), err)
+	}
+	return header.KDFParams()
+}
+
+func TestUnlockRederivesBelowPolicyFloor(t *testing.T) {
+	appState := newTestKeypair(t)
+	prevParams, prevCalibration := crypto.CurrentKDFPolicy()
+	prevVault := crypto.CurrentVaultKDFPolicy()
+	t.Cleanup(func() {
+		crypto.SetKDFPolicy(prevParams, prevCalibration)
+		crypto.SetVaultKDFPolicy(prevVault)
+	})
+
+	if err := CreateMasterPasswordProfile(appState, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if appState.SecurityProfile.KDFCalibration == nil {
+		t.Error("new profile carries no calibration")
+	}
+	if err := CreateNewVault(appState, "Default"); err != nil {
+		t.Fatal(err)
+	}
+	// Vault files are sealed at the per-operation cost, not the unlock one.
+	vaultCost := appState.SecurityProfile.VaultKDFParams
+	if vaultCost == nil {
+		t.Fatal("new profile carries no vault cost")
+	}
+	if got := vaultKDFParams(t, "Default"); got.Memory != vaultCost.Memory || got.Iterations != vaultCost.Iterations {
+		t.Errorf("vault cost %+v, want the calibrated %+v", got, *vaultCost)
+	}
+	appState.ClearSensitiveState()
+
+	floor := vaultKDFParams(t, "Default")
+	floor.Iterations++
+	policy := &KDFPolicy{MinMemoryKiB: floor.Memory, MinIterations: floor.Iterations}
+	if err := policy.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := NewHeadlessSession("first", "Default")
+	if err != nil {
+		t.Fatalf("unlock under a raised floor: %v", err)
+	}
+	defer session.ClearSensitiveState()
+	if got := session.SecurityProfile.KDFParams; got.Weaker(floor) {
+		t.Errorf("profile cost %+v, want at least %+v", got, floor)
+	}
+	if got := vaultKDFParams(t, "Default"); got.Weaker(floor) {
+		t.Errorf("vault cost %+v, want at least %+v", got, floor)
+	}
+	privPath, _ := securestorage.GetSecureFilePath(PrivKeyPath)
+	data, err := securestorage.ReadVaultFile(privPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.WrappedKeyKDFOutdated(data) {
+		t.Error("private.key still wrapped below the floor")
+	}
+
+	status, err := CurrentKDFStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.MinIterations != floor.Iterations {
+		t.Errorf("status floor iterations = %d, want %d", status.MinIterations, floor.Iterations)
+	}
+	if _, err := NewHeadlessSession("first", "Default"); err != nil {
+		t.Fatalf("unlock after re-derivation: %v", err)
+	}
+}
+
+func TestUnlockRederivesSnapshotsManifestsAndRetiredKeys(t *testing.T) {
+	appState := newTestKeypair(t)
+	prevParams, prevCalibration := crypto.CurrentKDFPolicy()
+	prevVault := crypto.CurrentVaultKDFPolicy()
+	t.Cleanup(func() {
+		crypto.SetKDFPolicy(prevParams, prevCalibration)
+		crypto.SetVaultKDFPolicy(prevVault)
+	})
+
+	if err := CreateMasterPasswordProfile(appState, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateNewVault(appState, "Default"); err != nil {
+		t.Fatal(err)
+	}
+	appState.CurrentVault = "Default"
+	entry, err := BuildPasswordEntry("github.com", "alice", &model.PasswordPayload{Password: "hunter2"}, appState.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddEntry(appState, entry); err != nil {
+		t.Fatal(err)
+	}
+	store, err := filevault.NewStore("Default", "first", appState.PublicKey, appState.PrivateKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "doc.txt")
+	if err := os.WriteFile(src, []byte("contents"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.StoreFile(src, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Rotating retires the old private key under the master password.
+	if err := RotateKeys(appState); err != nil {
+		t.Fatal(err)
+	}
+	appState.ClearSensitiveState()
+
+	snapshots, err := storage.ListSnapshots(GetVaultPath("Default"))
+	if err != nil || len(snapshots) == 0 {
+		t.Fatalf("ListSnapshots = %d, %v", len(snapshots), err)
+	}
+	manifest, err := filevault.ManifestPath("Default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	retired, err := listRetiredKeys()
+	if err != nil || len(retired) != 1 {
+		t.Fatalf("retired keys = %v, %v", retired, err)
+	}
+	planted := []string{snapshots[0].Path, manifest, retired[0]}
+
+	// Raise the floor above every planted file's cost.
+	var floor crypto.KDFParams
+	for _, path := range planted {
+		p := fileKDFParams(t, path)
+		floor.Memory = max(floor.Memory, p.Memory)
+		floor.Iterations = max(floor.Iterations, p.Iterations+1)
+	}
+	policy := &KDFPolicy{MinMemoryKiB: floor.Memory, MinIterations: floor.Iterations}
+	if err := policy.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := NewHeadlessSession("first", "Default")
+	if err != nil {
+		t.Fatalf("unlock under a raised floor: %v", err)
+	}
+	defer session.ClearSensitiveState()
+	for _, path := range planted {
+		if got := fileKDFParams(t, path); got.Weaker(floor) {
+			t.Errorf("%s cost %+v, want at least %+v", filepath.Base(path), got, floor)
+		}
+	}
+	if _, err := storage.ReadSnapshot(snapshots[0], "first"); err != nil {
+		t.Errorf("upgraded snapshot: %v", err)
+	}
+	if err := InitFileStore(session); err != nil {
+		t.Errorf("upgraded manifest: %v", err)
+	}
+	if _, err := crypto.LoadPrivateKey(retired[0], "first"); err != nil {
+		t.Errorf("upgraded retired key: %v", err)
+	}
+}
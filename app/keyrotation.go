@@ -0,0 +1,529 @@
+package app
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+
+	"passquantum/core/crypto"
+	"passquantum/core/filevault"
+	"passquantum/core/model"
+	"passquantum/core/storage"
+	securestorage "passquantum/internal/storage"
+)
+
+const (
+	keyRotationFileName = "key_rotation.json"
+	retiredKeysDirName  = "retired-keys"
+)
+
+// KeyRotationSchedule is how often the vault keypair must be replaced, when
+// it last was, and which KEM the next keypair uses. A zero IntervalDays
+// means rotation is manual only; an empty Algorithm means crypto.DefaultKEM.
+type KeyRotationSchedule struct {
+	IntervalDays int       `json:"interval_days"`
+	LastRotated  time.Time `json:"last_rotated"`
+	Algorithm    string    `json:"algorithm,omitempty"`
+}
+
+// LoadKeyRotationSchedule reads the saved schedule; none saved means manual
+// rotation and an unknown key age.
+func LoadKeyRotationSchedule() (*KeyRotationSchedule, error) {
+	path, err := securestorage.GetSecureFilePath(keyRotationFileName)
+	if err != nil {
+		return nil, err
+	}
+	s := &KeyRotationSchedule{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read key rotation schedule: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parse key rotation schedule: %w", err)
+	}
+	return s, nil
+}
+
+func (s *KeyRotationSchedule) Save() error {
+	path, err := securestorage.GetSecureFilePath(keyRotationFileName)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal key rotation schedule: %w", err)
+	}
+	return securestorage.WriteFileAtomic(path, data, 0600)
+}
+
+// NextRotation returns when the schedule next requires a rotation, or the
+// zero time when rotation is manual. A key of unknown age is due now.
+func (s *KeyRotationSchedule) NextRotation(now time.Time) time.Time {
+	if s.IntervalDays <= 0 {
+		return time.Time{}
+	}
+	if s.LastRotated.IsZero() {
+		return now
+	}
+	return s.LastRotated.Add(time.Duration(s.IntervalDays) * 24 * time.Hour)
+}
+
+// KEM returns the algorithm the next keypair is generated for.
+func (s *KeyRotationSchedule) KEM() (crypto.KEMAlgorithm, error) {
+	if s.Algorithm == "" {
+		return crypto.DefaultKEM, nil
+	}
+	algorithm, err := crypto.ParseKEMAlgorithm(s.Algorithm)
+	if err == nil && algorithm.Deprecated() {
+		err = fmt.Errorf("%s is deprecated and cannot be used for new keys", algorithm)
+	}
+	return algorithm, err
+}
+
+func (s *KeyRotationSchedule) Due(now time.Time) bool {
+	next := s.NextRotation(now)
+	return !next.IsZero() && !now.Before(next)
+}
+
+// stampKeyCreated starts the rotation clock for a keypair set up without a
+// recorded age, so a fresh install is not immediately due.
+func stampKeyCreated() error {
+	s, err := LoadKeyRotationSchedule()
+	if err != nil || !s.LastRotated.IsZero() {
+		return err
+	}
+	s.LastRotated = time.Now().UTC()
+	return s.Save()
+}
+
+// KeyRotationDue reports whether the saved schedule requires a rotation now.
+func KeyRotationDue(now time.Time) bool {
+	s, err := LoadKeyRotationSchedule()
+	return err == nil && s.Due(now)
+}
+
+// RotateKeys replaces the vault keypair with a new one for the schedule's
+// algorithm. Every entry (including saved
+// password versions) in every vault is re-sealed for the new public key,
+// every file manifest is re-keyed (blobs keep their content key, wrapped
+// under the new key), every sealed file is sealed again, and the security
+// profile is re-bound to the new private key.
+//
+// All of it is prepared in memory first and then replaced in one journaled
+// transaction, so an interruption either changes nothing, and the rotation
+// can simply be run again, or is completed by RecoverJournal at the next
+// start. The old private key is kept under retired-keys/, wrapped under the
+// master password, so snapshots taken before the rotation stay restorable.
+func RotateKeys(appState *AppState) error {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+
+	if !appState.IsUnlocked || appState.MasterPassword == "" || appState.PrivateKey == nil {
+		return fmt.Errorf("unlock the app before rotating keys")
+	}
+	password := appState.MasterPassword
+	oldPub, oldPriv := appState.PublicKey, appState.PrivateKey
+
+	// The open store's background upgrade saves its manifest, which must
+	// not land between reading the manifests here and the commit below.
+	if appState.FileStore != nil {
+		appState.FileStore.StopUpgrade()
+		defer appState.FileStore.StartUpgrade()
+	}
+
+	schedule, err := LoadKeyRotationSchedule()
+	if err != nil {
+		return err
+	}
+	algorithm, err := schedule.KEM()
+	if err != nil {
+		return err
+	}
+	newPub, newPriv, err := crypto.GenerateKeypairFor(algorithm)
+	if err != nil {
+		return fmt.Errorf("failed to generate keypair: %w", err)
+	}
+	oldKeys := []kem.PrivateKey{oldPriv}
+
+	type staged struct {
+		path string
+		data []byte
+	}
+	var vaults, manifests, sealed []staged
+
+	for _, name := range ListVaults() {
+		path := GetVaultPath(name)
+		entries, err := storage.ReadVault(path, password)
+		if err != nil {
+			return fmt.Errorf("failed to read vault %s: %w", name, err)
+		}
+		for _, e := range entries {
+			if err := resealEntry(e, oldKeys, newPub); err != nil {
+				return fmt.Errorf("vault %s, entry %s: %w (run pq fsck)", name, FormatEntryID(e.ID), err)
+			}
+		}
+		data, err := storage.EncodeVault(entries, password)
+		if err != nil {
+			return fmt.Errorf("failed to encode vault %s: %w", name, err)
+		}
+		vaults = append(vaults, staged{path, data})
+	}
+
+	stores, err := filevault.ListStores()
+	if err != nil {
+		return err
+	}
+	for _, name := range stores {
+		store, err := filevault.NewStore(name, password, oldPub, oldPriv, nil)
+		if err != nil {
+			return fmt.Errorf("failed to open file store %s: %w", name, err)
+		}
+		path, data, err := store.RekeyedManifest(newPub)
+		if err != nil {
+			return fmt.Errorf("file store %s: %w", name, err)
+		}
+		manifests = append(manifests, staged{path, data})
+	}
+	sealedPaths, sealedContents, err := resealedFiles(oldPriv, newPub)
+	if err != nil {
+		return err
+	}
+	for i, path := range sealedPaths {
+		sealed = append(sealed, staged{path, sealedContents[i]})
+	}
+
+	profile, sessionEncryptionKey, sessionVerificationKey, err := crypto.CreateAppSecurityProfile(password, newPriv)
+	if err != nil {
+		return err
+	}
+	defer crypto.WipeBytes(sessionEncryptionKey)
+	defer crypto.WipeBytes(sessionVerificationKey)
+
+	pubBytes, err := crypto.MarshalPublicKey(newPub)
+	if err != nil {
+		return err
+	}
+	wrappedNew, err := crypto.WrapPrivateKey(newPriv, password)
+	if err != nil {
+		return err
+	}
+	wrappedOld, err := crypto.WrapPrivateKey(oldPriv, password)
+	if err != nil {
+		return err
+	}
+	retiredPath, err := retiredKeyPath(oldPriv)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(retiredPath), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", retiredKeysDirName, err)
+	}
+
+	schedule.LastRotated = time.Now().UTC()
+	scheduleData, err := json.MarshalIndent(schedule, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	pubPath, err := securestorage.GetSecureFilePath(PubKeyPath)
+	if err != nil {
+		return err
+	}
+	privPath, err := securestorage.GetSecureFilePath(PrivKeyPath)
+	if err != nil {
+		return err
+	}
+	schedulePath, err := securestorage.GetSecureFilePath(keyRotationFileName)
+	if err != nil {
+		return err
+	}
+
+	tx, err := securestorage.BeginTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Abort()
+
+	for _, v := range vaults {
+		if err := storage.SnapshotVaultFile(v.path); err != nil {
+			return fmt.Errorf("failed to snapshot vault %s: %w", filepath.Base(v.path), err)
+		}
+	}
+	writes := append(vaults, manifests...)
+	writes = append(writes, sealed...)
+	writes = append(writes,
+		staged{pubPath, pubBytes},
+		staged{privPath, wrappedNew},
+		staged{retiredPath, wrappedOld},
+		staged{schedulePath, scheduleData},
+	)
+	for _, w := range writes {
+		if err := tx.Write(w.path, w.data, 0600); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", filepath.Base(w.path), err)
+		}
+	}
+	if err := storage.StageAppSecurityProfile(tx, appSecurityMetadataPath, profile); err != nil {
+		return fmt.Errorf("failed to stage app security metadata: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to activate the new keypair: %w", err)
+	}
+
+	appState.PublicKey = newPub
+	appState.PrivateKey = newPriv
+	appState.SecurityProfile = profile
+	crypto.WipeBytes(appState.SessionEncryptionKey)
+	crypto.WipeBytes(appState.SessionVerificationKey)
+	appState.SessionEncryptionKey = append([]byte(nil), sessionEncryptionKey...)
+	appState.SessionVerificationKey = append([]byte(nil), sessionVerificationKey...)
+	if appState.FileStore != nil {
+		if err := appState.FileStore.Rekey(newPub, newPriv); err != nil {
+			return fmt.Errorf("keys rotated, but the open file store could not be reloaded: %w", err)
+		}
+	}
+	return nil
+}
+
+// KeypairAlgorithm names the KEM of the loaded keypair, for display.
+func KeypairAlgorithm(appState *AppState) string {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+	if appState.PublicKey == nil {
+		return "not loaded"
+	}
+	algorithm, err := crypto.KEMAlgorithmOf(appState.PublicKey)
+	if err != nil {
+		return appState.PublicKey.Scheme().Name()
+	}
+	return algorithm.String()
+}
+
+// KeypairMigrationTarget reports whether a keypair under pub is replaced at
+// the next unlock, and with which algorithm. That is the case for deprecated
+// algorithms such as pre-standard Kyber768, and for a KEM-only keypair while
+// the schedule asks for a hybrid one.
+func KeypairMigrationTarget(pub kem.PublicKey) (crypto.KEMAlgorithm, bool) {
+	algorithm, err := crypto.KEMAlgorithmOf(pub)
+	if err != nil {
+		return 0, false
+	}
+	schedule, err := LoadKeyRotationSchedule()
+	if err != nil {
+		return 0, false
+	}
+	next, err := schedule.KEM()
+	if err != nil || next == algorithm {
+		return 0, false
+	}
+	if algorithm.Deprecated() || (next.Hybrid() && !algorithm.Hybrid()) {
+		return next, true
+	}
+	return 0, false
+}
+
+// migrateKeypair replaces the keypair right after unlock when
+// KeypairMigrationTarget says so. Until it succeeds the old keypair keeps
+// working, so a failure is only logged and retried at the next unlock.
+func migrateKeypair(appState *AppState) {
+	next, ok := KeypairMigrationTarget(appState.PublicKey)
+	if !ok {
+		return
+	}
+	algorithm, _ := crypto.KEMAlgorithmOf(appState.PublicKey)
+	log.Printf("[Vault] migrating the vault keypair from %s to %s", algorithm, next)
+	if err := RotateKeys(appState); err != nil {
+		log.Printf("[Vault] WARNING: keypair migration failed, retrying at next unlock: %v", err)
+	}
+}
+
+// resealEntry opens the entry's payload and password history with the
+// first of privKeys that works and seals them again for pubKey, under the
+// current entry crypto version.
+func resealEntry(e *model.VaultEntry, privKeys []kem.PrivateKey, pubKey kem.PublicKey) error {
+	return resealEntryFrom(e, e, privKeys, pubKey)
+}
+
+// resealEntryFrom is resealEntry for an entry whose ciphertexts were sealed
+// while it had the metadata of sealedAs.
+func resealEntryFrom(e, sealedAs *model.VaultEntry, privKeys []kem.PrivateKey, pubKey kem.PublicKey) error {
+	if len(e.KyberCiphertext) > 0 {
+		if err := resealCiphertext(e, sealedAs, &e.KyberCiphertext, &e.Nonce, &e.Ciphertext, &e.CryptoVersion, privKeys, pubKey); err != nil {
+			return err
+		}
+	}
+	for i := range e.PasswordHistory {
+		h := &e.PasswordHistory[i]
+		if err := resealCiphertext(e, sealedAs, &h.KyberCiphertext, &h.Nonce, &h.Ciphertext, &h.CryptoVersion, privKeys, pubKey); err != nil {
+			return fmt.Errorf("password history version %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func resealCiphertext(e, sealedAs *model.VaultEntry, kyberCt, nonce, ciphertext *[]byte, version *uint8, privKeys []kem.PrivateKey, pubKey kem.PublicKey) error {
+	var plaintext string
+	opened := false
+	for _, k := range privKeys {
+		var err error
+		plaintext, err = openEntryCiphertext(sealedAs, *kyberCt, *nonce, *ciphertext, *version, k)
+		if err == nil {
+			opened = true
+			break
+		}
+	}
+	if !opened {
+		return fmt.Errorf("cannot be decrypted with any available key")
+	}
+
+	ct, n, c, err := sealEntryCiphertext(e, plaintext, pubKey)
+	if err != nil {
+		return err
+	}
+	*kyberCt, *nonce, *ciphertext, *version = ct, n, c, model.CurrentEntryCrypto
+	return nil
+}
+
+// retiredKeyPath names a retired key after the start of its fingerprint.
+func retiredKeyPath(privKey kem.PrivateKey) (string, error) {
+	fingerprint, err := crypto.PrivateKeyFingerprint(privKey)
+	if err != nil {
+		return "", err
+	}
+	dir, err := securestorage.GetSecureFilePath(retiredKeysDirName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, hex.EncodeToString(fingerprint[:8])+".key"), nil
+}
+
+func listRetiredKeys() ([]string, error) {
+	dir, err := securestorage.GetSecureFilePath(retiredKeysDirName)
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var paths []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".key") {
+			paths = append(paths, filepath.Join(dir, f.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// stageRetiredKeys re-wraps every retired key for newPassword in tx, so a
+// master-password change does not strand them.
+func stageRetiredKeys(tx *securestorage.Tx, currentPassword, newPassword string) error {
+	paths, err := listRetiredKeys()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		key, err := crypto.LoadPrivateKey(path, currentPassword)
+		if err != nil {
+			return fmt.Errorf("failed to open retired key %s: %w", filepath.Base(path), err)
+		}
+		wrapped, err := crypto.WrapPrivateKey(key, newPassword)
+		if err != nil {
+			return err
+		}
+		if err := tx.Write(path, wrapped, 0600); err != nil {
+			return fmt.Errorf("failed to stage retired key %s: %w", filepath.Base(path), err)
+		}
+	}
+	return nil
+}
+
+// upgradeRetiredKeys wraps every retired key again whose Argon2id cost is
+// below the unlock policy, and returns how many it rewrote.
+func upgradeRetiredKeys(password string) (int, error) {
+	paths, err := listRetiredKeys()
+	if err != nil {
+		return 0, err
+	}
+	var upgraded int
+	var errs []error
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !crypto.WrappedKeyKDFOutdated(data) {
+			continue
+		}
+		key, err := crypto.UnwrapPrivateKey(data, password)
+		if err == nil {
+			err = crypto.SaveWrappedPrivateKey(key, path, password)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("retired key %s: %w", filepath.Base(path), err))
+			continue
+		}
+		upgraded++
+	}
+	return upgraded, errors.Join(errs...)
+}
+
+// adoptSnapshotEntries re-seals snapshot entries that were sealed before a
+// key rotation, so they open with the current key once restored.
+func adoptSnapshotEntries(appState *AppState, entries []*model.VaultEntry) error {
+	appState.Mu.Lock()
+	pubKey, privKey, password := appState.PublicKey, appState.PrivateKey, appState.MasterPassword
+	appState.Mu.Unlock()
+
+	var keys []kem.PrivateKey
+	loaded := false
+	for _, e := range entries {
+		if entryOpens(e, privKey) {
+			continue
+		}
+		if !loaded {
+			loaded = true
+			paths, err := listRetiredKeys()
+			if err != nil {
+				return err
+			}
+			for _, path := range paths {
+				if k, err := crypto.LoadPrivateKey(path, password); err == nil {
+					keys = append(keys, k)
+				}
+			}
+		}
+		if err := resealEntry(e, keys, pubKey); err != nil {
+			return fmt.Errorf("entry %s was sealed with a key that is no longer available: %w", EntryName(e), err)
+		}
+	}
+	return nil
+}
+
+func entryOpens(e *model.VaultEntry, privKey kem.PrivateKey) bool {
+	if len(e.KyberCiphertext) > 0 {
+		if _, err := OpenEntrySecret(e, privKey); err != nil {
+			return false
+		}
+	}
+	for i := range e.PasswordHistory {
+		if _, err := OpenPasswordHistory(e, i, privKey); err != nil {
+			return false
+		}
+	}
+	return true
+}
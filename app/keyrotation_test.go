@@ -0,0 +1,198 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"passquantum/core/crypto"
+	"passquantum/core/filevault"
+	"passquantum/core/model"
+	"passquantum/core/storage"
+	securestorage "passquantum/internal/storage"
+)
+
+func TestKeyRotationScheduleDue(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		schedule KeyRotationSchedule
+		want     bool
+	}{
+		{"manual", KeyRotationSchedule{LastRotated: now.AddDate(-5, 0, 0)}, false},
+		{"unknown age", KeyRotationSchedule{IntervalDays: 90}, true},
+		{"recent", KeyRotationSchedule{IntervalDays: 90, LastRotated: now.AddDate(0, 0, -89)}, false},
+		{"expired", KeyRotationSchedule{IntervalDays: 90, LastRotated: now.AddDate(0, 0, -90)}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.Due(now); got != tt.want {
+			t.Errorf("%s: Due = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRotateKeys(t *testing.T) {
+	appState := newTestKeypair(t)
+	if err := CreateMasterPasswordProfile(appState, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateNewVault(appState, "Default"); err != nil {
+		t.Fatal(err)
+	}
+	appState.CurrentVault = "Default"
+	entry, err := BuildPasswordEntry("github.com", "alice", &model.PasswordPayload{Password: "hunter2"}, appState.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddEntry(appState, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := filevault.NewStore("Default", "first", appState.PublicKey, appState.PrivateKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "doc.txt")
+	if err := os.WriteFile(src, []byte("contents"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := store.StoreFile(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldPriv := appState.PrivateKey
+	if err := RotateKeys(appState); err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+	if appState.PrivateKey == oldPriv {
+		t.Fatal("private key not replaced")
+	}
+	if KeyRotationDue(time.Now()) {
+		t.Error("rotation still due after rotating")
+	}
+
+	entries := readTestVault(t, appState)
+	if secret, err := OpenEntrySecret(entries[0], appState.PrivateKey); err != nil || secret == "" {
+		t.Fatalf("entry with the new key: %q, %v", secret, err)
+	}
+	if _, err := OpenEntrySecret(entries[0], oldPriv); err == nil {
+		t.Error("entry still opens with the retired key")
+	}
+
+	rotated, err := filevault.NewStore("Default", "first", appState.PublicKey, appState.PrivateKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := rotated.DecryptToMemory(meta.UUID); err != nil || string(data) != "contents" {
+		t.Fatalf("file with the new key: %q, %v", data, err)
+	}
+
+	// A snapshot from before the rotation is re-sealed on restore.
+	snapshots, err := ListVaultSnapshots(appState)
+	if err != nil || len(snapshots) == 0 {
+		t.Fatalf("ListVaultSnapshots = %d, %v", len(snapshots), err)
+	}
+	if err := RestoreVaultSnapshot(appState, snapshots[0]); err != nil {
+		t.Fatalf("RestoreVaultSnapshot: %v", err)
+	}
+	entries = readTestVault(t, appState)
+	if _, err := OpenEntrySecret(entries[0], appState.PrivateKey); err != nil {
+		t.Fatalf("restored entry with the new key: %v", err)
+	}
+
+	// Retired keys follow the master password.
+	if err := ChangeMasterPassword(appState, "first", "second"); err != nil {
+		t.Fatal(err)
+	}
+	appState.ClearSensitiveState()
+	session, err := NewHeadlessSession("second", "Default")
+	if err != nil {
+		t.Fatalf("unlock after rotation: %v", err)
+	}
+	paths, err := listRetiredKeys()
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("retired keys = %v, %v", paths, err)
+	}
+	if _, err := crypto.LoadPrivateKey(paths[0], "second"); err != nil {
+		t.Errorf("retired key with the new password: %v", err)
+	}
+	session.ClearSensitiveState()
+}
+
+func TestUnlockMigratesKeypair(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm crypto.KEMAlgorithm
+		schedule  string
+		want      crypto.KEMAlgorithm
+	}{
+		// Releases before ML-KEM wrote untagged Kyber768 keys.
+		{"kyber768", crypto.KEMKyber768, "", crypto.DefaultKEM},
+		{"kem-only to hybrid", crypto.KEMMLKEM768, "", crypto.KEMXWing},
+		{"kem-only by choice", crypto.KEMMLKEM1024, "ML-KEM-1024", crypto.KEMMLKEM1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv("HOME", t.TempDir())
+
+			pub, priv, err := crypto.GenerateKeypairFor(tt.algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubBytes, _ := crypto.MarshalPublicKey(pub)
+			privBytes, _ := crypto.MarshalPrivateKey(priv)
+			if tt.algorithm == crypto.KEMKyber768 {
+				pubBytes, _ = pub.MarshalBinary()
+				privBytes, _ = priv.MarshalBinary()
+			}
+			pubPath, _ := securestorage.GetSecureFilePath(PubKeyPath)
+			privPath, _ := securestorage.GetSecureFilePath(PrivKeyPath)
+			if err := os.WriteFile(pubPath, pubBytes, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(privPath, privBytes, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if tt.schedule != "" {
+				schedule := &KeyRotationSchedule{Algorithm: tt.schedule}
+				if err := schedule.Save(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			profile, _, _, err := crypto.CreateAppSecurityProfile("first", priv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := storage.SaveAppSecurityProfile(appSecurityMetadataPath, profile); err != nil {
+				t.Fatal(err)
+			}
+			entry, err := BuildPasswordEntry("github.com", "alice", &model.PasswordPayload{Password: "hunter2"}, pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteVault([]*model.VaultEntry{entry}, GetVaultPath("Default"), "first"); err != nil {
+				t.Fatal(err)
+			}
+
+			session, err := NewHeadlessSession("first", "Default")
+			if err != nil {
+				t.Fatalf("NewHeadlessSession: %v", err)
+			}
+			defer session.ClearSensitiveState()
+			if got := KeypairAlgorithm(session); got != tt.want.String() {
+				t.Fatalf("keypair after unlock = %s, want %s", got, tt.want)
+			}
+			entries := readTestVault(t, session)
+			if _, err := OpenEntrySecret(entries[0], session.PrivateKey); err != nil {
+				t.Fatalf("entry after unlock: %v", err)
+			}
+			stored, _, err := LoadStoredKeypair()
+			if err != nil || !stored.Equal(session.PublicKey) {
+				t.Fatalf("public.key after unlock: %v", err)
+			}
+		})
+	}
+}
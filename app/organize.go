@@ -0,0 +1,182 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"passquantum/core/model"
+)
+
+// EntryFilter selects vault entries by their organization metadata. Zero
+// values match everything, so an empty filter returns the input unchanged.
+type EntryFilter struct {
+	Folder            string   // folder path; "" matches every folder
+	IncludeSubfolders bool     // also match entries below Folder
+	Unfiled           bool     // only entries without a folder; overrides Folder
+	Tags              []string // entry must carry every tag (case-insensitive)
+	FavoritesOnly     bool
+	Types             []model.EntryType // empty means any type
+	Query             string            // case-insensitive substring of service or username
+}
+
+// Matches reports whether e passes every criterion of the filter.
+func (f EntryFilter) Matches(e *model.VaultEntry) bool {
+	if e == nil {
+		return false
+	}
+	if f.Unfiled {
+		if e.Folder != "" {
+			return false
+		}
+	} else if f.Folder != "" && !e.InFolder(f.Folder, f.IncludeSubfolders) {
+		return false
+	}
+	if f.FavoritesOnly && !e.Favorite {
+		return false
+	}
+	if len(f.Types) > 0 {
+		ok := false
+		for _, t := range f.Types {
+			if e.Type == t {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for _, tag := range f.Tags {
+		if !e.HasTag(tag) {
+			return false
+		}
+	}
+	if q := strings.ToLower(strings.TrimSpace(f.Query)); q != "" {
+		if !strings.Contains(strings.ToLower(e.Service), q) && !strings.Contains(strings.ToLower(e.Username), q) {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterEntries returns the entries that match f, preserving their order.
+func FilterEntries(entries []*model.VaultEntry, f EntryFilter) []*model.VaultEntry {
+	var out []*model.VaultEntry
+	for _, e := range entries {
+		if f.Matches(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// FolderGroup is one folder's worth of entries as returned by GroupByFolder.
+type FolderGroup struct {
+	Path    string // "" for unfiled entries
+	Entries []*model.VaultEntry
+}
+
+// GroupByFolder buckets entries by their exact folder. Groups are sorted by
+// path (case-insensitively) with unfiled entries first; favorites lead each
+// group and the original order is otherwise kept.
+func GroupByFolder(entries []*model.VaultEntry) []FolderGroup {
+	index := make(map[string]int)
+	var groups []FolderGroup
+	for _, e := range entries {
+		if e == nil {
+			continue
+		}
+		i, ok := index[e.Folder]
+		if !ok {
+			i = len(groups)
+			index[e.Folder] = i
+			groups = append(groups, FolderGroup{Path: e.Folder})
+		}
+		groups[i].Entries = append(groups[i].Entries, e)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return strings.ToLower(groups[i].Path) < strings.ToLower(groups[j].Path)
+	})
+	for _, g := range groups {
+		sort.SliceStable(g.Entries, func(i, j int) bool {
+			return g.Entries[i].Favorite && !g.Entries[j].Favorite
+		})
+	}
+	return groups
+}
+
+// FolderTree lists every folder used by entries, including intermediate
+// folders that hold no entries directly, sorted so parents precede children.
+func FolderTree(entries []*model.VaultEntry) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	for _, e := range entries {
+		if e == nil {
+			continue
+		}
+		for _, p := range model.FolderAncestors(e.Folder) {
+			if _, ok := seen[p]; ok {
+				continue
+			}
+			seen[p] = struct{}{}
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.ToLower(out[i]) < strings.ToLower(out[j])
+	})
+	return out
+}
+
+// AllTags returns the distinct tags used across entries.
+func AllTags(entries []*model.VaultEntry) []string {
+	var tags []string
+	for _, e := range entries {
+		if e != nil {
+			tags = append(tags, e.Tags...)
+		}
+	}
+	return model.NormalizeTags(tags)
+}
+
+// SetEntryOrganization updates the folder, tags and favorite flag of the
+// entry with the given ID and writes the vault back. Values are normalized
+// before they are stored.
+func SetEntryOrganization(appState *AppState, entryID uint64, folder string, tags []string, favorite bool) error {
+	return UpdateEntry(appState, entryID, func(e *model.VaultEntry) error {
+		e.Folder = model.NormalizeFolderPath(folder)
+		e.Tags = model.NormalizeTags(tags)
+		e.Favorite = favorite
+		e.Touch(time.Now().UTC())
+		return nil
+	})
+}
+
+// UpdateEntry reads the current vault, applies fn to the entry with the
+// given ID and writes the vault back, all under appState.Mu. Nothing is
+// written when fn returns an error.
+func UpdateEntry(appState *AppState, entryID uint64, fn func(*model.VaultEntry) error) error {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+
+	if !appState.IsUnlocked || appState.CurrentVault == "" {
+		return fmt.Errorf("vault is locked")
+	}
+	vaultFile := GetVaultPath(appState.CurrentVault)
+	entries, err := ReadVault(vaultFile, appState.MasterPassword)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.ID == entryID {
+			if err := fn(e); err != nil {
+				return err
+			}
+			upgradeEntryCrypto(entries, appState.PublicKey, appState.PrivateKey)
+			return WriteVault(entries, vaultFile, appState.MasterPassword)
+		}
+	}
+	return fmt.Errorf("entry %d not found", entryID)
+}
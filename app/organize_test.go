@@ -0,0 +1,81 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"passquantum/core/model"
+)
+
+func organizedEntries() []*model.VaultEntry {
+	mk := func(service, folder string, fav bool, tags ...string) *model.VaultEntry {
+		e := makePasswordEntry(service, "user")
+		e.Folder = folder
+		e.Favorite = fav
+		e.Tags = tags
+		return e
+	}
+	return []*model.VaultEntry{
+		mk("github.com", "Work/Dev", false, "ci"),
+		mk("gitlab.com", "Work/Dev", true, "ci", "mirror"),
+		mk("jira.example.com", "Work", false),
+		mk("bank.example.com", "", true, "finance"),
+		mk("shop.example.com", "Personal/Shopping", false),
+	}
+}
+
+func services(entries []*model.VaultEntry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.Service)
+	}
+	return out
+}
+
+func TestFilterEntries(t *testing.T) {
+	entries := organizedEntries()
+	cases := []struct {
+		name   string
+		filter EntryFilter
+		want   []string
+	}{
+		{"empty", EntryFilter{}, services(entries)},
+		{"exact folder", EntryFilter{Folder: "Work"}, []string{"jira.example.com"}},
+		{"subfolders", EntryFilter{Folder: "Work", IncludeSubfolders: true}, []string{"github.com", "gitlab.com", "jira.example.com"}},
+		{"unfiled", EntryFilter{Unfiled: true}, []string{"bank.example.com"}},
+		{"favorites", EntryFilter{FavoritesOnly: true}, []string{"gitlab.com", "bank.example.com"}},
+		{"all tags", EntryFilter{Tags: []string{"CI", "mirror"}}, []string{"gitlab.com"}},
+		{"query", EntryFilter{Query: "GIT", Folder: "Work/Dev"}, []string{"github.com", "gitlab.com"}},
+	}
+	for _, c := range cases {
+		got := services(FilterEntries(entries, c.filter))
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestGroupByFolder(t *testing.T) {
+	groups := GroupByFolder(organizedEntries())
+	var paths []string
+	for _, g := range groups {
+		paths = append(paths, g.Path)
+	}
+	want := []string{"", "Personal/Shopping", "Work", "Work/Dev"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("group paths = %v, want %v", paths, want)
+	}
+	if got := services(groups[3].Entries); !reflect.DeepEqual(got, []string{"gitlab.com", "github.com"}) {
+		t.Errorf("favorites should lead their group: %v", got)
+	}
+}
+
+func TestFolderTreeAndAllTags(t *testing.T) {
+	entries := organizedEntries()
+	if got, want := FolderTree(entries), []string{"Personal", "Personal/Shopping", "Work", "Work/Dev"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FolderTree = %v, want %v", got, want)
+	}
+	if got, want := AllTags(entries), []string{"ci", "finance", "mirror"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AllTags = %v, want %v", got, want)
+	}
+}
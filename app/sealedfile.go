@@ -0,0 +1,170 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudflare/circl/kem"
+
+	"passquantum/core/crypto"
+	securestorage "passquantum/internal/storage"
+)
+
+const (
+	sealedDirName     = "sealed"
+	sealedFileSuffix  = ".sealed"
+	sealedFileVersion = 1
+)
+
+// sealedFile is the on-disk form of a sealed file. The payload is
+// encrypted to the vault keypair, with the file's name as associated data
+// so one sealed file cannot be swapped for another.
+type sealedFile struct {
+	Version    int    `json:"version"`
+	KEM        []byte `json:"kem"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ReadSealedFile opens the sealed file name. Sealed files keep small
+// secrets of other components, such as the browser extension's pairing
+// keys, off the disk in the clear: they open only while the app is
+// unlocked. A file that does not exist yet reads as nil.
+func ReadSealedFile(appState *AppState, name string) ([]byte, error) {
+	appState.Mu.Lock()
+	privKey, unlocked := appState.PrivateKey, appState.IsUnlocked
+	appState.Mu.Unlock()
+	if !unlocked || privKey == nil {
+		return nil, fmt.Errorf("unlock the app to read %s", name)
+	}
+
+	path, err := sealedFilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return openSealed(name, data, privKey)
+}
+
+// WriteSealedFile seals data to the vault public key and saves it as name.
+// RotateKeys seals every such file again for the new keypair.
+func WriteSealedFile(appState *AppState, name string, data []byte) error {
+	appState.Mu.Lock()
+	pubKey, unlocked := appState.PublicKey, appState.IsUnlocked
+	appState.Mu.Unlock()
+	if !unlocked || pubKey == nil {
+		return fmt.Errorf("unlock the app to write %s", name)
+	}
+
+	sealed, err := sealData(name, data, pubKey)
+	if err != nil {
+		return err
+	}
+	path, err := sealedFilePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", sealedDirName, err)
+	}
+	return securestorage.WriteFileAtomic(path, sealed, 0600)
+}
+
+func sealedFilePath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid sealed file name %q", name)
+	}
+	return securestorage.GetSecureFilePath(filepath.Join(sealedDirName, name+sealedFileSuffix))
+}
+
+func sealedAssociatedData(name string) []byte {
+	return []byte("passquantum sealed file v1\x00" + name)
+}
+
+func sealData(name string, data []byte, pubKey kem.PublicKey) ([]byte, error) {
+	ct, ss, err := crypto.Encapsulate(pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("encapsulation failed: %w", err)
+	}
+	defer crypto.WipeBytes(ss)
+
+	nonce, ciphertext, err := crypto.EncryptAES256GCMWithAAD(string(data), ss, sealedAssociatedData(name))
+	if err != nil {
+		return nil, fmt.Errorf("encryption failed: %w", err)
+	}
+	return json.Marshal(sealedFile{
+		Version:    sealedFileVersion,
+		KEM:        ct,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	})
+}
+
+func openSealed(name string, data []byte, privKey kem.PrivateKey) ([]byte, error) {
+	var f sealedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse sealed file %s: %w", name, err)
+	}
+	if f.Version != sealedFileVersion {
+		return nil, fmt.Errorf("sealed file %s has unsupported version %d", name, f.Version)
+	}
+	ss, err := crypto.Decapsulate(f.KEM, privKey)
+	if err != nil {
+		return nil, fmt.Errorf("decapsulation failed: %w", err)
+	}
+	defer crypto.WipeBytes(ss)
+
+	plaintext, err := crypto.DecryptAES256GCMWithAAD(f.Nonce, f.Ciphertext, ss, sealedAssociatedData(name))
+	if err != nil {
+		return nil, fmt.Errorf("sealed file %s: %w", name, err)
+	}
+	return []byte(plaintext), nil
+}
+
+// resealedFiles opens every sealed file with privKey and seals it again to
+// pubKey, for RotateKeys to stage. It returns the paths and new contents.
+func resealedFiles(privKey kem.PrivateKey, pubKey kem.PublicKey) (paths []string, contents [][]byte, err error) {
+	dir, err := securestorage.GetSecureFilePath(sealedDirName)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), sealedFileSuffix) {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), sealedFileSuffix)
+		path := filepath.Join(dir, f.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		plaintext, err := openSealed(name, data, privKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		sealed, err := sealData(name, plaintext, pubKey)
+		crypto.WipeBytes(plaintext)
+		if err != nil {
+			return nil, nil, err
+		}
+		paths = append(paths, path)
+		contents = append(contents, sealed)
+	}
+	return paths, contents, nil
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestSealedFileSurvivesKeyRotation(t *testing.T) {
+	appState := newTestKeypair(t)
+	if err := CreateMasterPasswordProfile(appState, "first"); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := ReadSealedFile(appState, "pairings"); err != nil || data != nil {
+		t.Fatalf("missing file = %q, %v; want nil, nil", data, err)
+	}
+	secret := []byte(`{"key":"c2VjcmV0"}`)
+	if err := WriteSealedFile(appState, "pairings", secret); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := sealedFilePath("pairings")
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("c2VjcmV0")) {
+		t.Fatal("sealed file holds the plaintext")
+	}
+
+	if err := RotateKeys(appState); err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+	got, err := ReadSealedFile(appState, "pairings")
+	if err != nil || !bytes.Equal(got, secret) {
+		t.Fatalf("after rotation = %q, %v", got, err)
+	}
+
+	// The name is bound to the ciphertext.
+	other, _ := sealedFilePath("other")
+	raw, _ = os.ReadFile(path)
+	if err := os.WriteFile(other, raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSealedFile(appState, "other"); err == nil {
+		t.Fatal("sealed file opened under another name")
+	}
+
+	appState.ClearSensitiveState()
+	if _, err := ReadSealedFile(appState, "pairings"); err == nil {
+		t.Fatal("sealed file opened while locked")
+	}
+}
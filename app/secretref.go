@@ -0,0 +1,140 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"passquantum/core/model"
+	"passquantum/core/totp"
+)
+
+// SecretRefScheme prefixes a secret reference.
+const SecretRefScheme = "pq://"
+
+// SecretRef points at one field of one entry: pq://<vault>/<entry>/<field>.
+//
+// Entry is an entry name or ID as accepted by ResolveEntry and may itself
+// contain slashes; the first segment is always the vault and the last the
+// field. Segments are percent-decoded, so names with "/" or spaces can be
+// written as %2F and %20. An empty vault ("pq:///github.com/password")
+// means the caller's default vault. With only two segments the field is
+// omitted and the entry's main secret is used. TOTP entries also accept the
+// field "code", which yields the current one-time code.
+type SecretRef struct {
+	Vault string
+	Entry string
+	Field string
+}
+
+// IsSecretRef reports whether s is written as a secret reference.
+func IsSecretRef(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), SecretRefScheme)
+}
+
+// ParseSecretRef parses a pq:// reference.
+func ParseSecretRef(s string) (SecretRef, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), SecretRefScheme)
+	if !ok {
+		return SecretRef{}, fmt.Errorf("secret reference must start with %s", SecretRefScheme)
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) < 2 {
+		return SecretRef{}, fmt.Errorf("secret reference %q needs at least a vault and an entry", s)
+	}
+	for i, p := range parts {
+		decoded, err := url.PathUnescape(p)
+		if err != nil {
+			return SecretRef{}, fmt.Errorf("secret reference %q: %w", s, err)
+		}
+		parts[i] = decoded
+	}
+
+	ref := SecretRef{Vault: parts[0]}
+	if len(parts) == 2 {
+		ref.Entry = parts[1]
+	} else {
+		ref.Entry = strings.Join(parts[1:len(parts)-1], "/")
+		ref.Field = parts[len(parts)-1]
+	}
+	if strings.TrimSpace(ref.Entry) == "" {
+		return SecretRef{}, fmt.Errorf("secret reference %q has no entry", s)
+	}
+	return ref, nil
+}
+
+// String formats r back into reference form.
+func (r SecretRef) String() string {
+	s := SecretRefScheme + url.PathEscape(r.Vault) + "/" + url.PathEscape(r.Entry)
+	if r.Field != "" {
+		s += "/" + url.PathEscape(r.Field)
+	}
+	return s
+}
+
+// ResolveSecretRefs decrypts every reference in refs and returns the values
+// in the same order. Each vault is read once with the session's master
+// password; defaultVault stands in for references without a vault. The
+// plaintexts only ever live in memory.
+func ResolveSecretRefs(appState *AppState, refs []SecretRef, defaultVault string) ([]string, error) {
+	if appState == nil || !appState.IsUnlocked {
+		return nil, fmt.Errorf("app is locked")
+	}
+
+	vaults := make(map[string][]*model.VaultEntry)
+	values := make([]string, len(refs))
+	for i, ref := range refs {
+		vaultName := ref.Vault
+		if vaultName == "" {
+			vaultName = defaultVault
+		}
+		entries, ok := vaults[vaultName]
+		if !ok {
+			var err error
+			entries, err = readNamedVault(appState, vaultName)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", ref, err)
+			}
+			vaults[vaultName] = entries
+		}
+
+		entry, err := ResolveEntry(entries, ref.Entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		if entry.Deleted {
+			return nil, fmt.Errorf("%s: entry is in the trash", ref)
+		}
+		d, err := DescribeEntry(entry, appState.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+
+		if d.TOTP != nil && (ref.Field == "" || strings.EqualFold(ref.Field, "code")) {
+			code, _, err := totp.GenerateCode(d.TOTP)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", ref, err)
+			}
+			values[i] = code
+			continue
+		}
+		value, ok := d.Field(ref.Field)
+		if !ok {
+			return nil, fmt.Errorf("%s: entry has no field %q", ref, ref.Field)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func readNamedVault(appState *AppState, vaultName string) ([]*model.VaultEntry, error) {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+
+	vaultFile := GetVaultPath(vaultName)
+	if _, err := os.Stat(vaultFile); err != nil {
+		return nil, fmt.Errorf("vault '%s' does not exist", vaultName)
+	}
+	return ReadVault(vaultFile, appState.MasterPassword)
+}
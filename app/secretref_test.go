@@ -0,0 +1,81 @@
+package app
+
+import (
+	"testing"
+
+	"passquantum/core/crypto"
+	"passquantum/core/model"
+	"passquantum/core/totp"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	tests := []struct {
+		in   string
+		want SecretRef
+	}{
+		{"pq://Default/github.com/password", SecretRef{"Default", "github.com", "password"}},
+		{"pq://Work/github.com", SecretRef{"Work", "github.com", ""}},
+		{"pq:///db/api key", SecretRef{"", "db", "api key"}},
+		{"pq://Work/a/b/token", SecretRef{"Work", "a/b", "token"}},
+		{"pq://My%20Vault/x%2Fy/password", SecretRef{"My Vault", "x/y", "password"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSecretRef(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSecretRef(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"github.com/password", "pq://Default", "pq://Default//password"} {
+		if _, err := ParseSecretRef(bad); err == nil {
+			t.Errorf("ParseSecretRef(%q) should fail", bad)
+		}
+	}
+
+	ref := SecretRef{"My Vault", "x/y", "password"}
+	if back, _ := ParseSecretRef(ref.String()); back != ref {
+		t.Errorf("String round trip = %+v", back)
+	}
+}
+
+func TestResolveSecretRefs(t *testing.T) {
+	pub, priv, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("GenerateKeypair: %v", err)
+	}
+	payload := model.NewPasswordPayload("s3cret!")
+	payload.Fields = []model.CustomField{{Name: "API Key", Value: "ak-123", Hidden: true}}
+	pw, err := BuildPasswordEntry("db", "admin", payload, pub)
+	if err != nil {
+		t.Fatalf("BuildPasswordEntry: %v", err)
+	}
+	params := totp.DefaultParams()
+	params.Issuer, params.Secret = "GitHub", "JBSWY3DPEHPK3PXP"
+	otp, err := BuildTOTPEntry(params, pub)
+	if err != nil {
+		t.Fatalf("BuildTOTPEntry: %v", err)
+	}
+
+	appState := newTestVaultState(t, pw, otp)
+	appState.PrivateKey = priv
+
+	refs := []SecretRef{
+		{Entry: "db"},
+		{Vault: appState.CurrentVault, Entry: "DB", Field: "username"},
+		{Entry: "db", Field: "api key"},
+		{Entry: "GitHub", Field: "code"},
+	}
+	values, err := ResolveSecretRefs(appState, refs, appState.CurrentVault)
+	if err != nil {
+		t.Fatalf("ResolveSecretRefs: %v", err)
+	}
+	if values[0] != "s3cret!" || values[1] != "admin" || values[2] != "ak-123" || len(values[3]) != 6 {
+		t.Errorf("values = %q", values)
+	}
+
+	if _, err := ResolveSecretRefs(appState, []SecretRef{{Entry: "db", Field: "nope"}}, appState.CurrentVault); err == nil {
+		t.Error("unknown field should fail")
+	}
+	if _, err := ResolveSecretRefs(appState, []SecretRef{{Vault: "missing", Entry: "db"}}, ""); err == nil {
+		t.Error("missing vault should fail")
+	}
+}
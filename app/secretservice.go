@@ -0,0 +1,239 @@
+package app
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"time"
+
+	"passquantum/core/model"
+	"passquantum/internal/secretservice"
+)
+
+// secretBackend exposes the vaults to freedesktop Secret Service clients:
+// each vault is a collection and its password entries are the items.
+type secretBackend struct {
+	appState *AppState
+}
+
+// NewSecretServiceBackend returns the vault-backed store for the Secret
+// Service provider.
+func NewSecretServiceBackend(appState *AppState) secretservice.Backend {
+	return &secretBackend{appState: appState}
+}
+
+// secretAttributes returns the lookup attributes of a password entry.
+// Entries stored through the Secret Service keep the client's attributes;
+// all others answer to "service" and "username".
+func secretAttributes(e *model.VaultEntry) map[string]string {
+	if len(e.Attributes) > 0 {
+		return maps.Clone(e.Attributes)
+	}
+	attrs := map[string]string{"service": e.Service}
+	if e.Username != "" {
+		attrs["username"] = e.Username
+	}
+	return attrs
+}
+
+func (b *secretBackend) Locked() bool {
+	b.appState.Mu.Lock()
+	defer b.appState.Mu.Unlock()
+	return !b.appState.IsUnlocked
+}
+
+func (b *secretBackend) DefaultCollection() string {
+	b.appState.Mu.Lock()
+	defer b.appState.Mu.Unlock()
+	if !b.appState.IsUnlocked {
+		return ""
+	}
+	return b.appState.CurrentVault
+}
+
+func (b *secretBackend) Collections() []string {
+	return ListVaults()
+}
+
+func (b *secretBackend) Items(collection string) ([]secretservice.Item, error) {
+	var items []secretservice.Item
+	err := b.withVault(collection, func(entries []*model.VaultEntry) error {
+		for _, e := range EntriesByType(entries, model.EntryTypePassword) {
+			items = append(items, secretservice.Item{
+				ID:         e.ID,
+				Label:      e.Service,
+				Attributes: secretAttributes(e),
+				Created:    e.Created,
+				Modified:   e.Modified,
+			})
+		}
+		return nil
+	})
+	return items, err
+}
+
+func (b *secretBackend) Secret(collection string, id uint64) ([]byte, error) {
+	var secret []byte
+	current := false
+	err := b.withVault(collection, func(entries []*model.VaultEntry) error {
+		e, err := findSecretItem(entries, id)
+		if err != nil {
+			return err
+		}
+		payload, err := OpenPasswordPayload(e, b.appState.PrivateKey)
+		if err != nil {
+			return err
+		}
+		secret = []byte(payload.Password)
+		current = collection == b.appState.CurrentVault
+		return nil
+	})
+	if err == nil && current {
+		_ = MarkEntryUsed(b.appState, id)
+	}
+	return secret, err
+}
+
+func (b *secretBackend) CreateItem(collection, label string, attrs map[string]string, secret []byte, replace bool) (uint64, error) {
+	var id uint64
+	err := b.rewrite(collection, func(entries []*model.VaultEntry) ([]*model.VaultEntry, error) {
+		if replace {
+			for _, e := range EntriesByType(entries, model.EntryTypePassword) {
+				if !maps.Equal(secretAttributes(e), attrs) {
+					continue
+				}
+				if label != "" {
+					if err := b.relabel(e, label); err != nil {
+						return nil, err
+					}
+				}
+				id = e.ID
+				return entries, b.resealSecret(e, secret)
+			}
+		}
+
+		e := model.NewVaultEntry()
+		e.Type = model.EntryTypePassword
+		e.Service = label
+		if e.Service == "" {
+			e.Service = attrs["service"]
+		}
+		for _, key := range []string{"username", "user", "account"} {
+			if attrs[key] != "" {
+				e.Username = attrs[key]
+				break
+			}
+		}
+		if len(attrs) > 0 {
+			e.Attributes = maps.Clone(attrs)
+		}
+		if err := SealPasswordPayload(e, model.NewPasswordPayload(string(secret)), b.appState.PublicKey); err != nil {
+			return nil, err
+		}
+		id = e.ID
+		return append(entries, e), nil
+	})
+	return id, err
+}
+
+func (b *secretBackend) SetSecret(collection string, id uint64, secret []byte) error {
+	return b.rewrite(collection, func(entries []*model.VaultEntry) ([]*model.VaultEntry, error) {
+		e, err := findSecretItem(entries, id)
+		if err != nil {
+			return nil, err
+		}
+		return entries, b.resealSecret(e, secret)
+	})
+}
+
+func (b *secretBackend) UpdateItem(collection string, id uint64, label *string, attrs map[string]string) error {
+	return b.rewrite(collection, func(entries []*model.VaultEntry) ([]*model.VaultEntry, error) {
+		e, err := findSecretItem(entries, id)
+		if err != nil {
+			return nil, err
+		}
+		if label != nil {
+			if err := b.relabel(e, *label); err != nil {
+				return nil, err
+			}
+		}
+		if attrs != nil {
+			e.Attributes = nil
+			if len(attrs) > 0 {
+				e.Attributes = maps.Clone(attrs)
+			}
+		}
+		e.Touch(time.Now().UTC())
+		return entries, nil
+	})
+}
+
+func (b *secretBackend) DeleteItem(collection string, id uint64) error {
+	return b.rewrite(collection, func(entries []*model.VaultEntry) ([]*model.VaultEntry, error) {
+		e, err := findSecretItem(entries, id)
+		if err != nil {
+			return nil, err
+		}
+		e.MoveToTrash(time.Now().UTC())
+		return entries, nil
+	})
+}
+
+// relabel renames e, re-sealing it since the service name is bound to the
+// ciphertext. Caller holds appState.Mu.
+func (b *secretBackend) relabel(e *model.VaultEntry, label string) error {
+	return UpdateEntryMetadata(e, func(e *model.VaultEntry) {
+		e.Service = label
+	}, b.appState.PublicKey, b.appState.PrivateKey)
+}
+
+// resealSecret replaces the password in e's payload, keeping its other
+// fields and archiving the previous version. Caller holds appState.Mu.
+func (b *secretBackend) resealSecret(e *model.VaultEntry, secret []byte) error {
+	payload, err := OpenPasswordPayload(e, b.appState.PrivateKey)
+	if err != nil {
+		payload = model.NewPasswordPayload("")
+	}
+	payload.Password = string(secret)
+	return ReplacePasswordPayload(e, payload, b.appState.PublicKey, b.appState.PrivateKey)
+}
+
+// withVault reads the named vault under appState.Mu and passes its entries
+// to fn while the lock is held.
+func (b *secretBackend) withVault(collection string, fn func([]*model.VaultEntry) error) error {
+	b.appState.Mu.Lock()
+	defer b.appState.Mu.Unlock()
+
+	if !b.appState.IsUnlocked {
+		return secretservice.ErrLocked
+	}
+	vaultFile := GetVaultPath(collection)
+	if _, err := os.Stat(vaultFile); err != nil {
+		return fmt.Errorf("vault '%s' does not exist", collection)
+	}
+	entries, err := ReadVault(vaultFile, b.appState.MasterPassword)
+	if err != nil {
+		return err
+	}
+	return fn(entries)
+}
+
+// rewrite is withVault for changes: the list fn returns is written back.
+func (b *secretBackend) rewrite(collection string, fn func([]*model.VaultEntry) ([]*model.VaultEntry, error)) error {
+	return b.withVault(collection, func(entries []*model.VaultEntry) error {
+		updated, err := fn(entries)
+		if err != nil {
+			return err
+		}
+		return WriteVault(updated, GetVaultPath(collection), b.appState.MasterPassword)
+	})
+}
+
+func findSecretItem(entries []*model.VaultEntry, id uint64) (*model.VaultEntry, error) {
+	for _, e := range entries {
+		if e.ID == id && e.Type == model.EntryTypePassword && !e.Deleted {
+			return e, nil
+		}
+	}
+	return nil, secretservice.ErrNoSuchItem
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"passquantum/core/crypto"
+	"passquantum/core/model"
+	"passquantum/internal/secretservice"
+)
+
+func TestSecretServiceBackend(t *testing.T) {
+	pubKey, privKey, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("GenerateKeypair: %v", err)
+	}
+	login := makePasswordEntry("github.com", "alice")
+	if err := SealPasswordPayload(login, model.NewPasswordPayload("web-pass"), pubKey); err != nil {
+		t.Fatal(err)
+	}
+	appState := newTestVaultState(t, login)
+	appState.PublicKey, appState.PrivateKey = pubKey, privKey
+	backend := NewSecretServiceBackend(appState)
+	vault := appState.CurrentVault
+
+	if got := backend.DefaultCollection(); got != vault {
+		t.Errorf("DefaultCollection = %q", got)
+	}
+
+	// Entries saved in the app answer to service/username.
+	items, err := backend.Items(vault)
+	if err != nil || len(items) != 1 || items[0].Attributes["service"] != "github.com" || items[0].Attributes["username"] != "alice" {
+		t.Fatalf("Items = %+v, %v", items, err)
+	}
+
+	attrs := map[string]string{"xdg:schema": "org.gnome.keyring.NetworkPassword", "server": "imap.example.org", "user": "bob"}
+	id, err := backend.CreateItem(vault, "IMAP", attrs, []byte("first"), true)
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	again, err := backend.CreateItem(vault, "IMAP", attrs, []byte("second"), true)
+	if err != nil || again != id {
+		t.Fatalf("replace should reuse item %x, got %x, %v", id, again, err)
+	}
+	secret, err := backend.Secret(vault, id)
+	if err != nil || string(secret) != "second" {
+		t.Errorf("Secret = %q, %v", secret, err)
+	}
+
+	entries := readTestVault(t, appState)
+	if len(entries) != 2 {
+		t.Fatalf("vault has %d entries", len(entries))
+	}
+	stored := entries[1]
+	if stored.Service != "IMAP" || stored.Username != "bob" || stored.Attributes["server"] != "imap.example.org" {
+		t.Errorf("stored entry = %q / %q / %v", stored.Service, stored.Username, stored.Attributes)
+	}
+	if len(stored.PasswordHistory) != 1 {
+		t.Errorf("replacing a secret should archive the old one, history = %d", len(stored.PasswordHistory))
+	}
+
+	label := "Mail"
+	if err := backend.UpdateItem(vault, id, &label, map[string]string{"server": "imap.example.org"}); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	if err := backend.DeleteItem(vault, id); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	entries = readTestVault(t, appState)
+	if !entries[1].Deleted || entries[1].Service != "Mail" || len(entries[1].Attributes) != 1 {
+		t.Errorf("entry after update+delete = %+v", entries[1])
+	}
+	if _, err := backend.Secret(vault, id); !errors.Is(err, secretservice.ErrNoSuchItem) {
+		t.Errorf("trashed item should be gone, got %v", err)
+	}
+
+	appState.ClearSensitiveState()
+	if !backend.Locked() || backend.DefaultCollection() != "" {
+		t.Error("backend should report locked after ClearSensitiveState")
+	}
+	if _, err := backend.Items(vault); !errors.Is(err, secretservice.ErrLocked) {
+		t.Errorf("Items while locked = %v", err)
+	}
+}
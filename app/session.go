@@ -0,0 +1,114 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cloudflare/circl/kem"
+
+	"passquantum/core/crypto"
+	"passquantum/core/storage"
+	securestorage "passquantum/internal/storage"
+)
+
+// LoadStoredKeypair loads the vault keypair from the secure key directory.
+// Unlike the desktop startup path it never generates a new pair: a headless
+// caller that finds no keys has nothing it could decrypt. A private key
+// wrapped under the master password comes back nil; unlocking loads it.
+func LoadStoredKeypair() (kem.PublicKey, kem.PrivateKey, error) {
+	pubKeyPath, err := securestorage.GetSecureFilePath(PubKeyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolve public key path: %w", err)
+	}
+	privKeyPath, err := securestorage.GetSecureFilePath(PrivKeyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolve private key path: %w", err)
+	}
+	pubKey, privKey, err := crypto.LoadKeypair(pubKeyPath, privKeyPath)
+	if err != nil && !errors.Is(err, crypto.ErrPrivateKeyWrapped) {
+		return nil, nil, fmt.Errorf("load keypair (has the desktop app been set up?): %w", err)
+	}
+	return pubKey, privKey, nil
+}
+
+// LoadStoredPrivateKey reads private.key, unwrapping it with masterPassword
+// if it is wrapped.
+func LoadStoredPrivateKey(masterPassword string) (kem.PrivateKey, error) {
+	privKeyPath, err := securestorage.GetSecureFilePath(PrivKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolve private key path: %w", err)
+	}
+	return crypto.LoadPrivateKey(privKeyPath, masterPassword)
+}
+
+// wrapStoredPrivateKey wraps a private.key that is still stored raw, or
+// wraps it again when its Argon2id cost is below the current policy. Keys
+// written before wrapping existed are converted this way on first unlock.
+func wrapStoredPrivateKey(privateKey kem.PrivateKey, masterPassword string) error {
+	privKeyPath, err := securestorage.GetSecureFilePath(PrivKeyPath)
+	if err != nil {
+		return fmt.Errorf("resolve private key path: %w", err)
+	}
+	data, err := os.ReadFile(privKeyPath)
+	if err != nil {
+		return err
+	}
+	if crypto.IsWrappedPrivateKey(data) && !crypto.WrappedKeyKDFOutdated(data) {
+		return nil
+	}
+	return crypto.SaveWrappedPrivateKey(privateKey, privKeyPath, masterPassword)
+}
+
+// NewHeadlessSession loads the keypair and unlocks the application with the
+// master password, verifying it against the stored security profile, for
+// callers without a UI. vaultName, when not empty, is opened as well. Like
+// the desktop app at startup, it first finishes a multi-file write that a
+// crash interrupted.
+func NewHeadlessSession(masterPassword, vaultName string) (*AppState, error) {
+	if recovered, err := securestorage.RecoverJournal(); err != nil {
+		log.Printf("[Vault] WARNING: failed to recover interrupted vault write: %v", err)
+	} else if recovered {
+		log.Printf("[Vault] completed a vault write interrupted by the last shutdown")
+	}
+
+	pubKey, privKey, err := LoadStoredKeypair()
+	if err != nil {
+		return nil, err
+	}
+	appState := &AppState{PublicKey: pubKey, PrivateKey: privKey}
+
+	if !storage.AppSecurityProfileExists(appSecurityMetadataPath) {
+		return nil, fmt.Errorf("no master password has been set up yet")
+	}
+	if err := unlockAppSession(appState, masterPassword); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(vaultName) == "" {
+		return appState, nil
+	}
+	if err := openVaultWithUnlockedSession(appState, vaultName); err != nil {
+		appState.ClearSensitiveState()
+		return nil, err
+	}
+	return appState, nil
+}
+
+// DeleteVault removes a vault file. It refuses to delete the vault that is
+// currently open in appState.
+func DeleteVault(appState *AppState, vaultName string) error {
+	vaultName = strings.TrimSpace(vaultName)
+	if vaultName == "" {
+		return fmt.Errorf("vault name cannot be empty")
+	}
+	if appState != nil && appState.CurrentVault == vaultName {
+		return fmt.Errorf("vault '%s' is open; close it before deleting", vaultName)
+	}
+	vaultFile := GetVaultPath(vaultName)
+	if _, err := os.Stat(vaultFile); err != nil {
+		return fmt.Errorf("vault '%s' does not exist", vaultName)
+	}
+	return os.Remove(vaultFile)
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"fmt"
+
+	"passquantum/core/model"
+	"passquantum/core/storage"
+)
+
+// ListVaultSnapshots returns the automatic snapshots of the open vault,
+// newest first. Every WriteVault keeps the file it replaces.
+func ListVaultSnapshots(appState *AppState) ([]storage.Snapshot, error) {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+
+	if !appState.IsUnlocked || appState.CurrentVault == "" {
+		return nil, fmt.Errorf("vault is locked")
+	}
+	return storage.ListSnapshots(GetVaultPath(appState.CurrentVault))
+}
+
+// OpenVaultSnapshot decrypts a snapshot with the current master password.
+func OpenVaultSnapshot(appState *AppState, snap storage.Snapshot) ([]*model.VaultEntry, error) {
+	appState.Mu.Lock()
+	password := appState.MasterPassword
+	unlocked := appState.IsUnlocked
+	appState.Mu.Unlock()
+
+	if !unlocked {
+		return nil, fmt.Errorf("vault is locked")
+	}
+	return storage.ReadSnapshot(snap, password)
+}
+
+// SnapshotCurrentVault takes a snapshot of the open vault now, outside the
+// automatic ones taken on every write.
+func SnapshotCurrentVault(appState *AppState) error {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+
+	if !appState.IsUnlocked || appState.CurrentVault == "" {
+		return fmt.Errorf("vault is locked")
+	}
+	return storage.SnapshotVaultFile(GetVaultPath(appState.CurrentVault))
+}
+
+// RestoreVaultSnapshot replaces the open vault's entries with the
+// snapshot's. The vault being replaced is itself snapshotted, so a restore
+// can be undone the same way. Entries sealed before a key rotation are
+// re-sealed for the current key.
+func RestoreVaultSnapshot(appState *AppState, snap storage.Snapshot) error {
+	restored, err := OpenVaultSnapshot(appState, snap)
+	if err != nil {
+		return err
+	}
+	if err := adoptSnapshotEntries(appState, restored); err != nil {
+		return err
+	}
+	return rewriteEntries(appState, func([]*model.VaultEntry) ([]*model.VaultEntry, error) {
+		return restored, nil
+	})
+}
+
+// RestoreSnapshotEntries copies the snapshot's entries with the given IDs
+// into the open vault, replacing the current version of each entry that
+// still exists and adding back those that were deleted. It returns how
+// many entries were restored.
+func RestoreSnapshotEntries(appState *AppState, snap storage.Snapshot, ids []uint64) (int, error) {
+	snapshotEntries, err := OpenVaultSnapshot(appState, snap)
+	if err != nil {
+		return 0, err
+	}
+	wanted := make(map[uint64]*model.VaultEntry, len(ids))
+	for _, id := range ids {
+		wanted[id] = nil
+	}
+	var picked []*model.VaultEntry
+	for _, e := range snapshotEntries {
+		if _, ok := wanted[e.ID]; ok {
+			wanted[e.ID] = e
+			picked = append(picked, e)
+		}
+	}
+	if err := adoptSnapshotEntries(appState, picked); err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	err = rewriteEntries(appState, func(entries []*model.VaultEntry) ([]*model.VaultEntry, error) {
+		for i, e := range entries {
+			if old := wanted[e.ID]; old != nil {
+				entries[i] = old
+				delete(wanted, e.ID)
+				restored++
+			}
+		}
+		for _, id := range ids {
+			if old := wanted[id]; old != nil {
+				entries = append(entries, old)
+				delete(wanted, id)
+				restored++
+			}
+		}
+		if restored == 0 {
+			return nil, nil
+		}
+		return entries, nil
+	})
+	return restored, err
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"testing"
+
+	"passquantum/core/model"
+)
+
+func TestRestoreSnapshotEntries(t *testing.T) {
+	keep := makePasswordEntry("github.com", "alice")
+	gone := makePasswordEntry("gitlab.com", "bob")
+	gone.ID = 2
+	appState := newTestVaultState(t, keep, gone)
+
+	// Edit one entry and drop the other; each write snapshots the vault.
+	err := rewriteEntries(appState, func(entries []*model.VaultEntry) ([]*model.VaultEntry, error) {
+		entries[0].Username = "mallory"
+		return entries[:1], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := ListVaultSnapshots(appState)
+	if err != nil || len(snapshots) == 0 {
+		t.Fatalf("ListVaultSnapshots = %d, %v", len(snapshots), err)
+	}
+	n, err := RestoreSnapshotEntries(appState, snapshots[0], []uint64{2})
+	if err != nil || n != 1 {
+		t.Fatalf("RestoreSnapshotEntries = %d, %v", n, err)
+	}
+	entries := readTestVault(t, appState)
+	if len(entries) != 2 || entries[0].Username != "mallory" || entries[1].Service != "gitlab.com" {
+		t.Fatalf("after entry restore: %d entries", len(entries))
+	}
+
+	snapshots, _ = ListVaultSnapshots(appState)
+	if err := RestoreVaultSnapshot(appState, snapshots[len(snapshots)-1]); err != nil {
+		t.Fatal(err)
+	}
+	entries = readTestVault(t, appState)
+	if len(entries) != 2 || entries[0].Username != "alice" {
+		t.Errorf("after whole-vault restore: %+v", entries[0])
+	}
+}
@@ -0,0 +1,252 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+	"golang.org/x/crypto/ssh"
+
+	"passquantum/core/model"
+	"passquantum/internal/sshagent"
+)
+
+// SSHKeyServicePrefix marks the service name of SSH key entries.
+const SSHKeyServicePrefix = "SSH:"
+
+// PrepareSSHKey checks that privateKey parses (with passphrase, when it is
+// encrypted) and returns a payload with the public key filled in, together
+// with the key's SHA256 fingerprint.
+func PrepareSSHKey(privateKey, passphrase, comment string) (*model.SSHKeyPayload, string, error) {
+	privateKey = strings.TrimSpace(privateKey) + "\n"
+	signer, err := parseSSHSigner(privateKey, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	pub := signer.PublicKey()
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	comment = strings.TrimSpace(comment)
+	if comment != "" {
+		authorized += " " + comment
+	}
+	return &model.SSHKeyPayload{
+		PrivateKey: privateKey,
+		PublicKey:  authorized,
+		Comment:    comment,
+		Passphrase: passphrase,
+	}, ssh.FingerprintSHA256(pub), nil
+}
+
+// BuildSSHKeyEntry creates a sealed SSH key entry named name from a payload
+// produced by PrepareSSHKey. The entry's username is the key fingerprint.
+func BuildSSHKeyEntry(name string, payload *model.SSHKeyPayload, pubKey kem.PublicKey) (*model.VaultEntry, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("SSH key name cannot be empty")
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(payload.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH public key: %w", err)
+	}
+	entry := model.NewVaultEntry()
+	entry.Type = model.EntryTypeSSHKey
+	entry.Service = SSHKeyServicePrefix + name
+	entry.Username = ssh.FingerprintSHA256(pub)
+	if err := SealSSHKeyPayload(entry, payload, pubKey); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// SealSSHKeyPayload encrypts payload into entry.
+func SealSSHKeyPayload(entry *model.VaultEntry, payload *model.SSHKeyPayload, pubKey kem.PublicKey) error {
+	data, err := payload.Marshal()
+	if err != nil {
+		return fmt.Errorf("encode SSH key: %w", err)
+	}
+	return SealEntrySecret(entry, string(data), pubKey)
+}
+
+// OpenSSHKeyPayload decrypts an SSH key entry.
+func OpenSSHKeyPayload(entry *model.VaultEntry, privKey kem.PrivateKey) (*model.SSHKeyPayload, error) {
+	plaintext, err := OpenEntrySecret(entry, privKey)
+	if err != nil {
+		return nil, err
+	}
+	return model.ParseSSHKeyPayload(plaintext)
+}
+
+// SSHKeySigner parses the private key held by payload.
+func SSHKeySigner(payload *model.SSHKeyPayload) (ssh.Signer, error) {
+	return parseSSHSigner(payload.PrivateKey, payload.Passphrase)
+}
+
+func parseSSHSigner(privateKey, passphrase string) (ssh.Signer, error) {
+	if passphrase != "" {
+		signer, err := ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("parse SSH private key: %w", err)
+		}
+		return signer, nil
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("SSH private key is passphrase-protected; enter its passphrase")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse SSH private key: %w", err)
+	}
+	return signer, nil
+}
+
+// sshUsageInterval is how often a key's use by the agent is written to
+// LastUsed. Recording every signature would rewrite the vault on each git
+// fetch or ssh hop.
+const sshUsageInterval = time.Hour
+
+// sshKeySource serves the SSH key entries of the currently open vault to
+// the agent. The public identities are decoded once per vault file and kept
+// for the session; Signer decrypts only the key it is asked for.
+type sshKeySource struct {
+	appState *AppState
+
+	mu       sync.Mutex
+	cacheKey sshKeyCacheKey
+	cached   []sshKeyEntry
+	marked   map[uint64]time.Time
+}
+
+// sshKeyCacheKey identifies the vault file the cached identities were read
+// from; any write to it (a new key, a rotation) changes the key.
+type sshKeyCacheKey struct {
+	vault   string
+	modTime time.Time
+	size    int64
+}
+
+// NewSSHKeySource returns the sshagent.KeySource backed by appState.
+func NewSSHKeySource(appState *AppState) sshagent.KeySource {
+	return &sshKeySource{appState: appState, marked: make(map[uint64]time.Time)}
+}
+
+// sshKeyEntry is the public half of an SSH key entry. The entry stays
+// sealed; its private key is only opened by Signer.
+type sshKeyEntry struct {
+	entry   *model.VaultEntry
+	pub     ssh.PublicKey
+	comment string
+	confirm bool
+}
+
+// keys returns the open vault's SSH identities, reading and decrypting the
+// vault only when the file changed since the last call. It also returns the
+// private key of the session, for Signer.
+func (s *sshKeySource) keys() ([]sshKeyEntry, kem.PrivateKey, error) {
+	appState := s.appState
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !appState.IsUnlocked || appState.CurrentVault == "" {
+		s.cached, s.cacheKey = nil, sshKeyCacheKey{}
+		return nil, nil, fmt.Errorf("vault is locked")
+	}
+	vaultPath := GetVaultPath(appState.CurrentVault)
+	key := sshKeyCacheKey{vault: appState.CurrentVault}
+	if info, err := os.Stat(vaultPath); err == nil {
+		key.modTime, key.size = info.ModTime(), info.Size()
+	}
+	if s.cached != nil && key == s.cacheKey {
+		return s.cached, appState.PrivateKey, nil
+	}
+
+	entries, err := ReadVault(vaultPath, appState.MasterPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := []sshKeyEntry{}
+	for _, e := range EntriesByType(entries, model.EntryTypeSSHKey) {
+		payload, err := OpenSSHKeyPayload(e, appState.PrivateKey)
+		if err != nil {
+			log.Printf("[SSHAgent] skipping entry %s: %v", FormatEntryID(e.ID), err)
+			continue
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(payload.PublicKey))
+		if err != nil {
+			log.Printf("[SSHAgent] skipping entry %s: %v", FormatEntryID(e.ID), err)
+			continue
+		}
+		comment := payload.Comment
+		if comment == "" {
+			comment = EntryName(e)
+		}
+		keys = append(keys, sshKeyEntry{entry: e, pub: pub, comment: comment, confirm: payload.Confirm})
+	}
+	s.cached, s.cacheKey = keys, key
+	return keys, appState.PrivateKey, nil
+}
+
+func (s *sshKeySource) Identities() ([]sshagent.Identity, error) {
+	keys, _, err := s.keys()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]sshagent.Identity, len(keys))
+	for i, k := range keys {
+		ids[i] = sshagent.Identity{
+			EntryID:   k.entry.ID,
+			Name:      EntryName(k.entry),
+			PublicKey: k.pub,
+			Comment:   k.comment,
+			Confirm:   k.confirm,
+		}
+	}
+	return ids, nil
+}
+
+func (s *sshKeySource) Signer(pub ssh.PublicKey) (ssh.Signer, error) {
+	keys, privKey, err := s.keys()
+	if err != nil {
+		return nil, err
+	}
+	want := pub.Marshal()
+	for _, k := range keys {
+		if !bytes.Equal(k.pub.Marshal(), want) {
+			continue
+		}
+		payload, err := OpenSSHKeyPayload(k.entry, privKey)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := SSHKeySigner(payload)
+		if err != nil {
+			return nil, err
+		}
+		s.markUsed(k.entry.ID)
+		return signer, nil
+	}
+	return nil, fmt.Errorf("key not found")
+}
+
+// markUsed records the key's use, at most once per sshUsageInterval.
+func (s *sshKeySource) markUsed(entryID uint64) {
+	now := time.Now()
+	s.mu.Lock()
+	if last, ok := s.marked[entryID]; ok && now.Sub(last) < sshUsageInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.marked[entryID] = now
+	s.mu.Unlock()
+
+	if err := MarkEntryUsed(s.appState, entryID); err != nil {
+		log.Printf("[SSHAgent] could not record key use: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"passquantum/core/crypto"
+	"passquantum/internal/sshagent"
+)
+
+func testSSHPrivateKey(t *testing.T, passphrase string) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(block))
+}
+
+func TestPrepareSSHKey(t *testing.T) {
+	if _, _, err := PrepareSSHKey("not a key", "", ""); err == nil {
+		t.Error("garbage should not parse")
+	}
+
+	protected := testSSHPrivateKey(t, "hunter2")
+	if _, _, err := PrepareSSHKey(protected, "", ""); err == nil {
+		t.Error("protected key without passphrase should fail")
+	}
+	payload, fingerprint, err := PrepareSSHKey(protected, "hunter2", "alice@laptop")
+	if err != nil {
+		t.Fatalf("PrepareSSHKey: %v", err)
+	}
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(payload.PublicKey))
+	if err != nil || comment != "alice@laptop" || ssh.FingerprintSHA256(pub) != fingerprint {
+		t.Errorf("public key = %q (%v)", payload.PublicKey, err)
+	}
+	if _, err := SSHKeySigner(payload); err != nil {
+		t.Errorf("SSHKeySigner: %v", err)
+	}
+}
+
+func TestSSHAgentServesVaultKeys(t *testing.T) {
+	pubKey, privKey, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("GenerateKeypair: %v", err)
+	}
+	payload, fingerprint, err := PrepareSSHKey(testSSHPrivateKey(t, ""), "", "")
+	if err != nil {
+		t.Fatalf("PrepareSSHKey: %v", err)
+	}
+	entry, err := BuildSSHKeyEntry("laptop", payload, pubKey)
+	if err != nil {
+		t.Fatalf("BuildSSHKeyEntry: %v", err)
+	}
+	if entry.Username != fingerprint || EntryName(entry) != "laptop" {
+		t.Errorf("entry = %q / %q", entry.Service, entry.Username)
+	}
+
+	appState := newTestVaultState(t, entry)
+	appState.PublicKey, appState.PrivateKey = pubKey, privKey
+	appState.SSHAgent = sshagent.NewServer(NewSSHKeySource(appState), filepath.Join(t.TempDir(), "agent.sock"))
+	if err := appState.SSHAgent.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	conn, err := net.Dial("unix", appState.SSHAgent.SocketPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := agent.NewClient(conn)
+
+	keys, err := client.List()
+	if err != nil || len(keys) != 1 || keys[0].Comment != "laptop" {
+		t.Fatalf("List = %v, %v", keys, err)
+	}
+	sig, err := client.Sign(keys[0], []byte("challenge"))
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if err := keys[0].Verify([]byte("challenge"), sig); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if readTestVault(t, appState)[0].LastUsed.IsZero() {
+		t.Error("signing should mark the key used")
+	}
+
+	appState.ClearSensitiveState()
+	if appState.SSHAgent.Running() {
+		t.Error("ClearSensitiveState should stop the agent")
+	}
+}
+
+func TestSSHKeySourceCachesIdentities(t *testing.T) {
+	pubKey, privKey, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _, err := PrepareSSHKey(testSSHPrivateKey(t, ""), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := BuildSSHKeyEntry("laptop", payload, pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appState := newTestVaultState(t, entry)
+	appState.PublicKey, appState.PrivateKey = pubKey, privKey
+	source := NewSSHKeySource(appState)
+
+	ids, err := source.Identities()
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("Identities = %v, %v", ids, err)
+	}
+	if _, err := source.Signer(ids[0].PublicKey); err != nil {
+		t.Fatalf("Signer: %v", err)
+	}
+	if readTestVault(t, appState)[0].LastUsed.IsZero() {
+		t.Fatal("first signature should mark the key used")
+	}
+
+	// Recording the use rewrote the file; the next call reads it again.
+	if _, err := source.Identities(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The vault is not read again while its file is unchanged: with the
+	// password swapped out, only the cache can answer.
+	password := appState.MasterPassword
+	appState.MasterPassword = "not-the-password"
+	if _, err := source.Identities(); err != nil {
+		t.Fatalf("Identities from the cache: %v", err)
+	}
+	// A second signature within sshUsageInterval does not rewrite the vault.
+	if _, err := source.Signer(ids[0].PublicKey); err != nil {
+		t.Fatalf("Signer from the cache: %v", err)
+	}
+	appState.MasterPassword = password
+
+	// Adding a key changes the file, so it is picked up.
+	second, _, err := PrepareSSHKey(testSSHPrivateKey(t, ""), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondEntry, err := BuildSSHKeyEntry("desktop", second, pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddEntry(appState, secondEntry); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := source.Identities(); err != nil || len(ids) != 2 {
+		t.Fatalf("Identities after adding a key = %d, %v", len(ids), err)
+	}
+}
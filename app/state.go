@@ -2,12 +2,16 @@ package app
 
 import (
 	"sync"
+	"time"
 
-	"github.com/cloudflare/circl/kem/kyber/kyber768"
+	"github.com/cloudflare/circl/kem"
 
 	"passquantum/bridge"
 	"passquantum/core/crypto"
 	"passquantum/core/filevault"
+	"passquantum/internal/lockpolicy"
+	"passquantum/internal/secretservice"
+	"passquantum/internal/sshagent"
 )
 
 const (
@@ -19,8 +23,8 @@ const (
 // Fields are intentionally unexported; screens access them through
 // the exported methods and helpers in this package.
 type AppState struct {
-	PublicKey              *kyber768.PublicKey
-	PrivateKey             *kyber768.PrivateKey
+	PublicKey              kem.PublicKey
+	PrivateKey             kem.PrivateKey
 	MasterPassword         string
 	SessionEncryptionKey   []byte
 	SessionVerificationKey []byte
@@ -33,6 +37,19 @@ type AppState struct {
 	FaceGuard              *bridge.FaceGuard
 	FileStore              *filevault.Store
 	TempTracker            *filevault.TempTracker
+	// SSHAgent serves the vault's SSH keys while a vault is open. The UI
+	// starts it; ClearSensitiveState stops it.
+	SSHAgent *sshagent.Server
+	// SecretService, when running, is told whenever the collections lock
+	// or unlock with the app.
+	SecretService *secretservice.Service
+	// TrashRetention is how long trashed entries and files are kept before
+	// they are purged when a vault is opened. Zero keeps them until the
+	// trash is emptied by hand.
+	TrashRetention time.Duration
+	// LockMonitor applies the auto-lock policy. Unlocks and locks are
+	// reported to it here; the UI feeds it input activity.
+	LockMonitor *lockpolicy.Monitor
 	// LockApp is called from any goroutine to lock the app immediately;
 	// it clears sensitive state and returns the user to the login screen.
 	LockApp func()
@@ -51,6 +68,9 @@ func (appState *AppState) StoreUnlockedSession(masterPassword string, profile *c
 	appState.IsUnlocked = true
 	crypto.WipeBytes(sessionEncryptionKey)
 	crypto.WipeBytes(sessionVerificationKey)
+	if appState.LockMonitor != nil {
+		appState.LockMonitor.SessionStarted()
+	}
 }
 
 func (appState *AppState) StoreCurrentVaultState(vaultName string) {
@@ -59,6 +79,9 @@ func (appState *AppState) StoreCurrentVaultState(vaultName string) {
 
 	appState.CurrentVault = vaultName
 	appState.IsUnlocked = true
+	if appState.SecretService != nil {
+		appState.SecretService.NotifyLockChanged(false)
+	}
 }
 
 func (appState *AppState) ClearSensitiveState() {
@@ -72,14 +95,25 @@ func (appState *AppState) ClearSensitiveState() {
 	if appState.TempTracker != nil {
 		appState.TempTracker.CleanupAll()
 	}
+	if appState.SSHAgent != nil {
+		appState.SSHAgent.Stop()
+	}
 
 	crypto.WipeBytes(appState.SessionEncryptionKey)
 	crypto.WipeBytes(appState.SessionVerificationKey)
 	appState.SessionEncryptionKey = nil
 	appState.SessionVerificationKey = nil
+	// private.key is wrapped on disk; unlocking opens it again.
+	appState.PrivateKey = nil
 	appState.MasterPassword = ""
 	appState.CurrentVault = ""
 	appState.IsUnlocked = false
 	appState.SecurityProfile = nil
 	appState.StartupWarning = ""
+	if appState.SecretService != nil {
+		appState.SecretService.NotifyLockChanged(true)
+	}
+	if appState.LockMonitor != nil {
+		appState.LockMonitor.SessionEnded()
+	}
 }
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"passquantum/core/model"
+	"passquantum/core/storage"
+)
+
+// DefaultTrashRetention is how long trashed items are kept when the user
+// has not chosen a purge period.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// LiveEntries returns the entries that are not in the trash.
+func LiveEntries(entries []*model.VaultEntry) []*model.VaultEntry {
+	var out []*model.VaultEntry
+	for _, e := range entries {
+		if e != nil && !e.Deleted {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// TrashedEntries returns the entries in the trash, most recently deleted
+// first.
+func TrashedEntries(entries []*model.VaultEntry) []*model.VaultEntry {
+	var out []*model.VaultEntry
+	for _, e := range entries {
+		if e != nil && e.Deleted {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].DeletedAt.After(out[j].DeletedAt)
+	})
+	return out
+}
+
+// TrashEntry moves the entry with the given ID to the trash.
+func TrashEntry(appState *AppState, entryID uint64) error {
+	return UpdateEntry(appState, entryID, func(e *model.VaultEntry) error {
+		e.MoveToTrash(time.Now().UTC())
+		return nil
+	})
+}
+
+// RestoreEntry takes the entry with the given ID back out of the trash.
+func RestoreEntry(appState *AppState, entryID uint64) error {
+	return UpdateEntry(appState, entryID, func(e *model.VaultEntry) error {
+		if !e.Deleted {
+			return fmt.Errorf("entry is not in the trash")
+		}
+		e.RestoreFromTrash(time.Now().UTC())
+		return nil
+	})
+}
+
+// PurgeEntry permanently removes a trashed entry from the vault and its
+// snapshots. Entries that are not in the trash are refused so a stray call
+// cannot skip it.
+func PurgeEntry(appState *AppState, entryID uint64) error {
+	return purgeEntries(appState, func(entries []*model.VaultEntry) ([]*model.VaultEntry, error) {
+		for i, e := range entries {
+			if e.ID != entryID {
+				continue
+			}
+			if !e.Deleted {
+				return nil, fmt.Errorf("entry is not in the trash")
+			}
+			return append(entries[:i:i], entries[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("entry %d not found", entryID)
+	})
+}
+
+// EmptyTrash permanently removes every trashed entry and returns how many
+// were removed.
+func EmptyTrash(appState *AppState) (int, error) {
+	var purged int
+	err := purgeEntries(appState, func(entries []*model.VaultEntry) ([]*model.VaultEntry, error) {
+		kept := append([]*model.VaultEntry{}, LiveEntries(entries)...)
+		purged = len(entries) - len(kept)
+		if purged == 0 {
+			return nil, nil
+		}
+		return kept, nil
+	})
+	return purged, err
+}
+
+// PurgeExpiredTrash permanently removes entries that have been in the trash
+// for at least appState.TrashRetention at now. It is run when a vault is
+// opened; a zero retention disables it.
+func PurgeExpiredTrash(appState *AppState, now time.Time) (int, error) {
+	if appState.TrashRetention <= 0 {
+		return 0, nil
+	}
+	var purged int
+	err := purgeEntries(appState, func(entries []*model.VaultEntry) ([]*model.VaultEntry, error) {
+		kept := make([]*model.VaultEntry, 0, len(entries))
+		for _, e := range entries {
+			if e.TrashExpired(now, appState.TrashRetention) {
+				purged++
+				continue
+			}
+			kept = append(kept, e)
+		}
+		if purged == 0 {
+			return nil, nil
+		}
+		return kept, nil
+	})
+	return purged, err
+}
+
+// rewriteEntries reads the current vault, lets fn produce the new entry
+// list and writes it back under appState.Mu, upgrading entries still on an
+// older crypto version. A nil list with a nil error leaves the vault
+// untouched.
+func rewriteEntries(appState *AppState, fn func([]*model.VaultEntry) ([]*model.VaultEntry, error)) error {
+	return rewriteVault(appState, false, fn)
+}
+
+// purgeEntries is rewriteEntries for permanent deletes: the entries fn
+// leaves out are dropped from the vault's snapshots as well, and no
+// snapshot is taken of the vault being replaced (see
+// storage.PurgeVaultEntries).
+func purgeEntries(appState *AppState, fn func([]*model.VaultEntry) ([]*model.VaultEntry, error)) error {
+	return rewriteVault(appState, true, fn)
+}
+
+func rewriteVault(appState *AppState, purge bool, fn func([]*model.VaultEntry) ([]*model.VaultEntry, error)) error {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+
+	if !appState.IsUnlocked || appState.CurrentVault == "" {
+		return fmt.Errorf("vault is locked")
+	}
+	vaultFile := GetVaultPath(appState.CurrentVault)
+	entries, err := ReadVault(vaultFile, appState.MasterPassword)
+	if err != nil {
+		return err
+	}
+	ids := make([]uint64, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	updated, err := fn(entries)
+	if err != nil || updated == nil {
+		return err
+	}
+	upgradeEntryCrypto(updated, appState.PublicKey, appState.PrivateKey)
+	if !purge {
+		return WriteVault(updated, vaultFile, appState.MasterPassword)
+	}
+
+	kept := make(map[uint64]bool, len(updated))
+	for _, e := range updated {
+		kept[e.ID] = true
+	}
+	var purged []uint64
+	for _, id := range ids {
+		if !kept[id] {
+			purged = append(purged, id)
+		}
+	}
+	return storage.PurgeVaultEntries(updated, purged, vaultFile, appState.MasterPassword)
+}
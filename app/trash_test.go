@@ -0,0 +1,122 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"passquantum/core/model"
+)
+
+// newTestVaultState points the vault directory at a temp dir and returns an
+// unlocked AppState whose current vault holds entries.
+func newTestVaultState(t *testing.T, entries ...*model.VaultEntry) *AppState {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	appState := &AppState{
+		MasterPassword: "trash-test-pass",
+		IsUnlocked:     true,
+		CurrentVault:   "trash-test",
+	}
+	if err := WriteVault(entries, GetVaultPath(appState.CurrentVault), appState.MasterPassword); err != nil {
+		t.Fatalf("WriteVault: %v", err)
+	}
+	return appState
+}
+
+func readTestVault(t *testing.T, appState *AppState) []*model.VaultEntry {
+	t.Helper()
+	entries, err := ReadVault(GetVaultPath(appState.CurrentVault), appState.MasterPassword)
+	if err != nil {
+		t.Fatalf("ReadVault: %v", err)
+	}
+	return entries
+}
+
+func TestTrashRestoreAndPurgeEntry(t *testing.T) {
+	keep := makePasswordEntry("github.com", "alice")
+	keep.ID = 1
+	gone := makePasswordEntry("gitlab.com", "alice")
+	gone.ID = 2
+	appState := newTestVaultState(t, keep, gone)
+
+	if err := PurgeEntry(appState, 2); err == nil {
+		t.Fatal("PurgeEntry should refuse a live entry")
+	}
+	if err := TrashEntry(appState, 2); err != nil {
+		t.Fatalf("TrashEntry: %v", err)
+	}
+	entries := readTestVault(t, appState)
+	if len(LiveEntries(entries)) != 1 || len(TrashedEntries(entries)) != 1 {
+		t.Fatalf("after trash: %d live, %d trashed", len(LiveEntries(entries)), len(TrashedEntries(entries)))
+	}
+	if FindDuplicateEntry(entries, model.EntryTypePassword, "gitlab.com", "alice") != nil {
+		t.Error("trashed entry should not count as a duplicate")
+	}
+
+	if err := RestoreEntry(appState, 2); err != nil {
+		t.Fatalf("RestoreEntry: %v", err)
+	}
+	if got := LiveEntries(readTestVault(t, appState)); len(got) != 2 {
+		t.Fatalf("after restore: %d live", len(got))
+	}
+
+	TrashEntry(appState, 2)
+	if err := PurgeEntry(appState, 2); err != nil {
+		t.Fatalf("PurgeEntry: %v", err)
+	}
+	if got := readTestVault(t, appState); len(got) != 1 || got[0].ID != 1 {
+		t.Fatalf("after purge: %+v", got)
+	}
+	// A purged entry cannot be brought back from a snapshot either.
+	snapshots, err := ListVaultSnapshots(appState)
+	if err != nil || len(snapshots) == 0 {
+		t.Fatalf("ListVaultSnapshots = %d, %v", len(snapshots), err)
+	}
+	for _, snap := range snapshots {
+		entries, err := OpenVaultSnapshot(appState, snap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.ID == 2 {
+				t.Errorf("snapshot %s still holds the purged entry", snap.ID)
+			}
+		}
+	}
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := makePasswordEntry("old.example", "u")
+	old.ID = 1
+	old.MoveToTrash(now.Add(-40 * 24 * time.Hour))
+	recent := makePasswordEntry("recent.example", "u")
+	recent.ID = 2
+	recent.MoveToTrash(now.Add(-24 * time.Hour))
+	live := makePasswordEntry("live.example", "u")
+	live.ID = 3
+	appState := newTestVaultState(t, old, recent, live)
+
+	if n, err := PurgeExpiredTrash(appState, now); err != nil || n != 0 {
+		t.Fatalf("zero retention purged %d, %v", n, err)
+	}
+
+	appState.TrashRetention = DefaultTrashRetention
+	n, err := PurgeExpiredTrash(appState, now)
+	if err != nil || n != 1 {
+		t.Fatalf("PurgeExpiredTrash = %d, %v", n, err)
+	}
+	entries := readTestVault(t, appState)
+	if len(entries) != 2 || entries[0].ID != 2 || entries[1].ID != 3 {
+		t.Fatalf("remaining entries = %+v", entries)
+	}
+
+	if n, err := EmptyTrash(appState); err != nil || n != 1 {
+		t.Fatalf("EmptyTrash = %d, %v", n, err)
+	}
+	if got := readTestVault(t, appState); len(got) != 1 || got[0].ID != 3 {
+		t.Fatalf("after EmptyTrash: %+v", got)
+	}
+}
@@ -0,0 +1,64 @@
+// Command git-credential-passquantum is a git credential helper backed by
+// the running PassQuantum desktop app. Enable it with
+//
+//	git config --global credential.helper passquantum
+//
+// and git will look up, save and forget HTTPS credentials in the open vault.
+// The helper never sees the master password: it forwards each request to the
+// app over a local socket, authenticated with a secret the app writes to its
+// vault directory. When the app is closed or locked the helper answers
+// nothing and git falls back to its other helpers or a prompt.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"passquantum/internal/gitcred"
+)
+
+const usage = "usage: git-credential-passquantum get|store|erase"
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	action := os.Args[1]
+	switch action {
+	case "get", "store", "erase":
+	default:
+		// gitcredentials(7): helpers ignore actions they do not know.
+		return
+	}
+
+	if err := run(action); err != nil {
+		fmt.Fprintf(os.Stderr, "passquantum: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(action string) error {
+	cred, err := gitcred.ReadCredential(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	socketPath, err := gitcred.DefaultSocketPath()
+	if err != nil {
+		return err
+	}
+	secretPath, err := gitcred.DefaultSecretPath()
+	if err != nil {
+		return err
+	}
+
+	reply, err := gitcred.NewClient(socketPath, secretPath).Do(action, cred)
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return nil
+	}
+	return reply.Write(os.Stdout)
+}
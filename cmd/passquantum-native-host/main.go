@@ -0,0 +1,95 @@
+// Command passquantum-native-host connects the PassQuantum browser extension
+// to the running desktop app. Register it with the browsers once:
+//
+//	passquantum-native-host install
+//
+// which writes a native messaging host manifest for every Chromium-based
+// browser and Firefox this user has run. The browser then starts the host
+// when the extension connects and talks to it over stdin/stdout; the host
+// relays each message to the app's Unix socket, which only this user can
+// open (on Windows, a loopback port guarded by a per-run token). While the app is closed every request is answered with 503.
+//
+// Nothing but framed messages may be written to stdout, so diagnostics go
+// to stderr, which browsers log.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"passquantum/internal/nativehost"
+)
+
+const usage = `usage: passquantum-native-host install [EXTRA-CHROMIUM-EXTENSION-ID...]
+       passquantum-native-host uninstall
+
+Browsers start the host themselves; run it by hand only to install or
+uninstall the host manifests.`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "install":
+		err = install(os.Args[2:])
+	case "uninstall":
+		err = uninstall()
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+	default:
+		// Chromium passes the caller's origin, Firefox the manifest path
+		// and extension ID; the manifests already restrict both.
+		err = relay()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "passquantum-native-host: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func install(extraIDs []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	written, err := nativehost.Install(exe, extraIDs)
+	for _, path := range written {
+		fmt.Println("installed", path)
+	}
+	if err != nil {
+		return err
+	}
+	if len(written) == 0 {
+		return fmt.Errorf("no supported browser found; start the browser once and run install again")
+	}
+	return nil
+}
+
+func uninstall() error {
+	removed, err := nativehost.Uninstall()
+	for _, path := range removed {
+		fmt.Println("removed", path)
+	}
+	return err
+}
+
+func relay() error {
+	socketPath, err := nativehost.DefaultSocketPath()
+	if err != nil {
+		return err
+	}
+	conn, err := nativehost.Dial(socketPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "passquantum-native-host: %v\n", err)
+		return nativehost.Unavailable(os.Stdin, os.Stdout, "PassQuantum is not running")
+	}
+	return nativehost.Relay(os.Stdin, os.Stdout, conn)
+}
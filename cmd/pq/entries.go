@@ -0,0 +1,759 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"passquantum/app"
+	"passquantum/core/model"
+	"passquantum/core/totp"
+	"passquantum/core/urlmatch"
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+func runList(c *cli, args []string) error {
+	fs := newFlags("list")
+	typeName := fs.String("type", "", "only entries of this type")
+	folder := fs.String("folder", "", "only entries in this folder or below")
+	tag := fs.String("tag", "", "only entries with this tag")
+	favorites := fs.Bool("favorites", false, "only favorites")
+	trash := fs.Bool("trash", false, "list the trash instead")
+	query := fs.String("query", "", "substring of name or username")
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+
+	filter := app.EntryFilter{
+		Folder:            model.NormalizeFolderPath(*folder),
+		IncludeSubfolders: true,
+		FavoritesOnly:     *favorites,
+		Query:             *query,
+	}
+	if *typeName != "" {
+		t, err := app.ParseEntryType(*typeName)
+		if err != nil {
+			return usageError("%v", err)
+		}
+		filter.Types = []model.EntryType{t}
+	}
+	if *tag != "" {
+		filter.Tags = []string{*tag}
+	}
+
+	appState, err := c.openVault()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	entries, err := readEntries(appState)
+	if err != nil {
+		return err
+	}
+	if *trash {
+		entries = app.TrashedEntries(entries)
+	} else {
+		entries = app.LiveEntries(entries)
+	}
+
+	details := []*app.EntryDetails{}
+	for _, e := range app.FilterEntries(entries, filter) {
+		d, err := app.DescribeEntry(e, nil)
+		if err != nil {
+			return err
+		}
+		details = append(details, d)
+	}
+	if c.json {
+		return c.printJSON(details)
+	}
+
+	rows := make([][]string, len(details))
+	for i, d := range details {
+		name := d.Name
+		if d.Favorite {
+			name = "* " + name
+		}
+		rows[i] = []string{d.ID, d.Type, name, d.Username, d.Folder, strings.Join(d.Tags, ",")}
+	}
+	return c.printTable([]string{"ID", "TYPE", "NAME", "USERNAME", "FOLDER", "TAGS"}, rows)
+}
+
+func runGet(c *cli, args []string) error {
+	fs := newFlags("get")
+	reveal := fs.Bool("reveal", false, "show secret values")
+	field := fs.String("field", "", "print only this field, unmasked")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("get takes exactly one entry")
+	}
+
+	appState, err := c.openVault()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	entries, err := readEntries(appState)
+	if err != nil {
+		return err
+	}
+	entry, err := app.ResolveEntry(entries, rest[0])
+	if err != nil {
+		return err
+	}
+	d, err := app.DescribeEntry(entry, appState.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	if *field != "" {
+		value, ok := d.Field(*field)
+		if !ok {
+			return fmt.Errorf("entry %s has no field %q", d.ID, *field)
+		}
+		if c.json {
+			if err := c.printJSON(map[string]string{*field: value}); err != nil {
+				return err
+			}
+		} else {
+			fmt.Fprintln(c.stdout, value)
+		}
+		return app.MarkEntryUsed(appState, entry.ID)
+	}
+
+	if !*reveal {
+		d.Redact()
+	}
+	if err := c.printDetails(d); err != nil {
+		return err
+	}
+	if *reveal {
+		return app.MarkEntryUsed(appState, entry.ID)
+	}
+	return nil
+}
+
+// entryFlags are the flags shared by add and edit. Secret-bearing flags
+// accept "-" to read the value from stdin.
+type entryFlags struct {
+	fs *flag.FlagSet
+
+	name, username, password, loginURL, notes string
+	urls, fields, hiddenFields                stringList
+	match, matchPattern                       string
+
+	content string
+
+	subtype, holder, number, expiry, cvv string
+
+	uri, issuer, account, secret, algorithm string
+	digits, period                          int
+
+	keyFile, passphrase, comment string
+	confirm                      bool
+
+	folder, tags string
+	favorite     bool
+}
+
+func newEntryFlags(name string) *entryFlags {
+	f := &entryFlags{fs: newFlags(name)}
+	fs := f.fs
+	fs.StringVar(&f.name, "name", "", "entry name (edit only; add takes it as an argument)")
+	fs.StringVar(&f.username, "username", "", "password: username")
+	fs.StringVar(&f.password, "password", "", "password: the password, or - for stdin")
+	fs.StringVar(&f.loginURL, "login-url", "", "password: login page URL")
+	fs.Var(&f.urls, "url", "password: additional URL (repeatable)")
+	fs.StringVar(&f.notes, "notes", "", "password: notes")
+	fs.Var(&f.fields, "field", "password: custom field NAME=VALUE (repeatable)")
+	fs.Var(&f.hiddenFields, "hidden-field", "password: hidden custom field NAME=VALUE (repeatable)")
+	fs.StringVar(&f.match, "match", "", "password: when the browser offers it: "+matchModeNames())
+	fs.StringVar(&f.matchPattern, "match-pattern", "", "password: URL, prefix URL or whole-URL regex the match mode compares pages with")
+	fs.StringVar(&f.content, "content", "", "note: content, or - for stdin")
+	fs.StringVar(&f.subtype, "subtype", "", "card: card kind, e.g. credit or debit")
+	fs.StringVar(&f.holder, "holder", "", "card: holder name")
+	fs.StringVar(&f.number, "number", "", "card: number, or - for stdin")
+	fs.StringVar(&f.expiry, "expiry", "", "card: expiry (MM/YY)")
+	fs.StringVar(&f.cvv, "cvv", "", "card: CVV, or - for stdin")
+	fs.StringVar(&f.uri, "uri", "", "totp: otpauth:// URI, or - for stdin")
+	fs.StringVar(&f.issuer, "issuer", "", "totp: issuer")
+	fs.StringVar(&f.account, "account", "", "totp: account name")
+	fs.StringVar(&f.secret, "secret", "", "totp: base32 secret, or - for stdin")
+	fs.StringVar(&f.algorithm, "algorithm", "", "totp: SHA1, SHA256 or SHA512")
+	fs.IntVar(&f.digits, "digits", 0, "totp: code length")
+	fs.IntVar(&f.period, "period", 0, "totp: period in seconds")
+	fs.StringVar(&f.keyFile, "private-key-file", "", "ssh: private key file to import, or - for stdin")
+	fs.StringVar(&f.passphrase, "passphrase", "", "ssh: passphrase of the private key, or - for stdin")
+	fs.StringVar(&f.comment, "comment", "", "ssh: key comment")
+	fs.BoolVar(&f.confirm, "confirm", false, "ssh: ask before every use of the key")
+	fs.StringVar(&f.folder, "folder", "", "folder path, e.g. Work/Dev")
+	fs.StringVar(&f.tags, "tags", "", "comma-separated tags (replaces existing ones)")
+	fs.BoolVar(&f.favorite, "favorite", false, "mark as favorite")
+	return f
+}
+
+// set reports which flags were given explicitly.
+func (f *entryFlags) set() map[string]bool {
+	given := make(map[string]bool)
+	f.fs.Visit(func(fl *flag.Flag) { given[fl.Name] = true })
+	return given
+}
+
+// resolveSecrets replaces "-" values with a line from stdin. Only one flag
+// may read stdin per invocation.
+func (f *entryFlags) resolveSecrets(c *cli) error {
+	fromStdin := 0
+	for _, p := range []*string{&f.password, &f.content, &f.number, &f.cvv, &f.uri, &f.secret, &f.passphrase} {
+		if *p != "-" {
+			continue
+		}
+		fromStdin++
+		if fromStdin > 1 {
+			return usageError("only one flag can read from stdin")
+		}
+		v, err := c.readSecretArg(*p)
+		if err != nil {
+			return err
+		}
+		*p = v
+	}
+	return nil
+}
+
+// customFields parses the --field and --hidden-field values.
+func (f *entryFlags) customFields() ([]model.CustomField, error) {
+	var out []model.CustomField
+	add := func(values []string, hidden bool) error {
+		for _, v := range values {
+			name, value, ok := strings.Cut(v, "=")
+			if !ok || strings.TrimSpace(name) == "" {
+				return usageError("custom field %q must be NAME=VALUE", v)
+			}
+			out = append(out, model.CustomField{Name: strings.TrimSpace(name), Value: value, Hidden: hidden})
+		}
+		return nil
+	}
+	if err := add(f.fields, false); err != nil {
+		return nil, err
+	}
+	if err := add(f.hiddenFields, true); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// applyTOTP copies the TOTP flags that were given onto params.
+func (f *entryFlags) applyTOTP(params *totp.TOTPParams, given map[string]bool) error {
+	if given["uri"] {
+		parsed, err := totp.ParseOTPAuthURI(f.uri)
+		if err != nil {
+			return err
+		}
+		*params = *parsed
+	}
+	if given["issuer"] {
+		params.Issuer = f.issuer
+	}
+	if given["account"] {
+		params.Account = f.account
+	}
+	if given["secret"] {
+		params.Secret = strings.ToUpper(strings.ReplaceAll(f.secret, " ", ""))
+	}
+	if given["algorithm"] {
+		params.Algorithm = totp.Algorithm(strings.ToUpper(f.algorithm))
+	}
+	if given["digits"] {
+		params.Digits = f.digits
+	}
+	if given["period"] {
+		params.Period = f.period
+	}
+	return nil
+}
+
+func matchModeNames() string {
+	names := make([]string, len(model.MatchModes))
+	for i, m := range model.MatchModes {
+		names[i] = m.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// applyMatch sets the match rule of a password entry from --match and
+// --match-pattern. A pattern alone keeps the entry's mode.
+func (f *entryFlags) applyMatch(e *model.VaultEntry, given map[string]bool) error {
+	if !given["match"] && !given["match-pattern"] {
+		return nil
+	}
+	if e.Type != model.EntryTypePassword {
+		return usageError("--match applies to password entries only")
+	}
+	rule := e.Match
+	if given["match"] {
+		mode, err := model.ParseMatchMode(f.match)
+		if err != nil {
+			return usageError("%v", err)
+		}
+		rule.Mode = mode
+	}
+	if given["match-pattern"] {
+		rule.Pattern = strings.TrimSpace(f.matchPattern)
+	}
+	if err := urlmatch.ValidateRule(rule); err != nil {
+		return usageError("%v", err)
+	}
+	e.Match = rule
+	return nil
+}
+
+func (f *entryFlags) applyOrganization(e *model.VaultEntry, given map[string]bool) {
+	if given["folder"] {
+		e.Folder = model.NormalizeFolderPath(f.folder)
+	}
+	if given["tags"] {
+		e.Tags = model.NormalizeTags(strings.Split(f.tags, ","))
+	}
+	if given["favorite"] {
+		e.Favorite = f.favorite
+	}
+}
+
+func runAdd(c *cli, args []string) error {
+	f := newEntryFlags("add")
+	rest, err := parseInterspersed(f.fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) < 1 {
+		return usageError("add needs an entry type")
+	}
+	entryType, err := app.ParseEntryType(rest[0])
+	if err != nil {
+		return usageError("%v", err)
+	}
+	if entryType == model.EntryTypeFile {
+		return usageError("pq cannot add files; store them under Files in the desktop app")
+	}
+	name := f.name
+	if len(rest) > 1 {
+		name = strings.Join(rest[1:], " ")
+	}
+	if f.keyFile == "-" && f.passphrase == "-" {
+		return usageError("only one flag can read from stdin")
+	}
+	if err := f.resolveSecrets(c); err != nil {
+		return err
+	}
+	given := f.set()
+
+	appState, err := c.openVault()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	var entry *model.VaultEntry
+	switch entryType {
+	case model.EntryTypePassword:
+		if f.password == "" {
+			if f.password, err = readPassword("Password for " + name + ": "); err != nil {
+				return err
+			}
+		}
+		payload := model.NewPasswordPayload(f.password)
+		payload.LoginURL = f.loginURL
+		payload.URLs = f.urls
+		payload.Notes = f.notes
+		if payload.Fields, err = f.customFields(); err != nil {
+			return err
+		}
+		entry, err = app.BuildPasswordEntry(name, f.username, payload, appState.PublicKey)
+	case model.EntryTypeNote:
+		entry, err = app.BuildNoteEntry(name, f.content, appState.PublicKey)
+	case model.EntryTypeCard:
+		entry, err = app.BuildCardEntry(name, &app.CardPayload{
+			Subtype: f.subtype,
+			Holder:  f.holder,
+			Number:  f.number,
+			Expiry:  f.expiry,
+			CVV:     f.cvv,
+		}, appState.PublicKey)
+	case model.EntryTypeTOTP:
+		params := totp.DefaultParams()
+		if name != "" {
+			params.Issuer = name
+		}
+		if err := f.applyTOTP(params, given); err != nil {
+			return err
+		}
+		entry, err = app.BuildTOTPEntry(params, appState.PublicKey)
+	case model.EntryTypeSSHKey:
+		if f.keyFile == "" {
+			return usageError("add ssh needs --private-key-file")
+		}
+		var keyData []byte
+		if f.keyFile == "-" {
+			keyData, err = io.ReadAll(c.stdin)
+		} else {
+			keyData, err = os.ReadFile(f.keyFile)
+		}
+		if err != nil {
+			return fmt.Errorf("read private key: %w", err)
+		}
+		payload, _, err := app.PrepareSSHKey(string(keyData), f.passphrase, f.comment)
+		if err != nil {
+			return err
+		}
+		payload.Confirm = f.confirm
+		entry, err = app.BuildSSHKeyEntry(name, payload, appState.PublicKey)
+	default:
+		return usageError("cannot add %s entries from the command line", app.EntryTypeName(entryType))
+	}
+	if err != nil {
+		return err
+	}
+	f.applyOrganization(entry, given)
+	if err := f.applyMatch(entry, given); err != nil {
+		return err
+	}
+
+	entries, err := readEntries(appState)
+	if err != nil {
+		return err
+	}
+	if dup := app.FindDuplicateEntry(entries, entry.Type, entry.Service, entry.Username); dup != nil {
+		return fmt.Errorf("%s already exists as %s; use edit to change it", app.EntryName(dup), app.FormatEntryID(dup.ID))
+	}
+	if err := app.AddEntry(appState, entry); err != nil {
+		return err
+	}
+
+	if c.json {
+		d, err := app.DescribeEntry(entry, nil)
+		if err != nil {
+			return err
+		}
+		return c.printJSON(d)
+	}
+	fmt.Fprintln(c.stdout, app.FormatEntryID(entry.ID))
+	return nil
+}
+
+func runEdit(c *cli, args []string) error {
+	f := newEntryFlags("edit")
+	rest, err := parseInterspersed(f.fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("edit takes exactly one entry")
+	}
+	if err := f.resolveSecrets(c); err != nil {
+		return err
+	}
+	given := f.set()
+	if len(given) == 0 {
+		return usageError("edit: nothing to change")
+	}
+
+	appState, err := c.openVault()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	entries, err := readEntries(appState)
+	if err != nil {
+		return err
+	}
+	target, err := app.ResolveEntry(entries, rest[0])
+	if err != nil {
+		return err
+	}
+	if target.Type == model.EntryTypeFile {
+		return fmt.Errorf("%s is a file entry; pq cannot edit files, manage them under Files in the desktop app", app.FormatEntryID(target.ID))
+	}
+
+	err = app.UpdateEntry(appState, target.ID, func(e *model.VaultEntry) error {
+		now := time.Now().UTC()
+		switch e.Type {
+		case model.EntryTypePassword:
+			payload, err := app.OpenPasswordPayload(e, appState.PrivateKey)
+			if err != nil {
+				return err
+			}
+			// Renaming re-seals the history too, since the name and
+			// username are bound to every ciphertext of the entry.
+			err = app.UpdateEntryMetadata(e, func(e *model.VaultEntry) {
+				if given["name"] {
+					e.Service = f.name
+				}
+				if given["username"] {
+					e.Username = f.username
+				}
+			}, appState.PublicKey, appState.PrivateKey)
+			if err != nil {
+				return err
+			}
+			if given["password"] {
+				payload.Password = f.password
+			}
+			if given["login-url"] {
+				payload.LoginURL = f.loginURL
+			}
+			if given["url"] {
+				payload.URLs = f.urls
+			}
+			if given["notes"] {
+				payload.Notes = f.notes
+			}
+			if given["field"] || given["hidden-field"] {
+				if payload.Fields, err = f.customFields(); err != nil {
+					return err
+				}
+			}
+			if err := app.ReplacePasswordPayload(e, payload, appState.PublicKey, appState.PrivateKey); err != nil {
+				return err
+			}
+		case model.EntryTypeNote:
+			d, err := app.DescribeEntry(e, appState.PrivateKey)
+			if err != nil {
+				return err
+			}
+			if given["name"] {
+				d.Note.Title = f.name
+				e.Service = app.NoteServicePrefix + f.name
+			}
+			if given["content"] {
+				d.Note.Content = f.content
+			}
+			if err := app.SealNotePayload(e, d.Note, appState.PublicKey); err != nil {
+				return err
+			}
+			e.Touch(now)
+		case model.EntryTypeCard:
+			d, err := app.DescribeEntry(e, appState.PrivateKey)
+			if err != nil {
+				return err
+			}
+			card := d.Card
+			if given["name"] {
+				e.Service = app.CardServicePrefix + f.name
+			}
+			if given["subtype"] {
+				card.Subtype = f.subtype
+				e.CardSubtype = f.subtype
+				e.Username = f.subtype
+			}
+			if given["holder"] {
+				card.Holder = f.holder
+			}
+			if given["number"] {
+				card.Number = f.number
+			}
+			if given["expiry"] {
+				card.Expiry = f.expiry
+			}
+			if given["cvv"] {
+				card.CVV = f.cvv
+			}
+			if err := app.SealCardPayload(e, card, appState.PublicKey); err != nil {
+				return err
+			}
+			e.Touch(now)
+		case model.EntryTypeTOTP:
+			d, err := app.DescribeEntry(e, appState.PrivateKey)
+			if err != nil {
+				return err
+			}
+			if given["name"] {
+				given["issuer"], f.issuer = true, f.name
+			}
+			if err := f.applyTOTP(d.TOTP, given); err != nil {
+				return err
+			}
+			e.Service = app.TOTPServicePrefix + d.TOTP.Issuer
+			e.Username = d.TOTP.Account
+			if err := app.SealTOTPParams(e, d.TOTP, appState.PublicKey); err != nil {
+				return err
+			}
+			e.Touch(now)
+		case model.EntryTypeSSHKey:
+			payload, err := app.OpenSSHKeyPayload(e, appState.PrivateKey)
+			if err != nil {
+				return err
+			}
+			if given["name"] {
+				e.Service = app.SSHKeyServicePrefix + f.name
+			}
+			if given["comment"] {
+				updated, _, err := app.PrepareSSHKey(payload.PrivateKey, payload.Passphrase, f.comment)
+				if err != nil {
+					return err
+				}
+				payload.PublicKey, payload.Comment = updated.PublicKey, updated.Comment
+			}
+			if given["confirm"] {
+				payload.Confirm = f.confirm
+			}
+			if err := app.SealSSHKeyPayload(e, payload, appState.PublicKey); err != nil {
+				return err
+			}
+			e.Touch(now)
+		default:
+			if given["name"] {
+				err := app.UpdateEntryMetadata(e, func(e *model.VaultEntry) {
+					e.Service = f.name
+				}, appState.PublicKey, appState.PrivateKey)
+				if err != nil {
+					return err
+				}
+			}
+			e.Touch(now)
+		}
+		f.applyOrganization(e, given)
+		return f.applyMatch(e, given)
+	})
+	if err != nil {
+		return err
+	}
+	if !c.json {
+		fmt.Fprintln(c.stdout, "updated", app.FormatEntryID(target.ID))
+	}
+	return nil
+}
+
+func runRemove(c *cli, args []string) error {
+	fs := newFlags("rm")
+	purge := fs.Bool("purge", false, "delete permanently instead of moving to the trash")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("rm takes exactly one entry")
+	}
+
+	appState, err := c.openVault()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	entries, err := readEntries(appState)
+	if err != nil {
+		return err
+	}
+	entry, err := app.ResolveEntry(entries, rest[0])
+	if err != nil {
+		return err
+	}
+	if !entry.Deleted {
+		if err := app.TrashEntry(appState, entry.ID); err != nil {
+			return err
+		}
+	}
+	if *purge {
+		if err := app.PurgeEntry(appState, entry.ID); err != nil {
+			return err
+		}
+	}
+	if !c.json {
+		if *purge {
+			fmt.Fprintln(c.stdout, "deleted", app.FormatEntryID(entry.ID))
+		} else {
+			fmt.Fprintln(c.stdout, "moved to trash", app.FormatEntryID(entry.ID))
+		}
+	}
+	return nil
+}
+
+func runRestore(c *cli, args []string) error {
+	rest, err := parseInterspersed(newFlags("restore"), args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("restore takes exactly one entry")
+	}
+
+	appState, err := c.openVault()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	entries, err := readEntries(appState)
+	if err != nil {
+		return err
+	}
+	entry, err := app.ResolveTrashedEntry(entries, rest[0])
+	if err != nil {
+		return err
+	}
+	if err := app.RestoreEntry(appState, entry.ID); err != nil {
+		return err
+	}
+	if !c.json {
+		fmt.Fprintln(c.stdout, "restored", app.FormatEntryID(entry.ID))
+	}
+	return nil
+}
+
+func runTOTP(c *cli, args []string) error {
+	rest, err := parseInterspersed(newFlags("totp"), args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("totp takes exactly one entry")
+	}
+
+	appState, err := c.openVault()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	entries, err := readEntries(appState)
+	if err != nil {
+		return err
+	}
+	entry, err := app.ResolveEntry(app.EntriesByType(entries, model.EntryTypeTOTP), rest[0])
+	if err != nil {
+		return err
+	}
+	d, err := app.DescribeEntry(entry, appState.PrivateKey)
+	if err != nil {
+		return err
+	}
+	code, remaining, err := totp.GenerateCode(d.TOTP)
+	if err != nil {
+		return err
+	}
+	if err := app.MarkEntryUsed(appState, entry.ID); err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(map[string]any{"code": code, "remaining": remaining, "period": d.TOTP.Period})
+	}
+	fmt.Fprintln(c.stdout, code)
+	fmt.Fprintln(c.stderr, "expires in "+strconv.Itoa(remaining)+"s")
+	return nil
+}
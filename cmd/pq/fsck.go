@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"passquantum/internal/fsck"
+)
+
+// runFsck checks the whole vault directory. It does not unlock a session,
+// so it still runs when the security profile itself is damaged.
+func runFsck(c *cli, args []string) error {
+	fs := newFlags("fsck")
+	repair := fs.Bool("repair", false, "apply the listed repairs")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return usageError("fsck takes no arguments")
+	}
+
+	pw, err := c.masterPassword()
+	if err != nil {
+		return err
+	}
+	report, err := fsck.Run(fsck.Options{Password: pw, Repair: *repair})
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		if err := c.printJSON(report); err != nil {
+			return err
+		}
+	} else if err := c.printFsckReport(report); err != nil {
+		return err
+	}
+
+	if n := len(report.Outstanding()); n > 0 {
+		return fmt.Errorf("%d problem(s) found", n)
+	}
+	return nil
+}
+
+func (c *cli) printFsckReport(r *fsck.Report) error {
+	if len(r.Findings) > 0 {
+		rows := make([][]string, 0, len(r.Findings))
+		for _, f := range r.Findings {
+			repair := f.Repair
+			switch {
+			case f.Repaired:
+				repair = "repaired: " + repair
+			case f.RepairError != "":
+				repair = "repair failed: " + f.RepairError
+			}
+			rows = append(rows, []string{string(f.Severity), f.Check, f.Vault, f.Subject, f.Problem, repair})
+		}
+		if err := c.printTable([]string{"SEVERITY", "CHECK", "VAULT", "SUBJECT", "PROBLEM", "REPAIR"}, rows); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout)
+	}
+	fmt.Fprintf(c.stdout, "checked %d vault files, %d entries, %d stored files, %d domain links\n",
+		r.Vaults, r.Entries, r.Files, r.DomainLinks)
+	return nil
+}
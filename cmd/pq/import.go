@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"passquantum/app"
+	"passquantum/core/migration"
+)
+
+var duplicateActions = map[string]migration.DuplicateAction{
+	"skip":    migration.DupSkip,
+	"replace": migration.DupReplace,
+	"keep":    migration.DupKeepBoth,
+}
+
+func runImport(c *cli, args []string) error {
+	fs := newFlags("import")
+	format := fs.String("format", "", "importer ID (see pq importers); detected when omitted")
+	onDuplicate := fs.String("on-duplicate", "skip", "skip, replace or keep")
+	sourcePasswordStdin := fs.Bool("source-password-stdin", false, "read the export's own password from stdin")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("import takes exactly one file")
+	}
+	path := rest[0]
+	dupAction, ok := duplicateActions[*onDuplicate]
+	if !ok {
+		return usageError("--on-duplicate must be skip, replace or keep")
+	}
+
+	importerID := *format
+	if importerID == "" {
+		results, err := migration.DetectFile(path)
+		if err != nil {
+			return fmt.Errorf("detect format: %w", err)
+		}
+		if len(results) == 0 {
+			return fmt.Errorf("no importer recognized %q; pass --format", filepath.Base(path))
+		}
+		importerID = results[0].Importer.ID()
+	}
+
+	var opts migration.ParseOptions
+	if *sourcePasswordStdin {
+		pw, err := c.readSecretArg("-")
+		if err != nil {
+			return err
+		}
+		opts.Password = []byte(pw)
+	}
+
+	// Parse before unlocking so format errors do not cost a password prompt.
+	parsed, imp, err := app.ParseImportFile(path, importerID, opts)
+	if err != nil {
+		if imp != nil {
+			return fmt.Errorf("parse %s: %w", imp.DisplayName(), err)
+		}
+		return err
+	}
+
+	appState, err := c.openVault()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	summary, err := app.BatchImport(appState, parsed, dupAction)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(summary)
+	}
+	fmt.Fprintf(c.stdout, "%s: %d parsed, %d added, %d replaced, %d duplicates skipped, %d rows skipped\n",
+		imp.DisplayName(), summary.TotalParsed, summary.NewEntries, summary.Replaced, summary.DupSkipped, summary.Skipped)
+	for _, group := range [][]string{summary.ParseWarnings, summary.MapWarnings, summary.MapErrors} {
+		for _, msg := range group {
+			fmt.Fprintln(c.stderr, "warning:", msg)
+		}
+	}
+	return nil
+}
+
+func runImporters(c *cli, args []string) error {
+	importers := migration.DefaultRegistry.All()
+	if c.json {
+		type importerInfo struct {
+			ID         string   `json:"id"`
+			Name       string   `json:"name"`
+			Extensions []string `json:"extensions"`
+		}
+		out := make([]importerInfo, len(importers))
+		for i, imp := range importers {
+			out[i] = importerInfo{imp.ID(), imp.DisplayName(), imp.Extensions()}
+		}
+		return c.printJSON(out)
+	}
+	rows := make([][]string, len(importers))
+	for i, imp := range importers {
+		rows[i] = []string{imp.ID(), imp.DisplayName()}
+	}
+	return c.printTable([]string{"ID", "FORMAT"}, rows)
+}
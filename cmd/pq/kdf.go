@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+
+	"passquantum/app"
+	"passquantum/core/crypto"
+)
+
+// runKDF shows the Argon2id cost keys are derived with, at unlock and for
+// vault files, and edits the policy behind it. Unlocking already re-derives
+// whatever is below the saved floor; --min-memory and --min-iterations
+// raise that floor and apply it at once, and --calibrate benchmarks this
+// machine again.
+func runKDF(c *cli, args []string) error {
+	fs := newFlags("kdf")
+	calibrate := fs.Bool("calibrate", false, "benchmark this machine and re-derive the keys at the result")
+	target := fs.Int64("target", -1, "set the unlock time calibration aims for, in milliseconds (0 = default)")
+	vaultTarget := fs.Int64("vault-target", -1, "set the time one vault read or write's derivation aims for, in milliseconds (0 = default)")
+	minMemory := fs.Int("min-memory", -1, "set the minimum Argon2id memory in MiB (0 = default)")
+	minIterations := fs.Int("min-iterations", -1, "set the minimum Argon2id iterations (0 = default)")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return usageError("kdf takes no arguments")
+	}
+	if *minMemory > crypto.MaxKDFMemory/1024 {
+		return usageError("--min-memory is at most %d MiB", crypto.MaxKDFMemory/1024)
+	}
+	if *minIterations > crypto.MaxKDFIterations {
+		return usageError("--min-iterations is at most %d", crypto.MaxKDFIterations)
+	}
+
+	appState, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	policy, err := app.LoadKDFPolicy()
+	if err != nil {
+		return err
+	}
+	changed := false
+	if *target >= 0 {
+		policy.TargetMillis = *target
+		changed = true
+	}
+	if *vaultTarget >= 0 {
+		policy.VaultTargetMillis = *vaultTarget
+		changed = true
+	}
+	if *minMemory >= 0 {
+		policy.MinMemoryKiB = uint32(*minMemory) * 1024
+		changed = true
+	}
+	if *minIterations >= 0 {
+		policy.MinIterations = uint32(*minIterations)
+		changed = true
+	}
+	if changed {
+		if err := policy.Save(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case *calibrate:
+		if _, err := app.RecalibrateKDF(appState); err != nil {
+			return err
+		}
+	case changed:
+		if err := app.EnforceKDFPolicy(appState); err != nil {
+			return err
+		}
+	}
+
+	status, err := app.CurrentKDFStatus()
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(status)
+	}
+	fmt.Fprintf(c.stdout, "Argon2id: %d MiB, %d iterations, %d lanes\n", status.MemoryKiB/1024, status.Iterations, status.Parallelism)
+	fmt.Fprintf(c.stdout, "vault files: %d MiB, %d iterations\n", status.VaultMemoryKiB/1024, status.VaultIterations)
+	if status.CalibratedAt.IsZero() {
+		fmt.Fprintln(c.stdout, "not calibrated on this machine")
+	} else {
+		fmt.Fprintf(c.stdout, "calibrated %s for %d ms per unlock, %d ms per vault operation\n",
+			status.CalibratedAt.Local().Format("2006-01-02 15:04"), status.TargetMillis, status.VaultTargetMillis)
+	}
+	fmt.Fprintf(c.stdout, "policy floor: %d MiB, %d iterations\n", status.MinMemoryKiB/1024, status.MinIterations)
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"passquantum/app"
+)
+
+type rotationStatus struct {
+	Rotated      bool      `json:"rotated"`
+	Algorithm    string    `json:"algorithm"`
+	IntervalDays int       `json:"interval_days"`
+	LastRotated  time.Time `json:"last_rotated"`
+	NextRotation time.Time `json:"next_rotation,omitempty"`
+}
+
+// runRotateKeys replaces the vault keypair and re-seals everything under
+// it. With --if-due it only does so when the schedule says the key is due,
+// which is how a cron job or systemd timer enforces the rotation policy.
+func runRotateKeys(c *cli, args []string) error {
+	fs := newFlags("rotate-keys")
+	ifDue := fs.Bool("if-due", false, "rotate only when the schedule requires it")
+	interval := fs.Int("interval", -1, "set the rotation interval in days (0 turns the schedule off)")
+	algorithm := fs.String("algorithm", "", "KEM for new keypairs: X-Wing (hybrid X25519 + ML-KEM-768), ML-KEM-768 or ML-KEM-1024")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return usageError("rotate-keys takes no arguments")
+	}
+
+	// The schedule is security policy, so changing it needs the master
+	// password as much as rotating does.
+	appState, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	schedule, err := app.LoadKeyRotationSchedule()
+	if err != nil {
+		return err
+	}
+	changed := false
+	if *interval >= 0 {
+		schedule.IntervalDays = *interval
+		changed = true
+	}
+	if *algorithm != "" {
+		schedule.Algorithm = *algorithm
+		if _, err := schedule.KEM(); err != nil {
+			return usageError("%v", err)
+		}
+		changed = true
+	}
+	if changed {
+		if err := schedule.Save(); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	rotate := !*ifDue || schedule.Due(now)
+	if rotate {
+		if err := app.RotateKeys(appState); err != nil {
+			return err
+		}
+		if schedule, err = app.LoadKeyRotationSchedule(); err != nil {
+			return err
+		}
+	}
+
+	next, err := schedule.KEM()
+	if err != nil {
+		return err
+	}
+	status := rotationStatus{
+		Rotated:      rotate,
+		Algorithm:    next.String(),
+		IntervalDays: schedule.IntervalDays,
+		LastRotated:  schedule.LastRotated,
+		NextRotation: schedule.NextRotation(now),
+	}
+	if c.json {
+		return c.printJSON(status)
+	}
+	if rotate {
+		fmt.Fprintf(c.stdout, "rotated the vault keypair (%s)\n", next)
+	} else {
+		fmt.Fprintln(c.stdout, "key rotation not due")
+	}
+	if !status.NextRotation.IsZero() {
+		fmt.Fprintf(c.stdout, "next rotation due %s\n", status.NextRotation.Local().Format("2006-01-02"))
+	}
+	return nil
+}
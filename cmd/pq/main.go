@@ -0,0 +1,207 @@
+// Command pq is a headless PassQuantum client for shells, SSH sessions and
+// scripts. It uses the same app and core packages as the desktop app and
+// never loads a display.
+//
+// The master password is read from the PQ_MASTER_PASSWORD environment
+// variable when set, otherwise from the controlling terminal with echo
+// disabled. Use --json on any command for machine-readable output.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const defaultVault = "Default"
+
+const usage = `usage: pq [--vault NAME] [--json] <command> [arguments]
+
+Entries:
+  list      [--type T] [--folder F] [--tag T] [--favorites] [--trash] [--query Q]
+  get       REF [--reveal] [--field NAME]
+  add       TYPE [flags]            TYPE is password, note, card, totp or ssh;
+                                    password takes --match MODE (domain, host,
+                                    host-port, starts-with, regex or never)
+                                    and --match-pattern P
+  edit      REF [flags]             same flags as add; only given flags change
+  rm        REF [--purge]           move to the trash, or delete for good
+  restore   REF                     take an entry out of the trash
+  totp      REF                     print the current code
+
+Secrets:
+  run       [--env-file FILE]... -- COMMAND [ARGS...]
+            start COMMAND with every variable whose value is a reference
+            pq://VAULT/ENTRY/FIELD replaced by that secret
+
+Vaults:
+  vault list
+  vault create NAME
+  vault delete NAME [--yes]
+
+Import:
+  import    FILE [--format ID] [--on-duplicate skip|replace|keep] [--source-password-stdin]
+  importers
+
+Maintenance:
+  fsck      [--repair]              verify every vault, entry, stored file and
+                                    domain link; --repair applies safe fixes
+  rotate-keys [--if-due] [--interval DAYS] [--algorithm NAME]
+            replace the vault keypair and re-seal every entry and stored
+            file; --interval sets the schedule (0 = manual), --algorithm
+            the KEM of new keypairs (X-Wing, the default hybrid of X25519
+            and ML-KEM-768; ML-KEM-768; ML-KEM-1024), and --if-due
+            rotates only when it is due, for running from a timer
+  kdf       [--calibrate] [--target MS] [--vault-target MS] [--min-memory MIB]
+            [--min-iterations N]
+            show the Argon2id cost at unlock and for vault files;
+            --calibrate benchmarks this machine again, the other flags set
+            the policy and re-derive whatever falls below its floor
+  psl       [update FILE | reset]   show the Public Suffix List logins are
+                                    matched with; update installs a newer
+                                    public_suffix_list.dat (FILE or -),
+                                    reset returns to the built-in copy
+
+REF is an entry ID (16 hex digits) or its name. The vault defaults to
+$PQ_VAULT, then "Default".
+`
+
+// errUsage marks errors caused by bad arguments; they exit with status 2.
+var errUsage = errors.New("usage error")
+
+func usageError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, args...))
+}
+
+// cli carries the global options and the output streams shared by every
+// command.
+type cli struct {
+	vault  string
+	json   bool
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command struct {
+	run func(c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"list":        {runList},
+	"ls":          {runList},
+	"get":         {runGet},
+	"show":        {runGet},
+	"add":         {runAdd},
+	"edit":        {runEdit},
+	"rm":          {runRemove},
+	"restore":     {runRestore},
+	"totp":        {runTOTP},
+	"run":         {runRun},
+	"vault":       {runVault},
+	"import":      {runImport},
+	"importers":   {runImporters},
+	"fsck":        {runFsck},
+	"rotate-keys": {runRotateKeys},
+	"kdf":         {runKDF},
+	"psl":         {runPSL},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	global := flag.NewFlagSet("pq", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+	global.StringVar(&c.vault, "vault", envOr("PQ_VAULT", defaultVault), "vault to open")
+	global.BoolVar(&c.json, "json", false, "print JSON")
+	if err := global.Parse(args); err != nil {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	rest := global.Args()
+	if len(rest) == 0 || rest[0] == "help" || rest[0] == "-h" || rest[0] == "--help" {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	cmd, ok := commands[rest[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "pq: unknown command %q\n\n%s", rest[0], usage)
+		return 2
+	}
+
+	cmdArgs := rest[1:]
+	if rest[0] != "run" { // run passes its arguments through to the child
+		cmdArgs = c.takeJSONFlag(cmdArgs)
+	}
+	if err := cmd.run(c, cmdArgs); err != nil {
+		var exit *childExit
+		if errors.As(err, &exit) {
+			return exit.code
+		}
+		fmt.Fprintf(stderr, "pq: %v\n", err)
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// takeJSONFlag lets --json follow the command name as well as precede it.
+func (c *cli) takeJSONFlag(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i, a := range args {
+		if a == "--" {
+			return append(out, args[i:]...)
+		}
+		if a == "--json" || a == "-json" {
+			c.json = true
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func envOr(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// newFlags returns a FlagSet for a subcommand that reports errors instead of
+// exiting, and accepts flags after positional arguments.
+func newFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseInterspersed parses fs from args, allowing positional arguments to
+// appear before, between or after flags. It returns the positionals.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageError("%s: %v", fs.Name(), err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"passquantum/app"
+)
+
+func (c *cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printTable writes rows as tab-aligned columns under header.
+func (c *cli) printTable(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// printDetails writes one entry as "key: value" lines.
+func (c *cli) printDetails(d *app.EntryDetails) error {
+	if c.json {
+		return c.printJSON(d)
+	}
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 1, ' ', 0)
+	line := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", key, value)
+		}
+	}
+	line("id", d.ID)
+	line("type", d.Type)
+	line("name", d.Name)
+	line("username", d.Username)
+	line("folder", d.Folder)
+	line("tags", strings.Join(d.Tags, ", "))
+	if d.Favorite {
+		line("favorite", "yes")
+	}
+	line("match", strings.TrimSpace(d.Match+" "+d.MatchPattern))
+
+	if p := d.Password; p != nil {
+		line("password", p.Password)
+		line("login url", p.LoginURL)
+		for _, u := range p.URLs {
+			line("url", u)
+		}
+		for _, f := range p.Fields {
+			line(f.Name, f.Value)
+		}
+		line("notes", p.Notes)
+	}
+	if n := d.Note; n != nil {
+		line("content", n.Content)
+	}
+	if cd := d.Card; cd != nil {
+		line("holder", cd.Holder)
+		line("number", cd.Number)
+		line("expiry", cd.Expiry)
+		line("cvv", cd.CVV)
+	}
+	if t := d.TOTP; t != nil {
+		line("issuer", t.Issuer)
+		line("account", t.Account)
+		line("secret", t.Secret)
+		line("algorithm", string(t.Algorithm))
+		line("digits", fmt.Sprint(t.Digits))
+		line("period", fmt.Sprintf("%ds", t.Period))
+	}
+	if k := d.SSHKey; k != nil {
+		line("public key", k.PublicKey)
+		line("private key", k.PrivateKey)
+		line("passphrase", k.Passphrase)
+		if k.Confirm {
+			line("confirm", "every use")
+		}
+	}
+	line("secret", d.Secret)
+
+	line("created", formatTime(d.Created))
+	line("modified", formatTime(d.Modified))
+	line("last used", formatTime(d.LastUsed))
+	line("deleted", formatTime(d.DeletedAt))
+	if d.History > 0 {
+		line("history", fmt.Sprintf("%d previous passwords", d.History))
+	}
+	return tw.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
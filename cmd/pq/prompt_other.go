@@ -0,0 +1,21 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readPassword reads a line from stdin. Echo is only turned off on Linux,
+// macOS and Windows, so elsewhere prefer PQ_MASTER_PASSWORD.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
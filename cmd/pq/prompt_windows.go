@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// readPassword prompts on the console with echo turned off, so stdin stays
+// free for piped input.
+func readPassword(prompt string) (string, error) {
+	in, err := os.OpenFile("CONIN$", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no console to prompt on; set PQ_MASTER_PASSWORD")
+	}
+	defer in.Close()
+	out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0)
+	if err != nil {
+		return "", fmt.Errorf("no console to prompt on; set PQ_MASTER_PASSWORD")
+	}
+	defer out.Close()
+
+	handle := windows.Handle(in.Fd())
+	var saved uint32
+	if err := windows.GetConsoleMode(handle, &saved); err != nil {
+		return "", fmt.Errorf("read console mode: %w", err)
+	}
+	noEcho := (saved &^ windows.ENABLE_ECHO_INPUT) | windows.ENABLE_LINE_INPUT | windows.ENABLE_PROCESSED_INPUT
+	if err := windows.SetConsoleMode(handle, noEcho); err != nil {
+		return "", fmt.Errorf("disable console echo: %w", err)
+	}
+	defer windows.SetConsoleMode(handle, saved)
+
+	fmt.Fprint(out, prompt)
+	line, err := bufio.NewReader(in).ReadString('\n')
+	fmt.Fprintln(out)
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"passquantum/app"
+)
+
+// childExit carries a child process's exit status out of run so pq exits
+// with the same code.
+type childExit struct{ code int }
+
+func (e *childExit) Error() string { return fmt.Sprintf("command exited with status %d", e.code) }
+
+// runRun starts a command with pq:// references in its environment replaced
+// by the secrets they point at. References come from the inherited
+// environment and from --env-file templates; resolved values are handed to
+// the child in memory and never written anywhere.
+func runRun(c *cli, args []string) error {
+	fs := newFlags("run")
+	var envFiles stringList
+	fs.Var(&envFiles, "env-file", ".env template to load (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return usageError("run: %v", err)
+	}
+	argv := fs.Args()
+	if len(argv) > 0 && argv[0] == "--" {
+		argv = argv[1:]
+	}
+	if len(argv) == 0 {
+		return usageError("run needs a command, e.g. pq run -- ./server")
+	}
+
+	env := newEnvList(os.Environ())
+	for _, path := range envFiles {
+		vars, err := readEnvFile(path)
+		if err != nil {
+			return err
+		}
+		for _, kv := range vars {
+			env.set(kv[0], kv[1])
+		}
+	}
+	// The child gets secrets, not the key to all of them.
+	env.unset("PQ_MASTER_PASSWORD")
+
+	if err := c.injectSecrets(env); err != nil {
+		return err
+	}
+
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(path, argv[1:]...)
+	cmd.Env = env.environ()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = c.stdin, c.stdout, c.stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &childExit{code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+// injectSecrets replaces every variable whose value is a pq:// reference
+// with the secret it names. The master password is only asked for when at
+// least one reference is present.
+func (c *cli) injectSecrets(env *envList) error {
+	var keys []string
+	var refs []app.SecretRef
+	for _, key := range env.keys {
+		value := env.values[key]
+		if !app.IsSecretRef(value) {
+			continue
+		}
+		ref, err := app.ParseSecretRef(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		keys = append(keys, key)
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	appState, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	values, err := app.ResolveSecretRefs(appState, refs, c.vault)
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		env.set(key, values[i])
+	}
+	return nil
+}
+
+// envList is an ordered environment that later assignments override.
+type envList struct {
+	keys   []string
+	values map[string]string
+}
+
+func newEnvList(environ []string) *envList {
+	env := &envList{values: make(map[string]string)}
+	for _, kv := range environ {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env.set(key, value)
+		}
+	}
+	return env
+}
+
+func (e *envList) set(key, value string) {
+	if _, ok := e.values[key]; !ok {
+		e.keys = append(e.keys, key)
+	}
+	e.values[key] = value
+}
+
+func (e *envList) unset(key string) {
+	if _, ok := e.values[key]; !ok {
+		return
+	}
+	delete(e.values, key)
+	for i, k := range e.keys {
+		if k == key {
+			e.keys = append(e.keys[:i], e.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func (e *envList) environ() []string {
+	out := make([]string, len(e.keys))
+	for i, key := range e.keys {
+		out[i] = key + "=" + e.values[key]
+	}
+	return out
+}
+
+// readEnvFile parses a .env template: KEY=VALUE lines with an optional
+// "export " prefix, "#" comments, and single- or double-quoted values.
+func readEnvFile(path string) ([][2]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var vars [][2]string
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		vars = append(vars, [2]string{key, value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func parseEnvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	switch quote := raw[0]; quote {
+	case '\'', '"':
+		end := strings.LastIndexByte(raw, quote)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated %c quote", quote)
+		}
+		inner := raw[1:end]
+		if quote == '"' {
+			inner = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(inner)
+		}
+		return inner, nil
+	}
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	return raw, nil
+}
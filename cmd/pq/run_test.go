@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := `# database
+export DB_USER=admin
+DB_PASSWORD=pq://Default/db/password
+QUOTED="two words # not a comment"
+SINGLE='pq://Work/api/token'
+PLAIN=value # trailing comment
+EMPTY=
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readEnvFile(path)
+	if err != nil {
+		t.Fatalf("readEnvFile: %v", err)
+	}
+	want := [][2]string{
+		{"DB_USER", "admin"},
+		{"DB_PASSWORD", "pq://Default/db/password"},
+		{"QUOTED", "two words # not a comment"},
+		{"SINGLE", "pq://Work/api/token"},
+		{"PLAIN", "value"},
+		{"EMPTY", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readEnvFile = %q, want %q", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("NOT A VAR\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readEnvFile(path); err == nil {
+		t.Error("malformed line should fail")
+	}
+}
+
+func TestEnvListOverrideAndUnset(t *testing.T) {
+	env := newEnvList([]string{"A=1", "PQ_MASTER_PASSWORD=x", "B=2"})
+	env.set("A", "3")
+	env.unset("PQ_MASTER_PASSWORD")
+	if got, want := env.environ(), []string{"A=3", "B=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("environ = %q, want %q", got, want)
+	}
+}
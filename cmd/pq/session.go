@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"passquantum/app"
+	"passquantum/core/model"
+)
+
+// masterPassword returns $PQ_MASTER_PASSWORD when set, otherwise prompts on
+// the terminal.
+func (c *cli) masterPassword() (string, error) {
+	if pw := os.Getenv("PQ_MASTER_PASSWORD"); pw != "" {
+		return pw, nil
+	}
+	pw, err := readPassword("Master password: ")
+	if err != nil {
+		return "", fmt.Errorf("read master password: %w", err)
+	}
+	if pw == "" {
+		return "", fmt.Errorf("master password cannot be empty")
+	}
+	return pw, nil
+}
+
+// openVault unlocks the session and opens c.vault. Callers must
+// ClearSensitiveState the result when done.
+func (c *cli) openVault() (*app.AppState, error) {
+	pw, err := c.masterPassword()
+	if err != nil {
+		return nil, err
+	}
+	return app.NewHeadlessSession(pw, c.vault)
+}
+
+// unlock verifies the master password without opening a vault.
+func (c *cli) unlock() (*app.AppState, error) {
+	pw, err := c.masterPassword()
+	if err != nil {
+		return nil, err
+	}
+	return app.NewHeadlessSession(pw, "")
+}
+
+// readEntries returns every entry of the open vault, trashed ones included.
+func readEntries(appState *app.AppState) ([]*model.VaultEntry, error) {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+	return app.ReadVault(app.GetVaultPath(appState.CurrentVault), appState.MasterPassword)
+}
+
+// readSecretArg resolves a secret given on the command line: "-" reads one
+// line from stdin so secrets need not appear in the process list.
+func (c *cli) readSecretArg(value string) (string, error) {
+	if value != "-" {
+		return value, nil
+	}
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("read stdin: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
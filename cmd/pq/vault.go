@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"passquantum/app"
+)
+
+func runVault(c *cli, args []string) error {
+	if len(args) == 0 {
+		return usageError("vault needs a subcommand: list, create or delete")
+	}
+	switch args[0] {
+	case "list", "ls":
+		return runVaultList(c)
+	case "create":
+		return runVaultCreate(c, args[1:])
+	case "delete", "rm":
+		return runVaultDelete(c, args[1:])
+	}
+	return usageError("unknown vault subcommand %q", args[0])
+}
+
+// runVaultList only reads the vault directory, so it needs no password.
+func runVaultList(c *cli) error {
+	vaults := app.ListVaults()
+	sort.Strings(vaults)
+	if c.json {
+		if vaults == nil {
+			vaults = []string{}
+		}
+		return c.printJSON(vaults)
+	}
+	for _, v := range vaults {
+		fmt.Fprintln(c.stdout, v)
+	}
+	return nil
+}
+
+func runVaultCreate(c *cli, args []string) error {
+	rest, err := parseInterspersed(newFlags("vault create"), args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("vault create takes exactly one name")
+	}
+
+	appState, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	if err := app.CreateNewVault(appState, rest[0]); err != nil {
+		return err
+	}
+	if !c.json {
+		fmt.Fprintf(c.stdout, "created vault %s\n", appState.CurrentVault)
+	}
+	return nil
+}
+
+func runVaultDelete(c *cli, args []string) error {
+	fs := newFlags("vault delete")
+	yes := fs.Bool("yes", false, "confirm deletion")
+	rest, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageError("vault delete takes exactly one name")
+	}
+	if !*yes {
+		return usageError("deleting vault %q cannot be undone; pass --yes to confirm", rest[0])
+	}
+
+	// Require the master password so a stray shell cannot destroy vaults.
+	appState, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer appState.ClearSensitiveState()
+
+	if err := app.DeleteVault(appState, rest[0]); err != nil {
+		return err
+	}
+	if !c.json {
+		fmt.Fprintf(c.stdout, "deleted vault %s\n", rest[0])
+	}
+	return nil
+}
@@ -21,6 +21,12 @@ func NewAES256GCM(key []byte) (cipher.AEAD, error) {
 // EncryptAES256GCM encrypts plaintext using AES-256-GCM with a given shared secret key
 // Returns the nonce and ciphertext
 func EncryptAES256GCM(plaintext string, sharedSecret []byte) ([]byte, []byte, error) {
+	return EncryptAES256GCMWithAAD(plaintext, sharedSecret, nil)
+}
+
+// EncryptAES256GCMWithAAD is EncryptAES256GCM with additional data that is
+// authenticated but not encrypted; the same bytes must be passed to decrypt.
+func EncryptAES256GCMWithAAD(plaintext string, sharedSecret, additionalData []byte) ([]byte, []byte, error) {
 	gcm, err := NewAES256GCM(sharedSecret[:32])
 	if err != nil {
 		return nil, nil, err
@@ -31,7 +37,7 @@ func EncryptAES256GCM(plaintext string, sharedSecret []byte) ([]byte, []byte, er
 		return nil, nil, err
 	}
 
-	ciphertext := gcm.Seal(nil, nonce, []byte(plaintext), nil)
+	ciphertext := gcm.Seal(nil, nonce, []byte(plaintext), additionalData)
 
 	return nonce, ciphertext, nil
 }
@@ -39,12 +45,18 @@ func EncryptAES256GCM(plaintext string, sharedSecret []byte) ([]byte, []byte, er
 // DecryptAES256GCM decrypts ciphertext using AES-256-GCM with a given shared secret key
 // Returns the plaintext
 func DecryptAES256GCM(nonce []byte, ciphertext []byte, sharedSecret []byte) (string, error) {
+	return DecryptAES256GCMWithAAD(nonce, ciphertext, sharedSecret, nil)
+}
+
+// DecryptAES256GCMWithAAD reverses EncryptAES256GCMWithAAD. It fails unless
+// additionalData matches what the ciphertext was sealed with.
+func DecryptAES256GCMWithAAD(nonce, ciphertext, sharedSecret, additionalData []byte) (string, error) {
 	gcm, err := NewAES256GCM(sharedSecret[:32])
 	if err != nil {
 		return "", err
 	}
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return "", err
 	}
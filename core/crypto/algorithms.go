@@ -0,0 +1,154 @@
+package crypto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/kyber/kyber768"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem1024"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+	"github.com/cloudflare/circl/kem/xwing"
+	"github.com/cloudflare/circl/sign"
+	dilithiumMode3 "github.com/cloudflare/circl/sign/dilithium/mode3"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+)
+
+// KEMAlgorithm identifies the key-encapsulation mechanism behind a key, an
+// entry or file ciphertext, or a PQ vault header. It is stored as the first
+// byte of every key and ciphertext written since algorithm agility; older
+// data carries no identifier and is always Kyber768.
+type KEMAlgorithm uint8
+
+const (
+	// KEMKyber768 is circl's pre-standard round-3 Kyber. It is still read
+	// but no longer used for new keys.
+	KEMKyber768  KEMAlgorithm = 0x01
+	KEMMLKEM768  KEMAlgorithm = 0x02
+	KEMMLKEM1024 KEMAlgorithm = 0x03
+	// KEMXWing is the X-Wing hybrid: an X25519 exchange and ML-KEM-768
+	// whose shared secrets are bound together, with both ciphertexts and
+	// the X25519 public key, by X-Wing's SHA3-256 combiner. A break of
+	// either primitive alone does not reveal the shared secret.
+	KEMXWing KEMAlgorithm = 0x04
+)
+
+// DefaultKEM is the algorithm new keypairs use, and so the one entries and
+// stored files are sealed with.
+const DefaultKEM = KEMXWing
+
+// PQVaultKEM is the algorithm new PQ vault headers use. Its keys are derived
+// from the master password, so a classical half would add nothing there.
+const PQVaultKEM = KEMMLKEM768
+
+// SignatureAlgorithm identifies the scheme that signs a PQ vault.
+type SignatureAlgorithm uint8
+
+const (
+	SigDilithium3 SignatureAlgorithm = 0x01
+	SigMLDSA65    SignatureAlgorithm = 0x02
+)
+
+// DefaultSignature is the algorithm new PQ vault files are signed with.
+const DefaultSignature = SigMLDSA65
+
+var kemSchemes = map[KEMAlgorithm]kem.Scheme{
+	KEMKyber768:  kyber768.Scheme(),
+	KEMMLKEM768:  mlkem768.Scheme(),
+	KEMMLKEM1024: mlkem1024.Scheme(),
+	KEMXWing:     xwing.Scheme(),
+}
+
+var signatureSchemes = map[SignatureAlgorithm]sign.Scheme{
+	SigDilithium3: dilithiumMode3.Scheme(),
+	SigMLDSA65:    mldsa65.Scheme(),
+}
+
+// Scheme returns the circl implementation of a.
+func (a KEMAlgorithm) Scheme() (kem.Scheme, error) {
+	if s, ok := kemSchemes[a]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown KEM algorithm 0x%02x", uint8(a))
+}
+
+// Deprecated reports whether data under a should be migrated to DefaultKEM.
+func (a KEMAlgorithm) Deprecated() bool {
+	return a == KEMKyber768
+}
+
+// Hybrid reports whether a combines a classical exchange with the PQ KEM.
+func (a KEMAlgorithm) Hybrid() bool {
+	return a == KEMXWing
+}
+
+func (a KEMAlgorithm) String() string {
+	if s, ok := kemSchemes[a]; ok {
+		return s.Name()
+	}
+	return fmt.Sprintf("KEM(0x%02x)", uint8(a))
+}
+
+// ParseKEMAlgorithm accepts a scheme name such as "ML-KEM-1024", ignoring case.
+func ParseKEMAlgorithm(name string) (KEMAlgorithm, error) {
+	for a, s := range kemSchemes {
+		if strings.EqualFold(s.Name(), name) {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown KEM algorithm %q", name)
+}
+
+// KEMAlgorithmOf returns the algorithm of a key.
+func KEMAlgorithmOf(key interface{ Scheme() kem.Scheme }) (KEMAlgorithm, error) {
+	name := key.Scheme().Name()
+	for a, s := range kemSchemes {
+		if s.Name() == name {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported KEM scheme %s", name)
+}
+
+// Scheme returns the circl implementation of a.
+func (a SignatureAlgorithm) Scheme() (sign.Scheme, error) {
+	if s, ok := signatureSchemes[a]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown signature algorithm 0x%02x", uint8(a))
+}
+
+func (a SignatureAlgorithm) String() string {
+	if s, ok := signatureSchemes[a]; ok {
+		return s.Name()
+	}
+	return fmt.Sprintf("signature(0x%02x)", uint8(a))
+}
+
+// tagKEM prefixes raw key or ciphertext bytes with their algorithm.
+func tagKEM(a KEMAlgorithm, raw []byte) []byte {
+	out := make([]byte, 0, 1+len(raw))
+	out = append(out, byte(a))
+	return append(out, raw...)
+}
+
+// untagKEM splits data written by tagKEM. Data of exactly legacySize bytes
+// predates the identifiers and is Kyber768; otherwise the rest must be
+// exactly size(scheme) bytes for the tagged algorithm.
+func untagKEM(data []byte, legacySize int, size func(kem.Scheme) int) (KEMAlgorithm, kem.Scheme, []byte, error) {
+	if len(data) == legacySize {
+		return KEMKyber768, kyber768.Scheme(), data, nil
+	}
+	if len(data) == 0 {
+		return 0, nil, nil, fmt.Errorf("empty key material")
+	}
+	a := KEMAlgorithm(data[0])
+	s, err := a.Scheme()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if raw := data[1:]; len(raw) == size(s) {
+		return a, s, raw, nil
+	}
+	return 0, nil, nil, fmt.Errorf("%s data is %d bytes, want %d", a, len(data)-1, size(s))
+}
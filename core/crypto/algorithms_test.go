@@ -0,0 +1,123 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cloudflare/circl/kem/kyber/kyber768"
+)
+
+func TestPQVaultAlgorithms(t *testing.T) {
+	const password = "correct horse battery staple"
+	plaintext := []byte("vault payload")
+
+	legacy, err := pqVaultEncrypt(plaintext, password, legacyPQVaultSuite, CurrentVaultKDFPolicy())
+	if err != nil {
+		t.Fatalf("legacy encrypt: %v", err)
+	}
+	current, err := PQVaultEncrypt(plaintext, password)
+	if err != nil {
+		t.Fatalf("PQVaultEncrypt() error = %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		data []byte
+		kem  KEMAlgorithm
+		sig  SignatureAlgorithm
+	}{
+		"version 1": {legacy, KEMKyber768, SigDilithium3},
+		"version 2": {current, PQVaultKEM, DefaultSignature},
+	} {
+		h, err := ParsePQVaultHeader(tc.data)
+		if err != nil {
+			t.Fatalf("%s: ParsePQVaultHeader() error = %v", name, err)
+		}
+		if h.KEM != tc.kem || h.Signature != tc.sig {
+			t.Errorf("%s: header algorithms = %s, %s", name, h.KEM, h.Signature)
+		}
+		got, err := PQVaultDecrypt(tc.data, password)
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Fatalf("%s: PQVaultDecrypt() = %q, %v", name, got, err)
+		}
+		if _, err := PQVaultDecrypt(tc.data, "wrong"); !errors.Is(err, ErrPQSignatureInvalid) {
+			t.Errorf("%s: wrong password error = %v", name, err)
+		}
+	}
+
+	// An unknown algorithm identifier is refused before any key derivation.
+	unknown := append([]byte(nil), current...)
+	unknown[5] = 0x7f
+	if _, err := ParsePQVaultHeader(unknown); err == nil {
+		t.Error("ParsePQVaultHeader() accepted an unknown KEM")
+	}
+}
+
+func TestKEMAlgorithms(t *testing.T) {
+	for _, algorithm := range []KEMAlgorithm{KEMKyber768, KEMMLKEM768, KEMMLKEM1024, KEMXWing} {
+		pub, priv, err := GenerateKeypairFor(algorithm)
+		if err != nil {
+			t.Fatalf("%s: GenerateKeypairFor() error = %v", algorithm, err)
+		}
+		pubBytes, err := MarshalPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := UnmarshalPublicKey(pubBytes)
+		if err != nil || !loaded.Equal(pub) {
+			t.Fatalf("%s: UnmarshalPublicKey() = %v", algorithm, err)
+		}
+		privBytes, err := MarshalPrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded, err := unmarshalPrivateKey(privBytes); err != nil || !loaded.Equal(priv) {
+			t.Fatalf("%s: unmarshalPrivateKey() = %v", algorithm, err)
+		}
+
+		ct, ss, err := Encapsulate(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if KEMAlgorithm(ct[0]) != algorithm {
+			t.Errorf("%s: ciphertext tagged 0x%02x", algorithm, ct[0])
+		}
+		got, err := Decapsulate(ct, priv)
+		if err != nil || !bytes.Equal(got, ss) {
+			t.Fatalf("%s: Decapsulate() = %v", algorithm, err)
+		}
+	}
+
+	mlkemPub, mlkemPriv, _ := GenerateKeypairFor(KEMMLKEM768)
+	kyberPub, kyberPriv, _ := GenerateKeypairFor(KEMKyber768)
+	ct, _, _ := Encapsulate(kyberPub)
+	if _, err := Decapsulate(ct, mlkemPriv); err == nil {
+		t.Error("Kyber768 ciphertext opened with an ML-KEM-768 key")
+	}
+
+	// The hybrid is not interchangeable with the ML-KEM-768 inside it.
+	_, xwingPriv, _ := GenerateKeypairFor(KEMXWing)
+	ct, _, _ = Encapsulate(mlkemPub)
+	if _, err := Decapsulate(ct, xwingPriv); err == nil {
+		t.Error("ML-KEM-768 ciphertext opened with an X-Wing key")
+	}
+	if !KEMXWing.Hybrid() || KEMMLKEM768.Hybrid() {
+		t.Error("Hybrid() misclassifies X-Wing or ML-KEM-768")
+	}
+
+	// Untagged keys and ciphertexts from older releases are Kyber768.
+	rawPub, _ := kyberPub.MarshalBinary()
+	if loaded, err := UnmarshalPublicKey(rawPub); err != nil || !loaded.Equal(kyberPub) {
+		t.Fatalf("untagged public key: %v", err)
+	}
+	rawPriv, _ := kyberPriv.MarshalBinary()
+	if loaded, err := unmarshalPrivateKey(rawPriv); err != nil || !loaded.Equal(kyberPriv) {
+		t.Fatalf("untagged private key: %v", err)
+	}
+	rawCT := make([]byte, kyber768.CiphertextSize)
+	rawSS := make([]byte, kyber768.SharedKeySize)
+	kyberPub.(*kyber768.PublicKey).EncapsulateTo(rawCT, rawSS, nil)
+	if got, err := Decapsulate(rawCT, kyberPriv); err != nil || !bytes.Equal(got, rawSS) {
+		t.Fatalf("untagged ciphertext: %v", err)
+	}
+}
@@ -4,22 +4,30 @@ import (
 	"crypto/sha256"
 	"fmt"
 
-	"github.com/cloudflare/circl/kem/kyber/kyber768"
+	"github.com/cloudflare/circl/kem"
 )
 
-// AppSecurityFormatVersion is the current on-disk profile version.
-const AppSecurityFormatVersion uint8 = 1
+// AppSecurityFormatVersion is the current on-disk profile version. Version
+// 2 added KDFCalibration; version 1 profiles were all derived with one
+// Argon2id pass and are re-derived at the next unlock.
+const AppSecurityFormatVersion uint8 = 2
 
 // AppSecurityProfile stores the app-level master password verifier.
 type AppSecurityProfile struct {
-	FormatVersion         uint8             `json:"format_version"`
-	PrivateKeyFingerprint []byte            `json:"private_key_fingerprint"`
-	KDFParams             KDFParams         `json:"kdf_params"`
-	Verifier              []byte            `json:"verifier"`
+	FormatVersion         uint8     `json:"format_version"`
+	PrivateKeyFingerprint []byte    `json:"private_key_fingerprint"`
+	KDFParams             KDFParams `json:"kdf_params"`
+	Verifier              []byte    `json:"verifier"`
+	// KDFCalibration is set when KDFParams came from CalibrateKDF on this
+	// machine; they are then the cost of the profile and private.key, and
+	// VaultKDFParams, calibrated for a single read or write, the cost of
+	// vault files and file manifests.
+	KDFCalibration *KDFCalibration `json:"kdf_calibration,omitempty"`
+	VaultKDFParams *KDFParams      `json:"vault_kdf_params,omitempty"`
 }
 
 // PrivateKeyFingerprint returns a stable fingerprint for the current private key.
-func PrivateKeyFingerprint(privateKey *kyber768.PrivateKey) ([]byte, error) {
+func PrivateKeyFingerprint(privateKey kem.PrivateKey) ([]byte, error) {
 	if privateKey == nil {
 		return nil, fmt.Errorf("private key is required")
 	}
@@ -33,8 +41,9 @@ func PrivateKeyFingerprint(privateKey *kyber768.PrivateKey) ([]byte, error) {
 	return fingerprint[:], nil
 }
 
-// CreateAppSecurityProfile builds a verifier profile and the derived session keys.
-func CreateAppSecurityProfile(masterPassword string, privateKey *kyber768.PrivateKey) (*AppSecurityProfile, []byte, []byte, error) {
+// CreateAppSecurityProfile builds a verifier profile and the derived session
+// keys, at the cost set by SetKDFPolicy.
+func CreateAppSecurityProfile(masterPassword string, privateKey kem.PrivateKey) (*AppSecurityProfile, []byte, []byte, error) {
 	if masterPassword == "" {
 		return nil, nil, nil, fmt.Errorf("master password cannot be empty")
 	}
@@ -44,7 +53,7 @@ func CreateAppSecurityProfile(masterPassword string, privateKey *kyber768.Privat
 		return nil, nil, nil, err
 	}
 
-	params := DefaultKDFParams()
+	params, calibration := CurrentKDFPolicy()
 	params.Salt, err = GenerateSalt()
 	if err != nil {
 		return nil, nil, nil, err
@@ -60,13 +69,18 @@ func CreateAppSecurityProfile(masterPassword string, privateKey *kyber768.Privat
 		PrivateKeyFingerprint: append([]byte(nil), fingerprint...),
 		KDFParams:             params,
 		Verifier:              computeAppVerifier(verificationKey, fingerprint),
+		KDFCalibration:        calibration,
+	}
+	if calibration != nil {
+		vault := CurrentVaultKDFPolicy()
+		profile.VaultKDFParams = &vault
 	}
 
 	return profile, encryptionKey, verificationKey, nil
 }
 
 // VerifyAppSecurityProfile checks whether the provided password matches the stored verifier.
-func VerifyAppSecurityProfile(profile *AppSecurityProfile, masterPassword string, privateKey *kyber768.PrivateKey) ([]byte, []byte, bool, bool, error) {
+func VerifyAppSecurityProfile(profile *AppSecurityProfile, masterPassword string, privateKey kem.PrivateKey) ([]byte, []byte, bool, bool, error) {
 	if profile == nil {
 		return nil, nil, false, false, fmt.Errorf("security profile is required")
 	}
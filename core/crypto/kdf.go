@@ -12,21 +12,41 @@ import (
 // KDFParams contains the Argon2id parameters
 type KDFParams struct {
 	Salt        []byte // 16 bytes
-	Memory      uint32 // 64 MB for security
-	Iterations  uint32 // 1 iteration is fast
-	Parallelism uint8  // 4 cores
+	Memory      uint32 // KiB
+	Iterations  uint32 // passes over memory
+	Parallelism uint8  // lanes
 }
 
-// DefaultKDFParams returns secure defaults for password derivation
+// DefaultKDFParams returns the weakest cost the app derives keys with: the
+// RFC 9106 recommendation for memory-constrained settings. CalibrateKDF
+// only ever raises it.
 func DefaultKDFParams() KDFParams {
 	return KDFParams{
 		Salt:        nil,       // Will be generated
 		Memory:      64 * 1024, // 64 MB
-		Iterations:  1,
+		Iterations:  3,
 		Parallelism: 4,
 	}
 }
 
+// Weaker reports whether p costs less than policy in memory or passes.
+// Parallelism is not compared: it changes throughput, not the work an
+// attacker has to do per guess.
+func (p KDFParams) Weaker(policy KDFParams) bool {
+	return p.Memory < policy.Memory || p.Iterations < policy.Iterations
+}
+
+// AtLeast returns p with memory and passes raised to floor where they are
+// lower. The salt is kept.
+func (p KDFParams) AtLeast(floor KDFParams) KDFParams {
+	p.Memory = max(p.Memory, floor.Memory)
+	p.Iterations = max(p.Iterations, floor.Iterations)
+	if p.Parallelism == 0 {
+		p.Parallelism = floor.Parallelism
+	}
+	return p
+}
+
 // GenerateSalt creates a random 16-byte salt
 func GenerateSalt() ([]byte, error) {
 	salt := make([]byte, 16)
@@ -0,0 +1,114 @@
+package crypto
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Calibration bounds. Memory stops at 256 MiB so that several vaults can
+// still be re-encrypted in parallel (see app.maxRotationWorkers).
+const (
+	maxCalibratedMemory     = 256 * 1024
+	maxCalibratedIterations = 16
+)
+
+// MaxKDFMemory (KiB) and MaxKDFIterations bound every Argon2id cost the app
+// derives with, policy floor included, and so every cost a vault header may
+// carry. ParsePQVaultHeader rejects anything outside them before deriving,
+// so a corrupt header cannot allocate gigabytes or spin for minutes ahead of
+// the signature check.
+const (
+	MaxKDFMemory     = maxCalibratedMemory
+	MaxKDFIterations = 64
+)
+
+// KDFCalibration records the benchmark that chose a profile's parameters:
+// the unlock time KDFParams aims for and the per-operation time
+// VaultKDFParams aims for.
+type KDFCalibration struct {
+	TargetMillis      int64     `json:"target_ms"`
+	VaultTargetMillis int64     `json:"vault_target_ms,omitempty"`
+	CalibratedAt      time.Time `json:"calibrated_at"`
+}
+
+var (
+	kdfPolicyMu          sync.RWMutex
+	kdfPolicyParams      = DefaultKDFParams()
+	kdfVaultParams       = DefaultKDFParams()
+	kdfPolicyCalibration *KDFCalibration
+)
+
+// boundKDFParams raises params to DefaultKDFParams and caps them at
+// MaxKDFMemory and MaxKDFIterations.
+func boundKDFParams(params KDFParams) KDFParams {
+	params.Salt = nil
+	params = params.AtLeast(DefaultKDFParams())
+	params.Memory = min(params.Memory, MaxKDFMemory)
+	params.Iterations = min(params.Iterations, MaxKDFIterations)
+	return params
+}
+
+// SetKDFPolicy sets the Argon2id cost of the derivations made once per
+// unlock: the security profile and the wrapped private.key. calibration,
+// when not nil, is recorded in profiles created under the policy.
+func SetKDFPolicy(params KDFParams, calibration *KDFCalibration) {
+	kdfPolicyMu.Lock()
+	defer kdfPolicyMu.Unlock()
+	kdfPolicyParams = boundKDFParams(params)
+	kdfPolicyCalibration = calibration
+}
+
+// CurrentKDFPolicy returns the cost set by SetKDFPolicy, DefaultKDFParams
+// until then.
+func CurrentKDFPolicy() (KDFParams, *KDFCalibration) {
+	kdfPolicyMu.RLock()
+	defer kdfPolicyMu.RUnlock()
+	return kdfPolicyParams, kdfPolicyCalibration
+}
+
+// SetVaultKDFPolicy sets the Argon2id cost of PQ vault envelopes (vaults
+// and file manifests). Those are derived again on every read and write, so
+// this cost is sized for one operation rather than for an unlock.
+func SetVaultKDFPolicy(params KDFParams) {
+	kdfPolicyMu.Lock()
+	defer kdfPolicyMu.Unlock()
+	kdfVaultParams = boundKDFParams(params)
+}
+
+// CurrentVaultKDFPolicy returns the cost set by SetVaultKDFPolicy,
+// DefaultKDFParams until then.
+func CurrentVaultKDFPolicy() KDFParams {
+	kdfPolicyMu.RLock()
+	defer kdfPolicyMu.RUnlock()
+	return kdfVaultParams
+}
+
+// CalibrateKDF benchmarks Argon2id on this machine and returns the cost of
+// one derivation that takes about target, never below floor. Memory is
+// doubled first, up to 256 MiB, since it is what makes guessing expensive on
+// GPUs; the remaining time budget goes into passes.
+func CalibrateKDF(target time.Duration, floor KDFParams) KDFParams {
+	p := DefaultKDFParams().AtLeast(floor)
+	p.Salt = nil
+	measure := func(p KDFParams) time.Duration {
+		salt := make([]byte, 16)
+		start := time.Now()
+		key := argon2.IDKey([]byte("passquantum-kdf-calibration"), salt, p.Iterations, p.Memory, p.Parallelism, 32)
+		elapsed := time.Since(start)
+		WipeBytes(key)
+		return max(elapsed, time.Millisecond)
+	}
+
+	elapsed := measure(p)
+	for elapsed*2 <= target && p.Memory*2 <= maxCalibratedMemory {
+		p.Memory *= 2
+		elapsed = measure(p)
+	}
+	if elapsed < target {
+		scaled := uint32(int64(p.Iterations) * int64(target) / int64(elapsed))
+		p.Iterations = min(max(scaled, p.Iterations), max(maxCalibratedIterations, floor.Iterations))
+	}
+	return p
+}
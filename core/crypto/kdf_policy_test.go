@@ -0,0 +1,173 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// setTestKDFPolicy sets both the unlock and the vault policy for one test
+// and restores the previous ones afterwards.
+func setTestKDFPolicy(t *testing.T, params KDFParams) {
+	t.Helper()
+	prevParams, prevCalibration := CurrentKDFPolicy()
+	prevVault := CurrentVaultKDFPolicy()
+	SetKDFPolicy(params, nil)
+	SetVaultKDFPolicy(params)
+	t.Cleanup(func() {
+		SetKDFPolicy(prevParams, prevCalibration)
+		SetVaultKDFPolicy(prevVault)
+	})
+}
+
+func TestKDFParamsWeakerAndAtLeast(t *testing.T) {
+	base := DefaultKDFParams()
+	if base.Weaker(base) {
+		t.Error("params are weaker than themselves")
+	}
+	stronger := base
+	stronger.Iterations++
+	if !base.Weaker(stronger) {
+		t.Error("fewer iterations not reported as weaker")
+	}
+	if stronger.Weaker(base) {
+		t.Error("more iterations reported as weaker")
+	}
+
+	floor := KDFParams{Memory: base.Memory * 2, Iterations: 1}
+	raised := base.AtLeast(floor)
+	if raised.Memory != floor.Memory || raised.Iterations != base.Iterations || raised.Parallelism != base.Parallelism {
+		t.Errorf("AtLeast = %+v, want memory from the floor and the rest kept", raised)
+	}
+}
+
+func TestSetKDFPolicyKeepsDefaultFloor(t *testing.T) {
+	setTestKDFPolicy(t, KDFParams{Memory: 1024, Iterations: 1, Parallelism: 1})
+	params, _ := CurrentKDFPolicy()
+	if params.Weaker(DefaultKDFParams()) {
+		t.Errorf("policy %+v below DefaultKDFParams", params)
+	}
+}
+
+func TestCalibrateKDFRespectsFloor(t *testing.T) {
+	floor := DefaultKDFParams()
+	floor.Iterations = 5
+	params := CalibrateKDF(time.Millisecond, floor)
+	if params.Weaker(floor) {
+		t.Errorf("CalibrateKDF = %+v, below floor %+v", params, floor)
+	}
+	if params.Memory > maxCalibratedMemory {
+		t.Errorf("CalibrateKDF memory %d KiB above the cap", params.Memory)
+	}
+}
+
+func TestPQVaultHeaderRecordsPolicy(t *testing.T) {
+	data, err := PQVaultEncrypt([]byte("entries"), "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PQVaultKDFOutdated(data) {
+		t.Fatal("freshly sealed vault reported outdated")
+	}
+
+	stronger := DefaultKDFParams()
+	stronger.Iterations++
+	setTestKDFPolicy(t, stronger)
+	if !PQVaultKDFOutdated(data) {
+		t.Fatal("vault below the raised policy not reported outdated")
+	}
+
+	resealed, err := PQVaultEncrypt([]byte("entries"), "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := ParsePQVaultHeader(resealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := header.KDFParams(); got.Iterations != stronger.Iterations || got.Memory != stronger.Memory {
+		t.Errorf("header cost = %+v, want %+v", got, stronger)
+	}
+	if PQVaultKDFOutdated(resealed) {
+		t.Error("vault sealed at the policy reported outdated")
+	}
+	plaintext, err := PQVaultDecrypt(resealed, "pw")
+	if err != nil || string(plaintext) != "entries" {
+		t.Fatalf("PQVaultDecrypt = %q, %v", plaintext, err)
+	}
+}
+
+func TestPQVaultHeaderRejectsOutOfRangeCost(t *testing.T) {
+	data, err := PQVaultEncrypt([]byte("entries"), "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// v2 header: magic, version, KEM, signature, 32-byte salt, then the
+	// iterations and memory.
+	const iterOffset, memOffset = 7 + pqArgonSaltSize, 7 + pqArgonSaltSize + 4
+	for _, tt := range []struct {
+		name   string
+		offset int
+		value  uint32
+	}{
+		{"zero iterations", iterOffset, 0},
+		{"too many iterations", iterOffset, MaxKDFIterations + 1},
+		{"too little memory", memOffset, 8*pqArgonThreads - 1},
+		{"too much memory", memOffset, 0xFFFFFFFF},
+	} {
+		corrupt := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(corrupt[tt.offset:], tt.value)
+		if _, err := ParsePQVaultHeader(corrupt); !errors.Is(err, ErrPQInvalidHeader) {
+			t.Errorf("%s: ParsePQVaultHeader error = %v", tt.name, err)
+		}
+		if _, err := PQVaultDecrypt(corrupt, "pw"); !errors.Is(err, ErrPQInvalidHeader) {
+			t.Errorf("%s: PQVaultDecrypt error = %v", tt.name, err)
+		}
+		if PQVaultKDFOutdated(corrupt) {
+			t.Errorf("%s: reported outdated", tt.name)
+		}
+	}
+}
+
+func TestWrappedKeyUsesUnlockCost(t *testing.T) {
+	setTestKDFPolicy(t, DefaultKDFParams())
+	unlock := DefaultKDFParams()
+	unlock.Iterations++
+	SetKDFPolicy(unlock, nil)
+
+	_, priv, err := GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := WrapPrivateKey(priv, "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault, err := PQVaultEncrypt([]byte("entries"), "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want uint32
+	}{
+		{"private.key", wrapped, unlock.Iterations},
+		{"vault", vault, DefaultKDFParams().Iterations},
+	} {
+		header, err := ParsePQVaultHeader(tt.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := header.KDFParams().Iterations; got != tt.want {
+			t.Errorf("%s sealed with %d iterations, want %d", tt.name, got, tt.want)
+		}
+	}
+	if WrappedKeyKDFOutdated(wrapped) || PQVaultKDFOutdated(vault) {
+		t.Error("files sealed at their policy reported outdated")
+	}
+	if !WrappedKeyKDFOutdated(vault) {
+		t.Error("a key sealed at the vault cost should be below the unlock policy")
+	}
+}
@@ -0,0 +1,88 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/kyber/kyber768"
+
+	securestorage "passquantum/internal/storage"
+)
+
+// ErrPrivateKeyWrapped is returned by LoadKeypair, together with the public
+// key, when private.key can only be opened with the master password.
+var ErrPrivateKeyWrapped = errors.New("private key is encrypted under the master password")
+
+// WrapPrivateKey seals the private key under password in the same envelope
+// as a vault file (see PQVaultEncrypt), so a copied private.key is no
+// weaker than a copied vault. It is opened once per unlock, so it is sealed
+// at the unlock cost of CurrentKDFPolicy.
+func WrapPrivateKey(privateKey kem.PrivateKey, password string) ([]byte, error) {
+	if privateKey == nil {
+		return nil, fmt.Errorf("private key is required")
+	}
+	raw, err := MarshalPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	defer WipeBytes(raw)
+	cost, _ := CurrentKDFPolicy()
+	return pqVaultEncrypt(raw, password, pqVaultSuite{pqVaultVersion, PQVaultKEM, DefaultSignature}, cost)
+}
+
+// WrappedKeyKDFOutdated reports whether a private.key sealed by
+// WrapPrivateKey has a weaker Argon2id cost than CurrentKDFPolicy.
+func WrappedKeyKDFOutdated(data []byte) bool {
+	policy, _ := CurrentKDFPolicy()
+	return pqVaultKDFBelow(data, policy)
+}
+
+// UnwrapPrivateKey opens a key sealed by WrapPrivateKey. A wrong password
+// fails with ErrPQSignatureInvalid.
+func UnwrapPrivateKey(data []byte, password string) (kem.PrivateKey, error) {
+	raw, err := PQVaultDecrypt(data, password)
+	if err != nil {
+		return nil, err
+	}
+	defer WipeBytes(raw)
+	return unmarshalPrivateKey(raw)
+}
+
+// IsWrappedPrivateKey tells a wrapped private.key from a raw one: a tagged
+// raw key starts with its algorithm byte, and an untagged one is always
+// exactly kyber768.PrivateKeySize bytes.
+func IsWrappedPrivateKey(data []byte) bool {
+	return len(data) != kyber768.PrivateKeySize && IsPQVaultFormat(data)
+}
+
+// LoadPrivateKey reads privPath, unwrapping it with password when it is
+// wrapped. Raw keys written before wrapping existed load as they are.
+func LoadPrivateKey(privPath, password string) (kem.PrivateKey, error) {
+	data, err := os.ReadFile(privPath)
+	if err != nil {
+		return nil, err
+	}
+	if IsWrappedPrivateKey(data) {
+		return UnwrapPrivateKey(data, password)
+	}
+	return unmarshalPrivateKey(data)
+}
+
+// SaveWrappedPrivateKey replaces privPath with the key wrapped under password.
+func SaveWrappedPrivateKey(privateKey kem.PrivateKey, privPath, password string) error {
+	wrapped, err := WrapPrivateKey(privateKey, password)
+	if err != nil {
+		return err
+	}
+	return securestorage.WriteFileAtomic(privPath, wrapped, 0600)
+}
+
+func unmarshalPrivateKey(data []byte) (kem.PrivateKey, error) {
+	_, scheme, raw, err := untagKEM(data, kyber768.PrivateKeySize, kem.Scheme.PrivateKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("private key: %w", err)
+	}
+	return scheme.UnmarshalBinaryPrivateKey(raw)
+}
@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrapAndLoadPrivateKey(t *testing.T) {
+	publicKey, privateKey, err := GenerateKeypair()
+	if err != nil {
+		t.Fatalf("GenerateKeypair() error = %v", err)
+	}
+	dir := t.TempDir()
+	pubPath := filepath.Join(dir, "public.key")
+	privPath := filepath.Join(dir, "private.key")
+
+	if err := SaveKeypair(publicKey, privateKey, pubPath, privPath); err != nil {
+		t.Fatalf("SaveKeypair() error = %v", err)
+	}
+	if _, loaded, err := LoadKeypair(pubPath, privPath); err != nil || loaded == nil {
+		t.Fatalf("LoadKeypair() on a raw key = %v, %v", loaded, err)
+	}
+	if _, err := LoadPrivateKey(privPath, "anything"); err != nil {
+		t.Fatalf("LoadPrivateKey() on a raw key error = %v", err)
+	}
+
+	if err := SaveWrappedPrivateKey(privateKey, privPath, "correct horse battery staple"); err != nil {
+		t.Fatalf("SaveWrappedPrivateKey() error = %v", err)
+	}
+	loadedPublic, loaded, err := LoadKeypair(pubPath, privPath)
+	if !errors.Is(err, ErrPrivateKeyWrapped) || loaded != nil || loadedPublic == nil {
+		t.Fatalf("LoadKeypair() on a wrapped key = %v, %v, %v", loadedPublic, loaded, err)
+	}
+
+	if _, err := LoadPrivateKey(privPath, "wrong password"); !errors.Is(err, ErrPQSignatureInvalid) {
+		t.Fatalf("LoadPrivateKey() with wrong password error = %v", err)
+	}
+	unwrapped, err := LoadPrivateKey(privPath, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("LoadPrivateKey() error = %v", err)
+	}
+	want, _ := privateKey.MarshalBinary()
+	got, _ := unwrapped.MarshalBinary()
+	if !bytes.Equal(want, got) {
+		t.Fatal("unwrapped private key differs from the original")
+	}
+}
@@ -1,34 +1,85 @@
 package crypto
 
 import (
+	"fmt"
 	"os"
 
+	"github.com/cloudflare/circl/kem"
 	"github.com/cloudflare/circl/kem/kyber/kyber768"
+
+	securestorage "passquantum/internal/storage"
 )
 
-// GenerateKeypair generates a new Kyber768 keypair
-func GenerateKeypair() (*kyber768.PublicKey, *kyber768.PrivateKey, error) {
-	return kyber768.GenerateKeyPair(nil)
+// GenerateKeypair generates a new keypair for DefaultKEM
+func GenerateKeypair() (kem.PublicKey, kem.PrivateKey, error) {
+	return GenerateKeypairFor(DefaultKEM)
+}
+
+// GenerateKeypairFor generates a new keypair for the given algorithm
+func GenerateKeypairFor(algorithm KEMAlgorithm) (kem.PublicKey, kem.PrivateKey, error) {
+	scheme, err := algorithm.Scheme()
+	if err != nil {
+		return nil, nil, err
+	}
+	return scheme.GenerateKeyPair()
+}
+
+// MarshalPublicKey encodes a public key with its algorithm identifier
+func MarshalPublicKey(publicKey kem.PublicKey) ([]byte, error) {
+	algorithm, err := KEMAlgorithmOf(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := publicKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return tagKEM(algorithm, raw), nil
+}
+
+// UnmarshalPublicKey decodes a public key written by MarshalPublicKey, or
+// an untagged Kyber768 key from older releases
+func UnmarshalPublicKey(data []byte) (kem.PublicKey, error) {
+	_, scheme, raw, err := untagKEM(data, kyber768.PublicKeySize, kem.Scheme.PublicKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+	return scheme.UnmarshalBinaryPublicKey(raw)
+}
+
+// MarshalPrivateKey encodes a private key with its algorithm identifier
+func MarshalPrivateKey(privateKey kem.PrivateKey) ([]byte, error) {
+	algorithm, err := KEMAlgorithmOf(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := privateKey.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	defer WipeBytes(raw)
+	return tagKEM(algorithm, raw), nil
 }
 
-// SaveKeypair saves the Kyber768 keypair to disk
-func SaveKeypair(publicKey *kyber768.PublicKey, privateKey *kyber768.PrivateKey, pubPath, privPath string) error {
-	pubBytes, err := publicKey.MarshalBinary()
+// SaveKeypair saves the keypair to disk
+func SaveKeypair(publicKey kem.PublicKey, privateKey kem.PrivateKey, pubPath, privPath string) error {
+	pubBytes, err := MarshalPublicKey(publicKey)
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(pubPath, pubBytes, 0600)
+	err = securestorage.WriteFileAtomic(pubPath, pubBytes, 0600)
 	if err != nil {
 		return err
 	}
 
-	privBytes, err := privateKey.MarshalBinary()
+	privBytes, err := MarshalPrivateKey(privateKey)
 	if err != nil {
 		return err
 	}
+	defer WipeBytes(privBytes)
 
-	err = os.WriteFile(privPath, privBytes, 0600)
+	err = securestorage.WriteFileAtomic(privPath, privBytes, 0600)
 	if err != nil {
 		return err
 	}
@@ -36,8 +87,10 @@ func SaveKeypair(publicKey *kyber768.PublicKey, privateKey *kyber768.PrivateKey,
 	return nil
 }
 
-// LoadKeypair loads the Kyber768 keypair from disk
-func LoadKeypair(pubPath, privPath string) (*kyber768.PublicKey, *kyber768.PrivateKey, error) {
+// LoadKeypair loads the keypair from disk. When the private key is wrapped
+// it returns the public key alone with ErrPrivateKeyWrapped; use
+// LoadPrivateKey once the master password is known.
+func LoadKeypair(pubPath, privPath string) (kem.PublicKey, kem.PrivateKey, error) {
 	pubBytes, err := os.ReadFile(pubPath)
 	if err != nil {
 		return nil, nil, err
@@ -48,40 +101,59 @@ func LoadKeypair(pubPath, privPath string) (*kyber768.PublicKey, *kyber768.Priva
 		return nil, nil, err
 	}
 
-	scheme := kyber768.Scheme()
-
-	publicKey, err := scheme.UnmarshalBinaryPublicKey(pubBytes)
+	pk, err := UnmarshalPublicKey(pubBytes)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	privateKey, err := scheme.UnmarshalBinaryPrivateKey(privBytes)
+	if IsWrappedPrivateKey(privBytes) {
+		return pk, nil, ErrPrivateKeyWrapped
+	}
+
+	sk, err := unmarshalPrivateKey(privBytes)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	pk := publicKey.(*kyber768.PublicKey)
-	sk := privateKey.(*kyber768.PrivateKey)
-
 	return pk, sk, nil
 }
 
-// Encapsulate performs Kyber768 encapsulation with a public key
-// Returns the ciphertext and shared secret
-func Encapsulate(publicKey *kyber768.PublicKey) ([]byte, []byte, error) {
-	ct := make([]byte, kyber768.CiphertextSize)
-	ss := make([]byte, kyber768.SharedKeySize)
-
-	publicKey.EncapsulateTo(ct, ss, nil)
+// Encapsulate performs KEM encapsulation with a public key
+// Returns the ciphertext, tagged with the key's algorithm, and shared secret
+func Encapsulate(publicKey kem.PublicKey) ([]byte, []byte, error) {
+	if publicKey == nil {
+		return nil, nil, fmt.Errorf("public key is not loaded")
+	}
+	algorithm, err := KEMAlgorithmOf(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	ct, ss, err := publicKey.Scheme().Encapsulate(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return ct, ss, nil
+	return tagKEM(algorithm, ct), ss, nil
 }
 
-// Decapsulate performs Kyber768 decapsulation with a private key
+// Decapsulate performs KEM decapsulation with a private key. Untagged
+// ciphertexts from older releases are Kyber768.
 // Returns the shared secret
-func Decapsulate(encapsulatedSecret []byte, privateKey *kyber768.PrivateKey) ([]byte, error) {
-	ss := make([]byte, kyber768.SharedKeySize)
-	privateKey.DecapsulateTo(ss, encapsulatedSecret)
+func Decapsulate(encapsulatedSecret []byte, privateKey kem.PrivateKey) ([]byte, error) {
+	if privateKey == nil {
+		return nil, fmt.Errorf("private key is not loaded")
+	}
+	algorithm, scheme, ct, err := untagKEM(encapsulatedSecret, kyber768.CiphertextSize, kem.Scheme.CiphertextSize)
+	if err != nil {
+		return nil, fmt.Errorf("ciphertext: %w", err)
+	}
+	keyAlgorithm, err := KEMAlgorithmOf(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if algorithm != keyAlgorithm {
+		return nil, fmt.Errorf("%s ciphertext cannot be opened with a %s key", algorithm, keyAlgorithm)
+	}
 
-	return ss, nil
+	return scheme.Decapsulate(privateKey, ct)
 }
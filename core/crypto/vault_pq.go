@@ -8,8 +8,8 @@ import (
 	"fmt"
 	"io"
 
-	"github.com/cloudflare/circl/kem/kyber/kyber768"
-	dilithiumMode3 "github.com/cloudflare/circl/sign/dilithium/mode3"
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/sign"
 	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/hkdf"
 )
@@ -17,21 +17,28 @@ import (
 // pqVaultMagic is the 4-byte header magic for the PQ vault format.
 var pqVaultMagic = [4]byte{'P', 'Q', 'V', 'T'}
 
-const pqVaultVersion = 0x01
+// PQ vault format versions. Version 1 is always Kyber-768 + Dilithium3;
+// version 2 names its KEM and signature algorithms in the header. Every save
+// writes version 2, so older files migrate the next time they are written.
+const (
+	pqVaultVersionLegacy = 0x01
+	pqVaultVersion       = 0x02
+)
 
-// Argon2id parameters for PQ vault key derivation.
+// Argon2id parameters for PQ vault key derivation. The time and memory
+// cost come from CurrentVaultKDFPolicy (CurrentKDFPolicy for a wrapped
+// private.key) and are stored in each header; the parallelism is not stored
+// and stays fixed.
 const (
-	pqArgonSaltSize  = 32         // 32-byte salt (longer than legacy 16-byte)
-	pqArgonIter      = 2          // time cost
-	pqArgonMemKB     = 64 * 1024  // 64 MB memory cost
-	pqArgonThreads   = 4          // parallelism
-	pqMasterKeySize  = 32         // master_key output length in bytes
+	pqArgonSaltSize = 32 // 32-byte salt (longer than legacy 16-byte)
+	pqArgonThreads  = 4  // parallelism
+	pqMasterKeySize = 32 // master_key output length in bytes
 )
 
 // Sentinel errors for PQ vault operations.
 var (
 	ErrPQInvalidHeader    = errors.New("pq_vault: invalid or unrecognized header")
-	ErrPQSignatureInvalid = errors.New("pq_vault: Dilithium3 signature verification failed")
+	ErrPQSignatureInvalid = errors.New("pq_vault: signature verification failed")
 	ErrPQAuthFailed       = errors.New("pq_vault: AES-256-GCM authentication failed (wrong password or corrupted data)")
 )
 
@@ -41,19 +48,71 @@ func IsPQVaultFormat(data []byte) bool {
 		data[0] == 'P' && data[1] == 'Q' && data[2] == 'V' && data[3] == 'T'
 }
 
+// pqVaultSuite is the set of algorithms one PQ vault file uses.
+type pqVaultSuite struct {
+	version   uint8
+	kem       KEMAlgorithm
+	signature SignatureAlgorithm
+}
+
+var legacyPQVaultSuite = pqVaultSuite{pqVaultVersionLegacy, KEMKyber768, SigDilithium3}
+
+// seedInfo returns the HKDF labels for the suite's KEM and signature seeds.
+// Version 1 labels are kept byte-exact so old files still open.
+func (v pqVaultSuite) seedInfo() (kemInfo, sigInfo []byte) {
+	if v.version == pqVaultVersionLegacy {
+		return []byte("passquantum_kyber_seed_v1"), []byte("passquantum_dilithium_seed_v1")
+	}
+	return append([]byte("passquantum_kem_seed_v2:"), byte(v.kem)),
+		append([]byte("passquantum_sig_seed_v2:"), byte(v.signature))
+}
+
+// deriveKeys derives the suite's KEM and signature keypairs from master_key.
+func (v pqVaultSuite) deriveKeys(masterKey []byte) (kem.Scheme, kem.PublicKey, kem.PrivateKey, sign.Scheme, sign.PublicKey, sign.PrivateKey, error) {
+	kemScheme, err := v.kem.Scheme()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, err
+	}
+	sigScheme, err := v.signature.Scheme()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, err
+	}
+	kemInfo, sigInfo := v.seedInfo()
+
+	// Separate HKDF labels keep the KEM and signature keys independent.
+	kemSeed := make([]byte, kemScheme.SeedSize())
+	if err := hkdfExpand(masterKey, kemInfo, kemSeed); err != nil {
+		return nil, nil, nil, nil, nil, nil, fmt.Errorf("pq_vault: %s seed derivation failed: %w", v.kem, err)
+	}
+	defer WipeBytes(kemSeed)
+	sigSeed := make([]byte, sigScheme.SeedSize())
+	if err := hkdfExpand(masterKey, sigInfo, sigSeed); err != nil {
+		return nil, nil, nil, nil, nil, nil, fmt.Errorf("pq_vault: %s seed derivation failed: %w", v.signature, err)
+	}
+	defer WipeBytes(sigSeed)
+
+	kemPK, kemSK := kemScheme.DeriveKeyPair(kemSeed)
+	sigPK, sigSK := sigScheme.DeriveKey(sigSeed)
+	return kemScheme, kemPK, kemSK, sigScheme, sigPK, sigSK, nil
+}
+
 // PQVaultEncrypt encrypts plaintext using the full post-quantum pipeline:
 //
 //	Argon2id(password, fresh 32-byte salt) → 32-byte master_key
-//	HKDF(master_key, "passquantum_kyber_seed_v1")      → Kyber-768 keypair
-//	HKDF(master_key, "passquantum_dilithium_seed_v1")  → Dilithium3 keypair
-//	Kyber-768 KEM encapsulation (ephemeral)            → shared_secret + kyber_ct
+//	HKDF(master_key, "passquantum_kem_seed_v2:" ‖ id)  → ML-KEM-768 keypair
+//	HKDF(master_key, "passquantum_sig_seed_v2:" ‖ id)  → ML-DSA-65 keypair
+//	ML-KEM encapsulation (ephemeral)                   → shared_secret + kem_ct
 //	HKDF(shared_secret, "passquantum_aes_key_v1")      → 32-byte AES key
 //	HKDF(shared_secret, "passquantum_nonce_v1")        → 12-byte GCM nonce
 //	AES-256-GCM                                        → encrypted payload
-//	Dilithium3.Sign(header ‖ payload)                  → signature
+//	ML-DSA-65.Sign(header ‖ payload)                   → signature
 //
 // The returned bytes are the complete vault file ready to write to disk.
 func PQVaultEncrypt(plaintext []byte, password string) ([]byte, error) {
+	return pqVaultEncrypt(plaintext, password, pqVaultSuite{pqVaultVersion, PQVaultKEM, DefaultSignature}, CurrentVaultKDFPolicy())
+}
+
+func pqVaultEncrypt(plaintext []byte, password string, suite pqVaultSuite, cost KDFParams) ([]byte, error) {
 	// ── Step 1: Fresh 32-byte Argon2id salt per save. ─────────────────────────
 	salt := make([]byte, pqArgonSaltSize)
 	if _, err := cryptoRand.Read(salt); err != nil {
@@ -63,41 +122,25 @@ func PQVaultEncrypt(plaintext []byte, password string) ([]byte, error) {
 	// ── Step 2: Argon2id → 32-byte master_key. ────────────────────────────────
 	masterKey := argon2.IDKey(
 		[]byte(password), salt,
-		pqArgonIter, pqArgonMemKB, pqArgonThreads,
+		cost.Iterations, cost.Memory, pqArgonThreads,
 		pqMasterKeySize,
 	)
 	defer WipeBytes(masterKey)
 
-	// ── Step 3: Derive Kyber-768 seed (64 bytes) from master_key. ─────────────
-	// Domain string "passquantum_kyber_seed_v1" ensures key separation from Dilithium.
-	kyberSeed := make([]byte, kyber768.KeySeedSize)
-	if err := hkdfExpand(masterKey, []byte("passquantum_kyber_seed_v1"), kyberSeed); err != nil {
-		return nil, fmt.Errorf("pq_vault: Kyber seed derivation failed: %w", err)
-	}
-	defer WipeBytes(kyberSeed)
-
-	// ── Step 4: Derive Dilithium3 seed (32 bytes) from master_key. ────────────
-	dilSeed := make([]byte, dilithiumMode3.SeedSize)
-	if err := hkdfExpand(masterKey, []byte("passquantum_dilithium_seed_v1"), dilSeed); err != nil {
-		return nil, fmt.Errorf("pq_vault: Dilithium3 seed derivation failed: %w", err)
+	// ── Steps 3–5: Derive the KEM and signature keypairs from master_key. ─────
+	kemScheme, kemPK, _, sigScheme, _, sigSK, err := suite.deriveKeys(masterKey)
+	if err != nil {
+		return nil, err
 	}
-	defer WipeBytes(dilSeed)
-
-	// ── Step 5: Derive keypairs deterministically from seeds. ─────────────────
-	kyberPK, _ := kyber768.NewKeyFromSeed(kyberSeed)
 
-	var dilSeedArr [dilithiumMode3.SeedSize]byte
-	copy(dilSeedArr[:], dilSeed)
-	dilPK, dilSK := dilithiumMode3.NewKeyFromSeed(&dilSeedArr)
-	_ = dilPK // used only on the verify path; included here for symmetry
-
-	// ── Step 6: Ephemeral Kyber KEM encapsulation → shared_secret + kyber_ct. ─
+	// ── Step 6: Ephemeral KEM encapsulation → shared_secret + kem_ct. ─────────
 	// A fresh random encapsulation seed is used on every save; this keeps the
 	// shared_secret (and therefore the AES key) unique per ciphertext even if
 	// the password never changes.
-	kyberCT := make([]byte, kyber768.CiphertextSize)
-	sharedSecret := make([]byte, kyber768.SharedKeySize)
-	kyberPK.EncapsulateTo(kyberCT, sharedSecret, nil) // nil → random seed
+	kemCT, sharedSecret, err := kemScheme.Encapsulate(kemPK)
+	if err != nil {
+		return nil, fmt.Errorf("pq_vault: %s encapsulation failed: %w", suite.kem, err)
+	}
 	defer WipeBytes(sharedSecret)
 
 	// ── Step 7: HKDF(shared_secret) → AES-256-GCM key (32 bytes). ────────────
@@ -123,163 +166,223 @@ func PQVaultEncrypt(plaintext []byte, password string) ([]byte, error) {
 
 	// ── Step 10: Build the plaintext header. ──────────────────────────────────
 	//   4 bytes  – magic "PQVT"
-	//   1 byte   – format version (0x01)
+	//   1 byte   – format version (0x02; 0x01 files have no algorithm bytes)
+	//   1 byte   – KEM algorithm (KEMAlgorithm)
+	//   1 byte   – signature algorithm (SignatureAlgorithm)
 	//   32 bytes – Argon2id salt
 	//   4 bytes  – Argon2id iterations  (uint32 LE)
 	//   4 bytes  – Argon2id memory KB   (uint32 LE)
-	//   4 bytes  – Kyber ciphertext length (uint32 LE)
-	//   N bytes  – Kyber ciphertext (1088 bytes for Kyber-768)
+	//   4 bytes  – KEM ciphertext length (uint32 LE)
+	//   N bytes  – KEM ciphertext (1088 bytes for ML-KEM-768)
 	//   12 bytes – AES-GCM nonce
-	header := make([]byte, 0, 4+1+32+4+4+4+len(kyberCT)+12)
+	header := make([]byte, 0, 4+1+2+32+4+4+4+len(kemCT)+12)
 	header = append(header, pqVaultMagic[:]...)
-	header = append(header, pqVaultVersion)
+	header = append(header, suite.version)
+	if suite.version != pqVaultVersionLegacy {
+		header = append(header, byte(suite.kem), byte(suite.signature))
+	}
 	header = append(header, salt...)
-	header = binary.LittleEndian.AppendUint32(header, pqArgonIter)
-	header = binary.LittleEndian.AppendUint32(header, pqArgonMemKB)
-	header = binary.LittleEndian.AppendUint32(header, uint32(len(kyberCT)))
-	header = append(header, kyberCT...)
+	header = binary.LittleEndian.AppendUint32(header, cost.Iterations)
+	header = binary.LittleEndian.AppendUint32(header, cost.Memory)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(kemCT)))
+	header = append(header, kemCT...)
 	header = append(header, nonce...)
 
-	// ── Step 11: Dilithium3 signs (header ‖ payload). ─────────────────────────
+	// ── Step 11: Sign (header ‖ payload). ─────────────────────────────────────
 	// The signature covers both the plaintext header AND the encrypted payload,
 	// preventing any tampering with header fields or ciphertext bytes.
 	toSign := make([]byte, 0, len(header)+len(payload))
 	toSign = append(toSign, header...)
 	toSign = append(toSign, payload...)
-
-	var sigBuf [dilithiumMode3.SignatureSize]byte
-	dilithiumMode3.SignTo(dilSK, toSign, sigBuf[:])
+	sig := sigScheme.Sign(sigSK, toSign, nil)
 
 	// ── Step 12: Assemble final vault file: header ‖ payload ‖ sig_len ‖ sig. ─
-	sigLen := uint32(dilithiumMode3.SignatureSize)
-	out := make([]byte, 0, len(header)+len(payload)+4+dilithiumMode3.SignatureSize)
+	out := make([]byte, 0, len(header)+len(payload)+4+len(sig))
 	out = append(out, header...)
 	out = append(out, payload...)
-	out = binary.LittleEndian.AppendUint32(out, sigLen)
-	out = append(out, sigBuf[:]...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(sig)))
+	out = append(out, sig...)
 
 	return out, nil
 }
 
-// PQVaultDecrypt decrypts a PQ vault, enforcing strict verify-before-decrypt order:
-//
-//  1. Parse and validate header magic + version.
-//  2. Verify Dilithium3 signature over (header ‖ payload) — fails fast on any
-//     tampering or wrong-password scenario at the signature layer.
-//  3. Reconstruct master_key via Argon2id (password + stored salt).
-//  4. Derive Kyber-768 private key from master_key via HKDF.
-//  5. Kyber decapsulation → shared_secret.
-//  6. Derive AES-256-GCM key + nonce from shared_secret via HKDF.
-//  7. Decrypt and authenticate payload; return ErrPQAuthFailed on tag mismatch.
-func PQVaultDecrypt(data []byte, password string) ([]byte, error) {
-	// ── Step 1: Validate magic + version + minimum size. ──────────────────────
-	const minHeaderSize = 4 + 1 + 32 + 4 + 4 + 4 // magic+ver+salt+iter+mem+ctLen
-	if len(data) < minHeaderSize {
-		return nil, ErrPQInvalidHeader
+// PQVaultHeader is the structure of a PQ vault file as read from disk,
+// before any key material is derived.
+type PQVaultHeader struct {
+	Version     uint8
+	KEM         KEMAlgorithm
+	Signature   SignatureAlgorithm
+	ArgonIter   uint32
+	ArgonMemKB  uint32
+	PayloadSize int
+
+	salt      []byte
+	kemCT     []byte
+	nonce     []byte
+	header    []byte
+	payload   []byte
+	signature []byte
+}
+
+// KDFParams returns the Argon2id cost the file was sealed with.
+func (h *PQVaultHeader) KDFParams() KDFParams {
+	return KDFParams{Memory: h.ArgonMemKB, Iterations: h.ArgonIter, Parallelism: pqArgonThreads}
+}
+
+// PQVaultKDFOutdated reports whether the PQ vault file in data was sealed
+// with a weaker Argon2id cost than CurrentVaultKDFPolicy, so that it should
+// be sealed again. Files that do not parse are not outdated.
+func PQVaultKDFOutdated(data []byte) bool {
+	return pqVaultKDFBelow(data, CurrentVaultKDFPolicy())
+}
+
+func pqVaultKDFBelow(data []byte, policy KDFParams) bool {
+	h, err := ParsePQVaultHeader(data)
+	if err != nil {
+		return false
 	}
-	if data[0] != 'P' || data[1] != 'Q' || data[2] != 'V' || data[3] != 'T' {
+	return h.KDFParams().Weaker(policy)
+}
+
+// ParsePQVaultHeader checks the magic, version, algorithms and every length
+// field of a PQ vault without the password: the KEM ciphertext must be
+// exactly one ciphertext of the named KEM and the tail must hold one
+// signature of the named scheme. Authenticity still needs PQVaultDecrypt.
+func ParsePQVaultHeader(data []byte) (*PQVaultHeader, error) {
+	const minHeaderSize = 4 + 1 + 32 + 4 + 4 + 4 // magic+ver+salt+iter+mem+ctLen
+	if len(data) < minHeaderSize || !IsPQVaultFormat(data) {
 		return nil, ErrPQInvalidHeader
 	}
-	if data[4] != pqVaultVersion {
+
+	h := &PQVaultHeader{Version: data[4]}
+	idx := 5
+	switch h.Version {
+	case pqVaultVersionLegacy:
+		h.KEM, h.Signature = legacyPQVaultSuite.kem, legacyPQVaultSuite.signature
+	case pqVaultVersion:
+		if len(data) < minHeaderSize+2 {
+			return nil, ErrPQInvalidHeader
+		}
+		h.KEM, h.Signature = KEMAlgorithm(data[5]), SignatureAlgorithm(data[6])
+		idx += 2
+	default:
 		return nil, fmt.Errorf("pq_vault: unsupported version 0x%02x", data[4])
 	}
+	kemScheme, err := h.KEM.Scheme()
+	if err != nil {
+		return nil, fmt.Errorf("pq_vault: %w", err)
+	}
+	sigScheme, err := h.Signature.Scheme()
+	if err != nil {
+		return nil, fmt.Errorf("pq_vault: %w", err)
+	}
 
-	// Parse header fields.
-	idx := 5
-	salt := data[idx : idx+32]
-	idx += 32
-	argonIter := binary.LittleEndian.Uint32(data[idx : idx+4])
+	h.salt = data[idx : idx+pqArgonSaltSize]
+	idx += pqArgonSaltSize
+	h.ArgonIter = binary.LittleEndian.Uint32(data[idx : idx+4])
 	idx += 4
-	argonMemKB := binary.LittleEndian.Uint32(data[idx : idx+4])
+	h.ArgonMemKB = binary.LittleEndian.Uint32(data[idx : idx+4])
 	idx += 4
-	kyberCTLen := binary.LittleEndian.Uint32(data[idx : idx+4])
+	kemCTLen := binary.LittleEndian.Uint32(data[idx : idx+4])
 	idx += 4
+	// The cost is not authenticated until the signature is checked, which
+	// needs the derivation, so it is bounded first.
+	if h.ArgonIter < 1 || h.ArgonIter > MaxKDFIterations ||
+		h.ArgonMemKB < 8*pqArgonThreads || h.ArgonMemKB > MaxKDFMemory {
+		return nil, ErrPQInvalidHeader
+	}
 
-	if idx+int(kyberCTLen)+12 > len(data) {
+	if int(kemCTLen) != kemScheme.CiphertextSize() || idx+int(kemCTLen)+12 > len(data) {
 		return nil, ErrPQInvalidHeader
 	}
-	kyberCT := data[idx : idx+int(kyberCTLen)]
-	idx += int(kyberCTLen)
-	nonce := data[idx : idx+12]
+	h.kemCT = data[idx : idx+int(kemCTLen)]
+	idx += int(kemCTLen)
+	h.nonce = data[idx : idx+12]
 	idx += 12
-	headerEnd := idx // header boundary
+	headerEnd := idx
 
 	// Signature layout from the tail of the file:
 	//   … payload … | sig_len (4 LE) | sig (SignatureSize bytes)
-	// Because Dilithium3.SignatureSize is a fixed constant, we can find the
+	// Because each scheme's SignatureSize is a fixed constant, we can find the
 	// signature deterministically without parsing the payload length.
-	const sigSize = dilithiumMode3.SignatureSize
+	sigSize := sigScheme.SignatureSize()
 	sigFieldStart := len(data) - sigSize - 4
 	if sigFieldStart < headerEnd {
 		return nil, ErrPQInvalidHeader
 	}
-	storedSigLen := binary.LittleEndian.Uint32(data[sigFieldStart : sigFieldStart+4])
-	if int(storedSigLen) != sigSize {
+	if binary.LittleEndian.Uint32(data[sigFieldStart:sigFieldStart+4]) != uint32(sigSize) {
 		return nil, ErrPQInvalidHeader
 	}
-	sigBytes := data[len(data)-sigSize:]
-	payload := data[headerEnd:sigFieldStart]
-	header := data[:headerEnd]
+	h.signature = data[len(data)-sigSize:]
+	h.payload = data[headerEnd:sigFieldStart]
+	h.header = data[:headerEnd]
+	h.PayloadSize = len(h.payload)
+	return h, nil
+}
 
-	// ── Step 2: Derive Dilithium3 public key and verify signature
+// PQVaultDecrypt decrypts a PQ vault, enforcing strict verify-before-decrypt order:
+//
+//  1. Parse and validate header magic, version and algorithms.
+//  2. Verify the signature over (header ‖ payload) — fails fast on any
+//     tampering or wrong-password scenario at the signature layer.
+//  3. Reconstruct master_key via Argon2id (password + stored salt).
+//  4. Derive the KEM private key from master_key via HKDF.
+//  5. KEM decapsulation → shared_secret.
+//  6. Derive AES-256-GCM key + nonce from shared_secret via HKDF.
+//  7. Decrypt and authenticate payload; return ErrPQAuthFailed on tag mismatch.
+func PQVaultDecrypt(data []byte, password string) ([]byte, error) {
+	// ── Step 1: Validate magic + version + field lengths. ─────────────────────
+	h, err := ParsePQVaultHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	suite := pqVaultSuite{h.Version, h.KEM, h.Signature}
+
+	// ── Step 2: Derive the signature public key and verify the signature
 	//           BEFORE any decryption attempt. ────────────────────────────────
 	masterKey := argon2.IDKey(
-		[]byte(password), salt,
-		argonIter, argonMemKB, pqArgonThreads,
+		[]byte(password), h.salt,
+		h.ArgonIter, h.ArgonMemKB, pqArgonThreads,
 		pqMasterKeySize,
 	)
 	defer WipeBytes(masterKey)
 
-	dilSeed := make([]byte, dilithiumMode3.SeedSize)
-	if err := hkdfExpand(masterKey, []byte("passquantum_dilithium_seed_v1"), dilSeed); err != nil {
-		return nil, fmt.Errorf("pq_vault: Dilithium3 seed derivation failed: %w", err)
+	kemScheme, _, kemSK, sigScheme, sigPK, _, err := suite.deriveKeys(masterKey)
+	if err != nil {
+		return nil, err
 	}
-	defer WipeBytes(dilSeed)
 
-	var dilSeedArr [dilithiumMode3.SeedSize]byte
-	copy(dilSeedArr[:], dilSeed)
-	dilPK, _ := dilithiumMode3.NewKeyFromSeed(&dilSeedArr)
+	toVerify := make([]byte, 0, len(h.header)+len(h.payload))
+	toVerify = append(toVerify, h.header...)
+	toVerify = append(toVerify, h.payload...)
 
-	toVerify := make([]byte, 0, len(header)+len(payload))
-	toVerify = append(toVerify, header...)
-	toVerify = append(toVerify, payload...)
-
-	if !dilithiumMode3.Verify(dilPK, toVerify, sigBytes) {
+	if !sigScheme.Verify(sigPK, toVerify, h.signature, nil) {
 		// Signature mismatch: either wrong password or tampered file.
 		return nil, ErrPQSignatureInvalid
 	}
 
-	// ── Steps 3–4: Derive Kyber-768 private key from master_key. ──────────────
-	// master_key is already computed above; we now use it for the KEM path.
-	kyberSeed := make([]byte, kyber768.KeySeedSize)
-	if err := hkdfExpand(masterKey, []byte("passquantum_kyber_seed_v1"), kyberSeed); err != nil {
-		return nil, fmt.Errorf("pq_vault: Kyber seed derivation failed: %w", err)
+	// ── Steps 3–5: KEM decapsulation with the derived key → shared_secret. ────
+	sharedSecret, err := kemScheme.Decapsulate(kemSK, h.kemCT)
+	if err != nil {
+		return nil, fmt.Errorf("pq_vault: %s decapsulation failed: %w", h.KEM, err)
 	}
-	defer WipeBytes(kyberSeed)
-
-	// ── Step 5: Kyber decapsulation → shared_secret. ─────────────────────────
-	_, kyberSK := kyber768.NewKeyFromSeed(kyberSeed)
-	sharedSecret := make([]byte, kyber768.SharedKeySize)
-	kyberSK.DecapsulateTo(sharedSecret, kyberCT)
 	defer WipeBytes(sharedSecret)
 
-	// ── Step 6: Derive AES-256-GCM key + nonce from shared_secret. ────────────
+	// ── Step 6: Derive AES-256-GCM key from shared_secret. ────────────────────
 	aesKey := make([]byte, 32)
 	if err := hkdfExpand(sharedSecret, []byte("passquantum_aes_key_v1"), aesKey); err != nil {
 		return nil, fmt.Errorf("pq_vault: AES key derivation failed: %w", err)
 	}
 	defer WipeBytes(aesKey)
 
+	// ── Step 7: AES-256-GCM decrypt + authenticate. ───────────────────────────
 	// The nonce in the header is exactly the HKDF-derived nonce stored during
 	// encryption; use the stored header nonce for AES-GCM to remain byte-exact.
-	_ = nonce // referenced below
-
-	// ── Step 7: AES-256-GCM decrypt + authenticate. ───────────────────────────
 	gcm, err := NewAES256GCM(aesKey)
 	if err != nil {
 		return nil, fmt.Errorf("pq_vault: GCM init failed: %w", err)
 	}
-	plaintext, err := gcm.Open(nil, nonce, payload, nil)
+	plaintext, err := gcm.Open(nil, h.nonce, h.payload, nil)
 	if err != nil {
 		return nil, ErrPQAuthFailed
 	}
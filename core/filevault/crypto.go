@@ -1,7 +1,12 @@
 package filevault
 
 import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -11,92 +16,228 @@ import (
 
 const ChunkSize = 64 * 1024 // 64 KB
 
-// File format:
-//   [12B base_nonce][4B chunk_size LE]
-//   repeated: [4B encrypted_chunk_len LE][chunk_ciphertext + 16B GCM tag]
+// Stream formats. Blobs are written as StreamV2; StreamV1 blobs from
+// earlier releases stay readable until UpgradeBlobs rewrites them.
+const (
+	// StreamV1:
+	//   [12B base_nonce][4B chunk_size LE]
+	//   repeated: [4B encrypted_chunk_len LE][chunk_ciphertext + 16B GCM tag]
+	// Nothing marks the end of the stream, so dropping trailing chunks
+	// goes unnoticed.
+	StreamV1 = 1
+
+	// StreamV2, after STREAM as used by age:
+	//   [7B "PQFSTRM"][1B version = 2][4B chunk_size BE][16B salt][32B header MAC]
+	//   repeated: [4B encrypted_chunk_len LE][chunk_ciphertext + 16B GCM tag]
+	// The AES key and the header MAC key are derived from the file key, the
+	// salt and the fileID. Chunk i is sealed under the nonce
+	// [3B zero][8B i BE][1B last], and the stream must end with exactly one
+	// chunk whose last flag is set (empty for an empty file), so truncated,
+	// extended or reordered streams fail to decrypt.
+	StreamV2 = 2
+)
+
+const (
+	streamMagic      = "PQFSTRM"
+	streamSaltSize   = 16
+	streamMACSize    = sha256.Size
+	streamHeaderSize = len(streamMagic) + 1 + 4 + streamSaltSize + streamMACSize
+	streamKeyLabel   = "passquantum-file-stream-v2"
+	maxChunkSize     = 16 << 20
+)
+
+// chunkAADLabel leads each StreamV1 chunk's associated data when the blob
+// is bound to a fileID: label ‖ fileID ‖ [4B chunk_index BE]. A bound chunk
+// only opens in its own file at its own position. Blobs written without a
+// fileID have no associated data.
+const chunkAADLabel = "passquantum-file-chunk-v1"
 
 // EncryptFile encrypts src into dst using AES-256-GCM in 64 KB chunks.
 // sharedSecret must be exactly 32 bytes.
 func EncryptFile(src io.Reader, dst io.Writer, sharedSecret []byte) error {
-	return EncryptFileWithProgress(src, dst, sharedSecret, 0, nil)
+	return EncryptFileWithProgress(src, dst, sharedSecret, "", 0, nil)
 }
 
 // DecryptFile decrypts src into dst, reversing EncryptFile.
 func DecryptFile(src io.Reader, dst io.Writer, sharedSecret []byte) error {
-	return DecryptFileWithProgress(src, dst, sharedSecret, 0, nil)
+	return DecryptFileWithProgress(src, dst, sharedSecret, "", 0, nil)
 }
 
-// EncryptFileWithProgress encrypts with an optional progress callback.
+// EncryptFileWithProgress encrypts in the StreamV2 format with an optional
+// progress callback, binding the stream to fileID unless it is empty.
 // onProgress receives the cumulative number of plaintext bytes processed.
-func EncryptFileWithProgress(src io.Reader, dst io.Writer, sharedSecret []byte, totalSize int64, onProgress func(int64)) error {
+func EncryptFileWithProgress(src io.Reader, dst io.Writer, sharedSecret []byte, fileID string, totalSize int64, onProgress func(int64)) error {
 	if len(sharedSecret) < 32 {
 		return fmt.Errorf("filevault: shared secret must be at least 32 bytes")
 	}
 
-	gcm, err := crypto.NewAES256GCM(sharedSecret[:32])
-	if err != nil {
-		return fmt.Errorf("filevault: gcm init: %w", err)
+	salt := make([]byte, streamSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("filevault: salt generation: %w", err)
 	}
-
-	baseNonce := make([]byte, gcm.NonceSize())
-	if _, err := rand.Read(baseNonce); err != nil {
-		return fmt.Errorf("filevault: nonce generation: %w", err)
+	gcm, macKey, err := streamKeys(sharedSecret[:32], salt, fileID)
+	if err != nil {
+		return err
 	}
+	defer crypto.WipeBytes(macKey)
 
-	// Write header: base_nonce + chunk_size
-	if _, err := dst.Write(baseNonce); err != nil {
-		return fmt.Errorf("filevault: write nonce: %w", err)
-	}
-	var csLE [4]byte
-	binary.LittleEndian.PutUint32(csLE[:], ChunkSize)
-	if _, err := dst.Write(csLE[:]); err != nil {
-		return fmt.Errorf("filevault: write chunk size: %w", err)
+	header := make([]byte, 0, streamHeaderSize)
+	header = append(header, streamMagic...)
+	header = append(header, StreamV2)
+	header = binary.BigEndian.AppendUint32(header, ChunkSize)
+	header = append(header, salt...)
+	header = append(header, headerMAC(macKey, header)...)
+	if _, err := dst.Write(header); err != nil {
+		return fmt.Errorf("filevault: write header: %w", err)
 	}
 
+	in := bufio.NewReader(src)
 	buf := make([]byte, ChunkSize)
 	var processed int64
-	var chunkIdx uint32
+	var chunkIdx uint64
 
 	for {
-		n, readErr := io.ReadFull(src, buf)
-		if n > 0 {
-			nonce := deriveChunkNonce(baseNonce, chunkIdx)
-			sealed := gcm.Seal(nil, nonce, buf[:n], nil)
-
-			var lenBuf [4]byte
-			binary.LittleEndian.PutUint32(lenBuf[:], uint32(len(sealed)))
-			if _, err := dst.Write(lenBuf[:]); err != nil {
-				return fmt.Errorf("filevault: write chunk len: %w", err)
-			}
-			if _, err := dst.Write(sealed); err != nil {
-				return fmt.Errorf("filevault: write chunk: %w", err)
+		n, readErr := io.ReadFull(in, buf)
+		last := readErr == io.EOF || readErr == io.ErrUnexpectedEOF
+		if readErr != nil && !last {
+			return fmt.Errorf("filevault: read source: %w", readErr)
+		}
+		if !last {
+			// A full chunk is the last one only if nothing follows it.
+			if _, err := in.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return fmt.Errorf("filevault: read source: %w", err)
 			}
+		}
 
-			processed += int64(n)
-			chunkIdx++
-			if onProgress != nil {
-				onProgress(processed)
-			}
+		sealed := gcm.Seal(nil, streamNonce(chunkIdx, last), buf[:n], nil)
+		var lenBuf [4]byte
+		binary.LittleEndian.PutUint32(lenBuf[:], uint32(len(sealed)))
+		if _, err := dst.Write(lenBuf[:]); err != nil {
+			return fmt.Errorf("filevault: write chunk len: %w", err)
 		}
-		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
-			break
+		if _, err := dst.Write(sealed); err != nil {
+			return fmt.Errorf("filevault: write chunk: %w", err)
 		}
-		if readErr != nil {
-			return fmt.Errorf("filevault: read source: %w", readErr)
+
+		processed += int64(n)
+		chunkIdx++
+		if onProgress != nil && n > 0 {
+			onProgress(processed)
+		}
+		if last {
+			return nil
 		}
 	}
+}
 
-	return nil
+// DecryptFileWithProgress decrypts a StreamV2 or StreamV1 blob with an
+// optional progress callback. fileID must be the one the blob was
+// encrypted with. onProgress receives the cumulative number of plaintext
+// bytes written.
+func DecryptFileWithProgress(src io.Reader, dst io.Writer, sharedSecret []byte, fileID string, totalSize int64, onProgress func(int64)) error {
+	return decryptStream(src, dst, sharedSecret, fileID, fileID, true, onProgress)
 }
 
-// DecryptFileWithProgress decrypts with an optional progress callback.
-// onProgress receives the cumulative number of plaintext bytes written.
-func DecryptFileWithProgress(src io.Reader, dst io.Writer, sharedSecret []byte, totalSize int64, onProgress func(int64)) error {
+// decryptStream decrypts a StreamV2 blob bound to fileID. When allowV1 is
+// set, a blob that does not start with the StreamV2 magic is decrypted as
+// StreamV1 bound to v1FileID instead.
+func decryptStream(src io.Reader, dst io.Writer, sharedSecret []byte, fileID, v1FileID string, allowV1 bool, onProgress func(int64)) error {
 	if len(sharedSecret) < 32 {
 		return fmt.Errorf("filevault: shared secret must be at least 32 bytes")
 	}
 
-	gcm, err := crypto.NewAES256GCM(sharedSecret[:32])
+	in := bufio.NewReader(src)
+	magic, err := in.Peek(len(streamMagic) + 1)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("filevault: read header: %w", err)
+	}
+	if len(magic) < len(streamMagic)+1 || string(magic[:len(streamMagic)]) != streamMagic || magic[len(streamMagic)] != StreamV2 {
+		if !allowV1 {
+			return fmt.Errorf("filevault: not a version %d stream", StreamV2)
+		}
+		return decryptV1(in, dst, sharedSecret[:32], v1FileID, onProgress)
+	}
+	return decryptV2(in, dst, sharedSecret[:32], fileID, onProgress)
+}
+
+func decryptV2(in *bufio.Reader, dst io.Writer, key []byte, fileID string, onProgress func(int64)) error {
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return fmt.Errorf("filevault: read header: %w", err)
+	}
+	body := header[:streamHeaderSize-streamMACSize]
+	salt := body[len(body)-streamSaltSize:]
+	gcm, macKey, err := streamKeys(key, salt, fileID)
+	if err != nil {
+		return err
+	}
+	defer crypto.WipeBytes(macKey)
+	if !hmac.Equal(headerMAC(macKey, body), header[len(body):]) {
+		return fmt.Errorf("filevault: header authentication failed (wrong key or file)")
+	}
+	chunkSize := binary.BigEndian.Uint32(body[len(streamMagic)+1:])
+	if chunkSize == 0 || chunkSize > maxChunkSize {
+		return fmt.Errorf("filevault: invalid chunk size %d", chunkSize)
+	}
+
+	var processed int64
+	var chunkIdx uint64
+
+	for {
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(in, lenBuf[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return fmt.Errorf("filevault: stream truncated before its final chunk")
+			}
+			return fmt.Errorf("filevault: read chunk len: %w", err)
+		}
+
+		chunkLen := binary.LittleEndian.Uint32(lenBuf[:])
+		if chunkLen < uint32(gcm.Overhead()) || chunkLen > chunkSize+uint32(gcm.Overhead()) {
+			return fmt.Errorf("filevault: invalid chunk length %d", chunkLen)
+		}
+		sealed := make([]byte, chunkLen)
+		if _, err := io.ReadFull(in, sealed); err != nil {
+			return fmt.Errorf("filevault: stream truncated in chunk %d: %w", chunkIdx, err)
+		}
+
+		// Only the chunk that ends the stream may carry the last flag; a
+		// truncated stream ends on a chunk sealed without it.
+		_, peekErr := in.Peek(1)
+		if peekErr != nil && peekErr != io.EOF {
+			return fmt.Errorf("filevault: read stream: %w", peekErr)
+		}
+		last := peekErr == io.EOF
+		plaintext, err := gcm.Open(nil, streamNonce(chunkIdx, last), sealed, nil)
+		if err != nil {
+			if last {
+				return fmt.Errorf("filevault: decrypt chunk %d: stream truncated or corrupt", chunkIdx)
+			}
+			return fmt.Errorf("filevault: decrypt chunk %d: %w", chunkIdx, err)
+		}
+		if !last && len(plaintext) != int(chunkSize) {
+			return fmt.Errorf("filevault: chunk %d is short but not final", chunkIdx)
+		}
+
+		if _, err := dst.Write(plaintext); err != nil {
+			return fmt.Errorf("filevault: write plaintext: %w", err)
+		}
+
+		processed += int64(len(plaintext))
+		chunkIdx++
+		if onProgress != nil && len(plaintext) > 0 {
+			onProgress(processed)
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+func decryptV1(src io.Reader, dst io.Writer, key []byte, fileID string, onProgress func(int64)) error {
+	gcm, err := crypto.NewAES256GCM(key)
 	if err != nil {
 		return fmt.Errorf("filevault: gcm init: %w", err)
 	}
@@ -135,7 +276,7 @@ func DecryptFileWithProgress(src io.Reader, dst io.Writer, sharedSecret []byte,
 		}
 
 		nonce := deriveChunkNonce(baseNonce, chunkIdx)
-		plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+		plaintext, err := gcm.Open(nil, nonce, sealed, chunkAAD(fileID, chunkIdx))
 		if err != nil {
 			return fmt.Errorf("filevault: decrypt chunk %d: %w", chunkIdx, err)
 		}
@@ -154,6 +295,49 @@ func DecryptFileWithProgress(src io.Reader, dst io.Writer, sharedSecret []byte,
 	return nil
 }
 
+// streamKeys derives a StreamV2 blob's AES-256-GCM cipher and header MAC
+// key from the file key, the header salt and fileID.
+func streamKeys(key, salt []byte, fileID string) (cipher.AEAD, []byte, error) {
+	okm, err := hkdf.Key(sha256.New, key, salt, streamKeyLabel+"\x00"+fileID, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("filevault: derive stream keys: %w", err)
+	}
+	defer crypto.WipeBytes(okm[:32])
+	gcm, err := crypto.NewAES256GCM(okm[:32])
+	if err != nil {
+		return nil, nil, fmt.Errorf("filevault: gcm init: %w", err)
+	}
+	return gcm, okm[32:], nil
+}
+
+func headerMAC(macKey, header []byte) []byte {
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(header)
+	return mac.Sum(nil)
+}
+
+// streamNonce is the StreamV2 nonce of chunk chunkIdx.
+func streamNonce(chunkIdx uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], chunkIdx)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// chunkAAD returns the associated data of StreamV1 chunk chunkIdx of
+// fileID, or nil for unbound blobs.
+func chunkAAD(fileID string, chunkIdx uint32) []byte {
+	if fileID == "" {
+		return nil
+	}
+	aad := make([]byte, 0, len(chunkAADLabel)+len(fileID)+4)
+	aad = append(aad, chunkAADLabel...)
+	aad = append(aad, fileID...)
+	return binary.BigEndian.AppendUint32(aad, chunkIdx)
+}
+
 // deriveChunkNonce XORs a 32-bit chunk counter into the last 4 bytes of baseNonce.
 func deriveChunkNonce(baseNonce []byte, chunkIdx uint32) []byte {
 	nonce := make([]byte, len(baseNonce))
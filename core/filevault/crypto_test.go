@@ -2,11 +2,16 @@ package filevault
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
+	"encoding/binary"
 	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"passquantum/core/crypto"
 )
 
 func TestEncryptDecryptRoundTrip_Small(t *testing.T) {
@@ -115,6 +120,114 @@ func TestDecryptFile_WrongKey(t *testing.T) {
 	}
 }
 
+func TestDecryptFile_ChunkBinding(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	original := make([]byte, ChunkSize+10)
+	rand.Read(original)
+
+	var encrypted bytes.Buffer
+	if err := EncryptFileWithProgress(bytes.NewReader(original), &encrypted, key, "file-a", 0, nil); err != nil {
+		t.Fatalf("EncryptFileWithProgress: %v", err)
+	}
+	var decrypted bytes.Buffer
+	if err := DecryptFileWithProgress(bytes.NewReader(encrypted.Bytes()), &decrypted, key, "file-a", 0, nil); err != nil {
+		t.Fatalf("decrypt with its own ID: %v", err)
+	}
+	if !bytes.Equal(decrypted.Bytes(), original) {
+		t.Fatal("round-trip mismatch")
+	}
+
+	for name, id := range map[string]string{"another file": "file-b", "unbound": ""} {
+		err := DecryptFileWithProgress(bytes.NewReader(encrypted.Bytes()), io.Discard, key, id, 0, nil)
+		if err == nil {
+			t.Errorf("%s: bound blob decrypted", name)
+		}
+	}
+}
+
+func TestDecryptFile_TruncatedOrReordered(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	original := make([]byte, ChunkSize*2+100)
+	rand.Read(original)
+
+	var encrypted bytes.Buffer
+	if err := EncryptFileWithProgress(bytes.NewReader(original), &encrypted, key, "file-a", 0, nil); err != nil {
+		t.Fatalf("EncryptFileWithProgress: %v", err)
+	}
+	blob := encrypted.Bytes()
+	fullChunk := 4 + ChunkSize + 16
+	chunk := func(i int) []byte {
+		start := streamHeaderSize + i*fullChunk
+		return blob[start : start+fullChunk]
+	}
+	header := blob[:streamHeaderSize]
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	cases := map[string][]byte{
+		"last chunk dropped":   blob[:streamHeaderSize+2*fullChunk],
+		"two chunks dropped":   blob[:streamHeaderSize+fullChunk],
+		"cut inside a chunk":   blob[:len(blob)-5],
+		"chunks swapped":       concat(header, chunk(1), chunk(0), blob[streamHeaderSize+2*fullChunk:]),
+		"chunk repeated":       concat(header, chunk(0), chunk(0), chunk(1), blob[streamHeaderSize+2*fullChunk:]),
+		"trailing data":        concat(blob, []byte{0, 0, 0, 0}),
+		"header only":          header,
+		"header magic changed": concat([]byte("PQFSTRX"), blob[len(streamMagic):]),
+	}
+	for name, tampered := range cases {
+		if err := DecryptFileWithProgress(bytes.NewReader(tampered), io.Discard, key, "file-a", 0, nil); err == nil {
+			t.Errorf("%s: decrypted without error", name)
+		}
+	}
+}
+
+func TestDecryptFile_StreamV1(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	original := make([]byte, ChunkSize+10)
+	rand.Read(original)
+
+	for _, id := range []string{"", "file-a"} {
+		var decrypted bytes.Buffer
+		if err := DecryptFileWithProgress(bytes.NewReader(encryptV1(t, original, key, id)), &decrypted, key, id, 0, nil); err != nil {
+			t.Fatalf("decrypt v1 blob bound to %q: %v", id, err)
+		}
+		if !bytes.Equal(decrypted.Bytes(), original) {
+			t.Fatalf("v1 round-trip mismatch for %q", id)
+		}
+	}
+
+	meta := &FileMetadata{UUID: "file-a", StreamVersion: StreamV2}
+	if err := meta.decryptBlob(bytes.NewReader(encryptV1(t, original, key, "")), io.Discard, key, nil); err == nil {
+		t.Error("v2 manifest entry accepted a v1 blob")
+	}
+}
+
+// encryptV1 writes plaintext in the StreamV1 format.
+func encryptV1(t *testing.T, plaintext, key []byte, fileID string) []byte {
+	t.Helper()
+	gcm, err := crypto.NewAES256GCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseNonce := make([]byte, gcm.NonceSize())
+	rand.Read(baseNonce)
+
+	out := append([]byte(nil), baseNonce...)
+	out = binary.LittleEndian.AppendUint32(out, ChunkSize)
+	for i := uint32(0); len(plaintext) > 0; i++ {
+		n := min(len(plaintext), ChunkSize)
+		sealed := gcm.Seal(nil, deriveChunkNonce(baseNonce, i), plaintext[:n], chunkAAD(fileID, i))
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(sealed)))
+		out = append(out, sealed...)
+		plaintext = plaintext[n:]
+	}
+	return out
+}
+
 func TestEncryptFileWithProgress(t *testing.T) {
 	key := make([]byte, 32)
 	rand.Read(key)
@@ -129,7 +242,7 @@ func TestEncryptFileWithProgress(t *testing.T) {
 	}
 
 	var encrypted bytes.Buffer
-	if err := EncryptFileWithProgress(bytes.NewReader(original), &encrypted, key, size, onProgress); err != nil {
+	if err := EncryptFileWithProgress(bytes.NewReader(original), &encrypted, key, "", size, onProgress); err != nil {
 		t.Fatalf("EncryptFileWithProgress: %v", err)
 	}
 
@@ -154,7 +267,7 @@ func TestDecryptFileWithProgress(t *testing.T) {
 
 	var progressCalls []int64
 	var decrypted bytes.Buffer
-	if err := DecryptFileWithProgress(bytes.NewReader(encrypted.Bytes()), &decrypted, key, size, func(p int64) {
+	if err := DecryptFileWithProgress(bytes.NewReader(encrypted.Bytes()), &decrypted, key, "", size, func(p int64) {
 		progressCalls = append(progressCalls, p)
 	}); err != nil {
 		t.Fatalf("DecryptFileWithProgress: %v", err)
@@ -270,6 +383,116 @@ func TestManifestAddRemoveFind(t *testing.T) {
 	}
 }
 
+func TestStoreTrashRestorePurge(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	pub, priv, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore("trash-test", "store-pass", pub, priv, nil)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	src := filepath.Join(t.TempDir(), "doc.txt")
+	os.WriteFile(src, []byte("keep me"), 0600)
+	meta, err := store.StoreFile(src, nil)
+	if err != nil {
+		t.Fatalf("StoreFile: %v", err)
+	}
+
+	if err := store.TrashFile(meta.UUID); err != nil {
+		t.Fatalf("TrashFile: %v", err)
+	}
+	if len(store.ListFiles()) != 0 || len(store.ListTrash()) != 1 {
+		t.Fatalf("after trash: %d live, %d trashed", len(store.ListFiles()), len(store.ListTrash()))
+	}
+	if n, err := store.PurgeTrash(time.Now(), time.Hour); err != nil || n != 0 {
+		t.Fatalf("PurgeTrash before expiry = %d, %v", n, err)
+	}
+
+	if err := store.RestoreFile(meta.UUID); err != nil {
+		t.Fatalf("RestoreFile: %v", err)
+	}
+	if len(store.ListFiles()) != 1 {
+		t.Fatal("restored file not listed")
+	}
+
+	store.TrashFile(meta.UUID)
+	if n, err := store.PurgeTrash(time.Now().Add(2*time.Hour), time.Hour); err != nil || n != 1 {
+		t.Fatalf("PurgeTrash after expiry = %d, %v", n, err)
+	}
+	if len(store.ListTrash()) != 0 {
+		t.Error("purged file still in trash")
+	}
+	if _, err := os.Stat(filepath.Join(store.vaultDir, meta.UUID+".bin")); !os.IsNotExist(err) {
+		t.Errorf("blob not removed: %v", err)
+	}
+}
+
+func TestStoreUpgradeBlobs(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	pub, priv, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore("upgrade-test", "store-pass", pub, priv, nil)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	content := make([]byte, ChunkSize*2+7)
+	rand.Read(content)
+	src := filepath.Join(t.TempDir(), "doc.bin")
+	os.WriteFile(src, content, 0600)
+	meta, err := store.StoreFile(src, nil)
+	if err != nil {
+		t.Fatalf("StoreFile: %v", err)
+	}
+
+	// Turn the blob back into an unbound v1 blob, as older releases wrote it.
+	key, err := store.fileKey(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobPath := filepath.Join(store.vaultDir, meta.UUID+".bin")
+	os.WriteFile(blobPath, encryptV1(t, content, key, ""), 0600)
+	meta.StreamVersion = 0
+	meta.ChunksBound = false
+	if err := store.SaveManifest(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := store.UpgradeBlobs(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("UpgradeBlobs = %d, %v", n, err)
+	}
+	if n, err := store.UpgradeBlobs(context.Background()); err != nil || n != 0 {
+		t.Fatalf("second UpgradeBlobs = %d, %v", n, err)
+	}
+
+	reopened, err := NewStore("upgrade-test", "store-pass", pub, priv, nil)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	upgraded := reopened.ListFiles()[0]
+	if upgraded.StreamVersion != StreamV2 || !upgraded.ChunksBound {
+		t.Fatalf("manifest entry not upgraded: %+v", upgraded)
+	}
+	got, err := reopened.DecryptToMemory(meta.UUID)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("DecryptToMemory after upgrade: %v", err)
+	}
+	if blob, _ := os.ReadFile(blobPath); !bytes.HasPrefix(blob, []byte(streamMagic)) {
+		t.Error("blob not rewritten as v2")
+	}
+	if problems, err := reopened.Verify(); err != nil || len(problems) != 0 {
+		t.Errorf("Verify after upgrade = %v, %v", problems, err)
+	}
+}
+
 func TestTempTracker(t *testing.T) {
 	dir := t.TempDir()
 	tracker := NewTempTracker()
@@ -3,6 +3,7 @@ package filevault
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"time"
 )
 
@@ -20,7 +21,44 @@ type FileMetadata struct {
 	Size            int64     `json:"size"`
 	SHA256          string    `json:"sha256"`
 	StoredAt        time.Time `json:"stored_at"`
-	KyberCiphertext []byte    `json:"kyber_ct"`
+	KyberCiphertext []byte    `json:"kyber_ct"` // KEM ciphertext, led by its crypto.KEMAlgorithm
+	// WrappedKey, when set, is the blob's content key sealed under the
+	// KEM shared secret (nonce ‖ AES-GCM ciphertext). Files stored since
+	// the last key rotation use the shared secret directly.
+	WrappedKey []byte `json:"wrapped_key,omitempty"`
+	// ChunksBound is set for blobs whose chunks authenticate the file UUID
+	// and chunk index (see chunkAAD). Older blobs have no associated data.
+	ChunksBound bool `json:"chunks_bound,omitempty"`
+	// StreamVersion is the blob's stream format; zero means StreamV1.
+	// StreamV2 blobs are always bound to the file UUID.
+	StreamVersion int       `json:"stream_version,omitempty"`
+	Deleted       bool      `json:"deleted,omitempty"`    // in the trash
+	DeletedAt     time.Time `json:"deleted_at,omitempty"` // when it was trashed
+}
+
+// chunkBinding is the fileID to decrypt a StreamV1 blob with.
+func (f *FileMetadata) chunkBinding() string {
+	if f.ChunksBound {
+		return f.UUID
+	}
+	return ""
+}
+
+// decryptBlob decrypts the blob of f with key. A StreamV1 entry may point
+// at a blob that UpgradeBlobs already rewrote if the manifest was not saved
+// afterwards, so it accepts either format; a StreamV2 entry does not fall
+// back.
+func (f *FileMetadata) decryptBlob(src io.Reader, dst io.Writer, key []byte, onProgress func(int64)) error {
+	return decryptStream(src, dst, key, f.UUID, f.chunkBinding(), f.StreamVersion < StreamV2, onProgress)
+}
+
+// trashExpired reports whether a trashed file has been in the trash for at
+// least retention at now. A non-positive retention keeps trash forever.
+func (f *FileMetadata) trashExpired(now time.Time, retention time.Duration) bool {
+	if !f.Deleted || retention <= 0 {
+		return false
+	}
+	return !now.Before(f.DeletedAt.Add(retention))
 }
 
 func newManifest() *FileManifest {
@@ -41,6 +79,16 @@ func (m *FileManifest) remove(uuid string) bool {
 	return false
 }
 
+// replace swaps in f for the entry with the same UUID.
+func (m *FileManifest) replace(f *FileMetadata) {
+	for i, old := range m.Files {
+		if old.UUID == f.UUID {
+			m.Files[i] = f
+			return
+		}
+	}
+}
+
 func (m *FileManifest) find(uuid string) *FileMetadata {
 	for _, f := range m.Files {
 		if f.UUID == uuid {
@@ -0,0 +1,172 @@
+package filevault
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudflare/circl/kem"
+
+	"passquantum/core/crypto"
+	securestorage "passquantum/internal/storage"
+)
+
+// fileKey returns the key a blob was encrypted with: the Kyber shared
+// secret itself, or the content key it wraps after a key rotation. s.mu
+// must be held.
+func (s *Store) fileKey(meta *FileMetadata) ([]byte, error) {
+	ss, err := crypto.Decapsulate(meta.KyberCiphertext, s.privKey)
+	if err != nil {
+		return nil, fmt.Errorf("filevault: decapsulate: %w", err)
+	}
+	if len(meta.WrappedKey) == 0 {
+		return ss, nil
+	}
+	defer crypto.WipeBytes(ss)
+
+	if len(meta.WrappedKey) < 12 {
+		return nil, fmt.Errorf("filevault: wrapped key for %q is truncated", meta.UUID)
+	}
+	key, err := crypto.DecryptAES256GCM(meta.WrappedKey[:12], meta.WrappedKey[12:], ss)
+	if err != nil {
+		return nil, fmt.Errorf("filevault: unwrap file key: %w", err)
+	}
+	return []byte(key), nil
+}
+
+// RekeyedManifest returns the store's manifest path and the manifest
+// re-encrypted so that every file opens with the private key matching
+// newPub. Blobs are not rewritten: each file's content key is wrapped under
+// a fresh encapsulation instead. The store itself is left unchanged; the
+// caller writes the result, normally in the same transaction as the new
+// keypair, and then calls Rekey.
+func (s *Store) RekeyedManifest(newPub kem.PublicKey) (string, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rekeyed := &FileManifest{Version: s.manifest.Version}
+	for _, meta := range s.manifest.Files {
+		key, err := s.fileKey(meta)
+		if err != nil {
+			return "", nil, fmt.Errorf("filevault: %s: %w", meta.OriginalName, err)
+		}
+		ct, ss, err := crypto.Encapsulate(newPub)
+		if err != nil {
+			crypto.WipeBytes(key)
+			return "", nil, fmt.Errorf("filevault: encapsulate: %w", err)
+		}
+		nonce, wrapped, err := crypto.EncryptAES256GCM(string(key), ss)
+		crypto.WipeBytes(key)
+		crypto.WipeBytes(ss)
+		if err != nil {
+			return "", nil, fmt.Errorf("filevault: wrap file key: %w", err)
+		}
+
+		copied := *meta
+		copied.KyberCiphertext = ct
+		copied.WrappedKey = append(nonce, wrapped...)
+		rekeyed.add(&copied)
+	}
+
+	data, err := serializeManifest(rekeyed)
+	if err != nil {
+		return "", nil, err
+	}
+	encrypted, err := crypto.PQVaultEncrypt(data, s.password)
+	if err != nil {
+		return "", nil, fmt.Errorf("filevault: encrypt manifest: %w", err)
+	}
+	return filepath.Join(s.vaultDir, manifestFile), encrypted, nil
+}
+
+// Rekey switches an open store to a new keypair and reloads the manifest
+// that RekeyedManifest produced for it.
+func (s *Store) Rekey(pubKey kem.PublicKey, privKey kem.PrivateKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pubKey = pubKey
+	s.privKey = privKey
+	return s.loadManifest()
+}
+
+// ManifestPath returns where vaultName's store keeps its manifest, without
+// creating the store.
+func ManifestPath(vaultName string) (string, error) {
+	dir, err := storeDir(vaultName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, manifestFile), nil
+}
+
+// ReencryptManifest returns the manifest at path sealed again for
+// newPassword. Like RekeyedManifest it writes nothing: the caller stages the
+// result with the rest of the password change and then calls SetPassword
+// on an open store.
+func ReencryptManifest(path, currentPassword, newPassword string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("filevault: read manifest: %w", err)
+	}
+	plaintext, err := crypto.PQVaultDecrypt(data, currentPassword)
+	if err != nil {
+		return nil, fmt.Errorf("filevault: decrypt manifest: %w", err)
+	}
+	defer crypto.WipeBytes(plaintext)
+	encrypted, err := crypto.PQVaultEncrypt(plaintext, newPassword)
+	if err != nil {
+		return nil, fmt.Errorf("filevault: encrypt manifest: %w", err)
+	}
+	return encrypted, nil
+}
+
+// SetPassword switches an open store to a new master password and reloads
+// the manifest that ReencryptManifest produced for it.
+func (s *Store) SetPassword(password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+	return s.loadManifest()
+}
+
+// UpgradeManifestKDF seals the manifest at path again when its Argon2id cost
+// is below the current vault policy, and reports whether it did. The open
+// store's own manifest goes through Store.UpgradeKDF instead, so it cannot
+// race the store's saves.
+func UpgradeManifestKDF(path, password string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("filevault: read manifest: %w", err)
+	}
+	if !crypto.PQVaultKDFOutdated(data) {
+		return false, nil
+	}
+	encrypted, err := ReencryptManifest(path, password, password)
+	if err != nil {
+		return false, err
+	}
+	if err := securestorage.WriteFileAtomic(path, encrypted, storedFilePerms); err != nil {
+		return false, fmt.Errorf("filevault: write manifest: %w", err)
+	}
+	return true, nil
+}
+
+// UpgradeKDF saves the store's manifest again when the copy on disk is
+// sealed below the current vault policy, and reports whether it did.
+func (s *Store) UpgradeKDF() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(filepath.Join(s.vaultDir, manifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("filevault: read manifest: %w", err)
+	}
+	if !crypto.PQVaultKDFOutdated(data) {
+		return false, nil
+	}
+	return true, s.saveManifest()
+}
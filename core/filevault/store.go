@@ -10,12 +10,14 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"time"
 
-	"github.com/cloudflare/circl/kem/kyber/kyber768"
+	"github.com/cloudflare/circl/kem"
 	"github.com/google/uuid"
 
 	"passquantum/core/crypto"
+	securestorage "passquantum/internal/storage"
 )
 
 const (
@@ -25,18 +27,26 @@ const (
 	storedFilePerms = 0600
 )
 
-// Store manages encrypted file storage for a single vault.
+// Store manages encrypted file storage for a single vault. Its methods are
+// safe for concurrent use, so the background blob upgrade (see
+// StartUpgrade) can run while the UI works with the store.
 type Store struct {
 	vaultDir    string
 	password    string
-	pubKey      *kyber768.PublicKey
-	privKey     *kyber768.PrivateKey
-	manifest    *FileManifest
 	tempTracker *TempTracker
+
+	// mu guards the keys and the manifest. It is not held while blobs
+	// are encrypted or decrypted.
+	mu       sync.Mutex
+	pubKey   kem.PublicKey
+	privKey  kem.PrivateKey
+	manifest *FileManifest
+
+	upgrade *upgradeRun
 }
 
 // NewStore opens (or creates) a file store for the given vault.
-func NewStore(vaultName, password string, pubKey *kyber768.PublicKey, privKey *kyber768.PrivateKey, tracker *TempTracker) (*Store, error) {
+func NewStore(vaultName, password string, pubKey kem.PublicKey, privKey kem.PrivateKey, tracker *TempTracker) (*Store, error) {
 	baseDir, err := getFilesDir(vaultName)
 	if err != nil {
 		return nil, err
@@ -56,6 +66,12 @@ func NewStore(vaultName, password string, pubKey *kyber768.PublicKey, privKey *k
 
 // LoadManifest reads and decrypts the manifest, or creates a new one.
 func (s *Store) LoadManifest() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadManifest()
+}
+
+func (s *Store) loadManifest() error {
 	path := filepath.Join(s.vaultDir, manifestFile)
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -81,6 +97,12 @@ func (s *Store) LoadManifest() error {
 
 // SaveManifest encrypts and writes the manifest to disk.
 func (s *Store) SaveManifest() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveManifest()
+}
+
+func (s *Store) saveManifest() error {
 	data, err := serializeManifest(s.manifest)
 	if err != nil {
 		return err
@@ -92,7 +114,7 @@ func (s *Store) SaveManifest() error {
 	}
 
 	path := filepath.Join(s.vaultDir, manifestFile)
-	if err := os.WriteFile(path, encrypted, storedFilePerms); err != nil {
+	if err := securestorage.WriteFileAtomic(path, encrypted, storedFilePerms); err != nil {
 		return fmt.Errorf("filevault: write manifest: %w", err)
 	}
 	return nil
@@ -119,7 +141,10 @@ func (s *Store) StoreFile(srcPath string, onProgress func(int64)) (*FileMetadata
 	tee := io.TeeReader(src, hasher)
 
 	// Per-file Kyber KEM
-	ct, ss, err := crypto.Encapsulate(s.pubKey)
+	s.mu.Lock()
+	pubKey := s.pubKey
+	s.mu.Unlock()
+	ct, ss, err := crypto.Encapsulate(pubKey)
 	if err != nil {
 		return nil, fmt.Errorf("filevault: encapsulate: %w", err)
 	}
@@ -134,7 +159,7 @@ func (s *Store) StoreFile(srcPath string, onProgress func(int64)) (*FileMetadata
 	}
 	defer dst.Close()
 
-	if err := EncryptFileWithProgress(tee, dst, ss, info.Size(), onProgress); err != nil {
+	if err := EncryptFileWithProgress(tee, dst, ss, fileUUID, info.Size(), onProgress); err != nil {
 		os.Remove(dstPath)
 		return nil, err
 	}
@@ -154,10 +179,14 @@ func (s *Store) StoreFile(srcPath string, onProgress func(int64)) (*FileMetadata
 		SHA256:          fmt.Sprintf("%x", hasher.Sum(nil)),
 		StoredAt:        time.Now(),
 		KyberCiphertext: ct,
+		ChunksBound:     true,
+		StreamVersion:   StreamV2,
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.manifest.add(meta)
-	if err := s.SaveManifest(); err != nil {
+	if err := s.saveManifest(); err != nil {
 		os.Remove(dstPath)
 		s.manifest.remove(fileUUID)
 		return nil, err
@@ -168,14 +197,9 @@ func (s *Store) StoreFile(srcPath string, onProgress func(int64)) (*FileMetadata
 
 // RetrieveFile decrypts a stored file to dstPath.
 func (s *Store) RetrieveFile(fileUUID, dstPath string, onProgress func(int64)) error {
-	meta := s.manifest.find(fileUUID)
-	if meta == nil {
-		return fmt.Errorf("filevault: file %q not found in manifest", fileUUID)
-	}
-
-	ss, err := crypto.Decapsulate(meta.KyberCiphertext, s.privKey)
+	meta, ss, err := s.openFileKey(fileUUID)
 	if err != nil {
-		return fmt.Errorf("filevault: decapsulate: %w", err)
+		return err
 	}
 	defer crypto.WipeBytes(ss)
 
@@ -192,18 +216,40 @@ func (s *Store) RetrieveFile(fileUUID, dstPath string, onProgress func(int64)) e
 	}
 	defer dst.Close()
 
-	return DecryptFileWithProgress(src, dst, ss, meta.Size, onProgress)
+	return meta.decryptBlob(src, dst, ss, onProgress)
+}
+
+// openFileKey returns a copy of fileUUID's metadata and its content key.
+func (s *Store) openFileKey(fileUUID string) (*FileMetadata, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta := s.manifest.find(fileUUID)
+	if meta == nil {
+		return nil, nil, fmt.Errorf("filevault: file %q not found in manifest", fileUUID)
+	}
+	ss, err := s.fileKey(meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	copied := *meta
+	return &copied, ss, nil
 }
 
 // OpenFile decrypts to a temp file and opens it with the system default app.
 // Returns the temp file path (tracked for cleanup).
 func (s *Store) OpenFile(fileUUID string) (string, error) {
+	s.mu.Lock()
 	meta := s.manifest.find(fileUUID)
+	var name string
+	if meta != nil {
+		name = meta.OriginalName
+	}
+	s.mu.Unlock()
 	if meta == nil {
 		return "", fmt.Errorf("filevault: file %q not found", fileUUID)
 	}
 
-	tmpPath := TempFilePath(meta.OriginalName)
+	tmpPath := TempFilePath(name)
 	if err := s.RetrieveFile(fileUUID, tmpPath, nil); err != nil {
 		return "", err
 	}
@@ -222,14 +268,9 @@ func (s *Store) OpenFile(fileUUID string) (string, error) {
 // DecryptToMemory decrypts a stored file entirely into memory and returns
 // the plaintext bytes. Use only for small files (e.g. image thumbnails).
 func (s *Store) DecryptToMemory(fileUUID string) ([]byte, error) {
-	meta := s.manifest.find(fileUUID)
-	if meta == nil {
-		return nil, fmt.Errorf("filevault: file %q not found in manifest", fileUUID)
-	}
-
-	ss, err := crypto.Decapsulate(meta.KyberCiphertext, s.privKey)
+	meta, ss, err := s.openFileKey(fileUUID)
 	if err != nil {
-		return nil, fmt.Errorf("filevault: decapsulate: %w", err)
+		return nil, err
 	}
 	defer crypto.WipeBytes(ss)
 
@@ -241,14 +282,48 @@ func (s *Store) DecryptToMemory(fileUUID string) ([]byte, error) {
 	defer src.Close()
 
 	var buf bytes.Buffer
-	if err := DecryptFile(src, &buf, ss); err != nil {
+	if err := meta.decryptBlob(src, &buf, ss, nil); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
 
+// TrashFile moves a stored file to the trash. The encrypted blob is kept so
+// the file can be restored until it is deleted or purged.
+func (s *Store) TrashFile(fileUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta := s.manifest.find(fileUUID)
+	if meta == nil {
+		return fmt.Errorf("filevault: file %q not found in manifest", fileUUID)
+	}
+	meta.Deleted = true
+	meta.DeletedAt = time.Now()
+	return s.saveManifest()
+}
+
+// RestoreFile takes a file back out of the trash.
+func (s *Store) RestoreFile(fileUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta := s.manifest.find(fileUUID)
+	if meta == nil {
+		return fmt.Errorf("filevault: file %q not found in manifest", fileUUID)
+	}
+	meta.Deleted = false
+	meta.DeletedAt = time.Time{}
+	return s.saveManifest()
+}
+
 // DeleteFile securely removes an encrypted file and its manifest entry.
+// This is permanent; use TrashFile for a recoverable delete.
 func (s *Store) DeleteFile(fileUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleteFile(fileUUID)
+}
+
+func (s *Store) deleteFile(fileUUID string) error {
 	meta := s.manifest.find(fileUUID)
 	if meta == nil {
 		return fmt.Errorf("filevault: file %q not found in manifest", fileUUID)
@@ -262,19 +337,58 @@ func (s *Store) DeleteFile(fileUUID string) error {
 		return err
 	}
 
-	return s.SaveManifest()
+	return s.saveManifest()
 }
 
-// ListFiles returns all file metadata in the manifest.
+// PurgeTrash permanently deletes every trashed file that has been in the
+// trash for at least retention, returning how many were removed. A
+// non-positive retention purges nothing.
+func (s *Store) PurgeTrash(now time.Time, retention time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []string
+	for _, f := range s.manifest.Files {
+		if f.trashExpired(now, retention) {
+			expired = append(expired, f.UUID)
+		}
+	}
+	for i, id := range expired {
+		if err := s.deleteFile(id); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+// ListFiles returns the metadata of every file that is not in the trash.
 func (s *Store) ListFiles() []*FileMetadata {
+	return s.listFiles(false)
+}
+
+// ListTrash returns the metadata of every trashed file.
+func (s *Store) ListTrash() []*FileMetadata {
+	return s.listFiles(true)
+}
+
+func (s *Store) listFiles(deleted bool) []*FileMetadata {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.manifest == nil {
 		return nil
 	}
-	return s.manifest.Files
+	var out []*FileMetadata
+	for _, f := range s.manifest.Files {
+		if f.Deleted == deleted {
+			out = append(out, f)
+		}
+	}
+	return out
 }
 
-// Close flushes the manifest and cleans up temp files.
+// Close stops the background upgrade, flushes the manifest and cleans up
+// temp files.
 func (s *Store) Close() {
+	s.StopUpgrade()
 	_ = s.SaveManifest()
 	if s.tempTracker != nil {
 		s.tempTracker.CleanupAll()
@@ -283,18 +397,26 @@ func (s *Store) Close() {
 }
 
 func getFilesDir(vaultName string) (string, error) {
-	configDir, err := os.UserConfigDir()
+	dir, err := storeDir(vaultName)
 	if err != nil {
-		return "", fmt.Errorf("filevault: config dir: %w", err)
+		return "", err
 	}
-
-	dir := filepath.Join(configDir, "passquantum", "files", vaultName)
 	if err := os.MkdirAll(dir, filesDirPerms); err != nil {
 		return "", fmt.Errorf("filevault: create files dir: %w", err)
 	}
 	return dir, nil
 }
 
+// storeDir returns the store directory of vaultName; an empty name gives
+// the directory holding every store.
+func storeDir(vaultName string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("filevault: config dir: %w", err)
+	}
+	return filepath.Join(configDir, "passquantum", "files", vaultName), nil
+}
+
 func openWithSystem(path string) error {
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
@@ -0,0 +1,209 @@
+package filevault
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"passquantum/core/crypto"
+)
+
+// upgradeSuffix names the temporary blob UpgradeBlobs writes next to the
+// one it replaces.
+const upgradeSuffix = ".upgrade"
+
+type upgradeRun struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartUpgrade runs UpgradeBlobs in the background until it is done or
+// StopUpgrade or Close is called. It does nothing while an upgrade is
+// already running.
+func (s *Store) StartUpgrade() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.upgrade != nil {
+		select {
+		case <-s.upgrade.done:
+		default:
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &upgradeRun{cancel: cancel, done: make(chan struct{})}
+	s.upgrade = run
+	go func() {
+		defer close(run.done)
+		n, err := s.UpgradeBlobs(ctx)
+		if n > 0 {
+			log.Printf("[FileVault] upgraded %d file(s) to stream format v%d", n, StreamV2)
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("[FileVault] WARNING: blob upgrade incomplete: %v", err)
+		}
+	}()
+}
+
+// StopUpgrade cancels a background upgrade and waits for it to return, so
+// the caller can replace the manifest on disk without the upgrade writing
+// over it.
+func (s *Store) StopUpgrade() {
+	s.mu.Lock()
+	run := s.upgrade
+	s.upgrade = nil
+	s.mu.Unlock()
+	if run == nil {
+		return
+	}
+	run.cancel()
+	<-run.done
+}
+
+// UpgradeBlobs rewrites every StreamV1 blob in the StreamV2 format, bound to
+// its file UUID, and returns how many it rewrote. Each blob is re-encrypted
+// under its existing content key into a temporary file, checked against the
+// manifest's SHA-256 and renamed over the old blob before the manifest is
+// updated, so an interruption at any point leaves a readable blob. A file
+// that cannot be upgraded is skipped and reported in the returned error.
+func (s *Store) UpgradeBlobs(ctx context.Context) (int, error) {
+	leftovers, _ := filepath.Glob(filepath.Join(s.vaultDir, "*.bin"+upgradeSuffix))
+	for _, path := range leftovers {
+		_ = os.Remove(path)
+	}
+
+	s.mu.Lock()
+	var pending []string
+	for _, f := range s.manifest.Files {
+		if f.StreamVersion < StreamV2 {
+			pending = append(pending, f.UUID)
+		}
+	}
+	s.mu.Unlock()
+
+	upgraded := 0
+	var errs []error
+	for _, id := range pending {
+		if err := ctx.Err(); err != nil {
+			return upgraded, err
+		}
+		done, err := s.upgradeBlob(ctx, id)
+		if errors.Is(err, context.Canceled) {
+			return upgraded, err
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("filevault: upgrade %s: %w", id, err))
+			continue
+		}
+		if done {
+			upgraded++
+		}
+	}
+	return upgraded, errors.Join(errs...)
+}
+
+// upgradeBlob rewrites one blob and reports whether it did. A file that was
+// deleted, replaced or upgraded in the meantime is left alone.
+func (s *Store) upgradeBlob(ctx context.Context, fileUUID string) (bool, error) {
+	meta, key, err := s.openFileKey(fileUUID)
+	if err != nil {
+		return false, err
+	}
+	defer crypto.WipeBytes(key)
+	if meta.StreamVersion >= StreamV2 {
+		return false, nil
+	}
+
+	blobPath := filepath.Join(s.vaultDir, fileUUID+".bin")
+	tmpPath := blobPath + upgradeSuffix
+	if err := s.reencryptBlob(ctx, meta, key, blobPath, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.manifest.find(fileUUID)
+	if err := ctx.Err(); err != nil || current == nil || current.StreamVersion >= StreamV2 || current.SHA256 != meta.SHA256 {
+		os.Remove(tmpPath)
+		return false, err
+	}
+	if err := os.Rename(tmpPath, blobPath); err != nil {
+		os.Remove(tmpPath)
+		return false, fmt.Errorf("replace blob: %w", err)
+	}
+
+	// Replace rather than modify the entry: callers of ListFiles may still
+	// be reading the old one.
+	updated := *current
+	updated.StreamVersion = StreamV2
+	updated.ChunksBound = true
+	s.manifest.replace(&updated)
+	if err := s.saveManifest(); err != nil {
+		// The StreamV1 entry still opens the rewritten blob.
+		s.manifest.replace(current)
+		return false, err
+	}
+	return true, nil
+}
+
+// reencryptBlob decrypts blobPath and writes it to tmpPath as StreamV2,
+// failing unless the plaintext matches meta's size and SHA-256.
+func (s *Store) reencryptBlob(ctx context.Context, meta *FileMetadata, key []byte, blobPath, tmpPath string) error {
+	src, err := os.Open(blobPath)
+	if err != nil {
+		return fmt.Errorf("open blob: %w", err)
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, storedFilePerms)
+	if err != nil {
+		return fmt.Errorf("create upgraded blob: %w", err)
+	}
+	defer dst.Close()
+
+	hasher := sha256.New()
+	counter := &countingWriter{w: hasher}
+	pr, pw := io.Pipe()
+	decrypted := make(chan error, 1)
+	go func() {
+		err := meta.decryptBlob(&ctxReader{ctx: ctx, r: src}, io.MultiWriter(pw, counter), key, nil)
+		pw.CloseWithError(err)
+		decrypted <- err
+	}()
+	err = EncryptFileWithProgress(pr, dst, key, meta.UUID, meta.Size, nil)
+	pr.Close()
+	if decErr := <-decrypted; decErr != nil {
+		return decErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if sum := fmt.Sprintf("%x", hasher.Sum(nil)); sum != meta.SHA256 || counter.n != meta.Size {
+		return fmt.Errorf("decrypted content does not match the manifest")
+	}
+	if err := dst.Sync(); err != nil {
+		return fmt.Errorf("sync upgraded blob: %w", err)
+	}
+	return dst.Close()
+}
+
+// ctxReader fails reads once ctx is cancelled, so a long re-encryption
+// stops promptly.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,169 @@
+package filevault
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"passquantum/core/crypto"
+)
+
+// Kinds of StoreProblem.
+const (
+	ProblemMissingBlob  = "missing_blob"  // listed in the manifest, no .bin on disk
+	ProblemOrphanBlob   = "orphan_blob"   // .bin on disk, not in the manifest
+	ProblemHashMismatch = "hash_mismatch" // decrypts, but not to the recorded SHA-256
+	ProblemUnreadable   = "unreadable"    // cannot be decapsulated or decrypted
+)
+
+// StoreProblem is one disagreement between the manifest and the blobs.
+type StoreProblem struct {
+	Kind   string
+	UUID   string
+	Name   string // original file name; empty for orphans
+	Detail string
+}
+
+// Verify compares the manifest with the .bin blobs in the store directory.
+// Every listed blob is decrypted in full and hashed, so this reads the
+// whole store.
+func (s *Store) Verify() ([]StoreProblem, error) {
+	dir, err := os.ReadDir(s.vaultDir)
+	if err != nil {
+		return nil, fmt.Errorf("filevault: read store directory: %w", err)
+	}
+	onDisk := map[string]bool{}
+	for _, f := range dir {
+		if id, ok := strings.CutSuffix(f.Name(), ".bin"); ok && !f.IsDir() {
+			onDisk[id] = true
+		}
+	}
+
+	s.mu.Lock()
+	files := make([]FileMetadata, len(s.manifest.Files))
+	for i, meta := range s.manifest.Files {
+		files[i] = *meta
+	}
+	s.mu.Unlock()
+
+	var problems []StoreProblem
+	for i := range files {
+		meta := &files[i]
+		if !onDisk[meta.UUID] {
+			problems = append(problems, StoreProblem{Kind: ProblemMissingBlob, UUID: meta.UUID, Name: meta.OriginalName})
+			continue
+		}
+		delete(onDisk, meta.UUID)
+		if p := s.verifyBlob(meta); p != nil {
+			problems = append(problems, *p)
+		}
+	}
+	for id := range onDisk {
+		problems = append(problems, StoreProblem{Kind: ProblemOrphanBlob, UUID: id})
+	}
+	return problems, nil
+}
+
+func (s *Store) verifyBlob(meta *FileMetadata) *StoreProblem {
+	fail := func(kind, format string, args ...any) *StoreProblem {
+		return &StoreProblem{Kind: kind, UUID: meta.UUID, Name: meta.OriginalName, Detail: fmt.Sprintf(format, args...)}
+	}
+
+	s.mu.Lock()
+	ss, err := s.fileKey(meta)
+	s.mu.Unlock()
+	if err != nil {
+		return fail(ProblemUnreadable, "%v", err)
+	}
+	defer crypto.WipeBytes(ss)
+
+	src, err := os.Open(filepath.Join(s.vaultDir, meta.UUID+".bin"))
+	if err != nil {
+		return fail(ProblemUnreadable, "open: %v", err)
+	}
+	defer src.Close()
+
+	hasher := sha256.New()
+	counter := &countingWriter{w: hasher}
+	if err := meta.decryptBlob(src, counter, ss, nil); err != nil {
+		return fail(ProblemUnreadable, "%v", err)
+	}
+	if sum := fmt.Sprintf("%x", hasher.Sum(nil)); sum != meta.SHA256 {
+		return fail(ProblemHashMismatch, "sha256 %s, manifest has %s", sum, meta.SHA256)
+	}
+	if counter.n != meta.Size {
+		return fail(ProblemHashMismatch, "%d bytes, manifest has %d", counter.n, meta.Size)
+	}
+	return nil
+}
+
+// ForgetFile removes a manifest entry without touching the blob. It is the
+// repair for ProblemMissingBlob, where DeleteFile has nothing to delete.
+func (s *Store) ForgetFile(fileUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.manifest.remove(fileUUID) {
+		return fmt.Errorf("filevault: file %q not found in manifest", fileUUID)
+	}
+	return s.saveManifest()
+}
+
+// RemoveOrphan securely deletes a blob that the manifest does not list.
+func (s *Store) RemoveOrphan(fileUUID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.manifest.find(fileUUID) != nil {
+		return fmt.Errorf("filevault: file %q is in the manifest", fileUUID)
+	}
+	if strings.ContainsAny(fileUUID, `/\`) {
+		return fmt.Errorf("filevault: invalid file id %q", fileUUID)
+	}
+	return SecureDelete(filepath.Join(s.vaultDir, fileUUID+".bin"))
+}
+
+// StoreExists reports whether vaultName has a file store directory, without
+// creating one.
+func StoreExists(vaultName string) bool {
+	dir, err := storeDir(vaultName)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+// ListStores returns the names of every vault with a file store directory.
+func ListStores() ([]string, error) {
+	base, err := storeDir("")
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := os.ReadDir(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("filevault: list stores: %w", err)
+	}
+	var names []string
+	for _, d := range dirs {
+		if d.IsDir() {
+			names = append(names, d.Name())
+		}
+	}
+	return names, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/csv"
 	"errors"
 	"io"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -69,6 +71,16 @@ func getCSVCol(record []string, idx map[string]int, name string) string {
 	return strings.TrimSpace(record[i])
 }
 
+// parseUnixMillis converts a millisecond Unix timestamp column into a time,
+// returning the zero time for blank or malformed values.
+func parseUnixMillis(raw string) time.Time {
+	ms, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms).UTC()
+}
+
 // hasUTF8 cheaply checks that a byte slice is valid UTF-8 to avoid
 // reporting binary garbage as a parse error.
 func hasUTF8(b []byte) bool {
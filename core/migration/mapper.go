@@ -3,9 +3,11 @@ package migration
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/cloudflare/circl/kem/kyber/kyber768"
+	"github.com/cloudflare/circl/kem"
 
 	"passquantum/core/crypto"
 	"passquantum/core/model"
@@ -31,7 +33,7 @@ type cardPayload struct {
 }
 
 // MapAndEncrypt converts a slice of parsed ImportedEntry values into
-// model.VaultEntry values, encrypting each payload with a fresh Kyber +
+// model.VaultEntry values, encrypting each payload with a fresh KEM +
 // AES-256-GCM envelope identical to the one used by manual entry creation.
 //
 // existing is the current vault contents; it is used both for duplicate
@@ -42,11 +44,10 @@ type cardPayload struct {
 // was kept, skipped or replaced.
 //
 // result.Warnings collects human-readable, non-secret hints about data that
-// could not be stored (e.g. password entries whose notes were diverted into
-// companion note entries).
+// could not be stored (e.g. an embedded TOTP secret that failed validation).
 func MapAndEncrypt(
 	entries []ImportedEntry,
-	pubKey *kyber768.PublicKey,
+	pubKey kem.PublicKey,
 	existing []*model.VaultEntry,
 	dupAction DuplicateAction,
 ) (*MapResult, error) {
@@ -54,9 +55,15 @@ func MapAndEncrypt(
 		return nil, fmt.Errorf("migration: nil public key")
 	}
 	result := &MapResult{}
+	now := time.Now().UTC()
+	s := &sealer{pubKey: pubKey, existing: existing, replace: dupAction == DupReplace}
 
 	for i := range entries {
-		built, err := buildVaultEntries(&entries[i], pubKey, result)
+		built, err := buildVaultEntries(&entries[i], s, result)
+		for _, ve := range built {
+			applyImportedTimes(ve, &entries[i])
+			applyOrganization(ve, &entries[i])
+		}
 
 		// Unconditionally wipe secrets we copied or touched.
 		wipeSecrets(&entries[i])
@@ -76,9 +83,18 @@ func MapAndEncrypt(
 					result.Skipped++
 					continue
 				case DupReplace:
+					// Keep the secret being replaced: password entries
+					// archive it into their history instead of losing it.
+					if dup.Type == model.EntryTypePassword {
+						dup.ArchivePassword(now)
+					} else {
+						dup.Touch(now)
+					}
 					dup.KyberCiphertext = ve.KyberCiphertext
 					dup.Nonce = ve.Nonce
 					dup.Ciphertext = ve.Ciphertext
+					dup.CryptoVersion = ve.CryptoVersion
+					mergeOrganization(dup, ve)
 					result.Replaced++
 					continue
 				case DupKeepBoth:
@@ -93,29 +109,29 @@ func MapAndEncrypt(
 }
 
 // buildVaultEntries produces one or more VaultEntries from an ImportedEntry.
-// A password entry that also carries extras (notes/extra URLs/custom fields)
-// or a TOTP secret yields multiple entries: the password, an optional
-// companion note, and an optional TOTP entry.
-func buildVaultEntries(entry *ImportedEntry, pubKey *kyber768.PublicKey, result *MapResult) ([]*model.VaultEntry, error) {
+// A password entry that also carries a TOTP secret yields two entries: the
+// password (with its URLs, notes and custom fields in the structured payload)
+// and a separate TOTP entry for the authenticator view.
+func buildVaultEntries(entry *ImportedEntry, s *sealer, result *MapResult) ([]*model.VaultEntry, error) {
 	entry.URLs = DedupURLs(entry.URLs)
 
 	switch entry.Type {
 	case model.EntryTypePassword, model.EntryTypeUnknown:
-		return buildPasswordWithExtras(entry, pubKey, result)
+		return buildPasswordWithExtras(entry, s, result)
 	case model.EntryTypeNote:
-		ve, err := buildNote(entry, pubKey)
+		ve, err := buildNote(entry, s)
 		if err != nil {
 			return nil, err
 		}
 		return []*model.VaultEntry{ve}, nil
 	case model.EntryTypeCard:
-		ve, err := buildCard(entry, pubKey)
+		ve, err := buildCard(entry, s)
 		if err != nil {
 			return nil, err
 		}
 		return []*model.VaultEntry{ve}, nil
 	case model.EntryTypeTOTP:
-		ve, err := buildTOTP(entry, pubKey)
+		ve, err := buildTOTP(entry, s)
 		if err != nil {
 			return nil, err
 		}
@@ -125,71 +141,49 @@ func buildVaultEntries(entry *ImportedEntry, pubKey *kyber768.PublicKey, result
 	}
 }
 
-// buildPasswordWithExtras encrypts the password as a raw string payload
-// (preserving compatibility with the existing UI decoder, which treats the
-// plaintext as the password itself) and then emits companion entries when
-// the imported record carries additional data:
-//
-//   - A separate EntryTypeNote when there are notes, extra URLs, a folder
-//     name or custom fields. The note title is "<service> — notes" so it
-//     groups visually next to its password.
-//   - A separate EntryTypeTOTP when a TOTP secret was attached to the login.
-func buildPasswordWithExtras(entry *ImportedEntry, pubKey *kyber768.PublicKey, result *MapResult) ([]*model.VaultEntry, error) {
-	out := make([]*model.VaultEntry, 0, 3)
+// buildPasswordWithExtras encrypts a login as a single structured
+// model.PasswordPayload: the password, the primary URL as the login URL, any
+// further URLs, the notes and every custom field, hidden ones included. An
+// embedded TOTP secret still becomes its own EntryTypeTOTP record so it shows
+// up in the authenticator view.
+func buildPasswordWithExtras(entry *ImportedEntry, s *sealer, result *MapResult) ([]*model.VaultEntry, error) {
+	out := make([]*model.VaultEntry, 0, 2)
 
 	title := DeriveTitle(entry.Title, entry.URLs, entry.Username)
 	service := DeriveServiceName(title, entry.URLs)
 
-	if len(entry.Password) > 0 {
-		// Keep the payload as the raw password string so the existing UI
-		// can render it without any decoder changes.
-		ve, err := encryptEntry(pubKey, entry.Password)
+	payload := model.NewPasswordPayload(string(entry.Password))
+	if len(entry.URLs) > 0 {
+		payload.LoginURL = entry.URLs[0]
+		payload.URLs = append([]string(nil), entry.URLs[1:]...)
+	}
+	payload.Notes = strings.TrimSpace(entry.Notes)
+	payload.Fields = buildCustomFields(entry.Fields, entry.HiddenFields)
+
+	if len(entry.Password) > 0 || len(payload.URLs) > 0 || payload.Notes != "" || len(payload.Fields) > 0 {
+		plain, err := payload.Marshal()
+		payload.Password = ""
+		wipeCustomFields(payload.Fields)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("password marshal: %w", err)
 		}
+		ve := model.NewVaultEntry()
 		ve.Type = model.EntryTypePassword
 		ve.Service = service
 		ve.Username = strings.TrimSpace(entry.Username)
-		out = append(out, ve)
-	}
-
-	// Collect everything we cannot store on the password itself into a
-	// companion note. The primary URL is already represented in the service
-	// name, so only the *extra* URLs are folded in.
-	extraURLs := entry.URLs
-	if len(extraURLs) > 0 {
-		extraURLs = extraURLs[1:]
-	}
-	notesPlain := BuildNotesPayload(entry.Notes, extraURLs, entry.Folder, entry.Fields)
-
-	if notesPlain != "" {
-		noteTitle := title + " — notes"
-		payload, err := json.Marshal(notePayload{
-			Type:    "note",
-			Title:   noteTitle,
-			Content: notesPlain,
-		})
-		if err == nil {
-			ve, err := encryptEntry(pubKey, payload)
-			crypto.WipeBytes(payload)
-			if err == nil {
-				ve.Type = model.EntryTypeNote
-				ve.Service = "NOTE:" + noteTitle
-				ve.Username = "note"
-				out = append(out, ve)
-				if result != nil {
-					result.Warnings = append(result.Warnings,
-						"created companion note for entry: "+title)
-				}
-			}
+		err = s.seal(ve, plain)
+		crypto.WipeBytes(plain)
+		if err != nil {
+			return nil, err
 		}
+		out = append(out, ve)
 	}
 
 	// Embedded TOTP becomes its own EntryTypeTOTP record.
 	if strings.TrimSpace(entry.TOTP) != "" {
 		totpEntry := *entry
 		totpEntry.Type = model.EntryTypeTOTP
-		ve, err := buildTOTP(&totpEntry, pubKey)
+		ve, err := buildTOTP(&totpEntry, s)
 		if err != nil {
 			if result != nil {
 				result.Warnings = append(result.Warnings,
@@ -206,12 +200,70 @@ func buildPasswordWithExtras(entry *ImportedEntry, pubKey *kyber768.PublicKey, r
 	return out, nil
 }
 
-func buildNote(entry *ImportedEntry, pubKey *kyber768.PublicKey) (*model.VaultEntry, error) {
+// buildCustomFields merges visible and hidden importer fields into a
+// name-sorted slice so the encrypted payload is deterministic.
+func buildCustomFields(visible map[string]string, hidden map[string][]byte) []model.CustomField {
+	if len(visible) == 0 && len(hidden) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(visible)+len(hidden))
+	for name := range visible {
+		names = append(names, name)
+	}
+	for name := range hidden {
+		if _, ok := visible[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	out := make([]model.CustomField, 0, len(names))
+	for _, name := range names {
+		if v, ok := hidden[name]; ok {
+			out = append(out, model.CustomField{Name: name, Value: string(v), Hidden: true})
+			continue
+		}
+		out = append(out, model.CustomField{Name: name, Value: visible[name]})
+	}
+	return out
+}
+
+// maskHiddenFields returns visible plus a "(hidden value)" placeholder for
+// every hidden field name, for payloads that have no notion of a concealed
+// field.
+func maskHiddenFields(visible map[string]string, hidden map[string][]byte) map[string]string {
+	if len(hidden) == 0 {
+		return visible
+	}
+	out := make(map[string]string, len(visible)+len(hidden))
+	for k, v := range visible {
+		out[k] = v
+	}
+	for k := range hidden {
+		out[k] = "(hidden value)"
+	}
+	return out
+}
+
+// wipeCustomFields drops references to hidden field values once they have
+// been marshalled. Go strings are immutable, so this only shortens their
+// lifetime; the []byte originals are wiped by wipeSecrets.
+func wipeCustomFields(fields []model.CustomField) {
+	for i := range fields {
+		if fields[i].Hidden {
+			fields[i].Value = ""
+		}
+	}
+}
+
+func buildNote(entry *ImportedEntry, s *sealer) (*model.VaultEntry, error) {
 	title := DeriveTitle(entry.Title, entry.URLs, entry.Username)
 
-	// Append extras (URLs, folder, custom fields) to the visible content so
-	// the importer never silently drops information.
-	content := BuildNotesPayload(entry.Notes, entry.URLs, entry.Folder, entry.Fields)
+	// Append extras (URLs, custom fields) to the visible content so the
+	// importer never silently drops information. Hidden field values are not
+	// copied into the note body; only their names are recorded. The folder
+	// lives on the entry itself.
+	content := BuildNotesPayload(entry.Notes, entry.URLs, "", maskHiddenFields(entry.Fields, entry.HiddenFields))
 	if strings.TrimSpace(content) == "" {
 		return nil, fmt.Errorf("empty note content")
 	}
@@ -225,18 +277,19 @@ func buildNote(entry *ImportedEntry, pubKey *kyber768.PublicKey) (*model.VaultEn
 		return nil, fmt.Errorf("note marshal: %w", err)
 	}
 
-	ve, err := encryptEntry(pubKey, payload)
+	ve := model.NewVaultEntry()
+	ve.Type = model.EntryTypeNote
+	ve.Service = "NOTE:" + title
+	ve.Username = "note"
+	err = s.seal(ve, payload)
 	crypto.WipeBytes(payload)
 	if err != nil {
 		return nil, err
 	}
-	ve.Type = model.EntryTypeNote
-	ve.Service = "NOTE:" + title
-	ve.Username = "note"
 	return ve, nil
 }
 
-func buildCard(entry *ImportedEntry, pubKey *kyber768.PublicKey) (*model.VaultEntry, error) {
+func buildCard(entry *ImportedEntry, s *sealer) (*model.VaultEntry, error) {
 	if entry.Card == nil {
 		return nil, fmt.Errorf("card data missing")
 	}
@@ -259,21 +312,22 @@ func buildCard(entry *ImportedEntry, pubKey *kyber768.PublicKey) (*model.VaultEn
 		return nil, fmt.Errorf("card marshal: %w", err)
 	}
 
-	ve, err := encryptEntry(pubKey, payload)
+	ve := model.NewVaultEntry()
+	ve.Type = model.EntryTypeCard
+	ve.CardSubtype = subtype
+	ve.Service = "CARD:" + title
+	ve.Username = subtype
+	err = s.seal(ve, payload)
 	crypto.WipeBytes(payload)
 	crypto.WipeBytes(entry.Card.Number)
 	crypto.WipeBytes(entry.Card.CVV)
 	if err != nil {
 		return nil, err
 	}
-	ve.Type = model.EntryTypeCard
-	ve.CardSubtype = subtype
-	ve.Service = "CARD:" + title
-	ve.Username = subtype
 	return ve, nil
 }
 
-func buildTOTP(entry *ImportedEntry, pubKey *kyber768.PublicKey) (*model.VaultEntry, error) {
+func buildTOTP(entry *ImportedEntry, s *sealer) (*model.VaultEntry, error) {
 	raw := strings.TrimSpace(entry.TOTP)
 	if raw == "" {
 		return nil, fmt.Errorf("missing TOTP secret")
@@ -312,36 +366,82 @@ func buildTOTP(entry *ImportedEntry, pubKey *kyber768.PublicKey) (*model.VaultEn
 		return nil, fmt.Errorf("totp serialize: %w", err)
 	}
 
-	ve, err := encryptEntry(pubKey, payload)
+	ve := model.NewVaultEntry()
+	ve.Type = model.EntryTypeTOTP
+	ve.Service = "TOTP:" + params.Issuer
+	ve.Username = params.Account
+	err = s.seal(ve, payload)
 	crypto.WipeBytes(payload)
 	if err != nil {
 		return nil, err
 	}
-	ve.Type = model.EntryTypeTOTP
-	ve.Service = "TOTP:" + params.Issuer
-	ve.Username = params.Account
 	return ve, nil
 }
 
-// encryptEntry performs the standard per-entry Kyber + AES-256-GCM envelope
-// and returns a VaultEntry populated with the resulting crypto fields. The
-// caller is responsible for setting Type, Service, Username and CardSubtype.
-func encryptEntry(pubKey *kyber768.PublicKey, plaintext []byte) (*model.VaultEntry, error) {
-	ct, ss, err := crypto.Encapsulate(pubKey)
+// applyImportedTimes carries the source manager's creation and modification
+// times onto ve when the exporter provided them.
+func applyImportedTimes(ve *model.VaultEntry, entry *ImportedEntry) {
+	if !entry.Created.IsZero() {
+		ve.Created = entry.Created.UTC()
+	}
+	if !entry.Modified.IsZero() {
+		ve.Modified = entry.Modified.UTC()
+	}
+}
+
+// applyOrganization copies the folder path, tags and favorite flag onto ve.
+func applyOrganization(ve *model.VaultEntry, entry *ImportedEntry) {
+	ve.Folder = model.NormalizeFolderPath(entry.Folder)
+	ve.Tags = model.NormalizeTags(entry.Tags)
+	ve.Favorite = entry.Favorite
+}
+
+// mergeOrganization fills in organization metadata on an existing entry
+// being replaced by an import, without discarding what the user already set:
+// the folder is only taken when the entry has none, tags are unioned and the
+// favorite flag is sticky.
+func mergeOrganization(dst, src *model.VaultEntry) {
+	if dst.Folder == "" {
+		dst.Folder = src.Folder
+	}
+	dst.Tags = model.NormalizeTags(append(append([]string(nil), dst.Tags...), src.Tags...))
+	dst.Favorite = dst.Favorite || src.Favorite
+}
+
+// sealer performs the standard per-entry KEM + AES-256-GCM envelope. The
+// entry's Type, Service, Username and CardSubtype are authenticated as
+// associated data, so builders set them before sealing. When duplicates are
+// replaced in place, a payload is bound to the existing entry it is about
+// to be copied onto rather than to the freshly built one.
+type sealer struct {
+	pubKey   kem.PublicKey
+	existing []*model.VaultEntry
+	replace  bool
+}
+
+// seal encrypts plaintext and stores the resulting crypto fields on ve.
+func (s *sealer) seal(ve *model.VaultEntry, plaintext []byte) error {
+	owner := ve
+	if s.replace {
+		if dup := findDuplicate(s.existing, ve.Type, ve.Service, ve.Username); dup != nil {
+			owner = dup
+		}
+	}
+	ct, ss, err := crypto.Encapsulate(s.pubKey)
 	if err != nil {
-		return nil, fmt.Errorf("kyber encapsulate: %w", err)
+		return fmt.Errorf("kem encapsulate: %w", err)
 	}
-	nonce, ciphertext, err := crypto.EncryptAES256GCM(string(plaintext), ss)
+	nonce, ciphertext, err := crypto.EncryptAES256GCMWithAAD(string(plaintext), ss, owner.AssociatedData(model.CurrentEntryCrypto))
 	crypto.WipeBytes(ss)
 	if err != nil {
-		return nil, fmt.Errorf("aes encrypt: %w", err)
+		return fmt.Errorf("aes encrypt: %w", err)
 	}
 
-	ve := model.NewVaultEntry()
 	ve.KyberCiphertext = ct
 	ve.Nonce = nonce
 	ve.Ciphertext = ciphertext
-	return ve, nil
+	ve.CryptoVersion = model.CurrentEntryCrypto
+	return nil
 }
 
 // findDuplicate is a local copy of app.FindDuplicateEntry's logic, kept here
@@ -418,6 +518,10 @@ func serviceCompareKeys(service string, entryType model.EntryType) []string {
 func wipeSecrets(e *ImportedEntry) {
 	crypto.WipeBytes(e.Password)
 	e.Password = nil
+	for name, v := range e.HiddenFields {
+		crypto.WipeBytes(v)
+		delete(e.HiddenFields, name)
+	}
 	if e.Card != nil {
 		crypto.WipeBytes(e.Card.Number)
 		crypto.WipeBytes(e.Card.CVV)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 
 	"passquantum/core/crypto"
 	"passquantum/core/model"
@@ -44,18 +45,22 @@ func TestMapper_PasswordOnly(t *testing.T) {
 		t.Errorf("username = %q", ve.Username)
 	}
 
-	// Round-trip: decrypt the payload and verify it matches the original
-	// password verbatim (no JSON wrapping for password-only entries).
+	// Round-trip: decrypt the structured payload and verify the password
+	// and login URL survived.
 	ss, err := crypto.Decapsulate(ve.KyberCiphertext, priv)
 	if err != nil {
 		t.Fatalf("decap: %v", err)
 	}
-	plain, err := crypto.DecryptAES256GCM(ve.Nonce, ve.Ciphertext, ss)
+	plain, err := crypto.DecryptAES256GCMWithAAD(ve.Nonce, ve.Ciphertext, ss, ve.AssociatedData(ve.CryptoVersion))
 	if err != nil {
 		t.Fatalf("decrypt: %v", err)
 	}
-	if plain != "hunter2!" {
-		t.Errorf("plaintext = %q", plain)
+	payload := model.ParsePasswordPayload(plain)
+	if payload.Password != "hunter2!" {
+		t.Errorf("password = %q", payload.Password)
+	}
+	if payload.LoginURL != "https://github.com" {
+		t.Errorf("login url = %q", payload.LoginURL)
 	}
 }
 
@@ -82,7 +87,7 @@ func TestMapper_PasswordSecretIsWipedAfterMap(t *testing.T) {
 	}
 }
 
-func TestMapper_PasswordWithNotesEmitsCompanion(t *testing.T) {
+func TestMapper_PasswordWithNotesStaysSingleEntry(t *testing.T) {
 	pub, priv, err := crypto.GenerateKeypair()
 	if err != nil {
 		t.Fatalf("keys: %v", err)
@@ -96,6 +101,7 @@ func TestMapper_PasswordWithNotesEmitsCompanion(t *testing.T) {
 			URLs:     []string{"https://github.com", "https://github.com/settings"},
 			Notes:    "Recovery codes: abc-def",
 			Folder:   "Dev",
+			Fields:   map[string]string{"Team": "core"},
 			Source:   "test",
 		},
 	}
@@ -103,30 +109,107 @@ func TestMapper_PasswordWithNotesEmitsCompanion(t *testing.T) {
 	if err != nil {
 		t.Fatalf("map: %v", err)
 	}
-	if len(result.NewEntries) != 2 {
-		t.Fatalf("expected 2 entries (password + companion note), got %d", len(result.NewEntries))
+	if len(result.NewEntries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(result.NewEntries))
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", result.Warnings)
 	}
 
-	var note *model.VaultEntry
-	for _, e := range result.NewEntries {
-		if e.Type == model.EntryTypeNote {
-			note = e
+	ve := result.NewEntries[0]
+	if ve.Type != model.EntryTypePassword {
+		t.Fatalf("type = %v", ve.Type)
+	}
+	ss, _ := crypto.Decapsulate(ve.KyberCiphertext, priv)
+	plain, _ := crypto.DecryptAES256GCMWithAAD(ve.Nonce, ve.Ciphertext, ss, ve.AssociatedData(ve.CryptoVersion))
+	payload := model.ParsePasswordPayload(plain)
+	if payload.Password != "ghpw" {
+		t.Errorf("password = %q", payload.Password)
+	}
+	if payload.LoginURL != "https://github.com" {
+		t.Errorf("login url = %q", payload.LoginURL)
+	}
+	if len(payload.URLs) != 1 || payload.URLs[0] != "https://github.com/settings" {
+		t.Errorf("urls = %v", payload.URLs)
+	}
+	if !strings.Contains(payload.Notes, "Recovery codes") {
+		t.Errorf("notes missing user notes: %q", payload.Notes)
+	}
+	if strings.Contains(payload.Notes, "Folder:") {
+		t.Errorf("folder leaked into notes: %q", payload.Notes)
+	}
+	if ve.Folder != "Dev" {
+		t.Errorf("folder = %q", ve.Folder)
+	}
+	if len(payload.Fields) != 1 || payload.Fields[0].Name != "Team" || payload.Fields[0].Value != "core" {
+		t.Errorf("fields = %+v", payload.Fields)
+	}
+}
+
+func TestMapper_HiddenFieldsAreConcealedAndWiped(t *testing.T) {
+	pub, priv, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
+	}
+	pin := []byte("4242")
+	entries := []ImportedEntry{{
+		Type:         model.EntryTypePassword,
+		Title:        "Bank",
+		Username:     "alice",
+		Password:     []byte("bankpw"),
+		HiddenFields: map[string][]byte{"PIN": pin},
+	}}
+	result, err := MapAndEncrypt(entries, pub, nil, DupSkip)
+	if err != nil {
+		t.Fatalf("map: %v", err)
+	}
+	if len(result.NewEntries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(result.NewEntries))
+	}
+	ve := result.NewEntries[0]
+	ss, _ := crypto.Decapsulate(ve.KyberCiphertext, priv)
+	plain, _ := crypto.DecryptAES256GCMWithAAD(ve.Nonce, ve.Ciphertext, ss, ve.AssociatedData(ve.CryptoVersion))
+	payload := model.ParsePasswordPayload(plain)
+	if len(payload.Fields) != 1 {
+		t.Fatalf("fields = %+v", payload.Fields)
+	}
+	if f := payload.Fields[0]; f.Name != "PIN" || f.Value != "4242" || !f.Hidden {
+		t.Errorf("hidden field = %+v", f)
+	}
+	for _, b := range pin {
+		if b != 0 {
+			t.Fatalf("hidden field bytes not wiped: %q", string(pin))
 		}
 	}
-	if note == nil {
-		t.Fatal("missing companion note")
+}
+
+func TestMapper_NoteMasksHiddenFields(t *testing.T) {
+	pub, priv, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
 	}
-	ss, _ := crypto.Decapsulate(note.KyberCiphertext, priv)
-	plain, _ := crypto.DecryptAES256GCM(note.Nonce, note.Ciphertext, ss)
+	entries := []ImportedEntry{{
+		Type:         model.EntryTypeNote,
+		Title:        "Wifi",
+		Notes:        "Router in the hallway",
+		HiddenFields: map[string][]byte{"Key": []byte("s3cret")},
+	}}
+	result, err := MapAndEncrypt(entries, pub, nil, DupSkip)
+	if err != nil {
+		t.Fatalf("map: %v", err)
+	}
+	ve := result.NewEntries[0]
+	ss, _ := crypto.Decapsulate(ve.KyberCiphertext, priv)
+	plain, _ := crypto.DecryptAES256GCMWithAAD(ve.Nonce, ve.Ciphertext, ss, ve.AssociatedData(ve.CryptoVersion))
 	var parsed notePayload
 	if err := json.Unmarshal([]byte(plain), &parsed); err != nil {
 		t.Fatalf("note payload not JSON: %v", err)
 	}
-	if !strings.Contains(parsed.Content, "Recovery codes") {
-		t.Errorf("note content missing user notes: %q", parsed.Content)
+	if strings.Contains(parsed.Content, "s3cret") {
+		t.Errorf("hidden value leaked into note: %q", parsed.Content)
 	}
-	if !strings.Contains(parsed.Content, "Folder: Dev") {
-		t.Errorf("note content missing folder: %q", parsed.Content)
+	if !strings.Contains(parsed.Content, "Key: (hidden value)") {
+		t.Errorf("note missing hidden placeholder: %q", parsed.Content)
 	}
 }
 
@@ -167,7 +250,7 @@ func TestMapper_PasswordWithEmbeddedTOTPSplits(t *testing.T) {
 	}
 	// Decrypted TOTP payload should be a JSON document with the secret.
 	ss, _ := crypto.Decapsulate(totp.KyberCiphertext, priv)
-	plain, _ := crypto.DecryptAES256GCM(totp.Nonce, totp.Ciphertext, ss)
+	plain, _ := crypto.DecryptAES256GCMWithAAD(totp.Nonce, totp.Ciphertext, ss, totp.AssociatedData(totp.CryptoVersion))
 	if !strings.Contains(plain, "JBSWY3DPEHPK3PXP") {
 		t.Errorf("totp payload missing secret")
 	}
@@ -209,7 +292,7 @@ func TestMapper_Card(t *testing.T) {
 		t.Errorf("subtype = %q", ve.CardSubtype)
 	}
 	ss, _ := crypto.Decapsulate(ve.KyberCiphertext, priv)
-	plain, _ := crypto.DecryptAES256GCM(ve.Nonce, ve.Ciphertext, ss)
+	plain, _ := crypto.DecryptAES256GCMWithAAD(ve.Nonce, ve.Ciphertext, ss, ve.AssociatedData(ve.CryptoVersion))
 	if !strings.Contains(plain, "4111111111111111") {
 		t.Errorf("card payload missing number")
 	}
@@ -278,14 +361,80 @@ func TestMapper_DupReplaceRewritesCrypto(t *testing.T) {
 	if len(result.NewEntries) != 0 {
 		t.Errorf("expected 0 new entries, got %d", len(result.NewEntries))
 	}
-	// Existing entry's crypto fields must have been overwritten.
+	// Existing entry's crypto fields must have been overwritten, with the
+	// previous version archived into the password history.
 	if string(existing[0].Ciphertext) == "old-payload" {
 		t.Error("existing ciphertext was not replaced")
 	}
+	if len(existing[0].PasswordHistory) != 1 || string(existing[0].PasswordHistory[0].Ciphertext) != "old-payload" {
+		t.Errorf("previous secret not archived: %+v", existing[0].PasswordHistory)
+	}
+	if existing[0].Modified.IsZero() {
+		t.Error("modified time not stamped on replace")
+	}
 	// Decryption of the rewritten entry yields the new password.
 	ss, _ := crypto.Decapsulate(existing[0].KyberCiphertext, priv)
-	plain, _ := crypto.DecryptAES256GCM(existing[0].Nonce, existing[0].Ciphertext, ss)
-	if plain != "newpw" {
-		t.Errorf("decrypted plaintext = %q, want newpw", plain)
+	plain, _ := crypto.DecryptAES256GCMWithAAD(existing[0].Nonce, existing[0].Ciphertext, ss, existing[0].AssociatedData(existing[0].CryptoVersion))
+	if got := model.ParsePasswordPayload(plain).Password; got != "newpw" {
+		t.Errorf("decrypted password = %q, want newpw", got)
+	}
+}
+
+func TestMapper_CarriesImportedTimestamps(t *testing.T) {
+	pub, _, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
+	}
+	created := time.Date(2021, 5, 4, 10, 0, 0, 0, time.UTC)
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries := []ImportedEntry{{
+		Type:     model.EntryTypePassword,
+		Title:    "Example",
+		Password: []byte("pw"),
+		Created:  created,
+		Modified: modified,
+	}}
+	result, err := MapAndEncrypt(entries, pub, nil, DupSkip)
+	if err != nil {
+		t.Fatalf("map: %v", err)
+	}
+	ve := result.NewEntries[0]
+	if !ve.Created.Equal(created) || !ve.Modified.Equal(modified) {
+		t.Errorf("times = %v / %v", ve.Created, ve.Modified)
+	}
+}
+
+func TestMapper_CarriesFolderTagsFavorite(t *testing.T) {
+	pub, _, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
+	}
+	entries := []ImportedEntry{{
+		Type:     model.EntryTypePassword,
+		Title:    "Gmail",
+		Username: "alice@example.com",
+		Password: []byte("gmailpw"),
+		TOTP:     "JBSWY3DPEHPK3PXP",
+		Folder:   " Personal / Mail ",
+		Tags:     []string{"email", "Email", " google "},
+		Favorite: true,
+	}}
+	result, err := MapAndEncrypt(entries, pub, nil, DupSkip)
+	if err != nil {
+		t.Fatalf("map: %v", err)
+	}
+	if len(result.NewEntries) != 2 {
+		t.Fatalf("expected password + totp, got %d", len(result.NewEntries))
+	}
+	for _, ve := range result.NewEntries {
+		if ve.Folder != "Personal/Mail" {
+			t.Errorf("type %v folder = %q", ve.Type, ve.Folder)
+		}
+		if len(ve.Tags) != 2 || ve.Tags[0] != "email" || ve.Tags[1] != "google" {
+			t.Errorf("type %v tags = %v", ve.Type, ve.Tags)
+		}
+		if !ve.Favorite {
+			t.Errorf("type %v lost favorite flag", ve.Type)
+		}
 	}
 }
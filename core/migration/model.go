@@ -79,11 +79,16 @@ type ImportedEntry struct {
 	// Free-form
 	Notes string
 
-	// Optional extras that VaultEntry cannot natively hold; the mapper folds
-	// these into the encrypted Notes payload or discards them with a warning.
-	Folder       string
-	Tags         []string
+	// Organization. Folder is a "/"-separated path; the mapper normalizes
+	// it and the tags onto every VaultEntry built from this record.
+	Folder   string
+	Tags     []string
+	Favorite bool
+
+	// Optional extras. Password entries carry URLs, notes and fields inside
+	// their structured payload; other types fold them into the note body.
 	Fields       map[string]string
+	HiddenFields map[string][]byte // SECRET — concealed custom fields; wipe after use
 	Created      time.Time
 	Modified     time.Time
 	Source       string // importer ID that produced this entry
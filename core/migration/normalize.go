@@ -5,6 +5,8 @@ import (
 	"net/url"
 	"sort"
 	"strings"
+
+	"passquantum/core/urlmatch"
 )
 
 // NormalizeURL ensures a URL string has a scheme and is syntactically valid.
@@ -26,22 +28,10 @@ func NormalizeURL(raw string) string {
 }
 
 // DomainOf returns the bare host (without scheme, port, www. prefix or path)
-// of a URL string. Returns "" if the input has no recognizable host.
+// of a URL string, parsed by urlmatch.Host like browser lookups. Returns ""
+// if the input has no recognizable host.
 func DomainOf(raw string) string {
-	raw = strings.TrimSpace(raw)
-	if raw == "" {
-		return ""
-	}
-	if !strings.Contains(raw, "://") {
-		raw = "https://" + raw
-	}
-	u, err := url.Parse(raw)
-	if err != nil {
-		return ""
-	}
-	host := u.Hostname()
-	host = strings.TrimPrefix(strings.ToLower(host), "www.")
-	return host
+	return urlmatch.Host(raw)
 }
 
 // DedupURLs normalizes and de-duplicates a URL list, preserving first-seen
@@ -108,10 +98,11 @@ func DeriveServiceName(title string, urls []string) string {
 	return fmt.Sprintf("%s (%s)", t, domain)
 }
 
-// BuildNotesPayload assembles the encrypted notes content for a password
-// entry. It folds extra URLs, custom fields and the original folder name into
-// the plaintext so the information is preserved even though VaultEntry has no
-// dedicated columns for them. The order is stable so round-trips are clean.
+// BuildNotesPayload assembles the encrypted body of an imported secure note.
+// It folds URLs, custom fields and, when given, a folder name into the
+// plaintext for note types that have no structured slot for them. The mapper
+// passes an empty folder now that folders live on the entry. The order is
+// stable so round-trips are clean.
 func BuildNotesPayload(notes string, urls []string, folder string, fields map[string]string) string {
 	var b strings.Builder
 	if n := strings.TrimSpace(notes); n != "" {
@@ -69,6 +69,7 @@ type onePuxItem struct {
 	Overview     onePuxOverview  `json:"overview"`
 	Details      onePuxDetails   `json:"details"`
 	TrashedTime  int64           `json:"trashed"`
+	FavIndex     int64           `json:"favIndex"`
 }
 
 type onePuxOverview struct {
@@ -183,9 +184,10 @@ func onePuxConvertItem(item *onePuxItem, folder string) (ImportedEntry, bool) {
 	base := ImportedEntry{
 		Title:  strings.TrimSpace(item.Overview.Title),
 		Notes:  strings.TrimSpace(item.Details.NotesPlain),
-		Folder: folder,
-		Tags:   item.Overview.Tags,
-		Source: "1password_1pux",
+		Folder:   folder,
+		Tags:     item.Overview.Tags,
+		Favorite: item.FavIndex > 0,
+		Source:   "1password_1pux",
 	}
 	if u := strings.TrimSpace(item.Overview.URL); u != "" {
 		base.URLs = []string{u}
@@ -209,6 +211,9 @@ func onePuxConvertItem(item *onePuxItem, folder string) (ImportedEntry, bool) {
 		if extras := flattenOnePuxSections(item.Details.Sections); len(extras) > 0 {
 			base.Fields = extras
 		}
+		// Concealed values override their placeholders in the structured
+		// password payload.
+		base.HiddenFields = concealedOnePuxFields(item.Details.Sections)
 		return base, true
 
 	case "003": // Secure Note
@@ -331,6 +336,33 @@ func flattenOnePuxSections(sections []onePuxSection) map[string]string {
 	return out
 }
 
+// concealedOnePuxFields returns the concealed section values keyed the same
+// way as flattenOnePuxSections, or nil when there are none.
+func concealedOnePuxFields(sections []onePuxSection) map[string][]byte {
+	var out map[string][]byte
+	for _, s := range sections {
+		for _, f := range s.Fields {
+			key := strings.TrimSpace(f.Title)
+			if key == "" {
+				key = strings.TrimSpace(f.ID)
+			}
+			raw, ok := f.Value["concealed"]
+			if key == "" || !ok {
+				continue
+			}
+			var v string
+			if err := json.Unmarshal(raw, &v); err != nil {
+				continue
+			}
+			if out == nil {
+				out = map[string][]byte{}
+			}
+			out[key] = []byte(v)
+		}
+	}
+	return out
+}
+
 // cardFromSections recognizes a credit-card item by scanning the standard
 // 1Password section fields (cardholder / number / expiry / cvv). Any field
 // it cannot map is dropped on the floor; the section flattener separately
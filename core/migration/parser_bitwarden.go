@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"passquantum/core/model"
 )
@@ -59,6 +60,7 @@ func (BitwardenCSVImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult, e
 		name := getCSVCol(row, idx, "name")
 		notes := getCSVCol(row, idx, "notes")
 		folder := getCSVCol(row, idx, "folder")
+		favorite := getCSVCol(row, idx, "favorite") == "1"
 
 		switch t {
 		case "note", "secure_note":
@@ -67,11 +69,12 @@ func (BitwardenCSVImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult, e
 				continue
 			}
 			result.Entries = append(result.Entries, ImportedEntry{
-				Type:   model.EntryTypeNote,
-				Title:  name,
-				Notes:  notes,
-				Folder: folder,
-				Source: "bitwarden_csv",
+				Type:     model.EntryTypeNote,
+				Title:    name,
+				Notes:    notes,
+				Folder:   folder,
+				Favorite: favorite,
+				Source:   "bitwarden_csv",
 			})
 		default:
 			username := getCSVCol(row, idx, "login_username")
@@ -96,6 +99,7 @@ func (BitwardenCSVImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult, e
 				TOTP:     totp,
 				Notes:    notes,
 				Folder:   folder,
+				Favorite: favorite,
 				Source:   "bitwarden_csv",
 			})
 		}
@@ -122,6 +126,8 @@ type bitwardenJSONItem struct {
 	Notes    string                 `json:"notes"`
 	FolderID string                 `json:"folderId"`
 	Favorite bool                   `json:"favorite"`
+	Created  string                 `json:"creationDate"`
+	Revised  string                 `json:"revisionDate"`
 	Fields   []bitwardenJSONField   `json:"fields"`
 	Login    *bitwardenJSONLogin    `json:"login,omitempty"`
 	Card     *bitwardenJSONCard     `json:"card,omitempty"`
@@ -209,7 +215,8 @@ func (BitwardenJSONImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult,
 	result := &ImportResult{}
 	for _, item := range data.Items {
 		folder := folderByID[item.FolderID]
-		fields := flattenBWFields(item.Fields)
+		fields, hidden := flattenBWFields(item.Fields)
+		created, modified := parseBWTime(item.Created), parseBWTime(item.Revised)
 
 		switch item.Type {
 		case 1: // login
@@ -224,16 +231,20 @@ func (BitwardenJSONImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult,
 				}
 			}
 			result.Entries = append(result.Entries, ImportedEntry{
-				Type:     model.EntryTypePassword,
-				Title:    item.Name,
-				Username: item.Login.Username,
-				Password: []byte(item.Login.Password),
-				URLs:     urls,
-				TOTP:     item.Login.TOTP,
-				Notes:    item.Notes,
-				Folder:   folder,
-				Fields:   fields,
-				Source:   "bitwarden_json",
+				Type:         model.EntryTypePassword,
+				Title:        item.Name,
+				Username:     item.Login.Username,
+				Password:     []byte(item.Login.Password),
+				URLs:         urls,
+				TOTP:         item.Login.TOTP,
+				Notes:        item.Notes,
+				Folder:       folder,
+				Fields:       fields,
+				HiddenFields: hidden,
+				Favorite:     item.Favorite,
+				Created:      created,
+				Modified:     modified,
+				Source:       "bitwarden_json",
 			})
 
 		case 2: // secure note
@@ -242,12 +253,16 @@ func (BitwardenJSONImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult,
 				continue
 			}
 			result.Entries = append(result.Entries, ImportedEntry{
-				Type:   model.EntryTypeNote,
-				Title:  item.Name,
-				Notes:  item.Notes,
-				Folder: folder,
-				Fields: fields,
-				Source: "bitwarden_json",
+				Type:         model.EntryTypeNote,
+				Title:        item.Name,
+				Notes:        item.Notes,
+				Folder:       folder,
+				Fields:       fields,
+				HiddenFields: hidden,
+				Favorite:     item.Favorite,
+				Created:      created,
+				Modified:     modified,
+				Source:       "bitwarden_json",
 			})
 
 		case 3: // card
@@ -256,12 +271,16 @@ func (BitwardenJSONImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult,
 				continue
 			}
 			result.Entries = append(result.Entries, ImportedEntry{
-				Type:   model.EntryTypeCard,
-				Title:  item.Name,
-				Notes:  item.Notes,
-				Folder: folder,
-				Fields: fields,
-				Source: "bitwarden_json",
+				Type:         model.EntryTypeCard,
+				Title:        item.Name,
+				Notes:        item.Notes,
+				Folder:       folder,
+				Fields:       fields,
+				HiddenFields: hidden,
+				Favorite:     item.Favorite,
+				Created:      created,
+				Modified:     modified,
+				Source:       "bitwarden_json",
 				Card: &CardData{
 					Subtype:  "credit",
 					Brand:    item.Card.Brand,
@@ -291,11 +310,14 @@ func (BitwardenJSONImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult,
 				"Country: " + id.Country,
 			}, "\n")
 			result.Entries = append(result.Entries, ImportedEntry{
-				Type:   model.EntryTypeNote,
-				Title:  item.Name,
-				Notes:  content + "\n\n" + item.Notes,
-				Folder: folder,
-				Source: "bitwarden_json",
+				Type:     model.EntryTypeNote,
+				Title:    item.Name,
+				Notes:    content + "\n\n" + item.Notes,
+				Folder:   folder,
+				Favorite: item.Favorite,
+				Created:  created,
+				Modified: modified,
+				Source:   "bitwarden_json",
 			})
 
 		default:
@@ -305,24 +327,37 @@ func (BitwardenJSONImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult,
 	return result, nil
 }
 
-func flattenBWFields(fields []bitwardenJSONField) map[string]string {
+// parseBWTime parses Bitwarden's RFC 3339 creationDate / revisionDate,
+// returning the zero time when the field is absent or malformed.
+func parseBWTime(raw string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(raw))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func flattenBWFields(fields []bitwardenJSONField) (map[string]string, map[string][]byte) {
 	if len(fields) == 0 {
-		return nil
+		return nil, nil
 	}
 	out := make(map[string]string, len(fields))
+	var hidden map[string][]byte
 	for _, f := range fields {
 		if f.Name == "" {
 			continue
 		}
 		// type 1 in Bitwarden's schema is a hidden / password-style field.
-		// We do not include those values verbatim to avoid creating a second
-		// plaintext copy of a secret inside a separate note: we just record
-		// that a hidden field existed.
+		// Those values are kept apart as secrets so the mapper can store
+		// them as concealed fields instead of plain text.
 		if f.Type == 1 {
-			out[f.Name] = "(hidden value)"
+			if hidden == nil {
+				hidden = map[string][]byte{}
+			}
+			hidden[f.Name] = []byte(f.Value)
 			continue
 		}
 		out[f.Name] = f.Value
 	}
-	return out
+	return out, hidden
 }
@@ -70,6 +70,8 @@ func (FirefoxImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult, error)
 			Username: username,
 			Password: []byte(password),
 			URLs:     urls,
+			Created:  parseUnixMillis(getCSVCol(row, idx, "timecreated")),
+			Modified: parseUnixMillis(getCSVCol(row, idx, "timepasswordchanged")),
 			Source:   "firefox_csv",
 		}
 		result.Entries = append(result.Entries, entry)
@@ -3,6 +3,7 @@ package migration
 import (
 	"errors"
 	"io"
+	"strings"
 
 	"passquantum/core/model"
 )
@@ -53,7 +54,7 @@ func (KeePassImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult, error)
 		urlStr := getCSVCol(row, idx, "url")
 		notes := getCSVCol(row, idx, "notes")
 		totp := getCSVCol(row, idx, "totp")
-		group := getCSVCol(row, idx, "group")
+		group := keepassGroupPath(getCSVCol(row, idx, "group"))
 
 		if password == "" && username == "" && notes == "" && totp == "" {
 			result.Skipped++
@@ -73,8 +74,29 @@ func (KeePassImporter) Parse(r io.Reader, _ ParseOptions) (*ImportResult, error)
 			TOTP:     totp,
 			Notes:    notes,
 			Folder:   group,
+			Tags:     splitKeePassTags(getCSVCol(row, idx, "tags")),
 			Source:   "keepass_csv",
 		})
 	}
 	return result, nil
 }
+
+// keepassGroupPath turns KeePassXC's group column ("Root/Internet/Shops")
+// into a folder path. Every path starts with the database's root group,
+// which carries no information, so it is dropped.
+func keepassGroupPath(group string) string {
+	group = model.NormalizeFolderPath(group)
+	if i := strings.Index(group, model.FolderSeparator); i >= 0 {
+		return group[i+1:]
+	}
+	return ""
+}
+
+// splitKeePassTags splits the optional Tags column, which KeePassXC writes
+// as a comma- or semicolon-separated list.
+func splitKeePassTags(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	return strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ';' })
+}
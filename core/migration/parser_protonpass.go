@@ -197,9 +197,13 @@ func protonPassConvertItem(item *protonPassItem, folder string) (ImportedEntry,
 		common.TOTP = c.TOTPURI
 
 		// Custom fields are stored in extraFields with various types.
-		if extras := flattenProtonExtraFields(item.Data.Extra); len(extras) > 0 {
+		extras, hidden := flattenProtonExtraFields(item.Data.Extra)
+		if len(extras) > 0 {
 			common.Fields = extras
 		}
+		if len(hidden) > 0 {
+			common.HiddenFields = hidden
+		}
 		return common, true
 
 	case "note":
@@ -249,17 +253,16 @@ func protonPassConvertItem(item *protonPassItem, folder string) (ImportedEntry,
 	}
 }
 
-// flattenProtonExtraFields walks Proton's extraFields[] and copies every
-// non-secret value into a flat map[string]string so the mapper can fold it
-// into the encrypted notes payload. Hidden fields (type == "hidden") are
-// preserved as "(hidden value)" rather than copied verbatim, mirroring the
-// Bitwarden handling — we do not want to make a second plaintext copy of a
-// secret in a non-secret field.
-func flattenProtonExtraFields(extras []protonPassExtraField) map[string]string {
+// flattenProtonExtraFields walks Proton's extraFields[] and splits them into
+// plain values and secret ones. Hidden and TOTP fields go into the second
+// map as []byte so the mapper can store them as concealed custom fields and
+// wipe the originals afterwards.
+func flattenProtonExtraFields(extras []protonPassExtraField) (map[string]string, map[string][]byte) {
 	if len(extras) == 0 {
-		return nil
+		return nil, nil
 	}
 	out := make(map[string]string, len(extras))
+	var hidden map[string][]byte
 	for _, f := range extras {
 		name := strings.TrimSpace(f.FieldName)
 		if name == "" {
@@ -277,14 +280,17 @@ func flattenProtonExtraFields(extras []protonPassExtraField) map[string]string {
 		}
 		switch strings.ToLower(f.Type) {
 		case "hidden", "totp":
-			out[name] = "(hidden value)"
+			if hidden == nil {
+				hidden = map[string][]byte{}
+			}
+			hidden[name] = []byte(value)
 		default:
 			if value != "" {
 				out[name] = value
 			}
 		}
 	}
-	return out
+	return out, hidden
 }
 
 // splitProtonExpiry handles Proton's "MM/YY", "MM/YYYY" and "YYYY-MM" forms.
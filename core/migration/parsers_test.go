@@ -180,6 +180,10 @@ func TestKeePassParser(t *testing.T) {
 	if !strings.HasPrefix(gmail.TOTP, "otpauth://") {
 		t.Errorf("expected otpauth URI, got %q", gmail.TOTP)
 	}
+	// The database root group is dropped from the folder path.
+	if res.Entries[0].Folder != "Web" {
+		t.Errorf("folder = %q, want Web", res.Entries[0].Folder)
+	}
 }
 
 func TestNordPassParser_Mixed(t *testing.T) {
@@ -244,6 +248,13 @@ func TestBitwardenJSONParser(t *testing.T) {
 	if card.Card.Holder != "Alice Doe" {
 		t.Errorf("card holder = %q", card.Card.Holder)
 	}
+	gmail := res.Entries[1]
+	if gmail.Folder != "Email" || !gmail.Favorite {
+		t.Errorf("gmail folder/favorite = %q/%v", gmail.Folder, gmail.Favorite)
+	}
+	if res.Entries[0].Favorite {
+		t.Error("github should not be a favorite")
+	}
 }
 
 // ---------------- Generic CSV ----------------
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MatchMode says how a password entry is matched against the page the
+// browser extension asks about.
+type MatchMode uint8
+
+const (
+	// MatchBaseDomain matches any page on the same registrable domain
+	// (per the Public Suffix List) as the entry's site. It is the default.
+	MatchBaseDomain MatchMode = iota
+	// MatchHost matches only the entry's exact host name.
+	MatchHost
+	// MatchHostPort matches the exact host name and port.
+	MatchHostPort
+	// MatchStartsWith matches pages with the pattern URL's scheme, host and
+	// port whose path starts with the pattern's path.
+	MatchStartsWith
+	// MatchRegex matches pages whose whole URL matches the pattern as a Go
+	// regular expression.
+	MatchRegex
+	// MatchNever keeps the entry out of browser suggestions and fills.
+	MatchNever
+)
+
+var matchModeNames = [...]string{
+	MatchBaseDomain: "domain",
+	MatchHost:       "host",
+	MatchHostPort:   "host-port",
+	MatchStartsWith: "starts-with",
+	MatchRegex:      "regex",
+	MatchNever:      "never",
+}
+
+// MatchModes lists every mode in display order.
+var MatchModes = []MatchMode{MatchBaseDomain, MatchHost, MatchHostPort, MatchStartsWith, MatchRegex, MatchNever}
+
+func (m MatchMode) String() string {
+	if int(m) < len(matchModeNames) {
+		return matchModeNames[m]
+	}
+	return fmt.Sprintf("mode(%d)", m)
+}
+
+// ParseMatchMode reads a mode name as printed by String.
+func ParseMatchMode(name string) (MatchMode, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for m, n := range matchModeNames {
+		if n == name {
+			return MatchMode(m), nil
+		}
+	}
+	return MatchBaseDomain, fmt.Errorf("unknown match mode %q (want %s)", name, strings.Join(matchModeNames[:], ", "))
+}
+
+// MatchRule is an entry's match mode and its pattern. The base-domain, host
+// and host-port modes take a URL or host name as the pattern and fall back
+// to the entry's site when it is empty; starts-with and regex need one;
+// never ignores it. The zero rule matches by base domain.
+type MatchRule struct {
+	Mode    MatchMode
+	Pattern string
+}
+
+// IsZero reports whether r is the default rule, which is not stored.
+func (r MatchRule) IsZero() bool {
+	return r.Mode == MatchBaseDomain && r.Pattern == ""
+}
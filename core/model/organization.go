@@ -0,0 +1,86 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// FolderSeparator splits a folder path into its nested components.
+const FolderSeparator = "/"
+
+// NormalizeFolderPath trims every component of a "/"-separated folder path
+// and drops empty ones, so " Work / /Dev/ " becomes "Work/Dev". Backslashes
+// are treated as separators too, since some exporters use them.
+func NormalizeFolderPath(path string) string {
+	path = strings.ReplaceAll(path, `\`, FolderSeparator)
+	parts := strings.Split(path, FolderSeparator)
+	out := parts[:0]
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, FolderSeparator)
+}
+
+// FolderAncestors returns every prefix of a normalized folder path, from the
+// top-level folder down to the path itself: "a/b/c" → ["a", "a/b", "a/b/c"].
+func FolderAncestors(path string) []string {
+	if path == "" {
+		return nil
+	}
+	parts := strings.Split(path, FolderSeparator)
+	out := make([]string, len(parts))
+	for i := range parts {
+		out[i] = strings.Join(parts[:i+1], FolderSeparator)
+	}
+	return out
+}
+
+// NormalizeTags trims tags, drops empty ones and removes case-insensitive
+// duplicates (keeping the first spelling), then sorts the result.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]struct{}, len(tags))
+	var out []string
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		key := strings.ToLower(t)
+		if t == "" {
+			continue
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.ToLower(out[i]) < strings.ToLower(out[j])
+	})
+	return out
+}
+
+// InFolder reports whether the entry sits in folder, or in one of its
+// subfolders when recursive is set. The empty folder matches unfiled
+// entries only (or everything, when recursive).
+func (pe *VaultEntry) InFolder(folder string, recursive bool) bool {
+	folder = NormalizeFolderPath(folder)
+	if pe.Folder == folder {
+		return true
+	}
+	if !recursive {
+		return false
+	}
+	return folder == "" || strings.HasPrefix(pe.Folder, folder+FolderSeparator)
+}
+
+// HasTag reports whether the entry carries tag, compared case-insensitively.
+func (pe *VaultEntry) HasTag(tag string) bool {
+	tag = strings.TrimSpace(tag)
+	for _, t := range pe.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeFolderPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Work", "Work"},
+		{" Work / /Dev/ ", "Work/Dev"},
+		{`Root\Internet\Shops`, "Root/Internet/Shops"},
+		{"///", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeFolderPath(tt.in); got != tt.want {
+			t.Errorf("NormalizeFolderPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFolderAncestors(t *testing.T) {
+	got := FolderAncestors("a/b/c")
+	want := []string{"a", "a/b", "a/b/c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FolderAncestors = %v, want %v", got, want)
+	}
+	if FolderAncestors("") != nil {
+		t.Error("FolderAncestors(\"\") should be nil")
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{" work", "Personal", "WORK", "", "banking "})
+	want := []string{"banking", "Personal", "work"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTags = %v, want %v", got, want)
+	}
+}
+
+func TestInFolder(t *testing.T) {
+	e := &VaultEntry{Folder: "Work/Dev"}
+	cases := []struct {
+		folder    string
+		recursive bool
+		want      bool
+	}{
+		{"Work/Dev", false, true},
+		{"Work", false, false},
+		{"Work", true, true},
+		{"Wor", true, false},
+		{"", false, false},
+		{"", true, true},
+	}
+	for _, c := range cases {
+		if got := e.InFolder(c.folder, c.recursive); got != c.want {
+			t.Errorf("InFolder(%q, %v) = %v, want %v", c.folder, c.recursive, got, c.want)
+		}
+	}
+}
+
+func TestSerializeV2_OrganizationRoundTrip(t *testing.T) {
+	e := sampleEntry()
+	e.Folder = "Work/Dev"
+	e.Tags = []string{"ci", "github"}
+	e.Favorite = true
+
+	got, err := DeserializeV2(serialize(t, e))
+	if err != nil {
+		t.Fatalf("deserialize: %v", err)
+	}
+	if got.Folder != "Work/Dev" || !got.Favorite || !reflect.DeepEqual(got.Tags, e.Tags) {
+		t.Errorf("organization = %q %v %v", got.Folder, got.Tags, got.Favorite)
+	}
+	if !got.HasTag("GitHub") {
+		t.Error("HasTag should be case-insensitive")
+	}
+}
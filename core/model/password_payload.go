@@ -0,0 +1,97 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// PasswordPayloadVersion is the current version of the structured password
+// payload. Readers accept any version and ignore fields they do not know.
+const PasswordPayloadVersion = 1
+
+// passwordPayloadType is the discriminator written into every structured
+// password payload, matching the "type" key used by note payloads.
+const passwordPayloadType = "password"
+
+// CustomField is a user- or importer-defined name/value pair attached to a
+// password entry. Hidden fields are masked in the UI until revealed.
+type CustomField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Hidden bool   `json:"hidden,omitempty"`
+}
+
+// PasswordPayload is the encrypted plaintext of an EntryTypePassword entry.
+//
+// Entries written before this format existed carry the raw password string
+// as their payload; ParsePasswordPayload maps those to a payload with only
+// Password set, so callers never need to distinguish the two on read.
+type PasswordPayload struct {
+	Type     string        `json:"type"`
+	Version  int           `json:"version"`
+	Password string        `json:"password"`
+	LoginURL string        `json:"login_url,omitempty"`
+	URLs     []string      `json:"urls,omitempty"`
+	Notes    string        `json:"notes,omitempty"`
+	Fields   []CustomField `json:"fields,omitempty"`
+}
+
+// NewPasswordPayload returns a current-version payload holding password.
+func NewPasswordPayload(password string) *PasswordPayload {
+	return &PasswordPayload{
+		Type:     passwordPayloadType,
+		Version:  PasswordPayloadVersion,
+		Password: password,
+	}
+}
+
+// ParsePasswordPayload decodes a decrypted password entry payload.
+//
+// Plaintext that is not a JSON object tagged "type":"password" is treated as
+// a legacy raw password, so a password that merely looks like JSON is still
+// returned verbatim.
+func ParsePasswordPayload(plaintext string) *PasswordPayload {
+	trimmed := strings.TrimSpace(plaintext)
+	if strings.HasPrefix(trimmed, "{") {
+		var p PasswordPayload
+		if err := json.Unmarshal([]byte(trimmed), &p); err == nil && p.Type == passwordPayloadType && p.Version > 0 {
+			return &p
+		}
+	}
+	return NewPasswordPayload(plaintext)
+}
+
+// Marshal encodes the payload as JSON, stamping the current type and
+// version so a payload built by hand is always readable.
+func (p *PasswordPayload) Marshal() ([]byte, error) {
+	out := *p
+	out.Type = passwordPayloadType
+	if out.Version <= 0 {
+		out.Version = PasswordPayloadVersion
+	}
+	return json.Marshal(out)
+}
+
+// AllURLs returns the login URL followed by the additional URLs, skipping
+// blanks and exact duplicates.
+func (p *PasswordPayload) AllURLs() []string {
+	seen := make(map[string]struct{}, len(p.URLs)+1)
+	var out []string
+	for _, u := range append([]string{p.LoginURL}, p.URLs...) {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			continue
+		}
+		if _, dup := seen[u]; dup {
+			continue
+		}
+		seen[u] = struct{}{}
+		out = append(out, u)
+	}
+	return out
+}
+
+// HasExtras reports whether the payload carries anything beyond the password.
+func (p *PasswordPayload) HasExtras() bool {
+	return p.LoginURL != "" || len(p.URLs) > 0 || strings.TrimSpace(p.Notes) != "" || len(p.Fields) > 0
+}
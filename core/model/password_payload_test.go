@@ -0,0 +1,52 @@
+package model
+
+import "testing"
+
+func TestParsePasswordPayload_LegacyRawString(t *testing.T) {
+	for _, raw := range []string{"hunter2!", "", `{"not":"ours"}`, `{"type":"note","title":"x"}`, "{broken"} {
+		p := ParsePasswordPayload(raw)
+		if p.Password != raw {
+			t.Errorf("ParsePasswordPayload(%q).Password = %q", raw, p.Password)
+		}
+		if p.HasExtras() {
+			t.Errorf("ParsePasswordPayload(%q) unexpectedly has extras", raw)
+		}
+	}
+}
+
+func TestPasswordPayload_RoundTrip(t *testing.T) {
+	in := NewPasswordPayload("s3cret")
+	in.LoginURL = "https://example.com/login"
+	in.URLs = []string{"https://example.com", "https://example.com/login"}
+	in.Notes = "recovery: 1234"
+	in.Fields = []CustomField{{Name: "PIN", Value: "0000", Hidden: true}, {Name: "Team", Value: "core"}}
+
+	data, err := in.Marshal()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	out := ParsePasswordPayload(string(data))
+	if out.Password != "s3cret" || out.Notes != in.Notes || out.LoginURL != in.LoginURL {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+	if out.Version != PasswordPayloadVersion {
+		t.Errorf("version = %d", out.Version)
+	}
+	if len(out.Fields) != 2 || !out.Fields[0].Hidden || out.Fields[1].Hidden {
+		t.Errorf("fields = %+v", out.Fields)
+	}
+	if urls := out.AllURLs(); len(urls) != 2 || urls[0] != in.LoginURL {
+		t.Errorf("AllURLs = %v", urls)
+	}
+}
+
+func TestPasswordPayload_MarshalStampsTypeAndVersion(t *testing.T) {
+	data, err := (&PasswordPayload{Password: "pw"}).Marshal()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	out := ParsePasswordPayload(string(data))
+	if out.Password != "pw" || out.Version != PasswordPayloadVersion {
+		t.Errorf("payload = %+v", out)
+	}
+}
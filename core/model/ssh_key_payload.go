@@ -0,0 +1,50 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SSHKeyPayloadVersion is the current version of the SSH key payload.
+const SSHKeyPayloadVersion = 1
+
+const sshKeyPayloadType = "ssh_key"
+
+// SSHKeyPayload is the encrypted plaintext of an EntryTypeSSHKey entry.
+//
+// PrivateKey holds the key exactly as imported (OpenSSH or PEM armour),
+// still protected by Passphrase when it had one. PublicKey is the
+// authorized_keys line derived from it, kept so identities can be listed
+// without parsing the private half.
+type SSHKeyPayload struct {
+	Type       string `json:"type"`
+	Version    int    `json:"version"`
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	Comment    string `json:"comment,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+	// Confirm asks the user before every signature made with this key.
+	Confirm bool `json:"confirm,omitempty"`
+}
+
+// ParseSSHKeyPayload decodes a decrypted SSH key entry payload.
+func ParseSSHKeyPayload(plaintext string) (*SSHKeyPayload, error) {
+	var p SSHKeyPayload
+	if err := json.Unmarshal([]byte(plaintext), &p); err != nil {
+		return nil, fmt.Errorf("decode SSH key payload: %w", err)
+	}
+	if p.Type != sshKeyPayloadType || p.PrivateKey == "" {
+		return nil, fmt.Errorf("not an SSH key payload")
+	}
+	return &p, nil
+}
+
+// Marshal encodes the payload as JSON with the current type and version.
+func (p *SSHKeyPayload) Marshal() ([]byte, error) {
+	out := *p
+	out.Type = sshKeyPayloadType
+	if out.Version <= 0 {
+		out.Version = SSHKeyPayloadVersion
+	}
+	return json.Marshal(out)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"strings"
+	"time"
 )
 
 type EntryType uint8
@@ -16,20 +17,90 @@ const (
 	EntryTypeCard
 	EntryTypeTOTP
 	EntryTypeFile
+	EntryTypeSSHKey
 )
 
 // VaultEntry represents an encrypted entry stored in the vault.
 // Each entry is encrypted with a unique nonce using AES-256-GCM and
-// contains Kyber768 encapsulated key material for hybrid encryption.
+// contains KEM-encapsulated key material (ML-KEM, or Kyber768 in older
+// vaults), led by its crypto.KEMAlgorithm identifier.
 type VaultEntry struct {
 	ID              uint64 // Unique identifier (4 bytes + reserved for future use)
 	Type            EntryType
 	CardSubtype     string
 	Service         string // Service/website name (e.g., "Gmail", "GitHub")
 	Username        string // Username or email associated with the password
-	KyberCiphertext []byte // Kyber768 encapsulated secret (~1088 bytes)
+	KyberCiphertext []byte // KEM encapsulated secret led by its algorithm byte (1121 bytes for X-Wing)
 	Nonce           []byte // AES-GCM nonce (12 bytes)
 	Ciphertext      []byte // AES-256-GCM encrypted entry payload
+	CryptoVersion   uint8  // how Ciphertext was sealed; see EntryCryptoV2
+
+	// Metadata carried in the V2 extension trailer (see vault_entry_ext.go).
+	// Zero times mean "unknown" for entries written before they existed.
+	Created         time.Time
+	Modified        time.Time
+	LastUsed        time.Time
+	PasswordHistory []PasswordHistoryEntry // newest first, at most MaxPasswordHistory
+	Folder          string                 // "/"-separated folder path; "" means unfiled
+	Tags            []string               // normalized by NormalizeTags
+	Favorite        bool
+	Deleted         bool      // in the trash; hidden from normal views
+	DeletedAt       time.Time // when the entry was moved to the trash
+	// Attributes are the lookup attributes freedesktop Secret Service
+	// clients attach to the secrets they store (e.g. "server", "user").
+	Attributes map[string]string
+	// Match says which pages the browser extension offers a password
+	// entry on.
+	Match MatchRule
+
+	unknownExt []byte // extension records this build does not understand
+}
+
+// Entry crypto versions. Version 1, which the zero value also means for
+// entries written before versions were recorded, seals payloads with no
+// associated data. Version 2 authenticates the entry's ID, type, card
+// subtype, service and username as AES-GCM associated data, so ciphertexts
+// cannot be swapped between entries and the metadata cannot be edited
+// without the payload failing to open.
+const (
+	EntryCryptoV1 uint8 = 1
+	EntryCryptoV2 uint8 = 2
+
+	// CurrentEntryCrypto is the version new seals use.
+	CurrentEntryCrypto = EntryCryptoV2
+)
+
+const entryAADLabel = "passquantum-entry-v2"
+
+// AssociatedData returns the AES-GCM additional data for a ciphertext of
+// this entry sealed under version: nil before version 2, otherwise
+// [label][id u64][type u8][subtypeLen u8][subtype][serviceLen u16][service]
+// [usernameLen u16][username]. The type is inferred the same way
+// SerializeV2 does, so the bytes survive a write and read.
+func (pe *VaultEntry) AssociatedData(version uint8) []byte {
+	if version < EntryCryptoV2 {
+		return nil
+	}
+	entryType := pe.Type
+	if entryType == EntryTypeUnknown {
+		entryType = inferEntryType(pe.Service)
+	}
+	subtype := []byte(pe.CardSubtype)
+	if len(subtype) > 0xFF {
+		subtype = subtype[:0xFF]
+	}
+	service := truncateUint16([]byte(pe.Service))
+	username := truncateUint16([]byte(pe.Username))
+
+	out := make([]byte, 0, len(entryAADLabel)+8+1+1+len(subtype)+2+len(service)+2+len(username))
+	out = append(out, entryAADLabel...)
+	out = binary.BigEndian.AppendUint64(out, pe.ID)
+	out = append(out, byte(entryType), byte(len(subtype)))
+	out = append(out, subtype...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(service)))
+	out = append(out, service...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(username)))
+	return append(out, username...)
 }
 
 // PasswordEntry remains as an alias for backward compatibility.
@@ -40,10 +111,13 @@ func NewVaultEntry() *VaultEntry {
 	idBytes := make([]byte, 8)
 	rand.Read(idBytes)
 	id := binary.BigEndian.Uint64(idBytes)
+	now := time.Now().UTC()
 
 	return &VaultEntry{
-		ID:   id,
-		Type: EntryTypePassword,
+		ID:       id,
+		Type:     EntryTypePassword,
+		Created:  now,
+		Modified: now,
 	}
 }
 
@@ -53,18 +127,23 @@ func NewPasswordEntry() *PasswordEntry {
 	return NewVaultEntry()
 }
 
-// SerializeV2 encodes an entry with explicit type metadata for the migrated format.
-func (pe *VaultEntry) SerializeV2() []byte {
+// SerializeV2 encodes an entry with explicit type metadata for the migrated
+// format. It fails rather than drop data when a field, or a version in the
+// password history, is too long for its length prefix.
+func (pe *VaultEntry) SerializeV2() ([]byte, error) {
 	typeByte := byte(pe.Type)
 	if typeByte == byte(EntryTypeUnknown) {
 		typeByte = byte(inferEntryType(pe.Service))
 	}
 
-	if len(pe.Nonce) > 255 {
-		return nil
+	if len(pe.Nonce) > 0xFF {
+		return nil, fmt.Errorf("entry %016x: nonce of %d bytes is too long", pe.ID, len(pe.Nonce))
 	}
-	if len(pe.CardSubtype) > 255 {
-		return nil
+	if len(pe.CardSubtype) > 0xFF {
+		return nil, fmt.Errorf("entry %016x: card subtype of %d bytes is too long", pe.ID, len(pe.CardSubtype))
+	}
+	if len(pe.KyberCiphertext) > 0xFFFF {
+		return nil, fmt.Errorf("entry %016x: KEM ciphertext of %d bytes is too long", pe.ID, len(pe.KyberCiphertext))
 	}
 
 	subtypeBytes := []byte(pe.CardSubtype)
@@ -110,7 +189,7 @@ func (pe *VaultEntry) SerializeV2() []byte {
 	idx += 4
 	copy(data[idx:idx+len(pe.Ciphertext)], pe.Ciphertext)
 
-	return data
+	return pe.appendExtensions(data)
 }
 
 func DeserializeV2(data []byte) (*VaultEntry, error) {
@@ -171,12 +250,13 @@ func DeserializeV2(data []byte) (*VaultEntry, error) {
 		return nil, fmt.Errorf("invalid typed entry: truncated ciphertext")
 	}
 	ciphertext := append([]byte(nil), data[idx:idx+ciphertextLen]...)
+	idx += ciphertextLen
 
 	if entryType == EntryTypeUnknown {
 		entryType = inferEntryType(service)
 	}
 
-	return &VaultEntry{
+	entry := &VaultEntry{
 		ID:              id,
 		Type:            entryType,
 		CardSubtype:     cardSubtype,
@@ -185,7 +265,11 @@ func DeserializeV2(data []byte) (*VaultEntry, error) {
 		KyberCiphertext: kyberCiphertext,
 		Nonce:           nonce,
 		Ciphertext:      ciphertext,
-	}, nil
+	}
+	if err := entry.parseExtensions(data[idx:]); err != nil {
+		return nil, err
+	}
+	return entry, nil
 }
 
 // Serialize encodes the entry to the legacy binary format for backward compatibility.
@@ -0,0 +1,468 @@
+package model
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// The V2 entry record ends with the AES-GCM ciphertext. Everything after it
+// is an optional extension trailer: a sequence of [tag u8][len u32][value]
+// records. Readers that predate a tag skip it (and older builds ignore the
+// whole trailer), so new per-entry metadata can be added without bumping
+// the vault format. Unknown records are carried through a read/write cycle
+// untouched.
+const (
+	extTagTimestamps      byte = 1
+	extTagPasswordHistory byte = 2
+	extTagOrganization    byte = 3
+	extTagTrash           byte = 4
+	extTagAttributes      byte = 5
+	extTagCryptoVersions  byte = 6
+	extTagMatchRule       byte = 7
+)
+
+const extHeaderSize = 1 + 4
+
+// MaxPasswordHistory bounds how many previous versions of a password entry
+// are kept. Older versions are dropped when a new one is archived.
+const MaxPasswordHistory = 10
+
+// PasswordHistoryEntry is a previous version of a password entry's payload,
+// still encrypted with its original KEM encapsulation and entry crypto
+// version.
+type PasswordHistoryEntry struct {
+	ChangedAt       time.Time // when this version was replaced
+	KyberCiphertext []byte
+	Nonce           []byte
+	Ciphertext      []byte
+	CryptoVersion   uint8
+}
+
+// ArchivePassword moves the entry's current ciphertext into its password
+// history (newest first, trimmed to MaxPasswordHistory) and stamps the
+// modification time. The caller then stores the new ciphertext on the entry.
+// Entries with no ciphertext yet are only stamped.
+func (pe *VaultEntry) ArchivePassword(now time.Time) {
+	if len(pe.Ciphertext) > 0 {
+		h := PasswordHistoryEntry{
+			ChangedAt:       now,
+			KyberCiphertext: pe.KyberCiphertext,
+			Nonce:           pe.Nonce,
+			Ciphertext:      pe.Ciphertext,
+			CryptoVersion:   pe.CryptoVersion,
+		}
+		pe.PasswordHistory = append([]PasswordHistoryEntry{h}, pe.PasswordHistory...)
+		if len(pe.PasswordHistory) > MaxPasswordHistory {
+			pe.PasswordHistory = pe.PasswordHistory[:MaxPasswordHistory]
+		}
+	}
+	pe.Touch(now)
+}
+
+// Touch records a modification at now. Entries that predate timestamps get
+// their creation time backfilled to the same instant.
+func (pe *VaultEntry) Touch(now time.Time) {
+	if pe.Created.IsZero() {
+		pe.Created = now
+	}
+	pe.Modified = now
+}
+
+// MarkUsed records that the entry's secret was used (copied, filled) at now.
+func (pe *VaultEntry) MarkUsed(now time.Time) {
+	pe.LastUsed = now
+}
+
+// MoveToTrash marks the entry as deleted at now. Trashed entries keep their
+// ciphertext until they are purged, so they can be restored unchanged.
+func (pe *VaultEntry) MoveToTrash(now time.Time) {
+	pe.Deleted = true
+	pe.DeletedAt = now
+}
+
+// RestoreFromTrash clears the deleted marker and stamps the modification time.
+func (pe *VaultEntry) RestoreFromTrash(now time.Time) {
+	pe.Deleted = false
+	pe.DeletedAt = time.Time{}
+	pe.Touch(now)
+}
+
+// TrashExpired reports whether a trashed entry has been in the trash for at
+// least retention at now. A non-positive retention never expires anything.
+func (pe *VaultEntry) TrashExpired(now time.Time, retention time.Duration) bool {
+	if !pe.Deleted || retention <= 0 {
+		return false
+	}
+	return !now.Before(pe.DeletedAt.Add(retention))
+}
+
+// appendExtensions writes the extension trailer for pe onto data.
+func (pe *VaultEntry) appendExtensions(data []byte) ([]byte, error) {
+	if !pe.Created.IsZero() || !pe.Modified.IsZero() || !pe.LastUsed.IsZero() {
+		var ts [24]byte
+		binary.BigEndian.PutUint64(ts[0:8], uint64(unixNano(pe.Created)))
+		binary.BigEndian.PutUint64(ts[8:16], uint64(unixNano(pe.Modified)))
+		binary.BigEndian.PutUint64(ts[16:24], uint64(unixNano(pe.LastUsed)))
+		data = appendExtRecord(data, extTagTimestamps, ts[:])
+	}
+
+	if len(pe.PasswordHistory) > 0 {
+		rec, err := encodePasswordHistory(pe.PasswordHistory)
+		if err != nil {
+			return nil, fmt.Errorf("entry %016x: %w", pe.ID, err)
+		}
+		data = appendExtRecord(data, extTagPasswordHistory, rec)
+	}
+
+	if pe.Folder != "" || len(pe.Tags) > 0 || pe.Favorite {
+		data = appendExtRecord(data, extTagOrganization, encodeOrganization(pe))
+	}
+
+	if pe.Deleted {
+		var rec [1 + 8]byte
+		rec[0] = 1
+		binary.BigEndian.PutUint64(rec[1:], uint64(unixNano(pe.DeletedAt)))
+		data = appendExtRecord(data, extTagTrash, rec[:])
+	}
+
+	if len(pe.Attributes) > 0 {
+		data = appendExtRecord(data, extTagAttributes, encodeAttributes(pe.Attributes))
+	}
+
+	if rec := encodeCryptoVersions(pe); rec != nil {
+		data = appendExtRecord(data, extTagCryptoVersions, rec)
+	}
+
+	if !pe.Match.IsZero() {
+		data = appendExtRecord(data, extTagMatchRule, encodeMatchRule(pe.Match))
+	}
+
+	return append(data, pe.unknownExt...), nil
+}
+
+// parseExtensions decodes the extension trailer into pe.
+func (pe *VaultEntry) parseExtensions(data []byte) error {
+	var versions []byte
+	for len(data) > 0 {
+		if len(data) < extHeaderSize {
+			return fmt.Errorf("invalid typed entry: truncated extension header")
+		}
+		tag := data[0]
+		size := int(binary.BigEndian.Uint32(data[1:extHeaderSize]))
+		if size < 0 || len(data) < extHeaderSize+size {
+			return fmt.Errorf("invalid typed entry: truncated extension %d", tag)
+		}
+		value := data[extHeaderSize : extHeaderSize+size]
+
+		switch tag {
+		case extTagTimestamps:
+			if len(value) < 24 {
+				return fmt.Errorf("invalid typed entry: short timestamps")
+			}
+			pe.Created = fromUnixNano(int64(binary.BigEndian.Uint64(value[0:8])))
+			pe.Modified = fromUnixNano(int64(binary.BigEndian.Uint64(value[8:16])))
+			pe.LastUsed = fromUnixNano(int64(binary.BigEndian.Uint64(value[16:24])))
+		case extTagPasswordHistory:
+			history, err := decodePasswordHistory(value)
+			if err != nil {
+				return err
+			}
+			pe.PasswordHistory = history
+		case extTagOrganization:
+			if err := decodeOrganization(pe, value); err != nil {
+				return err
+			}
+		case extTagTrash:
+			if len(value) < 1+8 {
+				return fmt.Errorf("invalid typed entry: short trash record")
+			}
+			pe.Deleted = value[0]&1 != 0
+			pe.DeletedAt = fromUnixNano(int64(binary.BigEndian.Uint64(value[1:9])))
+		case extTagAttributes:
+			attrs, err := decodeAttributes(value)
+			if err != nil {
+				return err
+			}
+			pe.Attributes = attrs
+		case extTagCryptoVersions:
+			if len(value) < 1 {
+				return fmt.Errorf("invalid typed entry: short crypto version record")
+			}
+			versions = value
+		case extTagMatchRule:
+			rule, err := decodeMatchRule(value)
+			if err != nil {
+				return err
+			}
+			pe.Match = rule
+		default:
+			pe.unknownExt = append(pe.unknownExt, data[:extHeaderSize+size]...)
+		}
+		data = data[extHeaderSize+size:]
+	}
+	// The history record may come before or after the versions record.
+	if versions != nil {
+		pe.CryptoVersion = versions[0]
+		for i := range pe.PasswordHistory {
+			if 1+i < len(versions) {
+				pe.PasswordHistory[i].CryptoVersion = versions[1+i]
+			}
+		}
+	}
+	return nil
+}
+
+// encodeCryptoVersions lays out the payload's crypto version followed by
+// one byte per password history version, in history order. Entries sealed
+// entirely under version 1 get no record, which older builds also read as
+// version 1.
+func encodeCryptoVersions(pe *VaultEntry) []byte {
+	out := []byte{pe.CryptoVersion}
+	needed := pe.CryptoVersion > EntryCryptoV1
+	for _, h := range pe.PasswordHistory {
+		out = append(out, h.CryptoVersion)
+		needed = needed || h.CryptoVersion > EntryCryptoV1
+	}
+	if !needed {
+		return nil
+	}
+	return out
+}
+
+// encodeMatchRule lays out [mode u8][patternLen u16][pattern].
+func encodeMatchRule(r MatchRule) []byte {
+	pattern := truncateUint16([]byte(r.Pattern))
+	out := []byte{byte(r.Mode)}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(pattern)))
+	return append(out, pattern...)
+}
+
+func decodeMatchRule(data []byte) (MatchRule, error) {
+	if len(data) < 1+2 {
+		return MatchRule{}, fmt.Errorf("invalid typed entry: short match rule")
+	}
+	n := int(binary.BigEndian.Uint16(data[1:3]))
+	if len(data) < 3+n {
+		return MatchRule{}, fmt.Errorf("invalid typed entry: truncated match rule")
+	}
+	return MatchRule{Mode: MatchMode(data[0]), Pattern: string(data[3 : 3+n])}, nil
+}
+
+func appendExtRecord(data []byte, tag byte, value []byte) []byte {
+	var hdr [extHeaderSize]byte
+	hdr[0] = tag
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(value)))
+	data = append(data, hdr[:]...)
+	return append(data, value...)
+}
+
+// encodePasswordHistory lays out [count u16] followed by, per version,
+// [changedAt i64][kyberLen u16][kyber][nonceLen u8][nonce][ctLen u32][ct].
+func encodePasswordHistory(history []PasswordHistoryEntry) ([]byte, error) {
+	if len(history) > 0xFFFF {
+		return nil, fmt.Errorf("password history of %d versions is too long", len(history))
+	}
+	out := make([]byte, 2, 2+len(history)*(8+2+1121+1+12+4+64))
+	binary.BigEndian.PutUint16(out, uint16(len(history)))
+	for i, h := range history {
+		if len(h.KyberCiphertext) > 0xFFFF || len(h.Nonce) > 0xFF {
+			return nil, fmt.Errorf("password history version %d has an oversized ciphertext or nonce", i)
+		}
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], uint64(unixNano(h.ChangedAt)))
+		out = append(out, buf[:]...)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(h.KyberCiphertext)))
+		out = append(out, h.KyberCiphertext...)
+		out = append(out, byte(len(h.Nonce)))
+		out = append(out, h.Nonce...)
+		out = binary.BigEndian.AppendUint32(out, uint32(len(h.Ciphertext)))
+		out = append(out, h.Ciphertext...)
+	}
+	return out, nil
+}
+
+func decodePasswordHistory(data []byte) ([]PasswordHistoryEntry, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("invalid typed entry: short password history")
+	}
+	count := int(binary.BigEndian.Uint16(data))
+	idx := 2
+	history := make([]PasswordHistoryEntry, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < idx+8+2 {
+			return nil, fmt.Errorf("invalid typed entry: truncated password history")
+		}
+		changedAt := fromUnixNano(int64(binary.BigEndian.Uint64(data[idx : idx+8])))
+		idx += 8
+
+		kyberLen := int(binary.BigEndian.Uint16(data[idx : idx+2]))
+		idx += 2
+		if len(data) < idx+kyberLen+1 {
+			return nil, fmt.Errorf("invalid typed entry: truncated password history")
+		}
+		kyber := append([]byte(nil), data[idx:idx+kyberLen]...)
+		idx += kyberLen
+
+		nonceLen := int(data[idx])
+		idx++
+		if len(data) < idx+nonceLen+4 {
+			return nil, fmt.Errorf("invalid typed entry: truncated password history")
+		}
+		nonce := append([]byte(nil), data[idx:idx+nonceLen]...)
+		idx += nonceLen
+
+		ctLen := int(binary.BigEndian.Uint32(data[idx : idx+4]))
+		idx += 4
+		if len(data) < idx+ctLen {
+			return nil, fmt.Errorf("invalid typed entry: truncated password history")
+		}
+		ct := append([]byte(nil), data[idx:idx+ctLen]...)
+		idx += ctLen
+
+		history = append(history, PasswordHistoryEntry{
+			ChangedAt:       changedAt,
+			KyberCiphertext: kyber,
+			Nonce:           nonce,
+			Ciphertext:      ct,
+		})
+	}
+	return history, nil
+}
+
+// encodeOrganization lays out [flags u8][folderLen u16][folder]
+// [tagCount u16] followed by [tagLen u16][tag] per tag. Bit 0 of flags is
+// the favorite marker.
+func encodeOrganization(pe *VaultEntry) []byte {
+	var flags byte
+	if pe.Favorite {
+		flags |= 1
+	}
+	out := []byte{flags}
+	folder := truncateUint16([]byte(pe.Folder))
+	out = binary.BigEndian.AppendUint16(out, uint16(len(folder)))
+	out = append(out, folder...)
+
+	tags := pe.Tags
+	if len(tags) > 0xFFFF {
+		tags = tags[:0xFFFF]
+	}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(tags)))
+	for _, tag := range tags {
+		b := truncateUint16([]byte(tag))
+		out = binary.BigEndian.AppendUint16(out, uint16(len(b)))
+		out = append(out, b...)
+	}
+	return out
+}
+
+func decodeOrganization(pe *VaultEntry, data []byte) error {
+	if len(data) < 1+2 {
+		return fmt.Errorf("invalid typed entry: short organization record")
+	}
+	pe.Favorite = data[0]&1 != 0
+	idx := 1
+
+	folderLen := int(binary.BigEndian.Uint16(data[idx : idx+2]))
+	idx += 2
+	if len(data) < idx+folderLen+2 {
+		return fmt.Errorf("invalid typed entry: truncated folder")
+	}
+	pe.Folder = string(data[idx : idx+folderLen])
+	idx += folderLen
+
+	count := int(binary.BigEndian.Uint16(data[idx : idx+2]))
+	idx += 2
+	tags := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < idx+2 {
+			return fmt.Errorf("invalid typed entry: truncated tags")
+		}
+		tagLen := int(binary.BigEndian.Uint16(data[idx : idx+2]))
+		idx += 2
+		if len(data) < idx+tagLen {
+			return fmt.Errorf("invalid typed entry: truncated tags")
+		}
+		tags = append(tags, string(data[idx:idx+tagLen]))
+		idx += tagLen
+	}
+	if len(tags) > 0 {
+		pe.Tags = tags
+	}
+	return nil
+}
+
+// encodeAttributes lays out [count u16] followed by
+// [keyLen u16][key][valueLen u16][value] per attribute, keys sorted so the
+// record is stable across writes.
+func encodeAttributes(attrs map[string]string) []byte {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0xFFFF {
+		keys = keys[:0xFFFF]
+	}
+
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(keys)))
+	for _, k := range keys {
+		for _, s := range [2]string{k, attrs[k]} {
+			b := truncateUint16([]byte(s))
+			out = binary.BigEndian.AppendUint16(out, uint16(len(b)))
+			out = append(out, b...)
+		}
+	}
+	return out
+}
+
+func decodeAttributes(data []byte) (map[string]string, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("invalid typed entry: short attributes record")
+	}
+	count := int(binary.BigEndian.Uint16(data))
+	idx := 2
+	attrs := make(map[string]string, count)
+	for i := 0; i < count; i++ {
+		var kv [2]string
+		for j := range kv {
+			if len(data) < idx+2 {
+				return nil, fmt.Errorf("invalid typed entry: truncated attributes")
+			}
+			n := int(binary.BigEndian.Uint16(data[idx : idx+2]))
+			idx += 2
+			if len(data) < idx+n {
+				return nil, fmt.Errorf("invalid typed entry: truncated attributes")
+			}
+			kv[j] = string(data[idx : idx+n])
+			idx += n
+		}
+		attrs[kv[0]] = kv[1]
+	}
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+	return attrs, nil
+}
+
+func truncateUint16(b []byte) []byte {
+	if len(b) > 0xFFFF {
+		return b[:0xFFFF]
+	}
+	return b
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
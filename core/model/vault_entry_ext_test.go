@@ -0,0 +1,237 @@
+package model
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func sampleEntry() *VaultEntry {
+	return &VaultEntry{
+		ID:              42,
+		Type:            EntryTypePassword,
+		Service:         "github.com",
+		Username:        "octocat",
+		KyberCiphertext: bytes.Repeat([]byte{0xAA}, 1088),
+		Nonce:           bytes.Repeat([]byte{0x01}, 12),
+		Ciphertext:      []byte("ciphertext-v1"),
+	}
+}
+
+func serialize(t *testing.T, e *VaultEntry) []byte {
+	t.Helper()
+	data, err := e.SerializeV2()
+	if err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+	return data
+}
+
+func TestSerializeV2_NoTrailerForBareEntry(t *testing.T) {
+	e := sampleEntry()
+	got, err := DeserializeV2(serialize(t, e))
+	if err != nil {
+		t.Fatalf("deserialize: %v", err)
+	}
+	if !got.Created.IsZero() || !got.Modified.IsZero() || len(got.PasswordHistory) != 0 {
+		t.Errorf("unexpected metadata on bare entry: %+v", got)
+	}
+}
+
+func TestSerializeV2_TimestampsAndHistoryRoundTrip(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	e := sampleEntry()
+	e.Created = base
+	e.ArchivePassword(base.Add(time.Hour))
+	e.Ciphertext = []byte("ciphertext-v2")
+	e.MarkUsed(base.Add(2 * time.Hour))
+
+	got, err := DeserializeV2(serialize(t, e))
+	if err != nil {
+		t.Fatalf("deserialize: %v", err)
+	}
+	if !got.Created.Equal(base) || !got.Modified.Equal(base.Add(time.Hour)) || !got.LastUsed.Equal(base.Add(2*time.Hour)) {
+		t.Errorf("timestamps = %v / %v / %v", got.Created, got.Modified, got.LastUsed)
+	}
+	if string(got.Ciphertext) != "ciphertext-v2" {
+		t.Errorf("ciphertext = %q", got.Ciphertext)
+	}
+	if len(got.PasswordHistory) != 1 {
+		t.Fatalf("history length = %d", len(got.PasswordHistory))
+	}
+	h := got.PasswordHistory[0]
+	if string(h.Ciphertext) != "ciphertext-v1" || len(h.KyberCiphertext) != 1088 || len(h.Nonce) != 12 {
+		t.Errorf("history entry = %+v", h)
+	}
+	if !h.ChangedAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("changedAt = %v", h.ChangedAt)
+	}
+}
+
+func TestSerializeV2_RejectsOversizedHistory(t *testing.T) {
+	e := sampleEntry()
+	e.ArchivePassword(time.Now())
+	e.PasswordHistory[0].Nonce = bytes.Repeat([]byte{0x02}, 0x100)
+	if _, err := e.SerializeV2(); err == nil {
+		t.Fatal("entry whose history cannot be encoded serialized without error")
+	}
+
+	e = sampleEntry()
+	e.Nonce = bytes.Repeat([]byte{0x02}, 0x100)
+	if _, err := e.SerializeV2(); err == nil {
+		t.Fatal("entry with an oversized nonce serialized without error")
+	}
+}
+
+func TestArchivePassword_BoundedNewestFirst(t *testing.T) {
+	e := sampleEntry()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < MaxPasswordHistory+5; i++ {
+		e.Ciphertext = []byte{byte(i)}
+		e.ArchivePassword(start.Add(time.Duration(i) * time.Minute))
+	}
+	if len(e.PasswordHistory) != MaxPasswordHistory {
+		t.Fatalf("history length = %d, want %d", len(e.PasswordHistory), MaxPasswordHistory)
+	}
+	if got := e.PasswordHistory[0].Ciphertext[0]; got != byte(MaxPasswordHistory+4) {
+		t.Errorf("newest archived version = %d", got)
+	}
+}
+
+func TestDeserializeV2_PreservesUnknownExtensions(t *testing.T) {
+	e := sampleEntry()
+	raw := appendExtRecord(serialize(t, e), 0xEE, []byte("future"))
+
+	got, err := DeserializeV2(raw)
+	if err != nil {
+		t.Fatalf("deserialize: %v", err)
+	}
+	if !bytes.Equal(serialize(t, got), raw) {
+		t.Error("unknown extension record was not carried through")
+	}
+}
+
+func TestDeserializeV2_RejectsTruncatedExtension(t *testing.T) {
+	raw := append(serialize(t, sampleEntry()), extTagTimestamps, 0, 0, 0, 24, 1, 2)
+	if _, err := DeserializeV2(raw); err == nil {
+		t.Fatal("expected error for truncated extension record")
+	}
+}
+
+func TestSerializeV2_TrashRoundTrip(t *testing.T) {
+	deletedAt := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	e := sampleEntry()
+	e.MoveToTrash(deletedAt)
+
+	got, err := DeserializeV2(serialize(t, e))
+	if err != nil {
+		t.Fatalf("deserialize: %v", err)
+	}
+	if !got.Deleted || !got.DeletedAt.Equal(deletedAt) {
+		t.Errorf("trash state = %v %v", got.Deleted, got.DeletedAt)
+	}
+
+	got.RestoreFromTrash(deletedAt.Add(time.Hour))
+	again, err := DeserializeV2(serialize(t, got))
+	if err != nil {
+		t.Fatalf("deserialize restored: %v", err)
+	}
+	if again.Deleted || !again.DeletedAt.IsZero() {
+		t.Errorf("restored entry still trashed: %v %v", again.Deleted, again.DeletedAt)
+	}
+}
+
+func TestSerializeV2_AttributesRoundTrip(t *testing.T) {
+	e := sampleEntry()
+	e.Attributes = map[string]string{"xdg:schema": "org.gnome.keyring.NetworkPassword", "server": "imap.example.org", "user": ""}
+
+	first := serialize(t, e)
+	if !bytes.Equal(first, serialize(t, e)) {
+		t.Error("attribute record should serialize deterministically")
+	}
+	got, err := DeserializeV2(first)
+	if err != nil {
+		t.Fatalf("deserialize: %v", err)
+	}
+	if len(got.Attributes) != 3 || got.Attributes["server"] != "imap.example.org" {
+		t.Errorf("attributes = %v", got.Attributes)
+	}
+	if _, ok := got.Attributes["user"]; !ok {
+		t.Error("empty attribute values should survive")
+	}
+}
+
+func TestSerializeV2_CryptoVersionsRoundTrip(t *testing.T) {
+	// A version 1 password archived before the entry was re-sealed.
+	e := sampleEntry()
+	e.ArchivePassword(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	e.CryptoVersion = EntryCryptoV2
+
+	got, err := DeserializeV2(serialize(t, e))
+	if err != nil {
+		t.Fatalf("deserialize: %v", err)
+	}
+	if got.CryptoVersion != EntryCryptoV2 || got.PasswordHistory[0].CryptoVersion >= EntryCryptoV2 {
+		t.Errorf("versions = %d / %d", got.CryptoVersion, got.PasswordHistory[0].CryptoVersion)
+	}
+
+	// The associated data covers the metadata and changes with it.
+	aad := got.AssociatedData(EntryCryptoV2)
+	if got.AssociatedData(EntryCryptoV1) != nil || len(aad) == 0 {
+		t.Fatalf("associated data v1 = %x, v2 = %x", got.AssociatedData(EntryCryptoV1), aad)
+	}
+	got.Service = "gitlab.com"
+	if bytes.Equal(aad, got.AssociatedData(EntryCryptoV2)) {
+		t.Error("associated data ignores the service")
+	}
+}
+
+func TestSerializeV2_MatchRuleRoundTrip(t *testing.T) {
+	e := sampleEntry()
+	e.Match = MatchRule{Mode: MatchRegex, Pattern: `^https://github\.com/login`}
+
+	got, err := DeserializeV2(serialize(t, e))
+	if err != nil {
+		t.Fatalf("deserialize: %v", err)
+	}
+	if got.Match != e.Match {
+		t.Errorf("match rule = %+v, want %+v", got.Match, e.Match)
+	}
+
+	// The default rule writes no record.
+	bare := sampleEntry()
+	if len(serialize(t, bare)) >= len(serialize(t, e)) {
+		t.Error("default match rule should not be stored")
+	}
+}
+
+func TestParseMatchMode(t *testing.T) {
+	for _, m := range MatchModes {
+		got, err := ParseMatchMode(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseMatchMode(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if _, err := ParseMatchMode("fuzzy"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestTrashExpired(t *testing.T) {
+	deletedAt := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	e := sampleEntry()
+	if e.TrashExpired(deletedAt.Add(time.Hour), time.Minute) {
+		t.Error("live entry must never expire")
+	}
+	e.MoveToTrash(deletedAt)
+	week := 7 * 24 * time.Hour
+	if e.TrashExpired(deletedAt.Add(week-time.Second), week) {
+		t.Error("expired before retention elapsed")
+	}
+	if !e.TrashExpired(deletedAt.Add(week), week) {
+		t.Error("not expired after retention elapsed")
+	}
+	if e.TrashExpired(deletedAt.Add(100*week), 0) {
+		t.Error("zero retention should keep trash forever")
+	}
+}
@@ -42,13 +42,28 @@ func SaveAppSecurityProfile(path string, profile *crypto.AppSecurityProfile) err
 		return fmt.Errorf("failed to encode app security profile: %w", err)
 	}
 
-	if err := os.WriteFile(resolveSecurityMetadataPath(path), data, 0600); err != nil {
+	if err := securestorage.WriteFileAtomic(resolveSecurityMetadataPath(path), data, 0600); err != nil {
 		return fmt.Errorf("failed to write app security profile: %w", err)
 	}
 
 	return nil
 }
 
+// StageAppSecurityProfile adds the profile to tx, so it is replaced together
+// with the vaults re-encrypted for the same password.
+func StageAppSecurityProfile(tx *securestorage.Tx, path string, profile *crypto.AppSecurityProfile) error {
+	if profile == nil {
+		return fmt.Errorf("security profile is required")
+	}
+
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode app security profile: %w", err)
+	}
+
+	return tx.Write(resolveSecurityMetadataPath(path), data, 0600)
+}
+
 // ReencryptVaultFile decrypts a vault with currentPassword and returns the
 // bytes of the same vault re-encrypted with newPassword using the PQ format.
 // The caller is responsible for atomically replacing the vault file.
@@ -58,7 +73,10 @@ func ReencryptVaultFile(vaultPath string, currentPassword string, newPassword st
 		return nil, fmt.Errorf("failed to decrypt vault with current password: %w", err)
 	}
 
-	plaintext := serializeEntries(entries)
+	plaintext, err := serializeEntries(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode vault: %w", err)
+	}
 
 	newData, err := crypto.PQVaultEncrypt(plaintext, newPassword)
 	if err != nil {
@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"passquantum/core/crypto"
+	"passquantum/core/model"
+	securestorage "passquantum/internal/storage"
+)
+
+const (
+	snapshotDirName    = "backups"
+	snapshotTimeLayout = "20060102T150405.000000000Z"
+	snapshotVaultExt   = ".enc"
+	snapshotMetaExt    = ".pqmeta"
+)
+
+// SnapshotRetention bounds the snapshots kept per vault. A zero field
+// disables that limit. The newest snapshot is never pruned by age, so a
+// vault that has not changed in a while still has one to go back to.
+type SnapshotRetention struct {
+	MaxCount int
+	MaxAge   time.Duration
+}
+
+// DefaultSnapshotRetention is in effect until SetSnapshotRetention is called.
+var DefaultSnapshotRetention = SnapshotRetention{MaxCount: 20, MaxAge: 30 * 24 * time.Hour}
+
+var (
+	retentionMu sync.Mutex
+	retention   = DefaultSnapshotRetention
+)
+
+// SetSnapshotRetention changes how many snapshots later writes keep.
+func SetSnapshotRetention(r SnapshotRetention) {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+	retention = r
+}
+
+func currentRetention() SnapshotRetention {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+	return retention
+}
+
+// Snapshot is one saved copy of a vault file, still encrypted exactly as it
+// was on disk, together with the app-security profile that was current then.
+type Snapshot struct {
+	ID           string
+	Vault        string
+	Created      time.Time
+	Size         int64
+	Path         string
+	MetadataPath string
+}
+
+// snapshotDir returns backups/<vault file name>/ in the vault directory.
+func snapshotDir(vaultPath string) (string, error) {
+	resolved, err := securestorage.ResolveVaultPath(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	backups, err := securestorage.GetSecureFilePath(snapshotDirName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(backups, filepath.Base(resolved)), nil
+}
+
+// SnapshotVaultFile copies the vault file as it is now into its snapshot
+// directory and prunes old snapshots. A vault that does not exist yet has
+// nothing to keep.
+func SnapshotVaultFile(vaultPath string) error {
+	data, err := securestorage.ReadVaultFile(vaultPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read vault for snapshot: %w", err)
+	}
+
+	dir, err := snapshotDir(vaultPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	if latest, err := ListSnapshots(vaultPath); err == nil && len(latest) > 0 {
+		if previous, err := os.ReadFile(latest[0].Path); err == nil && bytes.Equal(previous, data) {
+			return nil
+		}
+	}
+
+	id := time.Now().UTC().Format(snapshotTimeLayout)
+	if err := securestorage.WriteFileAtomic(filepath.Join(dir, id+snapshotVaultExt), data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if meta, err := os.ReadFile(resolveSecurityMetadataPath("")); err == nil {
+		if err := securestorage.WriteFileAtomic(filepath.Join(dir, id+snapshotMetaExt), meta, 0600); err != nil {
+			return fmt.Errorf("failed to write snapshot metadata: %w", err)
+		}
+	}
+
+	return pruneSnapshots(vaultPath, currentRetention(), time.Now())
+}
+
+// ListSnapshots returns the vault's snapshots, newest first.
+func ListSnapshots(vaultPath string) ([]Snapshot, error) {
+	dir, err := snapshotDir(vaultPath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	vault := filepath.Base(dir)
+	var snapshots []Snapshot
+	for _, f := range files {
+		id, ok := strings.CutSuffix(f.Name(), snapshotVaultExt)
+		if !ok || f.IsDir() {
+			continue
+		}
+		created, err := time.Parse(snapshotTimeLayout, id)
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		s := Snapshot{
+			ID:      id,
+			Vault:   vault,
+			Created: created,
+			Size:    info.Size(),
+			Path:    filepath.Join(dir, f.Name()),
+		}
+		if meta := filepath.Join(dir, id+snapshotMetaExt); fileExists(meta) {
+			s.MetadataPath = meta
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Created.After(snapshots[j].Created) })
+	return snapshots, nil
+}
+
+// ReadSnapshot decrypts a snapshot with password. A master-password change
+// re-encrypts the snapshots it can open (see ReencryptSnapshot); one that
+// failed to open then still needs the password that was current before.
+func ReadSnapshot(s Snapshot, password string) ([]*model.VaultEntry, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	entries, _, err := decodeVaultData(data, password)
+	if err != nil {
+		if s.MetadataPath != "" && !sameFile(s.MetadataPath, resolveSecurityMetadataPath("")) {
+			return nil, fmt.Errorf("%w (the snapshot predates a master password change)", err)
+		}
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ReencryptSnapshot decrypts a snapshot with currentPassword and returns
+// its file bytes sealed for newPassword. Like ReencryptVaultFile, it leaves
+// replacing the file to the caller's transaction.
+func ReencryptSnapshot(s Snapshot, currentPassword string, newPassword string) ([]byte, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	entries, _, err := decodeVaultData(data, currentPassword)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeVault(entries, newPassword)
+}
+
+// UpgradeSnapshotKDF seals a snapshot again when its Argon2id cost is below
+// the current vault policy, as UpgradeVaultKDF does for the vault, and
+// reports whether it was rewritten. A snapshot that predates a master
+// password change does not open with password and is left as it is.
+func UpgradeSnapshotKDF(s Snapshot, password string) (bool, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if !crypto.IsPQVaultFormat(data) || !crypto.PQVaultKDFOutdated(data) {
+		return false, nil
+	}
+	plaintext, err := crypto.PQVaultDecrypt(data, password)
+	if errors.Is(err, crypto.ErrPQSignatureInvalid) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt snapshot: %w", err)
+	}
+	defer crypto.WipeBytes(plaintext)
+	newData, err := crypto.PQVaultEncrypt(plaintext, password)
+	if err != nil {
+		return false, fmt.Errorf("failed to encrypt snapshot: %w", err)
+	}
+	if err := securestorage.WriteFileAtomic(s.Path, newData, 0600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// purgeSnapshotEntries rewrites every snapshot of vaultPath that holds one
+// of the purged entries without it. Snapshots that do not open with
+// password are skipped.
+func purgeSnapshotEntries(vaultPath string, purged []uint64, password string) error {
+	snapshots, err := ListSnapshots(vaultPath)
+	if err != nil {
+		return err
+	}
+	drop := make(map[uint64]bool, len(purged))
+	for _, id := range purged {
+		drop[id] = true
+	}
+	for _, s := range snapshots {
+		data, err := os.ReadFile(s.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot %s: %w", s.ID, err)
+		}
+		entries, _, err := decodeVaultData(data, password)
+		if errors.Is(err, crypto.ErrPQSignatureInvalid) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot %s: %w", s.ID, err)
+		}
+		kept := entries[:0:0]
+		for _, e := range entries {
+			if !drop[e.ID] {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == len(entries) {
+			continue
+		}
+		newData, err := EncodeVault(kept, password)
+		if err != nil {
+			return err
+		}
+		if err := securestorage.WriteFileAtomic(s.Path, newData, 0600); err != nil {
+			return fmt.Errorf("failed to rewrite snapshot %s: %w", s.ID, err)
+		}
+	}
+	return nil
+}
+
+// pruneSnapshots applies r to the vault's snapshots as of now.
+func pruneSnapshots(vaultPath string, r SnapshotRetention, now time.Time) error {
+	snapshots, err := ListSnapshots(vaultPath)
+	if err != nil {
+		return err
+	}
+	for i, s := range snapshots {
+		tooMany := r.MaxCount > 0 && i >= r.MaxCount
+		tooOld := r.MaxAge > 0 && i > 0 && now.Sub(s.Created) > r.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to prune snapshot %s: %w", s.ID, err)
+		}
+		if s.MetadataPath != "" {
+			_ = os.Remove(s.MetadataPath)
+		}
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func sameFile(a, b string) bool {
+	da, errA := os.ReadFile(a)
+	db, errB := os.ReadFile(b)
+	return errA == nil && errB == nil && bytes.Equal(da, db)
+}
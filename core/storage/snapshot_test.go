@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"passquantum/core/model"
+)
+
+func snapshotTestEntry(service string) *model.VaultEntry {
+	e := model.NewVaultEntry()
+	e.Type = model.EntryTypePassword
+	e.Service = service
+	e.KyberCiphertext = []byte{1}
+	e.Nonce = []byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	e.Ciphertext = []byte("enc")
+	return e
+}
+
+func TestWriteVaultKeepsSnapshots(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	const vault, password = "snap.enc", "snap-pass"
+
+	var entries []*model.VaultEntry
+	for _, service := range []string{"one", "two", "three"} {
+		entries = append(entries, snapshotTestEntry(service))
+		if err := WriteVault(entries, vault, password); err != nil {
+			t.Fatalf("WriteVault: %v", err)
+		}
+	}
+
+	snapshots, err := ListSnapshots(vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first write had no previous file to keep.
+	if len(snapshots) != 2 {
+		t.Fatalf("snapshots = %d, want 2", len(snapshots))
+	}
+	newest, err := ReadSnapshot(snapshots[0], password)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if len(newest) != 2 || newest[1].Service != "two" {
+		t.Errorf("newest snapshot has %d entries", len(newest))
+	}
+	if snapshots[0].MetadataPath != "" {
+		t.Error("no security profile exists, so none should be copied")
+	}
+	if _, err := ReadSnapshot(snapshots[1], "wrong"); err == nil {
+		t.Error("ReadSnapshot should fail with the wrong password")
+	}
+}
+
+func TestWriteVaultUsageKeepsNoSnapshot(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	const vault, password = "usage.enc", "usage-pass"
+
+	entries := []*model.VaultEntry{snapshotTestEntry("one")}
+	for i := 0; i < 2; i++ {
+		if err := WriteVault(entries, vault, password); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		entries[0].MarkUsed(time.Now().UTC())
+		if err := WriteVaultUsage(entries, vault, password); err != nil {
+			t.Fatalf("WriteVaultUsage: %v", err)
+		}
+	}
+
+	snapshots, err := ListSnapshots(vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("snapshots = %d, want only the one from the second WriteVault", len(snapshots))
+	}
+	got, err := ReadVault(vault, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].LastUsed.IsZero() {
+		t.Error("usage write was not persisted")
+	}
+}
+
+func TestPurgeVaultEntriesScrubsSnapshots(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	const vault, password = "purge.enc", "purge-pass"
+
+	keep, gone := snapshotTestEntry("keep"), snapshotTestEntry("gone")
+	for _, entries := range [][]*model.VaultEntry{{keep}, {keep, gone}, {keep, gone}} {
+		if err := WriteVault(entries, vault, password); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := ListSnapshots(vault)
+	if err != nil || len(before) != 2 {
+		t.Fatalf("snapshots = %d, %v", len(before), err)
+	}
+
+	if err := PurgeVaultEntries([]*model.VaultEntry{keep}, []uint64{gone.ID}, vault, password); err != nil {
+		t.Fatalf("PurgeVaultEntries: %v", err)
+	}
+	after, err := ListSnapshots(vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("snapshots = %d after the purge, want %d", len(after), len(before))
+	}
+	for _, s := range after {
+		entries, err := ReadSnapshot(s, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.ID == gone.ID {
+				t.Errorf("snapshot %s still holds the purged entry", s.ID)
+			}
+		}
+		if len(entries) != 1 || entries[0].ID != keep.ID {
+			t.Errorf("snapshot %s has %d entries, want the kept one", s.ID, len(entries))
+		}
+	}
+	got, err := ReadVault(vault, password)
+	if err != nil || len(got) != 1 || got[0].ID != keep.ID {
+		t.Fatalf("vault after the purge = %d entries, %v", len(got), err)
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	const vault = "prune.enc"
+
+	SetSnapshotRetention(SnapshotRetention{MaxCount: 3})
+	defer SetSnapshotRetention(DefaultSnapshotRetention)
+
+	var entries []*model.VaultEntry
+	for i := 0; i < 6; i++ {
+		entries = append(entries, snapshotTestEntry("svc"))
+		if err := WriteVault(entries, vault, "pw"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshots, _ := ListSnapshots(vault)
+	if len(snapshots) != 3 {
+		t.Fatalf("count retention kept %d snapshots", len(snapshots))
+	}
+
+	// Everything is older than a minute an hour from now, except the newest
+	// snapshot, which is always kept.
+	if err := pruneSnapshots(vault, SnapshotRetention{MaxAge: time.Minute}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, _ = ListSnapshots(vault)
+	if len(snapshots) != 1 {
+		t.Fatalf("age retention kept %d snapshots", len(snapshots))
+	}
+}
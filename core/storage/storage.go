@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -13,13 +14,75 @@ const DefaultVaultFile = "vault.pqdb"
 
 // WriteVault encrypts and writes vault entries using the PQ vault format.
 // The password is the user's master password; all KDF and key material is
-// derived internally on each call.
+// derived internally on each call. The file being replaced is kept as a
+// snapshot first (see SnapshotVaultFile).
 func WriteVault(entries []*model.VaultEntry, vaultPath string, password string) error {
-	plaintext := serializeEntries(entries)
+	vaultData, err := EncodeVault(entries, password)
+	if err != nil {
+		return err
+	}
+
+	return writeVaultData(vaultPath, vaultData)
+}
 
+// WriteVaultUsage writes entries like WriteVault but keeps no snapshot of
+// the file it replaces. It is for writes that only record usage (LastUsed),
+// which happen on every copy, signature and credential lookup and would
+// otherwise push the real restore points out of retention.
+func WriteVaultUsage(entries []*model.VaultEntry, vaultPath string, password string) error {
+	vaultData, err := EncodeVault(entries, password)
+	if err != nil {
+		return err
+	}
+
+	if err := securestorage.WriteVaultFile(vaultPath, vaultData); err != nil {
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	return nil
+}
+
+// PurgeVaultEntries writes entries like WriteVault for a permanent delete of
+// the entries whose IDs are in purged. It keeps no snapshot of the file it
+// replaces and drops the purged entries from the vault's existing snapshots
+// first, so a purged secret does not stay restorable from backups/. A
+// snapshot that predates a master-password change does not open with
+// password; it cannot be rewritten and still needs the old password.
+func PurgeVaultEntries(entries []*model.VaultEntry, purged []uint64, vaultPath string, password string) error {
+	vaultData, err := EncodeVault(entries, password)
+	if err != nil {
+		return err
+	}
+	if err := purgeSnapshotEntries(vaultPath, purged, password); err != nil {
+		return err
+	}
+
+	if err := securestorage.WriteVaultFile(vaultPath, vaultData); err != nil {
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	return nil
+}
+
+// EncodeVault returns the vault file bytes for entries without writing
+// them, for callers that replace several files in one transaction.
+func EncodeVault(entries []*model.VaultEntry, password string) ([]byte, error) {
+	plaintext, err := serializeEntries(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode vault: %w", err)
+	}
+	defer crypto.WipeBytes(plaintext)
 	vaultData, err := crypto.PQVaultEncrypt(plaintext, password)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt vault: %w", err)
+		return nil, fmt.Errorf("failed to encrypt vault: %w", err)
+	}
+	return vaultData, nil
+}
+
+// writeVaultData snapshots the current vault file and replaces it with
+// vaultData. A snapshot failure aborts the write: the previous version must
+// be recoverable before it is overwritten.
+func writeVaultData(vaultPath string, vaultData []byte) error {
+	if err := SnapshotVaultFile(vaultPath); err != nil {
+		return fmt.Errorf("failed to snapshot vault before writing: %w", err)
 	}
 
 	if err := securestorage.WriteVaultFile(vaultPath, vaultData); err != nil {
@@ -31,45 +94,102 @@ func WriteVault(entries []*model.VaultEntry, vaultPath string, password string)
 
 // ReadVault reads and decrypts a vault file.
 // It auto-detects the vault format:
-//   - "PQVT" magic → PQ vault (Argon2id + Kyber-768 KEM + Dilithium3 signature)
-//   - legacy format → auto-migrated on first open (re-encrypted to PQ format in-place)
+//   - "PQVT" magic → PQ vault (Argon2id + ML-KEM-768 KEM + ML-DSA-65 signature;
+//     version 1 files are Kyber-768 + Dilithium3 and are rewritten on the next save)
+//   - legacy format → auto-migrated on first open (re-encrypted to PQ format in-place,
+//     after the legacy file has been snapshotted)
 func ReadVault(vaultPath string, password string) ([]*model.VaultEntry, error) {
 	vaultData, err := securestorage.ReadVaultFile(vaultPath)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, os.ErrNotExist) {
 			return []*model.VaultEntry{}, nil
 		}
 		return nil, fmt.Errorf("failed to read vault file: %w", err)
 	}
 
+	entries, migrated, err := decodeVaultData(vaultData, password)
+	if err != nil {
+		return nil, err
+	}
+	if migrated != nil {
+		_ = writeVaultData(vaultPath, migrated) // best-effort; don't fail the read
+	}
+
+	return entries, nil
+}
+
+// DecodeVaultFile decrypts the vault at vaultPath without migrating or
+// snapshotting it, for callers that must not change the file.
+func DecodeVaultFile(vaultPath string, password string) ([]*model.VaultEntry, error) {
+	vaultData, err := securestorage.ReadVaultFile(vaultPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault file: %w", err)
+	}
+	entries, _, err := decodeVaultData(vaultData, password)
+	return entries, err
+}
+
+// UpgradeVaultKDF seals the vault at vaultPath again when its Argon2id cost
+// is below the current policy (see crypto.CurrentKDFPolicy), and reports
+// whether it did. The entries are not touched; the previous file is kept as
+// a snapshot. Legacy-format vaults are left to ReadVault's migration.
+func UpgradeVaultKDF(vaultPath string, password string) (bool, error) {
+	vaultData, err := securestorage.ReadVaultFile(vaultPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read vault file: %w", err)
+	}
+	if !crypto.IsPQVaultFormat(vaultData) || !crypto.PQVaultKDFOutdated(vaultData) {
+		return false, nil
+	}
+
+	plaintext, err := crypto.PQVaultDecrypt(vaultData, password)
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt vault: %w", err)
+	}
+	defer crypto.WipeBytes(plaintext)
+	newData, err := crypto.PQVaultEncrypt(plaintext, password)
+	if err != nil {
+		return false, fmt.Errorf("failed to encrypt vault: %w", err)
+	}
+	if err := writeVaultData(vaultPath, newData); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// decodeVaultData decrypts and parses vault bytes in either format. For a
+// legacy vault it also returns the same entries re-encrypted in the PQ
+// format, for the caller to write back.
+func decodeVaultData(vaultData []byte, password string) ([]*model.VaultEntry, []byte, error) {
 	var plaintext []byte
+	var migrated []byte
+	var err error
 
 	if crypto.IsPQVaultFormat(vaultData) {
 		plaintext, err = crypto.PQVaultDecrypt(vaultData, password)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt vault: %w", err)
+			return nil, nil, fmt.Errorf("failed to decrypt vault: %w", err)
 		}
 	} else {
 		// Legacy format (Argon2id + HMAC-SHA256 + AES-256-GCM).
-		// Auto-migrate: decrypt with old scheme, re-encrypt with PQ format, write back.
 		plaintext, err = decryptLegacyVault(vaultData, password)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt legacy vault (wrong password?): %w", err)
+			return nil, nil, fmt.Errorf("failed to decrypt legacy vault (wrong password?): %w", err)
 		}
-
-		// Re-encrypt with the new PQ format and write back immediately.
-		newData, migrateErr := crypto.PQVaultEncrypt(plaintext, password)
-		if migrateErr == nil {
-			_ = securestorage.WriteVaultFile(vaultPath, newData) // best-effort; don't fail the read
+		if newData, migrateErr := crypto.PQVaultEncrypt(plaintext, password); migrateErr == nil {
+			migrated = newData
 		}
 	}
 
 	entries, err := deserializeEntries(plaintext)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse vault entries: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse vault entries: %w", err)
 	}
 
-	return entries, nil
+	return entries, migrated, nil
 }
 
 // decryptLegacyVault decrypts a vault file written in the pre-PQ format:
@@ -8,9 +8,15 @@ import (
 	"passquantum/core/model"
 )
 
+// vaultPlaintextMagic opens the decrypted vault payload:
+// [magic][count u32] followed by [len u32][entry] per entry. Each entry is
+// model.VaultEntry.SerializeV2, whose optional extension trailer carries
+// timestamps, password history and folder/tag/favorite metadata.
 var vaultPlaintextMagic = []byte("PQV2")
 
-func serializeEntries(entries []*model.VaultEntry) []byte {
+// serializeEntries fails when an entry cannot be encoded in full, so that
+// a write never silently drops an entry or its history.
+func serializeEntries(entries []*model.VaultEntry) ([]byte, error) {
 	buf := bytes.NewBuffer(make([]byte, 0, 256))
 	buf.Write(vaultPlaintextMagic)
 
@@ -19,12 +25,15 @@ func serializeEntries(entries []*model.VaultEntry) []byte {
 		if entry == nil {
 			continue
 		}
-		encoded := entry.SerializeV2()
+		encoded, err := entry.SerializeV2()
+		if err != nil {
+			return nil, err
+		}
 		_ = binary.Write(buf, binary.BigEndian, uint32(len(encoded)))
 		buf.Write(encoded)
 	}
 
-	return buf.Bytes()
+	return buf.Bytes(), nil
 }
 
 func deserializeEntries(plaintext []byte) ([]*model.VaultEntry, error) {
@@ -20,6 +20,9 @@ func TestWriteReadVaultTypedFormatRoundTrip(t *testing.T) {
 	passwordEntry.KyberCiphertext = []byte{1, 2, 3}
 	passwordEntry.Nonce = []byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
 	passwordEntry.Ciphertext = []byte("enc-password")
+	passwordEntry.Folder = "Work/Dev"
+	passwordEntry.Tags = []string{"ci", "github"}
+	passwordEntry.Favorite = true
 
 	noteEntry := model.NewVaultEntry()
 	noteEntry.Type = model.EntryTypeNote
@@ -51,6 +54,9 @@ func TestWriteReadVaultTypedFormatRoundTrip(t *testing.T) {
 		t.Fatalf("ReadVault() entries length = %d, want 3", len(loaded))
 	}
 
+	if loaded[0].Folder != "Work/Dev" || !loaded[0].Favorite || len(loaded[0].Tags) != 2 {
+		t.Fatalf("password organization = %q %v %v", loaded[0].Folder, loaded[0].Tags, loaded[0].Favorite)
+	}
 	if loaded[1].Type != model.EntryTypeNote {
 		t.Fatalf("note entry type = %d, want %d", loaded[1].Type, model.EntryTypeNote)
 	}
@@ -76,3 +82,38 @@ func TestReadVaultLegacyFormatReturnsError(t *testing.T) {
 		t.Fatal("ReadVault() with legacy format should return an error, got nil")
 	}
 }
+
+// TestWriteVaultRejectsUnencodableEntry verifies that an entry whose
+// history cannot be encoded fails the write instead of losing the history.
+func TestWriteVaultRejectsUnencodableEntry(t *testing.T) {
+	tempDir := t.TempDir()
+	vaultPath := filepath.Join(tempDir, filepath.Base(tempDir)+"-unencodable.pqdb")
+	password := "typed-pass"
+
+	entry := model.NewVaultEntry()
+	entry.Type = model.EntryTypePassword
+	entry.Service = "GitHub"
+	entry.KyberCiphertext = []byte{1, 2, 3}
+	entry.Nonce = []byte{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	entry.Ciphertext = []byte("enc-password")
+	if err := WriteVault([]*model.VaultEntry{entry}, vaultPath, password); err != nil {
+		t.Fatalf("WriteVault() error = %v", err)
+	}
+
+	entry.PasswordHistory = []model.PasswordHistoryEntry{{
+		KyberCiphertext: make([]byte, 0x10000),
+		Nonce:           entry.Nonce,
+		Ciphertext:      []byte("enc-old-password"),
+	}}
+	if err := WriteVault([]*model.VaultEntry{entry}, vaultPath, password); err == nil {
+		t.Fatal("WriteVault() accepted an entry whose history cannot be encoded")
+	}
+
+	loaded, err := ReadVault(vaultPath, password)
+	if err != nil {
+		t.Fatalf("ReadVault() error = %v", err)
+	}
+	if len(loaded) != 1 || len(loaded[0].PasswordHistory) != 0 {
+		t.Fatalf("vault changed by the failed write: %+v", loaded)
+	}
+}
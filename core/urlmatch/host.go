@@ -0,0 +1,125 @@
+// Package urlmatch decides which sites a login belongs to. It parses the
+// URLs and host names found in entries, imports and browser requests the
+// same way everywhere, finds registrable domains with the Public Suffix
+// List, and applies an entry's match rule to a page URL.
+package urlmatch
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Site is a URL or host name taken apart for matching. Host is lower-case
+// ASCII (punycode for international names) without a trailing dot or
+// "www." prefix; Port is explicit or the scheme's default; Path is the
+// escaped path and query, "/" at least.
+type Site struct {
+	Scheme string
+	Host   string
+	Port   string
+	Path   string
+	URL    string
+}
+
+// SameOrigin reports whether s and o have the same scheme, host and port.
+func (s Site) SameOrigin(o Site) bool {
+	return s.Scheme == o.Scheme && s.Host == o.Host && s.Port == o.Port
+}
+
+// Parse reads a URL or a bare host name ("github.com", "localhost:3000").
+// Bare names are taken as https. It reports false when raw has no host.
+func Parse(raw string) (Site, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Site{}, false
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Site{}, false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return Site{}, false
+	}
+	if net.ParseIP(host) == nil {
+		if ascii, err := idna.ToASCII(host); err == nil {
+			host = ascii
+		}
+		host = strings.TrimPrefix(host, "www.")
+	}
+	scheme := strings.ToLower(u.Scheme)
+	port := u.Port()
+	if port == "" {
+		switch scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return Site{Scheme: scheme, Host: host, Port: port, Path: path, URL: u.String()}, true
+}
+
+// Host returns the host name of a URL or host name, without port or
+// "www.", or "" if it has none.
+func Host(raw string) string {
+	s, _ := Parse(raw)
+	return s.Host
+}
+
+// BaseDomain returns the registrable domain of a URL or host name:
+// "https://mail.google.com/inbox" → "google.com", "alice.github.io" →
+// "alice.github.io", "www.amazon.co.uk" → "amazon.co.uk". IP addresses,
+// single-label names such as "localhost" and names that are themselves
+// public suffixes come back as the bare host.
+func BaseDomain(raw string) string {
+	s, ok := Parse(raw)
+	if !ok {
+		return ""
+	}
+	return s.BaseDomain()
+}
+
+// BaseDomain returns the registrable domain of s.Host; see the function of
+// the same name.
+func (s Site) BaseDomain() string {
+	if net.ParseIP(s.Host) != nil || !strings.Contains(s.Host, ".") {
+		return s.Host
+	}
+	if d := Default().RegistrableDomain(s.Host); d != "" {
+		return d
+	}
+	return s.Host
+}
+
+// ServiceSite returns the site an entry's service names: the service
+// itself when it is a host name or URL ("github.com"), or the domain an
+// import appended to the title ("MyBank (chase.com)"). Plain names such as
+// "Gmail" name no site and give "".
+func ServiceSite(service string) string {
+	s := strings.TrimSpace(service)
+	if open := strings.LastIndex(s, " ("); open >= 0 && strings.HasSuffix(s, ")") {
+		s = s[open+2 : len(s)-1]
+	}
+	if s == "" || strings.ContainsAny(s, " \t") {
+		return ""
+	}
+	site, ok := Parse(s)
+	if !ok || (!strings.Contains(site.Host, ".") && site.Host != "localhost") {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package urlmatch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"passquantum/core/model"
+)
+
+// compiled caches the regular expressions of regex rules; a nil value
+// marks a pattern that does not compile.
+var compiled sync.Map
+
+// compileAnchored compiles a regex rule's pattern to match the whole URL,
+// so that "bank\.com" cannot match "https://evil.io/?bank.com". The pattern
+// is compiled on its own first: one that does not, such as "a)|(b", could
+// otherwise close the group and escape the anchors.
+func compileAnchored(pattern string) (*regexp.Regexp, error) {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, err
+	}
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+func compile(pattern string) *regexp.Regexp {
+	if re, ok := compiled.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := compileAnchored(pattern)
+	if err != nil {
+		re = nil
+	}
+	compiled.Store(pattern, re)
+	return re
+}
+
+// Match reports whether an entry with rule is offered on page. site is the
+// entry's own URL or host name, which the base-domain, host and host-port
+// modes compare against when the rule has no pattern. An entry with no
+// site and no pattern matches nothing.
+//
+// A starts-with pattern is a URL: the page must have its scheme, host and
+// port, and a path that starts with its path. A regex pattern must match
+// the whole page URL.
+func Match(rule model.MatchRule, site, page string) bool {
+	p, ok := Parse(page)
+	if !ok {
+		return false
+	}
+	target := rule.Pattern
+	if target == "" {
+		target = site
+	}
+
+	switch rule.Mode {
+	case model.MatchBaseDomain, model.MatchHost, model.MatchHostPort:
+		t, ok := Parse(target)
+		if !ok {
+			return false
+		}
+		switch rule.Mode {
+		case model.MatchHost:
+			return t.Host == p.Host
+		case model.MatchHostPort:
+			return t.Host == p.Host && t.Port == p.Port
+		}
+		return t.BaseDomain() == p.BaseDomain()
+	case model.MatchStartsWith:
+		t, ok := Parse(rule.Pattern)
+		return ok && strings.Contains(rule.Pattern, "://") &&
+			t.SameOrigin(p) && strings.HasPrefix(p.Path, t.Path)
+	case model.MatchRegex:
+		re := compile(rule.Pattern)
+		return rule.Pattern != "" && re != nil && re.MatchString(p.URL)
+	}
+	return false
+}
+
+// ValidateRule checks that rule can match something: a known mode, a
+// pattern where the mode needs one, and a regular expression that
+// compiles.
+func ValidateRule(rule model.MatchRule) error {
+	switch rule.Mode {
+	case model.MatchBaseDomain, model.MatchHost, model.MatchHostPort:
+		if rule.Pattern != "" {
+			if _, ok := Parse(rule.Pattern); !ok {
+				return fmt.Errorf("%q is not a URL or host name", rule.Pattern)
+			}
+		}
+	case model.MatchStartsWith:
+		if _, ok := Parse(rule.Pattern); !ok || !strings.Contains(rule.Pattern, "://") {
+			return errors.New("starts-with needs a URL prefix such as https://example.com/login")
+		}
+	case model.MatchRegex:
+		if rule.Pattern == "" {
+			return errors.New("regex needs a pattern")
+		}
+		if _, err := compileAnchored(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid regular expression: %w", err)
+		}
+	case model.MatchNever:
+	default:
+		return fmt.Errorf("unknown match mode %d", rule.Mode)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package urlmatch
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/idna"
+
+	securestorage "passquantum/internal/storage"
+)
+
+// EmbeddedListDate is the publication date of the Public Suffix List
+// compiled into this build.
+const EmbeddedListDate = "2023-02-09"
+
+// listFileName is the updated list in the vault directory, which replaces
+// the embedded one while it parses.
+const listFileName = "public_suffix_list.dat"
+
+// minRules guards against installing a truncated or unrelated file: the
+// real list has thousands of rules.
+const minRules = 1000
+
+const icannMarker = "===BEGIN ICANN DOMAINS==="
+
+//go:embed data/public_suffix_list.dat
+var embeddedList []byte
+
+// ruleKind flags which kinds of rule a name carries; "*.ck" and "!www.ck"
+// both live under their name with the prefix removed.
+type ruleKind uint8
+
+const (
+	ruleNormal ruleKind = 1 << iota
+	ruleWildcard
+	ruleException
+)
+
+// List is a parsed Public Suffix List: ICANN and private suffixes alike,
+// so "github.io" and "s3.amazonaws.com" are suffixes and every user's site
+// under them is a registrable domain of its own.
+type List struct {
+	rules  map[string]ruleKind
+	source string
+}
+
+// ParseList reads the list's text format. Rules with non-ASCII labels are
+// stored in their punycode form, which is how browsers report host names.
+func ParseList(data []byte, source string) (*List, error) {
+	if !strings.Contains(string(data), icannMarker) {
+		return nil, errors.New("not a Public Suffix List: no ICANN section")
+	}
+	l := &List{rules: make(map[string]ruleKind, 10000), source: source}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		rule, _, _ := strings.Cut(line, " ")
+		kind := ruleNormal
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			kind, rule = ruleException, rule[1:]
+		case strings.HasPrefix(rule, "*."):
+			kind, rule = ruleWildcard, rule[2:]
+		}
+		ascii, err := idna.ToASCII(strings.ToLower(rule))
+		if err != nil || ascii == "" {
+			continue
+		}
+		l.rules[ascii] |= kind
+	}
+	if len(l.rules) < minRules {
+		return nil, fmt.Errorf("Public Suffix List has only %d rules", len(l.rules))
+	}
+	return l, nil
+}
+
+// Rules returns the number of distinct suffix names in the list.
+func (l *List) Rules() int { return len(l.rules) }
+
+// Source says where the list came from: "embedded" or a file path.
+func (l *List) Source() string { return l.source }
+
+// PublicSuffix returns the public suffix of a lower-case ASCII host name.
+// Names no rule covers fall under the implicit "*" rule, so their last
+// label is the suffix.
+func (l *List) PublicSuffix(host string) string {
+	labels := strings.Split(host, ".")
+	suffixLabels := 1
+	for i := range labels {
+		kind := l.rules[strings.Join(labels[i:], ".")]
+		if kind&ruleException != 0 {
+			return strings.Join(labels[i+1:], ".")
+		}
+		n := len(labels) - i
+		if kind&ruleNormal != 0 && n > suffixLabels {
+			suffixLabels = n
+		}
+		if kind&ruleWildcard != 0 && i > 0 && n+1 > suffixLabels {
+			suffixLabels = n + 1
+		}
+	}
+	return strings.Join(labels[len(labels)-suffixLabels:], ".")
+}
+
+// RegistrableDomain returns the public suffix of host plus one label, or ""
+// when host is itself a public suffix.
+func (l *List) RegistrableDomain(host string) string {
+	suffix := l.PublicSuffix(host)
+	if len(host) <= len(suffix) {
+		return ""
+	}
+	rest := host[:len(host)-len(suffix)-1]
+	if i := strings.LastIndexByte(rest, '.'); i >= 0 {
+		rest = rest[i+1:]
+	}
+	return rest + "." + suffix
+}
+
+var (
+	defaultMu   sync.Mutex
+	defaultList *List
+)
+
+// Default returns the list in use: the updated copy in the vault directory
+// if one was installed and still parses, otherwise the embedded list.
+func Default() *List {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultList == nil {
+		defaultList = loadList()
+	}
+	return defaultList
+}
+
+func loadList() *List {
+	if path, err := securestorage.GetSecureFilePath(listFileName); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			if l, err := ParseList(data, path); err == nil {
+				return l
+			}
+		}
+	}
+	l, err := ParseList(embeddedList, "embedded")
+	if err != nil {
+		panic("urlmatch: embedded Public Suffix List: " + err.Error())
+	}
+	return l
+}
+
+// Install checks that data is a usable Public Suffix List, saves it to the
+// vault directory and switches to it. Fetch it from
+// https://publicsuffix.org/list/public_suffix_list.dat.
+func Install(data []byte) (*List, error) {
+	path, err := securestorage.GetSecureFilePath(listFileName)
+	if err != nil {
+		return nil, err
+	}
+	l, err := ParseList(data, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := securestorage.WriteFileAtomic(path, data, 0600); err != nil {
+		return nil, err
+	}
+	defaultMu.Lock()
+	defaultList = l
+	defaultMu.Unlock()
+	return l, nil
+}
+
+// Reset removes an installed list and goes back to the embedded one.
+func Reset() error {
+	path, err := securestorage.GetSecureFilePath(listFileName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	defaultMu.Lock()
+	defaultList = nil
+	defaultMu.Unlock()
+	return nil
+}
@@ -0,0 +1,170 @@
+package urlmatch
+
+import (
+	"strings"
+	"testing"
+
+	"passquantum/core/model"
+)
+
+func TestBaseDomain(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"https://mail.google.com/inbox", "google.com"},
+		{"https://www.amazon.co.uk/dp/B08N5WRWNW", "amazon.co.uk"},
+		{"alice.github.io", "alice.github.io"},
+		{"https://myblog.blogspot.com/", "myblog.blogspot.com"},
+		{"bucket.s3.amazonaws.com", "bucket.s3.amazonaws.com"},
+		{"https://shop.example.com.au", "example.com.au"},
+		{"https://www.city.kawasaki.jp", "city.kawasaki.jp"},
+		{"https://foo.bar.kawasaki.jp", "foo.bar.kawasaki.jp"},
+		{"https://bücher.example.de/", "example.de"},
+		{"https://xn--bcher-kva.de", "xn--bcher-kva.de"},
+		{"github.io", "github.io"},
+		{"co.uk", "co.uk"},
+		{"unknowntld.zzz", "unknowntld.zzz"},
+		{"a.b.unknowntld.zzz", "unknowntld.zzz"},
+		{"localhost:3000", "localhost"},
+		{"http://192.168.1.1:8080/admin", "192.168.1.1"},
+		{"http://[::1]:8080/", "::1"},
+		{"GitHub.COM.", "github.com"},
+		{"My Bank", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := BaseDomain(tt.in); got != tt.want {
+			t.Errorf("BaseDomain(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, ok := Parse("https://WWW.Example.com:8443/login?next=/")
+	if !ok || s.Host != "example.com" || s.Port != "8443" || s.Scheme != "https" {
+		t.Fatalf("Parse = %+v, %v", s, ok)
+	}
+	if s, _ := Parse("http://example.com"); s.Port != "80" {
+		t.Errorf("default http port = %q", s.Port)
+	}
+	if _, ok := Parse("https://"); ok {
+		t.Error("URL without host parsed")
+	}
+}
+
+func TestServiceSite(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"github.com", "github.com"},
+		{"https://login.example.com/sso", "https://login.example.com/sso"},
+		{"MyBank (chase.com)", "chase.com"},
+		{"localhost", "localhost"},
+		{"Gmail", ""},
+		{"My Bank", ""},
+		{"Work (VPN)", ""},
+	}
+	for _, tt := range tests {
+		if got := ServiceSite(tt.in); got != tt.want {
+			t.Errorf("ServiceSite(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rule := func(m model.MatchMode, pattern string) model.MatchRule {
+		return model.MatchRule{Mode: m, Pattern: pattern}
+	}
+	tests := []struct {
+		rule       model.MatchRule
+		site, page string
+		want       bool
+	}{
+		{rule(model.MatchBaseDomain, ""), "github.com", "https://gist.github.com/x", true},
+		{rule(model.MatchBaseDomain, ""), "bank.com", "https://mybank-phish.com", false},
+		{rule(model.MatchBaseDomain, ""), "alice.github.io", "https://bob.github.io", false},
+		{rule(model.MatchBaseDomain, ""), "", "https://github.com", false},
+		{rule(model.MatchBaseDomain, "https://accounts.google.com"), "Google", "https://mail.google.com", true},
+		{rule(model.MatchHost, ""), "https://login.example.com/", "https://login.example.com/sso", true},
+		{rule(model.MatchHost, ""), "login.example.com", "https://example.com", false},
+		{rule(model.MatchHostPort, "https://nas.local:5001"), "", "https://nas.local:5001/", true},
+		{rule(model.MatchHostPort, "https://nas.local:5001"), "", "https://nas.local/", false},
+		{rule(model.MatchHostPort, "router.lan"), "", "http://router.lan", false},
+		{rule(model.MatchStartsWith, "https://example.com/admin"), "example.com", "https://example.com/admin/users", true},
+		{rule(model.MatchStartsWith, "https://example.com/admin"), "example.com", "https://example.com/", false},
+		{rule(model.MatchStartsWith, "https://example.com/admin"), "example.com", "https://example.com.evil.io/admin", false},
+		{rule(model.MatchStartsWith, "https://bank.com"), "", "https://bank.com/login", true},
+		{rule(model.MatchStartsWith, "https://bank.com"), "", "https://bank.com.evil.io/login", false},
+		{rule(model.MatchStartsWith, "https://bank.com"), "", "https://bank.com@evil.io/login", false},
+		{rule(model.MatchStartsWith, "https://bank.com"), "", "https://bank.com:8443/login", false},
+		{rule(model.MatchStartsWith, "https://bank.com/admin"), "", "http://bank.com/admin", false},
+		{rule(model.MatchRegex, `https://(eu|us)\.example\.com/.*`), "", "https://eu.example.com/login", true},
+		{rule(model.MatchRegex, `https://(eu|us)\.example\.com/.*`), "", "https://asia.example.com/login", false},
+		{rule(model.MatchRegex, `bank\.com`), "", "https://evil.io/?bank.com", false},
+		{rule(model.MatchRegex, `https://bank\.com/.*`), "", "https://bank.com/login?next=https://evil.io", true},
+		{rule(model.MatchRegex, `https://bank\.com/.*`), "", "https://evil.io/https://bank.com/", false},
+		{rule(model.MatchRegex, `(`), "", "https://example.com", false},
+		{rule(model.MatchNever, ""), "github.com", "https://github.com", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.rule, tt.site, tt.page); got != tt.want {
+			t.Errorf("Match(%v %q, %q, %q) = %v, want %v", tt.rule.Mode, tt.rule.Pattern, tt.site, tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	valid := []model.MatchRule{
+		{},
+		{Mode: model.MatchHost, Pattern: "login.example.com"},
+		{Mode: model.MatchStartsWith, Pattern: "https://example.com/login"},
+		{Mode: model.MatchRegex, Pattern: `^https://example\.com/`},
+		{Mode: model.MatchNever},
+	}
+	for _, r := range valid {
+		if err := ValidateRule(r); err != nil {
+			t.Errorf("ValidateRule(%+v) = %v", r, err)
+		}
+	}
+	invalid := []model.MatchRule{
+		{Mode: model.MatchStartsWith, Pattern: "example.com"},
+		{Mode: model.MatchRegex},
+		{Mode: model.MatchRegex, Pattern: "("},
+		{Mode: model.MatchRegex, Pattern: "a)|(b"},
+		{Mode: model.MatchMode(42)},
+	}
+	for _, r := range invalid {
+		if err := ValidateRule(r); err == nil {
+			t.Errorf("ValidateRule(%+v) accepted", r)
+		}
+	}
+}
+
+func TestParseListRejectsOtherFiles(t *testing.T) {
+	if _, err := ParseList([]byte("com\nnet\n"), "x"); err == nil {
+		t.Error("accepted a file without the ICANN section")
+	}
+	if _, err := ParseList([]byte("// "+icannMarker+"\ncom\n"), "x"); err == nil || !strings.Contains(err.Error(), "rules") {
+		t.Errorf("accepted a truncated list: %v", err)
+	}
+}
+
+func TestInstallAndReset(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("APPDATA", t.TempDir())
+	t.Cleanup(func() { Reset() })
+
+	// An updated list with one extra private suffix.
+	updated := append(append([]byte(nil), embeddedList...), "\nexample-hosting.test\n"...)
+	l, err := Install(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Default() != l || BaseDomain("me.example-hosting.test") != "me.example-hosting.test" {
+		t.Fatalf("installed list not in use: %s", Default().Source())
+	}
+
+	if err := Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if Default().Source() != "embedded" || BaseDomain("me.example-hosting.test") != "example-hosting.test" {
+		t.Fatalf("after Reset the list is %s", Default().Source())
+	}
+}
@@ -1,91 +1,459 @@
 /* PassQuantum — Background Service Worker */
 
-const API_BASE = 'http://127.0.0.1:8765';
+const NATIVE_HOST = 'com.passquantum.host';
+const NATIVE_TIMEOUT_MS = 15000;
 const PENDING_TTL_MS = 2 * 60 * 1000; // 2 minutes
+const FILL_POLL_MS = 1000;
 
 // Pending credentials survive page navigation (multi-step login flows like GitHub 2FA).
-// Keyed by normalized domain. Cleared on retrieval or after TTL.
+// Keyed by the registrable domain the app reported for the page. Cleared on
+// retrieval or after TTL.
 const pendingCredentials = {};
 
-// --- Authenticated fetch wrapper ---
+// --- Encoding helpers ---
+
+const utf8 = s => new TextEncoder().encode(s);
 
-async function getSecret() {
-  const result = await browser.storage.local.get('pqSecret');
-  return result.pqSecret || null;
+function concatBytes(...parts) {
+  const out = new Uint8Array(parts.reduce((n, p) => n + p.length, 0));
+  let offset = 0;
+  for (const p of parts) {
+    out.set(p, offset);
+    offset += p.length;
+  }
+  return out;
 }
 
-async function apiFetch(path, options = {}) {
-  const secret = await getSecret();
-  if (!secret) {
-    throw new Error('not_paired');
+function toBase64(bytes) {
+  return btoa(String.fromCharCode(...bytes));
+}
+
+function fromBase64(s) {
+  return Uint8Array.from(atob(s || ''), c => c.charCodeAt(0));
+}
+
+function bytesEqual(a, b) {
+  if (a.length !== b.length) return false;
+  let diff = 0;
+  for (let i = 0; i < a.length; i++) diff |= a[i] ^ b[i];
+  return diff === 0;
+}
+
+function bytesToBigInt(bytes) {
+  let n = 0n;
+  for (const b of bytes) n = (n << 8n) | BigInt(b);
+  return n;
+}
+
+function bigIntToBytes(n, length) {
+  const out = new Uint8Array(length);
+  for (let i = length - 1; i >= 0; i--) {
+    out[i] = Number(n & 0xffn);
+    n >>= 8n;
   }
+  return out;
+}
+
+async function hkdf(secret, salt, info, bits) {
+  const key = await crypto.subtle.importKey('raw', secret, 'HKDF', false, ['deriveBits']);
+  return new Uint8Array(await crypto.subtle.deriveBits(
+    { name: 'HKDF', hash: 'SHA-256', salt, info: utf8(info) }, key, bits));
+}
+
+async function hmac(key, message) {
+  const k = await crypto.subtle.importKey('raw', key, { name: 'HMAC', hash: 'SHA-256' }, false, ['sign']);
+  return new Uint8Array(await crypto.subtle.sign('HMAC', k, message));
+}
 
-  const headers = {
-    'X-Secret': secret,
-    'Content-Type': 'application/json',
-    ...(options.headers || {}),
+// --- P-256 arithmetic for SPAKE2 (affine, BigInt) ---
+// WebCrypto has no point addition, which SPAKE2 needs. Mirrors
+// internal/browser/spake2.go.
+
+const P256 = {
+  p: 0xffffffff00000001000000000000000000000000ffffffffffffffffffffffffn,
+  n: 0xffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551n,
+  b: 0x5ac635d8aa3a93e7b3ebbd55769886bc651d06b0cc53b0f63bce3c3e27d2604bn,
+  G: {
+    x: 0x6b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296n,
+    y: 0x4fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f5n,
+  },
+};
+
+// Points nobody knows the discrete log of; see spake2M and spake2N.
+const SPAKE2_M = '04deeba8d81cfbc9a1b6ab467a0297f5cff2289a036c7dfe7cddf20eab4c21a23bc30e395976899332ae742d24053eb3a0bc7f599c46bffa52fb9b92ae11384f91';
+const SPAKE2_N = '04ba65c5807183b514eb87d100e36455067b3151d3645462689429ec7187dd5461738b5ea6db51a9c446b1955279592766a4d1e097f3846d4a5d82c591593de9c9';
+
+const SPAKE2_CLIENT_ID = 'passquantum-extension';
+const SPAKE2_SERVER_ID = 'passquantum-app';
+
+const fieldMod = a => ((a % P256.p) + P256.p) % P256.p;
+
+function fieldInv(a) {
+  let [r0, r1, s0, s1] = [fieldMod(a), P256.p, 1n, 0n];
+  while (r1 !== 0n) {
+    const q = r0 / r1;
+    [r0, r1] = [r1, r0 - q * r1];
+    [s0, s1] = [s1, s0 - q * s1];
+  }
+  return fieldMod(s0);
+}
+
+// null is the point at infinity.
+function pointAdd(P, Q) {
+  if (!P) return Q;
+  if (!Q) return P;
+  let l;
+  if (P.x === Q.x) {
+    if (fieldMod(P.y + Q.y) === 0n) return null;
+    l = fieldMod((3n * P.x * P.x - 3n) * fieldInv(2n * P.y));
+  } else {
+    l = fieldMod((Q.y - P.y) * fieldInv(Q.x - P.x));
+  }
+  const x = fieldMod(l * l - P.x - Q.x);
+  return { x, y: fieldMod(l * (P.x - x) - P.y) };
+}
+
+function pointMul(P, k) {
+  let R = null;
+  for (let i = BigInt(k.toString(2).length) - 1n; i >= 0n; i--) {
+    R = pointAdd(R, R);
+    if ((k >> i) & 1n) R = pointAdd(R, P);
+  }
+  return R;
+}
+
+const pointNeg = P => (P ? { x: P.x, y: fieldMod(-P.y) } : null);
+
+function pointEncode(P) {
+  return concatBytes(new Uint8Array([4]), bigIntToBytes(P.x, 32), bigIntToBytes(P.y, 32));
+}
+
+function pointDecode(bytes) {
+  if (bytes.length !== 65 || bytes[0] !== 4) throw new Error('invalid point');
+  const x = bytesToBigInt(bytes.subarray(1, 33));
+  const y = bytesToBigInt(bytes.subarray(33));
+  if (x >= P256.p || y >= P256.p ||
+      fieldMod(y * y) !== fieldMod(x * x * x - 3n * x + P256.b)) {
+    throw new Error('invalid point');
+  }
+  return { x, y };
+}
+
+const hexToBytes = hex => Uint8Array.from(hex.match(/../g), h => parseInt(h, 16));
+
+// --- SPAKE2 pairing (client half) ---
+// The 6-digit code never leaves the browser: both sides prove they know it
+// and come out with the same pairing key.
+
+async function spake2Start(code) {
+  const w = bytesToBigInt(await hkdf(utf8(code), new Uint8Array(), 'passquantum spake2 w', 384)) % P256.n;
+  let x = 0n;
+  while (x === 0n) {
+    x = bytesToBigInt(crypto.getRandomValues(new Uint8Array(48))) % P256.n;
+  }
+  const M = pointDecode(hexToBytes(SPAKE2_M));
+  const share = pointEncode(pointAdd(pointMul(P256.G, x), pointMul(M, w)));
+  return { w, x, share };
+}
+
+async function spake2Finish(client, serverShare) {
+  const N = pointDecode(hexToBytes(SPAKE2_N));
+  const K = pointMul(pointAdd(pointDecode(serverShare), pointNeg(pointMul(N, client.w))), client.x);
+  if (!K) throw new Error('invalid point');
+
+  const fields = [utf8(SPAKE2_CLIENT_ID), utf8(SPAKE2_SERVER_ID), client.share, serverShare,
+    pointEncode(K), bigIntToBytes(client.w, 32)];
+  const transcript = concatBytes(...fields.flatMap(f => [bigIntToBytes(BigInt(f.length), 8).reverse(), f]));
+  const th = new Uint8Array(await crypto.subtle.digest('SHA-256', transcript));
+
+  const empty = new Uint8Array();
+  return {
+    clientConfirm: await hmac(await hkdf(th, empty, 'passquantum spake2 confirm client', 256), th),
+    serverConfirm: await hmac(await hkdf(th, empty, 'passquantum spake2 confirm server', 256), th),
+    pairingKey: await hkdf(th, empty, 'passquantum spake2 pairing key', 256),
   };
+}
+
+// --- Native messaging ---
+// The browser starts passquantum-native-host, which relays each request to
+// the desktop app. Requests carry an ID so replies may come back in any
+// order.
+
+let nativePort = null;
+let nextRequestId = 1;
+const pendingRequests = new Map();
+
+function connectHost() {
+  const port = browser.runtime.connectNative(NATIVE_HOST);
+  port.onMessage.addListener(msg => {
+    const pending = pendingRequests.get(msg.id);
+    if (!pending) return;
+    pendingRequests.delete(msg.id);
+    clearTimeout(pending.timer);
+    pending.resolve({ status: msg.status, body: msg.body });
+  });
+  port.onDisconnect.addListener(p => {
+    const err = (p && p.error) || browser.runtime.lastError;
+    // Chromium: "Specified native messaging host not found.";
+    // Firefox: "No such native application ...".
+    const reason = err && /not found|no such native|forbidden/i.test(err.message) ? 'host_missing' : 'unreachable';
+    if (nativePort === port) nativePort = null;
+    for (const [id, pending] of pendingRequests) {
+      pendingRequests.delete(id);
+      clearTimeout(pending.timer);
+      pending.reject(new Error(reason));
+    }
+  });
+  return port;
+}
 
-  const response = await fetch(`${API_BASE}${path}`, {
-    ...options,
-    headers,
+// Sends one request to the app and resolves with { status, body }. The
+// host answers 503 while the app is not running.
+async function appRequest(method, path, body) {
+  if (!nativePort) nativePort = connectHost();
+  const id = nextRequestId++;
+  const resp = await new Promise((resolve, reject) => {
+    const timer = setTimeout(() => {
+      pendingRequests.delete(id);
+      reject(new Error('unreachable'));
+    }, NATIVE_TIMEOUT_MS);
+    pendingRequests.set(id, { resolve, reject, timer });
+    nativePort.postMessage({ id, method, path, body });
   });
+  if (resp.status === 503) {
+    throw new Error('unreachable');
+  }
+  return resp;
+}
 
-  if (response.status === 401) {
+function appError(resp) {
+  return new Error((resp.body && resp.body.error) || `HTTP ${resp.status}`);
+}
+
+const isOk = resp => resp.status >= 200 && resp.status < 300;
+
+// --- Encrypted session ---
+// An ephemeral ECDH exchange authenticated with the pairing key; every
+// call is then one AES-GCM message each way. Mirrors session.go. The
+// session lives only in memory and is opened again when the app has ended
+// it (lock, idle timeout, restart).
+
+let currentSession = null;
+
+async function getPairing() {
+  const result = await browser.storage.local.get(['pqPairing', 'pqSecret']);
+  if (result.pqSecret) {
+    // Static secret from before encrypted sessions; the app no longer accepts it.
     await browser.storage.local.remove('pqSecret');
+  }
+  return result.pqPairing || null;
+}
+
+async function forgetPairing() {
+  currentSession = null;
+  await browser.storage.local.remove('pqPairing');
+}
+
+// adoptRotatedKey stores the key the app handed over after a key rotation.
+// The next session is opened with it, which retires the old key.
+async function adoptRotatedKey(pairing, key) {
+  currentSession = null;
+  await browser.storage.local.set({ pqPairing: { ...pairing, key } });
+}
+
+async function openSession(pairing) {
+  const pairingKey = fromBase64(pairing.key);
+  const ecdh = { name: 'ECDH', namedCurve: 'P-256' };
+  const keyPair = await crypto.subtle.generateKey(ecdh, false, ['deriveBits']);
+  const ephemeral = new Uint8Array(await crypto.subtle.exportKey('raw', keyPair.publicKey));
+  const mac = await hmac(pairingKey, concatBytes(utf8('passquantum session client' + pairing.clientId), ephemeral));
+
+  const resp = await appRequest('POST', '/vault/session', {
+    client_id: pairing.clientId, ephemeral: toBase64(ephemeral), mac: toBase64(mac),
+  });
+  if (resp.status === 401) {
+    await forgetPairing();
     throw new Error('auth_failed');
   }
-  if (response.status === 423) {
+  if (resp.status === 423) {
     throw new Error('vault_locked');
   }
-  if (!response.ok) {
-    const body = await response.json().catch(() => ({}));
-    throw new Error(body.error || `HTTP ${response.status}`);
+  if (!isOk(resp)) {
+    throw appError(resp);
+  }
+
+  const data = resp.body;
+  const serverEphemeral = fromBase64(data.ephemeral);
+  const expected = await hmac(pairingKey, concatBytes(
+    utf8('passquantum session server' + pairing.clientId), ephemeral, serverEphemeral, utf8(data.session_id)));
+  if (!bytesEqual(expected, fromBase64(data.mac))) {
+    throw new Error('auth_failed');
   }
-  return response;
+
+  const serverKey = await crypto.subtle.importKey('raw', serverEphemeral, ecdh, false, []);
+  const shared = new Uint8Array(await crypto.subtle.deriveBits({ name: 'ECDH', public: serverKey }, keyPair.privateKey, 256));
+  const info = concatBytes(utf8('passquantum session keys' + pairing.clientId), ephemeral, serverEphemeral, utf8(data.session_id));
+  const secret = await crypto.subtle.importKey('raw', shared, 'HKDF', false, ['deriveBits']);
+  const okm = new Uint8Array(await crypto.subtle.deriveBits(
+    { name: 'HKDF', hash: 'SHA-256', salt: pairingKey, info }, secret, 512));
+
+  return {
+    id: data.session_id,
+    send: await crypto.subtle.importKey('raw', okm.subarray(0, 32), 'AES-GCM', false, ['encrypt']),
+    recv: await crypto.subtle.importKey('raw', okm.subarray(32), 'AES-GCM', false, ['decrypt']),
+    seq: 0,
+  };
+}
+
+function callNonce(seq) {
+  return concatBytes(new Uint8Array(4), bigIntToBytes(BigInt(seq), 8));
+}
+
+function callAAD(sessionId, seq) {
+  return concatBytes(utf8(sessionId), bigIntToBytes(BigInt(seq), 8));
+}
+
+// Sends one call in session; returns null when the app has ended it.
+async function sessionCall(session, method, path, body) {
+  const seq = ++session.seq;
+  const message = { method, path };
+  if (body !== undefined) message.body = JSON.parse(body);
+  const payload = new Uint8Array(await crypto.subtle.encrypt(
+    { name: 'AES-GCM', iv: callNonce(seq), additionalData: callAAD(session.id, seq) },
+    session.send, utf8(JSON.stringify(message))));
+
+  const resp = await appRequest('POST', '/vault/call', { session_id: session.id, seq, payload: toBase64(payload) });
+  if (resp.status === 401) return null;
+  if (!isOk(resp)) {
+    throw appError(resp);
+  }
+
+  const data = resp.body;
+  const plaintext = await crypto.subtle.decrypt(
+    { name: 'AES-GCM', iv: callNonce(seq), additionalData: callAAD(session.id, seq) },
+    session.recv, fromBase64(data.payload));
+  return JSON.parse(new TextDecoder().decode(plaintext));
+}
+
+// --- Authenticated fetch wrapper ---
+// Runs path inside the encrypted session and returns the result as a
+// Response, so callers read it as if they had fetched the route directly.
+
+async function apiFetch(path, options = {}) {
+  const pairing = await getPairing();
+  if (!pairing) {
+    throw new Error('not_paired');
+  }
+
+  const method = options.method || 'GET';
+  let result = null;
+  for (let attempt = 0; attempt < 2 && !result; attempt++) {
+    if (!currentSession) {
+      currentSession = await openSession(pairing);
+    }
+    result = await sessionCall(currentSession, method, path, options.body);
+    if (!result) currentSession = null;
+  }
+  if (!result) {
+    throw new Error('auth_failed');
+  }
+  if (result.rekey) {
+    await adoptRotatedKey(pairing, result.rekey);
+  }
+
+  if (result.status === 423) {
+    throw new Error('vault_locked');
+  }
+  if (!isOk(result)) {
+    throw appError(result);
+  }
+  return new Response(JSON.stringify(result.body), {
+    status: result.status,
+    headers: { 'Content-Type': 'application/json' },
+  });
 }
 
 // --- Status check (no auth required) ---
 
 async function checkStatus() {
   try {
-    const resp = await fetch(`${API_BASE}/vault/status`, {
-      headers: { 'Content-Type': 'application/json' },
-    });
-    return await resp.json();
-  } catch {
-    return { unlocked: false, error: 'unreachable' };
+    const resp = await appRequest('GET', '/vault/status');
+    return resp.body;
+  } catch (err) {
+    return { unlocked: false, error: err.message === 'host_missing' ? 'host_missing' : 'unreachable' };
   }
 }
 
 // --- Pairing ---
 
+async function pairPost(body) {
+  const resp = await appRequest('POST', '/vault/pair', body);
+  const data = resp.body || {};
+  if (!isOk(resp)) {
+    throw new Error(data.error || 'Pairing failed');
+  }
+  return data;
+}
+
 async function initiatePairing() {
-  const resp = await fetch(`${API_BASE}/vault/pair`, {
-    method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({}),
+  return await pairPost({});
+}
+
+// detectBrowser names the browser for the app's list of paired clients.
+function detectBrowser() {
+  const ua = navigator.userAgent;
+  if (ua.includes('Firefox/')) return 'firefox';
+  if (ua.includes('Edg/')) return 'edge';
+  const brands = (navigator.userAgentData && navigator.userAgentData.brands) || [];
+  if (brands.some(b => b.brand === 'Google Chrome')) return 'chrome';
+  return 'chromium';
+}
+
+async function completePairing(code, name) {
+  const client = await spake2Start(code);
+  const exchange = await pairPost({ share: toBase64(client.share) });
+  const keys = await spake2Finish(client, fromBase64(exchange.share));
+  if (!bytesEqual(keys.serverConfirm, fromBase64(exchange.confirm))) {
+    throw new Error('Wrong code');
+  }
+
+  const data = await pairPost({
+    confirm: toBase64(keys.clientConfirm),
+    name: name || '',
+    browser: detectBrowser(),
   });
-  return await resp.json();
+  currentSession = null;
+  await browser.storage.local.set({
+    pqPairing: { clientId: data.client_id, key: toBase64(keys.pairingKey) },
+  });
+  return data;
 }
 
-async function completePairing(token) {
-  const resp = await fetch(`${API_BASE}/vault/pair`, {
+// --- Fill ---
+
+// Asks the app to release a credential. The app shows a confirmation unless
+// the site has an "always allow" grant; the secret is then collected once
+// with the short-lived request ID.
+async function requestFill(url, entryId) {
+  const resp = await apiFetch('/vault/fill', {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({ token }),
+    body: JSON.stringify({ url, entry_id: entryId }),
   });
+  const { request_id: requestId, expires_in: expiresIn } = await resp.json();
 
-  if (!resp.ok) {
-    const body = await resp.json().catch(() => ({}));
-    throw new Error(body.error || 'Pairing failed');
-  }
-
-  const data = await resp.json();
-  if (data.secret) {
-    await browser.storage.local.set({ pqSecret: data.secret });
+  const deadline = Date.now() + expiresIn * 1000;
+  while (Date.now() < deadline) {
+    const result = await apiFetch('/vault/fill/result', {
+      method: 'POST',
+      body: JSON.stringify({ request_id: requestId }),
+    });
+    if (result.status === 200) {
+      const { credential } = await result.json();
+      return credential;
+    }
+    await new Promise(resolve => setTimeout(resolve, FILL_POLL_MS));
   }
-  return data;
+  throw new Error('fill_timeout');
 }
 
 // --- Message handler from content script / popup ---
@@ -102,9 +470,9 @@ browser.runtime.onMessage.addListener((msg, sender, sendResponse) => {
 
 const messageHandlers = {
   CHECK_STATUS: async () => {
-    const secret = await getSecret();
+    const pairing = await getPairing();
     const status = await checkStatus();
-    return { ...status, paired: !!secret };
+    return { ...status, paired: !!pairing };
   },
 
   INITIATE_PAIRING: async () => {
@@ -112,15 +480,37 @@ const messageHandlers = {
   },
 
   COMPLETE_PAIRING: async (msg) => {
-    return await completePairing(msg.token);
+    return await completePairing(msg.token, msg.name);
+  },
+
+  GET_CLIENT: async () => {
+    const resp = await apiFetch('/vault/client');
+    return await resp.json();
+  },
+
+  UNPAIR: async () => {
+    await apiFetch('/vault/client', { method: 'DELETE' });
+    await forgetPairing();
+    return { success: true };
   },
 
   FORM_SUBMITTED: async (msg) => {
     try {
-      const secret = await getSecret();
-      if (!secret) return { action: 'skip', reason: 'not_paired' };
+      const pairing = await getPairing();
+      if (!pairing) return { action: 'skip', reason: 'not_paired' };
+
+      // A browser the app has made read-only never offers to save.
+      const clientResp = await apiFetch('/vault/client');
+      const { permissions } = await clientResp.json();
+      if (!permissions || !permissions.save) {
+        return { action: 'skip', reason: 'read_only' };
+      }
 
-      const domain = normalizeDomain(msg.domain);
+      // Check if credentials exist. The app also reports the page's
+      // registrable domain, which the never-save list and pending
+      // credentials are keyed by.
+      const existsResp = await apiFetch(`/vault/exists?url=${encodeURIComponent(msg.url)}`);
+      const { domain, found, credentials } = await existsResp.json();
 
       // Check never-save list
       const nsResp = await apiFetch('/vault/never-save');
@@ -129,10 +519,6 @@ const messageHandlers = {
         return { action: 'skip', reason: 'never_save' };
       }
 
-      // Check if credentials exist
-      const existsResp = await apiFetch(`/vault/exists?domain=${encodeURIComponent(domain)}`);
-      const { found, credentials } = await existsResp.json();
-
       let result;
       if (found) {
         const match = credentials.find(c => c.username === msg.username);
@@ -165,8 +551,8 @@ const messageHandlers = {
   },
 
   CHECK_PENDING: async (msg) => {
-    const domain = normalizeDomain(msg.domain);
-    const entry = pendingCredentials[domain];
+    const domain = Object.keys(pendingCredentials).find(d => hostInDomain(msg.host, d));
+    const entry = domain && pendingCredentials[domain];
     if (!entry) return { hasPending: false };
 
     if (Date.now() - entry.timestamp > PENDING_TTL_MS) {
@@ -187,8 +573,7 @@ const messageHandlers = {
   },
 
   DISMISS_PENDING: async (msg) => {
-    const domain = normalizeDomain(msg.domain);
-    delete pendingCredentials[domain];
+    delete pendingCredentials[msg.domain];
     return { cleared: true };
   },
 
@@ -227,36 +612,30 @@ const messageHandlers = {
     return await resp.json();
   },
 
+  FIND_CREDENTIALS: async (msg) => {
+    const resp = await apiFetch(`/vault/exists?url=${encodeURIComponent(msg.url)}`);
+    return await resp.json();
+  },
+
+  FILL_CREDENTIAL: async (msg) => {
+    const credential = await requestFill(msg.url, msg.entryId);
+    await browser.tabs.sendMessage(msg.tabId, { type: 'FILL', credential });
+    return { filled: true };
+  },
+
   GET_NEVER_SAVE_LIST: async () => {
     const resp = await apiFetch('/vault/never-save');
     return await resp.json();
   },
 };
 
-// --- Domain normalization (mirrors Go logic) ---
+// --- Domains ---
 
-function normalizeDomain(raw) {
-  if (!raw) return '';
-  try {
-    if (!raw.includes('://')) raw = 'https://' + raw;
-    const url = new URL(raw);
-    let host = url.hostname;
-    if (!host) return raw;
-    if (host === 'localhost') return host;
-    if (/^\d+\.\d+\.\d+\.\d+$/.test(host)) return host;
-
-    const parts = host.split('.');
-    if (parts.length <= 2) return host;
-
-    const knownSLDs = ['co', 'com', 'org', 'net', 'ac', 'gov', 'edu', 'mil'];
-    const last = parts[parts.length - 1];
-    const secondLast = parts[parts.length - 2];
-
-    if (last.length === 2 && knownSLDs.includes(secondLast)) {
-      return parts.slice(-3).join('.');
-    }
-    return parts.slice(-2).join('.');
-  } catch {
-    return raw;
-  }
+// The app decides which logins a page gets, using the Public Suffix List and
+// each entry's match rule; the extension only sends it the page URL. This
+// check just tells whether a host belongs to a domain the app reported.
+function hostInDomain(host, domain) {
+  if (!host || !domain) return false;
+  host = host.toLowerCase().replace(/\.$/, '');
+  return host === domain || host.endsWith('.' + domain);
 }
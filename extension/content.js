@@ -137,11 +137,12 @@
 
     browser.runtime.sendMessage({
       type: 'FORM_SUBMITTED',
-      domain: location.hostname,
+      url: location.href,
       username,
       password,
     }).then((response) => {
       if (!response || response.action === 'skip') return;
+      creds.domain = response.domain;
       if (response.action === 'prompt_save') {
         showBanner('save', response.domain, response.username, null, creds);
       } else if (response.action === 'prompt_update') {
@@ -152,6 +153,40 @@
     });
   }
 
+  // --- Autofill ---
+
+  browser.runtime.onMessage.addListener((msg) => {
+    if (msg.type === 'FILL' && msg.credential) {
+      fillCredential(msg.credential);
+      return Promise.resolve({ filled: true });
+    }
+  });
+
+  function fillCredential(cred) {
+    const passwordField = Array.from(
+      document.querySelectorAll('input[type="password"]')
+    ).find(isVisible);
+    if (passwordField) {
+      const usernameField = findUsernameField(passwordField.form || document, passwordField);
+      if (usernameField && cred.username) setFieldValue(usernameField, cred.username);
+      setFieldValue(passwordField, cred.password);
+    }
+
+    if (cred.totp) {
+      const otpField = document.querySelector('input[autocomplete="one-time-code"]');
+      if (otpField && isVisible(otpField)) setFieldValue(otpField, cred.totp);
+    }
+  }
+
+  // Sets the value the way typing would, so frameworks that track input
+  // events see it.
+  function setFieldValue(field, value) {
+    field.focus();
+    field.value = value;
+    field.dispatchEvent(new Event('input', { bubbles: true }));
+    field.dispatchEvent(new Event('change', { bubbles: true }));
+  }
+
   // --- Scan & Observe ---
 
   function scanForms() {
@@ -180,7 +215,7 @@
   function checkPendingCredentials() {
     browser.runtime.sendMessage({
       type: 'CHECK_PENDING',
-      domain: location.hostname,
+      host: location.hostname,
     }).then((response) => {
       if (!response || !response.hasPending) return;
 
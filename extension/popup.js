@@ -10,6 +10,7 @@ async function init() {
   const connectedSection = document.getElementById('connected-section');
   const btnPair = document.getElementById('btn-pair');
   const pairingInput = document.getElementById('pairing-input');
+  const pairName = document.getElementById('pair-name');
   const pairCode = document.getElementById('pair-code');
   const btnConfirmPair = document.getElementById('btn-confirm-pair');
   const pairError = document.getElementById('pair-error');
@@ -17,9 +18,11 @@ async function init() {
   // Check status
   const status = await browser.runtime.sendMessage({ type: 'CHECK_STATUS' });
 
-  if (status.error === 'unreachable') {
+  if (status.error === 'unreachable' || status.error === 'host_missing') {
     statusDot.className = 'status-dot disconnected';
-    statusText.textContent = 'PassQuantum not running';
+    statusText.textContent = status.error === 'host_missing'
+      ? 'Run passquantum-native-host install'
+      : 'PassQuantum not running';
     pairingSection.classList.add('hidden');
     connectedSection.classList.add('hidden');
     return;
@@ -50,7 +53,9 @@ async function init() {
     }
     pairingSection.classList.add('hidden');
     connectedSection.classList.remove('hidden');
+    loadSiteCredentials();
     loadNeverSaveList();
+    loadClientInfo();
   }
 
   // Pairing flow
@@ -59,14 +64,15 @@ async function init() {
     btnPair.textContent = 'Connecting...';
 
     try {
-      await browser.runtime.sendMessage({ type: 'INITIATE_PAIRING' });
+      const result = await browser.runtime.sendMessage({ type: 'INITIATE_PAIRING' });
+      if (result && result.error) throw new Error(result.error);
       pairingInput.classList.remove('hidden');
       btnPair.classList.add('hidden');
-      pairCode.focus();
+      pairName.focus();
     } catch (err) {
       btnPair.disabled = false;
       btnPair.textContent = 'Connect to PassQuantum';
-      showPairError('Could not reach PassQuantum');
+      showPairError(err.message === 'Failed to fetch' ? 'Could not reach PassQuantum' : err.message);
     }
   });
 
@@ -84,6 +90,7 @@ async function init() {
       const result = await browser.runtime.sendMessage({
         type: 'COMPLETE_PAIRING',
         token: code,
+        name: pairName.value.trim(),
       });
 
       if (result.error) {
@@ -92,12 +99,14 @@ async function init() {
         return;
       }
 
-      if (result.secret || result.status === 'paired') {
+      if (result.status === 'paired') {
         statusDot.className = 'status-dot connected';
         statusText.textContent = 'Connected';
         pairingSection.classList.add('hidden');
         connectedSection.classList.remove('hidden');
+        loadSiteCredentials();
         loadNeverSaveList();
+        loadClientInfo();
       }
     } catch (err) {
       showPairError('Pairing failed: ' + err.message);
@@ -109,12 +118,96 @@ async function init() {
     if (e.key === 'Enter') btnConfirmPair.click();
   });
 
+  pairName.addEventListener('keydown', (e) => {
+    if (e.key === 'Enter') pairCode.focus();
+  });
+
+  document.getElementById('btn-unpair').addEventListener('click', async () => {
+    const result = await browser.runtime.sendMessage({ type: 'UNPAIR' });
+    if (result && result.error) return;
+    window.close();
+  });
+
   function showPairError(msg) {
     pairError.textContent = msg;
     pairError.classList.remove('hidden');
   }
 }
 
+async function loadSiteCredentials() {
+  const listEl = document.getElementById('site-credentials');
+  const emptyEl = document.getElementById('site-credentials-empty');
+
+  try {
+    const [tab] = await browser.tabs.query({ active: true, currentWindow: true });
+    if (!tab || !tab.url || !/^https?:/.test(tab.url)) return;
+
+    const result = await browser.runtime.sendMessage({
+      type: 'FIND_CREDENTIALS',
+      url: tab.url,
+    });
+    const credentials = result.credentials || [];
+
+    listEl.innerHTML = '';
+
+    if (credentials.length === 0) {
+      emptyEl.classList.remove('hidden');
+      return;
+    }
+
+    emptyEl.classList.add('hidden');
+
+    for (const cred of credentials) {
+      const li = document.createElement('li');
+
+      const span = document.createElement('span');
+      span.textContent = cred.username || cred.service;
+
+      // The app asks for confirmation before releasing the password; the
+      // background worker fills the page once it is approved.
+      const btn = document.createElement('button');
+      btn.className = 'btn btn-ghost btn-sm';
+      btn.textContent = 'Fill';
+      btn.addEventListener('click', () => {
+        btn.disabled = true;
+        btn.textContent = 'Confirm in app';
+        browser.runtime.sendMessage({
+          type: 'FILL_CREDENTIAL',
+          tabId: tab.id,
+          url: tab.url,
+          entryId: cred.id,
+        }).then((response) => {
+          if (response && !response.error) window.close();
+          btn.textContent = 'Denied';
+        });
+      });
+
+      li.appendChild(span);
+      li.appendChild(btn);
+      listEl.appendChild(li);
+    }
+  } catch {
+    emptyEl.textContent = 'Could not load logins';
+    emptyEl.classList.remove('hidden');
+  }
+}
+
+// Shows the name the app lists this browser under and what it may do.
+// Permissions are changed in PassQuantum's settings.
+async function loadClientInfo() {
+  const infoEl = document.getElementById('client-info');
+  try {
+    const client = await browser.runtime.sendMessage({ type: 'GET_CLIENT' });
+    if (client.error) return;
+    const allowed = [];
+    if (client.permissions.save) allowed.push('save');
+    if (client.permissions.fill) allowed.push('fill');
+    infoEl.textContent = `${client.name} (${allowed.length ? allowed.join(', ') : 'read-only'})`;
+  } catch {
+    infoEl.textContent = 'Unknown';
+  }
+}
+
 async function loadNeverSaveList() {
   const listEl = document.getElementById('never-save-list');
   const emptyEl = document.getElementById('never-save-empty');
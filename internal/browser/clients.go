@@ -0,0 +1,372 @@
+package browser
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// clientsFileName is the sealed file holding the paired clients and
+	// their keys; it opens only while the app is unlocked.
+	clientsFileName    = "browser_pairings"
+	clientsFileVersion = 2
+	clientIDBytes      = 16
+	maxClientNameLen   = 64
+)
+
+var errUnknownClient = errors.New("no such paired browser")
+
+// ClientPermissions says what a paired browser may do beyond reading which
+// sites have saved logins. A client with neither is read-only.
+type ClientPermissions struct {
+	Save bool `json:"save"`
+	Fill bool `json:"fill"`
+}
+
+// Client describes a paired browser for the settings screen. It never
+// carries the client's key.
+type Client struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Browser     string            `json:"browser"`
+	PairedAt    time.Time         `json:"paired_at"`
+	LastSeen    time.Time         `json:"last_seen"`
+	Permissions ClientPermissions `json:"permissions"`
+	// RekeyPending is set from a key rotation until the client has
+	// opened a session with its new key.
+	RekeyPending bool `json:"rekey_pending"`
+}
+
+// clientRecord is one paired browser as stored. Sessions are authenticated
+// with Key; after a rotation PendingKey is handed to the client inside its
+// next session and replaces Key once the client opens a session with it.
+type clientRecord struct {
+	ClientID    string            `json:"client_id"`
+	Name        string            `json:"name,omitempty"`
+	Browser     string            `json:"browser,omitempty"`
+	Key         []byte            `json:"key"`
+	PendingKey  []byte            `json:"pending_key,omitempty"`
+	PairedAt    time.Time         `json:"paired_at"`
+	LastSeen    time.Time         `json:"last_seen"`
+	Permissions ClientPermissions `json:"permissions"`
+}
+
+func (c *clientRecord) info() Client {
+	return Client{
+		ID:           c.ClientID,
+		Name:         c.Name,
+		Browser:      c.Browser,
+		PairedAt:     c.PairedAt,
+		LastSeen:     c.LastSeen,
+		Permissions:  c.Permissions,
+		RekeyPending: c.PendingKey != nil,
+	}
+}
+
+// withKey returns a copy of c that authenticates with key.
+func (c *clientRecord) withKey(key []byte) *clientRecord {
+	cp := *c
+	cp.Key = key
+	return &cp
+}
+
+// clientsFile is the sealed file. Version 1 held only the key and pairing
+// time of each client, under "pairings".
+type clientsFile struct {
+	Version  int            `json:"version"`
+	Clients  []clientRecord `json:"clients"`
+	Pairings []clientRecord `json:"pairings,omitempty"`
+}
+
+// clientRegistry reads and writes the paired clients through the vault
+// service, which keeps them sealed.
+type clientRegistry struct {
+	mu    sync.Mutex
+	vault VaultService
+}
+
+func (cr *clientRegistry) loadLocked() ([]clientRecord, error) {
+	data, err := cr.vault.LoadPairingData()
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var f clientsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse paired clients: %w", err)
+	}
+	switch f.Version {
+	case clientsFileVersion:
+		return f.Clients, nil
+	case 1:
+		// Clients paired before permissions existed could save and fill.
+		for i := range f.Pairings {
+			f.Pairings[i].Name = "Browser extension"
+			f.Pairings[i].Permissions = ClientPermissions{Save: true, Fill: true}
+		}
+		return f.Pairings, nil
+	}
+	return nil, fmt.Errorf("paired clients have unsupported version %d", f.Version)
+}
+
+func (cr *clientRegistry) saveLocked(clients []clientRecord) error {
+	data, err := json.Marshal(clientsFile{Version: clientsFileVersion, Clients: clients})
+	if err != nil {
+		return err
+	}
+	return cr.vault.SavePairingData(data)
+}
+
+func (cr *clientRegistry) list() ([]Client, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	clients, err := cr.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Client, len(clients))
+	for i := range clients {
+		out[i] = clients[i].info()
+	}
+	return out, nil
+}
+
+// lookup returns the client with id, or nil if there is none.
+func (cr *clientRegistry) lookup(id string) (*clientRecord, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	clients, err := cr.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+	for i := range clients {
+		if clients[i].ClientID == id {
+			return &clients[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// add records a newly paired client and returns it. New clients may save
+// and fill; the user can narrow that in settings.
+func (cr *clientRegistry) add(key []byte, name, browserName string) (*clientRecord, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	clients, err := cr.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, clientIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generate client ID: %w", err)
+	}
+	browserName = sanitizeClientName(browserName, "unknown")
+	c := clientRecord{
+		ClientID:    hex.EncodeToString(id),
+		Name:        sanitizeClientName(name, browserName),
+		Browser:     browserName,
+		Key:         key,
+		PairedAt:    time.Now().UTC(),
+		Permissions: ClientPermissions{Save: true, Fill: true},
+	}
+	if err := cr.saveLocked(append(clients, c)); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// update applies fn to the client with id and saves the result.
+func (cr *clientRegistry) update(id string, fn func(*clientRecord) error) (*clientRecord, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	clients, err := cr.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+	for i := range clients {
+		if clients[i].ClientID != id {
+			continue
+		}
+		if err := fn(&clients[i]); err != nil {
+			return nil, err
+		}
+		if err := cr.saveLocked(clients); err != nil {
+			return nil, err
+		}
+		return &clients[i], nil
+	}
+	return nil, errUnknownClient
+}
+
+func (cr *clientRegistry) remove(id string) error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	clients, err := cr.loadLocked()
+	if err != nil {
+		return err
+	}
+	for i := range clients {
+		if clients[i].ClientID == id {
+			return cr.saveLocked(append(clients[:i], clients[i+1:]...))
+		}
+	}
+	return errUnknownClient
+}
+
+// authenticate finds the key a session handshake was made with: the
+// client's key, or the pending key from a rotation, which then replaces
+// it. It records the handshake as the client's last contact and returns
+// the client holding the key that was used, or nil if neither matched.
+func (cr *clientRegistry) authenticate(id string, clientEphemeral, mac []byte) (*clientRecord, error) {
+	c, err := cr.lookup(id)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	var used []byte
+	switch {
+	case validHandshakeMAC(c, clientEphemeral, mac):
+		used = c.Key
+	case c.PendingKey != nil && validHandshakeMAC(c.withKey(c.PendingKey), clientEphemeral, mac):
+		used = c.PendingKey
+	default:
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	c, err = cr.update(id, func(c *clientRecord) error {
+		if c.PendingKey != nil && bytes.Equal(c.PendingKey, used) {
+			c.Key, c.PendingKey = c.PendingKey, nil
+			log.Printf("[Browser] Client %s switched to its rotated key", c.ClientID)
+		}
+		c.LastSeen = now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.withKey(used), nil
+}
+
+// sanitizeClientName trims a name sent by the extension to one short
+// printable line, or returns fallback if nothing is left.
+func sanitizeClientName(name, fallback string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > maxClientNameLen {
+		name = string(runes[:maxClientNameLen])
+	}
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+// permission is what an API call needs from the calling client.
+type permission int
+
+const (
+	permRead permission = iota
+	permSave
+	permFill
+)
+
+// callPermission classifies an API call by its method and path.
+func callPermission(method, path string) permission {
+	switch {
+	case path == "/vault/fill" || strings.HasPrefix(path, "/vault/fill/"):
+		return permFill
+	case path == "/vault/save" || strings.HasPrefix(path, "/vault/update/"):
+		return permSave
+	case path == "/vault/never-save" && method != http.MethodGet:
+		return permSave
+	}
+	return permRead
+}
+
+func (p ClientPermissions) allow(perm permission) bool {
+	switch perm {
+	case permSave:
+		return p.Save
+	case permFill:
+		return p.Fill
+	}
+	return true
+}
+
+// --- Managing clients from the app ---
+
+// Clients lists the paired browsers. The app must be unlocked.
+func (s *Server) Clients() ([]Client, error) {
+	return s.clients.list()
+}
+
+// RevokeClient unpairs a browser and ends its sessions. It has to pair
+// again to reconnect.
+func (s *Server) RevokeClient(id string) error {
+	if err := s.clients.remove(id); err != nil {
+		return err
+	}
+	s.sessions.endClient(id)
+	log.Printf("[Browser] Revoked client %s", id)
+	return nil
+}
+
+// RotateClientKey gives a browser a new key. The new key reaches the
+// extension inside its next session and replaces the old one as soon as
+// the extension opens a session with it; until then the old key still
+// works. A key that may have leaked calls for RevokeClient and pairing
+// again instead.
+func (s *Server) RotateClientKey(id string) error {
+	key := make([]byte, pairingKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("generate client key: %w", err)
+	}
+	if _, err := s.clients.update(id, func(c *clientRecord) error {
+		c.PendingKey = key
+		return nil
+	}); err != nil {
+		return err
+	}
+	s.sessions.endClient(id)
+	log.Printf("[Browser] Rotating the key of client %s", id)
+	return nil
+}
+
+// SetClientPermissions changes what a browser may do. Its open sessions
+// end, so the change applies to the next call.
+func (s *Server) SetClientPermissions(id string, perms ClientPermissions) error {
+	if _, err := s.clients.update(id, func(c *clientRecord) error {
+		c.Permissions = perms
+		return nil
+	}); err != nil {
+		return err
+	}
+	s.sessions.endClient(id)
+	log.Printf("[Browser] Client %s may save: %t, fill: %t", id, perms.Save, perms.Fill)
+	return nil
+}
+
+// RenameClient changes the name a browser is listed under.
+func (s *Server) RenameClient(id, name string) error {
+	_, err := s.clients.update(id, func(c *clientRecord) error {
+		c.Name = sanitizeClientName(name, c.Name)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,178 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClientPermissions(t *testing.T) {
+	vault := &mockVaultService{ready: true}
+	s, ts := newTestServer(vault)
+	defer ts.Close()
+
+	if err := s.SetClientPermissions(testClient.ClientID, ClientPermissions{}); err != nil {
+		t.Fatal(err)
+	}
+	sess := mustOpenSession(t, ts)
+
+	for _, call := range []struct {
+		method, path string
+		body         interface{}
+		want         int
+	}{
+		{"GET", "/vault/exists?domain=github.com", nil, http.StatusOK},
+		{"GET", "/vault/never-save", nil, http.StatusOK},
+		{"POST", "/vault/save", SaveRequest{Domain: "github.com", Password: "x"}, http.StatusForbidden},
+		{"PUT", "/vault/update/1", UpdateRequest{Password: "x"}, http.StatusForbidden},
+		{"POST", "/vault/never-save", NeverSaveRequest{Domain: "github.com"}, http.StatusForbidden},
+		{"POST", "/vault/fill", FillRequest{Domain: "github.com", EntryID: 1}, http.StatusForbidden},
+		{"POST", "/vault/fill/result?x=1", FillResultRequest{RequestID: "x"}, http.StatusForbidden},
+	} {
+		resp := doRequest(ts, call.method, call.path, sess, call.body)
+		if resp.StatusCode != call.want {
+			t.Errorf("read-only %s %s: expected %d, got %d", call.method, call.path, call.want, resp.StatusCode)
+		}
+	}
+}
+
+// Changing permissions ends the client's sessions; the next one has the
+// new permissions.
+func TestClientPermissionsChange(t *testing.T) {
+	vault := &mockVaultService{ready: true}
+	s, ts := newTestServer(vault)
+	defer ts.Close()
+	sess := mustOpenSession(t, ts)
+
+	if err := s.SetClientPermissions(testClient.ClientID, ClientPermissions{Save: true}); err != nil {
+		t.Fatal(err)
+	}
+	if resp := doRequest(ts, "GET", "/vault/never-save", sess, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("old session after a permission change: expected 401, got %d", resp.StatusCode)
+	}
+	sess = mustOpenSession(t, ts)
+	if resp := doRequest(ts, "POST", "/vault/save", sess, SaveRequest{Domain: "github.com", Password: "x"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("save with permission: expected 200, got %d", resp.StatusCode)
+	}
+	if resp := doRequest(ts, "POST", "/vault/fill", sess, FillRequest{Domain: "github.com", EntryID: 1}); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("fill without permission: expected 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestRevokeClient(t *testing.T) {
+	vault := &mockVaultService{ready: true}
+	s, ts := newTestServer(vault)
+	defer ts.Close()
+	sess := mustOpenSession(t, ts)
+
+	if err := s.RevokeClient(testClient.ClientID); err != nil {
+		t.Fatal(err)
+	}
+	if resp := doRequest(ts, "GET", "/vault/never-save", sess, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("session of a revoked client: expected 401, got %d", resp.StatusCode)
+	}
+	if sess, resp := openSession(ts, testClient); sess != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("handshake of a revoked client: expected 401, got %d", resp.StatusCode)
+	}
+	if err := s.RevokeClient(testClient.ClientID); err != errUnknownClient {
+		t.Fatalf("revoking twice: %v", err)
+	}
+}
+
+func TestClientUnpairsItself(t *testing.T) {
+	vault := &mockVaultService{ready: true}
+	s, ts := newTestServer(vault)
+	defer ts.Close()
+	sess := mustOpenSession(t, ts)
+
+	resp := doRequest(ts, "GET", "/vault/client", sess, nil)
+	var self Client
+	json.NewDecoder(resp.Body).Decode(&self)
+	if resp.StatusCode != http.StatusOK || self.ID != testClient.ClientID || self.Name != "Test browser" {
+		t.Fatalf("GET /vault/client: %d %+v", resp.StatusCode, self)
+	}
+
+	if resp := doRequest(ts, "DELETE", "/vault/client", sess, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /vault/client: expected 200, got %d", resp.StatusCode)
+	}
+	if clients, _ := s.Clients(); len(clients) != 0 {
+		t.Fatalf("clients after unpairing = %+v", clients)
+	}
+}
+
+func TestRotateClientKey(t *testing.T) {
+	vault := &mockVaultService{ready: true}
+	s, ts := newTestServer(vault)
+	defer ts.Close()
+
+	if err := s.RotateClientKey(testClient.ClientID); err != nil {
+		t.Fatal(err)
+	}
+	clients, _ := s.Clients()
+	if len(clients) != 1 || !clients[0].RekeyPending {
+		t.Fatalf("clients after rotation = %+v", clients)
+	}
+
+	// The old key still opens a session, which hands over the new key.
+	sess := mustOpenSession(t, ts)
+	doRequest(ts, "GET", "/vault/never-save", sess, nil)
+	if len(sess.rekey) != pairingKeySize || bytes.Equal(sess.rekey, testClient.Key) {
+		t.Fatalf("rekey = %x", sess.rekey)
+	}
+
+	// A session with the new key completes the rotation and retires the
+	// old one.
+	rotated := testClient
+	rotated.Key = sess.rekey
+	sess, resp := openSession(ts, rotated)
+	if sess == nil {
+		t.Fatalf("session with the rotated key: got %d", resp.StatusCode)
+	}
+	doRequest(ts, "GET", "/vault/never-save", sess, nil)
+	if sess.rekey != nil {
+		t.Fatal("rekey still offered after the switch")
+	}
+	if _, resp := openSession(ts, testClient); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("old key after the switch: expected 401, got %d", resp.StatusCode)
+	}
+	clients, _ = s.Clients()
+	if clients[0].RekeyPending || clients[0].LastSeen.IsZero() {
+		t.Fatalf("client after the switch = %+v", clients[0])
+	}
+}
+
+func TestClientsFileV1Migrates(t *testing.T) {
+	vault := &mockVaultService{ready: true}
+	s, ts := newTestServer(vault)
+	defer ts.Close()
+	vault.pairingData = []byte(`{"version":1,"pairings":[{"client_id":"` + testClient.ClientID +
+		`","key":"QkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkJCQkI=","paired_at":"2026-01-02T03:04:05Z"}]}`)
+
+	clients, err := s.Clients()
+	if err != nil || len(clients) != 1 {
+		t.Fatalf("Clients() = %+v, %v", clients, err)
+	}
+	if c := clients[0]; !c.Permissions.Save || !c.Permissions.Fill || c.PairedAt.Year() != 2026 {
+		t.Fatalf("migrated client = %+v", c)
+	}
+	if sess, resp := openSession(ts, testClient); sess == nil {
+		t.Fatalf("session for a migrated client: got %d", resp.StatusCode)
+	}
+	if !strings.Contains(string(vault.pairingData), `"version":2`) {
+		t.Fatalf("clients file not rewritten: %s", vault.pairingData)
+	}
+}
+
+func TestSanitizeClientName(t *testing.T) {
+	if got := sanitizeClientName("  Work\x00 profile\n", "x"); got != "Work profile" {
+		t.Fatalf("got %q", got)
+	}
+	if got := sanitizeClientName(" \t", "Firefox"); got != "Firefox" {
+		t.Fatalf("got %q", got)
+	}
+	if got := sanitizeClientName(strings.Repeat("é", 100), "x"); len([]rune(got)) != maxClientNameLen {
+		t.Fatalf("got %d runes", len([]rune(got)))
+	}
+}
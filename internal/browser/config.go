@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"sync"
 	"time"
@@ -17,12 +18,23 @@ const (
 	secretBytes    = 32
 )
 
+// Config is the extension settings kept in browser_config.json. Pairing
+// keys are not among them: they are sealed to the vault keypair by
+// clientRegistry (see clients.go).
 type Config struct {
-	mu        sync.RWMutex
-	Secret    string   `json:"secret"`
-	PairedAt  string   `json:"paired_at,omitempty"`
-	NeverSave []string `json:"never_save"`
-	filePath  string
+	mu         sync.RWMutex
+	NeverSave  []string    `json:"never_save"`
+	FillGrants []FillGrant `json:"fill_grants,omitempty"`
+	filePath   string
+}
+
+// FillGrant lets /vault/fill release one entry on one site without asking
+// first. It is recorded when the user answers a fill prompt with "always
+// allow".
+type FillGrant struct {
+	Domain    string `json:"domain"`
+	EntryID   uint64 `json:"entry_id"`
+	GrantedAt string `json:"granted_at"`
 }
 
 func ConfigPath() (string, error) {
@@ -52,6 +64,17 @@ func LoadConfig() (*Config, error) {
 		cfg.NeverSave = []string{}
 	}
 	cfg.filePath = path
+
+	// Older releases stored a static shared secret here in the clear.
+	var legacy struct {
+		Secret string `json:"secret"`
+	}
+	if json.Unmarshal(data, &legacy) == nil && legacy.Secret != "" {
+		if err := cfg.Save(); err != nil {
+			return nil, fmt.Errorf("remove legacy pairing secret: %w", err)
+		}
+		log.Printf("[Browser] Removed the legacy plaintext pairing secret; pair the extension again")
+	}
 	return cfg, nil
 }
 
@@ -72,25 +95,12 @@ func (c *Config) Save() error {
 		path = p
 	}
 
-	if err := os.WriteFile(path, data, 0600); err != nil {
+	if err := securestorage.WriteFileAtomic(path, data, 0600); err != nil {
 		return fmt.Errorf("write config: %w", err)
 	}
 	return nil
 }
 
-func (c *Config) IsPaired() bool {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.Secret != ""
-}
-
-func (c *Config) SetPaired(secret string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.Secret = secret
-	c.PairedAt = time.Now().UTC().Format(time.RFC3339)
-}
-
 func (c *Config) IsNeverSave(domain string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -132,6 +142,51 @@ func (c *Config) GetNeverSaveList() []string {
 	return out
 }
 
+func (c *Config) HasFillGrant(domain string, entryID uint64) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, g := range c.FillGrants {
+		if g.Domain == domain && g.EntryID == entryID {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) AddFillGrant(domain string, entryID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, g := range c.FillGrants {
+		if g.Domain == domain && g.EntryID == entryID {
+			return
+		}
+	}
+	c.FillGrants = append(c.FillGrants, FillGrant{
+		Domain:    domain,
+		EntryID:   entryID,
+		GrantedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func (c *Config) RemoveFillGrant(domain string, entryID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, g := range c.FillGrants {
+		if g.Domain == domain && g.EntryID == entryID {
+			c.FillGrants = append(c.FillGrants[:i], c.FillGrants[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Config) GetFillGrants() []FillGrant {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]FillGrant, len(c.FillGrants))
+	copy(out, c.FillGrants)
+	return out
+}
+
 func GenerateSecret() (string, error) {
 	b := make([]byte, secretBytes)
 	if _, err := rand.Read(b); err != nil {
@@ -82,6 +82,44 @@ func (dm *DomainMap) Dissociate(domain string, entryID uint64) error {
 	return nil
 }
 
+// All returns a copy of every domain and the entry IDs linked to it.
+func (dm *DomainMap) All() map[string][]uint64 {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+
+	out := make(map[string][]uint64, len(dm.entries))
+	for domain, ids := range dm.entries {
+		out[domain] = append([]uint64(nil), ids...)
+	}
+	return out
+}
+
+// Relink points domain at entry to instead of entry from, e.g. after the
+// entry was re-created under a new ID.
+func (dm *DomainMap) Relink(domain string, from, to uint64) error {
+	normalized := NormalizeDomain(domain)
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	ids := dm.entries[normalized]
+	kept := ids[:0]
+	linked := false
+	for _, id := range ids {
+		if id == from {
+			continue
+		}
+		if id == to {
+			linked = true
+		}
+		kept = append(kept, id)
+	}
+	if !linked {
+		kept = append(kept, to)
+	}
+	dm.entries[normalized] = kept
+	return dm.save()
+}
+
 func (dm *DomainMap) Load() error {
 	data, err := os.ReadFile(dm.filePath)
 	if err != nil {
@@ -99,7 +137,7 @@ func (dm *DomainMap) save() error {
 	if err != nil {
 		return fmt.Errorf("marshal domain map: %w", err)
 	}
-	if err := os.WriteFile(dm.filePath, data, 0600); err != nil {
+	if err := securestorage.WriteFileAtomic(dm.filePath, data, 0600); err != nil {
 		return fmt.Errorf("write domain map: %w", err)
 	}
 	return nil
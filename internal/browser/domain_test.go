@@ -24,6 +24,13 @@ func TestNormalizeDomain(t *testing.T) {
 		{"https://subdomain.example.org/path?query=1", "example.org"},
 		{"https://bbc.co.uk/news", "bbc.co.uk"},
 		{"notion.so", "notion.so"},
+		{"https://alice.github.io/blog", "alice.github.io"},
+		{"https://myblog.blogspot.com", "myblog.blogspot.com"},
+		{"https://bucket.s3.amazonaws.com/key", "bucket.s3.amazonaws.com"},
+		{"https://www.tax.service.gov.uk/account", "tax.service.gov.uk"},
+		{"https://shop.example.com.au/cart", "example.com.au"},
+		{"https://www.city.kawasaki.jp", "city.kawasaki.jp"},
+		{"My Bank", ""},
 	}
 
 	for _, tt := range tests {
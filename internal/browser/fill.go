@@ -0,0 +1,129 @@
+package browser
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	fillRequestTTL     = 60 * time.Second
+	fillRequestIDBytes = 16
+)
+
+// FillPrompt describes a pending /vault/fill request to the user.
+type FillPrompt struct {
+	RequestID string
+	Domain    string
+	EntryID   uint64
+	Service   string
+	Username  string
+}
+
+// FillDecision is the user's answer to a FillPrompt.
+type FillDecision int
+
+const (
+	FillDeny FillDecision = iota
+	FillAllowOnce
+	// FillAlwaysAllow also records a grant, so later requests for the same
+	// entry on the same site are released without asking.
+	FillAlwaysAllow
+)
+
+type fillState int
+
+const (
+	fillPending fillState = iota
+	fillApproved
+	fillDenied
+)
+
+type fillRequest struct {
+	prompt    FillPrompt
+	state     fillState
+	granted   string // how the release was authorized, for the log
+	expiresAt time.Time
+}
+
+// fillRequests holds the fill requests the extension has yet to collect.
+// Every ID is random, expires after fillRequestTTL and is removed when it
+// is collected, so a released secret cannot be fetched twice.
+type fillRequests struct {
+	mu      sync.Mutex
+	pending map[string]*fillRequest
+}
+
+func newFillRequests() *fillRequests {
+	return &fillRequests{pending: make(map[string]*fillRequest)}
+}
+
+func (fr *fillRequests) add(prompt FillPrompt, state fillState, granted string) (FillPrompt, error) {
+	id, err := generateFillRequestID()
+	if err != nil {
+		return prompt, err
+	}
+	prompt.RequestID = id
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.pruneLocked(time.Now())
+	fr.pending[id] = &fillRequest{
+		prompt:    prompt,
+		state:     state,
+		granted:   granted,
+		expiresAt: time.Now().Add(fillRequestTTL),
+	}
+	return prompt, nil
+}
+
+// decide records the user's answer. It is ignored once the request has
+// expired or been collected.
+func (fr *fillRequests) decide(id string, approved bool, granted string) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	req, ok := fr.pending[id]
+	if !ok || req.state != fillPending {
+		return
+	}
+	if approved {
+		req.state = fillApproved
+		req.granted = granted
+	} else {
+		req.state = fillDenied
+	}
+}
+
+// take returns the request with id and removes it unless it is still
+// pending. ok is false for an unknown or expired ID.
+func (fr *fillRequests) take(id string) (req fillRequest, ok bool) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.pruneLocked(time.Now())
+	r, ok := fr.pending[id]
+	if !ok {
+		return fillRequest{}, false
+	}
+	if r.state != fillPending {
+		delete(fr.pending, id)
+	}
+	return *r, true
+}
+
+func (fr *fillRequests) pruneLocked(now time.Time) {
+	for id, r := range fr.pending {
+		if now.After(r.expiresAt) {
+			delete(fr.pending, id)
+		}
+	}
+}
+
+func generateFillRequestID() (string, error) {
+	b := make([]byte, fillRequestIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate request ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
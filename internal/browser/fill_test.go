@@ -0,0 +1,163 @@
+package browser
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newFillTestServer(t *testing.T, decide func(FillPrompt) FillDecision) (*Server, func(path string, body interface{}) *http.Response) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	vault := &mockVaultService{
+		ready: true,
+		credentials: []CredentialSummary{
+			{ID: 7, Service: "github.com", Username: "alice"},
+		},
+		secrets: map[uint64]*FillCredential{
+			7: {ID: 7, Username: "alice", Password: "hunter2", TOTP: "123456", TOTPRemaining: 20},
+		},
+	}
+	s, ts := newTestServer(vault)
+	t.Cleanup(ts.Close)
+	s.SetFillConfirmCallback(decide)
+	sess := mustOpenSession(t, ts)
+	return s, func(path string, body interface{}) *http.Response {
+		resp := doRequest(ts, "POST", path, sess, body)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+}
+
+// requestFill opens a fill request and returns its ID.
+func requestFill(t *testing.T, post func(string, interface{}) *http.Response) string {
+	t.Helper()
+	resp := post("/vault/fill", FillRequest{Domain: "https://github.com/login", EntryID: 7})
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("fill request: expected 202, got %d", resp.StatusCode)
+	}
+	var fill FillResponse
+	json.NewDecoder(resp.Body).Decode(&fill)
+	if fill.RequestID == "" || fill.Credential != nil {
+		t.Fatalf("fill request response = %+v, want only a request ID", fill)
+	}
+	return fill.RequestID
+}
+
+// collectFill polls /vault/fill/result until the request is no longer
+// pending.
+func collectFill(t *testing.T, post func(string, interface{}) *http.Response, id string) (*http.Response, FillResponse) {
+	t.Helper()
+	for i := 0; i < 20; i++ {
+		resp := post("/vault/fill/result", FillResultRequest{RequestID: id})
+		if resp.StatusCode != http.StatusAccepted {
+			var fill FillResponse
+			json.NewDecoder(resp.Body).Decode(&fill)
+			return resp, fill
+		}
+		time.Sleep(150 * time.Millisecond)
+	}
+	t.Fatal("fill request still pending")
+	return nil, FillResponse{}
+}
+
+func TestFillReleasesOnceAfterConfirmation(t *testing.T) {
+	var asked []FillPrompt
+	_, post := newFillTestServer(t, func(p FillPrompt) FillDecision {
+		asked = append(asked, p)
+		return FillAllowOnce
+	})
+
+	id := requestFill(t, post)
+	resp, fill := collectFill(t, post, id)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if fill.Credential == nil || fill.Credential.Password != "hunter2" || fill.Credential.TOTP != "123456" {
+		t.Fatalf("credential = %+v", fill.Credential)
+	}
+	if len(asked) != 1 || asked[0].Domain != "github.com" || asked[0].Username != "alice" {
+		t.Fatalf("prompts = %+v", asked)
+	}
+
+	resp = post("/vault/fill/result", FillResultRequest{RequestID: id})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("second collection: expected 404, got %d", resp.StatusCode)
+	}
+
+	// Allow once records no grant: the next request asks again.
+	resp, _ = collectFill(t, post, requestFill(t, post))
+	if resp.StatusCode != http.StatusOK || len(asked) != 2 {
+		t.Fatalf("second fill: status %d after %d prompts", resp.StatusCode, len(asked))
+	}
+}
+
+func TestFillDenied(t *testing.T) {
+	_, post := newFillTestServer(t, func(FillPrompt) FillDecision { return FillDeny })
+
+	resp, fill := collectFill(t, post, requestFill(t, post))
+	if resp.StatusCode != http.StatusForbidden || fill.Credential != nil {
+		t.Fatalf("expected 403 without a credential, got %d", resp.StatusCode)
+	}
+}
+
+func TestFillWithoutPromptDenies(t *testing.T) {
+	_, post := newFillTestServer(t, nil)
+
+	resp, _ := collectFill(t, post, requestFill(t, post))
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 without a confirmation prompt, got %d", resp.StatusCode)
+	}
+}
+
+func TestFillAlwaysAllowGrant(t *testing.T) {
+	prompts := 0
+	s, post := newFillTestServer(t, func(FillPrompt) FillDecision {
+		prompts++
+		return FillAlwaysAllow
+	})
+
+	resp, _ := collectFill(t, post, requestFill(t, post))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if !s.config.HasFillGrant("github.com", 7) {
+		t.Fatal("always allow recorded no grant")
+	}
+
+	resp, fill := collectFill(t, post, requestFill(t, post))
+	if resp.StatusCode != http.StatusOK || fill.Credential == nil {
+		t.Fatalf("granted fill: expected 200, got %d", resp.StatusCode)
+	}
+	if prompts != 1 {
+		t.Fatalf("prompted %d times, want 1", prompts)
+	}
+}
+
+func TestFillUnknownEntry(t *testing.T) {
+	_, post := newFillTestServer(t, func(FillPrompt) FillDecision { return FillAllowOnce })
+
+	resp := post("/vault/fill", FillRequest{Domain: "github.com", EntryID: 8})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an entry not matching the site, got %d", resp.StatusCode)
+	}
+	resp = post("/vault/fill/result", FillResultRequest{RequestID: "0123456789abcdef"})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown request ID, got %d", resp.StatusCode)
+	}
+}
+
+func TestFillRequestExpires(t *testing.T) {
+	fr := newFillRequests()
+	prompt, err := fr.add(FillPrompt{Domain: "github.com", EntryID: 7}, fillApproved, "always allowed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr.pending[prompt.RequestID].expiresAt = time.Now().Add(-time.Second)
+	if _, ok := fr.take(prompt.RequestID); ok {
+		t.Fatal("expired request was still handed out")
+	}
+}
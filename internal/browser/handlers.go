@@ -1,24 +1,76 @@
 package browser
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // --- Request/Response types ---
 
+// PairRequest carries one step of pairing: nothing to start it, the
+// client's SPAKE2 share, then its confirmation along with the name and
+// browser it is to be listed under. Byte fields are base64.
 type PairRequest struct {
-	Token string `json:"token"`
+	Share   []byte `json:"share,omitempty"`
+	Confirm []byte `json:"confirm,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Browser string `json:"browser,omitempty"`
 }
 
 type PairResponse struct {
-	Secret  string `json:"secret,omitempty"`
-	Status  string `json:"status"`
-	Message string `json:"message,omitempty"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	Share    []byte `json:"share,omitempty"`
+	Confirm  []byte `json:"confirm,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+type SessionRequest struct {
+	ClientID  string `json:"client_id"`
+	Ephemeral []byte `json:"ephemeral"`
+	MAC       []byte `json:"mac"`
+}
+
+type SessionResponse struct {
+	SessionID string `json:"session_id"`
+	Ephemeral []byte `json:"ephemeral"`
+	MAC       []byte `json:"mac"`
+	IdleTTL   int    `json:"idle_ttl"`
+}
+
+// CallRequest is one encrypted API call; Payload seals a CallMessage.
+type CallRequest struct {
+	SessionID string `json:"session_id"`
+	Seq       uint64 `json:"seq"`
+	Payload   []byte `json:"payload"`
+}
+
+// CallResponse seals a CallResult under the same sequence number.
+type CallResponse struct {
+	Seq     uint64 `json:"seq"`
+	Payload []byte `json:"payload"`
+}
+
+type CallMessage struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// CallResult is the reply to a CallMessage. Rekey carries the client's new
+// key after a rotation; the client switches to it for its next session.
+type CallResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+	Rekey  []byte          `json:"rekey,omitempty"`
 }
 
 type StatusResponse struct {
@@ -28,8 +80,11 @@ type StatusResponse struct {
 	Version     string `json:"version"`
 }
 
+// ExistsResponse lists the credentials offered on a page. Domain is the
+// page's registrable domain, which the never-save list is keyed by.
 type ExistsResponse struct {
 	Found       bool                `json:"found"`
+	Domain      string              `json:"domain"`
 	Credentials []CredentialSummary `json:"credentials"`
 }
 
@@ -40,7 +95,7 @@ type SaveRequest struct {
 }
 
 type SaveResponse struct {
-	ID    uint64 `json:"id"`
+	ID    uint64 `json:"id,string"`
 	Saved bool   `json:"saved"`
 }
 
@@ -61,12 +116,36 @@ type NeverSaveListResponse struct {
 	Domains []string `json:"domains"`
 }
 
+// FillRequest names the page to fill by URL, so that entries with host,
+// port, prefix or regex rules can be checked; a bare Domain is matched as
+// https on that host.
+type FillRequest struct {
+	URL     string `json:"url,omitempty"`
+	Domain  string `json:"domain,omitempty"`
+	EntryID uint64 `json:"entry_id,string"`
+}
+
+type FillResultRequest struct {
+	RequestID string `json:"request_id"`
+}
+
+type FillResponse struct {
+	Status     string          `json:"status"`
+	RequestID  string          `json:"request_id,omitempty"`
+	ExpiresIn  int             `json:"expires_in,omitempty"`
+	Credential *FillCredential `json:"credential,omitempty"`
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
 // --- Handlers ---
 
+// handlePair runs pairing in three posts: an empty one shows a new code in
+// the app, the client's SPAKE2 share is answered with the app's share and
+// confirmation, and the client's confirmation completes it. The agreed key
+// is stored sealed, so pairing needs the app unlocked.
 func (s *Server) handlePair(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -81,37 +160,204 @@ func (s *Server) handlePair(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if req.Token == "" {
+	if !s.vault.Status().AppUnlocked {
+		writeError(w, http.StatusLocked, "Unlock PassQuantum to pair")
+		return
+	}
+
+	switch {
+	case req.Share != nil:
+		share, confirm, err := s.pairing.Exchange(req.Share)
+		if err == errPairingInactive {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid pairing share")
+			return
+		}
+		writeJSON(w, http.StatusOK, PairResponse{
+			Status:  "confirm",
+			Share:   share,
+			Confirm: confirm,
+		})
+
+	case req.Confirm != nil:
+		key, ok := s.pairing.Confirm(req.Confirm)
+		if !ok {
+			log.Printf("[Browser] Pairing confirmation failed")
+			writeError(w, http.StatusUnauthorized, "invalid or expired token")
+			return
+		}
+		client, err := s.clients.add(key, req.Name, req.Browser)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to save pairing")
+			log.Printf("[Browser] WARNING: failed to save pairing: %v", err)
+			return
+		}
+		log.Printf("[Browser] Paired extension client %s (%s, %s)", client.ClientID, client.Name, client.Browser)
+		writeJSON(w, http.StatusOK, PairResponse{
+			Status:   "paired",
+			ClientID: client.ClientID,
+		})
+
+	default:
 		s.pairing.StartPairing()
 		writeJSON(w, http.StatusOK, PairResponse{
 			Status:  "pending",
 			Message: "Enter the 6-digit code shown in PassQuantum",
 		})
+	}
+}
+
+// handleSession opens an encrypted session for a paired client.
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req SessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	if !s.pairing.ValidateToken(req.Token) {
-		writeError(w, http.StatusUnauthorized, "invalid or expired token")
+	if !s.vault.Status().AppUnlocked {
+		writeError(w, http.StatusLocked, "PassQuantum is locked")
+		return
+	}
+	client, err := s.clients.authenticate(req.ClientID, req.Ephemeral, req.MAC)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read paired clients")
+		log.Printf("[Browser] WARNING: failed to read paired clients: %v", err)
+		return
+	}
+	if client == nil {
+		writeError(w, http.StatusUnauthorized, "unknown client or bad handshake")
 		return
 	}
 
-	secret, err := GenerateSecret()
+	sessionID, ephemeral, err := s.sessions.open(client, req.Ephemeral)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to generate secret")
+		writeError(w, http.StatusBadRequest, "invalid ephemeral key")
 		return
 	}
 
-	s.config.SetPaired(secret)
-	if err := s.config.Save(); err != nil {
-		log.Printf("[Browser] WARNING: failed to save config after pairing: %v", err)
+	writeJSON(w, http.StatusOK, SessionResponse{
+		SessionID: sessionID,
+		Ephemeral: ephemeral,
+		MAC:       serverHandshakeMAC(client, req.Ephemeral, ephemeral, sessionID),
+		IdleTTL:   int(sessionIdleTTL.Seconds()),
+	})
+}
+
+// handleCall decrypts a call, serves it from the API routes if the
+// client's permissions allow it and returns the encrypted result. Only
+// failures before decryption are in the clear.
+func (s *Server) handleCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
 	}
 
-	writeJSON(w, http.StatusOK, PairResponse{
-		Status: "paired",
-		Secret: secret,
+	var req CallRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	sess, err := s.sessions.get(req.SessionID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	plaintext, err := sess.openCall(req.Seq, req.Payload)
+	if err == errReplayed {
+		log.Printf("[Browser] Rejected replayed call %d in session of client %s", req.Seq, sess.clientID)
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var msg CallMessage
+	if err := json.Unmarshal(plaintext, &msg); err != nil || !strings.HasPrefix(msg.Path, "/vault/") {
+		writeError(w, http.StatusBadRequest, "invalid call")
+		return
+	}
+	target, err := url.ParseRequestURI(msg.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid call")
+		return
+	}
+
+	rec := &callRecorder{header: make(http.Header), status: http.StatusOK}
+	switch {
+	case !sess.perms.allow(callPermission(msg.Method, target.Path)):
+		log.Printf("[Browser] Refused %s %s from client %s: not permitted", msg.Method, target.Path, sess.clientID)
+		rec.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(rec).Encode(ErrorResponse{Error: "this browser is not allowed to do that; change it in PassQuantum settings"})
+	case !s.vault.IsReady():
+		rec.WriteHeader(http.StatusLocked)
+		json.NewEncoder(rec).Encode(ErrorResponse{Error: "PassQuantum is locked"})
+	default:
+		ctx := context.WithValue(r.Context(), clientIDKey{}, sess.clientID)
+		inner, err := http.NewRequestWithContext(ctx, msg.Method, msg.Path, bytes.NewReader(msg.Body))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid call")
+			return
+		}
+		inner.Header.Set("Content-Type", "application/json")
+		inner.RemoteAddr = r.RemoteAddr
+		s.api.ServeHTTP(rec, inner)
+	}
+
+	result, err := json.Marshal(CallResult{Status: rec.status, Body: rec.jsonBody(), Rekey: sess.rekey})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to encode result")
+		return
+	}
+	writeJSON(w, http.StatusOK, CallResponse{
+		Seq:     req.Seq,
+		Payload: sess.sealReply(req.Seq, result),
 	})
 }
 
+// handleClient lets the calling extension see how it is listed and
+// unpair itself. Permissions can only be changed in the app.
+func (s *Server) handleClient(w http.ResponseWriter, r *http.Request) {
+	id, _ := r.Context().Value(clientIDKey{}).(string)
+
+	switch r.Method {
+	case http.MethodGet:
+		client, err := s.clients.lookup(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read paired clients")
+			log.Printf("[Browser] WARNING: failed to read paired clients: %v", err)
+			return
+		}
+		if client == nil {
+			writeError(w, http.StatusNotFound, errUnknownClient.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, client.info())
+
+	case http.MethodDelete:
+		if err := s.RevokeClient(id); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to unpair")
+			log.Printf("[Browser] WARNING: failed to unpair client %s: %v", id, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -133,13 +379,21 @@ func (s *Server) handleExists(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	domain := r.URL.Query().Get("domain")
+	page := r.URL.Query().Get("url")
+	if page == "" {
+		page = r.URL.Query().Get("domain")
+	}
+	if page == "" {
+		writeError(w, http.StatusBadRequest, "url or domain parameter required")
+		return
+	}
+	domain := NormalizeDomain(page)
 	if domain == "" {
-		writeError(w, http.StatusBadRequest, "domain parameter required")
+		writeError(w, http.StatusBadRequest, "no host name in url")
 		return
 	}
 
-	creds, err := s.vault.FindCredentials(NormalizeDomain(domain))
+	creds, err := s.vault.FindCredentials(page)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "vault error")
 		log.Printf("[Browser] FindCredentials error: %v", err)
@@ -152,6 +406,7 @@ func (s *Server) handleExists(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, ExistsResponse{
 		Found:       len(creds) > 0,
+		Domain:      domain,
 		Credentials: creds,
 	})
 }
@@ -269,8 +524,182 @@ func (s *Server) handleNeverSave(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleFill opens a fill request for one of the site's credentials. The
+// secret is never in this response: the extension collects it from
+// /vault/fill/result with the returned request ID once the user has
+// approved, or at once when the site has an "always allow" grant.
+func (s *Server) handleFill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req FillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	page := req.URL
+	if page == "" {
+		page = req.Domain
+	}
+	req.Domain = NormalizeDomain(page)
+	if req.Domain == "" || req.EntryID == 0 {
+		writeError(w, http.StatusBadRequest, "url and entry_id are required")
+		return
+	}
+
+	creds, err := s.vault.FindCredentials(page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "vault error")
+		log.Printf("[Browser] FindCredentials error: %v", err)
+		return
+	}
+	prompt := FillPrompt{Domain: req.Domain, EntryID: req.EntryID}
+	found := false
+	for _, c := range creds {
+		if c.ID == req.EntryID {
+			prompt.Service, prompt.Username = c.Service, c.Username
+			found = true
+			break
+		}
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "no such credential for this site")
+		return
+	}
+
+	if s.config.HasFillGrant(req.Domain, req.EntryID) {
+		prompt, err = s.fills.add(prompt, fillApproved, "always allowed")
+	} else {
+		prompt, err = s.fills.add(prompt, fillPending, "")
+		if err == nil {
+			go s.askFill(prompt)
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create request")
+		log.Printf("[Browser] fill request error: %v", err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, FillResponse{
+		Status:    "pending",
+		RequestID: prompt.RequestID,
+		ExpiresIn: int(fillRequestTTL.Seconds()),
+	})
+}
+
+// askFill asks the user about a fill request and records the answer.
+func (s *Server) askFill(prompt FillPrompt) {
+	decision := FillDeny
+	if s.confirmFill != nil {
+		decision = s.confirmFill(prompt)
+	}
+	switch decision {
+	case FillAlwaysAllow:
+		s.config.AddFillGrant(prompt.Domain, prompt.EntryID)
+		if err := s.config.Save(); err != nil {
+			log.Printf("[Browser] WARNING: failed to persist fill grant: %v", err)
+		}
+		s.fills.decide(prompt.RequestID, true, "confirmed, always allow")
+	case FillAllowOnce:
+		s.fills.decide(prompt.RequestID, true, "confirmed")
+	default:
+		s.fills.decide(prompt.RequestID, false, "")
+	}
+}
+
+// handleFillResult hands out the secret of an approved fill request. Each
+// request ID is good for one release; every release is logged.
+func (s *Server) handleFillResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req FillResultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	fill, ok := s.fills.take(req.RequestID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown or expired request")
+		return
+	}
+	prompt := fill.prompt
+
+	switch fill.state {
+	case fillPending:
+		writeJSON(w, http.StatusAccepted, FillResponse{
+			Status:    "pending",
+			RequestID: prompt.RequestID,
+			ExpiresIn: int(time.Until(fill.expiresAt).Seconds()),
+		})
+		return
+	case fillDenied:
+		log.Printf("[Browser] Fill of credential ID %d for %s denied", prompt.EntryID, prompt.Domain)
+		writeError(w, http.StatusForbidden, "fill request denied")
+		return
+	}
+
+	cred, err := s.vault.RevealCredential(prompt.EntryID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read credential")
+		log.Printf("[Browser] RevealCredential error: %v", err)
+		return
+	}
+
+	log.Printf("[Browser] Released credential ID %d for %s (user: %s, totp: %t, %s)",
+		prompt.EntryID, prompt.Domain, cred.Username, cred.TOTP != "", fill.granted)
+
+	writeJSON(w, http.StatusOK, FillResponse{Status: "approved", Credential: cred})
+}
+
 // --- Helpers ---
 
+// clientIDKey carries the calling client's ID into calls served inside a
+// session.
+type clientIDKey struct{}
+
+// callRecorder captures the response of a request served from a native
+// message or inside a session.
+type callRecorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (c *callRecorder) Header() http.Header { return c.header }
+
+func (c *callRecorder) Write(b []byte) (int, error) {
+	c.wroteHeader = true
+	return c.body.Write(b)
+}
+
+func (c *callRecorder) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.status = status
+		c.wroteHeader = true
+	}
+}
+
+// jsonBody returns the recorded body as JSON: null when empty, and a
+// plain-text error from net/http wrapped as an ErrorResponse.
+func (c *callRecorder) jsonBody() json.RawMessage {
+	body := bytes.TrimSpace(c.body.Bytes())
+	switch {
+	case len(body) == 0:
+		return json.RawMessage("null")
+	case !json.Valid(body):
+		body, _ = json.Marshal(ErrorResponse{Error: string(body)})
+	}
+	return body
+}
+
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,68 @@
+package browser
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"passquantum/internal/nativehost"
+)
+
+func TestNativeSocket(t *testing.T) {
+	vault := &mockVaultService{ready: true, vaultName: "Personal"}
+	s := NewServer(vault, &Config{}, filepath.Join(t.TempDir(), "browser.sock"))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	conn, err := nativehost.Dial(s.socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, req := range []nativehost.Request{
+		{ID: 7, Method: "GET", Path: "/vault/status"},
+		{ID: 8, Method: "GET", Path: "/vault/exists?domain=github.com"},
+	} {
+		msg, _ := json.Marshal(req)
+		if err := nativehost.WriteMessage(conn, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replies := map[uint64]nativehost.Response{}
+	for range 2 {
+		msg, err := nativehost.ReadMessage(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp nativehost.Response
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			t.Fatal(err)
+		}
+		replies[resp.ID] = resp
+	}
+
+	var status StatusResponse
+	if err := json.Unmarshal(replies[7].Body, &status); err != nil || replies[7].Status != http.StatusOK || !status.Unlocked {
+		t.Fatalf("status reply = %d %s", replies[7].Status, replies[7].Body)
+	}
+	if replies[8].Status != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated call: expected 401, got %d", replies[8].Status)
+	}
+}
+
+func TestNativeSocketRemovedOnStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "browser.sock")
+	s := NewServer(&mockVaultService{}, &Config{}, path)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop()
+	if _, err := nativehost.Dial(path); err == nil {
+		t.Fatal("socket still accepts connections after Stop")
+	}
+}
@@ -1,7 +1,9 @@
 package browser
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math/big"
 	"sync"
@@ -13,11 +15,18 @@ const (
 	pairingMaxRetries = 5
 )
 
+var errPairingInactive = errors.New("invalid or expired token")
+
+// PairingState runs the app's side of pairing. The 6-digit code shown to
+// the user is never sent: the extension proves it knows it through a
+// SPAKE2 exchange, and both sides come out of it holding the same pairing
+// key.
 type PairingState struct {
 	mu          sync.Mutex
 	token       string
 	expiresAt   time.Time
 	attempts    int
+	exchange    *spake2Keys // waiting for the client's confirmation
 	onShowToken func(token string)
 }
 
@@ -35,6 +44,7 @@ func (ps *PairingState) StartPairing() string {
 	ps.token = token
 	ps.expiresAt = time.Now().Add(pairingTokenTTL)
 	ps.attempts = 0
+	ps.exchange = nil
 
 	if ps.onShowToken != nil {
 		ps.onShowToken(token)
@@ -43,39 +53,63 @@ func (ps *PairingState) StartPairing() string {
 	return token
 }
 
-// ValidateToken checks the provided token. Returns true on match.
-// Clears the token on success or after max attempts.
-func (ps *PairingState) ValidateToken(token string) bool {
+// Exchange answers the client's SPAKE2 share with the app's share and
+// confirmation. Every exchange counts as an attempt, since each one lets
+// the client test a single guess of the code; the token is cleared after
+// pairingMaxRetries.
+func (ps *PairingState) Exchange(clientShare []byte) (share, confirm []byte, err error) {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
-	if ps.token == "" {
-		return false
+	if !ps.activeLocked() {
+		return nil, nil, errPairingInactive
 	}
-
-	if time.Now().After(ps.expiresAt) {
-		ps.token = ""
-		return false
-	}
-
 	ps.attempts++
 	if ps.attempts > pairingMaxRetries {
 		ps.token = ""
-		return false
+		return nil, nil, errPairingInactive
 	}
 
-	if token != ps.token {
-		return false
+	server, err := newSPAKE2(spake2Server, ps.token)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := server.finish(clientShare)
+	if err != nil {
+		return nil, nil, err
 	}
+	ps.exchange = keys
+	return server.share, keys.serverConfirm, nil
+}
 
+// Confirm checks the client's confirmation of the last exchange. On a
+// match pairing ends and the agreed key is returned; a client that got the
+// code wrong has to run a new exchange.
+func (ps *PairingState) Confirm(clientConfirm []byte) ([]byte, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	keys := ps.exchange
+	ps.exchange = nil
+	if keys == nil || !ps.activeLocked() || !hmac.Equal(clientConfirm, keys.clientConfirm) {
+		return nil, false
+	}
 	ps.token = ""
-	return true
+	return keys.pairingKey, true
 }
 
 func (ps *PairingState) IsActive() bool {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
-	return ps.token != "" && time.Now().Before(ps.expiresAt)
+	return ps.activeLocked()
+}
+
+func (ps *PairingState) activeLocked() bool {
+	if ps.token != "" && time.Now().After(ps.expiresAt) {
+		ps.token = ""
+		ps.exchange = nil
+	}
+	return ps.token != ""
 }
 
 func generate6DigitToken() string {
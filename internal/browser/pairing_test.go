@@ -1,10 +1,36 @@
 package browser
 
 import (
+	"bytes"
 	"testing"
 	"time"
 )
 
+// pairWith runs the client half of SPAKE2 against ps with code.
+func pairWith(t *testing.T, ps *PairingState, code string) ([]byte, bool) {
+	t.Helper()
+	client, err := newSPAKE2(spake2Client, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	share, confirm, err := ps.Exchange(client.share)
+	if err != nil {
+		return nil, false
+	}
+	keys, err := client.finish(share)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverKey, ok := ps.Confirm(keys.clientConfirm)
+	if ok != bytes.Equal(confirm, keys.serverConfirm) {
+		t.Fatalf("app accepted=%v but its confirmation verified=%v", ok, !ok)
+	}
+	if ok && !bytes.Equal(serverKey, keys.pairingKey) {
+		t.Fatal("the two sides agreed on different keys")
+	}
+	return serverKey, ok
+}
+
 func TestPairingFlow(t *testing.T) {
 	var shownToken string
 	ps := NewPairingState(func(token string) {
@@ -23,16 +49,21 @@ func TestPairingFlow(t *testing.T) {
 		t.Fatal("pairing should be active after StartPairing")
 	}
 
-	if ps.ValidateToken("000000") {
-		t.Fatal("wrong token should not validate")
+	wrong := "000000"
+	if token == wrong {
+		wrong = "000001"
+	}
+	if _, ok := pairWith(t, ps, wrong); ok {
+		t.Fatal("wrong token should not pair")
 	}
 
-	if !ps.ValidateToken(token) {
-		t.Fatal("correct token should validate")
+	key, ok := pairWith(t, ps, token)
+	if !ok || len(key) != pairingKeySize {
+		t.Fatal("correct token should pair")
 	}
 
 	if ps.IsActive() {
-		t.Fatal("pairing should be inactive after successful validation")
+		t.Fatal("pairing should be inactive after successful pairing")
 	}
 }
 
@@ -44,8 +75,8 @@ func TestPairingExpiry(t *testing.T) {
 	ps.expiresAt = time.Now().Add(-1 * time.Second)
 	ps.mu.Unlock()
 
-	if ps.ValidateToken(token) {
-		t.Fatal("expired token should not validate")
+	if _, ok := pairWith(t, ps, token); ok {
+		t.Fatal("expired token should not pair")
 	}
 }
 
@@ -54,10 +85,24 @@ func TestPairingMaxAttempts(t *testing.T) {
 	token := ps.StartPairing()
 
 	for i := 0; i < pairingMaxRetries; i++ {
-		ps.ValidateToken("wrong!")
+		pairWith(t, ps, "wrong!")
 	}
 
-	if ps.ValidateToken(token) {
+	if _, ok := pairWith(t, ps, token); ok {
 		t.Fatal("token should be invalidated after max attempts")
 	}
 }
+
+func TestSPAKE2RejectsBadShares(t *testing.T) {
+	server, err := newSPAKE2(spake2Server, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := []byte{0}
+	bad := append([]byte{4}, bytes.Repeat([]byte{1}, spake2ShareSize-1)...)
+	for _, share := range [][]byte{nil, identity, bad, server.share[:33]} {
+		if _, err := server.finish(share); err == nil {
+			t.Errorf("share %x accepted", share)
+		}
+	}
+}
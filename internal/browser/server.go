@@ -1,40 +1,62 @@
 package browser
 
 import (
+	"bytes"
 	"context"
-	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
+
+	"passquantum/internal/nativehost"
 )
 
 const (
-	listenAddr     = "127.0.0.1:8765"
-	shutdownTimeout = 5 * time.Second
+	requestTimeout = 10 * time.Second
 	rateBurst      = 10
 	rateWindow     = time.Second
 )
 
+// Server answers the browser extension. The extension reaches it through
+// passquantum-native-host, which the browser starts and which relays
+// native messages to a socket only this user can connect to (see
+// nativehost.Listen). Each
+// message is served by the HTTP-style handlers in routes.
 type Server struct {
-	httpServer *http.Server
-	vault      VaultService
-	config     *Config
-	pairing    *PairingState
-	limiter    *rateLimiter
-	mu         sync.Mutex
-	running    bool
+	vault       VaultService
+	config      *Config
+	socketPath  string
+	handler     http.Handler
+	pairing     *PairingState
+	clients     *clientRegistry
+	sessions    *sessionTable
+	api         *http.ServeMux
+	limiter     *rateLimiter
+	fills       *fillRequests
+	confirmFill func(FillPrompt) FillDecision
+	stopSweep   chan struct{}
+	mu          sync.Mutex
+	listener    *nativehost.Listener
+	conns       map[net.Conn]struct{}
 }
 
-func NewServer(vault VaultService, config *Config) *Server {
+func NewServer(vault VaultService, config *Config, socketPath string) *Server {
 	s := &Server{
-		vault:   vault,
-		config:  config,
-		pairing: NewPairingState(nil),
-		limiter: newRateLimiter(rateBurst, rateWindow),
+		vault:      vault,
+		config:     config,
+		socketPath: socketPath,
+		pairing:    NewPairingState(nil),
+		clients:    &clientRegistry{vault: vault},
+		sessions:   newSessionTable(),
+		limiter:    newRateLimiter(rateBurst, rateWindow),
+		fills:      newFillRequests(),
 	}
+	s.api = s.apiRoutes()
+	s.handler = s.middleware(s.routes())
 	return s
 }
 
@@ -42,123 +64,201 @@ func (s *Server) SetPairingCallback(fn func(token string)) {
 	s.pairing.onShowToken = fn
 }
 
-func (s *Server) Start() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.running {
-		return nil
-	}
+// SetFillConfirmCallback installs the prompt that approves /vault/fill
+// requests without a grant; call it before Start. fn runs on its own
+// goroutine and blocks until the user answers. Without it only sites with
+// an "always allow" grant are filled.
+func (s *Server) SetFillConfirmCallback(fn func(FillPrompt) FillDecision) {
+	s.confirmFill = fn
+}
 
+// routes is what the socket serves: pairing, status and the session
+// endpoints. Everything else is reachable only inside an encrypted
+// /vault/call.
+func (s *Server) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/vault/pair", s.handlePair)
 	mux.HandleFunc("/vault/status", s.handleStatus)
+	mux.HandleFunc("/vault/session", s.handleSession)
+	mux.HandleFunc("/vault/call", s.handleCall)
+	mux.HandleFunc("/vault/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusUnauthorized, "open an encrypted session first")
+	})
+	return mux
+}
+
+// apiRoutes are the vault operations served through /vault/call.
+func (s *Server) apiRoutes() *http.ServeMux {
+	mux := http.NewServeMux()
 	mux.HandleFunc("/vault/exists", s.handleExists)
 	mux.HandleFunc("/vault/save", s.handleSave)
 	mux.HandleFunc("/vault/update/", s.handleUpdate)
 	mux.HandleFunc("/vault/never-save", s.handleNeverSave)
+	mux.HandleFunc("/vault/fill", s.handleFill)
+	mux.HandleFunc("/vault/fill/result", s.handleFillResult)
+	mux.HandleFunc("/vault/client", s.handleClient)
+	return mux
+}
 
-	s.httpServer = &http.Server{
-		Addr:         listenAddr,
-		Handler:      s.middleware(mux),
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return nil
 	}
 
-	ln, err := net.Listen("tcp", listenAddr)
+	ln, err := nativehost.Listen(s.socketPath)
 	if err != nil {
 		return err
 	}
 
-	s.running = true
-	log.Printf("[Browser] API server listening on %s", listenAddr)
-
-	go func() {
-		if err := s.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
-			log.Printf("[Browser] server error: %v", err)
-		}
-	}()
+	s.listener = ln
+	s.conns = make(map[net.Conn]struct{})
+	s.stopSweep = make(chan struct{})
+	go s.sweepSessions(s.stopSweep)
+	log.Printf("[Browser] listening for the native messaging host on %s", s.socketPath)
 
+	go s.serve(ln)
 	return nil
 }
 
+// Stop closes the socket and every host connection and ends all sessions.
 func (s *Server) Stop() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.running || s.httpServer == nil {
+	if s.listener == nil {
 		return nil
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
-	defer cancel()
-
-	s.running = false
-	log.Println("[Browser] API server shutting down")
-	return s.httpServer.Shutdown(ctx)
+	err := s.listener.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.listener = nil
+	s.conns = nil
+	close(s.stopSweep)
+	s.sessions.clear()
+	log.Println("[Browser] stopped")
+	return err
 }
 
-func (s *Server) middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 1. Localhost check
-		remoteIP := extractIP(r.RemoteAddr)
-		if remoteIP != "127.0.0.1" && remoteIP != "::1" {
-			writeError(w, http.StatusForbidden, "localhost only")
+func (s *Server) serve(ln *nativehost.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[Browser] accept error: %v", err)
+			}
 			return
 		}
 
-		// 2. CORS
-		origin := r.Header.Get("Origin")
-		if origin != "" {
-			if strings.HasPrefix(origin, "chrome-extension://") ||
-				strings.HasPrefix(origin, "moz-extension://") {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-			}
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "X-Secret, Content-Type")
-			w.Header().Set("Access-Control-Max-Age", "86400")
+		s.mu.Lock()
+		if s.listener != ln {
+			s.mu.Unlock()
+			conn.Close()
+			return
 		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
+		go func() {
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			// Only a host started by this user's browser may connect.
+			if err := ln.Verify(conn); err != nil {
+				log.Printf("[Browser] Refused a connection: %v", err)
+				return
+			}
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// serveConn answers the requests of one host connection. Requests run
+// concurrently, since a fill waits for the user; replies carry the
+// request's ID.
+func (s *Server) serveConn(conn net.Conn) {
+	var writeMu sync.Mutex
+	for {
+		msg, err := nativehost.ReadMessage(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("[Browser] native host connection: %v", err)
+			}
 			return
 		}
+		go func() {
+			reply := s.dispatch(msg)
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if err := nativehost.WriteMessage(conn, reply); err != nil {
+				log.Printf("[Browser] write reply: %v", err)
+			}
+		}()
+	}
+}
 
-		// 3. Rate limit
+// dispatch serves one native message through the routes and encodes the
+// response.
+func (s *Server) dispatch(msg []byte) []byte {
+	var req nativehost.Request
+	rec := &callRecorder{header: make(http.Header), status: http.StatusOK}
+	if err := json.Unmarshal(msg, &req); err != nil {
+		writeError(rec, http.StatusBadRequest, "invalid message")
+	} else if r, err := http.NewRequest(req.Method, req.Path, bytes.NewReader(req.Body)); err != nil {
+		writeError(rec, http.StatusBadRequest, "invalid request")
+	} else {
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+		r.Header.Set("Content-Type", "application/json")
+		s.handler.ServeHTTP(rec, r.WithContext(ctx))
+	}
+	reply, err := json.Marshal(nativehost.Response{ID: req.ID, Status: rec.status, Body: rec.jsonBody()})
+	if err != nil {
+		reply, _ = json.Marshal(nativehost.Response{ID: req.ID, Status: http.StatusInternalServerError, Body: json.RawMessage("null")})
+	}
+	return reply
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 1. Rate limit
 		if !s.limiter.allow() {
 			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
 			return
 		}
 
-		// 4. Auth check (exempt: /vault/pair, /vault/status)
-		if r.URL.Path != "/vault/pair" && r.URL.Path != "/vault/status" {
-			secret := r.Header.Get("X-Secret")
-			configSecret := s.config.Secret
-			if configSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(configSecret)) != 1 {
-				writeError(w, http.StatusUnauthorized, "invalid or missing X-Secret")
-				return
-			}
-		}
-
-		// 5. Unlock check (exempt: /vault/pair, /vault/status)
-		if r.URL.Path != "/vault/pair" && r.URL.Path != "/vault/status" {
-			if !s.vault.IsReady() {
-				writeError(w, http.StatusLocked, "PassQuantum is locked")
-				return
-			}
+		// 2. Session keys do not outlive an unlock
+		if !s.vault.Status().AppUnlocked {
+			s.sessions.clear()
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func extractIP(addr string) string {
-	host, _, err := net.SplitHostPort(addr)
-	if err != nil {
-		return addr
+// sweepSessions ends sessions that have gone idle, and all of them once
+// the app locks, without waiting for the next request.
+func (s *Server) sweepSessions(stop <-chan struct{}) {
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if s.vault.Status().AppUnlocked {
+				s.sessions.prune()
+			} else {
+				s.sessions.clear()
+			}
+		}
 	}
-	return host
 }
 
 // Simple token bucket rate limiter — no external dependency.
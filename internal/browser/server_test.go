@@ -2,10 +2,15 @@ package browser
 
 import (
 	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 )
 
@@ -22,12 +27,15 @@ type mockVaultService struct {
 		password string
 	}
 	saveIDCounter uint64
+	secrets       map[uint64]*FillCredential
+	pairingData   []byte
 }
 
 func (m *mockVaultService) IsReady() bool { return m.ready }
 
+// Status reports the app unlocked whenever a vault is open.
 func (m *mockVaultService) Status() VaultStatus {
-	return VaultStatus{AppUnlocked: m.appUnlocked, VaultName: m.vaultName}
+	return VaultStatus{AppUnlocked: m.appUnlocked || m.ready, VaultName: m.vaultName}
 }
 
 func (m *mockVaultService) FindCredentials(domain string) ([]CredentialSummary, error) {
@@ -48,29 +56,138 @@ func (m *mockVaultService) UpdatePassword(entryID uint64, newPassword string) er
 	return nil
 }
 
+func (m *mockVaultService) RevealCredential(entryID uint64) (*FillCredential, error) {
+	cred, ok := m.secrets[entryID]
+	if !ok {
+		return nil, fmt.Errorf("entry %d not found", entryID)
+	}
+	copied := *cred
+	return &copied, nil
+}
+
+func (m *mockVaultService) LoadPairingData() ([]byte, error) {
+	return m.pairingData, nil
+}
+
+func (m *mockVaultService) SavePairingData(data []byte) error {
+	m.pairingData = data
+	return nil
+}
+
 // --- Helpers ---
 
+// testClient is the extension newTestServer has already paired.
+var testClient = clientRecord{
+	ClientID:    "00112233445566778899aabbccddeeff",
+	Name:        "Test browser",
+	Browser:     "firefox",
+	Key:         bytes.Repeat([]byte{0x42}, pairingKeySize),
+	Permissions: ClientPermissions{Save: true, Fill: true},
+}
+
 func newTestServer(vault *mockVaultService) (*Server, *httptest.Server) {
 	cfg := &Config{
-		Secret:    "test-secret-hex",
 		NeverSave: []string{},
 	}
-	s := NewServer(vault, cfg)
+	vault.pairingData, _ = json.Marshal(clientsFile{Version: clientsFileVersion, Clients: []clientRecord{testClient}})
+	s := NewServer(vault, cfg, "")
 	s.pairing = NewPairingState(nil)
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/vault/pair", s.handlePair)
-	mux.HandleFunc("/vault/status", s.handleStatus)
-	mux.HandleFunc("/vault/exists", s.handleExists)
-	mux.HandleFunc("/vault/save", s.handleSave)
-	mux.HandleFunc("/vault/update/", s.handleUpdate)
-	mux.HandleFunc("/vault/never-save", s.handleNeverSave)
-
-	ts := httptest.NewServer(s.middleware(mux))
+	ts := httptest.NewServer(s.handler)
 	return s, ts
 }
 
-func doRequest(ts *httptest.Server, method, path, secret string, body interface{}) *http.Response {
+// testSession is the client side of an encrypted session. rekey is the
+// key handed over in the last result, if any.
+type testSession struct {
+	ts    *httptest.Server
+	id    string
+	send  cipher.AEAD
+	recv  cipher.AEAD
+	seq   uint64
+	rekey []byte
+}
+
+// openSession runs the session handshake as the extension does. It
+// returns nil with the response when the app refuses.
+func openSession(ts *httptest.Server, client clientRecord) (*testSession, *http.Response) {
+	priv, _ := ecdh.P256().GenerateKey(rand.Reader)
+	ephemeral := priv.PublicKey().Bytes()
+	resp := doRequest(ts, "POST", "/vault/session", nil, SessionRequest{
+		ClientID:  client.ClientID,
+		Ephemeral: ephemeral,
+		MAC:       clientHandshakeMAC(&client, ephemeral),
+	})
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp
+	}
+	var sr SessionResponse
+	json.NewDecoder(resp.Body).Decode(&sr)
+	if !bytes.Equal(sr.MAC, serverHandshakeMAC(&client, ephemeral, sr.Ephemeral, sr.SessionID)) {
+		panic("server handshake MAC does not verify")
+	}
+	peer, _ := ecdh.P256().NewPublicKey(sr.Ephemeral)
+	shared, _ := priv.ECDH(peer)
+	c2s, s2c, _ := sessionKeys(shared, &client, ephemeral, sr.Ephemeral, sr.SessionID)
+	sess := &testSession{ts: ts, id: sr.SessionID}
+	sess.send, _ = newSessionAEAD(c2s)
+	sess.recv, _ = newSessionAEAD(s2c)
+	return sess, resp
+}
+
+func mustOpenSession(t *testing.T, ts *httptest.Server) *testSession {
+	t.Helper()
+	sess, resp := openSession(ts, testClient)
+	if sess == nil {
+		t.Fatalf("session handshake: expected 200, got %d", resp.StatusCode)
+	}
+	return sess
+}
+
+// seal encrypts a call under the next sequence number.
+func (c *testSession) seal(method, path string, body interface{}) CallRequest {
+	msg := CallMessage{Method: method, Path: path}
+	if body != nil {
+		msg.Body, _ = json.Marshal(body)
+	}
+	plaintext, _ := json.Marshal(msg)
+	c.seq++
+	return CallRequest{
+		SessionID: c.id,
+		Seq:       c.seq,
+		Payload:   c.send.Seal(nil, callNonce(c.seq), plaintext, callAAD(c.id, c.seq)),
+	}
+}
+
+// post sends a sealed call and unwraps the result into a response, so the
+// tests read it as if the route had been called directly. Failures before
+// decryption come back as they are.
+func (c *testSession) post(call CallRequest) *http.Response {
+	resp := doRequest(c.ts, "POST", "/vault/call", nil, call)
+	if resp.StatusCode != http.StatusOK {
+		return resp
+	}
+	var cr CallResponse
+	json.NewDecoder(resp.Body).Decode(&cr)
+	plaintext, err := c.recv.Open(nil, callNonce(cr.Seq), cr.Payload, callAAD(c.id, cr.Seq))
+	if err != nil || cr.Seq != call.Seq {
+		panic("reply does not open")
+	}
+	var result CallResult
+	json.Unmarshal(plaintext, &result)
+	c.rekey = result.Rekey
+	return &http.Response{
+		StatusCode: result.Status,
+		Body:       io.NopCloser(bytes.NewReader(result.Body)),
+	}
+}
+
+// doRequest calls path directly, or inside sess when it is not nil.
+func doRequest(ts *httptest.Server, method, path string, sess *testSession, body interface{}) *http.Response {
+	if sess != nil {
+		return sess.post(sess.seal(method, path, body))
+	}
+
 	var reqBody *bytes.Buffer
 	if body != nil {
 		data, _ := json.Marshal(body)
@@ -81,9 +198,6 @@ func doRequest(ts *httptest.Server, method, path, secret string, body interface{
 
 	req, _ := http.NewRequest(method, ts.URL+path, reqBody)
 	req.Header.Set("Content-Type", "application/json")
-	if secret != "" {
-		req.Header.Set("X-Secret", secret)
-	}
 	resp, _ := http.DefaultClient.Do(req)
 	return resp
 }
@@ -95,7 +209,7 @@ func TestStatusEndpoint(t *testing.T) {
 	_, ts := newTestServer(vault)
 	defer ts.Close()
 
-	resp := doRequest(ts, "GET", "/vault/status", "", nil)
+	resp := doRequest(ts, "GET", "/vault/status", nil, nil)
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
@@ -112,26 +226,34 @@ func TestAuthRequired(t *testing.T) {
 	_, ts := newTestServer(vault)
 	defer ts.Close()
 
-	resp := doRequest(ts, "GET", "/vault/exists?domain=github.com", "", nil)
+	resp := doRequest(ts, "GET", "/vault/exists?domain=github.com", nil, nil)
 	if resp.StatusCode != http.StatusUnauthorized {
-		t.Fatalf("expected 401 without secret, got %d", resp.StatusCode)
+		t.Fatalf("expected 401 outside a session, got %d", resp.StatusCode)
 	}
 
-	resp = doRequest(ts, "GET", "/vault/exists?domain=github.com", "wrong-secret", nil)
+	resp = doRequest(ts, "POST", "/vault/call", nil, CallRequest{SessionID: "nope", Seq: 1, Payload: []byte("x")})
 	if resp.StatusCode != http.StatusUnauthorized {
-		t.Fatalf("expected 401 with wrong secret, got %d", resp.StatusCode)
+		t.Fatalf("expected 401 for an unknown session, got %d", resp.StatusCode)
 	}
 }
 
 func TestLockedVault(t *testing.T) {
-	vault := &mockVaultService{ready: false}
+	// Unlocked app, no vault open: the session opens, calls report locked.
+	vault := &mockVaultService{appUnlocked: true}
 	_, ts := newTestServer(vault)
 	defer ts.Close()
+	sess := mustOpenSession(t, ts)
 
-	resp := doRequest(ts, "GET", "/vault/exists?domain=github.com", "test-secret-hex", nil)
+	resp := doRequest(ts, "GET", "/vault/exists?domain=github.com", sess, nil)
 	if resp.StatusCode != http.StatusLocked {
 		t.Fatalf("expected 423, got %d", resp.StatusCode)
 	}
+
+	// Locked app: the client keys are sealed, so no session opens.
+	vault.appUnlocked = false
+	if _, resp := openSession(ts, testClient); resp.StatusCode != http.StatusLocked {
+		t.Fatalf("handshake while locked: expected 423, got %d", resp.StatusCode)
+	}
 }
 
 func TestExistsEndpoint(t *testing.T) {
@@ -143,8 +265,9 @@ func TestExistsEndpoint(t *testing.T) {
 	}
 	_, ts := newTestServer(vault)
 	defer ts.Close()
+	sess := mustOpenSession(t, ts)
 
-	resp := doRequest(ts, "GET", "/vault/exists?domain=github.com", "test-secret-hex", nil)
+	resp := doRequest(ts, "GET", "/vault/exists?url="+url.QueryEscape("https://gist.github.com/alice"), sess, nil)
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
@@ -154,6 +277,9 @@ func TestExistsEndpoint(t *testing.T) {
 	if !body.Found {
 		t.Fatal("expected found=true")
 	}
+	if body.Domain != "github.com" {
+		t.Fatalf("expected domain github.com, got %q", body.Domain)
+	}
 	if len(body.Credentials) != 1 {
 		t.Fatalf("expected 1 credential, got %d", len(body.Credentials))
 	}
@@ -163,8 +289,9 @@ func TestSaveEndpoint(t *testing.T) {
 	vault := &mockVaultService{ready: true}
 	_, ts := newTestServer(vault)
 	defer ts.Close()
+	sess := mustOpenSession(t, ts)
 
-	resp := doRequest(ts, "POST", "/vault/save", "test-secret-hex", SaveRequest{
+	resp := doRequest(ts, "POST", "/vault/save", sess, SaveRequest{
 		Domain:   "github.com",
 		Username: "user@test.com",
 		Password: "secret123!",
@@ -191,8 +318,9 @@ func TestUpdateEndpoint(t *testing.T) {
 	vault := &mockVaultService{ready: true}
 	_, ts := newTestServer(vault)
 	defer ts.Close()
+	sess := mustOpenSession(t, ts)
 
-	resp := doRequest(ts, "PUT", "/vault/update/12345", "test-secret-hex", UpdateRequest{
+	resp := doRequest(ts, "PUT", "/vault/update/12345", sess, UpdateRequest{
 		Password: "newpass456!",
 	})
 	if resp.StatusCode != http.StatusOK {
@@ -211,9 +339,10 @@ func TestNeverSaveEndpoints(t *testing.T) {
 	vault := &mockVaultService{ready: true}
 	s, ts := newTestServer(vault)
 	defer ts.Close()
+	sess := mustOpenSession(t, ts)
 
 	// POST — add domain
-	resp := doRequest(ts, "POST", "/vault/never-save", "test-secret-hex", NeverSaveRequest{
+	resp := doRequest(ts, "POST", "/vault/never-save", sess, NeverSaveRequest{
 		Domain: "example.com",
 	})
 	if resp.StatusCode != http.StatusOK {
@@ -224,7 +353,7 @@ func TestNeverSaveEndpoints(t *testing.T) {
 	}
 
 	// GET — list domains
-	resp = doRequest(ts, "GET", "/vault/never-save", "test-secret-hex", nil)
+	resp = doRequest(ts, "GET", "/vault/never-save", sess, nil)
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("GET expected 200, got %d", resp.StatusCode)
 	}
@@ -235,7 +364,7 @@ func TestNeverSaveEndpoints(t *testing.T) {
 	}
 
 	// DELETE — remove domain
-	resp = doRequest(ts, "DELETE", "/vault/never-save?domain=example.com", "test-secret-hex", nil)
+	resp = doRequest(ts, "DELETE", "/vault/never-save?domain=example.com", sess, nil)
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("DELETE expected 200, got %d", resp.StatusCode)
 	}
@@ -245,12 +374,12 @@ func TestNeverSaveEndpoints(t *testing.T) {
 }
 
 func TestPairingEndpoint(t *testing.T) {
-	vault := &mockVaultService{ready: false}
+	vault := &mockVaultService{ready: true}
 	s, ts := newTestServer(vault)
 	defer ts.Close()
 
-	// Step 1: initiate pairing (no token)
-	resp := doRequest(ts, "POST", "/vault/pair", "", nil)
+	// Step 1: initiate pairing
+	resp := doRequest(ts, "POST", "/vault/pair", nil, nil)
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
@@ -260,43 +389,65 @@ func TestPairingEndpoint(t *testing.T) {
 		t.Fatalf("expected status pending, got %s", pr.Status)
 	}
 
-	// Get the token from pairing state
+	// Get the code from pairing state
 	s.pairing.mu.Lock()
 	token := s.pairing.token
 	s.pairing.mu.Unlock()
 
-	// Step 2: wrong token
-	resp = doRequest(ts, "POST", "/vault/pair", "", PairRequest{Token: "000000"})
-	if resp.StatusCode != http.StatusUnauthorized {
-		t.Fatalf("expected 401 for wrong token, got %d", resp.StatusCode)
+	// Step 2: a wrong code fails the confirmation
+	key, resp := pairOverHTTP(ts, "000000")
+	if key != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong code, got %d", resp.StatusCode)
 	}
 
-	// Step 3: correct token
-	resp = doRequest(ts, "POST", "/vault/pair", "", PairRequest{Token: token})
+	// Step 3: correct code
+	key, resp = pairOverHTTP(ts, token)
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
 	json.NewDecoder(resp.Body).Decode(&pr)
-	if pr.Status != "paired" || pr.Secret == "" {
-		t.Fatalf("expected paired with secret, got status=%s secret=%q", pr.Status, pr.Secret)
+	if pr.Status != "paired" || pr.ClientID == "" || key == nil {
+		t.Fatalf("expected paired with a client ID, got %+v", pr)
+	}
+	var saved clientsFile
+	json.Unmarshal(vault.pairingData, &saved)
+	if len(saved.Clients) != 2 || !bytes.Equal(saved.Clients[1].Key, key) {
+		t.Fatalf("saved clients = %+v", saved.Clients)
+	}
+	if c := saved.Clients[1]; c.Name != "Work profile" || c.Browser != "chromium" {
+		t.Fatalf("new client listed as %q (%q)", c.Name, c.Browser)
 	}
-}
-
-func TestCORSPreflight(t *testing.T) {
-	vault := &mockVaultService{ready: true}
-	_, ts := newTestServer(vault)
-	defer ts.Close()
 
-	req, _ := http.NewRequest("OPTIONS", ts.URL+"/vault/status", nil)
-	req.Header.Set("Origin", "chrome-extension://abcdef123456")
-	resp, _ := http.DefaultClient.Do(req)
+	// The new client opens sessions.
+	if sess, resp := openSession(ts, clientRecord{ClientID: pr.ClientID, Key: key}); sess == nil {
+		t.Fatalf("session with the new client: got %d", resp.StatusCode)
+	}
+}
 
-	if resp.StatusCode != http.StatusNoContent {
-		t.Fatalf("expected 204, got %d", resp.StatusCode)
+// pairOverHTTP runs the client half of pairing with code and returns the
+// agreed key with the final response. A client that fails to verify the
+// app's confirmation still sends its own, as an attacker would.
+func pairOverHTTP(ts *httptest.Server, code string) ([]byte, *http.Response) {
+	client, _ := newSPAKE2(spake2Client, code)
+	resp := doRequest(ts, "POST", "/vault/pair", nil, PairRequest{Share: client.share})
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp
 	}
-	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "chrome-extension://abcdef123456" {
-		t.Fatalf("expected extension origin in CORS, got %q", got)
+	var pr PairResponse
+	json.NewDecoder(resp.Body).Decode(&pr)
+	keys, err := client.finish(pr.Share)
+	if err != nil {
+		return nil, resp
+	}
+	resp = doRequest(ts, "POST", "/vault/pair", nil, PairRequest{
+		Confirm: keys.clientConfirm,
+		Name:    "Work profile\n",
+		Browser: "chromium",
+	})
+	if !bytes.Equal(pr.Confirm, keys.serverConfirm) {
+		return nil, resp
 	}
+	return keys.pairingKey, resp
 }
 
 func TestRateLimit(t *testing.T) {
@@ -306,7 +457,7 @@ func TestRateLimit(t *testing.T) {
 
 	var lastStatus int
 	for i := 0; i < rateBurst+5; i++ {
-		resp := doRequest(ts, "GET", "/vault/status", "", nil)
+		resp := doRequest(ts, "GET", "/vault/status", nil, nil)
 		lastStatus = resp.StatusCode
 		resp.Body.Close()
 	}
@@ -320,8 +471,9 @@ func TestSaveMissingFields(t *testing.T) {
 	vault := &mockVaultService{ready: true}
 	_, ts := newTestServer(vault)
 	defer ts.Close()
+	sess := mustOpenSession(t, ts)
 
-	resp := doRequest(ts, "POST", "/vault/save", "test-secret-hex", SaveRequest{
+	resp := doRequest(ts, "POST", "/vault/save", sess, SaveRequest{
 		Domain: "github.com",
 	})
 	if resp.StatusCode != http.StatusBadRequest {
@@ -333,8 +485,9 @@ func TestExistsMissingDomain(t *testing.T) {
 	vault := &mockVaultService{ready: true}
 	_, ts := newTestServer(vault)
 	defer ts.Close()
+	sess := mustOpenSession(t, ts)
 
-	resp := doRequest(ts, "GET", "/vault/exists", "test-secret-hex", nil)
+	resp := doRequest(ts, "GET", "/vault/exists", sess, nil)
 	if resp.StatusCode != http.StatusBadRequest {
 		t.Fatalf("expected 400 for missing domain, got %d", resp.StatusCode)
 	}
@@ -346,7 +499,7 @@ func TestStatusEndpointNoAuth(t *testing.T) {
 	defer ts.Close()
 
 	// /vault/status should work without auth
-	resp := doRequest(ts, "GET", "/vault/status", "", nil)
+	resp := doRequest(ts, "GET", "/vault/status", nil, nil)
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
@@ -358,26 +511,13 @@ func TestStatusEndpointNoAuth(t *testing.T) {
 	}
 }
 
-func TestFirefoxCORSOrigin(t *testing.T) {
-	vault := &mockVaultService{ready: true}
-	_, ts := newTestServer(vault)
-	defer ts.Close()
-
-	req, _ := http.NewRequest("OPTIONS", ts.URL+"/vault/status", nil)
-	req.Header.Set("Origin", "moz-extension://abcdef-1234-5678")
-	resp, _ := http.DefaultClient.Do(req)
-
-	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "moz-extension://abcdef-1234-5678" {
-		t.Fatalf("expected Firefox extension origin in CORS, got %q", got)
-	}
-}
-
 func TestUpdateInvalidID(t *testing.T) {
 	vault := &mockVaultService{ready: true}
 	_, ts := newTestServer(vault)
 	defer ts.Close()
+	sess := mustOpenSession(t, ts)
 
-	resp := doRequest(ts, "PUT", "/vault/update/not-a-number", "test-secret-hex", UpdateRequest{
+	resp := doRequest(ts, "PUT", "/vault/update/not-a-number", sess, UpdateRequest{
 		Password: "new!",
 	})
 	if resp.StatusCode != http.StatusBadRequest {
@@ -0,0 +1,285 @@
+package browser
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// A session is opened with an ephemeral P-256 ECDH exchange authenticated
+// by the client's key, so its keys are fresh for every session and a copy
+// of that key taken later does not open recorded traffic. Every
+// call after that is one AES-256-GCM message each way. The extension's
+// side lives in background.js; the labels and layouts here must match it.
+
+const (
+	sessionIdleTTL       = 15 * time.Minute
+	sessionSweepInterval = 5 * time.Second
+	maxSessions          = 16
+	sessionIDBytes       = 16
+	// replayWindow is how far behind the highest sequence number a call
+	// may arrive, for requests the extension sends concurrently.
+	replayWindow = 64
+
+	sessionClientLabel = "passquantum session client"
+	sessionServerLabel = "passquantum session server"
+	sessionKeysLabel   = "passquantum session keys"
+)
+
+var (
+	errUnknownSession = errors.New("unknown or expired session")
+	errReplayed       = errors.New("replayed or out-of-window sequence number")
+	errBadCiphertext  = errors.New("message failed authentication")
+)
+
+// session is one open session. recv opens the client's calls and send
+// seals the replies. perms and rekey are the client's as of the
+// handshake; changing either ends the client's sessions.
+type session struct {
+	mu       sync.Mutex
+	id       string
+	clientID string
+	perms    ClientPermissions
+	rekey    []byte // pending key to hand to the client, if rotating
+	recv     cipher.AEAD
+	send     cipher.AEAD
+	highest  uint64
+	seen     uint64 // bit i set: highest-i has been accepted
+	lastUsed time.Time
+}
+
+// sessionTable holds the open sessions. It is cleared when the app locks.
+type sessionTable struct {
+	mu   sync.Mutex
+	byID map[string]*session
+}
+
+func newSessionTable() *sessionTable {
+	return &sessionTable{byID: make(map[string]*session)}
+}
+
+// open completes the server side of a session handshake for c, whose Key
+// is the one the handshake was made with. The caller has checked the
+// client's handshake MAC.
+func (t *sessionTable) open(c *clientRecord, clientEphemeral []byte) (sessionID string, serverEphemeral []byte, err error) {
+	curve := ecdh.P256()
+	peer, err := curve.NewPublicKey(clientEphemeral)
+	if err != nil {
+		return "", nil, fmt.Errorf("client ephemeral key: %w", err)
+	}
+	priv, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", nil, err
+	}
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return "", nil, err
+	}
+	defer clear(shared)
+
+	id := make([]byte, sessionIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("generate session ID: %w", err)
+	}
+	sessionID = hex.EncodeToString(id)
+	serverEphemeral = priv.PublicKey().Bytes()
+
+	c2s, s2c, err := sessionKeys(shared, c, clientEphemeral, serverEphemeral, sessionID)
+	if err != nil {
+		return "", nil, err
+	}
+	s := &session{
+		id:       sessionID,
+		clientID: c.ClientID,
+		perms:    c.Permissions,
+		rekey:    c.PendingKey,
+		lastUsed: time.Now(),
+	}
+	if s.recv, err = newSessionAEAD(c2s); err != nil {
+		return "", nil, err
+	}
+	if s.send, err = newSessionAEAD(s2c); err != nil {
+		return "", nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pruneLocked(time.Now())
+	if len(t.byID) >= maxSessions {
+		t.evictOldestLocked()
+	}
+	t.byID[sessionID] = s
+	return sessionID, serverEphemeral, nil
+}
+
+// get returns the session with id, marking it used.
+func (t *sessionTable) get(id string) (*session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.pruneLocked(now)
+	s, ok := t.byID[id]
+	if !ok {
+		return nil, errUnknownSession
+	}
+	s.mu.Lock()
+	s.lastUsed = now
+	s.mu.Unlock()
+	return s, nil
+}
+
+// clear ends every session.
+func (t *sessionTable) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.byID) > 0 {
+		log.Printf("[Browser] Ended %d extension session(s)", len(t.byID))
+	}
+	t.byID = make(map[string]*session)
+}
+
+// endClient ends the sessions of one client.
+func (t *sessionTable) endClient(clientID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, s := range t.byID {
+		if s.clientID == clientID {
+			delete(t.byID, id)
+		}
+	}
+}
+
+func (t *sessionTable) prune() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pruneLocked(time.Now())
+}
+
+func (t *sessionTable) pruneLocked(now time.Time) {
+	for id, s := range t.byID {
+		s.mu.Lock()
+		idle := now.Sub(s.lastUsed) > sessionIdleTTL
+		s.mu.Unlock()
+		if idle {
+			delete(t.byID, id)
+		}
+	}
+}
+
+func (t *sessionTable) evictOldestLocked() {
+	var oldest string
+	var oldestUse time.Time
+	for id, s := range t.byID {
+		s.mu.Lock()
+		used := s.lastUsed
+		s.mu.Unlock()
+		if oldest == "" || used.Before(oldestUse) {
+			oldest, oldestUse = id, used
+		}
+	}
+	delete(t.byID, oldest)
+}
+
+// openCall decrypts a call and checks its sequence number against the
+// replay window. A message that fails authentication does not move the
+// window.
+func (s *session) openCall(seq uint64, payload []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plaintext, err := s.recv.Open(nil, callNonce(seq), payload, callAAD(s.id, seq))
+	if err != nil {
+		return nil, errBadCiphertext
+	}
+	if !s.acceptLocked(seq) {
+		return nil, errReplayed
+	}
+	return plaintext, nil
+}
+
+// sealReply encrypts the reply to call seq. The directions have separate
+// keys, so the reply can reuse the call's sequence number.
+func (s *session) sealReply(seq uint64, plaintext []byte) []byte {
+	return s.send.Seal(nil, callNonce(seq), plaintext, callAAD(s.id, seq))
+}
+
+func (s *session) acceptLocked(seq uint64) bool {
+	if seq == 0 {
+		return false
+	}
+	if seq > s.highest {
+		if shift := seq - s.highest; shift >= replayWindow {
+			s.seen = 0
+		} else {
+			s.seen <<= shift
+		}
+		s.seen |= 1
+		s.highest = seq
+		return true
+	}
+	offset := s.highest - seq
+	if offset >= replayWindow || s.seen&(1<<offset) != 0 {
+		return false
+	}
+	s.seen |= 1 << offset
+	return true
+}
+
+// sessionKeys derives the client-to-server and server-to-client keys. The
+// client's key is the HKDF salt, so only the paired client can derive them.
+func sessionKeys(shared []byte, c *clientRecord, clientEphemeral, serverEphemeral []byte, sessionID string) (c2s, s2c []byte, err error) {
+	info := sessionKeysLabel + c.ClientID + string(clientEphemeral) + string(serverEphemeral) + sessionID
+	okm, err := hkdf.Key(sha256.New, shared, c.Key, info, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+	return okm[:32], okm[32:], nil
+}
+
+// clientHandshakeMAC is the MAC a client sends to open a session.
+func clientHandshakeMAC(c *clientRecord, clientEphemeral []byte) []byte {
+	return spake2MAC(c.Key, []byte(sessionClientLabel+c.ClientID+string(clientEphemeral)))
+}
+
+// serverHandshakeMAC is the MAC the app answers with, binding both
+// ephemeral keys and the session ID.
+func serverHandshakeMAC(c *clientRecord, clientEphemeral, serverEphemeral []byte, sessionID string) []byte {
+	return spake2MAC(c.Key, []byte(sessionServerLabel+c.ClientID+string(clientEphemeral)+string(serverEphemeral)+sessionID))
+}
+
+func validHandshakeMAC(c *clientRecord, clientEphemeral, mac []byte) bool {
+	return hmac.Equal(mac, clientHandshakeMAC(c, clientEphemeral))
+}
+
+func newSessionAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// callNonce is four zero bytes and the big-endian sequence number.
+func callNonce(seq uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], seq)
+	return nonce
+}
+
+// callAAD binds a message to its session and sequence number.
+func callAAD(sessionID string, seq uint64) []byte {
+	aad := make([]byte, len(sessionID)+8)
+	copy(aad, sessionID)
+	binary.BigEndian.PutUint64(aad[len(sessionID):], seq)
+	return aad
+}
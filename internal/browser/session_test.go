@@ -0,0 +1,129 @@
+package browser
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSessionHandshakeNeedsClientKey(t *testing.T) {
+	vault := &mockVaultService{ready: true}
+	_, ts := newTestServer(vault)
+	defer ts.Close()
+
+	forged := clientRecord{ClientID: testClient.ClientID, Key: make([]byte, pairingKeySize)}
+	if sess, resp := openSession(ts, forged); sess != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("handshake with the wrong key: expected 401, got %d", resp.StatusCode)
+	}
+	unknown := clientRecord{ClientID: "ffffffffffffffffffffffffffffffff", Key: testClient.Key}
+	if sess, resp := openSession(ts, unknown); sess != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("handshake for an unknown client: expected 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionRejectsReplayAndTampering(t *testing.T) {
+	vault := &mockVaultService{ready: true}
+	_, ts := newTestServer(vault)
+	defer ts.Close()
+	sess := mustOpenSession(t, ts)
+
+	first := sess.seal("GET", "/vault/never-save", nil)
+	second := sess.seal("GET", "/vault/never-save", nil)
+
+	// Out of order within the window is fine, each message once.
+	if resp := sess.post(second); resp.StatusCode != http.StatusOK {
+		t.Fatalf("second call: expected 200, got %d", resp.StatusCode)
+	}
+	if resp := sess.post(first); resp.StatusCode != http.StatusOK {
+		t.Fatalf("late first call: expected 200, got %d", resp.StatusCode)
+	}
+	if resp := sess.post(first); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("replayed call: expected 409, got %d", resp.StatusCode)
+	}
+
+	tampered := sess.seal("GET", "/vault/never-save", nil)
+	tampered.Payload[0] ^= 1
+	if resp := sess.post(tampered); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("tampered call: expected 400, got %d", resp.StatusCode)
+	}
+
+	moved := sess.seal("GET", "/vault/never-save", nil)
+	moved.Seq++
+	if resp := sess.post(moved); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("call under another sequence number: expected 400, got %d", resp.StatusCode)
+	}
+
+	stale := &session{}
+	stale.acceptLocked(replayWindow + 10)
+	if stale.acceptLocked(5) {
+		t.Fatal("sequence number behind the window accepted")
+	}
+}
+
+func TestSessionsEndOnLock(t *testing.T) {
+	vault := &mockVaultService{ready: true}
+	s, ts := newTestServer(vault)
+	defer ts.Close()
+	sess := mustOpenSession(t, ts)
+
+	vault.ready = false
+	resp := doRequest(ts, "GET", "/vault/status", nil, nil)
+	resp.Body.Close()
+	if len(s.sessions.byID) != 0 {
+		t.Fatal("sessions survived the lock")
+	}
+
+	vault.ready = true
+	resp = doRequest(ts, "GET", "/vault/never-save", sess, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("call in an ended session: expected 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionRejectsInvalidEphemeral(t *testing.T) {
+	vault := &mockVaultService{ready: true}
+	_, ts := newTestServer(vault)
+	defer ts.Close()
+
+	ephemeral := make([]byte, 65)
+	ephemeral[0] = 4
+	resp := doRequest(ts, "POST", "/vault/session", nil, SessionRequest{
+		ClientID:  testClient.ClientID,
+		Ephemeral: ephemeral,
+		MAC:       clientHandshakeMAC(&testClient, ephemeral),
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("point off the curve: expected 400, got %d", resp.StatusCode)
+	}
+
+	// A valid key still works afterwards.
+	priv, _ := ecdh.P256().GenerateKey(rand.Reader)
+	ephemeral = priv.PublicKey().Bytes()
+	resp = doRequest(ts, "POST", "/vault/session", nil, SessionRequest{
+		ClientID:  testClient.ClientID,
+		Ephemeral: ephemeral,
+		MAC:       clientHandshakeMAC(&testClient, ephemeral),
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+}
+
+// The extension carries its own copy of the SPAKE2 points.
+func TestExtensionSPAKE2Points(t *testing.T) {
+	src, err := os.ReadFile("../../extension/background.js")
+	if err != nil {
+		t.Skipf("extension source not available: %v", err)
+	}
+	m, _ := spake2M.MarshalBinary()
+	n, _ := spake2N.MarshalBinary()
+	for name, p := range map[string][]byte{"M": m, "N": n} {
+		if !strings.Contains(string(src), hex.EncodeToString(p)) {
+			t.Errorf("background.js does not carry SPAKE2 point %s", name)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package browser
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/cloudflare/circl/group"
+)
+
+// SPAKE2 (RFC 9382) over P-256 turns the 6-digit pairing code into a
+// 256-bit key. Each side proves it knows the code without revealing it,
+// and a party that does not know it learns nothing it could test offline:
+// every wrong guess costs one of the pairing attempts. The extension runs
+// the client half in background.js; the constants and key schedule here
+// must match it.
+
+const (
+	spake2ClientID = "passquantum-extension"
+	spake2ServerID = "passquantum-app"
+
+	spake2WordInfo     = "passquantum spake2 w"
+	spake2ConfirmAInfo = "passquantum spake2 confirm client"
+	spake2ConfirmBInfo = "passquantum spake2 confirm server"
+	spake2KeyInfo      = "passquantum spake2 pairing key"
+
+	spake2ShareSize = 65 // uncompressed P-256 point
+	pairingKeySize  = 32
+)
+
+var (
+	spake2Group = group.P256
+	// M and N are points nobody knows the discrete log of, hashed to the
+	// curve from fixed labels.
+	spake2M = spake2Group.HashToElement([]byte("M"), []byte("passquantum-SPAKE2-P256"))
+	spake2N = spake2Group.HashToElement([]byte("N"), []byte("passquantum-SPAKE2-P256"))
+)
+
+var errSPAKE2Share = errors.New("invalid SPAKE2 share")
+
+type spake2Role int
+
+const (
+	spake2Client spake2Role = iota
+	spake2Server
+)
+
+// spake2 is one side of an exchange.
+type spake2 struct {
+	role  spake2Role
+	w     group.Scalar
+	x     group.Scalar
+	share []byte
+}
+
+// spake2Keys is what a completed exchange agrees on. Each side sends its
+// own confirmation and checks the other's before trusting pairingKey.
+type spake2Keys struct {
+	clientConfirm []byte
+	serverConfirm []byte
+	pairingKey    []byte
+}
+
+func newSPAKE2(role spake2Role, code string) (*spake2, error) {
+	w, err := spake2Word(code)
+	if err != nil {
+		return nil, err
+	}
+	x := spake2Group.RandomNonZeroScalar(rand.Reader)
+	mask := spake2M
+	if role == spake2Server {
+		mask = spake2N
+	}
+	// share = x*G + w*mask
+	elt := spake2Group.NewElement().MulGen(x)
+	elt.Add(elt, spake2Group.NewElement().Mul(mask, w))
+	share, err := elt.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &spake2{role: role, w: w, x: x, share: share}, nil
+}
+
+// spake2Word maps the pairing code to the scalar w.
+func spake2Word(code string) (group.Scalar, error) {
+	wide, err := hkdf.Key(sha256.New, []byte(code), nil, spake2WordInfo, 48)
+	if err != nil {
+		return nil, err
+	}
+	// SetBigInt reduces mod the group order.
+	return spake2Group.NewScalar().SetBigInt(new(big.Int).SetBytes(wide)), nil
+}
+
+// finish combines the peer's share with ours into the agreed keys.
+func (s *spake2) finish(peerShare []byte) (*spake2Keys, error) {
+	if len(peerShare) != spake2ShareSize {
+		return nil, errSPAKE2Share
+	}
+	peer := spake2Group.NewElement()
+	if err := peer.UnmarshalBinary(peerShare); err != nil || peer.IsIdentity() {
+		return nil, errSPAKE2Share
+	}
+	peerMask := spake2N
+	if s.role == spake2Server {
+		peerMask = spake2M
+	}
+	// K = x*(peer - w*peerMask)
+	unmasked := spake2Group.NewElement().Mul(peerMask, s.w)
+	unmasked.Neg(unmasked)
+	unmasked.Add(unmasked, peer)
+	k := spake2Group.NewElement().Mul(unmasked, s.x)
+	if k.IsIdentity() {
+		return nil, errSPAKE2Share
+	}
+	kBytes, err := k.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	wBytes, err := s.w.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	shareA, shareB := s.share, peerShare
+	if s.role == spake2Server {
+		shareA, shareB = peerShare, s.share
+	}
+	transcript := sha256.New()
+	for _, field := range [][]byte{[]byte(spake2ClientID), []byte(spake2ServerID), shareA, shareB, kBytes, wBytes} {
+		var length [8]byte
+		binary.LittleEndian.PutUint64(length[:], uint64(len(field)))
+		transcript.Write(length[:])
+		transcript.Write(field)
+	}
+	th := transcript.Sum(nil)
+
+	derive := func(info string) ([]byte, error) {
+		return hkdf.Key(sha256.New, th, nil, info, pairingKeySize)
+	}
+	confirmA, err := derive(spake2ConfirmAInfo)
+	if err != nil {
+		return nil, err
+	}
+	confirmB, err := derive(spake2ConfirmBInfo)
+	if err != nil {
+		return nil, err
+	}
+	pairingKey, err := derive(spake2KeyInfo)
+	if err != nil {
+		return nil, err
+	}
+	return &spake2Keys{
+		clientConfirm: spake2MAC(confirmA, th),
+		serverConfirm: spake2MAC(confirmB, th),
+		pairingKey:    pairingKey,
+	}, nil
+}
+
+func spake2MAC(key, msg []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
@@ -5,9 +5,20 @@ package browser
 type VaultService interface {
 	IsReady() bool
 	Status() VaultStatus
-	FindCredentials(domain string) ([]CredentialSummary, error)
+	// FindCredentials returns the password entries offered on page, a URL
+	// or host name, under each entry's match rule.
+	FindCredentials(page string) ([]CredentialSummary, error)
 	SaveCredential(domain, username, password string) (uint64, error)
 	UpdatePassword(entryID uint64, newPassword string) error
+	// RevealCredential decrypts a password entry for /vault/fill, with the
+	// current code of its linked TOTP entry if it has one. Callers check
+	// that the entry belongs to the site and that the release was approved.
+	RevealCredential(entryID uint64) (*FillCredential, error)
+	// LoadPairingData and SavePairingData keep the paired clients and
+	// their keys sealed to the vault keypair. Both fail while the app is
+	// locked; no clients saved yet loads as nil.
+	LoadPairingData() ([]byte, error)
+	SavePairingData(data []byte) error
 }
 
 type VaultStatus struct {
@@ -15,8 +26,19 @@ type VaultStatus struct {
 	VaultName   string
 }
 
+// CredentialSummary identifies a credential without its secret. IDs travel
+// as decimal strings: a JavaScript number cannot hold every uint64.
 type CredentialSummary struct {
-	ID       uint64 `json:"id"`
+	ID       uint64 `json:"id,string"`
 	Service  string `json:"service"`
 	Username string `json:"username"`
 }
+
+// FillCredential is the secret /vault/fill releases.
+type FillCredential struct {
+	ID            uint64 `json:"id,string"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	TOTP          string `json:"totp,omitempty"`
+	TOTPRemaining int    `json:"totp_remaining,omitempty"`
+}
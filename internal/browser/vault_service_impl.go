@@ -2,10 +2,14 @@ package browser
 
 import (
 	"fmt"
+	"log"
+	"slices"
 	"strings"
 
 	pqapp "passquantum/app"
 	"passquantum/core/model"
+	"passquantum/core/totp"
+	"passquantum/core/urlmatch"
 )
 
 type appVaultService struct {
@@ -32,7 +36,11 @@ func (s *appVaultService) Status() VaultStatus {
 	}
 }
 
-func (s *appVaultService) FindCredentials(domain string) ([]CredentialSummary, error) {
+// FindCredentials applies every password entry's match rule to page. An
+// entry linked to the page's domain in the DomainMap belongs to that domain
+// unless its rule names another site; other entries are matched against the
+// site their service names.
+func (s *appVaultService) FindCredentials(page string) ([]CredentialSummary, error) {
 	s.state.Mu.Lock()
 	defer s.state.Mu.Unlock()
 
@@ -48,44 +56,27 @@ func (s *appVaultService) FindCredentials(domain string) ([]CredentialSummary, e
 
 	passwords := pqapp.EntriesByType(entries, model.EntryTypePassword)
 
-	normalized := NormalizeDomain(domain)
-	ids := s.domainMap.Lookup(domain)
+	normalized := NormalizeDomain(page)
+	ids := s.domainMap.Lookup(normalized)
 	idSet := make(map[uint64]bool, len(ids))
 	for _, id := range ids {
 		idSet[id] = true
 	}
 
 	var results []CredentialSummary
-	seen := make(map[uint64]bool)
-
 	for _, entry := range passwords {
-		if idSet[entry.ID] {
-			results = append(results, CredentialSummary{
-				ID:       entry.ID,
-				Service:  entry.Service,
-				Username: entry.Username,
-			})
-			seen[entry.ID] = true
+		site := urlmatch.ServiceSite(entry.Service)
+		if idSet[entry.ID] && (site == "" || entry.Match.Mode == model.MatchBaseDomain) {
+			site = normalized
 		}
-	}
-
-	// Fallback: fuzzy match on Service name
-	if normalized != "" {
-		baseDomain := strings.Split(normalized, ".")[0]
-		for _, entry := range passwords {
-			if seen[entry.ID] {
-				continue
-			}
-			serviceLower := strings.ToLower(entry.Service)
-			if strings.Contains(serviceLower, strings.ToLower(baseDomain)) ||
-				strings.Contains(serviceLower, strings.ToLower(normalized)) {
-				results = append(results, CredentialSummary{
-					ID:       entry.ID,
-					Service:  entry.Service,
-					Username: entry.Username,
-				})
-			}
+		if !urlmatch.Match(entry.Match, site, page) {
+			continue
 		}
+		results = append(results, CredentialSummary{
+			ID:       entry.ID,
+			Service:  entry.Service,
+			Username: entry.Username,
+		})
 	}
 
 	return results, nil
@@ -105,23 +96,13 @@ func (s *appVaultService) SaveCredential(domain, username, password string) (uin
 		return 0, fmt.Errorf("read vault: %w", err)
 	}
 
-	ct, ss, err := pqapp.Encapsulate(s.state.PublicKey)
-	if err != nil {
-		return 0, fmt.Errorf("encapsulate: %w", err)
-	}
-
-	nonce, ciphertext, err := pqapp.EncryptAES256GCM(password, ss)
-	if err != nil {
-		return 0, fmt.Errorf("encrypt: %w", err)
-	}
-
 	// Auto-update on duplicate: if an entry already exists for this
-	// domain + username, replace its crypto fields in place rather than
-	// creating a parallel duplicate entry.
+	// domain + username, swap its password in place (keeping any URLs,
+	// notes and custom fields) rather than creating a parallel duplicate.
 	if dup := pqapp.FindDuplicateEntry(entries, model.EntryTypePassword, domain, username); dup != nil {
-		dup.KyberCiphertext = ct
-		dup.Nonce = nonce
-		dup.Ciphertext = ciphertext
+		if err := s.resealPassword(dup, password); err != nil {
+			return 0, err
+		}
 		if err := pqapp.WriteVault(entries, vaultFile, s.state.MasterPassword); err != nil {
 			return 0, fmt.Errorf("write vault: %w", err)
 		}
@@ -133,9 +114,14 @@ func (s *appVaultService) SaveCredential(domain, username, password string) (uin
 	entry.Type = model.EntryTypePassword
 	entry.Service = domain
 	entry.Username = username
-	entry.KyberCiphertext = ct
-	entry.Nonce = nonce
-	entry.Ciphertext = ciphertext
+
+	payload := model.NewPasswordPayload(password)
+	if normalized := NormalizeDomain(domain); normalized != "" {
+		payload.LoginURL = "https://" + normalized
+	}
+	if err := pqapp.SealPasswordPayload(entry, payload, s.state.PublicKey); err != nil {
+		return 0, fmt.Errorf("encrypt: %w", err)
+	}
 
 	entries = append(entries, entry)
 
@@ -173,19 +159,105 @@ func (s *appVaultService) UpdatePassword(entryID uint64, newPassword string) err
 		return fmt.Errorf("entry %d not found", entryID)
 	}
 
-	ct, ss, err := pqapp.Encapsulate(s.state.PublicKey)
+	if err := s.resealPassword(target, newPassword); err != nil {
+		return err
+	}
+
+	return pqapp.WriteVault(entries, vaultFile, s.state.MasterPassword)
+}
+
+func (s *appVaultService) RevealCredential(entryID uint64) (*FillCredential, error) {
+	s.state.Mu.Lock()
+	defer s.state.Mu.Unlock()
+
+	if !s.state.IsUnlocked || s.state.CurrentVault == "" {
+		return nil, fmt.Errorf("vault is locked")
+	}
+
+	vaultFile := pqapp.GetVaultPath(s.state.CurrentVault)
+	entries, err := pqapp.ReadVault(vaultFile, s.state.MasterPassword)
 	if err != nil {
-		return fmt.Errorf("encapsulate: %w", err)
+		return nil, fmt.Errorf("read vault: %w", err)
 	}
 
-	nonce, ciphertext, err := pqapp.EncryptAES256GCM(newPassword, ss)
+	var target *model.VaultEntry
+	for _, e := range entries {
+		if e.ID == entryID && e.Type == model.EntryTypePassword && !e.Deleted {
+			target = e
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("entry %d not found", entryID)
+	}
+
+	payload, err := pqapp.OpenPasswordPayload(target, s.state.PrivateKey)
 	if err != nil {
-		return fmt.Errorf("encrypt: %w", err)
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	cred := &FillCredential{
+		ID:       target.ID,
+		Username: target.Username,
+		Password: payload.Password,
 	}
 
-	target.KyberCiphertext = ct
-	target.Nonce = nonce
-	target.Ciphertext = ciphertext
+	if linked := s.linkedTOTP(entries, target); linked != nil {
+		details, err := pqapp.DescribeEntry(linked, s.state.PrivateKey)
+		if err == nil && details.TOTP != nil {
+			cred.TOTP, cred.TOTPRemaining, err = totp.GenerateCode(details.TOTP)
+		}
+		if err != nil {
+			// The password is still worth filling without the code.
+			log.Printf("[Browser] WARNING: linked TOTP for entry %d: %v", target.ID, err)
+		}
+	}
+	return cred, nil
+}
 
-	return pqapp.WriteVault(entries, vaultFile, s.state.MasterPassword)
+// linkedTOTP finds the TOTP entry that belongs to a password entry: one for
+// the same service and account, as the importers create them, or else one
+// for the same account associated with any of the entry's domains.
+func (s *appVaultService) linkedTOTP(entries []*model.VaultEntry, entry *model.VaultEntry) *model.VaultEntry {
+	if dup := pqapp.FindDuplicateEntry(entries, model.EntryTypeTOTP, entry.Service, entry.Username); dup != nil {
+		return dup
+	}
+	linked := make(map[uint64]bool)
+	for _, ids := range s.domainMap.All() {
+		if slices.Contains(ids, entry.ID) {
+			for _, id := range ids {
+				linked[id] = true
+			}
+		}
+	}
+	for _, e := range entries {
+		if linked[e.ID] && e.Type == model.EntryTypeTOTP && !e.Deleted &&
+			strings.EqualFold(strings.TrimSpace(e.Username), strings.TrimSpace(entry.Username)) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *appVaultService) LoadPairingData() ([]byte, error) {
+	return pqapp.ReadSealedFile(s.state, clientsFileName)
+}
+
+func (s *appVaultService) SavePairingData(data []byte) error {
+	return pqapp.WriteSealedFile(s.state, clientsFileName, data)
+}
+
+// resealPassword replaces the password inside entry's structured payload
+// and re-encrypts it, archiving the previous version into the entry's
+// password history. If the existing payload cannot be decrypted it is
+// replaced with one holding only the new password. Caller holds state.Mu.
+func (s *appVaultService) resealPassword(entry *model.VaultEntry, password string) error {
+	payload, err := pqapp.OpenPasswordPayload(entry, s.state.PrivateKey)
+	if err != nil {
+		payload = model.NewPasswordPayload(password)
+	}
+	payload.Password = password
+	if err := pqapp.ReplacePasswordPayload(entry, payload, s.state.PublicKey, s.state.PrivateKey); err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	return nil
 }
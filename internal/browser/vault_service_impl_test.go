@@ -0,0 +1,90 @@
+package browser
+
+import (
+	"slices"
+	"testing"
+
+	pqapp "passquantum/app"
+	"passquantum/core/crypto"
+	"passquantum/core/model"
+)
+
+func TestFindCredentialsMatchRules(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	pubKey, privKey, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &pqapp.AppState{
+		PublicKey:      pubKey,
+		PrivateKey:     privKey,
+		MasterPassword: "browser-test-pass",
+		IsUnlocked:     true,
+		CurrentVault:   "browser-test",
+	}
+	logins := []struct {
+		service string
+		rule    model.MatchRule
+	}{
+		{"bank.com", model.MatchRule{}},
+		{"Example (example.com)", model.MatchRule{}},
+		{"Admin panel", model.MatchRule{Mode: model.MatchStartsWith, Pattern: "https://example.com/admin"}},
+		{"login.corp.com", model.MatchRule{Mode: model.MatchHost}},
+		{"NAS", model.MatchRule{Mode: model.MatchHostPort, Pattern: "https://nas.home.arpa:5001"}},
+		{"Regional", model.MatchRule{Mode: model.MatchRegex, Pattern: `https://(eu|us)\.shop\.com/.*`}},
+		{"old.example.com", model.MatchRule{Mode: model.MatchNever}},
+		{"Linked by name", model.MatchRule{}},
+	}
+	var entries []*model.VaultEntry
+	for _, l := range logins {
+		e := model.NewVaultEntry()
+		e.Service, e.Username, e.Match = l.service, "alice", l.rule
+		if err := pqapp.SealPasswordPayload(e, model.NewPasswordPayload("pw"), pubKey); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if err := pqapp.WriteVault(entries, pqapp.GetVaultPath(state.CurrentVault), state.MasterPassword); err != nil {
+		t.Fatal(err)
+	}
+	domainMap, err := NewDomainMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := domainMap.Associate("github.com", entries[7].ID); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewAppVaultService(state, domainMap)
+
+	for _, tt := range []struct {
+		page string
+		want []string
+	}{
+		{"https://online.bank.com/login", []string{"bank.com"}},
+		{"https://mybank-phish.com/login", nil},
+		{"https://bank.com.evil.io", nil},
+		{"https://www.example.com/", []string{"Example (example.com)"}},
+		{"https://example.com/admin/users", []string{"Example (example.com)", "Admin panel"}},
+		{"https://login.corp.com/sso", []string{"login.corp.com"}},
+		{"https://vpn.corp.com/", nil},
+		{"https://nas.home.arpa:5001/", []string{"NAS"}},
+		{"https://nas.home.arpa/", nil},
+		{"https://eu.shop.com/cart", []string{"Regional"}},
+		{"https://old.example.com/", []string{"Example (example.com)"}},
+		{"https://gist.github.com/", []string{"Linked by name"}},
+	} {
+		creds, err := svc.FindCredentials(tt.page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range creds {
+			got = append(got, c.Service)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("FindCredentials(%q) = %q, want %q", tt.page, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+// Package fsck verifies everything PassQuantum keeps in the vault directory
+// without changing it: vault files, every entry's encryption, the file
+// stores, the browser domain map and the security profile. Problems come
+// back as a Report; the ones with a safe fix are repaired only when asked.
+package fsck
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudflare/circl/kem"
+
+	pqapp "passquantum/app"
+	"passquantum/core/crypto"
+	"passquantum/core/filevault"
+	"passquantum/core/model"
+	"passquantum/core/storage"
+	"passquantum/internal/browser"
+	securestorage "passquantum/internal/storage"
+)
+
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Areas a Finding can belong to.
+const (
+	CheckProfile   = "profile"
+	CheckVault     = "vault"
+	CheckEntry     = "entry"
+	CheckFiles     = "files"
+	CheckDomainMap = "domain_map"
+)
+
+// Finding is one problem. Repair describes the fix Run would apply with
+// Options.Repair set; it is empty when there is no safe fix.
+type Finding struct {
+	Check       string   `json:"check"`
+	Severity    Severity `json:"severity"`
+	Vault       string   `json:"vault,omitempty"`
+	Subject     string   `json:"subject"`
+	Problem     string   `json:"problem"`
+	Repair      string   `json:"repair,omitempty"`
+	Repaired    bool     `json:"repaired,omitempty"`
+	RepairError string   `json:"repair_error,omitempty"`
+
+	fix func() error
+}
+
+// Report is the result of one Run.
+type Report struct {
+	Vaults      int        `json:"vaults"`
+	Entries     int        `json:"entries"`
+	Files       int        `json:"files"`
+	DomainLinks int        `json:"domain_links"`
+	Findings    []*Finding `json:"findings"`
+}
+
+// Outstanding returns the findings that were not repaired.
+func (r *Report) Outstanding() []*Finding {
+	var out []*Finding
+	for _, f := range r.Findings {
+		if !f.Repaired {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+type Options struct {
+	// Password is the master password; vaults and file manifests are
+	// encrypted with it.
+	Password string
+	// Repair applies every finding's Repair after the checks have run.
+	Repair bool
+}
+
+// entryRef records where an entry ID was seen, for the domain-map check.
+type entryRef struct {
+	vault   string
+	entry   *model.VaultEntry
+	trashed bool
+}
+
+type checker struct {
+	opts     Options
+	privKey  kem.PrivateKey
+	pubKey   kem.PublicKey
+	report   *Report
+	entries  map[uint64][]entryRef
+	complete bool // every vault decrypted, so entries lists every ID
+}
+
+// Run checks the vault directory. It returns an error only when the checks
+// cannot start at all; everything it finds is in the Report.
+func Run(opts Options) (*Report, error) {
+	vaultDir, err := securestorage.GetVaultDir()
+	if err != nil {
+		return nil, err
+	}
+	c := &checker{
+		opts:     opts,
+		report:   &Report{Findings: []*Finding{}},
+		entries:  map[uint64][]entryRef{},
+		complete: true,
+	}
+
+	c.checkProfile()
+	vaults, err := c.checkVaults(vaultDir)
+	if err != nil {
+		return nil, err
+	}
+	c.checkFileStores(vaults)
+	c.checkDomainMap()
+
+	if opts.Repair {
+		for _, f := range c.report.Findings {
+			if f.fix == nil {
+				continue
+			}
+			if err := f.fix(); err != nil {
+				f.RepairError = err.Error()
+			} else {
+				f.Repaired = true
+			}
+		}
+	}
+	return c.report, nil
+}
+
+func (c *checker) add(f *Finding) {
+	c.report.Findings = append(c.report.Findings, f)
+}
+
+// checkProfile loads the keypair and confirms that the security profile
+// belongs to this private.key and to the given master password.
+func (c *checker) checkProfile() {
+	pub, priv, err := pqapp.LoadStoredKeypair()
+	if err == nil && priv == nil {
+		priv, err = pqapp.LoadStoredPrivateKey(c.opts.Password)
+	} else if err == nil {
+		c.add(&Finding{Check: CheckProfile, Severity: SeverityWarning, Subject: pqapp.PrivKeyPath,
+			Problem: "stored unencrypted; it is wrapped under the master password at the next unlock"})
+	}
+	if err != nil {
+		c.add(&Finding{Check: CheckProfile, Severity: SeverityError, Subject: pqapp.PrivKeyPath,
+			Problem: fmt.Sprintf("cannot load keypair; entries and files are not checked: %v", err)})
+	} else {
+		c.pubKey, c.privKey = pub, priv
+		if next, ok := pqapp.KeypairMigrationTarget(pub); ok {
+			algorithm, _ := crypto.KEMAlgorithmOf(pub)
+			c.add(&Finding{Check: CheckProfile, Severity: SeverityWarning, Subject: pqapp.PubKeyPath,
+				Problem: fmt.Sprintf("keypair uses %s; it is migrated to %s at the next unlock", algorithm, next)})
+		}
+	}
+
+	profile, err := storage.LoadAppSecurityProfile("")
+	if err != nil {
+		c.add(&Finding{Check: CheckProfile, Severity: SeverityError, Subject: storage.DefaultAppSecurityMetadataPath,
+			Problem: fmt.Sprintf("cannot load security profile: %v", err)})
+		return
+	}
+	if c.privKey == nil {
+		return
+	}
+	_, _, verified, fingerprintOK, err := crypto.VerifyAppSecurityProfile(profile, c.opts.Password, c.privKey)
+	switch {
+	case err != nil:
+		c.add(&Finding{Check: CheckProfile, Severity: SeverityError, Subject: storage.DefaultAppSecurityMetadataPath,
+			Problem: err.Error()})
+	case !fingerprintOK:
+		c.add(&Finding{Check: CheckProfile, Severity: SeverityError, Subject: storage.DefaultAppSecurityMetadataPath,
+			Problem: "private key fingerprint does not match " + pqapp.PrivKeyPath})
+	case !verified:
+		c.add(&Finding{Check: CheckProfile, Severity: SeverityError, Subject: storage.DefaultAppSecurityMetadataPath,
+			Problem: "master password does not match the security profile"})
+	}
+}
+
+// checkVaults verifies every .enc and .pqdb file and returns the vault names.
+func (c *checker) checkVaults(vaultDir string) ([]string, error) {
+	files, err := os.ReadDir(vaultDir)
+	if err != nil {
+		return nil, fmt.Errorf("read vault directory: %w", err)
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".enc" && ext != ".pqdb") {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), ext)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		c.report.Vaults++
+		c.checkVaultFile(name, filepath.Join(vaultDir, f.Name()))
+	}
+	return names, nil
+}
+
+func (c *checker) checkVaultFile(vault, path string) {
+	subject := filepath.Base(path)
+	fail := func(problem string) {
+		c.complete = false
+		c.add(&Finding{Check: CheckVault, Severity: SeverityError, Vault: vault, Subject: subject, Problem: problem})
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	if crypto.IsPQVaultFormat(data) {
+		header, err := crypto.ParsePQVaultHeader(data)
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		if header.KEM != crypto.PQVaultKEM || header.Signature != crypto.DefaultSignature {
+			c.add(&Finding{Check: CheckVault, Severity: SeverityWarning, Vault: vault, Subject: subject,
+				Problem: fmt.Sprintf("sealed with %s and %s; it is rewritten with %s and %s the next time it is saved",
+					header.KEM, header.Signature, crypto.PQVaultKEM, crypto.DefaultSignature)})
+		}
+	} else {
+		c.add(&Finding{Check: CheckVault, Severity: SeverityWarning, Vault: vault, Subject: subject,
+			Problem: "legacy vault format; it is converted the next time the vault is opened"})
+	}
+
+	entries, err := storage.DecodeVaultFile(path, c.opts.Password)
+	switch {
+	case errors.Is(err, crypto.ErrPQSignatureInvalid):
+		fail("signature does not verify (wrong master password or modified file)")
+		return
+	case errors.Is(err, crypto.ErrPQAuthFailed):
+		fail("signature verifies but the payload does not decrypt")
+		return
+	case err != nil:
+		fail(err.Error())
+		return
+	}
+
+	unbound := 0
+	for _, e := range entries {
+		c.report.Entries++
+		c.entries[e.ID] = append(c.entries[e.ID], entryRef{vault: vault, entry: e, trashed: e.Deleted})
+		if len(e.KyberCiphertext) > 0 && e.CryptoVersion < model.CurrentEntryCrypto {
+			unbound++
+		}
+		if c.privKey != nil {
+			c.checkEntry(vault, e)
+		}
+	}
+	if unbound > 0 {
+		c.add(&Finding{Check: CheckVault, Severity: SeverityWarning, Vault: vault, Subject: subject,
+			Problem: fmt.Sprintf("%d entries are not yet bound to their metadata; they are re-sealed the next time the vault is changed", unbound)})
+	}
+}
+
+// checkEntry opens the entry's payload and every saved password version.
+func (c *checker) checkEntry(vault string, e *model.VaultEntry) {
+	subject := pqapp.FormatEntryID(e.ID) + " " + pqapp.EntryName(e)
+	if _, err := pqapp.OpenEntrySecret(e, c.privKey); err != nil {
+		c.add(&Finding{Check: CheckEntry, Severity: SeverityError, Vault: vault, Subject: subject, Problem: err.Error()})
+	}
+	for i := range e.PasswordHistory {
+		if _, err := pqapp.OpenPasswordHistory(e, i, c.privKey); err != nil {
+			c.add(&Finding{Check: CheckEntry, Severity: SeverityWarning, Vault: vault, Subject: subject,
+				Problem: fmt.Sprintf("password history version %d: %v", i+1, err)})
+		}
+	}
+}
+
+// checkFileStores compares each vault's file manifest with its blobs.
+func (c *checker) checkFileStores(vaults []string) {
+	stores, err := filevault.ListStores()
+	if err != nil {
+		c.add(&Finding{Check: CheckFiles, Severity: SeverityError, Subject: "files", Problem: err.Error()})
+		return
+	}
+	known := map[string]bool{}
+	for _, v := range vaults {
+		known[v] = true
+	}
+
+	for _, name := range stores {
+		if !known[name] {
+			c.add(&Finding{Check: CheckFiles, Severity: SeverityWarning, Vault: name, Subject: "files/" + name,
+				Problem: "file store belongs to a vault that no longer exists"})
+			continue
+		}
+		if c.privKey == nil {
+			continue
+		}
+		store, err := filevault.NewStore(name, c.opts.Password, c.pubKey, c.privKey, nil)
+		if err != nil {
+			c.add(&Finding{Check: CheckFiles, Severity: SeverityError, Vault: name, Subject: "manifest.enc", Problem: err.Error()})
+			continue
+		}
+		c.report.Files += len(store.ListFiles()) + len(store.ListTrash())
+		legacy := 0
+		for _, f := range append(store.ListFiles(), store.ListTrash()...) {
+			if f.StreamVersion < filevault.StreamV2 {
+				legacy++
+			}
+		}
+		if legacy > 0 {
+			c.add(&Finding{Check: CheckFiles, Severity: SeverityWarning, Vault: name, Subject: "files/" + name,
+				Problem: fmt.Sprintf("%d files use stream format v1, which cannot detect truncation; they are rewritten the next time the file store is opened", legacy)})
+		}
+
+		problems, err := store.Verify()
+		if err != nil {
+			c.add(&Finding{Check: CheckFiles, Severity: SeverityError, Vault: name, Subject: "files/" + name, Problem: err.Error()})
+			continue
+		}
+		for _, p := range problems {
+			c.add(fileFinding(name, store, p))
+		}
+	}
+}
+
+func fileFinding(vault string, store *filevault.Store, p filevault.StoreProblem) *Finding {
+	f := &Finding{Check: CheckFiles, Severity: SeverityError, Vault: vault, Subject: p.UUID + ".bin"}
+	if p.Name != "" {
+		f.Subject += " (" + p.Name + ")"
+	}
+	uuid := p.UUID
+	switch p.Kind {
+	case filevault.ProblemMissingBlob:
+		f.Problem = "listed in the manifest but missing on disk"
+		f.Repair = "remove from manifest"
+		f.fix = func() error { return store.ForgetFile(uuid) }
+	case filevault.ProblemOrphanBlob:
+		f.Severity = SeverityWarning
+		f.Problem = "not listed in the manifest"
+		f.Repair = "delete blob"
+		f.fix = func() error { return store.RemoveOrphan(uuid) }
+	case filevault.ProblemHashMismatch:
+		f.Problem = "content does not match the manifest: " + p.Detail
+	default:
+		f.Problem = "cannot be decrypted: " + p.Detail
+	}
+	return f
+}
+
+// checkDomainMap finds domain links to entries that no vault contains.
+// Links are only repaired when every vault could be read; otherwise the
+// entry may just be in a vault this run could not open.
+func (c *checker) checkDomainMap() {
+	dm, err := browser.NewDomainMap()
+	if err != nil {
+		c.add(&Finding{Check: CheckDomainMap, Severity: SeverityError, Subject: "domain_map.json", Problem: err.Error()})
+		return
+	}
+	all := dm.All()
+	domains := make([]string, 0, len(all))
+	for d := range all {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+
+	for _, domain := range domains {
+		ids := all[domain]
+		for _, id := range ids {
+			c.report.DomainLinks++
+			if len(c.entries[id]) > 0 {
+				continue
+			}
+			f := &Finding{Check: CheckDomainMap, Severity: SeverityWarning, Subject: domain,
+				Problem: fmt.Sprintf("linked to entry %s, which no vault contains", pqapp.FormatEntryID(id))}
+			if c.complete {
+				domain, id := domain, id
+				if target, ok := c.relinkTarget(domain, ids); ok {
+					f.Repair = "relink to " + pqapp.FormatEntryID(target)
+					f.fix = func() error { return dm.Relink(domain, id, target) }
+				} else {
+					f.Repair = "drop link"
+					f.fix = func() error { return dm.Dissociate(domain, id) }
+				}
+			}
+			c.add(f)
+		}
+	}
+}
+
+// relinkTarget returns the one live password entry whose service is domain
+// and that is not linked to it yet. Several candidates are ambiguous.
+func (c *checker) relinkTarget(domain string, linked []uint64) (uint64, bool) {
+	isLinked := map[uint64]bool{}
+	for _, id := range linked {
+		isLinked[id] = true
+	}
+	var found []uint64
+	for id, refs := range c.entries {
+		if isLinked[id] {
+			continue
+		}
+		for _, r := range refs {
+			if !r.trashed && r.entry.Type == model.EntryTypePassword && browser.NormalizeDomain(r.entry.Service) == domain {
+				found = append(found, id)
+				break
+			}
+		}
+	}
+	if len(found) != 1 {
+		return 0, false
+	}
+	return found[0], true
+}
@@ -0,0 +1,277 @@
+package fsck
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/circl/kem"
+
+	pqapp "passquantum/app"
+	"passquantum/core/crypto"
+	"passquantum/core/filevault"
+	"passquantum/core/model"
+	"passquantum/core/storage"
+	"passquantum/internal/browser"
+	securestorage "passquantum/internal/storage"
+)
+
+const testPassword = "correct horse battery staple"
+
+type testInstall struct {
+	pub   kem.PublicKey
+	priv  kem.PrivateKey
+	vault string
+	entry *model.VaultEntry
+}
+
+// newTestInstall sets up a keypair, security profile and a "Default" vault
+// holding one github.com login, in a temporary config directory.
+func newTestInstall(t *testing.T) *testInstall {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	pub, priv, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPath, _ := securestorage.GetSecureFilePath(pqapp.PubKeyPath)
+	privPath, _ := securestorage.GetSecureFilePath(pqapp.PrivKeyPath)
+	if err := crypto.SaveKeypair(pub, priv, pubPath, privPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := crypto.SaveWrappedPrivateKey(priv, privPath, testPassword); err != nil {
+		t.Fatal(err)
+	}
+	profile, _, _, err := crypto.CreateAppSecurityProfile(testPassword, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SaveAppSecurityProfile("", profile); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ID is bound to the ciphertext, so it is fixed before sealing.
+	entry := model.NewVaultEntry()
+	entry.ID = 1
+	entry.Service, entry.Username = "github.com", "alice"
+	if err := pqapp.SealPasswordPayload(entry, &model.PasswordPayload{Password: "hunter2"}, pub); err != nil {
+		t.Fatal(err)
+	}
+	in := &testInstall{pub: pub, priv: priv, vault: pqapp.GetVaultPath("Default"), entry: entry}
+	in.writeVault(t, entry)
+	return in
+}
+
+func (in *testInstall) writeVault(t *testing.T, entries ...*model.VaultEntry) {
+	t.Helper()
+	if err := storage.WriteVault(entries, in.vault, testPassword); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func run(t *testing.T, repair bool) *Report {
+	t.Helper()
+	report, err := Run(Options{Password: testPassword, Repair: repair})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func findingsFor(r *Report, check string) []*Finding {
+	var out []*Finding
+	for _, f := range r.Findings {
+		if f.Check == check {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func TestRunClean(t *testing.T) {
+	newTestInstall(t)
+
+	r := run(t, false)
+	if len(r.Findings) != 0 {
+		t.Fatalf("findings on a clean install: %+v", r.Findings[0])
+	}
+	if r.Vaults != 1 || r.Entries != 1 {
+		t.Errorf("checked %d vaults, %d entries", r.Vaults, r.Entries)
+	}
+}
+
+func TestRunTamperedVault(t *testing.T) {
+	in := newTestInstall(t)
+	data, err := os.ReadFile(in.vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(in.vault, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	vaults := findingsFor(run(t, false), CheckVault)
+	if len(vaults) != 1 || !strings.Contains(vaults[0].Problem, "signature") {
+		t.Fatalf("vault findings = %+v", vaults)
+	}
+}
+
+func TestRunTruncatedVault(t *testing.T) {
+	in := newTestInstall(t)
+	if err := os.WriteFile(in.vault, []byte("PQVT\x01short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	vaults := findingsFor(run(t, false), CheckVault)
+	if len(vaults) != 1 || !strings.Contains(vaults[0].Problem, "header") {
+		t.Fatalf("vault findings = %+v", vaults)
+	}
+}
+
+func TestRunCorruptKDFHeader(t *testing.T) {
+	in := newTestInstall(t)
+	data, err := os.ReadFile(in.vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The iteration count follows the magic, version, algorithm IDs and
+	// the 32-byte salt; zero used to panic in Argon2id.
+	binary.LittleEndian.PutUint32(data[7+32:], 0)
+	if err := os.WriteFile(in.vault, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	vaults := findingsFor(run(t, false), CheckVault)
+	if len(vaults) != 1 || !strings.Contains(vaults[0].Problem, "header") {
+		t.Fatalf("vault findings = %+v", vaults)
+	}
+}
+
+func TestRunCorruptEntry(t *testing.T) {
+	in := newTestInstall(t)
+	in.entry.Ciphertext[0] ^= 0xff
+	in.writeVault(t, in.entry)
+
+	entries := findingsFor(run(t, false), CheckEntry)
+	if len(entries) != 1 || entries[0].Severity != SeverityError {
+		t.Fatalf("entry findings = %+v", entries)
+	}
+}
+
+func TestRunProfileFingerprint(t *testing.T) {
+	newTestInstall(t)
+	pub, priv, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPath, _ := securestorage.GetSecureFilePath(pqapp.PubKeyPath)
+	privPath, _ := securestorage.GetSecureFilePath(pqapp.PrivKeyPath)
+	if err := crypto.SaveKeypair(pub, priv, pubPath, privPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := crypto.SaveWrappedPrivateKey(priv, privPath, testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	r := run(t, false)
+	profile := findingsFor(r, CheckProfile)
+	if len(profile) != 1 || !strings.Contains(profile[0].Problem, "fingerprint") {
+		t.Fatalf("profile findings = %+v", profile)
+	}
+	// The vault entry was sealed for the old key.
+	if len(findingsFor(r, CheckEntry)) != 1 {
+		t.Errorf("entry findings = %+v", findingsFor(r, CheckEntry))
+	}
+}
+
+func TestRunFileStoreRepair(t *testing.T) {
+	in := newTestInstall(t)
+	store, err := filevault.NewStore("Default", testPassword, in.pub, in.priv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "doc.txt")
+	if err := os.WriteFile(src, []byte("contents"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	kept, err := store.StoreFile(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost, err := store.StoreFile(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Dir(filepath.Dir(in.vault))
+	blobs := filepath.Join(dir, "passquantum", "files", "Default")
+	if err := os.Remove(filepath.Join(blobs, lost.UUID+".bin")); err != nil {
+		t.Fatal(err)
+	}
+	orphan := filepath.Join(blobs, "orphan.bin")
+	if err := os.WriteFile(orphan, []byte("stray"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := run(t, true)
+	files := findingsFor(r, CheckFiles)
+	if len(files) != 2 || r.Files != 2 {
+		t.Fatalf("file findings = %+v, %d files", files, r.Files)
+	}
+	for _, f := range files {
+		if !f.Repaired {
+			t.Errorf("%s not repaired: %s", f.Subject, f.RepairError)
+		}
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("orphan blob still present: %v", err)
+	}
+
+	r = run(t, false)
+	if len(r.Findings) != 0 || r.Files != 1 {
+		t.Fatalf("after repair: %d findings, %d files", len(r.Findings), r.Files)
+	}
+	if _, err := os.Stat(filepath.Join(blobs, kept.UUID+".bin")); err != nil {
+		t.Errorf("intact blob touched: %v", err)
+	}
+}
+
+func TestRunDomainMapRepair(t *testing.T) {
+	newTestInstall(t)
+	dm, err := browser.NewDomainMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for domain, id := range map[string]uint64{"github.com": 99, "example.com": 77} {
+		if err := dm.Associate(domain, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := run(t, false)
+	links := findingsFor(r, CheckDomainMap)
+	if len(links) != 2 || r.DomainLinks != 2 {
+		t.Fatalf("domain findings = %+v", links)
+	}
+	if links[0].Subject != "example.com" || links[0].Repair != "drop link" {
+		t.Errorf("example.com: %+v", links[0])
+	}
+	if links[1].Subject != "github.com" || !strings.HasPrefix(links[1].Repair, "relink") {
+		t.Errorf("github.com: %+v", links[1])
+	}
+
+	run(t, true)
+	dm, err = browser.NewDomainMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := dm.All()
+	if len(all) != 1 || len(all["github.com"]) != 1 || all["github.com"][0] != 1 {
+		t.Fatalf("domain map after repair = %v", all)
+	}
+}
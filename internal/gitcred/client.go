@@ -0,0 +1,66 @@
+package gitcred
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotRunning is returned by Client.Do when the desktop app is not
+// serving credentials.
+var ErrNotRunning = errors.New("PassQuantum is not running")
+
+// Client sends one credential request per call to a running Server.
+type Client struct {
+	socketPath string
+	secretPath string
+}
+
+func NewClient(socketPath, secretPath string) *Client {
+	return &Client{socketPath: socketPath, secretPath: secretPath}
+}
+
+// Do performs action ("get", "store" or "erase") for cred. For "get" it
+// returns the matching credential, or nil when the vault has none.
+func (c *Client) Do(action string, cred *Credential) (*Credential, error) {
+	secret, err := os.ReadFile(c.secretPath)
+	if os.IsNotExist(err) {
+		return nil, ErrNotRunning
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secret: %w", err)
+	}
+
+	conn, err := net.DialTimeout("unix", c.socketPath, requestTimeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	if _, err := fmt.Fprintf(conn, "%s\n%s\n", strings.TrimSpace(string(secret)), action); err != nil {
+		return nil, err
+	}
+	if err := cred.Write(conn); err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintln(conn); err != nil {
+		return nil, err
+	}
+
+	attrs, err := readAttributes(bufio.NewReader(conn))
+	if err != nil {
+		return nil, err
+	}
+	if msg, ok := attrs["error"]; ok {
+		return nil, errors.New(msg)
+	}
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+	return credentialFromAttributes(attrs), nil
+}
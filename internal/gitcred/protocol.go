@@ -0,0 +1,90 @@
+package gitcred
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Credential is the set of attributes git exchanges with a credential
+// helper (see gitcredentials(7)). Attributes this package does not use are
+// dropped when reading.
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// ReadCredential reads "key=value" lines up to a blank line or EOF.
+func ReadCredential(r io.Reader) (*Credential, error) {
+	attrs, err := readAttributes(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	return credentialFromAttributes(attrs), nil
+}
+
+// Write writes the non-empty attributes of c as "key=value" lines. It does
+// not write the terminating blank line.
+func (c *Credential) Write(w io.Writer) error {
+	for _, kv := range [][2]string{
+		{"protocol", c.Protocol},
+		{"host", c.Host},
+		{"path", c.Path},
+		{"username", c.Username},
+		{"password", c.Password},
+	} {
+		if err := writeAttribute(w, kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func credentialFromAttributes(attrs map[string]string) *Credential {
+	return &Credential{
+		Protocol: attrs["protocol"],
+		Host:     attrs["host"],
+		Path:     attrs["path"],
+		Username: attrs["username"],
+		Password: attrs["password"],
+	}
+}
+
+// readAttributes reads "key=value" lines until a blank line or EOF. A key
+// given more than once keeps its last value, as git does.
+func readAttributes(br *bufio.Reader) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			return attrs, nil
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed credential line %q", line)
+		}
+		attrs[key] = value
+		if err == io.EOF {
+			return attrs, nil
+		}
+	}
+}
+
+func writeAttribute(w io.Writer, key, value string) error {
+	if value == "" {
+		return nil
+	}
+	if strings.ContainsAny(value, "\n\x00") {
+		return fmt.Errorf("credential %s contains a newline or NUL", key)
+	}
+	_, err := fmt.Fprintf(w, "%s=%s\n", key, value)
+	return err
+}
@@ -0,0 +1,178 @@
+package gitcred
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"passquantum/internal/browser"
+	securestorage "passquantum/internal/storage"
+)
+
+const (
+	socketFileName = "git-credential.sock"
+	secretFileName = "git_credential.secret"
+	requestTimeout = 10 * time.Second
+)
+
+// DefaultSocketPath is the server socket inside the 0700 vault directory.
+func DefaultSocketPath() (string, error) {
+	return securestorage.GetSecureFilePath(socketFileName)
+}
+
+// DefaultSecretPath is the file holding the current run's shared secret.
+func DefaultSecretPath() (string, error) {
+	return securestorage.GetSecureFilePath(secretFileName)
+}
+
+// Server answers credential requests from git-credential-passquantum on a
+// Unix socket. Every Start generates a fresh secret and writes it to a 0600
+// file next to the socket; a client must present it before its request is
+// read. Stop removes both files.
+//
+// One request per connection: the secret line, the action line ("get",
+// "store" or "erase"), then the credential attributes. The reply is the
+// credential attributes for "get", nothing for the other actions, or a
+// single "error=" attribute.
+type Server struct {
+	store      Store
+	socketPath string
+	secretPath string
+
+	mu       sync.Mutex
+	listener net.Listener
+	secret   string
+}
+
+func NewServer(store Store, socketPath, secretPath string) *Server {
+	return &Server{store: store, socketPath: socketPath, secretPath: secretPath}
+}
+
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return nil
+	}
+
+	secret, err := browser.GenerateSecret()
+	if err != nil {
+		return err
+	}
+	if err := securestorage.WriteFileAtomic(s.secretPath, []byte(secret+"\n"), 0600); err != nil {
+		return fmt.Errorf("write secret: %w", err)
+	}
+
+	// A socket left behind by a crashed run would make Listen fail.
+	if info, err := os.Lstat(s.socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and is not a socket", s.socketPath)
+		}
+		if err := os.Remove(s.socketPath); err != nil {
+			return fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", s.socketPath)
+	if err != nil {
+		os.Remove(s.secretPath)
+		return err
+	}
+	if err := os.Chmod(s.socketPath, 0600); err != nil {
+		ln.Close()
+		os.Remove(s.secretPath)
+		return fmt.Errorf("restrict socket permissions: %w", err)
+	}
+
+	s.listener = ln
+	s.secret = secret
+	log.Printf("[GitCred] listening on %s", s.socketPath)
+
+	go s.serve(ln, secret)
+	return nil
+}
+
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	s.listener = nil
+	s.secret = ""
+	os.Remove(s.socketPath)
+	os.Remove(s.secretPath)
+	log.Println("[GitCred] stopped")
+	return err
+}
+
+func (s *Server) serve(ln net.Listener, secret string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[GitCred] accept error: %v", err)
+			}
+			return
+		}
+		go s.handle(conn, secret)
+	}
+}
+
+func (s *Server) handle(conn net.Conn, secret string) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	reply, err := s.answer(bufio.NewReader(conn), secret)
+	if err != nil {
+		writeAttribute(conn, "error", err.Error())
+		return
+	}
+	if reply != nil {
+		if err := reply.Write(conn); err != nil {
+			log.Printf("[GitCred] write reply: %v", err)
+		}
+	}
+}
+
+func (s *Server) answer(br *bufio.Reader, secret string) (*Credential, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("read secret: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(line)), []byte(secret)) != 1 {
+		return nil, fmt.Errorf("invalid secret")
+	}
+
+	line, err = br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("read action: %w", err)
+	}
+	action := strings.TrimSpace(line)
+
+	attrs, err := readAttributes(br)
+	if err != nil {
+		return nil, err
+	}
+	cred := credentialFromAttributes(attrs)
+
+	switch action {
+	case "get":
+		return s.store.Get(cred)
+	case "store":
+		return nil, s.store.Store(cred)
+	case "erase":
+		return nil, s.store.Erase(cred)
+	}
+	return nil, fmt.Errorf("unknown action %q", action)
+}
@@ -0,0 +1,135 @@
+package gitcred
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fakeStore struct {
+	creds  map[string]*Credential // keyed by host
+	erased []string
+}
+
+func (f *fakeStore) Get(c *Credential) (*Credential, error) {
+	if c.Host == "locked.example" {
+		return nil, fmt.Errorf("PassQuantum is locked")
+	}
+	got, ok := f.creds[c.Host]
+	if !ok {
+		return nil, nil
+	}
+	return &Credential{Username: got.Username, Password: got.Password}, nil
+}
+
+func (f *fakeStore) Store(c *Credential) error {
+	f.creds[c.Host] = c
+	return nil
+}
+
+func (f *fakeStore) Erase(c *Credential) error {
+	f.erased = append(f.erased, c.Host)
+	delete(f.creds, c.Host)
+	return nil
+}
+
+func startTestServer(t *testing.T, store Store) (*Server, *Client) {
+	t.Helper()
+	dir := t.TempDir()
+	s := NewServer(store, filepath.Join(dir, "git.sock"), filepath.Join(dir, "git.secret"))
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return s, NewClient(s.socketPath, s.secretPath)
+}
+
+func TestCredentialRoundTrip(t *testing.T) {
+	in := "protocol=https\nhost=github.com\nusername=alice\nwwwauth[]=Basic\n\nignored=after-blank\n"
+	c, err := ReadCredential(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("ReadCredential: %v", err)
+	}
+	if c.Protocol != "https" || c.Host != "github.com" || c.Username != "alice" || c.Password != "" {
+		t.Fatalf("parsed %+v", c)
+	}
+
+	var buf bytes.Buffer
+	c.Password = "tok"
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "protocol=https\nhost=github.com\nusername=alice\npassword=tok\n"; got != want {
+		t.Errorf("Write = %q, want %q", got, want)
+	}
+
+	if _, err := ReadCredential(strings.NewReader("no-equals-sign\n")); err == nil {
+		t.Error("malformed line should be rejected")
+	}
+	if err := (&Credential{Password: "a\nb"}).Write(&buf); err == nil {
+		t.Error("newline in a value should be rejected")
+	}
+}
+
+func TestServerGetStoreErase(t *testing.T) {
+	store := &fakeStore{creds: map[string]*Credential{}}
+	_, client := startTestServer(t, store)
+
+	got, err := client.Do("get", &Credential{Protocol: "https", Host: "github.com"})
+	if err != nil || got != nil {
+		t.Fatalf("get before store = %+v, %v", got, err)
+	}
+
+	if _, err := client.Do("store", &Credential{Protocol: "https", Host: "github.com", Username: "alice", Password: "tok"}); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	got, err = client.Do("get", &Credential{Protocol: "https", Host: "github.com"})
+	if err != nil || got == nil || got.Username != "alice" || got.Password != "tok" {
+		t.Fatalf("get = %+v, %v", got, err)
+	}
+
+	if _, err := client.Do("erase", &Credential{Host: "github.com"}); err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+	if len(store.erased) != 1 {
+		t.Errorf("erased = %v", store.erased)
+	}
+
+	if _, err := client.Do("get", &Credential{Host: "locked.example"}); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Errorf("store errors should reach the client, got %v", err)
+	}
+}
+
+func TestServerRequiresSecret(t *testing.T) {
+	store := &fakeStore{creds: map[string]*Credential{"github.com": {Username: "alice", Password: "tok"}}}
+	s, _ := startTestServer(t, store)
+
+	info, err := os.Stat(s.secretPath)
+	if err != nil {
+		t.Fatalf("secret file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("secret file mode = %v", info.Mode().Perm())
+	}
+
+	forged := filepath.Join(t.TempDir(), "forged")
+	if err := os.WriteFile(forged, []byte("not-the-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(s.socketPath, forged).Do("get", &Credential{Host: "github.com"}); err == nil {
+		t.Fatal("a wrong secret should be refused")
+	}
+
+	s.Stop()
+	for _, path := range []string{s.socketPath, s.secretPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed on Stop", path)
+		}
+	}
+	if _, err := NewClient(s.socketPath, s.secretPath).Do("get", &Credential{Host: "github.com"}); err != ErrNotRunning {
+		t.Errorf("after Stop err = %v, want ErrNotRunning", err)
+	}
+}
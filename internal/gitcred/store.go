@@ -0,0 +1,236 @@
+package gitcred
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	pqapp "passquantum/app"
+	"passquantum/core/model"
+	"passquantum/internal/browser"
+)
+
+// GitTag marks password entries created by the credential helper. Erase
+// requests only ever trash entries carrying it.
+const GitTag = "git"
+
+// Store answers git's credential actions. All methods are safe for
+// concurrent use.
+type Store interface {
+	// Get returns the credential for c's host, or nil if there is none.
+	Get(c *Credential) (*Credential, error)
+	// Store saves a credential git has just used successfully.
+	Store(c *Credential) error
+	// Erase forgets a credential the remote rejected.
+	Erase(c *Credential) error
+}
+
+// appStore serves password entries from the open vault. Hosts are matched
+// exactly like browser lookups: each entry's match rule against the
+// protocol and host, with the DomainMap.
+type appStore struct {
+	state     *pqapp.AppState
+	vault     browser.VaultService
+	domainMap *browser.DomainMap
+}
+
+func NewAppStore(state *pqapp.AppState, domainMap *browser.DomainMap) Store {
+	return &appStore{
+		state:     state,
+		vault:     browser.NewAppVaultService(state, domainMap),
+		domainMap: domainMap,
+	}
+}
+
+func (s *appStore) Get(c *Credential) (*Credential, error) {
+	ids, err := s.matches(c)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	s.state.Mu.Lock()
+	entries, err := s.readVault()
+	if err != nil {
+		s.state.Mu.Unlock()
+		return nil, err
+	}
+	entry := pickEntry(entries, ids)
+	if entry == nil {
+		s.state.Mu.Unlock()
+		return nil, nil
+	}
+	payload, err := pqapp.OpenPasswordPayload(entry, s.state.PrivateKey)
+	s.state.Mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+
+	_ = pqapp.MarkEntryUsed(s.state, entry.ID)
+	return &Credential{Username: entry.Username, Password: payload.Password}, nil
+}
+
+// Store is called after every successful authentication, so an unchanged
+// credential is not written back. A changed password replaces the old one
+// and the old one goes to the entry's password history.
+func (s *appStore) Store(c *Credential) error {
+	domain := browser.NormalizeDomain(c.Host)
+	if domain == "" || c.Password == "" {
+		return nil
+	}
+
+	s.state.Mu.Lock()
+	defer s.state.Mu.Unlock()
+
+	entries, err := s.readVault()
+	if err != nil {
+		return err
+	}
+	vaultFile := pqapp.GetVaultPath(s.state.CurrentVault)
+
+	if dup := pqapp.FindDuplicateEntry(entries, model.EntryTypePassword, domain, c.Username); dup != nil {
+		payload, err := pqapp.OpenPasswordPayload(dup, s.state.PrivateKey)
+		if err == nil && payload.Password == c.Password {
+			return nil
+		}
+		if err != nil {
+			payload = model.NewPasswordPayload(c.Password)
+		}
+		payload.Password = c.Password
+		if err := pqapp.ReplacePasswordPayload(dup, payload, s.state.PublicKey, s.state.PrivateKey); err != nil {
+			return fmt.Errorf("encrypt: %w", err)
+		}
+		if err := pqapp.WriteVault(entries, vaultFile, s.state.MasterPassword); err != nil {
+			return fmt.Errorf("write vault: %w", err)
+		}
+		_ = s.domainMap.Associate(domain, dup.ID)
+		return nil
+	}
+
+	entry := model.NewVaultEntry()
+	entry.Type = model.EntryTypePassword
+	entry.Service = domain
+	entry.Username = c.Username
+	entry.Tags = []string{GitTag}
+
+	protocol := c.Protocol
+	if protocol == "" {
+		protocol = "https"
+	}
+	payload := model.NewPasswordPayload(c.Password)
+	payload.LoginURL = protocol + "://" + c.Host
+	if err := pqapp.SealPasswordPayload(entry, payload, s.state.PublicKey); err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+
+	entries = append(entries, entry)
+	if err := pqapp.WriteVault(entries, vaultFile, s.state.MasterPassword); err != nil {
+		return fmt.Errorf("write vault: %w", err)
+	}
+	_ = s.domainMap.Associate(domain, entry.ID)
+	return nil
+}
+
+// Erase moves the matching helper-created entries to the trash, where they
+// can still be restored. Entries saved any other way are left alone, and
+// when git names the rejected password only an entry holding it is erased.
+func (s *appStore) Erase(c *Credential) error {
+	ids, err := s.matches(c)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	s.state.Mu.Lock()
+	defer s.state.Mu.Unlock()
+
+	entries, err := s.readVault()
+	if err != nil {
+		return err
+	}
+	idSet := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		idSet[id] = true
+	}
+
+	changed := false
+	for _, e := range entries {
+		if !idSet[e.ID] || e.Deleted || !e.HasTag(GitTag) {
+			continue
+		}
+		if c.Password != "" {
+			payload, err := pqapp.OpenPasswordPayload(e, s.state.PrivateKey)
+			if err != nil || payload.Password != c.Password {
+				continue
+			}
+		}
+		e.MoveToTrash(time.Now().UTC())
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return pqapp.WriteVault(entries, pqapp.GetVaultPath(s.state.CurrentVault), s.state.MasterPassword)
+}
+
+// matches returns the IDs of the password entries for c's host, best match
+// first, narrowed to c's username when git supplies one.
+func (s *appStore) matches(c *Credential) ([]uint64, error) {
+	domain := browser.NormalizeDomain(c.Host)
+	if domain == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+	if !s.vault.IsReady() {
+		return nil, fmt.Errorf("PassQuantum is locked")
+	}
+	page := c.Host
+	if c.Protocol != "" {
+		page = c.Protocol + "://" + c.Host
+	}
+	found, err := s.vault.FindCredentials(page)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, f := range found {
+		if c.Username == "" || strings.EqualFold(f.Username, c.Username) {
+			ids = append(ids, f.ID)
+		}
+	}
+	return ids, nil
+}
+
+// readVault reads the open vault. Caller holds state.Mu.
+func (s *appStore) readVault() ([]*model.VaultEntry, error) {
+	if !s.state.IsUnlocked || s.state.CurrentVault == "" {
+		return nil, fmt.Errorf("PassQuantum is locked")
+	}
+	entries, err := pqapp.ReadVault(pqapp.GetVaultPath(s.state.CurrentVault), s.state.MasterPassword)
+	if err != nil {
+		return nil, fmt.Errorf("read vault: %w", err)
+	}
+	return entries, nil
+}
+
+// pickEntry returns the first live entry in ids order, preferring entries
+// the helper created over, say, a website login for the same host.
+func pickEntry(entries []*model.VaultEntry, ids []uint64) *model.VaultEntry {
+	byID := make(map[uint64]*model.VaultEntry, len(entries))
+	for _, e := range entries {
+		if !e.Deleted {
+			byID[e.ID] = e
+		}
+	}
+	var first *model.VaultEntry
+	for _, id := range ids {
+		e := byID[id]
+		if e == nil {
+			continue
+		}
+		if e.HasTag(GitTag) {
+			return e
+		}
+		if first == nil {
+			first = e
+		}
+	}
+	return first
+}
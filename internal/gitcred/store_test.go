@@ -0,0 +1,115 @@
+package gitcred
+
+import (
+	"testing"
+
+	pqapp "passquantum/app"
+	"passquantum/core/crypto"
+	"passquantum/core/model"
+	"passquantum/internal/browser"
+)
+
+// newTestStore opens a temp vault holding one password entry per login
+// (service, username, password) and returns a Store over it.
+func newTestStore(t *testing.T, logins ...[3]string) (*pqapp.AppState, Store) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	pubKey, privKey, err := crypto.GenerateKeypair()
+	if err != nil {
+		t.Fatalf("GenerateKeypair: %v", err)
+	}
+	state := &pqapp.AppState{
+		PublicKey:      pubKey,
+		PrivateKey:     privKey,
+		MasterPassword: "gitcred-test-pass",
+		IsUnlocked:     true,
+		CurrentVault:   "gitcred-test",
+	}
+	var entries []*model.VaultEntry
+	for _, login := range logins {
+		e := model.NewVaultEntry()
+		e.Service, e.Username = login[0], login[1]
+		if err := pqapp.SealPasswordPayload(e, model.NewPasswordPayload(login[2]), pubKey); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if err := pqapp.WriteVault(entries, pqapp.GetVaultPath(state.CurrentVault), state.MasterPassword); err != nil {
+		t.Fatalf("WriteVault: %v", err)
+	}
+	domainMap, err := browser.NewDomainMap()
+	if err != nil {
+		t.Fatalf("NewDomainMap: %v", err)
+	}
+	return state, NewAppStore(state, domainMap)
+}
+
+func TestAppStoreMatchesLikeBrowser(t *testing.T) {
+	_, store := newTestStore(t,
+		[3]string{"github.com", "alice", "web-pass"},
+		[3]string{"gitlab.com", "alice", "gitlab-pass"},
+	)
+
+	got, err := store.Get(&Credential{Protocol: "https", Host: "api.github.com"})
+	if err != nil || got == nil || got.Password != "web-pass" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	got, err = store.Get(&Credential{Protocol: "https", Host: "github.com", Username: "bob"})
+	if err != nil || got != nil {
+		t.Errorf("Get for another user = %+v, %v", got, err)
+	}
+	got, err = store.Get(&Credential{Protocol: "https", Host: "bitbucket.org"})
+	if err != nil || got != nil {
+		t.Errorf("Get for unknown host = %+v, %v", got, err)
+	}
+}
+
+func TestAppStoreStoreAndErase(t *testing.T) {
+	state, store := newTestStore(t, [3]string{"github.com", "alice", "web-pass"})
+
+	token := &Credential{Protocol: "https", Host: "github.com", Username: "alice-ci", Password: "ghp_token"}
+	if err := store.Store(token); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	// git stores after every successful use; an unchanged token is a no-op.
+	if err := store.Store(token); err != nil {
+		t.Fatalf("Store again: %v", err)
+	}
+	entries, err := pqapp.ReadVault(pqapp.GetVaultPath(state.CurrentVault), state.MasterPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || !entries[1].HasTag(GitTag) {
+		t.Fatalf("expected one new git-tagged entry, got %d entries", len(entries))
+	}
+
+	got, err := store.Get(&Credential{Protocol: "https", Host: "github.com"})
+	if err != nil || got == nil || got.Password != "ghp_token" {
+		t.Fatalf("Get should prefer the helper's entry, got %+v, %v", got, err)
+	}
+
+	// The website login is never erased, and a stale password erases nothing.
+	if err := store.Erase(&Credential{Host: "github.com", Username: "alice", Password: "web-pass"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Erase(&Credential{Host: "github.com", Username: "alice-ci", Password: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Erase(&Credential{Host: "github.com", Username: "alice-ci", Password: "ghp_token"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = pqapp.ReadVault(pqapp.GetVaultPath(state.CurrentVault), state.MasterPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Deleted || !entries[1].Deleted {
+		t.Errorf("deleted = %v/%v, want only the token trashed", entries[0].Deleted, entries[1].Deleted)
+	}
+
+	state.IsUnlocked = false
+	if _, err := store.Get(&Credential{Host: "github.com"}); err == nil {
+		t.Error("Get should fail while locked")
+	}
+}
@@ -0,0 +1,130 @@
+package lockpolicy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindName    = "org.freedesktop.login1"
+	logindPath    = dbus.ObjectPath("/org/freedesktop/login1")
+	logindManager = "org.freedesktop.login1.Manager"
+	logindSession = "org.freedesktop.login1.Session"
+)
+
+// LogindWatcher turns systemd-logind signals into lock triggers: Lock on
+// the app's own session (screen lockers and `loginctl lock-session` send
+// it) and PrepareForSleep before suspend or hibernate.
+type LogindWatcher struct {
+	busAddress string
+	onEvent    func(Reason)
+
+	mu   sync.Mutex
+	conn *dbus.Conn
+}
+
+// NewLogindWatcher watches the bus at busAddress, or the system bus when it
+// is empty.
+func NewLogindWatcher(busAddress string, onEvent func(Reason)) *LogindWatcher {
+	return &LogindWatcher{busAddress: busAddress, onEvent: onEvent}
+}
+
+func (lw *LogindWatcher) Start() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	if lw.conn != nil {
+		return nil
+	}
+
+	var conn *dbus.Conn
+	var err error
+	if lw.busAddress == "" {
+		conn, err = dbus.ConnectSystemBus()
+	} else {
+		conn, err = dbus.Connect(lw.busAddress)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to system bus: %w", err)
+	}
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(logindPath),
+		dbus.WithMatchInterface(logindManager),
+		dbus.WithMatchMember("PrepareForSleep"),
+	); err != nil {
+		conn.Close()
+		return fmt.Errorf("watch PrepareForSleep: %w", err)
+	}
+
+	// Without a session (e.g. started from a plain ssh shell or a sandbox)
+	// only suspend can be detected.
+	session, err := ownSession(conn)
+	if err != nil {
+		log.Printf("[LockPolicy] WARNING: no logind session, screen lock will not lock the app: %v", err)
+	} else if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(session),
+		dbus.WithMatchInterface(logindSession),
+		dbus.WithMatchMember("Lock"),
+	); err != nil {
+		conn.Close()
+		return fmt.Errorf("watch session Lock: %w", err)
+	}
+
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	lw.conn = conn
+	go lw.dispatch(signals, session)
+	return nil
+}
+
+func (lw *LogindWatcher) Stop() {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if lw.conn != nil {
+		lw.conn.Close()
+		lw.conn = nil
+	}
+}
+
+// dispatch runs until the connection is closed, which closes signals.
+func (lw *LogindWatcher) dispatch(signals <-chan *dbus.Signal, session dbus.ObjectPath) {
+	for sig := range signals {
+		switch {
+		case sig.Name == logindManager+".PrepareForSleep" && sig.Path == logindPath:
+			if starting, ok := firstBool(sig.Body); ok && starting {
+				lw.onEvent(ReasonSuspend)
+			}
+		case sig.Name == logindSession+".Lock" && session != "" && sig.Path == session:
+			lw.onEvent(ReasonScreenLock)
+		}
+	}
+}
+
+// ownSession finds the logind session this process belongs to.
+func ownSession(conn *dbus.Conn) (dbus.ObjectPath, error) {
+	manager := conn.Object(logindName, logindPath)
+	var session dbus.ObjectPath
+	err := manager.Call(logindManager+".GetSessionByPID", 0, uint32(os.Getpid())).Store(&session)
+	if err == nil {
+		return session, nil
+	}
+	// Processes started by a user service manager are not in the session's
+	// cgroup; "auto" resolves to the caller's graphical session instead.
+	if err := manager.Call(logindManager+".GetSession", 0, "auto").Store(&session); err == nil {
+		return session, nil
+	}
+	return "", err
+}
+
+func firstBool(body []interface{}) (bool, bool) {
+	if len(body) == 0 {
+		return false, false
+	}
+	b, ok := body[0].(bool)
+	return b, ok
+}
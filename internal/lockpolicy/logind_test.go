@@ -0,0 +1,94 @@
+package lockpolicy
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeLogind answers the session lookup the watcher makes at start.
+type fakeLogind struct{ session dbus.ObjectPath }
+
+func (f *fakeLogind) GetSessionByPID(pid uint32) (dbus.ObjectPath, *dbus.Error) {
+	return f.session, nil
+}
+
+func (f *fakeLogind) GetSession(id string) (dbus.ObjectPath, *dbus.Error) {
+	return f.session, nil
+}
+
+// privateBus starts a throwaway bus standing in for the system bus.
+func privateBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address=1",
+		"--address=unix:path="+t.TempDir()+"/bus")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read bus address: %v", err)
+	}
+	return strings.TrimSpace(line)
+}
+
+func TestLogindWatcher(t *testing.T) {
+	addr := privateBus(t)
+	logind, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logind.Close()
+
+	own := dbus.ObjectPath("/org/freedesktop/login1/session/_32")
+	if err := logind.Export(&fakeLogind{session: own}, logindPath, logindManager); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := logind.RequestName(logindName, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan Reason, 4)
+	lw := NewLogindWatcher(addr, func(r Reason) { events <- r })
+	if err := lw.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer lw.Stop()
+
+	logind.Emit("/org/freedesktop/login1/session/_37", logindSession+".Lock")
+	logind.Emit(own, logindSession+".Lock")
+	logind.Emit(logindPath, logindManager+".PrepareForSleep", false)
+	logind.Emit(logindPath, logindManager+".PrepareForSleep", true)
+
+	for _, want := range []Reason{ReasonScreenLock, ReasonSuspend} {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Fatalf("event = %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %q event", want)
+		}
+	}
+	select {
+	case got := <-events:
+		t.Errorf("unexpected event %q", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,218 @@
+package lockpolicy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Reason names the trigger that locked the app.
+type Reason string
+
+const (
+	ReasonIdle          Reason = "idle timeout"
+	ReasonMaxSession    Reason = "session length limit"
+	ReasonScreenLock    Reason = "screen locked"
+	ReasonSuspend       Reason = "system suspend"
+	ReasonFailedUnlocks Reason = "too many failed unlock attempts"
+)
+
+// ErrLockedOut is returned by UnlockAllowed while unlocking is refused
+// after too many wrong master passwords.
+var ErrLockedOut = errors.New("too many failed unlock attempts")
+
+// checkInterval is how often the timers are evaluated.
+const checkInterval = 5 * time.Second
+
+// Monitor applies a Policy to the running app. The UI reports activity and
+// session boundaries; the Monitor calls onLock once per unlocked session
+// when any trigger fires. onLock runs on the Monitor's goroutine or the
+// caller's, never with the Monitor's lock held.
+type Monitor struct {
+	mu     sync.Mutex
+	policy Policy
+	onLock func(Reason)
+	now    func() time.Time
+
+	active         bool
+	sessionStart   time.Time
+	lastActivity   time.Time
+	failures       int
+	lockedOutUntil time.Time
+
+	stop chan struct{}
+}
+
+func NewMonitor(policy *Policy, onLock func(Reason)) *Monitor {
+	if policy == nil {
+		policy = DefaultPolicy()
+	}
+	return &Monitor{policy: *policy, onLock: onLock, now: time.Now}
+}
+
+// Policy returns a copy of the policy in force.
+func (m *Monitor) Policy() Policy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.policy
+}
+
+// SetPolicy replaces the policy; timers restart from the current time so a
+// shorter limit does not lock the app the moment it is saved.
+func (m *Monitor) SetPolicy(p Policy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = p
+	now := m.now()
+	if m.active {
+		m.sessionStart, m.lastActivity = now, now
+	}
+}
+
+// Start evaluates the timers in the background until Stop is called.
+func (m *Monitor) Start() {
+	m.mu.Lock()
+	if m.stop != nil {
+		m.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	m.stop = stop
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.check()
+			}
+		}
+	}()
+}
+
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+// SessionStarted is called after a successful unlock. It also clears the
+// failed-attempt count.
+func (m *Monitor) SessionStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.active = true
+	m.sessionStart, m.lastActivity = now, now
+	m.failures = 0
+	m.lockedOutUntil = time.Time{}
+}
+
+// SessionEnded is called whenever the app locks, by a trigger or by hand.
+func (m *Monitor) SessionEnded() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active = false
+}
+
+// Activity records user input and restarts the idle timer.
+func (m *Monitor) Activity() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active {
+		m.lastActivity = m.now()
+	}
+}
+
+// UnlockAllowed returns an error wrapping ErrLockedOut while a lockout is
+// in effect.
+func (m *Monitor) UnlockAllowed() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if remaining := m.lockedOutUntil.Sub(m.now()); remaining > 0 {
+		return fmt.Errorf("%w: try again in %s", ErrLockedOut, remaining.Round(time.Second))
+	}
+	return nil
+}
+
+// UnlockFailed counts a wrong master password. Reaching the policy's limit
+// starts the lockout and fires onLock.
+func (m *Monitor) UnlockFailed() {
+	m.mu.Lock()
+	m.failures++
+	limit := m.policy.MaxFailedUnlocks
+	if limit <= 0 || m.failures < limit {
+		m.mu.Unlock()
+		return
+	}
+	m.failures = 0
+	m.lockedOutUntil = m.now().Add(m.policy.Lockout())
+	m.active = false
+	m.mu.Unlock()
+
+	m.fire(ReasonFailedUnlocks)
+}
+
+// Trigger reports a system event (ReasonScreenLock or ReasonSuspend). The
+// app locks if the policy asks for it and a session is active.
+func (m *Monitor) Trigger(reason Reason) {
+	m.mu.Lock()
+	enabled := true
+	switch reason {
+	case ReasonScreenLock:
+		enabled = m.policy.LockOnScreenLock
+	case ReasonSuspend:
+		enabled = m.policy.LockOnSuspend
+	}
+	if !enabled || !m.active {
+		m.mu.Unlock()
+		return
+	}
+	m.active = false
+	m.mu.Unlock()
+
+	m.fire(reason)
+}
+
+func (m *Monitor) check() {
+	m.mu.Lock()
+	reason := m.due(m.now())
+	if reason != "" {
+		m.active = false
+	}
+	m.mu.Unlock()
+
+	if reason != "" {
+		m.fire(reason)
+	}
+}
+
+// due returns the timer that has expired, if any. Caller holds m.mu.
+func (m *Monitor) due(now time.Time) Reason {
+	if !m.active {
+		return ""
+	}
+	if limit := m.policy.MaxSession(); limit > 0 && now.Sub(m.sessionStart) >= limit {
+		return ReasonMaxSession
+	}
+	if limit := m.policy.IdleTimeout(); limit > 0 && now.Sub(m.lastActivity) >= limit {
+		return ReasonIdle
+	}
+	return ""
+}
+
+func (m *Monitor) fire(reason Reason) {
+	log.Printf("[LockPolicy] locking: %s", reason)
+	if m.onLock != nil {
+		m.onLock(reason)
+	}
+}
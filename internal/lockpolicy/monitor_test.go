@@ -0,0 +1,134 @@
+package lockpolicy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestMonitor(p Policy) (*Monitor, *fakeClock, *[]Reason) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)}
+	var fired []Reason
+	m := NewMonitor(&p, func(r Reason) { fired = append(fired, r) })
+	m.now = clock.now
+	return m, clock, &fired
+}
+
+func TestMonitorIdleTimeout(t *testing.T) {
+	m, clock, fired := newTestMonitor(Policy{IdleMinutes: 10})
+
+	m.check()
+	if len(*fired) != 0 {
+		t.Fatal("must not lock before a session starts")
+	}
+
+	m.SessionStarted()
+	clock.advance(9 * time.Minute)
+	m.Activity()
+	clock.advance(9 * time.Minute)
+	m.check()
+	if len(*fired) != 0 {
+		t.Fatal("activity should restart the idle timer")
+	}
+
+	clock.advance(time.Minute)
+	m.check()
+	m.check()
+	if len(*fired) != 1 || (*fired)[0] != ReasonIdle {
+		t.Fatalf("fired = %v, want one idle lock", *fired)
+	}
+}
+
+func TestMonitorMaxSession(t *testing.T) {
+	m, clock, fired := newTestMonitor(Policy{IdleMinutes: 10, MaxSessionMinutes: 30})
+
+	m.SessionStarted()
+	for i := 0; i < 6; i++ {
+		clock.advance(5 * time.Minute)
+		m.Activity()
+		m.check()
+	}
+	if len(*fired) != 1 || (*fired)[0] != ReasonMaxSession {
+		t.Fatalf("fired = %v, want the session limit", *fired)
+	}
+}
+
+func TestMonitorTriggers(t *testing.T) {
+	m, _, fired := newTestMonitor(Policy{LockOnSuspend: true})
+
+	m.SessionStarted()
+	m.Trigger(ReasonScreenLock)
+	if len(*fired) != 0 {
+		t.Fatal("screen lock is turned off in the policy")
+	}
+	m.Trigger(ReasonSuspend)
+	m.Trigger(ReasonSuspend)
+	if len(*fired) != 1 || (*fired)[0] != ReasonSuspend {
+		t.Fatalf("fired = %v, want one suspend lock", *fired)
+	}
+}
+
+func TestMonitorFailedUnlocks(t *testing.T) {
+	m, clock, fired := newTestMonitor(Policy{MaxFailedUnlocks: 3, LockoutMinutes: 5})
+
+	for i := 0; i < 3; i++ {
+		if err := m.UnlockAllowed(); err != nil {
+			t.Fatalf("attempt %d refused: %v", i+1, err)
+		}
+		m.UnlockFailed()
+	}
+	if len(*fired) != 1 || (*fired)[0] != ReasonFailedUnlocks {
+		t.Fatalf("fired = %v, want a lock after three failures", *fired)
+	}
+	if err := m.UnlockAllowed(); !errors.Is(err, ErrLockedOut) {
+		t.Fatalf("UnlockAllowed during lockout = %v", err)
+	}
+
+	clock.advance(5 * time.Minute)
+	if err := m.UnlockAllowed(); err != nil {
+		t.Fatalf("lockout should have ended: %v", err)
+	}
+
+	m.UnlockFailed()
+	m.SessionStarted()
+	m.UnlockFailed()
+	m.UnlockFailed()
+	if len(*fired) != 1 {
+		t.Fatal("a successful unlock should reset the failure count")
+	}
+}
+
+func TestPolicySaveAndLoad(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	p, err := LoadPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p != *withPath(DefaultPolicy(), p.filePath) {
+		t.Fatalf("unsaved policy = %+v, want defaults", p)
+	}
+
+	p.IdleMinutes, p.LockOnScreenLock = 0, false
+	if err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.IdleTimeout() != 0 || loaded.LockOnScreenLock || loaded.Lockout() != 5*time.Minute {
+		t.Errorf("loaded = %+v", loaded)
+	}
+}
+
+func withPath(p *Policy, path string) *Policy {
+	p.filePath = path
+	return p
+}
@@ -0,0 +1,109 @@
+package lockpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	securestorage "passquantum/internal/storage"
+)
+
+const policyFileName = "lock_policy.json"
+
+// Policy is the per-installation auto-lock configuration. Zero values turn
+// the corresponding trigger off.
+type Policy struct {
+	// IdleMinutes locks the app after this long without keyboard or window
+	// activity.
+	IdleMinutes int `json:"idle_minutes"`
+	// MaxSessionMinutes locks the app this long after an unlock, whatever
+	// the user is doing.
+	MaxSessionMinutes int `json:"max_session_minutes"`
+	// LockOnScreenLock locks the app when the desktop session locks.
+	LockOnScreenLock bool `json:"lock_on_screen_lock"`
+	// LockOnSuspend locks the app before the machine suspends or hibernates.
+	LockOnSuspend bool `json:"lock_on_suspend"`
+	// MaxFailedUnlocks locks the app and refuses further unlock attempts for
+	// LockoutMinutes after this many wrong master passwords in a row.
+	MaxFailedUnlocks int `json:"max_failed_unlocks"`
+	LockoutMinutes   int `json:"lockout_minutes"`
+
+	filePath string
+}
+
+// DefaultPolicy is used until the user saves their own.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		IdleMinutes:      15,
+		LockOnScreenLock: true,
+		LockOnSuspend:    true,
+		MaxFailedUnlocks: 5,
+		LockoutMinutes:   5,
+	}
+}
+
+func PolicyPath() (string, error) {
+	return securestorage.GetSecureFilePath(policyFileName)
+}
+
+// LoadPolicy reads the saved policy, or returns DefaultPolicy when none has
+// been saved yet.
+func LoadPolicy() (*Policy, error) {
+	path, err := PolicyPath()
+	if err != nil {
+		return nil, fmt.Errorf("policy path: %w", err)
+	}
+
+	p := DefaultPolicy()
+	p.filePath = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	return p, nil
+}
+
+func (p *Policy) Save() error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal policy: %w", err)
+	}
+
+	path := p.filePath
+	if path == "" {
+		if path, err = PolicyPath(); err != nil {
+			return err
+		}
+	}
+	if err := securestorage.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("write policy: %w", err)
+	}
+	return nil
+}
+
+func (p *Policy) IdleTimeout() time.Duration {
+	return minutes(p.IdleMinutes)
+}
+
+func (p *Policy) MaxSession() time.Duration {
+	return minutes(p.MaxSessionMinutes)
+}
+
+func (p *Policy) Lockout() time.Duration {
+	return minutes(p.LockoutMinutes)
+}
+
+func minutes(n int) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Minute
+}
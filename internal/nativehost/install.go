@@ -0,0 +1,169 @@
+package nativehost
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	// ChromiumExtensionID is the extension's ID in Chromium browsers,
+	// fixed by the public key in its manifest.
+	ChromiumExtensionID = "ojfefdgpmlkcohoibnklgjnlilbhmalm"
+	// FirefoxExtensionID is the gecko ID from the extension's manifest.
+	FirefoxExtensionID = "passquantum@esh2007"
+
+	manifestFileName = HostName + ".json"
+	hostDescription  = "PassQuantum browser integration"
+)
+
+// Manifest is a native messaging host manifest. Chromium browsers read
+// AllowedOrigins, Firefox reads AllowedExtensions.
+type Manifest struct {
+	Name              string   `json:"name"`
+	Description       string   `json:"description"`
+	Path              string   `json:"path"`
+	Type              string   `json:"type"`
+	AllowedOrigins    []string `json:"allowed_origins,omitempty"`
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"`
+}
+
+// browserFamily says which manifest flavour a browser reads.
+type browserFamily int
+
+const (
+	chromiumFamily browserFamily = iota
+	firefoxFamily
+)
+
+// browserDir is where one browser looks for per-user host manifests. The
+// manifest is installed only if Root exists, i.e. the browser has been run
+// by this user; every profile of that browser then finds it. On Windows
+// Root and Hosts are registry keys under HKEY_CURRENT_USER.
+type browserDir struct {
+	Name   string
+	Family browserFamily
+	Root   string
+	Hosts  string
+}
+
+// browserDirs lists the per-user manifest locations this platform's
+// browsers read.
+func browserDirs() ([]browserDir, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	chromium := func(name, root string) browserDir {
+		return browserDir{Name: name, Family: chromiumFamily, Root: root, Hosts: filepath.Join(root, "NativeMessagingHosts")}
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		config := os.Getenv("XDG_CONFIG_HOME")
+		if config == "" {
+			config = filepath.Join(home, ".config")
+		}
+		mozilla := filepath.Join(home, ".mozilla")
+		return []browserDir{
+			chromium("Google Chrome", filepath.Join(config, "google-chrome")),
+			chromium("Google Chrome Beta", filepath.Join(config, "google-chrome-beta")),
+			chromium("Chromium", filepath.Join(config, "chromium")),
+			chromium("Brave", filepath.Join(config, "BraveSoftware", "Brave-Browser")),
+			chromium("Microsoft Edge", filepath.Join(config, "microsoft-edge")),
+			chromium("Vivaldi", filepath.Join(config, "vivaldi")),
+			{Name: "Firefox", Family: firefoxFamily, Root: mozilla, Hosts: filepath.Join(mozilla, "native-messaging-hosts")},
+		}, nil
+	case "darwin":
+		support := filepath.Join(home, "Library", "Application Support")
+		mozilla := filepath.Join(support, "Mozilla")
+		return []browserDir{
+			chromium("Google Chrome", filepath.Join(support, "Google", "Chrome")),
+			chromium("Chromium", filepath.Join(support, "Chromium")),
+			chromium("Brave", filepath.Join(support, "BraveSoftware", "Brave-Browser")),
+			chromium("Microsoft Edge", filepath.Join(support, "Microsoft Edge")),
+			chromium("Vivaldi", filepath.Join(support, "Vivaldi")),
+			{Name: "Firefox", Family: firefoxFamily, Root: mozilla, Hosts: filepath.Join(mozilla, "NativeMessagingHosts")},
+		}, nil
+	case "windows":
+		return []browserDir{
+			chromium("Google Chrome", `Software\Google\Chrome`),
+			chromium("Chromium", `Software\Chromium`),
+			chromium("Brave", `Software\BraveSoftware\Brave-Browser`),
+			chromium("Microsoft Edge", `Software\Microsoft\Edge`),
+			chromium("Vivaldi", `Software\Vivaldi`),
+			{Name: "Firefox", Family: firefoxFamily, Root: `Software\Mozilla`, Hosts: `Software\Mozilla\NativeMessagingHosts`},
+		}, nil
+	}
+	return nil, fmt.Errorf("installing the native messaging host is not supported on %s", runtime.GOOS)
+}
+
+// Install writes a host manifest pointing at hostPath for every browser
+// this user has run, and returns the paths written (on Windows, the
+// registry keys set). extraChromiumIDs
+// allows further Chromium extension IDs, for builds signed with another
+// key.
+func Install(hostPath string, extraChromiumIDs []string) ([]string, error) {
+	if !filepath.IsAbs(hostPath) {
+		return nil, fmt.Errorf("host path %q is not absolute", hostPath)
+	}
+	dirs, err := browserDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	origins := []string{"chrome-extension://" + ChromiumExtensionID + "/"}
+	for _, id := range extraChromiumIDs {
+		origins = append(origins, "chrome-extension://"+id+"/")
+	}
+
+	var written []string
+	for _, d := range dirs {
+		if !browserInstalled(d) {
+			continue
+		}
+		m := Manifest{
+			Name:        HostName,
+			Description: hostDescription,
+			Path:        hostPath,
+			Type:        "stdio",
+		}
+		if d.Family == firefoxFamily {
+			m.AllowedExtensions = []string{FirefoxExtensionID}
+		} else {
+			m.AllowedOrigins = origins
+		}
+		data, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return written, err
+		}
+		path, err := writeManifest(d, append(data, '\n'))
+		if err != nil {
+			return written, fmt.Errorf("%s: %w", d.Name, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// Uninstall removes the host manifests Install wrote and returns their
+// paths (on Windows, the registry keys).
+func Uninstall() ([]string, error) {
+	dirs, err := browserDirs()
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, d := range dirs {
+		path, err := removeManifest(d)
+		if err != nil {
+			return removed, fmt.Errorf("%s: %w", d.Name, err)
+		}
+		if path != "" {
+			removed = append(removed, path)
+		}
+	}
+	return removed, nil
+}
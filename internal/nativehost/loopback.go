@@ -0,0 +1,83 @@
+package nativehost
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	securestorage "passquantum/internal/storage"
+)
+
+// Where sockets carry no peer credentials (Windows), the app listens on a
+// loopback TCP port instead. Every run writes the port and a fresh token to
+// an endpoint file in the vault directory, which only this user can read;
+// the host sends the token as its first message and the app hangs up
+// unless it matches.
+
+const (
+	tokenSize     = 32
+	verifyTimeout = 5 * time.Second
+)
+
+func listenLoopback(path string) (*Listener, error) {
+	token := make([]byte, tokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	endpoint := ln.Addr().String() + " " + hex.EncodeToString(token) + "\n"
+	if err := securestorage.WriteFileAtomic(path, []byte(endpoint), 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("write endpoint: %w", err)
+	}
+	return &Listener{Listener: ln, path: path, token: token}, nil
+}
+
+func (l *Listener) verifyToken(conn net.Conn) error {
+	if err := conn.SetReadDeadline(time.Now().Add(verifyTimeout)); err != nil {
+		return err
+	}
+	got, err := ReadMessage(conn)
+	if err != nil {
+		return fmt.Errorf("read token: %w", err)
+	}
+	if subtle.ConstantTimeCompare(got, l.token) != 1 {
+		return errors.New("wrong token")
+	}
+	return conn.SetReadDeadline(time.Time{})
+}
+
+func dialLoopback(path string) (net.Conn, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	addr, encoded, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	token, err := hex.DecodeString(encoded)
+	if err != nil || len(token) != tokenSize {
+		return nil, fmt.Errorf("malformed endpoint file %s", path)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+		return nil, fmt.Errorf("endpoint %q is not a loopback address", addr)
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteMessage(conn, token); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
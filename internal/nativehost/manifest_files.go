@@ -0,0 +1,42 @@
+//go:build !windows
+
+package nativehost
+
+import (
+	"os"
+	"path/filepath"
+
+	securestorage "passquantum/internal/storage"
+)
+
+// browserInstalled reports whether the browser's config directory exists.
+func browserInstalled(d browserDir) bool {
+	_, err := os.Stat(d.Root)
+	return err == nil
+}
+
+// writeManifest writes the manifest into the browser's host directory and
+// returns its path.
+func writeManifest(d browserDir, data []byte) (string, error) {
+	if err := os.MkdirAll(d.Hosts, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(d.Hosts, manifestFileName)
+	if err := securestorage.WriteFileAtomic(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// removeManifest deletes the browser's manifest and returns its path, or
+// "" if there was none.
+func removeManifest(d browserDir) (string, error) {
+	path := filepath.Join(d.Hosts, manifestFileName)
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return path, nil
+}
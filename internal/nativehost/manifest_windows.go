@@ -0,0 +1,92 @@
+//go:build windows
+
+package nativehost
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows/registry"
+
+	securestorage "passquantum/internal/storage"
+)
+
+// Browsers on Windows find a host through a registry key named after it,
+// whose default value is the manifest's path. The manifests themselves
+// live under the user's AppData, one per browser family since Chromium
+// and Firefox read different fields.
+
+// manifestPath is where the manifest for d's family is kept.
+func manifestPath(d browserDir) (string, error) {
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	family := "chromium"
+	if d.Family == firefoxFamily {
+		family = "firefox"
+	}
+	return filepath.Join(config, "PassQuantum", "NativeMessagingHosts", family, manifestFileName), nil
+}
+
+// hostKey is the registry key, under HKEY_CURRENT_USER, that points d at
+// the manifest.
+func hostKey(d browserDir) string {
+	return d.Hosts + `\` + HostName
+}
+
+// browserInstalled reports whether the browser's registry key exists.
+func browserInstalled(d browserDir) bool {
+	k, err := registry.OpenKey(registry.CURRENT_USER, d.Root, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	k.Close()
+	return true
+}
+
+// writeManifest writes the family's manifest and registers it for d. It
+// returns the registry key it set.
+func writeManifest(d browserDir, data []byte) (string, error) {
+	path, err := manifestPath(d)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := securestorage.WriteFileAtomic(path, data, 0644); err != nil {
+		return "", err
+	}
+	k, _, err := registry.CreateKey(registry.CURRENT_USER, hostKey(d), registry.SET_VALUE)
+	if err != nil {
+		return "", err
+	}
+	defer k.Close()
+	if err := k.SetStringValue("", path); err != nil {
+		return "", err
+	}
+	return `HKCU\` + hostKey(d), nil
+}
+
+// removeManifest deletes d's registry key and the family's manifest, and
+// returns the key, or "" if it was not registered.
+func removeManifest(d browserDir) (string, error) {
+	path, err := manifestPath(d)
+	if err != nil {
+		return "", err
+	}
+	// Other browsers of the family may share the manifest; it is removed
+	// with the first key and the rest find it gone.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err := registry.DeleteKey(registry.CURRENT_USER, hostKey(d)); err != nil {
+		if errors.Is(err, registry.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return `HKCU\` + hostKey(d), nil
+}
@@ -0,0 +1,84 @@
+// Package nativehost carries the browser extension's traffic between the
+// browser's native messaging and the desktop app. Browsers start
+// passquantum-native-host and talk to it over stdin/stdout; the host relays
+// each message unchanged to the app's Unix socket (a loopback port on
+// Windows) and the reply back. The
+// same framing is used on both legs: a 4-byte length in native byte order
+// followed by that many bytes of JSON.
+package nativehost
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	securestorage "passquantum/internal/storage"
+)
+
+const (
+	// HostName is the name the extension connects to and the host
+	// manifests are installed under.
+	HostName = "com.passquantum.host"
+
+	// MaxMessageSize bounds a message in either direction. Browsers refuse
+	// anything larger than 1 MiB from a host; requests are far smaller.
+	MaxMessageSize = 1 << 20
+)
+
+var ErrMessageTooLarge = errors.New("native message too large")
+
+// Request is one message from the extension. ID is chosen by the extension
+// and echoed in the Response, so replies may arrive out of order.
+type Request struct {
+	ID     uint64          `json:"id"`
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response answers the Request with the same ID.
+type Response struct {
+	ID     uint64          `json:"id"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// DefaultSocketPath is the app's socket inside the 0700 vault directory, or
+// on platforms without peer credentials its endpoint file.
+func DefaultSocketPath() (string, error) {
+	return securestorage.GetSecureFilePath(socketFileName)
+}
+
+// ReadMessage reads one framed message. It returns io.EOF when r ends
+// between messages.
+func ReadMessage(r io.Reader) ([]byte, error) {
+	var size uint32
+	if err := binary.Read(r, binary.NativeEndian, &size); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated message length: %w", err)
+		}
+		return nil, err
+	}
+	if size > MaxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("truncated message: %w", err)
+	}
+	return msg, nil
+}
+
+// WriteMessage writes msg as one framed message.
+func WriteMessage(w io.Writer, msg []byte) error {
+	if len(msg) > MaxMessageSize {
+		return ErrMessageTooLarge
+	}
+	frame := make([]byte, 4+len(msg))
+	binary.NativeEndian.PutUint32(frame, uint32(len(msg)))
+	copy(frame[4:], msg)
+	_, err := w.Write(frame)
+	return err
+}
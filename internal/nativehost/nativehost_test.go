@@ -0,0 +1,240 @@
+package nativehost
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for _, msg := range []string{`{"id":1}`, `{}`} {
+		if err := WriteMessage(&buf, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{`{"id":1}`, `{}`} {
+		got, err := ReadMessage(&buf)
+		if err != nil || string(got) != want {
+			t.Fatalf("ReadMessage = %q, %v; want %q", got, err, want)
+		}
+	}
+	if _, err := ReadMessage(&buf); err != io.EOF {
+		t.Fatalf("after the last message: %v", err)
+	}
+}
+
+func TestMessageLimits(t *testing.T) {
+	if err := WriteMessage(io.Discard, make([]byte, MaxMessageSize+1)); err != ErrMessageTooLarge {
+		t.Fatalf("writing an oversize message: %v", err)
+	}
+
+	var frame [4]byte
+	binary.NativeEndian.PutUint32(frame[:], MaxMessageSize+1)
+	if _, err := ReadMessage(bytes.NewReader(frame[:])); err != ErrMessageTooLarge {
+		t.Fatalf("reading an oversize length: %v", err)
+	}
+
+	binary.NativeEndian.PutUint32(frame[:], 10)
+	if _, err := ReadMessage(bytes.NewReader(append(frame[:], "short"...))); err == nil || err == io.EOF {
+		t.Fatalf("reading a truncated message: %v", err)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	hostEnd, appEnd := net.Pipe()
+	defer appEnd.Close()
+
+	var browserIn, browserOut bytes.Buffer
+	WriteMessage(&browserIn, []byte(`{"id":1,"method":"GET","path":"/vault/status"}`))
+
+	// A fake app answers one request and hangs up.
+	go func() {
+		msg, err := ReadMessage(appEnd)
+		if err != nil {
+			return
+		}
+		var req Request
+		json.Unmarshal(msg, &req)
+		reply, _ := json.Marshal(Response{ID: req.ID, Status: http.StatusOK, Body: json.RawMessage(`{}`)})
+		WriteMessage(appEnd, reply)
+		appEnd.Close()
+	}()
+
+	// browserIn runs dry first; Relay waits for the reply regardless,
+	// since the browser may still be reading.
+	hostIn := io.MultiReader(&browserIn, blockingReader{})
+	if err := Relay(hostIn, &browserOut, hostEnd); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := ReadMessage(&browserOut)
+	if err != nil || string(msg) != `{"id":1,"status":200,"body":{}}` {
+		t.Fatalf("relayed reply = %q, %v", msg, err)
+	}
+}
+
+// blockingReader stands in for a browser that keeps stdin open.
+type blockingReader struct{}
+
+func (blockingReader) Read([]byte) (int, error) { select {} }
+
+func TestUnavailable(t *testing.T) {
+	var in, out bytes.Buffer
+	WriteMessage(&in, []byte(`{"id":42,"method":"GET","path":"/vault/status"}`))
+	if err := Unavailable(&in, &out, "app is not running"); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := ReadMessage(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	json.Unmarshal(msg, &resp)
+	if resp.ID != 42 || resp.Status != http.StatusServiceUnavailable || string(resp.Body) != `{"error":"app is not running"}` {
+		t.Fatalf("reply = %s", msg)
+	}
+}
+
+func TestDialChecksOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	ln, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	verified := make(chan error, 1)
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			verified <- ln.Verify(c)
+			c.Close()
+		}
+	}()
+
+	conn, err := Dial(path)
+	if err != nil {
+		t.Fatalf("dialing our own socket: %v", err)
+	}
+	conn.Close()
+	if err := <-verified; err != nil {
+		t.Fatalf("verifying our own connection: %v", err)
+	}
+
+	if _, err := Dial(filepath.Join(t.TempDir(), "missing.sock")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("dialing a missing socket: %v", err)
+	}
+}
+
+// The loopback transport is what Windows uses; it is exercised directly so
+// it is covered everywhere.
+func TestLoopbackToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "browser.endpoint")
+	ln, err := listenLoopback(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified := make(chan error, 2)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			verified <- ln.verifyToken(c)
+			c.Close()
+		}
+	}()
+
+	conn, err := dialLoopback(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if err := <-verified; err != nil {
+		t.Fatalf("verifying the endpoint's token: %v", err)
+	}
+
+	// A client that knows the port but not the token is refused.
+	conn, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	WriteMessage(conn, make([]byte, tokenSize))
+	conn.Close()
+	if err := <-verified; err == nil {
+		t.Fatal("accepted a wrong token")
+	}
+
+	ln.Close()
+	if _, err := dialLoopback(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("endpoint file left after Close: %v", err)
+	}
+}
+
+func TestInstallUninstall(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("manifest locations are checked on Linux")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	for _, dir := range []string{".config/chromium", ".mozilla"} {
+		if err := os.MkdirAll(filepath.Join(home, dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	written, err := Install("/opt/passquantum/passquantum-native-host", []string{"abcdefghijklmnopabcdefghijklmnop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chromiumPath := filepath.Join(home, ".config/chromium/NativeMessagingHosts", manifestFileName)
+	firefoxPath := filepath.Join(home, ".mozilla/native-messaging-hosts", manifestFileName)
+	if len(written) != 2 || written[0] != chromiumPath || written[1] != firefoxPath {
+		t.Fatalf("written = %v", written)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config/google-chrome")); !os.IsNotExist(err) {
+		t.Fatal("installed for a browser that was never run")
+	}
+
+	var chromium, firefox Manifest
+	readManifest(t, chromiumPath, &chromium)
+	readManifest(t, firefoxPath, &firefox)
+	if chromium.Name != HostName || chromium.Type != "stdio" || len(chromium.AllowedOrigins) != 2 ||
+		chromium.AllowedOrigins[0] != "chrome-extension://"+ChromiumExtensionID+"/" || chromium.AllowedExtensions != nil {
+		t.Fatalf("Chromium manifest = %+v", chromium)
+	}
+	if len(firefox.AllowedExtensions) != 1 || firefox.AllowedExtensions[0] != FirefoxExtensionID || firefox.AllowedOrigins != nil {
+		t.Fatalf("Firefox manifest = %+v", firefox)
+	}
+
+	removed, err := Uninstall()
+	if err != nil || len(removed) != 2 {
+		t.Fatalf("Uninstall = %v, %v", removed, err)
+	}
+	if _, err := os.Stat(chromiumPath); !os.IsNotExist(err) {
+		t.Fatal("Chromium manifest left behind")
+	}
+
+	if _, err := Install("relative/host", nil); err == nil {
+		t.Fatal("installed a relative host path")
+	}
+}
+
+func readManifest(t *testing.T, path string, m *Manifest) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,74 @@
+package nativehost
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const dialTimeout = 5 * time.Second
+
+// Relay copies messages from the browser to the app and replies from the
+// app to the browser until either side closes. Every message is checked
+// against the framing and size limit on the way through.
+func Relay(browserIn io.Reader, browserOut io.Writer, app io.ReadWriteCloser) error {
+	done := make(chan error, 2)
+	go func() {
+		done <- copyMessages(app, browserIn)
+	}()
+	go func() {
+		done <- copyMessages(browserOut, app)
+	}()
+	err := <-done
+	app.Close()
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func copyMessages(dst io.Writer, src io.Reader) error {
+	for {
+		msg, err := ReadMessage(src)
+		if err != nil {
+			return err
+		}
+		if err := WriteMessage(dst, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Unavailable answers every request from the browser with 503 and reason,
+// for when the app is not running. It returns when the browser closes the
+// connection.
+func Unavailable(browserIn io.Reader, browserOut io.Writer, reason string) error {
+	body, err := json.Marshal(map[string]string{"error": reason})
+	if err != nil {
+		return err
+	}
+	for {
+		msg, err := ReadMessage(browserIn)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req Request
+		if err := json.Unmarshal(msg, &req); err != nil {
+			return fmt.Errorf("parse request: %w", err)
+		}
+		reply, err := json.Marshal(Response{ID: req.ID, Status: http.StatusServiceUnavailable, Body: body})
+		if err != nil {
+			return err
+		}
+		if err := WriteMessage(browserOut, reply); err != nil {
+			return err
+		}
+	}
+}
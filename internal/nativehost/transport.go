@@ -0,0 +1,23 @@
+package nativehost
+
+import (
+	"net"
+	"os"
+)
+
+// Listener accepts connections from the host. On Linux and macOS it is a
+// Unix socket and Verify checks the peer's user ID; elsewhere it is a
+// loopback TCP port (see loopback.go) and Verify checks the token the host
+// must send first.
+type Listener struct {
+	net.Listener
+	path  string
+	token []byte
+}
+
+// Close stops listening and removes the socket or endpoint file.
+func (l *Listener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
@@ -0,0 +1,23 @@
+//go:build !linux && !darwin
+
+package nativehost
+
+import "net"
+
+const socketFileName = "browser.endpoint"
+
+// Listen opens a loopback port and writes its endpoint file to path.
+func Listen(path string) (*Listener, error) {
+	return listenLoopback(path)
+}
+
+// Verify checks that conn presents this run's token.
+func (l *Listener) Verify(conn net.Conn) error {
+	return l.verifyToken(conn)
+}
+
+// Dial connects to the port named in the endpoint file at path and
+// presents its token.
+func Dial(path string) (net.Conn, error) {
+	return dialLoopback(path)
+}
@@ -0,0 +1,71 @@
+//go:build linux || darwin
+
+package nativehost
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+const socketFileName = "browser.sock"
+
+// Listen creates the app's socket at path with mode 0600, replacing one
+// left behind by a crashed run.
+func Listen(path string) (*Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("restrict socket permissions: %w", err)
+	}
+	return &Listener{Listener: ln, path: path}, nil
+}
+
+// Verify checks that conn comes from a process of this user.
+func (l *Listener) Verify(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("not a Unix socket connection")
+	}
+	uid, err := PeerUID(uc)
+	if err != nil {
+		return err
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("peer runs as uid %d", uid)
+	}
+	return nil
+}
+
+// Dial connects to the app's socket and checks that the app runs as the
+// same user as the host.
+func Dial(path string) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	uc := conn.(*net.UnixConn)
+	uid, err := PeerUID(uc)
+	if err != nil {
+		uc.Close()
+		return nil, fmt.Errorf("check app credentials: %w", err)
+	}
+	if uid != os.Getuid() {
+		uc.Close()
+		return nil, fmt.Errorf("socket %s belongs to uid %d", path, uid)
+	}
+	return uc, nil
+}
@@ -0,0 +1,52 @@
+package secretservice
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrLocked is returned by Backend methods that need an unlocked app.
+var ErrLocked = errors.New("PassQuantum is locked")
+
+// ErrNoSuchItem is returned when an item ID does not exist in a collection.
+var ErrNoSuchItem = errors.New("no such item")
+
+// Item is a stored secret as the service exposes it.
+type Item struct {
+	ID         uint64
+	Label      string
+	Attributes map[string]string
+	Created    time.Time
+	Modified   time.Time
+}
+
+// Backend is the vault store behind the service. A collection is a vault,
+// named as on disk. All methods are safe for concurrent use; everything but
+// Collections fails with ErrLocked while the app is locked.
+type Backend interface {
+	Locked() bool
+	// DefaultCollection is the vault open in the app, or "" when locked.
+	DefaultCollection() string
+	Collections() []string
+	Items(collection string) ([]Item, error)
+	Secret(collection string, id uint64) ([]byte, error)
+	// CreateItem stores a new secret. With replace set, an existing item
+	// with exactly the same attributes is overwritten instead.
+	CreateItem(collection, label string, attrs map[string]string, secret []byte, replace bool) (uint64, error)
+	SetSecret(collection string, id uint64, secret []byte) error
+	// UpdateItem changes the label and/or the attributes; nil leaves that
+	// part as it is.
+	UpdateItem(collection string, id uint64, label *string, attrs map[string]string) error
+	// DeleteItem moves the item to the vault's trash.
+	DeleteItem(collection string, id uint64) error
+}
+
+// matchAttributes reports whether attrs contains every pair in query.
+func matchAttributes(attrs, query map[string]string) bool {
+	for k, v := range query {
+		if got, ok := attrs[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,252 @@
+package secretservice
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	propItemLabel      = "org.freedesktop.Secret.Item.Label"
+	propItemAttributes = "org.freedesktop.Secret.Item.Attributes"
+	contentType        = "text/plain"
+)
+
+// Each D-Bus interface gets its own handler type so methods with the same
+// name (SearchItems, Delete) do not collide. Handlers other than the
+// service's are exported on the whole subtree and read the object path from
+// the message.
+
+type serviceHandler struct{ s *Service }
+
+func (h *serviceHandler) OpenSession(sender dbus.Sender, algorithm string, input dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	switch algorithm {
+	case algorithmPlain:
+		path := h.s.addSession(&session{owner: string(sender)})
+		return dbus.MakeVariant(""), path, nil
+	case algorithmDH:
+		peer, ok := input.Value().([]byte)
+		if !ok {
+			return dbus.Variant{}, noPrompt, dbusError("org.freedesktop.DBus.Error.InvalidArgs", "expected a byte array")
+		}
+		sess, pub, err := newDHSession(string(sender), peer)
+		if err != nil {
+			return dbus.Variant{}, noPrompt, dbusError("org.freedesktop.DBus.Error.InvalidArgs", err.Error())
+		}
+		return dbus.MakeVariant(pub), h.s.addSession(sess), nil
+	}
+	return dbus.Variant{}, noPrompt, notSupported("algorithm " + algorithm)
+}
+
+// CreateCollection only hands back the default collection when asked for
+// it by alias; vaults are created in the app.
+func (h *serviceHandler) CreateCollection(props map[string]dbus.Variant, alias string) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	if alias == "default" {
+		if name := h.s.backend.DefaultCollection(); name != "" {
+			return collectionPath(name), noPrompt, nil
+		}
+	}
+	return noPrompt, noPrompt, notSupported("creating collections")
+}
+
+func (h *serviceHandler) SearchItems(attrs map[string]string) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	unlocked := []dbus.ObjectPath{}
+	if h.s.backend.Locked() {
+		// Item lists live inside the encrypted vaults, so nothing can be
+		// listed, even as locked, until the app is unlocked.
+		return unlocked, []dbus.ObjectPath{}, nil
+	}
+	for _, collection := range h.s.backend.Collections() {
+		found, err := h.s.searchCollection(collection, attrs)
+		if err != nil {
+			return nil, nil, backendError(err)
+		}
+		unlocked = append(unlocked, found...)
+	}
+	return unlocked, []dbus.ObjectPath{}, nil
+}
+
+// Unlock cannot prompt for the master password; unlocking happens in the
+// app, after which every object is unlocked.
+func (h *serviceHandler) Unlock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	if h.s.backend.Locked() {
+		return []dbus.ObjectPath{}, noPrompt, nil
+	}
+	return objects, noPrompt, nil
+}
+
+// Lock is left to the app, which locks every collection at once.
+func (h *serviceHandler) Lock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	return []dbus.ObjectPath{}, noPrompt, nil
+}
+
+func (h *serviceHandler) GetSecrets(sender dbus.Sender, items []dbus.ObjectPath, sessionPath dbus.ObjectPath) (map[dbus.ObjectPath]Secret, *dbus.Error) {
+	sess, derr := h.s.session(sessionPath, sender)
+	if derr != nil {
+		return nil, derr
+	}
+	out := make(map[dbus.ObjectPath]Secret, len(items))
+	for _, path := range items {
+		collection, id, ok := h.s.resolveItem(path)
+		if !ok {
+			continue
+		}
+		secret, derr := h.s.encodeSecret(sess, sessionPath, collection, id)
+		if derr != nil {
+			return nil, derr
+		}
+		out[path] = secret
+	}
+	return out, nil
+}
+
+func (h *serviceHandler) ReadAlias(name string) (dbus.ObjectPath, *dbus.Error) {
+	if name == "default" {
+		if collection := h.s.backend.DefaultCollection(); collection != "" {
+			return collectionPath(collection), nil
+		}
+	}
+	return noPrompt, nil
+}
+
+func (h *serviceHandler) SetAlias(name string, collection dbus.ObjectPath) *dbus.Error {
+	return notSupported("setting aliases")
+}
+
+type collectionHandler struct{ s *Service }
+
+func (h *collectionHandler) Delete(msg dbus.Message) (dbus.ObjectPath, *dbus.Error) {
+	return noPrompt, notSupported("deleting collections")
+}
+
+func (h *collectionHandler) SearchItems(msg dbus.Message, attrs map[string]string) ([]dbus.ObjectPath, *dbus.Error) {
+	collection, ok := h.s.resolveCollection(messagePath(msg))
+	if !ok {
+		return nil, noSuchObject(messagePath(msg))
+	}
+	found, err := h.s.searchCollection(collection, attrs)
+	return found, backendError(err)
+}
+
+func (h *collectionHandler) CreateItem(msg dbus.Message, sender dbus.Sender, props map[string]dbus.Variant, secret Secret, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	path := messagePath(msg)
+	collection, ok := h.s.resolveCollection(path)
+	if !ok {
+		return noPrompt, noPrompt, noSuchObject(path)
+	}
+	value, derr := h.s.decodeSecret(sender, secret)
+	if derr != nil {
+		return noPrompt, noPrompt, derr
+	}
+
+	label, _ := props[propItemLabel].Value().(string)
+	attrs, _ := props[propItemAttributes].Value().(map[string]string)
+	id, err := h.s.backend.CreateItem(collection, label, attrs, value, replace)
+	if err != nil {
+		return noPrompt, noPrompt, backendError(err)
+	}
+
+	item := itemPath(collection, id)
+	h.s.emit(collectionPath(collection), ifaceCollection+".ItemCreated", item)
+	return item, noPrompt, nil
+}
+
+type itemHandler struct{ s *Service }
+
+// Delete moves the entry to the vault's trash rather than destroying it.
+func (h *itemHandler) Delete(msg dbus.Message) (dbus.ObjectPath, *dbus.Error) {
+	path := messagePath(msg)
+	collection, id, ok := h.s.resolveItem(path)
+	if !ok {
+		return noPrompt, noSuchObject(path)
+	}
+	if err := h.s.backend.DeleteItem(collection, id); err != nil {
+		return noPrompt, backendError(err)
+	}
+	h.s.emit(collectionPath(collection), ifaceCollection+".ItemDeleted", itemPath(collection, id))
+	return noPrompt, nil
+}
+
+func (h *itemHandler) GetSecret(msg dbus.Message, sender dbus.Sender, sessionPath dbus.ObjectPath) (Secret, *dbus.Error) {
+	path := messagePath(msg)
+	collection, id, ok := h.s.resolveItem(path)
+	if !ok {
+		return Secret{}, noSuchObject(path)
+	}
+	sess, derr := h.s.session(sessionPath, sender)
+	if derr != nil {
+		return Secret{}, derr
+	}
+	return h.s.encodeSecret(sess, sessionPath, collection, id)
+}
+
+func (h *itemHandler) SetSecret(msg dbus.Message, sender dbus.Sender, secret Secret) *dbus.Error {
+	path := messagePath(msg)
+	collection, id, ok := h.s.resolveItem(path)
+	if !ok {
+		return noSuchObject(path)
+	}
+	value, derr := h.s.decodeSecret(sender, secret)
+	if derr != nil {
+		return derr
+	}
+	if err := h.s.backend.SetSecret(collection, id, value); err != nil {
+		return backendError(err)
+	}
+	h.s.emit(collectionPath(collection), ifaceCollection+".ItemChanged", itemPath(collection, id))
+	return nil
+}
+
+type sessionHandler struct{ s *Service }
+
+func (h *sessionHandler) Close(msg dbus.Message, sender dbus.Sender) *dbus.Error {
+	path := messagePath(msg)
+	if _, derr := h.s.session(path, sender); derr != nil {
+		return derr
+	}
+	h.s.mu.Lock()
+	delete(h.s.sessions, path)
+	h.s.mu.Unlock()
+	return nil
+}
+
+func (s *Service) searchCollection(collection string, attrs map[string]string) ([]dbus.ObjectPath, error) {
+	items, err := s.backend.Items(collection)
+	if err != nil {
+		return nil, err
+	}
+	found := []dbus.ObjectPath{}
+	for _, item := range items {
+		if matchAttributes(item.Attributes, attrs) {
+			found = append(found, itemPath(collection, item.ID))
+		}
+	}
+	return found, nil
+}
+
+func (s *Service) encodeSecret(sess *session, sessionPath dbus.ObjectPath, collection string, id uint64) (Secret, *dbus.Error) {
+	value, err := s.backend.Secret(collection, id)
+	if err != nil {
+		return Secret{}, backendError(err)
+	}
+	params, encoded, err := sess.encode(value)
+	if err != nil {
+		return Secret{}, backendError(err)
+	}
+	return Secret{Session: sessionPath, Parameters: params, Value: encoded, ContentType: contentType}, nil
+}
+
+func (s *Service) decodeSecret(sender dbus.Sender, secret Secret) ([]byte, *dbus.Error) {
+	sess, derr := s.session(secret.Session, sender)
+	if derr != nil {
+		return nil, derr
+	}
+	value, err := sess.decode(secret.Parameters, secret.Value)
+	if err != nil {
+		return nil, dbusError("org.freedesktop.DBus.Error.InvalidArgs", err.Error())
+	}
+	return value, nil
+}
+
+func messagePath(msg dbus.Message) dbus.ObjectPath {
+	path, _ := msg.Headers[dbus.FieldPath].Value().(dbus.ObjectPath)
+	return path
+}
@@ -0,0 +1,151 @@
+package secretservice
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// propertiesHandler implements org.freedesktop.DBus.Properties for every
+// object in the tree. Values are computed on each call, so Locked and the
+// item lists always reflect the app's current state.
+type propertiesHandler struct{ s *Service }
+
+func (h *propertiesHandler) Get(msg dbus.Message, iface, name string) (dbus.Variant, *dbus.Error) {
+	props, derr := h.s.properties(messagePath(msg), iface)
+	if derr != nil {
+		return dbus.Variant{}, derr
+	}
+	v, ok := props[name]
+	if !ok {
+		return dbus.Variant{}, dbusError("org.freedesktop.DBus.Error.UnknownProperty", "unknown property "+name)
+	}
+	return v, nil
+}
+
+func (h *propertiesHandler) GetAll(msg dbus.Message, iface string) (map[string]dbus.Variant, *dbus.Error) {
+	return h.s.properties(messagePath(msg), iface)
+}
+
+// Set accepts the two writable properties: an item's Label and Attributes.
+func (h *propertiesHandler) Set(msg dbus.Message, iface, name string, value dbus.Variant) *dbus.Error {
+	path := messagePath(msg)
+	collection, id, ok := h.s.resolveItem(path)
+	if !ok || iface != ifaceItem {
+		return dbusError("org.freedesktop.DBus.Error.PropertyReadOnly", "property is read-only")
+	}
+
+	var err error
+	switch name {
+	case "Label":
+		label, ok := value.Value().(string)
+		if !ok {
+			return dbusError("org.freedesktop.DBus.Error.InvalidArgs", "Label must be a string")
+		}
+		err = h.s.backend.UpdateItem(collection, id, &label, nil)
+	case "Attributes":
+		attrs, ok := value.Value().(map[string]string)
+		if !ok {
+			return dbusError("org.freedesktop.DBus.Error.InvalidArgs", "Attributes must be a{ss}")
+		}
+		if attrs == nil {
+			attrs = map[string]string{}
+		}
+		err = h.s.backend.UpdateItem(collection, id, nil, attrs)
+	default:
+		return dbusError("org.freedesktop.DBus.Error.PropertyReadOnly", name+" is read-only")
+	}
+	if err != nil {
+		return backendError(err)
+	}
+	h.s.emit(collectionPath(collection), ifaceCollection+".ItemChanged", path)
+	return nil
+}
+
+func (s *Service) properties(path dbus.ObjectPath, iface string) (map[string]dbus.Variant, *dbus.Error) {
+	switch iface {
+	case ifaceService:
+		if path != servicePath {
+			break
+		}
+		var collections []dbus.ObjectPath
+		for _, name := range s.backend.Collections() {
+			collections = append(collections, collectionPath(name))
+		}
+		return map[string]dbus.Variant{"Collections": dbus.MakeVariant(nonNil(collections))}, nil
+
+	case ifaceCollection:
+		collection, ok := s.resolveCollection(path)
+		if !ok {
+			break
+		}
+		var items []dbus.ObjectPath
+		locked := s.backend.Locked()
+		if !locked {
+			list, err := s.backend.Items(collection)
+			if err != nil {
+				return nil, backendError(err)
+			}
+			for _, item := range list {
+				items = append(items, itemPath(collection, item.ID))
+			}
+		}
+		return map[string]dbus.Variant{
+			"Items":    dbus.MakeVariant(nonNil(items)),
+			"Label":    dbus.MakeVariant(collection),
+			"Locked":   dbus.MakeVariant(locked),
+			"Created":  dbus.MakeVariant(uint64(0)),
+			"Modified": dbus.MakeVariant(uint64(0)),
+		}, nil
+
+	case ifaceItem:
+		collection, id, ok := s.resolveItem(path)
+		if !ok {
+			break
+		}
+		item, err := s.item(collection, id)
+		if err != nil {
+			return nil, backendError(err)
+		}
+		attrs := item.Attributes
+		if attrs == nil {
+			attrs = map[string]string{}
+		}
+		return map[string]dbus.Variant{
+			"Locked":     dbus.MakeVariant(false),
+			"Attributes": dbus.MakeVariant(attrs),
+			"Label":      dbus.MakeVariant(item.Label),
+			"Created":    dbus.MakeVariant(unixSeconds(item.Created.Unix(), item.Created.IsZero())),
+			"Modified":   dbus.MakeVariant(unixSeconds(item.Modified.Unix(), item.Modified.IsZero())),
+		}, nil
+
+	case ifaceSession:
+		return map[string]dbus.Variant{}, nil
+	}
+	return nil, noSuchObject(path)
+}
+
+func (s *Service) item(collection string, id uint64) (Item, error) {
+	items, err := s.backend.Items(collection)
+	if err != nil {
+		return Item{}, err
+	}
+	for _, item := range items {
+		if item.ID == id {
+			return item, nil
+		}
+	}
+	return Item{}, ErrNoSuchItem
+}
+
+func nonNil(paths []dbus.ObjectPath) []dbus.ObjectPath {
+	if paths == nil {
+		return []dbus.ObjectPath{}
+	}
+	return paths
+}
+
+func unixSeconds(sec int64, zero bool) uint64 {
+	if zero || sec < 0 {
+		return 0
+	}
+	return uint64(sec)
+}
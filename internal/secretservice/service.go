@@ -0,0 +1,322 @@
+package secretservice
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	busName = "org.freedesktop.secrets"
+
+	servicePath    = dbus.ObjectPath("/org/freedesktop/secrets")
+	collectionRoot = "/org/freedesktop/secrets/collection/"
+	aliasRoot      = "/org/freedesktop/secrets/aliases/"
+	sessionRoot    = "/org/freedesktop/secrets/session/"
+	noPrompt       = dbus.ObjectPath("/")
+
+	ifaceService    = "org.freedesktop.Secret.Service"
+	ifaceCollection = "org.freedesktop.Secret.Collection"
+	ifaceItem       = "org.freedesktop.Secret.Item"
+	ifaceSession    = "org.freedesktop.Secret.Session"
+	ifaceProperties = "org.freedesktop.DBus.Properties"
+)
+
+// Secret is the (oayays) struct secrets travel in.
+type Secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// Service provides org.freedesktop.secrets on a D-Bus session bus. Start
+// and Stop may be called any number of times.
+type Service struct {
+	backend    Backend
+	busAddress string
+
+	mu          sync.Mutex
+	conn        *dbus.Conn
+	sessions    map[dbus.ObjectPath]*session
+	nextSession uint64
+}
+
+// NewService returns a provider for backend. An empty busAddress means the
+// user's session bus.
+func NewService(backend Backend, busAddress string) *Service {
+	return &Service{backend: backend, busAddress: busAddress}
+}
+
+func (s *Service) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn != nil
+}
+
+// Start connects to the bus and claims org.freedesktop.secrets. It fails if
+// another provider, such as gnome-keyring, already owns the name.
+func (s *Service) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		return nil
+	}
+
+	var conn *dbus.Conn
+	var err error
+	if s.busAddress == "" {
+		conn, err = dbus.ConnectSessionBus()
+	} else {
+		conn, err = dbus.Connect(s.busAddress)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to session bus: %w", err)
+	}
+
+	exports := []struct {
+		handler interface{}
+		iface   string
+		subtree bool
+	}{
+		{&serviceHandler{s}, ifaceService, false},
+		{&collectionHandler{s}, ifaceCollection, true},
+		{&itemHandler{s}, ifaceItem, true},
+		{&sessionHandler{s}, ifaceSession, true},
+		{&propertiesHandler{s}, ifaceProperties, true},
+	}
+	for _, e := range exports {
+		if e.subtree {
+			err = conn.ExportSubtree(e.handler, servicePath, e.iface)
+		} else {
+			err = conn.Export(e.handler, servicePath, e.iface)
+		}
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("export %s: %w", e.iface, err)
+		}
+	}
+
+	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("request %s: %w", busName, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return fmt.Errorf("another Secret Service provider already owns %s", busName)
+	}
+
+	// Forget sessions whose client has left the bus.
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+	); err != nil {
+		log.Printf("[SecretService] WARNING: cannot watch clients: %v", err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go s.watchClients(signals)
+
+	s.conn = conn
+	s.sessions = make(map[dbus.ObjectPath]*session)
+	log.Printf("[SecretService] providing %s", busName)
+	return nil
+}
+
+func (s *Service) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	_, _ = s.conn.ReleaseName(busName)
+	err := s.conn.Close()
+	s.conn = nil
+	s.sessions = nil
+	log.Println("[SecretService] stopped")
+	return err
+}
+
+// NotifyLockChanged tells clients that every collection is now locked or
+// unlocked. It does not call the backend, so it may be called while the
+// backend's own locks are held.
+func (s *Service) NotifyLockChanged(locked bool) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return
+	}
+
+	changed := map[string]dbus.Variant{"Locked": dbus.MakeVariant(locked)}
+	for _, name := range s.backend.Collections() {
+		path := collectionPath(name)
+		_ = conn.Emit(path, ifaceProperties+".PropertiesChanged", ifaceCollection, changed, []string{})
+		_ = conn.Emit(servicePath, ifaceService+".CollectionChanged", path)
+	}
+}
+
+func (s *Service) watchClients(signals chan *dbus.Signal) {
+	for sig := range signals {
+		if sig.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(sig.Body) != 3 {
+			continue
+		}
+		name, _ := sig.Body[0].(string)
+		newOwner, _ := sig.Body[2].(string)
+		if newOwner != "" {
+			continue
+		}
+		s.mu.Lock()
+		for path, sess := range s.sessions {
+			if sess.owner == name {
+				delete(s.sessions, path)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *Service) emit(path dbus.ObjectPath, name string, values ...interface{}) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn != nil {
+		_ = conn.Emit(path, name, values...)
+	}
+}
+
+func (s *Service) addSession(sess *session) dbus.ObjectPath {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextSession++
+	path := dbus.ObjectPath(sessionRoot + "s" + strconv.FormatUint(s.nextSession, 10))
+	s.sessions[path] = sess
+	return path
+}
+
+// session returns the caller's session at path.
+func (s *Service) session(path dbus.ObjectPath, sender dbus.Sender) (*session, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[path]
+	if !ok || sess.owner != string(sender) {
+		return nil, dbusError("org.freedesktop.Secret.Error.NoSession", "no such session")
+	}
+	return sess, nil
+}
+
+// collectionPath names a vault on the bus. Path elements only allow
+// [A-Za-z0-9_], so every other byte is written as _XX.
+func collectionPath(name string) dbus.ObjectPath {
+	var b strings.Builder
+	b.WriteString(collectionRoot)
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return dbus.ObjectPath(b.String())
+}
+
+func itemPath(collection string, id uint64) dbus.ObjectPath {
+	return dbus.ObjectPath(fmt.Sprintf("%s/%016x", collectionPath(collection), id))
+}
+
+// resolveCollection maps a collection or alias path to a vault name.
+func (s *Service) resolveCollection(path dbus.ObjectPath) (string, bool) {
+	p := string(path)
+	var name string
+	switch {
+	case strings.HasPrefix(p, aliasRoot):
+		if p[len(aliasRoot):] != "default" {
+			return "", false
+		}
+		name = s.backend.DefaultCollection()
+	case strings.HasPrefix(p, collectionRoot):
+		var ok bool
+		if name, ok = unescapeName(p[len(collectionRoot):]); !ok {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	for _, c := range s.backend.Collections() {
+		if c == name && name != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// resolveItem maps an item path to its vault name and entry ID.
+func (s *Service) resolveItem(path dbus.ObjectPath) (string, uint64, bool) {
+	i := strings.LastIndexByte(string(path), '/')
+	if i < 0 {
+		return "", 0, false
+	}
+	id, err := strconv.ParseUint(string(path)[i+1:], 16, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	collection, ok := s.resolveCollection(path[:i])
+	return collection, id, ok
+}
+
+func unescapeName(escaped string) (string, bool) {
+	if strings.Contains(escaped, "/") {
+		return "", false
+	}
+	var b strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] != '_' {
+			b.WriteByte(escaped[i])
+			continue
+		}
+		if i+3 > len(escaped) {
+			return "", false
+		}
+		v, err := strconv.ParseUint(escaped[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		b.WriteByte(byte(v))
+		i += 2
+	}
+	return b.String(), true
+}
+
+func dbusError(name, message string) *dbus.Error {
+	return dbus.NewError(name, []interface{}{message})
+}
+
+// backendError translates a Backend error for the bus.
+func backendError(err error) *dbus.Error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrLocked):
+		return dbusError("org.freedesktop.Secret.Error.IsLocked", err.Error())
+	case errors.Is(err, ErrNoSuchItem):
+		return dbusError("org.freedesktop.Secret.Error.NoSuchObject", err.Error())
+	}
+	return dbusError("org.freedesktop.DBus.Error.Failed", err.Error())
+}
+
+func noSuchObject(path dbus.ObjectPath) *dbus.Error {
+	return dbusError("org.freedesktop.Secret.Error.NoSuchObject", fmt.Sprintf("no such object %s", path))
+}
+
+func notSupported(what string) *dbus.Error {
+	return dbusError("org.freedesktop.DBus.Error.NotSupported", what+" is not supported by PassQuantum")
+}
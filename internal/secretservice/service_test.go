@@ -0,0 +1,349 @@
+package secretservice
+
+import (
+	"bufio"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"maps"
+	"math/big"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// memBackend is an in-memory Backend with one collection per map key.
+type memBackend struct {
+	mu      sync.Mutex
+	locked  bool
+	items   map[string][]Item
+	secrets map[uint64][]byte
+	nextID  uint64
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{
+		items:   map[string][]Item{"Default": nil, "Work Vault": nil},
+		secrets: map[uint64][]byte{},
+	}
+}
+
+func (m *memBackend) Locked() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.locked
+}
+
+func (m *memBackend) DefaultCollection() string {
+	if m.Locked() {
+		return ""
+	}
+	return "Default"
+}
+
+func (m *memBackend) Collections() []string { return []string{"Default", "Work Vault"} }
+
+func (m *memBackend) Items(collection string) ([]Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locked {
+		return nil, ErrLocked
+	}
+	return append([]Item(nil), m.items[collection]...), nil
+}
+
+func (m *memBackend) Secret(collection string, id uint64) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locked {
+		return nil, ErrLocked
+	}
+	s, ok := m.secrets[id]
+	if !ok {
+		return nil, ErrNoSuchItem
+	}
+	return s, nil
+}
+
+func (m *memBackend) CreateItem(collection, label string, attrs map[string]string, secret []byte, replace bool) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if replace {
+		for _, item := range m.items[collection] {
+			if maps.Equal(item.Attributes, attrs) {
+				m.secrets[item.ID] = secret
+				return item.ID, nil
+			}
+		}
+	}
+	m.nextID++
+	m.items[collection] = append(m.items[collection], Item{ID: m.nextID, Label: label, Attributes: attrs})
+	m.secrets[m.nextID] = secret
+	return m.nextID, nil
+}
+
+func (m *memBackend) SetSecret(collection string, id uint64, secret []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.secrets[id] = secret
+	return nil
+}
+
+func (m *memBackend) UpdateItem(collection string, id uint64, label *string, attrs map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, item := range m.items[collection] {
+		if item.ID != id {
+			continue
+		}
+		if label != nil {
+			m.items[collection][i].Label = *label
+		}
+		if attrs != nil {
+			m.items[collection][i].Attributes = attrs
+		}
+		return nil
+	}
+	return ErrNoSuchItem
+}
+
+func (m *memBackend) DeleteItem(collection string, id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := m.items[collection]
+	for i, item := range items {
+		if item.ID == id {
+			m.items[collection] = append(items[:i], items[i+1:]...)
+			delete(m.secrets, id)
+			return nil
+		}
+	}
+	return ErrNoSuchItem
+}
+
+// privateBus starts a throwaway session bus and returns its address.
+func privateBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address=1",
+		"--address=unix:path="+t.TempDir()+"/bus")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read bus address: %v", err)
+	}
+	return strings.TrimSpace(line)
+}
+
+func startTestService(t *testing.T, backend Backend) (*Service, *dbus.Conn) {
+	t.Helper()
+	addr := privateBus(t)
+	svc := NewService(backend, addr)
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { svc.Stop() })
+
+	client, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return svc, client
+}
+
+func call(t *testing.T, conn *dbus.Conn, path dbus.ObjectPath, method string, args ...interface{}) *dbus.Call {
+	t.Helper()
+	return conn.Object(busName, path).Call(method, 0, args...)
+}
+
+func TestServiceStoreAndLookup(t *testing.T) {
+	backend := newMemBackend()
+	_, client := startTestService(t, backend)
+
+	var output dbus.Variant
+	var sessionPath dbus.ObjectPath
+	if err := call(t, client, servicePath, ifaceService+".OpenSession", "plain", dbus.MakeVariant("")).Store(&output, &sessionPath); err != nil {
+		t.Fatalf("OpenSession: %v", err)
+	}
+
+	// secret-tool and libsecret store into the default alias.
+	props := map[string]dbus.Variant{
+		propItemLabel:      dbus.MakeVariant("IMAP password"),
+		propItemAttributes: dbus.MakeVariant(map[string]string{"server": "imap.example.org", "user": "alice"}),
+	}
+	secret := Secret{Session: sessionPath, Parameters: []byte{}, Value: []byte("hunter2"), ContentType: "text/plain"}
+	var item, prompt dbus.ObjectPath
+	if err := call(t, client, aliasRoot+"default", ifaceCollection+".CreateItem", props, secret, true).Store(&item, &prompt); err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	if prompt != noPrompt || !strings.HasPrefix(string(item), string(collectionPath("Default"))+"/") {
+		t.Fatalf("CreateItem = %s, %s", item, prompt)
+	}
+
+	var unlocked, locked []dbus.ObjectPath
+	if err := call(t, client, servicePath, ifaceService+".SearchItems", map[string]string{"server": "imap.example.org"}).Store(&unlocked, &locked); err != nil {
+		t.Fatalf("SearchItems: %v", err)
+	}
+	if len(unlocked) != 1 || unlocked[0] != item {
+		t.Fatalf("SearchItems = %v", unlocked)
+	}
+
+	var secrets map[dbus.ObjectPath]Secret
+	if err := call(t, client, servicePath, ifaceService+".GetSecrets", unlocked, sessionPath).Store(&secrets); err != nil {
+		t.Fatalf("GetSecrets: %v", err)
+	}
+	if string(secrets[item].Value) != "hunter2" {
+		t.Errorf("secret = %q", secrets[item].Value)
+	}
+
+	label, err := client.Object(busName, item).GetProperty(ifaceItem + ".Label")
+	if err != nil || label.Value() != "IMAP password" {
+		t.Errorf("Label = %v, %v", label, err)
+	}
+	if err := client.Object(busName, item).SetProperty(ifaceItem+".Label", dbus.MakeVariant("Mail")); err != nil {
+		t.Errorf("set Label: %v", err)
+	}
+
+	var alias dbus.ObjectPath
+	if err := call(t, client, servicePath, ifaceService+".ReadAlias", "default").Store(&alias); err != nil || alias != collectionPath("Default") {
+		t.Errorf("ReadAlias = %s, %v", alias, err)
+	}
+
+	if err := call(t, client, item, ifaceItem+".Delete").Store(&prompt); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if len(backend.secrets) != 0 {
+		t.Error("Delete should reach the backend")
+	}
+}
+
+func TestServiceDHSession(t *testing.T) {
+	backend := newMemBackend()
+	id, _ := backend.CreateItem("Work Vault", "token", map[string]string{"service": "ci"}, []byte("s3cr3t"), false)
+	_, client := startTestService(t, backend)
+
+	p := ietf1024Prime
+	priv, err := rand.Int(rand.Reader, new(big.Int).Sub(p, big.NewInt(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv.Add(priv, big.NewInt(2))
+	pub := new(big.Int).Exp(big.NewInt(2), priv, p)
+
+	var output dbus.Variant
+	var sessionPath dbus.ObjectPath
+	if err := call(t, client, servicePath, ifaceService+".OpenSession", algorithmDH, dbus.MakeVariant(pub.Bytes())).Store(&output, &sessionPath); err != nil {
+		t.Fatalf("OpenSession: %v", err)
+	}
+	servicePub, ok := output.Value().([]byte)
+	if !ok {
+		t.Fatalf("output = %v", output)
+	}
+	shared := new(big.Int).Exp(new(big.Int).SetBytes(servicePub), priv, p).FillBytes(make([]byte, 128))
+	key, err := hkdf.Key(sha256.New, shared, nil, "", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientSide := &session{key: key}
+
+	item := itemPath("Work Vault", id)
+	var secret Secret
+	if err := call(t, client, item, ifaceItem+".GetSecret", sessionPath).Store(&secret); err != nil {
+		t.Fatalf("GetSecret: %v", err)
+	}
+	if string(secret.Value) == "s3cr3t" {
+		t.Fatal("secret travelled in the clear over a DH session")
+	}
+	plain, err := clientSide.decode(secret.Parameters, secret.Value)
+	if err != nil || string(plain) != "s3cr3t" {
+		t.Fatalf("decrypted = %q, %v", plain, err)
+	}
+
+	params, value, err := clientSide.encode([]byte("rotated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := call(t, client, item, ifaceItem+".SetSecret", Secret{Session: sessionPath, Parameters: params, Value: value, ContentType: "text/plain"}).Err; err != nil {
+		t.Fatalf("SetSecret: %v", err)
+	}
+	if string(backend.secrets[id]) != "rotated" {
+		t.Errorf("stored secret = %q", backend.secrets[id])
+	}
+}
+
+func TestServiceLocked(t *testing.T) {
+	backend := newMemBackend()
+	id, _ := backend.CreateItem("Default", "x", nil, []byte("x"), false)
+	svc, client := startTestService(t, backend)
+
+	if err := client.AddMatchSignal(dbus.WithMatchInterface(ifaceProperties)); err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 8)
+	client.Signal(signals)
+
+	backend.mu.Lock()
+	backend.locked = true
+	backend.mu.Unlock()
+	svc.NotifyLockChanged(true)
+
+	select {
+	case sig := <-signals:
+		changed, _ := sig.Body[1].(map[string]dbus.Variant)
+		if sig.Path != collectionPath("Default") && sig.Path != collectionPath("Work Vault") || changed["Locked"].Value() != true {
+			t.Errorf("signal = %s %v", sig.Path, sig.Body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no PropertiesChanged signal on lock")
+	}
+
+	locked, err := client.Object(busName, collectionPath("Default")).GetProperty(ifaceCollection + ".Locked")
+	if err != nil || locked.Value() != true {
+		t.Errorf("Locked = %v, %v", locked, err)
+	}
+
+	var output dbus.Variant
+	var sessionPath dbus.ObjectPath
+	if err := call(t, client, servicePath, ifaceService+".OpenSession", "plain", dbus.MakeVariant("")).Store(&output, &sessionPath); err != nil {
+		t.Fatal(err)
+	}
+	var secret Secret
+	err = call(t, client, itemPath("Default", id), ifaceItem+".GetSecret", sessionPath).Store(&secret)
+	if dbusErr, ok := err.(dbus.Error); !ok || dbusErr.Name != "org.freedesktop.Secret.Error.IsLocked" {
+		t.Errorf("GetSecret while locked = %v", err)
+	}
+}
+
+func TestCollectionPathEscaping(t *testing.T) {
+	for _, name := range []string{"Default", "Work Vault", "ünï/code_1"} {
+		path := collectionPath(name)
+		if !path.IsValid() {
+			t.Errorf("%q: invalid path %s", name, path)
+		}
+		got, ok := unescapeName(strings.TrimPrefix(string(path), collectionRoot))
+		if !ok || got != name {
+			t.Errorf("%q round-tripped to %q", name, got)
+		}
+	}
+	if _, ok := unescapeName("bad_z"); ok {
+		t.Error("truncated escape should be rejected")
+	}
+}
@@ -0,0 +1,101 @@
+package secretservice
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	algorithmPlain = "plain"
+	algorithmDH    = "dh-ietf1024-sha256-aes128-cbc-pkcs7"
+)
+
+// ietf1024Prime is the 1024-bit MODP group from RFC 2409 (Oakley group 2),
+// the group the Secret Service specification fixes for its DH algorithm.
+var ietf1024Prime, _ = new(big.Int).SetString(
+	"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74"+
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437"+
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE65381FFFFFFFFFFFFFFFF", 16)
+
+// session carries secrets between the service and one client. A nil key
+// means the plain algorithm: secrets travel unencrypted over the bus.
+type session struct {
+	owner string // unique bus name of the client that opened it
+	key   []byte // AES-128 key for the DH algorithm
+}
+
+// newDHSession runs the service side of the key agreement. peer is the
+// client's public value; the returned bytes are ours.
+func newDHSession(owner string, peer []byte) (*session, []byte, error) {
+	p := ietf1024Prime
+	y := new(big.Int).SetBytes(peer)
+	if y.Cmp(big.NewInt(1)) <= 0 || y.Cmp(new(big.Int).Sub(p, big.NewInt(1))) >= 0 {
+		return nil, nil, fmt.Errorf("invalid DH public value")
+	}
+
+	priv, err := rand.Int(rand.Reader, new(big.Int).Sub(p, big.NewInt(3)))
+	if err != nil {
+		return nil, nil, err
+	}
+	priv.Add(priv, big.NewInt(2))
+	pub := new(big.Int).Exp(big.NewInt(2), priv, p)
+
+	// The shared value is left-padded to the prime's size before HKDF, as
+	// libsecret and gnome-keyring do.
+	shared := new(big.Int).Exp(y, priv, p).FillBytes(make([]byte, (p.BitLen()+7)/8))
+	key, err := hkdf.Key(sha256.New, shared, nil, "", 16)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &session{owner: owner, key: key}, pub.Bytes(), nil
+}
+
+// encode prepares value for the client and returns (parameters, value).
+func (s *session) encode(value []byte) ([]byte, []byte, error) {
+	if s.key == nil {
+		return []byte{}, value, nil
+	}
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, nil, err
+	}
+	pad := aes.BlockSize - len(value)%aes.BlockSize
+	padded := append(append([]byte(nil), value...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, padded)
+	return iv, out, nil
+}
+
+// decode recovers a secret the client sent with encode's counterpart.
+func (s *session) decode(params, value []byte) ([]byte, error) {
+	if s.key == nil {
+		return value, nil
+	}
+	if len(params) != aes.BlockSize || len(value) == 0 || len(value)%aes.BlockSize != 0 {
+		return nil, errors.New("malformed encrypted secret")
+	}
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(value))
+	cipher.NewCBCDecrypter(block, params).CryptBlocks(out, value)
+
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(out[len(out)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, errors.New("malformed encrypted secret")
+	}
+	return out[:len(out)-pad], nil
+}
@@ -0,0 +1,100 @@
+package sshagent
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var errReadOnly = errors.New("keys are managed in PassQuantum; the agent is read-only")
+
+// keyring implements agent.ExtendedAgent on top of a KeySource. Keys live in
+// the vault, so the mutating parts of the protocol are refused.
+type keyring struct {
+	source KeySource
+	// confirm is asked before signing with an identity marked Confirm. A nil
+	// confirm refuses such signatures.
+	confirm func(Identity) bool
+}
+
+func (k *keyring) List() ([]*agent.Key, error) {
+	ids, err := k.source.Identities()
+	if err != nil {
+		// A locked vault simply has no identities.
+		return []*agent.Key{}, nil
+	}
+	keys := make([]*agent.Key, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, &agent.Key{
+			Format:  id.PublicKey.Type(),
+			Blob:    id.PublicKey.Marshal(),
+			Comment: id.Comment,
+		})
+	}
+	return keys, nil
+}
+
+func (k *keyring) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return k.SignWithFlags(key, data, 0)
+}
+
+func (k *keyring) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	id, err := k.identity(key)
+	if err != nil {
+		return nil, err
+	}
+	if id.Confirm && (k.confirm == nil || !k.confirm(id)) {
+		return nil, fmt.Errorf("signature with %q was not confirmed", id.Name)
+	}
+
+	signer, err := k.source.Signer(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var algorithm string
+	switch {
+	case flags&agent.SignatureFlagRsaSha256 != 0:
+		algorithm = ssh.KeyAlgoRSASHA256
+	case flags&agent.SignatureFlagRsaSha512 != 0:
+		algorithm = ssh.KeyAlgoRSASHA512
+	}
+	if algorithm != "" && key.Type() == ssh.KeyAlgoRSA {
+		algSigner, ok := signer.(ssh.AlgorithmSigner)
+		if !ok {
+			return nil, fmt.Errorf("key %q cannot sign with %s", id.Name, algorithm)
+		}
+		return algSigner.SignWithAlgorithm(rand.Reader, data, algorithm)
+	}
+	return signer.Sign(rand.Reader, data)
+}
+
+// identity finds the listed identity for key.
+func (k *keyring) identity(key ssh.PublicKey) (Identity, error) {
+	ids, err := k.source.Identities()
+	if err != nil {
+		return Identity{}, err
+	}
+	want := key.Marshal()
+	for _, id := range ids {
+		if bytes.Equal(id.PublicKey.Marshal(), want) {
+			return id, nil
+		}
+	}
+	return Identity{}, errors.New("key not found")
+}
+
+func (k *keyring) Add(agent.AddedKey) error       { return errReadOnly }
+func (k *keyring) Remove(ssh.PublicKey) error     { return errReadOnly }
+func (k *keyring) RemoveAll() error               { return errReadOnly }
+func (k *keyring) Lock([]byte) error              { return errReadOnly }
+func (k *keyring) Unlock([]byte) error            { return errReadOnly }
+func (k *keyring) Signers() ([]ssh.Signer, error) { return nil, errReadOnly }
+
+func (k *keyring) Extension(string, []byte) ([]byte, error) {
+	return nil, agent.ErrExtensionUnsupported
+}
@@ -0,0 +1,145 @@
+package sshagent
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+type fakeSource struct {
+	signer  ssh.Signer
+	confirm bool
+	locked  bool
+}
+
+func (f *fakeSource) Identities() ([]Identity, error) {
+	if f.locked {
+		return nil, errors.New("locked")
+	}
+	return []Identity{{EntryID: 1, Name: "laptop", PublicKey: f.signer.PublicKey(), Comment: "alice@laptop", Confirm: f.confirm}}, nil
+}
+
+func (f *fakeSource) Signer(pub ssh.PublicKey) (ssh.Signer, error) {
+	if f.locked || !bytes.Equal(pub.Marshal(), f.signer.PublicKey().Marshal()) {
+		return nil, errors.New("key not found")
+	}
+	return f.signer, nil
+}
+
+func startTestServer(t *testing.T, source KeySource) (*Server, agent.ExtendedAgent) {
+	t.Helper()
+	srv := NewServer(source, filepath.Join(t.TempDir(), socketFileName))
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { srv.Stop() })
+	conn, err := net.Dial("unix", srv.SocketPath())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, agent.NewClient(conn)
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestAgentListAndSign(t *testing.T) {
+	source := &fakeSource{signer: newTestSigner(t)}
+	_, client := startTestServer(t, source)
+
+	keys, err := client.List()
+	if err != nil || len(keys) != 1 || keys[0].Comment != "alice@laptop" {
+		t.Fatalf("List = %v, %v", keys, err)
+	}
+
+	data := []byte("session data")
+	sig, err := client.Sign(keys[0], data)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if err := source.signer.PublicKey().Verify(data, sig); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+
+	if err := client.RemoveAll(); err == nil {
+		t.Error("agent should be read-only")
+	}
+
+	source.locked = true
+	if keys, err := client.List(); err != nil || len(keys) != 0 {
+		t.Errorf("locked List = %v, %v", keys, err)
+	}
+}
+
+func TestAgentConfirm(t *testing.T) {
+	source := &fakeSource{signer: newTestSigner(t), confirm: true}
+	srv := NewServer(source, filepath.Join(t.TempDir(), socketFileName))
+	allow := false
+	var asked Identity
+	srv.SetConfirmCallback(func(id Identity) bool {
+		asked = id
+		return allow
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer srv.Stop()
+	conn, err := net.Dial("unix", srv.SocketPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := agent.NewClient(conn)
+
+	pub := source.signer.PublicKey()
+	if _, err := client.Sign(pub, []byte("x")); err == nil {
+		t.Error("denied confirmation should fail the signature")
+	}
+	if asked.Name != "laptop" {
+		t.Errorf("confirm asked about %+v", asked)
+	}
+	allow = true
+	if _, err := client.Sign(pub, []byte("x")); err != nil {
+		t.Errorf("confirmed Sign: %v", err)
+	}
+}
+
+func TestServerStopClosesSocket(t *testing.T) {
+	srv, client := startTestServer(t, &fakeSource{signer: newTestSigner(t)})
+	if err := srv.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if srv.Running() {
+		t.Error("Running after Stop")
+	}
+	if _, err := client.List(); err == nil {
+		t.Error("open connections should be closed by Stop")
+	}
+	if _, err := net.Dial("unix", srv.SocketPath()); err == nil {
+		t.Error("socket should be gone after Stop")
+	}
+
+	// Restart on the same path, as happens on the next unlock.
+	if err := srv.Start(); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	srv.Stop()
+}
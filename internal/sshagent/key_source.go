@@ -0,0 +1,24 @@
+package sshagent
+
+import "golang.org/x/crypto/ssh"
+
+// Identity is one key the agent offers. It never carries private material.
+type Identity struct {
+	EntryID   uint64
+	Name      string
+	PublicKey ssh.PublicKey
+	Comment   string
+	// Confirm asks the user before every signature made with this key.
+	Confirm bool
+}
+
+// KeySource is the abstraction the agent depends on, keeping it decoupled
+// from the app package. Implementations decrypt on demand and must fail
+// while the vault is locked.
+type KeySource interface {
+	// Identities lists the keys currently available for signing.
+	Identities() ([]Identity, error)
+	// Signer decrypts the private key whose public half is pub. The caller
+	// discards the signer after a single use.
+	Signer(pub ssh.PublicKey) (ssh.Signer, error)
+}
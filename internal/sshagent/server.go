@@ -0,0 +1,146 @@
+package sshagent
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh/agent"
+
+	securestorage "passquantum/internal/storage"
+)
+
+const socketFileName = "ssh-agent.sock"
+
+// DefaultSocketPath is the agent socket inside the 0700 vault directory.
+// Point SSH_AUTH_SOCK at it to use the agent.
+func DefaultSocketPath() (string, error) {
+	return securestorage.GetSecureFilePath(socketFileName)
+}
+
+// Server serves the OpenSSH agent protocol on a Unix socket. It is started
+// when a vault is opened and stopped when the app locks; Start and Stop may
+// be called any number of times.
+type Server struct {
+	keyring    *keyring
+	socketPath string
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+func NewServer(source KeySource, socketPath string) *Server {
+	return &Server{
+		keyring:    &keyring{source: source},
+		socketPath: socketPath,
+	}
+}
+
+// SetConfirmCallback installs the prompt used for keys that require
+// confirmation; call it before Start. fn blocks until the user answers and
+// is called from connection goroutines. Without a callback such keys cannot
+// sign.
+func (s *Server) SetConfirmCallback(fn func(Identity) bool) {
+	s.keyring.confirm = fn
+}
+
+func (s *Server) SocketPath() string {
+	return s.socketPath
+}
+
+func (s *Server) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listener != nil
+}
+
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		return nil
+	}
+
+	// A socket left behind by a crashed run would make Listen fail.
+	if info, err := os.Lstat(s.socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and is not a socket", s.socketPath)
+		}
+		if err := os.Remove(s.socketPath); err != nil {
+			return fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", s.socketPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(s.socketPath, 0600); err != nil {
+		ln.Close()
+		return fmt.Errorf("restrict socket permissions: %w", err)
+	}
+
+	s.listener = ln
+	s.conns = make(map[net.Conn]struct{})
+	log.Printf("[SSHAgent] listening on %s", s.socketPath)
+
+	go s.serve(ln)
+	return nil
+}
+
+// Stop closes the socket and every open client connection. It does not
+// wait for in-flight requests, so it is safe to call while holding locks
+// those requests may need.
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.listener = nil
+	s.conns = nil
+	os.Remove(s.socketPath)
+	log.Println("[SSHAgent] stopped")
+	return err
+}
+
+func (s *Server) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[SSHAgent] accept error: %v", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		if s.listener != ln {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go func() {
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			_ = agent.ServeAgent(s.keyring, conn)
+		}()
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// WriteFileAtomic replaces path with data so that a crash or power loss
+// leaves either the old file or the new one, never a mix. The data goes to
+// a temporary file in the same directory, is flushed to disk, and is then
+// renamed over path; the directory is synced so the rename itself survives.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := writeSynced(path, data, perm)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace %q: %w", path, err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// writeSynced writes data to a fresh temporary file next to path and
+// fsyncs it. It returns the temporary file's name.
+func writeSynced(path string, data []byte, perm os.FileMode) (string, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file for %q: %w", path, err)
+	}
+	tmp := file.Name()
+
+	fail := func(step string, err error) (string, error) {
+		file.Close()
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("failed to %s %q: %w", step, tmp, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		return fail("write", err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := file.Chmod(perm); err != nil {
+			return fail("set permissions on", err)
+		}
+	}
+	if err := file.Sync(); err != nil {
+		return fail("sync", err)
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("failed to close %q: %w", tmp, err)
+	}
+	return tmp, nil
+}
+
+// syncDir flushes a directory's entries so renames and removals in it are
+// durable. Windows cannot open directories for syncing; NTFS journals
+// metadata changes itself.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory %q: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory %q: %w", dir, err)
+	}
+	return nil
+}
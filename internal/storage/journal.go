@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const journalFileName = "write-journal.json"
+
+// staleStagedAge is how old a temporary file must be before RecoverJournal
+// treats it as abandoned. A writer stages and commits within seconds, so a
+// transaction another process is still preparing (the desktop app, while pq
+// runs) keeps its files.
+const staleStagedAge = 10 * time.Minute
+
+// Tx replaces several files as one unit, e.g. every vault plus the
+// security profile during a master-password change.
+//
+// Write stages each new file next to its target and fsyncs it. Commit then
+// records the staged files in a journal before renaming any of them, so an
+// interruption after that point is completed by RecoverJournal on the next
+// start; an interruption before it leaves every target untouched.
+type Tx struct {
+	journalPath string
+	writes      []journalWrite
+	closed      bool
+}
+
+type journalWrite struct {
+	Target string `json:"target"`
+	Staged string `json:"staged"`
+}
+
+type journalFile struct {
+	Writes []journalWrite `json:"writes"`
+}
+
+// BeginTx starts a transaction whose journal lives in the vault directory.
+func BeginTx() (*Tx, error) {
+	journalPath, err := GetSecureFilePath(journalFileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(journalPath); err == nil {
+		return nil, fmt.Errorf("an interrupted write is still pending; restart the app to recover it")
+	}
+	return &Tx{journalPath: journalPath}, nil
+}
+
+// Write stages data as the new content of path.
+func (tx *Tx) Write(path string, data []byte, perm os.FileMode) error {
+	if tx.closed {
+		return fmt.Errorf("transaction already finished")
+	}
+	for _, w := range tx.writes {
+		if w.Target == path {
+			return fmt.Errorf("%q is already part of this transaction", path)
+		}
+	}
+	staged, err := writeSynced(path, data, perm)
+	if err != nil {
+		return err
+	}
+	tx.writes = append(tx.writes, journalWrite{Target: path, Staged: staged})
+	return nil
+}
+
+// Commit makes every staged write visible. Once the journal is on disk the
+// transaction is durable: if a rename fails here, the error is returned and
+// RecoverJournal finishes the job at the next start.
+func (tx *Tx) Commit() error {
+	if tx.closed {
+		return fmt.Errorf("transaction already finished")
+	}
+	tx.closed = true
+	if len(tx.writes) == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(journalFile{Writes: tx.writes}, "", "  ")
+	if err != nil {
+		tx.removeStaged()
+		return fmt.Errorf("failed to encode write journal: %w", err)
+	}
+	if err := WriteFileAtomic(tx.journalPath, data, 0600); err != nil {
+		tx.removeStaged()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	if err := applyJournal(tx.journalPath, tx.writes); err != nil {
+		return fmt.Errorf("%w (it will be completed when the app next starts)", err)
+	}
+	return nil
+}
+
+// Abort discards the staged files. It is a no-op after Commit, so callers
+// can defer it.
+func (tx *Tx) Abort() {
+	if tx.closed {
+		return
+	}
+	tx.closed = true
+	tx.removeStaged()
+}
+
+func (tx *Tx) removeStaged() {
+	for _, w := range tx.writes {
+		_ = os.Remove(w.Staged)
+	}
+}
+
+// RecoverJournal completes a transaction that was interrupted after its
+// journal was written and removes temporary files, anywhere in the vault
+// directory, left by writes that never got that far. It must run before
+// anything reads the vaults; the desktop app calls it at startup and
+// NewHeadlessSession before unlocking. Rolling forward is safe while another
+// process commits, and only temporary files older than staleStagedAge are
+// removed. It reports whether a transaction was rolled forward.
+func RecoverJournal() (bool, error) {
+	vaultDir, err := GetVaultDir()
+	if err != nil {
+		return false, err
+	}
+	journalPath := filepath.Join(vaultDir, journalFileName)
+
+	recovered := false
+	data, err := os.ReadFile(journalPath)
+	switch {
+	case err == nil:
+		var journal journalFile
+		if err := json.Unmarshal(data, &journal); err != nil {
+			return false, fmt.Errorf("failed to parse write journal %q: %w", journalPath, err)
+		}
+		if err := applyJournal(journalPath, journal.Writes); err != nil {
+			return false, err
+		}
+		recovered = true
+	case !errors.Is(err, os.ErrNotExist):
+		return false, fmt.Errorf("failed to read write journal: %w", err)
+	}
+
+	// Anything still named like a temporary file was never committed. Staged
+	// files live next to their targets: in backups/, retired-keys/ and files/
+	// as well as the vault directory itself.
+	_ = filepath.WalkDir(vaultDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if name := d.Name(); !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".tmp") {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && time.Since(info.ModTime()) > staleStagedAge {
+			_ = os.Remove(path)
+		}
+		return nil
+	})
+	return recovered, nil
+}
+
+// applyJournal renames every staged file over its target, syncs the
+// directories involved and then deletes the journal. A write whose staged
+// file is already gone was applied before an earlier interruption, or by
+// another process recovering the same journal.
+func applyJournal(journalPath string, writes []journalWrite) error {
+	dirs := map[string]bool{}
+	for _, w := range writes {
+		if err := os.Rename(w.Staged, w.Target); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("failed to replace %q: %w", w.Target, err)
+		}
+		dirs[filepath.Dir(w.Target)] = true
+	}
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(journalPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove write journal: %w", err)
+	}
+	return syncDir(filepath.Dir(journalPath))
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testVaultDir(t *testing.T) string {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	dir, err := GetVaultDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := testVaultDir(t)
+	path := filepath.Join(dir, "a.enc")
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFileAtomic(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if got := readString(t, path); got != content {
+			t.Fatalf("content = %q, want %q", got, content)
+		}
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(dir, ".*.tmp"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestTxCommitAndAbort(t *testing.T) {
+	dir := testVaultDir(t)
+	a, b := filepath.Join(dir, "a.enc"), filepath.Join(dir, "b.enc")
+	for _, p := range []string{a, b} {
+		if err := WriteFileAtomic(p, []byte("old"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx, err := BeginTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Write(a, []byte("new-a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tx.Abort()
+	if readString(t, a) != "old" {
+		t.Fatal("aborted transaction changed a file")
+	}
+
+	tx, err = BeginTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Abort()
+	if err := tx.Write(a, []byte("new-a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Write(b, []byte("new-b"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if readString(t, a) != "new-a" || readString(t, b) != "new-b" {
+		t.Fatal("commit did not replace both files")
+	}
+	if _, err := os.Stat(filepath.Join(dir, journalFileName)); !os.IsNotExist(err) {
+		t.Error("journal should be removed after commit")
+	}
+}
+
+// TestRecoverJournal simulates a crash after the journal was written and
+// one of two renames happened, plus a stray file from an uncommitted write.
+func TestRecoverJournal(t *testing.T) {
+	dir := testVaultDir(t)
+	a, b := filepath.Join(dir, "a.enc"), filepath.Join(dir, "b.enc")
+	if err := WriteFileAtomic(b, []byte("old-b"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stagedB, err := writeSynced(b, []byte("new-b"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(a, []byte("new-a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	journal, _ := json.Marshal(journalFile{Writes: []journalWrite{
+		{Target: a, Staged: filepath.Join(dir, ".a.enc.1.tmp")},
+		{Target: b, Staged: stagedB},
+	}})
+	if err := os.WriteFile(filepath.Join(dir, journalFileName), journal, 0600); err != nil {
+		t.Fatal(err)
+	}
+	stray, err := writeSynced(filepath.Join(dir, "c.enc"), []byte("never committed"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "backups", "c.enc"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	nestedStray, err := writeSynced(filepath.Join(dir, "backups", "c.enc", "snap.enc"), []byte("never committed"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abandoned := time.Now().Add(-2 * staleStagedAge)
+	for _, path := range []string{stray, nestedStray} {
+		if err := os.Chtimes(path, abandoned, abandoned); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Another process may be staging a transaction right now.
+	inFlight, err := writeSynced(filepath.Join(dir, "d.enc"), []byte("being staged"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := BeginTx(); err == nil {
+		t.Error("BeginTx should refuse to start while a journal is pending")
+	}
+
+	recovered, err := RecoverJournal()
+	if err != nil || !recovered {
+		t.Fatalf("RecoverJournal = %v, %v", recovered, err)
+	}
+	if readString(t, a) != "new-a" || readString(t, b) != "new-b" {
+		t.Error("recovery should roll both writes forward")
+	}
+	for _, path := range []string{stray, nestedStray} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("uncommitted staged file %s should be removed", path)
+		}
+	}
+	if _, err := os.Stat(inFlight); err != nil {
+		t.Error("a recently staged file should be kept")
+	}
+
+	if recovered, err := RecoverJournal(); err != nil || recovered {
+		t.Errorf("second RecoverJournal = %v, %v", recovered, err)
+	}
+}
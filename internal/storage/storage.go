@@ -34,7 +34,7 @@ func ResolveVaultPath(vaultPath string) (string, error) {
 	return filepath.Join(vaultDir, fileName), nil
 }
 
-// WriteVaultFile writes vault data to the secure vault directory.
+// WriteVaultFile atomically replaces a vault file in the secure vault directory.
 func WriteVaultFile(vaultPath string, data []byte) error {
 	return writeVaultFile(vaultPath, data)
 }
@@ -79,22 +79,10 @@ func writeVaultFile(vaultPath string, data []byte) error {
 		return fmt.Errorf("failed to resolve vault path: %w", err)
 	}
 
-	file, err := os.OpenFile(resolvedPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to open vault file %q: %w", resolvedPath, err)
-	}
-	defer file.Close()
-
-	if _, err := file.Write(data); err != nil {
+	if err := WriteFileAtomic(resolvedPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write vault file %q: %w", resolvedPath, err)
 	}
 
-	if runtime.GOOS != "windows" {
-		if err := os.Chmod(resolvedPath, 0600); err != nil {
-			return fmt.Errorf("failed to set vault file permissions on %q: %w", resolvedPath, err)
-		}
-	}
-
 	return nil
 }
 
@@ -275,6 +275,10 @@ type GlowButton struct {
 	glowColor     color.Color
 }
 
+// OnTap, when set, runs before every themed button's own handler. The UI
+// uses it to count clicks as activity for the idle lock.
+var OnTap func()
+
 type clickOverlay struct {
 	widget.BaseWidget
 	onTap func()
@@ -287,6 +291,9 @@ func NewClickOverlay(onTap func()) *clickOverlay {
 }
 
 func (o *clickOverlay) Tapped(_ *fyne.PointEvent) {
+	if OnTap != nil {
+		OnTap()
+	}
 	if o.onTap != nil {
 		o.onTap()
 	}
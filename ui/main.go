@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 	"os/signal"
+	"runtime"
 	"strings"
 	"sync"
 	"syscall"
@@ -15,7 +17,13 @@ import (
 	"passquantum/bridge"
 	"passquantum/core/crypto"
 	"passquantum/core/filevault"
+	"passquantum/core/storage"
 	"passquantum/internal/browser"
+	"passquantum/internal/gitcred"
+	"passquantum/internal/lockpolicy"
+	"passquantum/internal/nativehost"
+	"passquantum/internal/secretservice"
+	"passquantum/internal/sshagent"
 	securestorage "passquantum/internal/storage"
 	"passquantum/theme"
 	"passquantum/ui/screens"
@@ -31,6 +39,14 @@ func main() {
 		log.Printf("WARNING: failed to validate vault permissions: %v", err)
 	}
 
+	// Finish (or discard) a multi-file write that a crash interrupted before
+	// anything reads the vaults.
+	if recovered, err := securestorage.RecoverJournal(); err != nil {
+		log.Printf("WARNING: failed to recover interrupted vault write: %v", err)
+	} else if recovered {
+		log.Println("Completed a vault write interrupted by the last shutdown.")
+	}
+
 	if err := filevault.CleanupOrphans(); err != nil {
 		log.Printf("WARNING: failed to cleanup orphan temp files: %v", err)
 	}
@@ -52,6 +68,8 @@ func main() {
 
 	// Initialize crypto keypair
 	appState := initializeApp()
+	appState.TrashRetention = screens.LoadTrashRetention(myApp.Preferences())
+	storage.SetSnapshotRetention(screens.LoadSnapshotRetention(myApp.Preferences()))
 
 	// Initialize face recognition guard (warn-only on failure — app proceeds without it)
 	if guard, err := bridge.NewFaceGuard(); err != nil {
@@ -98,24 +116,79 @@ func main() {
 		screens.PromptMasterPassword(w, myApp, appState)
 	}
 
-	// Browser extension API server
+	// Auto-lock policy. Triggers clear sensitive state on their own goroutine
+	// right away, so the browser API, git helper and agents stop answering
+	// before the login screen is shown.
+	lockPolicy, err := lockpolicy.LoadPolicy()
+	if err != nil {
+		log.Printf("[LockPolicy] WARNING: using defaults: %v", err)
+		lockPolicy = lockpolicy.DefaultPolicy()
+	}
+	appState.LockMonitor = lockpolicy.NewMonitor(lockPolicy, func(reason lockpolicy.Reason) {
+		appState.ClearSensitiveState()
+		fyne.Do(appState.LockApp)
+	})
+	appState.LockMonitor.Start()
+	screens.TrackActivity(w, myApp, appState)
+
+	var logindWatcher *lockpolicy.LogindWatcher
+	if runtime.GOOS == "linux" {
+		logindWatcher = lockpolicy.NewLogindWatcher("", appState.LockMonitor.Trigger)
+		if err := logindWatcher.Start(); err != nil {
+			log.Printf("[LockPolicy] WARNING: screen lock and suspend will not lock the app: %v", err)
+		}
+	}
+
+	// Browser extension API, reached through passquantum-native-host
 	browserCfg, _ := browser.LoadConfig()
 	domainMap, _ := browser.NewDomainMap()
 	vaultSvc := browser.NewAppVaultService(appState, domainMap)
-	browserServer := browser.NewServer(vaultSvc, browserCfg)
+	browserSocket, browserSockErr := nativehost.DefaultSocketPath()
+	browserServer := browser.NewServer(vaultSvc, browserCfg, browserSocket)
 	browserServer.SetPairingCallback(func(token string) {
 		fyne.Do(func() {
 			screens.ShowPairingDialog(w, token)
 		})
 	})
-	if err := browserServer.Start(); err != nil {
+	browserServer.SetFillConfirmCallback(screens.ConfirmBrowserFill(w))
+	screens.SetBrowserServer(browserServer)
+	if browserSockErr != nil {
+		log.Printf("[Browser] WARNING: no socket path: %v", browserSockErr)
+	} else if err := browserServer.Start(); err != nil {
 		log.Printf("[Browser] WARNING: could not start browser API server: %v", err)
 	}
 
+	// git credential helper endpoint; answers "locked" until a vault is open.
+	var gitCredServer *gitcred.Server
+	gitSocket, sockErr := gitcred.DefaultSocketPath()
+	gitSecret, secretErr := gitcred.DefaultSecretPath()
+	if sockErr != nil || secretErr != nil {
+		log.Printf("[GitCred] WARNING: no socket path: %v %v", sockErr, secretErr)
+	} else {
+		gitCredServer = gitcred.NewServer(gitcred.NewAppStore(appState, domainMap), gitSocket, gitSecret)
+		if err := gitCredServer.Start(); err != nil {
+			log.Printf("[GitCred] WARNING: could not start credential server: %v", err)
+		}
+	}
+
+	// SSH agent: started by the main screen when enabled, stopped on lock.
+	if socketPath, err := sshagent.DefaultSocketPath(); err != nil {
+		log.Printf("[SSHAgent] WARNING: no socket path: %v", err)
+	} else {
+		appState.SSHAgent = sshagent.NewServer(pqapp.NewSSHKeySource(appState), socketPath)
+		appState.SSHAgent.SetConfirmCallback(screens.ConfirmSSHKeyUse(w))
+	}
+
+	// Secret Service provider: off unless enabled in settings.
+	if runtime.GOOS == "linux" {
+		appState.SecretService = secretservice.NewService(pqapp.NewSecretServiceBackend(appState), "")
+		screens.StartSecretServiceIfEnabled(myApp, appState)
+	}
+
 	screens.PromptMasterPassword(w, myApp, appState)
 
 	// cleanup releases the webcam (by killing face_guard.py) and stops the
-	// browser server. Guarded by sync.Once so it is safe to invoke from every
+	// local servers. Guarded by sync.Once so it is safe to invoke from every
 	// exit path without double-running.
 	var cleanupOnce sync.Once
 	cleanup := func() {
@@ -124,6 +197,19 @@ func main() {
 				appState.FaceGuard.Shutdown()
 			}
 			browserServer.Stop()
+			appState.LockMonitor.Stop()
+			if logindWatcher != nil {
+				logindWatcher.Stop()
+			}
+			if gitCredServer != nil {
+				gitCredServer.Stop()
+			}
+			if appState.SecretService != nil {
+				appState.SecretService.Stop()
+			}
+			if appState.SSHAgent != nil {
+				appState.SSHAgent.Stop()
+			}
 		})
 	}
 
@@ -177,8 +263,10 @@ func initializeApp() *pqapp.AppState {
 		log.Fatal("Failed to resolve private key path:", err)
 	}
 
+	// A wrapped private key is opened when the user unlocks; until then only
+	// the public key is loaded.
 	pubKey, privKey, err := crypto.LoadKeypair(pubKeyPath, privKeyPath)
-	if err != nil {
+	if err != nil && !errors.Is(err, crypto.ErrPrivateKeyWrapped) {
 		pubKey, privKey, err = crypto.GenerateKeypair()
 		if err != nil {
 			log.Fatal("Failed to generate keypair:", err)
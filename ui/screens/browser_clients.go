@@ -0,0 +1,146 @@
+package screens
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"passquantum/internal/browser"
+	"passquantum/theme"
+	"passquantum/ui/widgets"
+)
+
+// browserServer is the extension API server whose paired clients the
+// settings screen manages. It lives outside AppState because the browser
+// package depends on app.
+var browserServer *browser.Server
+
+// SetBrowserServer hands the running extension API server to the settings
+// screen.
+func SetBrowserServer(s *browser.Server) {
+	browserServer = s
+}
+
+// buildBrowserClientsCard lists the paired browsers with what each may do,
+// and renames, rotates the key of or revokes them.
+func buildBrowserClientsCard(w fyne.Window) fyne.CanvasObject {
+	if browserServer == nil {
+		return theme.CardWithHeader("BROWSER EXTENSIONS", "Paired browsers", nil,
+			theme.MonoText("The browser extension API is not running.", 11, theme.ColorFg2))
+	}
+
+	list := container.NewVBox()
+	var reload func()
+	reload = func() {
+		list.RemoveAll()
+		clients, err := browserServer.Clients()
+		if err != nil {
+			list.Add(theme.MonoText(fmt.Sprintf("Could not read the paired browsers: %v", err), 11, theme.ColorFg2))
+			return
+		}
+		if len(clients) == 0 {
+			list.Add(theme.MonoText("No browsers paired. Click Connect in the extension's popup.", 11, theme.ColorFg2))
+		}
+		for _, c := range clients {
+			list.Add(browserClientRow(c, w, reload))
+		}
+		list.Refresh()
+	}
+	reload()
+
+	return theme.CardWithHeader("BROWSER EXTENSIONS", "Paired browsers", nil,
+		container.NewVBox(
+			list,
+			theme.MonoText("Every browser or profile pairs on its own. Reading which sites have logins is always allowed.", 11, theme.ColorFg2),
+			theme.MonoText("Rotate a key for hygiene; revoke and pair again if a browser profile may be compromised.", 11, theme.ColorFg2),
+		),
+	)
+}
+
+func browserClientRow(c browser.Client, w fyne.Window, reload func()) fyne.CanvasObject {
+	nameTxt := canvas.NewText(c.Name, theme.ColorTextPrimary)
+	nameTxt.TextSize = 13
+	nameTxt.TextStyle = fyne.TextStyle{Bold: true}
+	titleRow := container.NewHBox(nameTxt, theme.KindBadge(c.Browser))
+	if c.RekeyPending {
+		titleRow.Add(theme.KindBadge("Key rotating"))
+	}
+
+	lastSeen := "never connected"
+	if !c.LastSeen.IsZero() {
+		lastSeen = "last seen " + c.LastSeen.Local().Format("2006-01-02 15:04")
+	}
+	details := theme.MonoText(fmt.Sprintf("Paired %s, %s", c.PairedAt.Local().Format("2006-01-02"), lastSeen), 11, theme.ColorTextSecondary)
+
+	perms := c.Permissions
+	saveCheck := widget.NewCheck("Save logins", nil)
+	saveCheck.SetChecked(perms.Save)
+	fillCheck := widget.NewCheck("Fill logins", nil)
+	fillCheck.SetChecked(perms.Fill)
+	apply := func() {
+		next := browser.ClientPermissions{Save: saveCheck.Checked, Fill: fillCheck.Checked}
+		if err := browserServer.SetClientPermissions(c.ID, next); err != nil {
+			widgets.ShowAppError(fmt.Errorf("could not change what %s may do: %w", c.Name, err), w)
+			reload()
+		}
+	}
+	saveCheck.OnChanged = func(bool) { apply() }
+	fillCheck.OnChanged = func(bool) { apply() }
+
+	renameBtn := theme.CreateSmallIconButton(theme.IconEdit, func() {
+		showRenameBrowserClient(c, w, reload)
+	})
+	rotateBtn := theme.CreateSmallIconButton(theme.IconRefresh, func() {
+		widgets.ShowAppConfirm("Rotate key",
+			fmt.Sprintf("Give '%s' a new key?\n\nThe extension switches to it the next time it connects; until then the current key keeps working.", c.Name),
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				if err := browserServer.RotateClientKey(c.ID); err != nil {
+					widgets.ShowAppError(fmt.Errorf("could not rotate the key: %w", err), w)
+				}
+				reload()
+			}, w)
+	})
+	revokeBtn := theme.CreateSmallIconButton(theme.IconTrash, func() {
+		widgets.ShowAppConfirm("Revoke browser",
+			fmt.Sprintf("Unpair '%s'? It is disconnected at once and has to pair again to reconnect.", c.Name),
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				if err := browserServer.RevokeClient(c.ID); err != nil {
+					widgets.ShowAppError(fmt.Errorf("could not revoke %s: %w", c.Name, err), w)
+				}
+				reload()
+			}, w)
+	})
+
+	info := container.NewVBox(titleRow, details, container.NewHBox(saveCheck, fillCheck))
+	return container.NewBorder(nil, nil, nil, container.NewHBox(renameBtn, rotateBtn, revokeBtn), info)
+}
+
+func showRenameBrowserClient(c browser.Client, w fyne.Window, reload func()) {
+	nameInput := widget.NewEntry()
+	nameInput.SetText(c.Name)
+
+	d := dialog.NewCustomConfirm("Rename browser", "Save", "Cancel",
+		container.NewVBox(theme.FieldLabel("NAME", nil), nameInput),
+		func(ok bool) {
+			if !ok || strings.TrimSpace(nameInput.Text) == "" {
+				return
+			}
+			if err := browserServer.RenameClient(c.ID, nameInput.Text); err != nil {
+				widgets.ShowAppError(fmt.Errorf("could not rename the browser: %w", err), w)
+			}
+			reload()
+		}, w)
+	d.Resize(fyne.NewSize(380, 180))
+	d.Show()
+}
@@ -0,0 +1,52 @@
+package screens
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+
+	"passquantum/internal/browser"
+	"passquantum/ui/widgets"
+)
+
+// fillConfirmTimeout bounds how long a fill request waits for the user; it
+// stays under the request's own lifetime so the answer can still be
+// collected.
+const fillConfirmTimeout = 45 * time.Second
+
+// ConfirmBrowserFill returns the browser server's fill prompt: a dialog on w
+// that denies the request if it is not answered in time.
+func ConfirmBrowserFill(w fyne.Window) func(browser.FillPrompt) browser.FillDecision {
+	return func(p browser.FillPrompt) browser.FillDecision {
+		answer := make(chan browser.FillDecision, 1)
+		fyne.Do(func() {
+			who := p.Username
+			if who == "" {
+				who = p.Service
+			}
+			widgets.ShowAppConfirmWithRemember(
+				"Fill password in browser?",
+				fmt.Sprintf("The browser extension asks for the password of '%s' to sign in to %s.", who, p.Domain),
+				"Always allow on "+p.Domain,
+				func(ok, always bool) {
+					switch {
+					case ok && always:
+						answer <- browser.FillAlwaysAllow
+					case ok:
+						answer <- browser.FillAllowOnce
+					default:
+						answer <- browser.FillDeny
+					}
+				},
+				w,
+			)
+		})
+		select {
+		case d := <-answer:
+			return d
+		case <-time.After(fillConfirmTimeout):
+			return browser.FillDeny
+		}
+	}
+}
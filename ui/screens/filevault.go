@@ -229,17 +229,17 @@ func buildFileCard(meta *filevault.FileMetadata, store *filevault.Store, w fyne.
 	})
 
 	deleteBtn := theme.CreateSmallIconButton(theme.IconTrash, func() {
-		widgets.ShowAppConfirm("Delete", fmt.Sprintf("Delete '%s'? This cannot be undone.", meta.OriginalName), func(ok bool) {
+		widgets.ShowAppConfirm("Delete", fmt.Sprintf("Move '%s' to the trash?", meta.OriginalName), func(ok bool) {
 			if !ok {
 				return
 			}
 			go func() {
-				err := store.DeleteFile(meta.UUID)
+				err := store.TrashFile(meta.UUID)
 				fyne.Do(func() {
 					if err != nil {
 						widgets.ShowAppError(fmt.Errorf("delete: %w", err), w)
 					} else {
-						widgets.ShowAppInformation("Deleted", "File deleted successfully", w)
+						widgets.ShowAppInformation("Moved to trash", "File moved to the trash", w)
 						if onRefresh != nil {
 							onRefresh()
 						}
@@ -338,7 +338,7 @@ func (w *importWizardState) runImport() {
 			theme.PageHeader(
 				"PASSQUANTUM / "+w.ns.appState.CurrentVault+" / IMPORT",
 				"Encrypting entries",
-				"Each entry is re-encrypted with a fresh Kyber-768 envelope.",
+				"Each entry is re-encrypted with a fresh ML-KEM envelope.",
 				nil,
 			),
 			progressCard,
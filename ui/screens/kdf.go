@@ -0,0 +1,77 @@
+package screens
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"passquantum/app"
+	"passquantum/theme"
+	"passquantum/ui/widgets"
+)
+
+// buildKDFCard shows the Argon2id cost the master password is stretched
+// with and recalibrates it for this machine.
+func buildKDFCard(w fyne.Window, appState *app.AppState) fyne.CanvasObject {
+	statusText := func() *canvas.Text {
+		t := canvas.NewText("", theme.ColorFg2)
+		t.TextSize = 11
+		t.TextStyle = fyne.TextStyle{Monospace: true}
+		return t
+	}
+	costLabel, vaultLabel, calibratedLabel, floorLabel := statusText(), statusText(), statusText(), statusText()
+	refresh := func() {
+		status, err := app.CurrentKDFStatus()
+		if err != nil {
+			costLabel.Text = fmt.Sprintf("Could not read the KDF policy: %v", err)
+			costLabel.Refresh()
+			return
+		}
+		costLabel.Text = fmt.Sprintf("Argon2id: %d MiB, %d iterations, %d lanes", status.MemoryKiB/1024, status.Iterations, status.Parallelism)
+		vaultLabel.Text = fmt.Sprintf("Vault files: %d MiB, %d iterations", status.VaultMemoryKiB/1024, status.VaultIterations)
+		calibratedLabel.Text = "Not calibrated on this machine"
+		if !status.CalibratedAt.IsZero() {
+			calibratedLabel.Text = fmt.Sprintf("Calibrated %s for %d ms per unlock, %d ms per vault operation",
+				status.CalibratedAt.Local().Format("2006-01-02 15:04"), status.TargetMillis, status.VaultTargetMillis)
+		}
+		floorLabel.Text = fmt.Sprintf("Policy floor: %d MiB, %d iterations", status.MinMemoryKiB/1024, status.MinIterations)
+		costLabel.Refresh()
+		vaultLabel.Refresh()
+		calibratedLabel.Refresh()
+		floorLabel.Refresh()
+	}
+	refresh()
+
+	calibrateBtn := theme.CreateDefaultButton("Recalibrate", func() {
+		progress := dialog.NewCustomWithoutButtons("Calibrating",
+			container.NewVBox(
+				theme.MonoText("Benchmarking Argon2id and re-deriving keys...", 11, theme.ColorFg2),
+				widget.NewProgressBarInfinite(),
+			), w)
+		progress.Show()
+		go func() {
+			_, err := app.RecalibrateKDF(appState)
+			fyne.Do(func() {
+				progress.Hide()
+				refresh()
+				if err != nil {
+					widgets.ShowAppError(fmt.Errorf("calibration failed: %w", err), w)
+				}
+			})
+		}()
+	})
+
+	return theme.CardWithHeader("KEY DERIVATION", "Master password cost", calibrateBtn,
+		container.NewVBox(
+			costLabel,
+			vaultLabel,
+			calibratedLabel,
+			floorLabel,
+			theme.MonoText("Recalibrate after moving to faster hardware; keys are re-derived at the new cost.", 11, theme.ColorFg2),
+		),
+	)
+}
@@ -0,0 +1,164 @@
+package screens
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"passquantum/app"
+	"passquantum/theme"
+	"passquantum/ui/widgets"
+)
+
+var rotationIntervals = []struct {
+	label string
+	days  int
+}{
+	{"Manual only", 0},
+	{"Every 90 days", 90},
+	{"Every 180 days", 180},
+	{"Every 365 days", 365},
+}
+
+var keyAlgorithms = []string{"X-Wing", "ML-KEM-768", "ML-KEM-1024"}
+
+// buildKeyRotationCard shows the keypair's algorithm and age, edits the rotation
+// schedule and rotates on demand.
+func buildKeyRotationCard(w fyne.Window, appState *app.AppState) fyne.CanvasObject {
+	schedule, err := app.LoadKeyRotationSchedule()
+	if err != nil {
+		return theme.CardWithHeader("KEY ROTATION", "Vault keypair", nil,
+			theme.MonoText(fmt.Sprintf("Could not read the rotation schedule: %v", err), 11, theme.ColorFg2))
+	}
+
+	statusText := func() *canvas.Text {
+		t := canvas.NewText("", theme.ColorFg2)
+		t.TextSize = 11
+		t.TextStyle = fyne.TextStyle{Monospace: true}
+		return t
+	}
+	keyLabel, lastLabel, nextLabel := statusText(), statusText(), statusText()
+	refresh := func() {
+		keyLabel.Text = "Current keypair: " + app.KeypairAlgorithm(appState)
+		last := "unknown"
+		if !schedule.LastRotated.IsZero() {
+			last = schedule.LastRotated.Local().Format("2006-01-02 15:04")
+		}
+		lastLabel.Text = "Last rotated: " + last
+		next := "manual only"
+		if n := schedule.NextRotation(time.Now()); !n.IsZero() {
+			next = n.Local().Format("2006-01-02")
+		}
+		nextLabel.Text = "Next rotation: " + next
+		keyLabel.Refresh()
+		lastLabel.Refresh()
+		nextLabel.Refresh()
+	}
+	refresh()
+
+	labels := make([]string, len(rotationIntervals))
+	selected := rotationIntervals[0].label
+	for i, r := range rotationIntervals {
+		labels[i] = r.label
+		if r.days == schedule.IntervalDays {
+			selected = r.label
+		}
+	}
+	intervalSelect := widget.NewSelect(labels, nil)
+	intervalSelect.SetSelected(selected)
+	intervalSelect.OnChanged = func(label string) {
+		for _, r := range rotationIntervals {
+			if r.label == label {
+				schedule.IntervalDays = r.days
+			}
+		}
+		if err := schedule.Save(); err != nil {
+			widgets.ShowAppError(fmt.Errorf("could not save the rotation schedule: %w", err), w)
+			return
+		}
+		refresh()
+	}
+
+	algorithmSelect := widget.NewSelect(keyAlgorithms, nil)
+	if next, err := schedule.KEM(); err == nil {
+		algorithmSelect.SetSelected(next.String())
+	}
+	algorithmSelect.OnChanged = func(name string) {
+		schedule.Algorithm = name
+		if err := schedule.Save(); err != nil {
+			widgets.ShowAppError(fmt.Errorf("could not save the rotation schedule: %w", err), w)
+		}
+	}
+
+	rotateBtn := theme.CreateDefaultButton("Rotate keys now", func() {
+		confirmKeyRotation(w, appState, func() {
+			if s, err := app.LoadKeyRotationSchedule(); err == nil {
+				schedule = s
+				refresh()
+			}
+		})
+	})
+
+	return theme.CardWithHeader("KEY ROTATION", "Vault keypair", rotateBtn,
+		container.NewVBox(
+			theme.FieldLabel("ROTATION SCHEDULE", nil),
+			intervalSelect,
+			theme.FieldLabel("ALGORITHM FOR NEW KEYS", nil),
+			algorithmSelect,
+			keyLabel,
+			lastLabel,
+			nextLabel,
+			theme.MonoText("Rotation re-seals every entry and stored file in every vault under a new keypair.", 11, theme.ColorFg2),
+		),
+	)
+}
+
+// PromptKeyRotationIfDue offers to rotate right after unlock when the
+// schedule says the keypair is due.
+func PromptKeyRotationIfDue(w fyne.Window, appState *app.AppState) {
+	if !app.KeyRotationDue(time.Now()) {
+		return
+	}
+	confirmKeyRotation(w, appState, nil)
+}
+
+func confirmKeyRotation(w fyne.Window, appState *app.AppState, onDone func()) {
+	message := "Generate a new vault keypair and re-seal every entry and stored file under it?\n\nThis can take a while for large vaults."
+	if app.KeyRotationDue(time.Now()) {
+		message = "Your key rotation policy says the vault keypair is due to be replaced.\n\n" + message
+	}
+	widgets.ShowAppConfirm("Rotate keys", message, func(ok bool) {
+		if ok {
+			runKeyRotation(w, appState, onDone)
+		}
+	}, w)
+}
+
+func runKeyRotation(w fyne.Window, appState *app.AppState, onDone func()) {
+	progress := dialog.NewCustomWithoutButtons("Rotating keys",
+		container.NewVBox(
+			theme.MonoText("Re-sealing vaults and stored files...", 11, theme.ColorFg2),
+			widget.NewProgressBarInfinite(),
+		), w)
+	progress.Show()
+
+	go func() {
+		err := app.RotateKeys(appState)
+		fyne.Do(func() {
+			progress.Hide()
+			if err != nil {
+				widgets.ShowAppError(fmt.Errorf("key rotation failed: %w", err), w)
+				return
+			}
+			if onDone != nil {
+				onDone()
+			}
+			widgets.ShowAppInformation("Keys rotated", "Every entry and stored file is now sealed under the new keypair.", w)
+		})
+	}()
+}
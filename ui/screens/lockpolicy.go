@@ -0,0 +1,95 @@
+package screens
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+
+	"passquantum/app"
+	"passquantum/theme"
+	"passquantum/ui/widgets"
+)
+
+// TrackActivity feeds the window's input to the lock monitor's idle timer:
+// key presses outside text fields, clicks on themed buttons and tabs, and
+// the window coming back to the foreground.
+func TrackActivity(w fyne.Window, fyneApp fyne.App, appState *app.AppState) {
+	monitor := appState.LockMonitor
+	if monitor == nil {
+		return
+	}
+	if dc, ok := w.Canvas().(desktop.Canvas); ok {
+		dc.SetOnKeyDown(func(*fyne.KeyEvent) { monitor.Activity() })
+	}
+	theme.OnTap = monitor.Activity
+	fyneApp.Lifecycle().SetOnEnteredForeground(monitor.Activity)
+}
+
+// buildLockPolicyCard edits the installation's auto-lock policy.
+func buildLockPolicyCard(w fyne.Window, appState *app.AppState) fyne.CanvasObject {
+	monitor := appState.LockMonitor
+	if monitor == nil {
+		return theme.CardWithHeader("AUTO-LOCK", "Lock policy", nil,
+			theme.MonoText("Automatic locking is not available in this build.", 11, theme.ColorFg2))
+	}
+	policy := monitor.Policy()
+
+	minutesEntry := func(value int) *widget.Entry {
+		e := widget.NewEntry()
+		e.SetText(strconv.Itoa(value))
+		return e
+	}
+	idleEntry := minutesEntry(policy.IdleMinutes)
+	sessionEntry := minutesEntry(policy.MaxSessionMinutes)
+	failedEntry := minutesEntry(policy.MaxFailedUnlocks)
+	lockoutEntry := minutesEntry(policy.LockoutMinutes)
+
+	screenLockCheck := widget.NewCheck("Lock when the screen locks", nil)
+	screenLockCheck.SetChecked(policy.LockOnScreenLock)
+	suspendCheck := widget.NewCheck("Lock before the system suspends", nil)
+	suspendCheck.SetChecked(policy.LockOnSuspend)
+
+	saveBtn := theme.CreateDefaultButton("Save policy", func() {
+		values := make([]int, 4)
+		for i, e := range []*widget.Entry{idleEntry, sessionEntry, failedEntry, lockoutEntry} {
+			n, err := strconv.Atoi(strings.TrimSpace(e.Text))
+			if err != nil || n < 0 {
+				widgets.ShowAppError(fmt.Errorf("%q is not a whole number of zero or more", e.Text), w)
+				return
+			}
+			values[i] = n
+		}
+		policy.IdleMinutes, policy.MaxSessionMinutes = values[0], values[1]
+		policy.MaxFailedUnlocks, policy.LockoutMinutes = values[2], values[3]
+		policy.LockOnScreenLock = screenLockCheck.Checked
+		policy.LockOnSuspend = suspendCheck.Checked
+
+		if err := policy.Save(); err != nil {
+			widgets.ShowAppError(fmt.Errorf("could not save the lock policy: %w", err), w)
+			return
+		}
+		monitor.SetPolicy(policy)
+		widgets.ShowAppInformation("Saved", "The new lock policy is in effect.", w)
+	})
+
+	return theme.CardWithHeader("AUTO-LOCK", "Lock policy", saveBtn,
+		container.NewVBox(
+			theme.FieldLabel("LOCK AFTER IDLE (MINUTES)", nil),
+			idleEntry,
+			theme.FieldLabel("MAXIMUM SESSION (MINUTES)", nil),
+			sessionEntry,
+			theme.FieldLabel("FAILED UNLOCKS BEFORE LOCKOUT", nil),
+			failedEntry,
+			theme.FieldLabel("LOCKOUT (MINUTES)", nil),
+			lockoutEntry,
+			screenLockCheck,
+			suspendCheck,
+			theme.MonoText("0 turns a limit off. Browser, git, SSH and keyring clients see the app as locked too.", 11, theme.ColorFg2),
+		),
+	)
+}
@@ -108,6 +108,7 @@ func showUnlockScreen(w fyne.Window, fyneApp fyne.App, appState *app.AppState) {
 		}
 
 		ShowVaultSelection(w, fyneApp, appState)
+		PromptKeyRotationIfDue(w, appState)
 	})
 
 	screen := buildAccessScreen(
@@ -35,6 +35,7 @@ const (
 	NavViewTOTP
 	NavViewFiles
 	NavViewImport
+	NavViewTrash
 )
 
 // NavigationState tracks the current view
@@ -70,6 +71,7 @@ func ShowMainScreen(w fyne.Window, fyneApp fyne.App, appState *app.AppState) {
 	if guard := appState.FaceGuard; guard != nil {
 		guard.SendCommand("START_MONITOR")
 	}
+	startSSHAgentIfEnabled(fyneApp, appState)
 }
 
 func (ns *NavigationState) breadcrumbs() []string {
@@ -93,6 +95,8 @@ func (ns *NavigationState) breadcrumbs() []string {
 		return []string{base, ns.appState.CurrentVault, "Files"}
 	case NavViewImport:
 		return []string{base, ns.appState.CurrentVault, "Import"}
+	case NavViewTrash:
+		return []string{base, ns.appState.CurrentVault, "Trash"}
 	default:
 		return []string{base}
 	}
@@ -155,6 +159,7 @@ func (ns *NavigationState) rebuildUI() {
 		{theme.IconClock, "Authenticator", NavViewTOTP, nil},
 		{theme.IconFolder, "Files", NavViewFiles, nil},
 		{theme.IconDownload, "Import", NavViewImport, nil},
+		{theme.IconTrash, "Trash", NavViewTrash, nil},
 	}
 	toolsSection := []navEntry{
 		{theme.IconWand, "Generate", NavViewGenerator, nil},
@@ -237,6 +242,7 @@ func (ns *NavigationState) rebuildUI() {
 			makeIconBtn(theme.IconClock, NavViewTOTP, nil),
 			makeIconBtn(theme.IconFolder, NavViewFiles, nil),
 			makeIconBtn(theme.IconDownload, NavViewImport, nil),
+			makeIconBtn(theme.IconTrash, NavViewTrash, nil),
 			divider2,
 			makeIconBtn(theme.IconWand, NavViewGenerator, nil),
 			makeIconBtn(theme.IconShieldCheck, NavViewChecker, nil),
@@ -301,6 +307,9 @@ func (ns *NavigationState) switchView(view NavView) {
 		ns.viewCleanup()
 		ns.viewCleanup = nil
 	}
+	if ns.appState.LockMonitor != nil {
+		ns.appState.LockMonitor.Activity()
+	}
 	ns.currentView = view
 	crumbs := ns.breadcrumbs()
 	ns.window.SetTitle("PassQuantum — " + crumbs[len(crumbs)-1])
@@ -329,6 +338,8 @@ func (ns *NavigationState) updateContent() {
 		content = ns.createFilesView()
 	case NavViewImport:
 		content = ns.createImportView()
+	case NavViewTrash:
+		content = ns.createTrashView()
 	default:
 		content = ns.createItemsView()
 	}
@@ -368,7 +379,7 @@ func (ns *NavigationState) createVaultsView() fyne.CanvasObject {
 	encryptionInfo := theme.CollapsibleCardWithHeader("ENCRYPTION", "Vault security", nil,
 		theme.KeyValueTable([]theme.KVItem{
 			{Key: "Algorithm", Value: "AES-256-GCM", Detail: "96-bit IV, 128-bit auth tag"},
-			{Key: "Key encapsulation", Value: "ML-KEM-768 (Kyber)", Detail: "NIST FIPS 203"},
+			{Key: "Key encapsulation", Value: app.KeypairAlgorithm(ns.appState), Detail: "NIST FIPS 203"},
 			{Key: "Password KDF", Value: "Argon2id", Detail: "m=64 MiB, t=3, p=4, 16-byte salt"},
 			{Key: "Random source", Value: "crypto/rand", Detail: "OS CSPRNG"},
 		}),
@@ -477,29 +488,16 @@ func showSaveGeneratedPasswordDialog(w fyne.Window, fyneApp fyne.App, appState *
 					return
 				}
 
-				ct, ss, err := app.Encapsulate(appState.PublicKey)
-				if err != nil {
-					fyne.Do(func() {
-						widgets.ShowAppError(fmt.Errorf("encapsulation failed: %v", err), w)
-					})
-					return
-				}
-
-				nonce, ciphertext, err := app.EncryptAES256GCM(password, ss)
-				if err != nil {
-					fyne.Do(func() {
-						widgets.ShowAppError(fmt.Errorf("encryption failed: %v", err), w)
-					})
-					return
-				}
-
 				entry := model.NewVaultEntry()
 				entry.Type = model.EntryTypePassword
-				entry.KyberCiphertext = ct
-				entry.Nonce = nonce
-				entry.Ciphertext = ciphertext
 				entry.Service = service
 				entry.Username = username
+				if err := app.SealPasswordPayload(entry, model.NewPasswordPayload(password), appState.PublicKey); err != nil {
+					fyne.Do(func() {
+						widgets.ShowAppError(err, w)
+					})
+					return
+				}
 
 				entries = append(entries, entry)
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -118,6 +119,14 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 	usernameInput := widget.NewEntry()
 	usernameInput.PlaceHolder = "Username or email"
 
+	loginURLInput := widget.NewEntry()
+	loginURLInput.PlaceHolder = "https://example.com/login (optional)"
+	passwordNotesInput := widget.NewMultiLineEntry()
+	passwordNotesInput.SetMinRowsVisible(3)
+	passwordNotesInput.PlaceHolder = "Optional notes, encrypted with the password"
+	fieldsEditor := newCustomFieldsEditor(nil)
+	matchEditor := newMatchRuleEditor(model.MatchRule{})
+
 	noteTitleInput := widget.NewEntry()
 	noteTitleInput.PlaceHolder = "Note title"
 	noteContentInput := widget.NewMultiLineEntry()
@@ -151,8 +160,16 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 	totpPeriodSelect := widget.NewSelect([]string{"30", "60", "90"}, nil)
 	totpPeriodSelect.SetSelected("30")
 
-	itemTypes := []string{"Password", "Cyphered Note", "Card", "TOTP"}
-	activeItemType := 0 // 0=Password, 1=Note, 2=Card, 3=TOTP
+	sshKeyForm := newSSHKeyFormSection()
+
+	folderInput := widget.NewEntry()
+	folderInput.PlaceHolder = "e.g. Work/Dev (optional)"
+	tagsInput := widget.NewEntry()
+	tagsInput.PlaceHolder = "Comma-separated tags"
+	favoriteCheck := widget.NewCheck("Favorite", nil)
+
+	itemTypes := []string{"Password", "Cyphered Note", "Card", "TOTP", "SSH Key"}
+	activeItemType := 0 // 0=Password, 1=Note, 2=Card, 3=TOTP, 4=SSH Key
 
 	passwordSection := container.NewVBox(
 		theme.FieldLabel("SERVICE", nil),
@@ -162,6 +179,14 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 		theme.FieldLabel("PASSWORD", nil),
 		passwordInput,
 		strengthContainer,
+		theme.FieldLabel("LOGIN URL", nil),
+		loginURLInput,
+		theme.FieldLabel("NOTES", nil),
+		passwordNotesInput,
+		theme.FieldLabel("CUSTOM FIELDS", nil),
+		fieldsEditor.content,
+		theme.FieldLabel("BROWSER MATCHING", nil),
+		matchEditor.content,
 	)
 
 	noteSection := container.NewVBox(
@@ -200,6 +225,14 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 		),
 	)
 
+	organizationSection := container.NewVBox(
+		container.NewGridWithColumns(2,
+			container.NewVBox(theme.FieldLabel("FOLDER", nil), folderInput),
+			container.NewVBox(theme.FieldLabel("TAGS", nil), tagsInput),
+		),
+		favoriteCheck,
+	)
+
 	var formContent *fyne.Container
 	var typeTabs fyne.CanvasObject
 	var buildTypeTabs func()
@@ -210,6 +243,7 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 		noteSection.Hide()
 		cardSection.Hide()
 		totpSection.Hide()
+		sshKeyForm.content.Hide()
 
 		switch idx {
 		case 1:
@@ -218,6 +252,8 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 			cardSection.Show()
 		case 3:
 			totpSection.Show()
+		case 4:
+			sshKeyForm.content.Show()
 		default:
 			passwordSection.Show()
 		}
@@ -247,6 +283,10 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 		secret := passwordInput.Text
 		entryType := model.EntryTypePassword
 		cardSubtype := ""
+		folder := model.NormalizeFolderPath(folderInput.Text)
+		tags := model.NormalizeTags(splitTags(tagsInput.Text))
+		favorite := favoriteCheck.Checked
+		var match model.MatchRule
 
 		switch itemType {
 		case "Cyphered Note":
@@ -307,6 +347,21 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 			service = "TOTP:" + totpIssuerInput.Text
 			username = totpAccountInput.Text
 			secret = string(totpJSON)
+		case "SSH Key":
+			entryType = model.EntryTypeSSHKey
+			sshPayload, fingerprint, err := sshKeyForm.payload()
+			if err != nil {
+				widgets.ShowAppError(err, ns.window)
+				return
+			}
+			sshJSON, err := sshPayload.Marshal()
+			if err != nil {
+				widgets.ShowAppError(fmt.Errorf("failed to encode SSH key: %w", err), ns.window)
+				return
+			}
+			service = app.SSHKeyServicePrefix + strings.TrimSpace(sshKeyForm.name.Text)
+			username = fingerprint
+			secret = string(sshJSON)
 		default:
 			if secret == "" {
 				widgets.ShowAppError(fmt.Errorf("password cannot be empty"), ns.window)
@@ -316,12 +371,32 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 				widgets.ShowAppError(fmt.Errorf("service name cannot be empty"), ns.window)
 				return
 			}
+			rule, err := matchEditor.Rule()
+			if err != nil {
+				widgets.ShowAppError(err, ns.window)
+				return
+			}
+			match = rule
+			payload := model.NewPasswordPayload(secret)
+			payload.LoginURL = strings.TrimSpace(loginURLInput.Text)
+			payload.Notes = passwordNotesInput.Text
+			payload.Fields = fieldsEditor.Fields()
+			payloadJSON, err := payload.Marshal()
+			if err != nil {
+				widgets.ShowAppError(fmt.Errorf("failed to encode password: %w", err), ns.window)
+				return
+			}
+			secret = string(payloadJSON)
 		}
 
 		clearInputs := func() {
 			serviceInput.SetText("")
 			usernameInput.SetText("")
 			passwordInput.SetText("")
+			loginURLInput.SetText("")
+			passwordNotesInput.SetText("")
+			fieldsEditor.Reset()
+			matchEditor.Reset()
 			noteTitleInput.SetText("")
 			noteContentInput.SetText("")
 			cardNameInput.SetText("")
@@ -332,6 +407,10 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 			totpIssuerInput.SetText("")
 			totpAccountInput.SetText("")
 			totpSecretInput.SetText("")
+			sshKeyForm.reset()
+			folderInput.SetText("")
+			tagsInput.SetText("")
+			favoriteCheck.SetChecked(false)
 		}
 
 		// writeEntry encrypts the secret and either appends a new entry or
@@ -349,22 +428,7 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 				return
 			}
 
-			ct, ss, err := app.Encapsulate(ns.appState.PublicKey)
-			if err != nil {
-				fyne.Do(func() {
-					widgets.ShowAppError(fmt.Errorf("encapsulation failed: %v", err), ns.window)
-				})
-				return
-			}
-
-			nonce, ciphertext, err := app.EncryptAES256GCM(secret, ss)
-			if err != nil {
-				fyne.Do(func() {
-					widgets.ShowAppError(fmt.Errorf("encryption failed: %v", err), ns.window)
-				})
-				return
-			}
-
+			var sealErr error
 			if replaceExisting {
 				var target *model.VaultEntry
 				for _, e := range entries {
@@ -379,20 +443,33 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 					})
 					return
 				}
-				target.KyberCiphertext = ct
-				target.Nonce = nonce
-				target.Ciphertext = ciphertext
+				// A replaced password keeps its previous version in history.
+				if target.Type == model.EntryTypePassword {
+					target.ArchivePassword(time.Now().UTC())
+					target.Match = match
+				} else {
+					target.Touch(time.Now().UTC())
+				}
+				sealErr = app.SealEntrySecret(target, secret, ns.appState.PublicKey)
 			} else {
 				entry := model.NewVaultEntry()
-				entry.KyberCiphertext = ct
-				entry.Nonce = nonce
-				entry.Ciphertext = ciphertext
 				entry.Type = entryType
 				entry.CardSubtype = cardSubtype
 				entry.Service = service
 				entry.Username = username
+				entry.Folder = folder
+				entry.Tags = tags
+				entry.Favorite = favorite
+				entry.Match = match
+				sealErr = app.SealEntrySecret(entry, secret, ns.appState.PublicKey)
 				entries = append(entries, entry)
 			}
+			if sealErr != nil {
+				fyne.Do(func() {
+					widgets.ShowAppError(fmt.Errorf("encryption failed: %v", sealErr), ns.window)
+				})
+				return
+			}
 
 			if err := app.WriteVault(entries, vaultFile, ns.appState.MasterPassword); err != nil {
 				fyne.Do(func() {
@@ -455,6 +532,8 @@ func (ns *NavigationState) createPasswordsView() fyne.CanvasObject {
 		noteSection,
 		cardSection,
 		totpSection,
+		sshKeyForm.content,
+		organizationSection,
 	)
 
 	footer := theme.FormFooter("Encrypted on save: AES-256-GCM", cancelBtn, saveBtn)
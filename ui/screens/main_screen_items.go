@@ -12,6 +12,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 
 	"passquantum/app"
+	"passquantum/core/model"
 	"passquantum/theme"
 )
 
@@ -23,39 +24,67 @@ func (ns *NavigationState) createItemsView() fyne.CanvasObject {
 	countLabel := canvas.NewText("Loading…", theme.ColorTextSecondary)
 	countLabel.TextSize = 13
 
-	// Track all decrypted cards for filtering
-	type cardEntry struct {
-		service  string
-		username string
-		card     fyne.CanvasObject
-	}
-	var allCards []cardEntry
+	// Track all decrypted entries and their cards for filtering
+	var allEntries []*model.VaultEntry
+	cardsByID := make(map[uint64]fyne.CanvasObject)
 
 	itemsContainer := container.NewVBox()
 	loadingText := canvas.NewText("Loading vault items...", theme.ColorFg2)
 	loadingText.TextSize = 13
 	itemsContainer.Objects = []fyne.CanvasObject{container.NewCenter(loadingText)}
 
-	// Search bar
+	// Search bar and organization filters
 	searchEntry := widget.NewEntry()
 	searchEntry.PlaceHolder = "Search items…"
-	searchEntry.OnChanged = func(query string) {
-		q := strings.ToLower(query)
-		var filtered []fyne.CanvasObject
-		for _, ce := range allCards {
-			if q == "" || strings.Contains(strings.ToLower(ce.service), q) || strings.Contains(strings.ToLower(ce.username), q) {
-				filtered = append(filtered, ce.card)
-			}
+	folderSelect := widget.NewSelect([]string{allFoldersOption}, nil)
+	folderSelect.SetSelected(allFoldersOption)
+	tagSelect := widget.NewSelect([]string{allTagsOption}, nil)
+	tagSelect.SetSelected(allTagsOption)
+	favoritesCheck := widget.NewCheck("Favorites", nil)
+
+	applyFilter := func() {
+		filter := app.EntryFilter{
+			Query:             searchEntry.Text,
+			FavoritesOnly:     favoritesCheck.Checked,
+			IncludeSubfolders: true,
+		}
+		switch folderSelect.Selected {
+		case allFoldersOption, "":
+		case unfiledOption:
+			filter.Unfiled = true
+		default:
+			filter.Folder = folderSelect.Selected
 		}
-		if len(filtered) == 0 && query != "" {
-			noMatch := canvas.NewText("No items match “"+query+"”", theme.ColorFg2)
+		if tagSelect.Selected != allTagsOption && tagSelect.Selected != "" {
+			filter.Tags = []string{tagSelect.Selected}
+		}
+
+		matched := app.FilterEntries(allEntries, filter)
+		if len(matched) == 0 && len(allEntries) > 0 {
+			noMatch := canvas.NewText("No items match the current filters", theme.ColorFg2)
+			if searchEntry.Text != "" {
+				noMatch.Text = "No items match “" + searchEntry.Text + "”"
+			}
 			noMatch.TextSize = 13
 			itemsContainer.Objects = []fyne.CanvasObject{container.NewCenter(noMatch)}
-		} else {
-			itemsContainer.Objects = filtered
+			itemsContainer.Refresh()
+			return
+		}
+
+		var objects []fyne.CanvasObject
+		for _, group := range app.GroupByFolder(matched) {
+			objects = append(objects, folderGroupHeader(group))
+			for _, entry := range group.Entries {
+				objects = append(objects, cardsByID[entry.ID])
+			}
 		}
+		itemsContainer.Objects = objects
 		itemsContainer.Refresh()
 	}
+	searchEntry.OnChanged = func(string) { applyFilter() }
+	folderSelect.OnChanged = func(string) { applyFilter() }
+	tagSelect.OnChanged = func(string) { applyFilter() }
+	favoritesCheck.OnChanged = func(bool) { applyFilter() }
 
 	go func() {
 		ns.appState.Mu.Lock()
@@ -74,6 +103,7 @@ func (ns *NavigationState) createItemsView() fyne.CanvasObject {
 			})
 			return
 		}
+		entries = app.LiveEntries(entries)
 
 		fyne.Do(func() {
 			if len(entries) == 0 {
@@ -101,30 +131,24 @@ func (ns *NavigationState) createItemsView() fyne.CanvasObject {
 				return
 			}
 
-			allCards = nil
-			var cards []fyne.CanvasObject
+			allEntries = nil
 			for _, entry := range entries {
-				ss, err := app.Decapsulate(entry.KyberCiphertext, ns.appState.PrivateKey)
-				if err != nil {
-					continue
-				}
-				plaintext, err := app.DecryptAES256GCM(entry.Nonce, entry.Ciphertext, ss)
+				plaintext, err := app.OpenEntrySecret(entry, ns.appState.PrivateKey)
 				if err != nil {
 					continue
 				}
 				card := createVaultItemCard(0, entry, plaintext, ns.window, ns.app, ns.appState)
-				allCards = append(allCards, cardEntry{
-					service:  entry.Service,
-					username: entry.Username,
-					card:     card,
-				})
-				cards = append(cards, card)
+				allEntries = append(allEntries, entry)
+				cardsByID[entry.ID] = card
 			}
 
-			itemsContainer.Objects = cards
-			itemsContainer.Refresh()
+			folderSelect.Options = append([]string{allFoldersOption, unfiledOption}, app.FolderTree(allEntries)...)
+			folderSelect.Refresh()
+			tagSelect.Options = append([]string{allTagsOption}, app.AllTags(allEntries)...)
+			tagSelect.Refresh()
+			applyFilter()
 
-			n := len(allCards)
+			n := len(allEntries)
 			if n == 1 {
 				countLabel.Text = "1 item"
 			} else {
@@ -158,6 +182,26 @@ func (ns *NavigationState) createItemsView() fyne.CanvasObject {
 	searchBorder.StrokeColor = theme.ColorLine2
 	searchBorder.FillColor = color.Transparent
 	searchRow := container.NewStack(searchBg, searchBorder, container.NewPadded(searchEntry))
+	filterRow := container.NewHBox(folderSelect, tagSelect, favoritesCheck)
 
-	return container.NewVBox(header, searchRow, itemsContainer)
+	return container.NewVBox(header, searchRow, filterRow, itemsContainer)
+}
+
+const (
+	allFoldersOption = "All folders"
+	unfiledOption    = "Unfiled"
+	allTagsOption    = "All tags"
+)
+
+// folderGroupHeader labels a run of item cards with their folder path.
+func folderGroupHeader(group app.FolderGroup) fyne.CanvasObject {
+	label := group.Path
+	if label == "" {
+		label = unfiledOption
+	}
+	count := fmt.Sprintf("%d", len(group.Entries))
+	return container.NewHBox(
+		theme.SectionEyebrow(strings.ReplaceAll(label, model.FolderSeparator, " / ")),
+		theme.SectionEyebrow(count),
+	)
 }
@@ -7,6 +7,7 @@ import (
 	"log"
 	"math/big"
 	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -15,6 +16,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 
 	"passquantum/core/model"
+	"passquantum/core/urlmatch"
 	"passquantum/app"
 	"passquantum/theme"
 	"passquantum/ui/widgets"
@@ -55,6 +57,8 @@ func createVaultItemCard(index int, entry *model.VaultEntry, payload string, w f
 		return createCardDetailsCard(index, entry, payload, w, fyneApp, appState)
 	case model.EntryTypeTOTP:
 		return createTOTPItemCard(index, entry, payload, w, fyneApp, appState)
+	case model.EntryTypeSSHKey:
+		return createSSHKeyCard(index, entry, payload, w, fyneApp, appState)
 	}
 
 	if strings.HasPrefix(entry.Service, "NOTE:") {
@@ -95,6 +99,7 @@ func createNoteCard(index int, entry *model.VaultEntry, payload string, w fyne.W
 
 	badge := theme.KindBadge("Note")
 	titleRow := container.NewHBox(titleTxt, badge)
+	addOrganizationBadges(titleRow, entry)
 
 	noteLabel := canvas.NewText(preview, theme.ColorTextSecondary)
 	noteLabel.TextSize = 11
@@ -126,7 +131,7 @@ func createNoteCard(index int, entry *model.VaultEntry, payload string, w fyne.W
 	})
 
 	left := container.NewHBox(icon, container.NewVBox(titleRow, noteLabel))
-	buttons := container.NewHBox(viewBtn, copyBtn, deleteBtn)
+	buttons := container.NewHBox(viewBtn, copyBtn, organizeButton(entry, w, fyneApp, appState), deleteBtn)
 	row := container.NewBorder(nil, nil, left, buttons)
 
 	return theme.CardWithHeader("", "", nil, row)
@@ -164,6 +169,7 @@ func createCardDetailsCard(index int, entry *model.VaultEntry, payload string, w
 
 	badge := theme.KindBadge(strings.ToUpper(cp.Subtype) + " Card")
 	titleRow := container.NewHBox(titleTxt, badge)
+	addOrganizationBadges(titleRow, entry)
 
 	numberTxt := canvas.NewText("**** **** **** "+masked, theme.ColorTextSecondary)
 	numberTxt.TextSize = 11
@@ -198,13 +204,16 @@ func createCardDetailsCard(index int, entry *model.VaultEntry, payload string, w
 	})
 
 	left := container.NewHBox(icon, container.NewVBox(titleRow, container.NewVBox(numberTxt, holderTxt)))
-	buttons := container.NewHBox(showBtn, copyBtn, deleteBtn)
+	buttons := container.NewHBox(showBtn, copyBtn, organizeButton(entry, w, fyneApp, appState), deleteBtn)
 	row := container.NewBorder(nil, nil, left, buttons)
 
 	return theme.CardWithHeader("", "", nil, row)
 }
 
-func createPasswordCard(index int, entry *model.VaultEntry, password string, w fyne.Window, fyneApp fyne.App, appState *app.AppState) fyne.CanvasObject {
+func createPasswordCard(index int, entry *model.VaultEntry, plaintext string, w fyne.Window, fyneApp fyne.App, appState *app.AppState) fyne.CanvasObject {
+	payload := model.ParsePasswordPayload(plaintext)
+	password := payload.Password
+
 	icon := theme.TypeIcon(theme.IconKey, theme.ColorAccentCyan)
 
 	titleTxt := canvas.NewText(entry.Service, theme.ColorTextPrimary)
@@ -213,11 +222,19 @@ func createPasswordCard(index int, entry *model.VaultEntry, password string, w f
 
 	badge := theme.KindBadge("Password")
 	titleRow := container.NewHBox(titleTxt, badge)
+	addOrganizationBadges(titleRow, entry)
 
 	maskedPassword := canvas.NewText(entry.Username+" : ............", theme.ColorTextSecondary)
 	maskedPassword.TextSize = 11
 	maskedPassword.TextStyle = fyne.TextStyle{Monospace: true}
 
+	details := container.NewVBox(titleRow, maskedPassword)
+	if summary := passwordExtrasSummary(payload); summary != "" {
+		extrasTxt := canvas.NewText(summary, theme.ColorFg2)
+		extrasTxt.TextSize = 11
+		details.Add(extrasTxt)
+	}
+
 	showing := false
 	showBtn := theme.CreateSmallIconButton(theme.IconEye, func() {
 		if showing {
@@ -233,12 +250,24 @@ func createPasswordCard(index int, entry *model.VaultEntry, password string, w f
 	copyBtn := theme.CreateSmallIconButton(theme.IconCopy, func() {
 		w.Clipboard().SetContent(password)
 		widgets.ShowAppInformation("Copied", "Password copied to clipboard!", w)
+		go func(id uint64) {
+			if err := app.MarkEntryUsed(appState, id); err != nil {
+				log.Printf("[Vault] WARNING: could not record last use: %v", err)
+			}
+		}(entry.ID)
 	})
 
 	editBtn := theme.CreateSmallIconButton(theme.IconEdit, func() {
-		showEditPasswordDialog(entry, password, w, fyneApp, appState)
+		showEditPasswordDialog(entry, payload, w, fyneApp, appState)
 	})
 
+	buttons := container.NewHBox(showBtn, copyBtn, editBtn, organizeButton(entry, w, fyneApp, appState))
+	if len(entry.PasswordHistory) > 0 {
+		buttons.Add(theme.CreateSmallIconButton(theme.IconClock, func() {
+			showPasswordHistoryDialog(entry, w, fyneApp, appState)
+		}))
+	}
+
 	deleteBtn := theme.CreateSmallIconButton(theme.IconTrash, func() {
 		widgets.ShowAppConfirm("Delete", fmt.Sprintf("Delete password for %s?", entry.Service), func(ok bool) {
 			if ok {
@@ -247,14 +276,36 @@ func createPasswordCard(index int, entry *model.VaultEntry, password string, w f
 		}, w)
 	})
 
-	left := container.NewHBox(icon, container.NewVBox(titleRow, maskedPassword))
-	buttons := container.NewHBox(showBtn, copyBtn, editBtn, deleteBtn)
+	left := container.NewHBox(icon, details)
+	buttons.Add(deleteBtn)
 	row := container.NewBorder(nil, nil, left, buttons)
 
 	return theme.CardWithHeader("", "", nil, row)
 }
 
-func showEditPasswordDialog(entry *model.VaultEntry, password string, w fyne.Window, fyneApp fyne.App, appState *app.AppState) {
+// passwordExtrasSummary renders a one-line hint of what a password entry
+// carries besides the password, e.g. "github.com · notes · 2 fields".
+func passwordExtrasSummary(payload *model.PasswordPayload) string {
+	var parts []string
+	if urls := payload.AllURLs(); len(urls) > 0 {
+		parts = append(parts, urls[0])
+		if len(urls) > 1 {
+			parts = append(parts, fmt.Sprintf("+%d URLs", len(urls)-1))
+		}
+	}
+	if strings.TrimSpace(payload.Notes) != "" {
+		parts = append(parts, "notes")
+	}
+	switch n := len(payload.Fields); {
+	case n == 1:
+		parts = append(parts, "1 field")
+	case n > 1:
+		parts = append(parts, fmt.Sprintf("%d fields", n))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func showEditPasswordDialog(entry *model.VaultEntry, payload *model.PasswordPayload, w fyne.Window, fyneApp fyne.App, appState *app.AppState) {
 	serviceInput := widget.NewEntry()
 	serviceInput.SetText(entry.Service)
 
@@ -262,14 +313,34 @@ func showEditPasswordDialog(entry *model.VaultEntry, password string, w fyne.Win
 	usernameInput.SetText(entry.Username)
 
 	passwordInput := widget.NewPasswordEntry()
-	passwordInput.SetText(password)
+	passwordInput.SetText(payload.Password)
 	passwordStrengthBar := NewStrengthBar()
 	BindStrengthBar(passwordStrengthBar, passwordInput, func() []string {
 		return storedVaultPasswords(appState)
 	})
 
+	loginURLInput := widget.NewEntry()
+	loginURLInput.PlaceHolder = "https://example.com/login"
+	loginURLInput.SetText(payload.LoginURL)
+
+	urlsInput := widget.NewMultiLineEntry()
+	urlsInput.PlaceHolder = "One URL per line"
+	urlsInput.SetMinRowsVisible(2)
+	urlsInput.SetText(strings.Join(payload.URLs, "\n"))
+
+	notesInput := widget.NewMultiLineEntry()
+	notesInput.SetMinRowsVisible(3)
+	notesInput.SetText(payload.Notes)
+
+	fieldsEditor := newCustomFieldsEditor(payload.Fields)
+	matchEditor := newMatchRuleEditor(entry.Match)
+
+	timesTxt := canvas.NewText(entryTimesSummary(entry), theme.ColorFg2)
+	timesTxt.TextSize = 11
+
 	formContent := container.NewVBox(
 		theme.SectionEyebrow("EDIT PASSWORD"),
+		timesTxt,
 		theme.FieldLabel("SERVICE", nil),
 		serviceInput,
 		theme.FieldLabel("USERNAME", nil),
@@ -277,6 +348,16 @@ func showEditPasswordDialog(entry *model.VaultEntry, password string, w fyne.Win
 		theme.FieldLabel("PASSWORD", nil),
 		passwordInput,
 		passwordStrengthBar,
+		theme.FieldLabel("LOGIN URL", nil),
+		loginURLInput,
+		theme.FieldLabel("OTHER URLS", nil),
+		urlsInput,
+		theme.FieldLabel("BROWSER MATCHING", nil),
+		matchEditor.content,
+		theme.FieldLabel("NOTES", nil),
+		notesInput,
+		theme.FieldLabel("CUSTOM FIELDS", nil),
+		fieldsEditor.content,
 	)
 
 	var customDialog *dialog.CustomDialog
@@ -284,7 +365,18 @@ func showEditPasswordDialog(entry *model.VaultEntry, password string, w fyne.Win
 	saveBtn := theme.CreatePrimaryButton("Save changes", func() {
 		newService := serviceInput.Text
 		newUsername := usernameInput.Text
-		newPassword := passwordInput.Text
+		matchRule, err := matchEditor.Rule()
+		if err != nil {
+			widgets.ShowAppError(err, w)
+			return
+		}
+
+		updatedPayload := *payload
+		updatedPayload.Password = passwordInput.Text
+		updatedPayload.LoginURL = strings.TrimSpace(loginURLInput.Text)
+		updatedPayload.URLs = splitLines(urlsInput.Text)
+		updatedPayload.Notes = notesInput.Text
+		updatedPayload.Fields = fieldsEditor.Fields()
 
 		go func(id uint64) {
 			appState.Mu.Lock()
@@ -302,28 +394,19 @@ func showEditPasswordDialog(entry *model.VaultEntry, password string, w fyne.Win
 			updated := false
 			for _, e := range entries {
 				if e.ID == id {
-					e.Service = newService
-					e.Username = newUsername
-					if newPassword != password {
-						ct, ss, cerr := app.Encapsulate(appState.PublicKey)
-						if cerr != nil {
-							fyne.Do(func() {
-								widgets.ShowAppError(fmt.Errorf("encapsulation failed: %v", cerr), w)
-							})
-							return
-						}
-
-						nonce, ciphertext, cerr := app.EncryptAES256GCM(newPassword, ss)
-						if cerr != nil {
-							fyne.Do(func() {
-								widgets.ShowAppError(fmt.Errorf("encryption failed: %v", cerr), w)
-							})
-							return
-						}
-
-						e.KyberCiphertext = ct
-						e.Nonce = nonce
-						e.Ciphertext = ciphertext
+					err := app.UpdateEntryMetadata(e, func(e *model.VaultEntry) {
+						e.Service = newService
+						e.Username = newUsername
+					}, appState.PublicKey, appState.PrivateKey)
+					if err == nil {
+						e.Match = matchRule
+						err = app.ReplacePasswordPayload(e, &updatedPayload, appState.PublicKey, appState.PrivateKey)
+					}
+					if err != nil {
+						fyne.Do(func() {
+							widgets.ShowAppError(err, w)
+						})
+						return
 					}
 					updated = true
 					break
@@ -363,33 +446,371 @@ func showEditPasswordDialog(entry *model.VaultEntry, password string, w fyne.Win
 
 	buttonBox := container.NewHBox(cancelBtn, saveBtn)
 	dialogContent := container.NewVBox(formContent, container.NewCenter(buttonBox))
-	customDialog = dialog.NewCustom("Edit Password", "Close", dialogContent, w)
+	customDialog = dialog.NewCustom("Edit Password", "Close", container.NewVScroll(dialogContent), w)
+	customDialog.Resize(fyne.NewSize(520, 640))
 	customDialog.Show()
 }
 
-func deleteEntryByID(entryID uint64, entryKind string, w fyne.Window, fyneApp fyne.App, appState *app.AppState) {
-	go func(id uint64) {
-		appState.Mu.Lock()
-		defer appState.Mu.Unlock()
+// addOrganizationBadges appends a favorite marker and the entry's tags to a
+// card title row.
+func addOrganizationBadges(titleRow *fyne.Container, entry *model.VaultEntry) {
+	if entry.Favorite {
+		titleRow.Add(theme.KindBadge("Favorite"))
+	}
+	for _, tag := range entry.Tags {
+		titleRow.Add(theme.KindBadge("#" + tag))
+	}
+}
 
-		vaultFile := app.GetVaultPath(appState.CurrentVault)
-		entries, err := app.ReadVault(vaultFile, appState.MasterPassword)
-		if err != nil {
+// organizeButton opens the folder/tags/favorite editor for any entry type.
+func organizeButton(entry *model.VaultEntry, w fyne.Window, fyneApp fyne.App, appState *app.AppState) fyne.CanvasObject {
+	return theme.CreateSmallIconButton(theme.IconFolder, func() {
+		showOrganizeDialog(entry, w, fyneApp, appState)
+	})
+}
+
+func showOrganizeDialog(entry *model.VaultEntry, w fyne.Window, fyneApp fyne.App, appState *app.AppState) {
+	folderInput := widget.NewEntry()
+	folderInput.PlaceHolder = "e.g. Work/Dev"
+	folderInput.SetText(entry.Folder)
+
+	tagsInput := widget.NewEntry()
+	tagsInput.PlaceHolder = "Comma-separated tags"
+	tagsInput.SetText(strings.Join(entry.Tags, ", "))
+
+	favoriteCheck := widget.NewCheck("Favorite", nil)
+	favoriteCheck.SetChecked(entry.Favorite)
+
+	content := container.NewVBox(
+		theme.FieldLabel("FOLDER", nil),
+		folderInput,
+		theme.FieldLabel("TAGS", nil),
+		tagsInput,
+		favoriteCheck,
+	)
+
+	d := dialog.NewCustomConfirm("Organize", "Save", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		folder := folderInput.Text
+		tags := splitTags(tagsInput.Text)
+		favorite := favoriteCheck.Checked
+		go func(id uint64) {
+			if err := app.SetEntryOrganization(appState, id, folder, tags, favorite); err != nil {
+				fyne.Do(func() {
+					widgets.ShowAppError(fmt.Errorf("failed to update item: %w", err), w)
+				})
+				return
+			}
 			fyne.Do(func() {
-				widgets.ShowAppError(fmt.Errorf("failed to read vault: %w", err), w)
+				ShowMainScreen(w, fyneApp, appState)
 			})
-			return
+		}(entry.ID)
+	}, w)
+	d.Resize(fyne.NewSize(420, 320))
+	d.Show()
+}
+
+// entryTimesSummary renders the entry's timestamps for display, omitting
+// those that are unknown (entries created before timestamps were stored).
+func entryTimesSummary(entry *model.VaultEntry) string {
+	var parts []string
+	if !entry.Created.IsZero() {
+		parts = append(parts, "Created "+formatEntryTime(entry.Created))
+	}
+	if !entry.Modified.IsZero() {
+		parts = append(parts, "Modified "+formatEntryTime(entry.Modified))
+	}
+	if !entry.LastUsed.IsZero() {
+		parts = append(parts, "Last used "+formatEntryTime(entry.LastUsed))
+	}
+	if len(parts) == 0 {
+		return "No history recorded yet"
+	}
+	return strings.Join(parts, " · ")
+}
+
+func formatEntryTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// showPasswordHistoryDialog lists the archived versions of a password entry,
+// newest first. Each can be revealed, copied or restored as the current
+// password; restoring archives the password it replaces.
+func showPasswordHistoryDialog(entry *model.VaultEntry, w fyne.Window, fyneApp fyne.App, appState *app.AppState) {
+	var customDialog *dialog.CustomDialog
+	rows := container.NewVBox(theme.SectionEyebrow("PASSWORD HISTORY"))
+
+	for i := range entry.PasswordHistory {
+		h := &entry.PasswordHistory[i]
+		index := i
+
+		oldPassword := ""
+		if payload, err := app.OpenPasswordHistory(entry, index, appState.PrivateKey); err == nil {
+			oldPassword = payload.Password
+		} else {
+			log.Printf("[Vault] WARNING: cannot decrypt history version %d of %q: %v", index, entry.Service, err)
 		}
 
-		newEntries := make([]*model.VaultEntry, 0, len(entries))
-		for _, e := range entries {
-			if e.ID != id {
-				newEntries = append(newEntries, e)
+		when := canvas.NewText("Replaced "+formatEntryTime(h.ChangedAt), theme.ColorTextSecondary)
+		when.TextSize = 11
+		value := canvas.NewText("............", theme.ColorTextPrimary)
+		value.TextSize = 11
+		value.TextStyle = fyne.TextStyle{Monospace: true}
+
+		showing := false
+		showBtn := theme.CreateSmallIconButton(theme.IconEye, func() {
+			showing = !showing
+			if showing {
+				value.Text = oldPassword
+			} else {
+				value.Text = "............"
 			}
+			value.Refresh()
+		})
+		copyBtn := theme.CreateSmallIconButton(theme.IconCopy, func() {
+			w.Clipboard().SetContent(oldPassword)
+			widgets.ShowAppInformation("Copied", "Previous password copied to clipboard", w)
+		})
+		restoreBtn := theme.CreateGhostButton("Restore", func() {
+			widgets.ShowAppConfirm("Restore password",
+				fmt.Sprintf("Make this the current password for %s? The current password will be kept in history.", entry.Service),
+				func(ok bool) {
+					if !ok {
+						return
+					}
+					go restorePasswordVersion(entry.ID, index, func() {
+						if customDialog != nil {
+							customDialog.Hide()
+						}
+					}, w, fyneApp, appState)
+				}, w)
+		})
+
+		rows.Add(container.NewBorder(nil, nil,
+			container.NewVBox(when, value),
+			container.NewHBox(showBtn, copyBtn, restoreBtn),
+		))
+	}
+
+	customDialog = dialog.NewCustom("History — "+entry.Service, "Close", container.NewVScroll(rows), w)
+	customDialog.Resize(fyne.NewSize(480, 420))
+	customDialog.Show()
+}
+
+func restorePasswordVersion(entryID uint64, index int, onDone func(), w fyne.Window, fyneApp fyne.App, appState *app.AppState) {
+	appState.Mu.Lock()
+	defer appState.Mu.Unlock()
+
+	vaultFile := app.GetVaultPath(appState.CurrentVault)
+	entries, err := app.ReadVault(vaultFile, appState.MasterPassword)
+	if err != nil {
+		fyne.Do(func() {
+			widgets.ShowAppError(fmt.Errorf("failed to read vault: %w", err), w)
+		})
+		return
+	}
+
+	var target *model.VaultEntry
+	for _, e := range entries {
+		if e.ID == entryID {
+			target = e
+			break
 		}
+	}
+	if target == nil {
+		fyne.Do(func() {
+			widgets.ShowAppError(fmt.Errorf("entry not found"), w)
+		})
+		return
+	}
 
-		err = app.WriteVault(newEntries, vaultFile, appState.MasterPassword)
-		if err != nil {
+	if err := app.RestorePasswordVersion(target, index, appState.PublicKey, appState.PrivateKey); err != nil {
+		fyne.Do(func() {
+			widgets.ShowAppError(fmt.Errorf("failed to restore password: %w", err), w)
+		})
+		return
+	}
+	if err := app.WriteVault(entries, vaultFile, appState.MasterPassword); err != nil {
+		fyne.Do(func() {
+			widgets.ShowAppError(fmt.Errorf("failed to save vault: %w", err), w)
+		})
+		return
+	}
+
+	fyne.Do(func() {
+		onDone()
+		widgets.ShowAppInformation("Restored", "Previous password restored", w)
+		ShowMainScreen(w, fyneApp, appState)
+	})
+}
+
+// customFieldsEditor is an editable list of custom field rows. Hidden
+// fields use a password entry so their value stays masked until revealed.
+type customFieldsEditor struct {
+	rows    []*customFieldRow
+	list    *fyne.Container
+	content fyne.CanvasObject
+}
+
+type customFieldRow struct {
+	name   *widget.Entry
+	value  *widget.Entry
+	hidden *widget.Check
+	line   *fyne.Container
+}
+
+func newCustomFieldsEditor(initial []model.CustomField) *customFieldsEditor {
+	ed := &customFieldsEditor{list: container.NewVBox()}
+	for _, f := range initial {
+		ed.addRow(f)
+	}
+	addBtn := theme.CreateGhostButton("Add field", func() {
+		ed.addRow(model.CustomField{})
+	})
+	ed.content = container.NewVBox(ed.list, container.NewHBox(addBtn))
+	return ed
+}
+
+func (ed *customFieldsEditor) addRow(f model.CustomField) {
+	r := &customFieldRow{name: widget.NewEntry(), hidden: widget.NewCheck("Hidden", nil)}
+	r.name.PlaceHolder = "Name"
+	r.name.SetText(f.Name)
+	if f.Hidden {
+		r.value = widget.NewPasswordEntry()
+	} else {
+		r.value = widget.NewEntry()
+	}
+	r.value.PlaceHolder = "Value"
+	r.value.SetText(f.Value)
+	r.hidden.SetChecked(f.Hidden)
+	r.hidden.OnChanged = func(hidden bool) {
+		r.value.Password = hidden
+		r.value.Refresh()
+	}
+
+	removeBtn := theme.CreateSmallIconButton(theme.IconTrash, func() {
+		ed.removeRow(r)
+	})
+	r.line = container.NewBorder(nil, nil, nil,
+		container.NewHBox(r.hidden, removeBtn),
+		container.NewGridWithColumns(2, r.name, r.value),
+	)
+	ed.rows = append(ed.rows, r)
+	ed.list.Add(r.line)
+}
+
+func (ed *customFieldsEditor) removeRow(r *customFieldRow) {
+	for i, existing := range ed.rows {
+		if existing == r {
+			ed.rows = append(ed.rows[:i], ed.rows[i+1:]...)
+			break
+		}
+	}
+	ed.list.Remove(r.line)
+}
+
+// Fields returns the current rows, dropping those without a name.
+func (ed *customFieldsEditor) Fields() []model.CustomField {
+	var out []model.CustomField
+	for _, r := range ed.rows {
+		name := strings.TrimSpace(r.name.Text)
+		if name == "" {
+			continue
+		}
+		out = append(out, model.CustomField{Name: name, Value: r.value.Text, Hidden: r.hidden.Checked})
+	}
+	return out
+}
+
+// Reset removes every row.
+func (ed *customFieldsEditor) Reset() {
+	ed.rows = nil
+	ed.list.Objects = nil
+	ed.list.Refresh()
+}
+
+// matchModeLabels names the match modes in model.MatchModes order.
+var matchModeLabels = []string{
+	"Base domain (default)",
+	"Exact host",
+	"Host and port",
+	"URL starts with",
+	"Regular expression",
+	"Never",
+}
+
+// matchRuleEditor picks when the browser extension offers a login: a mode
+// and, where the mode takes one, the URL or regular expression it uses.
+type matchRuleEditor struct {
+	mode    *widget.Select
+	pattern *widget.Entry
+	content fyne.CanvasObject
+}
+
+func newMatchRuleEditor(rule model.MatchRule) *matchRuleEditor {
+	ed := &matchRuleEditor{mode: widget.NewSelect(matchModeLabels, nil), pattern: widget.NewEntry()}
+	ed.pattern.PlaceHolder = "URL, prefix or regex (optional for domain and host)"
+	ed.pattern.SetText(rule.Pattern)
+	ed.mode.OnChanged = func(string) {
+		if ed.selected() == model.MatchNever {
+			ed.pattern.Disable()
+		} else {
+			ed.pattern.Enable()
+		}
+	}
+	ed.mode.SetSelectedIndex(int(rule.Mode))
+	ed.content = container.NewVBox(ed.mode, ed.pattern)
+	return ed
+}
+
+func (ed *matchRuleEditor) selected() model.MatchMode {
+	if i := ed.mode.SelectedIndex(); i >= 0 {
+		return model.MatchModes[i]
+	}
+	return model.MatchBaseDomain
+}
+
+// Rule returns the chosen rule, or an error if its pattern cannot match.
+func (ed *matchRuleEditor) Rule() (model.MatchRule, error) {
+	rule := model.MatchRule{Mode: ed.selected(), Pattern: strings.TrimSpace(ed.pattern.Text)}
+	if rule.Mode == model.MatchNever {
+		rule.Pattern = ""
+	}
+	if err := urlmatch.ValidateRule(rule); err != nil {
+		return model.MatchRule{}, fmt.Errorf("browser matching: %w", err)
+	}
+	return rule, nil
+}
+
+// Reset goes back to the default base-domain match.
+func (ed *matchRuleEditor) Reset() {
+	ed.mode.SetSelectedIndex(0)
+	ed.pattern.SetText("")
+}
+
+// splitLines returns the non-blank, trimmed lines of a multi-line entry.
+func splitLines(text string) []string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// splitTags splits a comma-separated tag list as typed into a form.
+func splitTags(text string) []string {
+	return strings.Split(text, ",")
+}
+
+// deleteEntryByID moves an entry to the trash. It can be restored or
+// permanently deleted from the Trash view until the purge period runs out.
+func deleteEntryByID(entryID uint64, entryKind string, w fyne.Window, fyneApp fyne.App, appState *app.AppState) {
+	go func(id uint64) {
+		if err := app.TrashEntry(appState, id); err != nil {
 			fyne.Do(func() {
 				widgets.ShowAppError(fmt.Errorf("failed to delete %s: %w", entryKind, err), w)
 			})
@@ -397,7 +818,7 @@ func deleteEntryByID(entryID uint64, entryKind string, w fyne.Window, fyneApp fy
 		}
 
 		fyne.Do(func() {
-			widgets.ShowAppInformation("Deleted", capitalizeWord(entryKind)+" deleted successfully", w)
+			widgets.ShowAppInformation("Moved to trash", capitalizeWord(entryKind)+" moved to the trash", w)
 			ShowMainScreen(w, fyneApp, appState)
 		})
 	}(entryID)
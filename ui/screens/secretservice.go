@@ -0,0 +1,64 @@
+package screens
+
+import (
+	"fmt"
+	"log"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"passquantum/app"
+	"passquantum/theme"
+	"passquantum/ui/widgets"
+)
+
+// PrefSecretServiceEnabled makes PassQuantum the desktop's Secret Service
+// provider (Linux only).
+const PrefSecretServiceEnabled = "pref_secret_service_enabled"
+
+// StartSecretServiceIfEnabled claims org.freedesktop.secrets at launch when
+// the user turned it on. The service runs for the app's lifetime and only
+// reports its collections as locked or unlocked.
+func StartSecretServiceIfEnabled(fyneApp fyne.App, appState *app.AppState) {
+	if appState.SecretService == nil || !fyneApp.Preferences().Bool(PrefSecretServiceEnabled) {
+		return
+	}
+	if err := appState.SecretService.Start(); err != nil {
+		log.Printf("[SecretService] WARNING: could not start: %v", err)
+	}
+}
+
+// buildSecretServiceCard is the settings card that switches the provider
+// on or off.
+func buildSecretServiceCard(w fyne.Window, fyneApp fyne.App, appState *app.AppState) fyne.CanvasObject {
+	if appState.SecretService == nil {
+		return theme.CardWithHeader("SECRET SERVICE", "Desktop keyring", nil,
+			theme.MonoText("The freedesktop Secret Service is only available on Linux.", 11, theme.ColorFg2))
+	}
+
+	prefs := fyneApp.Preferences()
+	enabledCheck := widget.NewCheck("Act as the desktop keyring (org.freedesktop.secrets)", nil)
+	enabledCheck.SetChecked(prefs.Bool(PrefSecretServiceEnabled))
+	enabledCheck.OnChanged = func(on bool) {
+		if !on {
+			prefs.SetBool(PrefSecretServiceEnabled, false)
+			appState.SecretService.Stop()
+			return
+		}
+		if err := appState.SecretService.Start(); err != nil {
+			enabledCheck.SetChecked(false)
+			widgets.ShowAppError(fmt.Errorf("could not become the Secret Service provider: %w\n\nStop gnome-keyring or KWallet's Secret Service first", err), w)
+			return
+		}
+		prefs.SetBool(PrefSecretServiceEnabled, true)
+	}
+
+	return theme.CardWithHeader("SECRET SERVICE", "Desktop keyring", nil,
+		container.NewVBox(
+			enabledCheck,
+			theme.MonoText("libsecret apps, NetworkManager and IDEs store their passwords in your vaults.", 11, theme.ColorFg2),
+			theme.MonoText("Each vault is a collection; all of them lock when the app locks.", 11, theme.ColorFg2),
+		),
+	)
+}
@@ -14,6 +14,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"passquantum/app"
 	"passquantum/bridge"
@@ -231,7 +232,14 @@ func buildSecuritySettings(w fyne.Window, fyneApp fyne.App, appState *app.AppSta
 
 	visualizerCard := theme.CardWithHeader("FACE DETECTION", "Detection visualizer", nil, visualizerBody)
 
-	return container.NewVBox(masterPwCard, guardCard, visualizerCard)
+	lockPolicyCard := buildLockPolicyCard(w, appState)
+	keyRotationCard := buildKeyRotationCard(w, appState)
+	kdfCard := buildKDFCard(w, appState)
+	sshAgentCard := buildSSHAgentCard(w, fyneApp, appState)
+	secretServiceCard := buildSecretServiceCard(w, fyneApp, appState)
+	browserClientsCard := buildBrowserClientsCard(w)
+
+	return container.NewVBox(masterPwCard, lockPolicyCard, keyRotationCard, kdfCard, sshAgentCard, secretServiceCard, browserClientsCard, guardCard, visualizerCard)
 }
 
 // showFaceVisualizerDialog opens a modal showing the live camera feed annotated
@@ -324,8 +332,16 @@ func buildVaultSettings(w fyne.Window, fyneApp fyne.App, appState *app.AppState)
 		widgets.ShowAppInformation("Import", "Select backup file to import", w)
 	})
 	backupNowBtn := theme.CreateDefaultButton("Back up now", func() {
-		widgets.ShowAppInformation("Backup", "Vault backup created successfully!", w)
+		if err := app.SnapshotCurrentVault(appState); err != nil {
+			widgets.ShowAppError(fmt.Errorf("backup failed: %w", err), w)
+			return
+		}
+		widgets.ShowAppInformation("Backup", "A snapshot of the vault was saved.", w)
 	})
+	snapshotsBtn := theme.CreateDefaultButton("Snapshots", func() {
+		showSnapshotsDialog(w, appState)
+	})
+	keepCountSelect, keepAgeSelect := buildSnapshotRetentionSelects(fyneApp.Preferences())
 
 	backupCard := theme.CardWithHeader("BACKUP", "Backup & restore", nil,
 		container.NewVBox(
@@ -334,8 +350,12 @@ func buildVaultSettings(w fyne.Window, fyneApp fyne.App, appState *app.AppState)
 				container.NewVBox(theme.SectionEyebrow("IMPORT"), importBtn),
 			),
 			container.NewBorder(nil, nil,
-				theme.MonoText("Auto-backup on vault changes.", 11, theme.ColorFg2),
-				backupNowBtn,
+				theme.MonoText("Encrypted snapshot kept on every vault change.", 11, theme.ColorFg2),
+				container.NewHBox(snapshotsBtn, backupNowBtn),
+			),
+			container.NewGridWithColumns(2,
+				container.NewVBox(theme.SectionEyebrow("KEEP"), keepCountSelect),
+				container.NewVBox(theme.SectionEyebrow("FOR"), keepAgeSelect),
 			),
 		),
 	)
@@ -377,7 +397,33 @@ func buildVaultSettings(w fyne.Window, fyneApp fyne.App, appState *app.AppState)
 		),
 	)
 
-	return container.NewVBox(vaultInfoCard, compactCard, backupCard, fileVaultCard)
+	retentionLabels := make([]string, len(trashRetentionChoices))
+	for i, c := range trashRetentionChoices {
+		retentionLabels[i] = c.label
+	}
+	retentionSelect := widget.NewSelect(retentionLabels, func(s string) {
+		for _, c := range trashRetentionChoices {
+			if c.label == s {
+				prefs.SetInt(PrefTrashRetentionDays, c.days)
+				appState.TrashRetention = time.Duration(c.days) * 24 * time.Hour
+			}
+		}
+	})
+	currentDays := prefs.IntWithFallback(PrefTrashRetentionDays, defaultTrashRetentionDays)
+	for _, c := range trashRetentionChoices {
+		if c.days == currentDays {
+			retentionSelect.SetSelected(c.label)
+		}
+	}
+
+	trashCard := theme.CardWithHeader("TRASH", "Purge deleted items", nil,
+		container.NewBorder(nil, nil,
+			theme.MonoText("Trashed items older than this are removed when a vault opens.", 11, theme.ColorFg2),
+			retentionSelect,
+		),
+	)
+
+	return container.NewVBox(vaultInfoCard, compactCard, backupCard, fileVaultCard, trashCard)
 }
 
 func buildDisplaySettings(w fyne.Window, fyneApp fyne.App, appState *app.AppState) *fyne.Container {
@@ -525,7 +571,8 @@ func buildAboutSettings(w fyne.Window, fyneApp fyne.App, appState *app.AppState)
 	securityCard := theme.CardWithHeader("CRYPTOGRAPHY", "Security stack", nil,
 		theme.KeyValueTable([]theme.KVItem{
 			{Key: "Bulk encryption", Value: "AES-256-GCM", Detail: "Symmetric — each entry encrypted independently with a unique key"},
-			{Key: "Key encapsulation", Value: "ML-KEM-768 (Kyber)", Detail: "Post-quantum — resists attacks from quantum computers (NIST FIPS 203)"},
+			{Key: "Key encapsulation", Value: app.KeypairAlgorithm(appState), Detail: "Post-quantum (NIST FIPS 203); X-Wing adds X25519, so entries hold unless both are broken"},
+			{Key: "Vault signature", Value: "ML-DSA-65", Detail: "Post-quantum — any change to a vault file is rejected before decryption (NIST FIPS 204)"},
 			{Key: "Password KDF", Value: "Argon2id", Detail: "Memory-hard stretching — intentionally slow to brute-force"},
 			{Key: "Authentication", Value: "HMAC-SHA-512", Detail: "Tamper detection — any modification to ciphertext is detected"},
 			{Key: "Random source", Value: "crypto/rand", Detail: "Hardware-seeded — OS kernel getrandom(2), not math/rand"},
@@ -0,0 +1,238 @@
+package screens
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"passquantum/app"
+	"passquantum/core/model"
+	"passquantum/core/storage"
+	"passquantum/theme"
+	"passquantum/ui/widgets"
+)
+
+// Snapshot retention preferences; 0 turns the limit off.
+const (
+	PrefSnapshotKeepCount = "pref_snapshot_keep_count"
+	PrefSnapshotKeepDays  = "pref_snapshot_keep_days"
+)
+
+var snapshotCountChoices = []struct {
+	label string
+	count int
+}{
+	{"Keep 10", 10},
+	{"Keep 20", 20},
+	{"Keep 50", 50},
+	{"Keep all", 0},
+}
+
+var snapshotAgeChoices = []struct {
+	label string
+	days  int
+}{
+	{"7 days", 7},
+	{"30 days", 30},
+	{"90 days", 90},
+	{"Forever", 0},
+}
+
+// LoadSnapshotRetention reads the configured snapshot limits.
+func LoadSnapshotRetention(prefs fyne.Preferences) storage.SnapshotRetention {
+	def := storage.DefaultSnapshotRetention
+	count := prefs.IntWithFallback(PrefSnapshotKeepCount, def.MaxCount)
+	days := prefs.IntWithFallback(PrefSnapshotKeepDays, int(def.MaxAge/(24*time.Hour)))
+	return storage.SnapshotRetention{
+		MaxCount: max(count, 0),
+		MaxAge:   time.Duration(max(days, 0)) * 24 * time.Hour,
+	}
+}
+
+// buildSnapshotRetentionSelects returns the two retention pickers shown on
+// the backup card.
+func buildSnapshotRetentionSelects(prefs fyne.Preferences) (fyne.CanvasObject, fyne.CanvasObject) {
+	apply := func() { storage.SetSnapshotRetention(LoadSnapshotRetention(prefs)) }
+	current := LoadSnapshotRetention(prefs)
+
+	countLabels := make([]string, len(snapshotCountChoices))
+	for i, c := range snapshotCountChoices {
+		countLabels[i] = c.label
+	}
+	countSelect := widget.NewSelect(countLabels, func(s string) {
+		for _, c := range snapshotCountChoices {
+			if c.label == s {
+				prefs.SetInt(PrefSnapshotKeepCount, c.count)
+				apply()
+			}
+		}
+	})
+	for _, c := range snapshotCountChoices {
+		if c.count == current.MaxCount {
+			countSelect.SetSelected(c.label)
+		}
+	}
+
+	ageLabels := make([]string, len(snapshotAgeChoices))
+	for i, c := range snapshotAgeChoices {
+		ageLabels[i] = c.label
+	}
+	ageSelect := widget.NewSelect(ageLabels, func(s string) {
+		for _, c := range snapshotAgeChoices {
+			if c.label == s {
+				prefs.SetInt(PrefSnapshotKeepDays, c.days)
+				apply()
+			}
+		}
+	})
+	for _, c := range snapshotAgeChoices {
+		if time.Duration(c.days)*24*time.Hour == current.MaxAge {
+			ageSelect.SetSelected(c.label)
+		}
+	}
+	return countSelect, ageSelect
+}
+
+// showSnapshotsDialog lists the open vault's snapshots with their entry
+// counts and offers whole-vault or per-entry restore.
+func showSnapshotsDialog(w fyne.Window, appState *app.AppState) {
+	snapshots, err := app.ListVaultSnapshots(appState)
+	if err != nil {
+		widgets.ShowAppError(fmt.Errorf("failed to list snapshots: %w", err), w)
+		return
+	}
+	if len(snapshots) == 0 {
+		widgets.ShowAppInformation("Snapshots", "No snapshots yet. One is taken every time the vault is saved.", w)
+		return
+	}
+
+	var d dialog.Dialog
+	rows := container.NewVBox()
+	countTexts := make([]*canvas.Text, len(snapshots))
+	for i, snap := range snapshots {
+		countTxt := canvas.NewText("decrypting…", theme.ColorFg2)
+		countTxt.TextSize = 11
+		countTxt.TextStyle = fyne.TextStyle{Monospace: true}
+		countTexts[i] = countTxt
+		entriesBtn := theme.CreateSmallIconButton(theme.IconEye, func() {
+			d.Hide()
+			showSnapshotEntriesDialog(w, appState, snap)
+		})
+		restoreBtn := theme.CreateDefaultButton("Restore", func() {
+			confirmVaultRestore(w, appState, snap, func() { d.Hide() })
+		})
+
+		rows.Add(container.NewBorder(nil, nil,
+			container.NewVBox(
+				theme.MonoText(snap.Created.Local().Format("2006-01-02 15:04:05"), 12, theme.ColorTextPrimary),
+				countTxt,
+			),
+			container.NewHBox(entriesBtn, restoreBtn),
+		))
+	}
+
+	// Each snapshot needs a full Argon2id derivation; count entries one at
+	// a time in the background so the list opens immediately.
+	var closed atomic.Bool
+	go func() {
+		for i, snap := range snapshots {
+			if closed.Load() {
+				return
+			}
+			entries, err := app.OpenVaultSnapshot(appState, snap)
+			countTxt := countTexts[i]
+			fyne.Do(func() {
+				if err != nil {
+					countTxt.Text = "cannot decrypt: " + err.Error()
+				} else {
+					countTxt.Text = fmt.Sprintf("%d entries · %d KB", len(entries), (snap.Size+1023)/1024)
+				}
+				countTxt.Refresh()
+			})
+		}
+	}()
+
+	d = dialog.NewCustom("Vault snapshots — "+appState.CurrentVault, "Close", container.NewVScroll(rows), w)
+	d.SetOnClosed(func() { closed.Store(true) })
+	d.Resize(fyne.NewSize(560, 520))
+	d.Show()
+}
+
+func confirmVaultRestore(w fyne.Window, appState *app.AppState, snap storage.Snapshot, onDone func()) {
+	msg := fmt.Sprintf("Replace every entry in '%s' with the snapshot from %s?\n\nThe current contents are kept as a new snapshot.",
+		appState.CurrentVault, snap.Created.Local().Format("2006-01-02 15:04"))
+	widgets.ShowAppConfirm("Restore vault", msg, func(ok bool) {
+		if !ok {
+			return
+		}
+		go func() {
+			err := app.RestoreVaultSnapshot(appState, snap)
+			fyne.Do(func() {
+				if err != nil {
+					widgets.ShowAppError(fmt.Errorf("restore failed: %w", err), w)
+					return
+				}
+				onDone()
+				widgets.ShowAppInformation("Restored", "The vault was restored from the snapshot.", w)
+			})
+		}()
+	}, w)
+}
+
+// showSnapshotEntriesDialog lets the user pick entries from one snapshot to
+// copy back into the open vault.
+func showSnapshotEntriesDialog(w fyne.Window, appState *app.AppState, snap storage.Snapshot) {
+	entries, err := app.OpenVaultSnapshot(appState, snap)
+	if err != nil {
+		widgets.ShowAppError(fmt.Errorf("failed to open snapshot: %w", err), w)
+		return
+	}
+
+	selected := map[uint64]bool{}
+	list := container.NewVBox()
+	for _, e := range entries {
+		label := app.EntryName(e)
+		if e.Username != "" && e.Type == model.EntryTypePassword {
+			label += " — " + e.Username
+		}
+		if e.Deleted {
+			label += " (in trash)"
+		}
+		id := e.ID
+		list.Add(widget.NewCheck(label, func(on bool) { selected[id] = on }))
+	}
+
+	d := dialog.NewCustomConfirm("Restore entries from "+snap.Created.Local().Format("2006-01-02 15:04"),
+		"Restore selected", "Cancel", container.NewVScroll(list), func(ok bool) {
+			if !ok {
+				return
+			}
+			var ids []uint64
+			for id, on := range selected {
+				if on {
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) == 0 {
+				return
+			}
+			go func() {
+				n, err := app.RestoreSnapshotEntries(appState, snap, ids)
+				fyne.Do(func() {
+					if err != nil {
+						widgets.ShowAppError(fmt.Errorf("restore failed: %w", err), w)
+						return
+					}
+					widgets.ShowAppInformation("Restored", fmt.Sprintf("%d entries restored.", n), w)
+				})
+			}()
+		}, w)
+	d.Resize(fyne.NewSize(520, 560))
+	d.Show()
+}
@@ -261,18 +261,14 @@ func storedVaultPasswords(appState *app.AppState) []string {
 
 	stored := make([]string, 0, len(entries))
 	for _, entry := range entries {
-		if entry.Type != model.EntryTypePassword {
+		if entry.Type != model.EntryTypePassword || entry.Deleted {
 			continue
 		}
-		sharedSecret, err := app.Decapsulate(entry.KyberCiphertext, privKey)
+		payload, err := app.OpenPasswordPayload(entry, privKey)
 		if err != nil {
 			continue
 		}
-		plaintext, err := app.DecryptAES256GCM(entry.Nonce, entry.Ciphertext, sharedSecret)
-		if err != nil {
-			continue
-		}
-		stored = append(stored, plaintext)
+		stored = append(stored, payload.Password)
 	}
 	return stored
 }
@@ -94,15 +94,11 @@ func (ns *NavigationState) createTOTPView() fyne.CanvasObject {
 
 		var totpEntries []*model.VaultEntry
 		var payloads []string
-		for _, entry := range entries {
+		for _, entry := range app.LiveEntries(entries) {
 			if entry.Type != model.EntryTypeTOTP && !strings.HasPrefix(entry.Service, "TOTP:") {
 				continue
 			}
-			ss, err := app.Decapsulate(entry.KyberCiphertext, ns.appState.PrivateKey)
-			if err != nil {
-				continue
-			}
-			plaintext, err := app.DecryptAES256GCM(entry.Nonce, entry.Ciphertext, ss)
+			plaintext, err := app.OpenEntrySecret(entry, ns.appState.PrivateKey)
 			if err != nil {
 				continue
 			}
@@ -543,24 +539,15 @@ func (ns *NavigationState) showAddTOTPDialog() {
 			customDialog.Hide()
 		}
 
-		// encryptTOTP builds a fresh TOTP entry from params. Returns ct, nonce,
-		// ciphertext suitable for either a new entry or replacing an existing
-		// entry's crypto fields.
-		encryptTOTP := func(params *totp.TOTPParams) (ct, nonce, ciphertext []byte, err error) {
+		// sealTOTP encrypts params into entry, either a new entry or an
+		// existing one whose secret is being replaced. The entry's metadata
+		// must already be set, since it is bound to the ciphertext.
+		sealTOTP := func(entry *model.VaultEntry, params *totp.TOTPParams) error {
 			totpJSON, err := totp.Serialize(params)
 			if err != nil {
-				return nil, nil, nil, err
-			}
-			var ss []byte
-			ct, ss, err = app.Encapsulate(ns.appState.PublicKey)
-			if err != nil {
-				return nil, nil, nil, fmt.Errorf("encapsulation failed: %w", err)
-			}
-			nonce, ciphertext, err = app.EncryptAES256GCM(string(totpJSON), ss)
-			if err != nil {
-				return nil, nil, nil, fmt.Errorf("encryption failed: %w", err)
+				return err
 			}
-			return ct, nonce, ciphertext, nil
+			return app.SealEntrySecret(entry, string(totpJSON), ns.appState.PublicKey)
 		}
 
 		// writeBulk processes paramsList against the current vault entries,
@@ -585,20 +572,16 @@ func (ns *NavigationState) showAddTOTPDialog() {
 					skipped++
 					continue
 				}
-				ct, nonce, ciphertext, err := encryptTOTP(params)
-				if err != nil {
+				entry := model.NewVaultEntry()
+				entry.Type = model.EntryTypeTOTP
+				entry.Service = "TOTP:" + params.Issuer
+				entry.Username = params.Account
+				if err := sealTOTP(entry, params); err != nil {
 					fyne.Do(func() {
 						widgets.ShowAppError(err, ns.window)
 					})
 					return
 				}
-				entry := model.NewVaultEntry()
-				entry.Type = model.EntryTypeTOTP
-				entry.KyberCiphertext = ct
-				entry.Nonce = nonce
-				entry.Ciphertext = ciphertext
-				entry.Service = "TOTP:" + params.Issuer
-				entry.Username = params.Account
 				entries = append(entries, entry)
 				added++
 			}
@@ -641,14 +624,7 @@ func (ns *NavigationState) showAddTOTPDialog() {
 				return
 			}
 
-			ct, nonce, ciphertext, err := encryptTOTP(params)
-			if err != nil {
-				fyne.Do(func() {
-					widgets.ShowAppError(err, ns.window)
-				})
-				return
-			}
-
+			var sealErr error
 			if replaceExisting {
 				var target *model.VaultEntry
 				for _, e := range entries {
@@ -663,19 +639,22 @@ func (ns *NavigationState) showAddTOTPDialog() {
 					})
 					return
 				}
-				target.KyberCiphertext = ct
-				target.Nonce = nonce
-				target.Ciphertext = ciphertext
+				target.Touch(time.Now().UTC())
+				sealErr = sealTOTP(target, params)
 			} else {
 				entry := model.NewVaultEntry()
 				entry.Type = model.EntryTypeTOTP
-				entry.KyberCiphertext = ct
-				entry.Nonce = nonce
-				entry.Ciphertext = ciphertext
 				entry.Service = "TOTP:" + params.Issuer
 				entry.Username = params.Account
+				sealErr = sealTOTP(entry, params)
 				entries = append(entries, entry)
 			}
+			if sealErr != nil {
+				fyne.Do(func() {
+					widgets.ShowAppError(sealErr, ns.window)
+				})
+				return
+			}
 
 			if err := app.WriteVault(entries, vaultFile, ns.appState.MasterPassword); err != nil {
 				fyne.Do(func() {
@@ -773,6 +752,7 @@ func createTOTPItemCard(index int, entry *model.VaultEntry, payload string, w fy
 
 	badge := theme.KindBadge("TOTP")
 	titleRow := container.NewHBox(titleTxt, badge)
+	addOrganizationBadges(titleRow, entry)
 
 	accountTxt := canvas.NewText(params.Account, theme.ColorTextSecondary)
 	accountTxt.TextSize = 11
@@ -806,7 +786,7 @@ func createTOTPItemCard(index int, entry *model.VaultEntry, payload string, w fy
 	})
 
 	left := container.NewHBox(icon, container.NewVBox(titleRow, accountTxt))
-	buttons := container.NewHBox(codeTxt, copyBtn, deleteBtn)
+	buttons := container.NewHBox(codeTxt, copyBtn, organizeButton(entry, w, fyneApp, appState), deleteBtn)
 	row := container.NewBorder(nil, nil, left, buttons)
 
 	return theme.CardWithHeader("", "", nil, row)
@@ -0,0 +1,248 @@
+package screens
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+
+	"passquantum/app"
+	"passquantum/core/filevault"
+	"passquantum/core/model"
+	"passquantum/theme"
+	"passquantum/ui/widgets"
+)
+
+// PrefTrashRetentionDays stores how many days trashed items are kept before
+// they are purged on unlock. 0 means keep them until the trash is emptied.
+const PrefTrashRetentionDays = "pref_trash_retention_days"
+
+const defaultTrashRetentionDays = int(app.DefaultTrashRetention / (24 * time.Hour))
+
+var trashRetentionChoices = []struct {
+	label string
+	days  int
+}{
+	{"7 days", 7},
+	{"30 days", 30},
+	{"90 days", 90},
+	{"Never purge", 0},
+}
+
+// LoadTrashRetention reads the configured trash purge period.
+func LoadTrashRetention(prefs fyne.Preferences) time.Duration {
+	days := prefs.IntWithFallback(PrefTrashRetentionDays, defaultTrashRetentionDays)
+	if days < 0 {
+		days = 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func (ns *NavigationState) createTrashView() fyne.CanvasObject {
+	refresh := func() { ns.switchView(NavViewTrash) }
+
+	emptyBtn := theme.CreateDangerButton("Empty trash", func() {
+		widgets.ShowAppConfirm("Empty trash", "Permanently delete every item and file in the trash? This cannot be undone.", func(ok bool) {
+			if !ok {
+				return
+			}
+			go func() {
+				_, err := app.EmptyTrash(ns.appState)
+				if err == nil {
+					if store, storeErr := ns.ensureFileStore(); storeErr == nil {
+						for _, meta := range store.ListTrash() {
+							if err = store.DeleteFile(meta.UUID); err != nil {
+								break
+							}
+						}
+					}
+				}
+				fyne.Do(func() {
+					if err != nil {
+						widgets.ShowAppError(fmt.Errorf("failed to empty trash: %w", err), ns.window)
+						return
+					}
+					refresh()
+				})
+			}()
+		}, ns.window)
+	})
+
+	subtitle := "Deleted items stay here until you restore them or the purge period runs out."
+	if ns.appState.TrashRetention > 0 {
+		subtitle = fmt.Sprintf("Deleted items are purged %d days after deletion.", int(ns.appState.TrashRetention/(24*time.Hour)))
+	}
+	header := theme.PageHeader(
+		"PASSQUANTUM / "+ns.appState.CurrentVault+" / TRASH",
+		"Trash",
+		subtitle,
+		emptyBtn,
+	)
+
+	itemsContainer := container.NewVBox()
+	loadingText := canvas.NewText("Loading trash...", theme.ColorFg2)
+	loadingText.TextSize = 13
+	itemsContainer.Objects = []fyne.CanvasObject{container.NewCenter(loadingText)}
+
+	go func() {
+		ns.appState.Mu.Lock()
+		vaultFile := app.GetVaultPath(ns.appState.CurrentVault)
+		entries, err := app.ReadVault(vaultFile, ns.appState.MasterPassword)
+		ns.appState.Mu.Unlock()
+		if err != nil {
+			fyne.Do(func() {
+				errText := canvas.NewText("Failed to read vault: "+err.Error(), theme.ColorDanger)
+				errText.TextSize = 13
+				itemsContainer.Objects = []fyne.CanvasObject{errText}
+				itemsContainer.Refresh()
+			})
+			return
+		}
+		trashed := app.TrashedEntries(entries)
+
+		var trashedFiles []*filevault.FileMetadata
+		store, storeErr := ns.ensureFileStore()
+		if storeErr == nil {
+			trashedFiles = store.ListTrash()
+		}
+
+		fyne.Do(func() {
+			var objects []fyne.CanvasObject
+			if len(trashed) > 0 {
+				objects = append(objects, theme.SectionEyebrow(fmt.Sprintf("Vault items · %d", len(trashed))))
+				for _, entry := range trashed {
+					objects = append(objects, ns.trashedEntryCard(entry, refresh))
+				}
+			}
+			if len(trashedFiles) > 0 {
+				objects = append(objects, theme.SectionEyebrow(fmt.Sprintf("Files · %d", len(trashedFiles))))
+				for _, meta := range trashedFiles {
+					objects = append(objects, ns.trashedFileCard(meta, store, refresh))
+				}
+			}
+			if len(objects) == 0 {
+				emptyText := canvas.NewText("The trash is empty", theme.ColorFg2)
+				emptyText.TextSize = 13
+				objects = []fyne.CanvasObject{container.NewCenter(emptyText)}
+			}
+			itemsContainer.Objects = objects
+			itemsContainer.Refresh()
+		})
+	}()
+
+	return container.NewVBox(header, itemsContainer)
+}
+
+func (ns *NavigationState) trashedEntryCard(entry *model.VaultEntry, refresh func()) fyne.CanvasObject {
+	title, kind, icon := trashedEntryLabel(entry)
+
+	titleTxt := canvas.NewText(title, theme.ColorTextPrimary)
+	titleTxt.TextSize = 13
+	titleTxt.TextStyle = fyne.TextStyle{Bold: true}
+	deletedTxt := canvas.NewText("Deleted "+formatEntryTime(entry.DeletedAt), theme.ColorFg2)
+	deletedTxt.TextSize = 11
+
+	restoreBtn := theme.CreateSmallIconButton(theme.IconRefresh, func() {
+		go func() {
+			err := app.RestoreEntry(ns.appState, entry.ID)
+			fyne.Do(func() {
+				if err != nil {
+					widgets.ShowAppError(fmt.Errorf("failed to restore %s: %w", strings.ToLower(kind), err), ns.window)
+					return
+				}
+				refresh()
+			})
+		}()
+	})
+	purgeBtn := theme.CreateSmallIconButton(theme.IconTrash, func() {
+		widgets.ShowAppConfirm("Delete permanently", fmt.Sprintf("Permanently delete '%s'? This cannot be undone.", title), func(ok bool) {
+			if !ok {
+				return
+			}
+			go func() {
+				err := app.PurgeEntry(ns.appState, entry.ID)
+				fyne.Do(func() {
+					if err != nil {
+						widgets.ShowAppError(fmt.Errorf("failed to delete %s: %w", strings.ToLower(kind), err), ns.window)
+						return
+					}
+					refresh()
+				})
+			}()
+		}, ns.window)
+	})
+
+	left := container.NewHBox(
+		theme.TypeIcon(icon, theme.ColorAccentCyan),
+		container.NewVBox(container.NewHBox(titleTxt, theme.KindBadge(kind)), deletedTxt),
+	)
+	row := container.NewBorder(nil, nil, left, container.NewHBox(restoreBtn, purgeBtn))
+	return theme.CardWithHeader("", "", nil, row)
+}
+
+func (ns *NavigationState) trashedFileCard(meta *filevault.FileMetadata, store *filevault.Store, refresh func()) fyne.CanvasObject {
+	titleTxt := canvas.NewText(meta.OriginalName, theme.ColorTextPrimary)
+	titleTxt.TextSize = 13
+	titleTxt.TextStyle = fyne.TextStyle{Bold: true}
+	detailTxt := canvas.NewText(humanSize(meta.Size)+" · deleted "+formatEntryTime(meta.DeletedAt), theme.ColorFg2)
+	detailTxt.TextSize = 11
+
+	restoreBtn := theme.CreateSmallIconButton(theme.IconRefresh, func() {
+		go func() {
+			err := store.RestoreFile(meta.UUID)
+			fyne.Do(func() {
+				if err != nil {
+					widgets.ShowAppError(fmt.Errorf("failed to restore file: %w", err), ns.window)
+					return
+				}
+				refresh()
+			})
+		}()
+	})
+	purgeBtn := theme.CreateSmallIconButton(theme.IconTrash, func() {
+		widgets.ShowAppConfirm("Delete permanently", fmt.Sprintf("Permanently delete '%s'? This cannot be undone.", meta.OriginalName), func(ok bool) {
+			if !ok {
+				return
+			}
+			go func() {
+				err := store.DeleteFile(meta.UUID)
+				fyne.Do(func() {
+					if err != nil {
+						widgets.ShowAppError(fmt.Errorf("failed to delete file: %w", err), ns.window)
+						return
+					}
+					refresh()
+				})
+			}()
+		}, ns.window)
+	})
+
+	left := container.NewHBox(
+		theme.TypeIcon(theme.IconFile, theme.ColorAccentCyan),
+		container.NewVBox(container.NewHBox(titleTxt, theme.KindBadge("File")), detailTxt),
+	)
+	row := container.NewBorder(nil, nil, left, container.NewHBox(restoreBtn, purgeBtn))
+	return theme.CardWithHeader("", "", nil, row)
+}
+
+// trashedEntryLabel returns the display title, kind badge and icon for a
+// trashed entry without decrypting it.
+func trashedEntryLabel(entry *model.VaultEntry) (string, string, *fyne.StaticResource) {
+	switch {
+	case entry.Type == model.EntryTypeNote || strings.HasPrefix(entry.Service, "NOTE:"):
+		return strings.TrimPrefix(entry.Service, "NOTE:"), "Note", theme.IconNote
+	case entry.Type == model.EntryTypeCard || strings.HasPrefix(entry.Service, "CARD:"):
+		return strings.TrimPrefix(entry.Service, "CARD:"), "Card", theme.IconCard
+	case entry.Type == model.EntryTypeTOTP || strings.HasPrefix(entry.Service, "TOTP:"):
+		return strings.TrimPrefix(entry.Service, "TOTP:"), "TOTP", theme.IconClock
+	case entry.Type == model.EntryTypeFile:
+		return entry.Service, "File", theme.IconFile
+	case entry.Type == model.EntryTypeSSHKey:
+		return strings.TrimPrefix(entry.Service, app.SSHKeyServicePrefix), "SSH key", theme.IconKey
+	default:
+		return entry.Service, "Password", theme.IconKey
+	}
+}